@@ -20,17 +20,21 @@ package agents
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cgrates/cgrates/config"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/cgrates/rpcclient"
 	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
 	"github.com/fiorix/go-diameter/diam/datatype"
 	"github.com/fiorix/go-diameter/diam/sm"
 )
 
-func NewDiameterAgent(cgrCfg *config.CGRConfig, smg *rpcclient.RpcClient) (*DiameterAgent, error) {
-	da := &DiameterAgent{cgrCfg: cgrCfg, smg: smg}
+func NewDiameterAgent(cgrCfg *config.CGRConfig, smg rpcclient.RpcClientConnection) (*DiameterAgent, error) {
+	da := &DiameterAgent{cgrCfg: cgrCfg, smg: smg, msccUsage: newMsccUsageTracker(), peers: newDiameterPeers()}
 	dictsDir := cgrCfg.DiameterAgentCfg().DictionariesDir
 	if len(dictsDir) != 0 {
 		if err := loadDictionaries(dictsDir, "DiameterAgent"); err != nil {
@@ -41,8 +45,87 @@ func NewDiameterAgent(cgrCfg *config.CGRConfig, smg *rpcclient.RpcClient) (*Diam
 }
 
 type DiameterAgent struct {
-	cgrCfg *config.CGRConfig
-	smg    *rpcclient.RpcClient // Connection towards CGR-SMG component
+	cgrCfg    *config.CGRConfig
+	smg       rpcclient.RpcClientConnection // Connection towards CGR-SMG component
+	msccUsage *msccUsageTracker             // Units used per MSCC, needed to charge the totals on CCR-T
+	peers     *diameterPeers                // Peers connected, with their state and statistics
+}
+
+func newMsccUsageTracker() *msccUsageTracker {
+	return &msccUsageTracker{sessions: make(map[string]*msccSession)}
+}
+
+// Usage reported within MSCC over the CCRs of one session
+type msccSession struct {
+	used       map[string]int64 // Units used, indexed on MSCC id
+	reqNrs     map[int]bool     // CC-Request-Numbers already counted, retransmissions being ignored
+	lastReqNr  int              // CC-Request-Number of the last answer
+	lastAnswer []*MSCCAnswer    // MSCC answered to the last request, sent again on retransmissions
+	lastActive time.Time
+}
+
+// Sums up the Used-Service-Unit reported within MSCC over the CCRs of a session
+type msccUsageTracker struct {
+	sync.Mutex
+	sessions  map[string]*msccSession // Indexed on Session-Id
+	lastSweep time.Time
+}
+
+// Adds the units reported by the CCR and populates each MSCC with the total used so far.
+// Returns true if the CCR was already counted, as happens on retransmissions.
+func (self *msccUsageTracker) track(ccr *CCR, sessionTTL time.Duration) (duplicate bool) {
+	self.Lock()
+	defer self.Unlock()
+	now := time.Now()
+	self.expire(now, sessionTTL)
+	if len(ccr.MultipleServicesCreditControl) == 0 {
+		return false
+	}
+	sess, hasIt := self.sessions[ccr.SessionId]
+	if !hasIt || (ccr.CCRequestType == 1 && !sess.reqNrs[ccr.CCRequestNumber]) { // Fresh session with the same id
+		sess = &msccSession{used: make(map[string]int64), reqNrs: make(map[int]bool)}
+		self.sessions[ccr.SessionId] = sess
+	}
+	sess.lastActive = now
+	duplicate = sess.reqNrs[ccr.CCRequestNumber]
+	sess.reqNrs[ccr.CCRequestNumber] = true
+	if duplicate && sess.lastReqNr == ccr.CCRequestNumber {
+		ccr.msccAnswered = sess.lastAnswer
+	}
+	for _, mscc := range ccr.MultipleServicesCreditControl {
+		if !duplicate {
+			_, units := mscc.UsedServiceUnit.Units()
+			sess.used[mscc.Id()] += units
+		}
+		mscc.usedTotal = sess.used[mscc.Id()]
+	}
+	if ccr.CCRequestType == 3 {
+		delete(self.sessions, ccr.SessionId)
+	}
+	return duplicate
+}
+
+// Keeps the MSCC answered so retransmissions of the request get the same answer without charging again
+func (self *msccUsageTracker) answered(ccr *CCR, msccAs []*MSCCAnswer) {
+	self.Lock()
+	defer self.Unlock()
+	if sess, hasIt := self.sessions[ccr.SessionId]; hasIt {
+		sess.lastReqNr = ccr.CCRequestNumber
+		sess.lastAnswer = msccAs
+	}
+}
+
+// Drops the sessions inactive for longer than ttl, checking at most once per ttl
+func (self *msccUsageTracker) expire(now time.Time, ttl time.Duration) {
+	if ttl == 0 || now.Sub(self.lastSweep) < ttl {
+		return
+	}
+	self.lastSweep = now
+	for sessionId, sess := range self.sessions {
+		if now.Sub(sess.lastActive) > ttl {
+			delete(self.sessions, sessionId)
+		}
+	}
 }
 
 // Creates the message handlers
@@ -56,28 +139,100 @@ func (self *DiameterAgent) handlers() diam.Handler {
 	}
 	dSM := sm.New(settings)
 	dSM.HandleFunc("CCR", self.handleCCR)
+	dSM.HandleFunc("DPR", self.handleDPR)
+	dSM.HandleFunc("DPA", self.handleDPA)
+	dSM.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {}) // Tracked already as peer activity
 	dSM.HandleFunc("ALL", self.handleALL)
 	go func() {
 		for err := range dSM.ErrorReports() {
 			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> StateMachine error: %+v", err))
 		}
 	}()
-	return dSM
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) { // Track peers before passing the messages to state machine
+		if peer, isNew := self.peers.received(c, m); isNew {
+			go self.watchPeer(c, peer.RemoteAddr)
+		}
+		dSM.ServeDIAM(c, m)
+	})
 }
 
-func (self DiameterAgent) processCCR(ccr *CCR, reqProcessor *config.DARequestProcessor) (*CCA, error) {
-	passesAllFilters := true
-	for _, fldFilter := range reqProcessor.RequestFilter {
-		if !ccr.passesFieldFilter(fldFilter) {
-			passesAllFilters = false
+// Builds a request originated by the agent towards one of the peers
+func (self *DiameterAgent) newRequest(cmdCode uint32) (*diam.Message, error) {
+	m := diam.NewRequest(cmdCode, 0, nil)
+	if _, err := m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity(self.cgrCfg.DiameterAgentCfg().OriginHost)); err != nil {
+		return nil, err
+	}
+	if _, err := m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity(self.cgrCfg.DiameterAgentCfg().OriginRealm)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sends Device-Watchdog-Requests towards idle peer and cleans it up once the transport goes down
+func (self *DiameterAgent) watchPeer(c diam.Conn, addr string) {
+	defer self.peers.remove(addr)
+	var tick <-chan time.Time
+	if wdInterval := self.cgrCfg.DiameterAgentCfg().WatchdogInterval; wdInterval != 0 {
+		ticker := time.NewTicker(wdInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-c.CloseNotify():
+			return
+		case <-tick:
+			sendDWR, closeConn := self.peers.watchdogDue(addr, self.cgrCfg.DiameterAgentCfg().WatchdogInterval)
+			if closeConn {
+				utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> No watchdog answer from peer %s, closing connection", addr))
+				c.Close()
+				return
+			} else if !sendDWR {
+				continue
+			}
+			if dwr, err := self.newRequest(diam.DeviceWatchdog); err != nil {
+				utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed building DWR, error: %s", err.Error()))
+			} else if _, err := dwr.WriteTo(c); err != nil {
+				utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed to write DWR to %s: %s", addr, err.Error()))
+			} else {
+				self.peers.sent(c, "DWR")
+			}
 		}
 	}
-	if !passesAllFilters { // Not going with this processor further
-		return nil, nil
+}
+
+// Answers the peer disconnect, the transport being closed by the peer once receiving DPA
+func (self *DiameterAgent) handleDPR(c diam.Conn, m *diam.Message) {
+	self.peers.setState(c, PEER_STATE_CLOSING)
+	dpa := m.Answer(diam.Success)
+	if _, err := dpa.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity(self.cgrCfg.DiameterAgentCfg().OriginHost)); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed building DPA, error: %s", err.Error()))
+		return
 	}
+	if _, err := dpa.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity(self.cgrCfg.DiameterAgentCfg().OriginRealm)); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed building DPA, error: %s", err.Error()))
+		return
+	}
+	if _, err := dpa.WriteTo(c); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed to write DPA to %s: %s", c.RemoteAddr(), err.Error()))
+		return
+	}
+	self.peers.sent(c, "DPA")
+}
+
+// Answer to our own DPR, we are the ones closing the transport
+func (self *DiameterAgent) handleDPA(c diam.Conn, m *diam.Message) {
+	c.Close()
+}
+
+// Sends the event built out of CCR to SMG, returning the usage granted
+func (self DiameterAgent) chargeCCR(ccr *CCR, reqProcessor *config.DARequestProcessor) (float64, string, error) {
 	smgEv, err := ccr.AsSMGenericEvent(reqProcessor.ContentFields)
 	if err != nil {
-		return nil, err
+		return 0, "", err
+	}
+	if ccr.msccIdx != -1 { // Each MSCC is charged as separate session
+		smgEv[utils.ACCID] = smgEv.GetUUID() + utils.CONCATENATED_KEY_SEP + ccr.MultipleServicesCreditControl[ccr.msccIdx].Id()
 	}
 	var maxUsage float64
 	switch ccr.CCRequestType {
@@ -92,23 +247,103 @@ func (self DiameterAgent) processCCR(ccr *CCR, reqProcessor *config.DARequestPro
 			err = errCdr
 		}
 	}
-	if err != nil {
-		return nil, err
+	return maxUsage, smgEv.GetTOR(utils.META_DEFAULT), err
+}
+
+func (self DiameterAgent) processCCR(ccr *CCR, reqProcessor *config.DARequestProcessor) (*CCA, error) {
+	passesAllFilters := true
+	for _, fldFilter := range reqProcessor.RequestFilter {
+		if !ccr.passesFieldFilter(fldFilter) {
+			passesAllFilters = false
+		}
+	}
+	if !passesAllFilters { // Not going with this processor further
+		return nil, nil
 	}
 	cca := NewCCAFromCCR(ccr)
 	cca.OriginHost = self.cgrCfg.DiameterAgentCfg().OriginHost
 	cca.OriginRealm = self.cgrCfg.DiameterAgentCfg().OriginRealm
-	cca.GrantedServiceUnit.CCTime = int(maxUsage)
 	cca.ResultCode = diam.Success
+	var grantedUsage float64 // Usage granted in total, available to CCA templates
+	if ccr.retransmitted {
+		if ccr.msccAnswered == nil { // Original request still being processed
+			cca.ResultCode = diam.TooBusy
+		}
+		for _, msccA := range ccr.msccAnswered {
+			_, units := msccA.GrantedServiceUnit.Units()
+			grantedUsage += float64(units)
+		}
+		cca.MultipleServicesCreditControl = ccr.msccAnswered
+	} else if len(ccr.MultipleServicesCreditControl) != 0 {
+		for i, mscc := range ccr.MultipleServicesCreditControl {
+			ccr.msccIdx = i
+			msccA := &MSCCAnswer{RatingGroup: mscc.RatingGroup, ServiceIdentifier: mscc.ServiceIdentifier, ResultCode: diam.Success}
+			maxUsage, tor, err := self.chargeCCR(ccr, reqProcessor)
+			if err != nil {
+				utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Error charging MSCC with id: %s, session: %s, error: %s", mscc.Id(), ccr.SessionId, err.Error()))
+				msccA.ResultCode = self.resultCode(err)
+			} else if ccr.CCRequestType != 3 {
+				if maxUsage == 0 {
+					msccA.ResultCode = self.resultCode(utils.ErrInsufficientCredit)
+				} else {
+					grantedUsage += maxUsage
+					if rsuTor, _ := mscc.RequestedServiceUnit.Units(); rsuTor != "" {
+						tor = rsuTor
+					}
+					msccA.GrantedServiceUnit.SetUnits(tor, int64(maxUsage))
+					msccA.ValidityTime = int(self.cgrCfg.DiameterAgentCfg().ValidityTime.Seconds())
+					if fua := self.cgrCfg.DiameterAgentCfg().FinalUnitAction; len(fua) != 0 && maxUsage < ccr.msccUsage() {
+						if msccA.FinalUnitIndication, err = NewFinalUnitIndication(fua, self.cgrCfg.DiameterAgentCfg().RedirectAddrType, self.cgrCfg.DiameterAgentCfg().RedirectAddress); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+			cca.MultipleServicesCreditControl = append(cca.MultipleServicesCreditControl, msccA)
+		}
+		ccr.msccIdx = -1
+		self.msccUsage.answered(ccr, cca.MultipleServicesCreditControl)
+	} else if maxUsage, _, err := self.chargeCCR(ccr, reqProcessor); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Error charging session: %s, error: %s", ccr.SessionId, err.Error()))
+		cca.ResultCode = self.resultCode(err)
+	} else if maxUsage == 0 && ccr.CCRequestType != 3 {
+		cca.ResultCode = self.resultCode(utils.ErrInsufficientCredit)
+	} else {
+		grantedUsage = maxUsage
+		cca.GrantedServiceUnit.CCTime = int(maxUsage)
+		if fua := self.cgrCfg.DiameterAgentCfg().FinalUnitAction; len(fua) != 0 && ccr.CCRequestType != 3 &&
+			maxUsage < usageFromCCR(ccr.CCRequestType, ccr.CCRequestNumber, ccr.RequestedServiceUnit.CCTime, ccr.debitInterval).Seconds() {
+			if err := cca.SetFinalUnitIndication(fua, self.cgrCfg.DiameterAgentCfg().RedirectAddrType, self.cgrCfg.DiameterAgentCfg().RedirectAddress); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := cca.SetProcessorAVPs(reqProcessor.CCAFields, grantedUsage, self.cgrCfg.DiameterAgentCfg().Timezone); err != nil {
+		return nil, err
+	}
 	return cca, nil
 }
 
+// Maps the error into Result-Code out of the first configured error contained in its message, errors being flattened by RPC
+func (self DiameterAgent) resultCode(err error) int {
+	for _, rc := range self.cgrCfg.DiameterAgentCfg().ResultCodes {
+		if strings.Contains(err.Error(), rc.Error) {
+			return rc.ResultCode
+		}
+	}
+	return diam.UnableToComply
+}
+
 func (self *DiameterAgent) handleCCR(c diam.Conn, m *diam.Message) {
 	ccr, err := NewCCRFromDiameterMessage(m, self.cgrCfg.DiameterAgentCfg().DebitInterval)
 	if err != nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Unmarshaling message: %s, error: %s", m, err))
 		return
 	}
+	if self.msccUsage.track(ccr, self.cgrCfg.DiameterAgentCfg().SessionTTL) {
+		utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> Retransmission of CCR with number %d for session: %s, not charging again", ccr.CCRequestNumber, ccr.SessionId))
+		ccr.retransmitted = true
+	}
 	var cca *CCA // For now we simply overload in loop, maybe we will find some other use of this
 	for _, reqProcessor := range self.cgrCfg.DiameterAgentCfg().RequestProcessors {
 		if cca, err = self.processCCR(ccr, reqProcessor); err != nil {
@@ -118,8 +353,12 @@ func (self *DiameterAgent) handleCCR(c diam.Conn, m *diam.Message) {
 			break
 		}
 	}
-	if err != nil { //ToDo: return standard diameter error
-		return
+	if err != nil { // Answer with the error instead of leaving the client to timeout
+		self.peers.failed(c)
+		cca = NewCCAFromCCR(ccr)
+		cca.OriginHost = self.cgrCfg.DiameterAgentCfg().OriginHost
+		cca.OriginRealm = self.cgrCfg.DiameterAgentCfg().OriginRealm
+		cca.ResultCode = self.resultCode(err)
 	} else if cca == nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> No request processor enabled for CCR: %+v, ignoring request", ccr))
 		return
@@ -131,12 +370,47 @@ func (self *DiameterAgent) handleCCR(c diam.Conn, m *diam.Message) {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Failed to write message to %s: %s\n%s\n", c.RemoteAddr(), err, dmtA))
 		return
 	}
+	self.peers.sent(c, "CCA")
 }
 
 func (self *DiameterAgent) handleALL(c diam.Conn, m *diam.Message) {
 	utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> Received unexpected message from %s:\n%s", c.RemoteAddr(), m))
 }
 
+// Returns status and statistics of the peers connected
+func (self *DiameterAgent) Peers() []*DiameterPeer {
+	return self.peers.status()
+}
+
+// Disconnects gracefully the peers, closing the transports not answering within DPR_TIMEOUT
+func (self *DiameterAgent) Shutdown() error {
+	for _, c := range self.peers.closeOpen() {
+		dpr, err := self.newRequest(diam.DisconnectPeer)
+		if err != nil {
+			return err
+		}
+		if _, err := dpr.NewAVP(273, avp.Mbit, 0, datatype.Enumerated(DISCONNECT_REBOOTING)); err != nil { // Disconnect-Cause
+			return err
+		}
+		if _, err := dpr.WriteTo(c); err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> Failed to write DPR to %s: %s", c.RemoteAddr(), err.Error()))
+			c.Close()
+			continue
+		}
+		self.peers.sent(c, "DPR")
+	}
+	timeout := time.After(DPR_TIMEOUT)
+	for self.peers.count() != 0 {
+		select {
+		case <-timeout:
+			self.peers.closeAll()
+			return nil
+		case <-time.After(time.Duration(100) * time.Millisecond):
+		}
+	}
+	return nil
+}
+
 func (self *DiameterAgent) ListenAndServe() error {
 	return diam.ListenAndServe(self.cgrCfg.DiameterAgentCfg().Listen, self.handlers(), nil)
 }
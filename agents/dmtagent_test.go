@@ -0,0 +1,66 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+	"github.com/fiorix/go-diameter/diam"
+)
+
+func TestDiameterAgentResultCode(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	da := DiameterAgent{cgrCfg: cgrCfg}
+	if rc := da.resultCode(utils.ErrInsufficientCredit); rc != 4012 {
+		t.Error(rc)
+	}
+	if rc := da.resultCode(utils.NewErrServerError(utils.ErrAccountDisabled)); rc != 4010 {
+		t.Error(rc)
+	}
+	if rc := da.resultCode(errors.New("UNKNOWN_ERROR")); rc != diam.UnableToComply {
+		t.Error(rc)
+	}
+}
+
+func TestDiameterAgentResultCodeOrder(t *testing.T) {
+	cgrCfg, err := config.NewCGRConfigFromJsonStringWithDefaults(`{"diameter_agent": {"result_codes": [
+		{"error": "ACCOUNT_DISABLED_TEMPORARY", "result_code": 4011},
+		{"error": "INSUFFICIENT_CREDIT", "result_code": 4013}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	da := DiameterAgent{cgrCfg: cgrCfg}
+	for i := 0; i < 10; i++ { // Same answer on each run when more errors match
+		if rc := da.resultCode(errors.New("SERVER_ERROR: ACCOUNT_DISABLED_TEMPORARY")); rc != 4011 {
+			t.Error(rc)
+		}
+	}
+	if rc := da.resultCode(utils.ErrInsufficientCredit); rc != 4013 {
+		t.Error(rc)
+	}
+	if rc := da.resultCode(utils.ErrAccountDisabled); rc != 4010 {
+		t.Error(rc)
+	}
+	if len(cgrCfg.DiameterAgentCfg().ResultCodes) != 5 {
+		t.Errorf("Unexpected result codes: %s", utils.ToJSON(cgrCfg.DiameterAgentCfg().ResultCodes))
+	}
+}
@@ -0,0 +1,212 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fiorix/go-diameter/diam"
+	"github.com/fiorix/go-diameter/diam/avp"
+	"github.com/fiorix/go-diameter/diam/dict"
+)
+
+const (
+	PEER_STATE_CONNECTED  = "CONNECTED" // transport established, capabilities not yet exchanged
+	PEER_STATE_OPEN       = "OPEN"      // capabilities exchanged, ready for application messages
+	PEER_STATE_CLOSING    = "CLOSING"   // disconnect requested by one of the sides
+	MAX_WATCHDOG_FAILURES = 2           // unanswered DWRs before considering the transport down
+	DPR_TIMEOUT           = time.Duration(2) * time.Second
+	DISCONNECT_REBOOTING  = 0 // Disconnect-Cause value sent on shutdown
+)
+
+// Abbreviations of the commands we track, the rest being tracked by their code
+var diamCmdAbbrevs = map[uint32]string{
+	diam.CapabilitiesExchange: "CE",
+	diam.DeviceWatchdog:       "DW",
+	diam.DisconnectPeer:       "DP",
+	diam.CreditControl:        "CC",
+}
+
+// Returns command abbreviation out of message, eg: CCR
+func diamCmdName(m *diam.Message) string {
+	name, hasIt := diamCmdAbbrevs[m.Header.CommandCode]
+	if !hasIt {
+		name = strconv.Itoa(int(m.Header.CommandCode))
+	}
+	if m.Header.CommandFlags&diam.RequestFlag == diam.RequestFlag {
+		return name + "R"
+	}
+	return name + "A"
+}
+
+// Status and statistics of one diameter peer connected to the agent
+type DiameterPeer struct {
+	RemoteAddr   string
+	OriginHost   string
+	OriginRealm  string
+	State        string
+	ConnectedAt  time.Time
+	LastActivity time.Time
+	Received     map[string]int64 // messages received, indexed on command abbreviation (eg: CCR)
+	Sent         map[string]int64 // messages sent out by the agent, indexed on command abbreviation
+	Errors       int64            // requests we could not answer
+	conn         diam.Conn
+	pendingDWRs  int // DWRs sent without receiving an answer
+}
+
+// Returns a copy, safe to be used outside of the lock
+func (self *DiameterPeer) Clone() *DiameterPeer {
+	clned := *self
+	clned.Received = make(map[string]int64, len(self.Received))
+	for cmd, cnt := range self.Received {
+		clned.Received[cmd] = cnt
+	}
+	clned.Sent = make(map[string]int64, len(self.Sent))
+	for cmd, cnt := range self.Sent {
+		clned.Sent[cmd] = cnt
+	}
+	return &clned
+}
+
+func newDiameterPeers() *diameterPeers {
+	return &diameterPeers{peers: make(map[string]*DiameterPeer)}
+}
+
+// Tracks the peers connected, indexed on their remote address
+type diameterPeers struct {
+	sync.RWMutex
+	peers map[string]*DiameterPeer
+}
+
+// Records a message received, returns the peer and whether it was seen for the first time
+func (self *diameterPeers) received(c diam.Conn, m *diam.Message) (*DiameterPeer, bool) {
+	self.Lock()
+	defer self.Unlock()
+	addr := c.RemoteAddr().String()
+	peer, hasIt := self.peers[addr]
+	if !hasIt {
+		peer = &DiameterPeer{RemoteAddr: addr, State: PEER_STATE_CONNECTED, ConnectedAt: time.Now(),
+			Received: make(map[string]int64), Sent: make(map[string]int64), conn: c}
+		self.peers[addr] = peer
+	}
+	peer.LastActivity = time.Now()
+	peer.pendingDWRs = 0 // Any traffic received proves the transport alive, RFC 3539
+	cmdName := diamCmdName(m)
+	peer.Received[cmdName] += 1
+	if cmdName == "CER" {
+		if originHost, err := m.FindAVP(avp.OriginHost, dict.UndefinedVendorID); err == nil && originHost != nil {
+			peer.OriginHost = avpValAsString(originHost)
+		}
+		if originRealm, err := m.FindAVP(avp.OriginRealm, dict.UndefinedVendorID); err == nil && originRealm != nil {
+			peer.OriginRealm = avpValAsString(originRealm)
+		}
+		peer.State = PEER_STATE_OPEN
+	}
+	return peer, !hasIt
+}
+
+// Records a message sent out towards the peer
+func (self *diameterPeers) sent(c diam.Conn, cmdName string) {
+	self.Lock()
+	defer self.Unlock()
+	if peer, hasIt := self.peers[c.RemoteAddr().String()]; hasIt {
+		peer.Sent[cmdName] += 1
+	}
+}
+
+// Records a request which could not be answered
+func (self *diameterPeers) failed(c diam.Conn) {
+	self.Lock()
+	defer self.Unlock()
+	if peer, hasIt := self.peers[c.RemoteAddr().String()]; hasIt {
+		peer.Errors += 1
+	}
+}
+
+func (self *diameterPeers) setState(c diam.Conn, state string) {
+	self.Lock()
+	defer self.Unlock()
+	if peer, hasIt := self.peers[c.RemoteAddr().String()]; hasIt {
+		peer.State = state
+	}
+}
+
+func (self *diameterPeers) remove(addr string) {
+	self.Lock()
+	delete(self.peers, addr)
+	self.Unlock()
+}
+
+// Decides on watchdog for an idle peer: true if a DWR should be sent, false with closeConn when the transport is considered down
+func (self *diameterPeers) watchdogDue(addr string, interval time.Duration) (sendDWR, closeConn bool) {
+	self.Lock()
+	defer self.Unlock()
+	peer, hasIt := self.peers[addr]
+	if !hasIt || peer.State != PEER_STATE_OPEN || time.Since(peer.LastActivity) < interval {
+		return false, false
+	}
+	if peer.pendingDWRs >= MAX_WATCHDOG_FAILURES {
+		return false, true
+	}
+	peer.pendingDWRs += 1
+	return true, false
+}
+
+// Returns the connections of the peers in state OPEN, marking them as CLOSING
+func (self *diameterPeers) closeOpen() []diam.Conn {
+	self.Lock()
+	defer self.Unlock()
+	var conns []diam.Conn
+	for _, peer := range self.peers {
+		if peer.State == PEER_STATE_OPEN {
+			peer.State = PEER_STATE_CLOSING
+			conns = append(conns, peer.conn)
+		}
+	}
+	return conns
+}
+
+// Closes the transport towards all peers still connected
+func (self *diameterPeers) closeAll() {
+	self.Lock()
+	defer self.Unlock()
+	for addr, peer := range self.peers {
+		peer.conn.Close()
+		delete(self.peers, addr)
+	}
+}
+
+func (self *diameterPeers) count() int {
+	self.RLock()
+	defer self.RUnlock()
+	return len(self.peers)
+}
+
+// Returns copies of the peers tracked
+func (self *diameterPeers) status() []*DiameterPeer {
+	self.RLock()
+	defer self.RUnlock()
+	peers := make([]*DiameterPeer, 0, len(self.peers))
+	for _, peer := range self.peers {
+		peers = append(peers, peer.Clone())
+	}
+	return peers
+}
@@ -0,0 +1,73 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/cgrates/cgrates/utils"
+	"github.com/cgrates/rpcclient"
+)
+
+func NewFailoverConnection(conns []rpcclient.RpcClientConnection) *FailoverConnection {
+	return &FailoverConnection{conns: conns}
+}
+
+// Connections towards redundant servers, the next one being used once the active one fails on transport level
+type FailoverConnection struct {
+	sync.RWMutex
+	conns  []rpcclient.RpcClientConnection
+	active int // index of the connection currently used
+}
+
+func (self *FailoverConnection) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	self.RLock()
+	active := self.active
+	self.RUnlock()
+	var err error
+	for i := 0; i < len(self.conns); i++ {
+		connIdx := (active + i) % len(self.conns)
+		if err = self.conns[connIdx].Call(serviceMethod, args, reply); isNetworkError(err) {
+			continue
+		}
+		if connIdx != active {
+			utils.Logger.Warning(fmt.Sprintf("<FailoverConnection> Failed over to connection with index: %d", connIdx))
+			self.Lock()
+			self.active = connIdx
+			self.Unlock()
+		}
+		return err
+	}
+	return err
+}
+
+// Errors caused by transport, application errors are returned as they are
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if _, isNetErr := err.(net.Error); isNetErr {
+		return true
+	}
+	return err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,62 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/cgrates/rpcclient"
+)
+
+type testConn struct {
+	err   error
+	calls int
+}
+
+func (self *testConn) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	self.calls += 1
+	return self.err
+}
+
+func TestFailoverConnection(t *testing.T) {
+	primary, secondary := &testConn{err: io.EOF}, &testConn{}
+	fc := NewFailoverConnection([]rpcclient.RpcClientConnection{primary, secondary})
+	var rpl string
+	if err := fc.Call("SMGenericV1.SessionEnd", nil, &rpl); err != nil {
+		t.Error(err)
+	}
+	if fc.active != 1 || primary.calls != 1 || secondary.calls != 1 {
+		t.Errorf("Unexpected failover, active: %d, primary calls: %d, secondary calls: %d", fc.active, primary.calls, secondary.calls)
+	}
+	// Application errors should not fail over
+	secondary.err = errors.New("INSUFFICIENT_CREDIT")
+	if err := fc.Call("SMGenericV1.SessionEnd", nil, &rpl); err == nil || err.Error() != "INSUFFICIENT_CREDIT" {
+		t.Error(err)
+	}
+	if fc.active != 1 || primary.calls != 1 {
+		t.Errorf("Unexpected failover, active: %d, primary calls: %d", fc.active, primary.calls)
+	}
+	// All down returns the last transport error
+	secondary.err = io.ErrUnexpectedEOF
+	if err := fc.Call("SMGenericV1.SessionEnd", nil, &rpl); err != io.EOF {
+		t.Error(err)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -49,7 +50,12 @@ func init() {
 const (
 	META_CCR_USAGE          = "*ccr_usage"
 	META_CCR_SMG_EVENT_NAME = "*ccr_smg_event_name"
+	META_CCA_USAGE          = "*cca_usage"
 	DIAMETER_CCR            = "DIAMETER_CCR"
+	// Final-Unit-Action values, RFC 4006
+	FUA_TERMINATE       = 0
+	FUA_REDIRECT        = 1
+	FUA_RESTRICT_ACCESS = 2
 )
 
 func loadDictionaries(dictsDir, componentId string) error {
@@ -153,9 +159,154 @@ func NewCCRFromDiameterMessage(m *diam.Message, debitInterval time.Duration) (*C
 	}
 	ccr.diamMessage = m
 	ccr.debitInterval = debitInterval
+	ccr.msccIdx = -1
 	return &ccr, nil
 }
 
+// Units as carried by Requested-Service-Unit, Used-Service-Unit and Granted-Service-Unit
+type ServiceUnit struct {
+	CCTime                 int   `avp:"CC-Time"`
+	CCTotalOctets          int64 `avp:"CC-Total-Octets"`
+	CCInputOctets          int64 `avp:"CC-Input-Octets"`
+	CCOutputOctets         int64 `avp:"CC-Output-Octets"`
+	CCServiceSpecificUnits int64 `avp:"CC-Service-Specific-Units"`
+}
+
+// Returns the type of units carried, as TOR, together with their amount.
+// Time is preferred over octets and octets over service specific units.
+func (self *ServiceUnit) Units() (string, int64) {
+	switch {
+	case self.CCTime != 0:
+		return utils.VOICE, int64(self.CCTime)
+	case self.CCTotalOctets != 0:
+		return utils.DATA, self.CCTotalOctets
+	case self.CCInputOctets != 0 || self.CCOutputOctets != 0:
+		return utils.DATA, self.CCInputOctets + self.CCOutputOctets
+	case self.CCServiceSpecificUnits != 0:
+		return utils.GENERIC, self.CCServiceSpecificUnits
+	}
+	return "", 0
+}
+
+// Populates the units matching the TOR, time being the default
+func (self *ServiceUnit) SetUnits(tor string, units int64) {
+	switch tor {
+	case utils.DATA:
+		self.CCTotalOctets = units
+	case utils.GENERIC, utils.SMS:
+		self.CCServiceSpecificUnits = units
+	default:
+		self.CCTime = int(units)
+	}
+}
+
+// Builds the grouped AVP out of the populated units
+func (self *ServiceUnit) AsGroupedAVP() *diam.GroupedAVP {
+	grp := new(diam.GroupedAVP)
+	if self.CCTime != 0 {
+		grp.AddAVP(diam.NewAVP(420, avp.Mbit, 0, datatype.Unsigned32(self.CCTime))) // CC-Time
+	}
+	if self.CCTotalOctets != 0 {
+		grp.AddAVP(diam.NewAVP(421, avp.Mbit, 0, datatype.Unsigned64(self.CCTotalOctets))) // CC-Total-Octets
+	}
+	if self.CCInputOctets != 0 {
+		grp.AddAVP(diam.NewAVP(412, avp.Mbit, 0, datatype.Unsigned64(self.CCInputOctets))) // CC-Input-Octets
+	}
+	if self.CCOutputOctets != 0 {
+		grp.AddAVP(diam.NewAVP(414, avp.Mbit, 0, datatype.Unsigned64(self.CCOutputOctets))) // CC-Output-Octets
+	}
+	if self.CCServiceSpecificUnits != 0 {
+		grp.AddAVP(diam.NewAVP(417, avp.Mbit, 0, datatype.Unsigned64(self.CCServiceSpecificUnits))) // CC-Service-Specific-Units
+	}
+	return grp
+}
+
+// Multiple-Services-Credit-Control as received within CCR
+type MultipleServicesCreditControl struct {
+	RatingGroup          int         `avp:"Rating-Group"`
+	ServiceIdentifier    int         `avp:"Service-Identifier"`
+	RequestedServiceUnit ServiceUnit `avp:"Requested-Service-Unit"`
+	UsedServiceUnit      ServiceUnit `avp:"Used-Service-Unit"`
+	usedTotal            int64       // Units used since the session start, populated by the agent
+}
+
+// Identifies the credit control instance within the session, Rating-Group having priority over Service-Identifier
+func (self *MultipleServicesCreditControl) Id() string {
+	if self.RatingGroup != 0 {
+		return strconv.Itoa(self.RatingGroup)
+	}
+	return strconv.Itoa(self.ServiceIdentifier)
+}
+
+// Final-Unit-Indication sent with the last units granted
+type FinalUnitIndication struct {
+	FinalUnitAction int `avp:"Final-Unit-Action"`
+	RedirectServer  struct {
+		RedirectAddressType   int    `avp:"Redirect-Address-Type"`
+		RedirectServerAddress string `avp:"Redirect-Server-Address"`
+	} `avp:"Redirect-Server"`
+}
+
+// Action being one of <*terminate|*redirect>
+func NewFinalUnitIndication(action string, redirectAddrType int, redirectAddr string) (*FinalUnitIndication, error) {
+	fui := new(FinalUnitIndication)
+	switch action {
+	case utils.META_TERMINATE:
+		fui.FinalUnitAction = FUA_TERMINATE
+	case utils.META_REDIRECT:
+		fui.FinalUnitAction = FUA_REDIRECT
+		fui.RedirectServer.RedirectAddressType = redirectAddrType
+		fui.RedirectServer.RedirectServerAddress = redirectAddr
+	default:
+		return nil, fmt.Errorf("Unsupported final unit action: %s", action)
+	}
+	return fui, nil
+}
+
+func (self *FinalUnitIndication) AsGroupedAVP() *diam.GroupedAVP {
+	grp := &diam.GroupedAVP{AVP: []*diam.AVP{diam.NewAVP(449, avp.Mbit, 0, datatype.Enumerated(self.FinalUnitAction))}} // Final-Unit-Action
+	if self.FinalUnitAction == FUA_REDIRECT {
+		grp.AddAVP(diam.NewAVP(434, avp.Mbit, 0, &diam.GroupedAVP{ // Redirect-Server
+			AVP: []*diam.AVP{
+				diam.NewAVP(433, avp.Mbit, 0, datatype.Enumerated(self.RedirectServer.RedirectAddressType)),   // Redirect-Address-Type
+				diam.NewAVP(435, avp.Mbit, 0, datatype.UTF8String(self.RedirectServer.RedirectServerAddress)), // Redirect-Server-Address
+			}}))
+	}
+	return grp
+}
+
+// Multiple-Services-Credit-Control as sent within CCA
+type MSCCAnswer struct {
+	RatingGroup         int                  `avp:"Rating-Group"`
+	ServiceIdentifier   int                  `avp:"Service-Identifier"`
+	GrantedServiceUnit  ServiceUnit          `avp:"Granted-Service-Unit"`
+	ValidityTime        int                  `avp:"Validity-Time"`
+	ResultCode          int                  `avp:"Result-Code"`
+	FinalUnitIndication *FinalUnitIndication `avp:"Final-Unit-Indication"` // nil unless granting the last units
+}
+
+// Builds the grouped AVP, empty grants or validity being left out
+func (self *MSCCAnswer) AsGroupedAVP() *diam.GroupedAVP {
+	grp := new(diam.GroupedAVP)
+	if gsu := self.GrantedServiceUnit.AsGroupedAVP(); len(gsu.AVP) != 0 {
+		grp.AddAVP(diam.NewAVP(431, avp.Mbit, 0, gsu)) // Granted-Service-Unit
+	}
+	if self.RatingGroup != 0 {
+		grp.AddAVP(diam.NewAVP(432, avp.Mbit, 0, datatype.Unsigned32(self.RatingGroup))) // Rating-Group
+	}
+	if self.ServiceIdentifier != 0 {
+		grp.AddAVP(diam.NewAVP(439, avp.Mbit, 0, datatype.Unsigned32(self.ServiceIdentifier))) // Service-Identifier
+	}
+	if self.ValidityTime != 0 {
+		grp.AddAVP(diam.NewAVP(448, avp.Mbit, 0, datatype.Unsigned32(self.ValidityTime))) // Validity-Time
+	}
+	grp.AddAVP(diam.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(self.ResultCode)))
+	if self.FinalUnitIndication != nil {
+		grp.AddAVP(diam.NewAVP(430, avp.Mbit, 0, self.FinalUnitIndication.AsGroupedAVP())) // Final-Unit-Indication
+	}
+	return grp
+}
+
 // CallControl Request
 type CCR struct {
 	SessionId         string    `avp:"Session-Id"`
@@ -176,7 +327,8 @@ type CCR struct {
 	RequestedServiceUnit struct {
 		CCTime int `avp:"CC-Time"`
 	} `avp:"Requested-Service-Unit"`
-	ServiceInformation struct {
+	MultipleServicesCreditControl []*MultipleServicesCreditControl `avp:"Multiple-Services-Credit-Control"`
+	ServiceInformation            struct {
 		INInformation struct {
 			CallingPartyAddress string `avp:"Calling-Party-Address"`
 			CalledPartyAddress  string `avp:"Called-Party-Address"`
@@ -194,6 +346,9 @@ type CCR struct {
 	} `avp:"Service-Information"`
 	diamMessage   *diam.Message // Used to parse fields with CGR templates
 	debitInterval time.Duration // Configured debit interval
+	msccIdx       int           // Index of the MSCC processed, -1 when processing the request as a whole
+	retransmitted bool          // Request number already received within the session
+	msccAnswered  []*MSCCAnswer // Answer sent to the original request when retransmitted
 }
 
 // Used when sending from client to agent
@@ -246,6 +401,24 @@ func (self *CCR) AsDiameterMessage() (*diam.Message, error) {
 			diam.NewAVP(420, avp.Mbit, 0, datatype.Unsigned32(self.RequestedServiceUnit.CCTime))}}); err != nil { // CC-Time
 		return nil, err
 	}
+	for _, mscc := range self.MultipleServicesCreditControl {
+		msccGrp := new(diam.GroupedAVP)
+		if rsu := mscc.RequestedServiceUnit.AsGroupedAVP(); len(rsu.AVP) != 0 {
+			msccGrp.AddAVP(diam.NewAVP(437, avp.Mbit, 0, rsu)) // Requested-Service-Unit
+		}
+		if usu := mscc.UsedServiceUnit.AsGroupedAVP(); len(usu.AVP) != 0 {
+			msccGrp.AddAVP(diam.NewAVP(446, avp.Mbit, 0, usu)) // Used-Service-Unit
+		}
+		if mscc.ServiceIdentifier != 0 {
+			msccGrp.AddAVP(diam.NewAVP(439, avp.Mbit, 0, datatype.Unsigned32(mscc.ServiceIdentifier))) // Service-Identifier
+		}
+		if mscc.RatingGroup != 0 {
+			msccGrp.AddAVP(diam.NewAVP(432, avp.Mbit, 0, datatype.Unsigned32(mscc.RatingGroup))) // Rating-Group
+		}
+		if _, err := m.NewAVP("Multiple-Services-Credit-Control", avp.Mbit, 0, msccGrp); err != nil {
+			return nil, err
+		}
+	}
 	if _, err := m.NewAVP(873, avp.Mbit, 10415, &diam.GroupedAVP{
 		AVP: []*diam.AVP{
 			diam.NewAVP(20300, avp.Mbit, 2011, &diam.GroupedAVP{ // IN-Information
@@ -294,24 +467,73 @@ func (self *CCR) passesFieldFilter(fieldFilter *utils.RSRField) bool {
 func (self *CCR) metaHandler(tag, arg string) (string, error) {
 	switch tag {
 	case META_CCR_USAGE:
+		if self.msccIdx != -1 {
+			return strconv.FormatFloat(self.msccUsage(), 'f', -1, 64), nil
+		}
 		usage := usageFromCCR(self.CCRequestType, self.CCRequestNumber, self.RequestedServiceUnit.CCTime, self.debitInterval)
 		return strconv.FormatFloat(usage.Seconds(), 'f', -1, 64), nil
 	}
 	return "", nil
 }
 
+// Usage out of the MSCC processed: requested units for CCR-I/CCR-U and total used units for CCR-T.
+// When the client does not request specific units, debit_interval seconds are used as units.
+func (self *CCR) msccUsage() float64 {
+	mscc := self.MultipleServicesCreditControl[self.msccIdx]
+	if self.CCRequestType == 3 {
+		return float64(mscc.usedTotal)
+	}
+	if _, units := mscc.RequestedServiceUnit.Units(); units != 0 {
+		return float64(units)
+	}
+	return self.debitInterval.Seconds()
+}
+
+// Finds AVPs on path, restricting the ones under Multiple-Services-Credit-Control to the MSCC processed
+func (self *CCR) findAVPsWithPath(hierarchyPath []string) ([]*diam.AVP, error) {
+	hpIf := make([]interface{}, len(hierarchyPath))
+	for i, val := range hierarchyPath {
+		hpIf[i] = val
+	}
+	if self.msccIdx == -1 || hierarchyPath[0] != "Multiple-Services-Credit-Control" {
+		return self.diamMessage.FindAVPsWithPath(hpIf, dict.UndefinedVendorID)
+	}
+	msccAvps, err := self.diamMessage.FindAVPs(hierarchyPath[0], dict.UndefinedVendorID)
+	if err != nil {
+		return nil, err
+	} else if len(msccAvps) <= self.msccIdx {
+		return nil, nil
+	}
+	matchingAvps := []*diam.AVP{msccAvps[self.msccIdx]}
+	for _, avpName := range hierarchyPath[1:] {
+		dictAvp, err := self.diamMessage.Dictionary().FindAVP(self.diamMessage.Header.ApplicationID, avpName)
+		if err != nil {
+			return nil, err
+		}
+		var children []*diam.AVP
+		for _, parent := range matchingAvps {
+			grp, isGrouped := parent.Data.(*diam.GroupedAVP)
+			if !isGrouped {
+				continue
+			}
+			for _, child := range grp.AVP {
+				if child.Code == dictAvp.Code {
+					children = append(children, child)
+				}
+			}
+		}
+		matchingAvps = children
+	}
+	return matchingAvps, nil
+}
+
 func (self *CCR) eventFieldValue(fldTpl utils.RSRFields) string {
 	var outVal string
 	for _, rsrTpl := range fldTpl {
 		if rsrTpl.IsStatic() {
 			outVal += rsrTpl.ParseValue("")
 		} else {
-			hierarchyPath := strings.Split(rsrTpl.Id, utils.HIERARCHY_SEP)
-			hpIf := make([]interface{}, len(hierarchyPath))
-			for i, val := range hierarchyPath {
-				hpIf[i] = val
-			}
-			matchingAvps, err := self.diamMessage.FindAVPsWithPath(hpIf, dict.UndefinedVendorID)
+			matchingAvps, err := self.findAVPsWithPath(strings.Split(rsrTpl.Id, utils.HIERARCHY_SEP))
 			if err != nil || len(matchingAvps) == 0 {
 				utils.Logger.Warning(fmt.Sprintf("<Diameter> Cannot find AVP for field template with id: %s, ignoring.", rsrTpl.Id))
 				continue // Filter not matching
@@ -361,8 +583,95 @@ func (self *CCR) AsSMGenericEvent(cfgFlds []*config.CfgCdrField) (sessionmanager
 	return sessionmanager.SMGenericEvent(utils.ConvertMapValStrIf(outMap)), nil
 }
 
+// Converts the string value into AVP data based on the data type defined in dictionary
+func avpDataFromString(dataType datatype.TypeID, valStr, timezone string) (datatype.Type, error) {
+	switch dataType {
+	case datatype.UTF8StringType:
+		return datatype.UTF8String(valStr), nil
+	case datatype.OctetStringType:
+		return datatype.OctetString(valStr), nil
+	case datatype.DiameterIdentityType:
+		return datatype.DiameterIdentity(valStr), nil
+	case datatype.DiameterURIType:
+		return datatype.DiameterURI(valStr), nil
+	case datatype.EnumeratedType, datatype.Integer32Type:
+		i, err := strconv.ParseInt(valStr, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		if dataType == datatype.EnumeratedType {
+			return datatype.Enumerated(i), nil
+		}
+		return datatype.Integer32(i), nil
+	case datatype.Integer64Type:
+		i, err := strconv.ParseInt(valStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Integer64(i), nil
+	case datatype.Unsigned32Type:
+		i, err := strconv.ParseUint(valStr, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Unsigned32(i), nil
+	case datatype.Unsigned64Type:
+		i, err := strconv.ParseUint(valStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Unsigned64(i), nil
+	case datatype.Float32Type:
+		f, err := strconv.ParseFloat(valStr, 32)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Float32(f), nil
+	case datatype.Float64Type:
+		f, err := strconv.ParseFloat(valStr, 64)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Float64(f), nil
+	case datatype.TimeType:
+		t, err := utils.ParseTimeDetectLayout(valStr, timezone)
+		if err != nil {
+			return nil, err
+		}
+		return datatype.Time(t), nil
+	case datatype.AddressType, datatype.IPv4Type:
+		ip := net.ParseIP(valStr)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid IP address: %s", valStr)
+		}
+		if dataType == datatype.IPv4Type {
+			return datatype.IPv4(ip), nil
+		}
+		return datatype.Address(ip), nil
+	}
+	return nil, fmt.Errorf("Unsupported AVP data type: %d", dataType)
+}
+
+// AVP built out of CCA templates, converted to diam.AVP only once complete so grouped AVPs get their final content
+type avpTplNode struct {
+	dictAVP  *dict.AVP
+	data     datatype.Type // nil for grouped AVPs
+	children []*avpTplNode
+}
+
+func (self *avpTplNode) asAVP() *diam.AVP {
+	if self.data != nil {
+		return diam.NewAVP(self.dictAVP.Code, avp.Mbit, self.dictAVP.VendorID, self.data)
+	}
+	grp := new(diam.GroupedAVP)
+	for _, child := range self.children {
+		grp.AddAVP(child.asAVP())
+	}
+	return diam.NewAVP(self.dictAVP.Code, avp.Mbit, self.dictAVP.VendorID, grp)
+}
+
 func NewCCAFromCCR(ccr *CCR) *CCA {
-	return &CCA{SessionId: ccr.SessionId, AuthApplicationId: ccr.AuthApplicationId, CCRequestType: ccr.CCRequestType, CCRequestNumber: ccr.CCRequestNumber,
+	return &CCA{SessionId: ccr.SessionId, AuthApplicationId: ccr.AuthApplicationId, CCRequestType: ccr.CCRequestType, CCRequestNumber: ccr.CCRequestNumber, ccr: ccr,
 		diamMessage: diam.NewMessage(ccr.diamMessage.Header.CommandCode, ccr.diamMessage.Header.CommandFlags&^diam.RequestFlag, ccr.diamMessage.Header.ApplicationID,
 			ccr.diamMessage.Header.HopByHopID, ccr.diamMessage.Header.EndToEndID, ccr.diamMessage.Dictionary()),
 	}
@@ -380,7 +689,97 @@ type CCA struct {
 	GrantedServiceUnit struct {
 		CCTime int `avp:"CC-Time"`
 	} `avp:"Granted-Service-Unit"`
-	diamMessage *diam.Message
+	FinalUnitIndication           FinalUnitIndication `avp:"Final-Unit-Indication"`
+	MultipleServicesCreditControl []*MSCCAnswer       `avp:"Multiple-Services-Credit-Control"`
+	finalUnit                     bool                // Final-Unit-Indication is part of the answer
+	tplAVPs                       []*avpTplNode       // AVPs out of templates
+	ccr                           *CCR                // Request we are answering to, source of template values
+	diamMessage                   *diam.Message
+}
+
+// Marks the granted units as the last ones, action being one of <*terminate|*redirect>
+func (self *CCA) SetFinalUnitIndication(action string, redirectAddrType int, redirectAddr string) error {
+	fui, err := NewFinalUnitIndication(action, redirectAddrType, redirectAddr)
+	if err != nil {
+		return err
+	}
+	self.FinalUnitIndication = *fui
+	self.finalUnit = true
+	return nil
+}
+
+// Adds AVP on path, the grouped AVPs on the path being shared with the previously added ones
+func (self *CCA) addTemplateAVP(path []string, valStr, timezone string) error {
+	nodes := &self.tplAVPs
+	for i, avpName := range path {
+		dictAVP, err := self.diamMessage.Dictionary().FindAVP(self.diamMessage.Header.ApplicationID, avpName)
+		if err != nil {
+			return err
+		}
+		if i == len(path)-1 {
+			avpData, err := avpDataFromString(dictAVP.Data.Type, valStr, timezone)
+			if err != nil {
+				return fmt.Errorf("AVP: %s, error: %s", avpName, err.Error())
+			}
+			*nodes = append(*nodes, &avpTplNode{dictAVP: dictAVP, data: avpData})
+			return nil
+		}
+		var parent *avpTplNode
+		for j := len(*nodes) - 1; j >= 0; j-- {
+			if (*nodes)[j].data == nil && (*nodes)[j].dictAVP.Code == dictAVP.Code {
+				parent = (*nodes)[j]
+				break
+			}
+		}
+		if parent == nil {
+			parent = &avpTplNode{dictAVP: dictAVP}
+			*nodes = append(*nodes, parent)
+		}
+		nodes = &parent.children
+	}
+	return nil
+}
+
+// Populates answer with AVPs out of templates, values being extracted out of request or charging result
+func (self *CCA) SetProcessorAVPs(cfgFlds []*config.CfgCdrField, maxUsage float64, timezone string) error {
+	for _, cfgFld := range cfgFlds {
+		passesAllFilters := true
+		for _, fldFilter := range cfgFld.FieldFilter {
+			if !self.ccr.passesFieldFilter(fldFilter) {
+				passesAllFilters = false
+				break
+			}
+		}
+		if !passesAllFilters {
+			continue
+		}
+		var outVal string
+		var err error
+		switch cfgFld.Type {
+		case utils.META_CONSTANT:
+			outVal = cfgFld.Value.Id()
+		case utils.META_HANDLER:
+			if cfgFld.HandlerId == META_CCA_USAGE {
+				outVal = strconv.FormatFloat(maxUsage, 'f', -1, 64)
+			} else if outVal, err = self.ccr.metaHandler(cfgFld.HandlerId, cfgFld.Layout); err != nil {
+				utils.Logger.Warning(fmt.Sprintf("<Diameter> Ignoring processing of metafunction: %s, error: %s", cfgFld.HandlerId, err.Error()))
+			}
+		case utils.META_COMPOSED:
+			outVal = self.ccr.eventFieldValue(cfgFld.Value)
+		}
+		fmtOut, err := utils.FmtFieldWidth(outVal, cfgFld.Width, cfgFld.Strip, cfgFld.Padding, cfgFld.Mandatory)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<Diameter> Error when processing field template with tag: %s, error: %s", cfgFld.Tag, err.Error()))
+			return err
+		}
+		if len(fmtOut) == 0 { // Not mandatory, no need of empty AVP
+			continue
+		}
+		if err := self.addTemplateAVP(strings.Split(cfgFld.FieldId, utils.HIERARCHY_SEP), fmtOut, timezone); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Converts itself into DiameterMessage
@@ -406,14 +805,29 @@ func (self *CCA) AsDiameterMessage() (*diam.Message, error) {
 	if _, err := self.diamMessage.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(self.ResultCode)); err != nil {
 		return nil, err
 	}
-	ccTimeAvp, err := self.diamMessage.Dictionary().FindAVP(self.diamMessage.Header.ApplicationID, "CC-Time")
-	if err != nil {
-		return nil, err
+	for _, mscc := range self.MultipleServicesCreditControl {
+		if _, err := self.diamMessage.NewAVP("Multiple-Services-Credit-Control", avp.Mbit, 0, mscc.AsGroupedAVP()); err != nil {
+			return nil, err
+		}
 	}
-	if _, err := self.diamMessage.NewAVP("Granted-Service-Unit", avp.Mbit, 0, &diam.GroupedAVP{
-		AVP: []*diam.AVP{
-			diam.NewAVP(ccTimeAvp.Code, avp.Mbit, 0, datatype.Unsigned32(self.GrantedServiceUnit.CCTime))}}); err != nil {
-		return nil, err
+	if len(self.MultipleServicesCreditControl) == 0 && self.ResultCode == diam.Success { // Units granted per MSCC otherwise
+		ccTimeAvp, err := self.diamMessage.Dictionary().FindAVP(self.diamMessage.Header.ApplicationID, "CC-Time")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := self.diamMessage.NewAVP("Granted-Service-Unit", avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				diam.NewAVP(ccTimeAvp.Code, avp.Mbit, 0, datatype.Unsigned32(self.GrantedServiceUnit.CCTime))}}); err != nil {
+			return nil, err
+		}
+	}
+	if self.finalUnit {
+		if _, err := self.diamMessage.NewAVP(430, avp.Mbit, 0, self.FinalUnitIndication.AsGroupedAVP()); err != nil { // Final-Unit-Indication
+			return nil, err
+		}
+	}
+	for _, tplAVP := range self.tplAVPs {
+		self.diamMessage.AddAVP(tplAVP.asAVP())
 	}
 	return self.diamMessage, nil
 }
@@ -20,9 +20,11 @@ package agents
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/cgrates/cgrates/utils"
 	"github.com/fiorix/go-diameter/diam"
 	"github.com/fiorix/go-diameter/diam/avp"
 	"github.com/fiorix/go-diameter/diam/datatype"
@@ -82,3 +84,169 @@ func TestAvpValAsString(t *testing.T) {
 		t.Errorf("Expected: %s, received: %s", originHostStr, avpValStr)
 	}
 }
+
+func TestCCASetFinalUnitIndication(t *testing.T) {
+	cca := new(CCA)
+	if err := cca.SetFinalUnitIndication("*unsupported", 0, ""); err == nil {
+		t.Error("Expecting error for unsupported action")
+	} else if cca.finalUnit {
+		t.Error("Final unit should not be set on error")
+	}
+	if err := cca.SetFinalUnitIndication(utils.META_TERMINATE, 2, "http://cgrates.org"); err != nil {
+		t.Error(err)
+	} else if !cca.finalUnit || cca.FinalUnitIndication.FinalUnitAction != FUA_TERMINATE ||
+		cca.FinalUnitIndication.RedirectServer.RedirectServerAddress != "" {
+		t.Errorf("Unexpected FinalUnitIndication: %+v", cca.FinalUnitIndication)
+	}
+	cca = new(CCA)
+	if err := cca.SetFinalUnitIndication(utils.META_REDIRECT, 2, "http://cgrates.org"); err != nil {
+		t.Error(err)
+	} else if !cca.finalUnit || cca.FinalUnitIndication.FinalUnitAction != FUA_REDIRECT ||
+		cca.FinalUnitIndication.RedirectServer.RedirectAddressType != 2 ||
+		cca.FinalUnitIndication.RedirectServer.RedirectServerAddress != "http://cgrates.org" {
+		t.Errorf("Unexpected FinalUnitIndication: %+v", cca.FinalUnitIndication)
+	}
+}
+
+func TestServiceUnitUnits(t *testing.T) {
+	su := &ServiceUnit{CCTime: 300}
+	if tor, units := su.Units(); tor != utils.VOICE || units != 300 {
+		t.Error(tor, units)
+	}
+	su = &ServiceUnit{CCInputOctets: 1024, CCOutputOctets: 2048}
+	if tor, units := su.Units(); tor != utils.DATA || units != 3072 {
+		t.Error(tor, units)
+	}
+	su = &ServiceUnit{CCTotalOctets: 4096, CCInputOctets: 1024}
+	if tor, units := su.Units(); tor != utils.DATA || units != 4096 {
+		t.Error(tor, units)
+	}
+	su = &ServiceUnit{CCServiceSpecificUnits: 1}
+	if tor, units := su.Units(); tor != utils.GENERIC || units != 1 {
+		t.Error(tor, units)
+	}
+	if tor, units := new(ServiceUnit).Units(); tor != "" || units != 0 {
+		t.Error(tor, units)
+	}
+	su = new(ServiceUnit)
+	su.SetUnits(utils.DATA, 1048576)
+	if su.CCTotalOctets != 1048576 || su.CCTime != 0 {
+		t.Errorf("Unexpected units: %+v", su)
+	}
+	su = new(ServiceUnit)
+	su.SetUnits(utils.VOICE, 60)
+	if su.CCTime != 60 || su.CCTotalOctets != 0 {
+		t.Errorf("Unexpected units: %+v", su)
+	}
+}
+
+func TestMSCCUsage(t *testing.T) {
+	tracker := newMsccUsageTracker()
+	ccr := &CCR{SessionId: "s1", CCRequestType: 1, debitInterval: time.Duration(300) * time.Second, msccIdx: 0,
+		MultipleServicesCreditControl: []*MultipleServicesCreditControl{
+			&MultipleServicesCreditControl{RatingGroup: 1, RequestedServiceUnit: ServiceUnit{CCTotalOctets: 1048576}},
+			&MultipleServicesCreditControl{ServiceIdentifier: 2}}}
+	if tracker.track(ccr, 0) {
+		t.Error("Not expecting duplicate")
+	}
+	if usage := ccr.msccUsage(); usage != 1048576 {
+		t.Error(usage)
+	}
+	ccr.msccIdx = 1
+	if usage := ccr.msccUsage(); usage != 300 {
+		t.Error(usage)
+	}
+	ccr.CCRequestType = 2
+	ccr.CCRequestNumber = 1
+	ccr.MultipleServicesCreditControl[0].UsedServiceUnit = ServiceUnit{CCInputOctets: 1000, CCOutputOctets: 24}
+	tracker.track(ccr, 0)
+	if !tracker.track(ccr, 0) { // Retransmission
+		t.Error("Expecting duplicate")
+	}
+	ccr.CCRequestType = 3
+	ccr.CCRequestNumber = 2
+	ccr.MultipleServicesCreditControl[0].UsedServiceUnit = ServiceUnit{CCTotalOctets: 512}
+	tracker.track(ccr, 0)
+	ccr.msccIdx = 0
+	if usage := ccr.msccUsage(); usage != 1536 {
+		t.Error(usage)
+	}
+	if len(tracker.sessions) != 0 {
+		t.Errorf("Tracker not cleaned on session end: %+v", tracker.sessions)
+	}
+	if id := ccr.MultipleServicesCreditControl[1].Id(); id != "2" {
+		t.Error(id)
+	}
+}
+
+func TestMSCCUsageRetransmittedAnswer(t *testing.T) {
+	tracker := newMsccUsageTracker()
+	ccr := &CCR{SessionId: "s1", CCRequestType: 1, MultipleServicesCreditControl: []*MultipleServicesCreditControl{
+		&MultipleServicesCreditControl{RatingGroup: 1}}}
+	tracker.track(ccr, 0)
+	if !tracker.track(ccr, 0) || ccr.msccAnswered != nil {
+		t.Errorf("Expecting duplicate without answer, received: %+v", ccr.msccAnswered)
+	}
+	msccAs := []*MSCCAnswer{&MSCCAnswer{RatingGroup: 1, ResultCode: 2001}}
+	tracker.answered(ccr, msccAs)
+	if !tracker.track(ccr, 0) || !reflect.DeepEqual(msccAs, ccr.msccAnswered) {
+		t.Errorf("Expecting answer: %+v, received: %+v", msccAs, ccr.msccAnswered)
+	}
+}
+
+func TestMSCCUsageExpire(t *testing.T) {
+	tracker := newMsccUsageTracker()
+	ccr := &CCR{SessionId: "s1", CCRequestType: 1, MultipleServicesCreditControl: []*MultipleServicesCreditControl{
+		&MultipleServicesCreditControl{RatingGroup: 1}}}
+	tracker.track(ccr, time.Hour)
+	tracker.sessions["s1"].lastActive = time.Now().Add(-2 * time.Hour) // Never sent CCR-T
+	tracker.lastSweep = time.Now().Add(-2 * time.Hour)
+	ccr.SessionId = "s2"
+	tracker.track(ccr, time.Hour)
+	if _, hasIt := tracker.sessions["s1"]; hasIt || len(tracker.sessions) != 1 {
+		t.Errorf("Expecting s1 expired: %+v", tracker.sessions)
+	}
+}
+
+func TestMSCCAnswerFinalUnitIndication(t *testing.T) {
+	if _, err := NewFinalUnitIndication("*unsupported", 0, ""); err == nil {
+		t.Error("Expecting error for unsupported action")
+	}
+	fui, err := NewFinalUnitIndication(utils.META_REDIRECT, 2, "http://cgrates.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fui.FinalUnitAction != FUA_REDIRECT || fui.RedirectServer.RedirectServerAddress != "http://cgrates.org" {
+		t.Errorf("Unexpected FinalUnitIndication: %+v", fui)
+	}
+}
+
+func TestAvpDataFromString(t *testing.T) {
+	if data, err := avpDataFromString(datatype.Unsigned32Type, "300", ""); err != nil {
+		t.Error(err)
+	} else if data != datatype.Unsigned32(300) {
+		t.Errorf("Received: %+v", data)
+	}
+	if data, err := avpDataFromString(datatype.EnumeratedType, "1", ""); err != nil {
+		t.Error(err)
+	} else if data != datatype.Enumerated(1) {
+		t.Errorf("Received: %+v", data)
+	}
+	if data, err := avpDataFromString(datatype.UTF8StringType, "cgrates.org", ""); err != nil {
+		t.Error(err)
+	} else if data != datatype.UTF8String("cgrates.org") {
+		t.Errorf("Received: %+v", data)
+	}
+	eTime := time.Date(2015, 11, 23, 12, 22, 24, 0, time.UTC)
+	if data, err := avpDataFromString(datatype.TimeType, "2015-11-23T12:22:24Z", "UTC"); err != nil {
+		t.Error(err)
+	} else if !time.Time(data.(datatype.Time)).Equal(eTime) {
+		t.Errorf("Received: %+v", data)
+	}
+	if _, err := avpDataFromString(datatype.Unsigned32Type, "notanumber", ""); err == nil {
+		t.Error("Should give error on invalid number")
+	}
+	if _, err := avpDataFromString(datatype.GroupedType, "1", ""); err == nil {
+		t.Error("Should give error on grouped type")
+	}
+}
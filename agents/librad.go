@@ -0,0 +1,475 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/sessionmanager"
+	"github.com/cgrates/cgrates/utils"
+	"layeh.com/radius"
+	"layeh.com/radius/dictionary"
+)
+
+const (
+	META_RADIUS_USAGE       = "*radius_usage"       // usage requested in Access-Request or reported since the previous Accounting-Request
+	META_RADIUS_ANSWER_TIME = "*radius_answer_time" // session start out of Event-Timestamp, Acct-Delay-Time and Acct-Session-Time
+	META_RADREPLY_USAGE     = "*radreply_usage"     // usage granted, available to reply templates
+	RADIUS_AUTH             = "RADIUS_AUTH"
+	RADIUS_ACCT             = "RADIUS_ACCT"
+	// Acct-Status-Type values, RFC 2866
+	ACCT_STATUS_START          = 1
+	ACCT_STATUS_STOP           = 2
+	ACCT_STATUS_INTERIM_UPDATE = 3
+	ACCT_STATUS_ACCOUNTING_ON  = 7
+	ACCT_STATUS_ACCOUNTING_OFF = 8
+	// Attribute types used internally
+	RAD_ATTR_USER_NAME          radius.Type = 1
+	RAD_ATTR_NAS_IP_ADDRESS     radius.Type = 4
+	RAD_ATTR_FRAMED_IP_ADDRESS  radius.Type = 8
+	RAD_ATTR_REPLY_MESSAGE      radius.Type = 18
+	RAD_ATTR_VENDOR_SPECIFIC    radius.Type = 26
+	RAD_ATTR_SESSION_TIMEOUT    radius.Type = 27
+	RAD_ATTR_CALLING_STATION_ID radius.Type = 31
+	RAD_ATTR_NAS_IDENTIFIER     radius.Type = 32
+	RAD_ATTR_ACCT_STATUS_TYPE   radius.Type = 40
+	RAD_ATTR_ACCT_DELAY_TIME    radius.Type = 41
+	RAD_ATTR_ACCT_SESSION_ID    radius.Type = 44
+	RAD_ATTR_ACCT_SESSION_TIME  radius.Type = 46
+	RAD_ATTR_EVENT_TIMESTAMP    radius.Type = 55
+	RAD_ATTR_ERROR_CAUSE        radius.Type = 101
+)
+
+// Attributes always known to the agent, additional ones (eg: vendor specific) being loaded out of dictionaries_dir
+const radiusBaseDictionary = `
+ATTRIBUTE	User-Name		1	string
+ATTRIBUTE	User-Password		2	string
+ATTRIBUTE	CHAP-Password		3	octets
+ATTRIBUTE	NAS-IP-Address		4	ipaddr
+ATTRIBUTE	NAS-Port		5	integer
+ATTRIBUTE	Service-Type		6	integer
+ATTRIBUTE	Framed-Protocol		7	integer
+ATTRIBUTE	Framed-IP-Address	8	ipaddr
+ATTRIBUTE	Framed-IP-Netmask	9	ipaddr
+ATTRIBUTE	Filter-Id		11	string
+ATTRIBUTE	Framed-MTU		12	integer
+ATTRIBUTE	Reply-Message		18	string
+ATTRIBUTE	State			24	octets
+ATTRIBUTE	Class			25	octets
+ATTRIBUTE	Vendor-Specific		26	octets
+ATTRIBUTE	Session-Timeout		27	integer
+ATTRIBUTE	Idle-Timeout		28	integer
+ATTRIBUTE	Termination-Action	29	integer
+ATTRIBUTE	Called-Station-Id	30	string
+ATTRIBUTE	Calling-Station-Id	31	string
+ATTRIBUTE	NAS-Identifier		32	string
+ATTRIBUTE	Proxy-State		33	octets
+ATTRIBUTE	Acct-Status-Type	40	integer
+ATTRIBUTE	Acct-Delay-Time		41	integer
+ATTRIBUTE	Acct-Input-Octets	42	integer
+ATTRIBUTE	Acct-Output-Octets	43	integer
+ATTRIBUTE	Acct-Session-Id		44	string
+ATTRIBUTE	Acct-Authentic		45	integer
+ATTRIBUTE	Acct-Session-Time	46	integer
+ATTRIBUTE	Acct-Input-Packets	47	integer
+ATTRIBUTE	Acct-Output-Packets	48	integer
+ATTRIBUTE	Acct-Terminate-Cause	49	integer
+ATTRIBUTE	Acct-Multi-Session-Id	50	string
+ATTRIBUTE	Acct-Link-Count		51	integer
+ATTRIBUTE	Acct-Input-Gigawords	52	integer
+ATTRIBUTE	Acct-Output-Gigawords	53	integer
+ATTRIBUTE	Event-Timestamp		55	date
+ATTRIBUTE	NAS-Port-Type		61	integer
+ATTRIBUTE	Acct-Interim-Interval	85	integer
+ATTRIBUTE	NAS-Port-Id		87	string
+ATTRIBUTE	Error-Cause		101	integer
+
+VALUE	Acct-Status-Type	Start			1
+VALUE	Acct-Status-Type	Stop			2
+VALUE	Acct-Status-Type	Interim-Update		3
+VALUE	Acct-Status-Type	Accounting-On		7
+VALUE	Acct-Status-Type	Accounting-Off		8
+VALUE	Termination-Action	Default			0
+VALUE	Termination-Action	RADIUS-Request		1
+VALUE	Acct-Terminate-Cause	User-Request		1
+VALUE	Acct-Terminate-Cause	Lost-Carrier		2
+VALUE	Acct-Terminate-Cause	Idle-Timeout		4
+VALUE	Acct-Terminate-Cause	Session-Timeout		5
+VALUE	Acct-Terminate-Cause	Admin-Reset		6
+VALUE	Acct-Terminate-Cause	NAS-Request		10
+`
+
+// In-memory dictionary file, satisfying dictionary.File
+type radiusDictFile struct {
+	*strings.Reader
+	name string
+}
+
+func (self *radiusDictFile) Name() string {
+	return self.name
+}
+
+func (self *radiusDictFile) Close() error {
+	return nil
+}
+
+// Loads the base dictionary and merges into it the files named dictionary* found in dictsDir
+func loadRadiusDictionaries(dictsDir, componentId string) (*dictionary.Dictionary, error) {
+	dict, err := new(dictionary.Parser).Parse(&radiusDictFile{Reader: strings.NewReader(radiusBaseDictionary), name: "base"})
+	if err != nil {
+		return nil, err
+	}
+	if len(dictsDir) == 0 {
+		return dict, nil
+	}
+	if fi, err := os.Stat(dictsDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("<%s> Invalid dictionaries folder: <%s>", componentId, dictsDir)
+		}
+		return nil, err
+	} else if !fi.IsDir() { // If config dir defined, needs to exist
+		return nil, fmt.Errorf("<%s> Path: <%s> is not a directory", componentId, dictsDir)
+	}
+	err = filepath.Walk(dictsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		dictFiles, err := filepath.Glob(filepath.Join(path, "dictionary*")) // FreeRADIUS naming, eg: dictionary.mikrotik
+		if err != nil {
+			return err
+		}
+		parser := &dictionary.Parser{Opener: &dictionary.FileSystemOpener{Root: path}, IgnoreIdenticalAttributes: true} // Root for $INCLUDE
+		for _, filePath := range dictFiles {
+			utils.Logger.Info(fmt.Sprintf("<%s> Loading dictionary out of file %s", componentId, filePath))
+			fileDict, err := parser.ParseFile(filepath.Base(filePath))
+			if err != nil {
+				return err
+			}
+			if dict, err = dictionary.Merge(dict, fileDict); err != nil {
+				return fmt.Errorf("%s: %s", filePath, err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dict, nil
+}
+
+// Finds the attribute definition by name, returning the vendor id for the vendor specific ones
+func radAttrByName(dict *dictionary.Dictionary, name string) (*dictionary.Attribute, uint32) {
+	if attr := dictionary.AttributeByName(dict.Attributes, name); attr != nil {
+		return attr, 0
+	}
+	for _, vendor := range dict.Vendors {
+		if attr := dictionary.AttributeByName(vendor.Attributes, name); attr != nil {
+			return attr, uint32(vendor.Number)
+		}
+	}
+	return nil, 0
+}
+
+// Returns the raw values of an attribute within packet, unpacking the Vendor-Specific ones
+func radAttrValues(attrs radius.Attributes, attr *dictionary.Attribute, vendorId uint32) []radius.Attribute {
+	if vendorId == 0 {
+		return attrs[radius.Type(attr.OID[0])]
+	}
+	var vals []radius.Attribute
+	for _, vsa := range attrs[RAD_ATTR_VENDOR_SPECIFIC] {
+		vsaVendorId, vsaVal, err := radius.VendorSpecific(vsa)
+		if err != nil || vsaVendorId != vendorId {
+			continue
+		}
+		for len(vsaVal) >= 2 { // One Vendor-Specific can carry more sub-attributes, RFC 2865 recommended format
+			subLen := int(vsaVal[1])
+			if subLen < 2 || subLen > len(vsaVal) {
+				break
+			}
+			if int(vsaVal[0]) == attr.OID[0] {
+				vals = append(vals, vsaVal[2:subLen])
+			}
+			vsaVal = vsaVal[subLen:]
+		}
+	}
+	return vals
+}
+
+// Converts the raw value into string based on the type defined in dictionary, dates as unix timestamps
+func radAttrValAsString(attr *dictionary.Attribute, val radius.Attribute) (string, error) {
+	switch attr.Type {
+	case dictionary.AttributeInteger:
+		i, err := radius.Integer(val)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(uint64(i), 10), nil
+	case dictionary.AttributeIPAddr:
+		ip, err := radius.IPAddr(val)
+		if err != nil {
+			return "", err
+		}
+		return ip.String(), nil
+	case dictionary.AttributeDate:
+		t, err := radius.Date(val)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	return radius.String(val), nil
+}
+
+// Converts the string value into attribute data based on the type defined in dictionary.
+// Integers can be also specified via the names defined with VALUE.
+func radAttrFromString(dict *dictionary.Dictionary, attr *dictionary.Attribute, vendorId uint32, valStr, timezone string) (radius.Attribute, error) {
+	switch attr.Type {
+	case dictionary.AttributeString:
+		return radius.NewString(valStr)
+	case dictionary.AttributeInteger:
+		if i, err := strconv.ParseUint(valStr, 10, 32); err == nil {
+			return radius.NewInteger(uint32(i)), nil
+		}
+		values := dict.Values
+		if vendorId != 0 {
+			values = dictionary.VendorByNumber(dict.Vendors, int(vendorId)).Values
+		}
+		for _, val := range dictionary.ValuesByAttribute(values, attr.Name) {
+			if val.Name == valStr {
+				return radius.NewInteger(uint32(val.Number)), nil
+			}
+		}
+		return nil, fmt.Errorf("Invalid value: %s for attribute: %s", valStr, attr.Name)
+	case dictionary.AttributeIPAddr:
+		ip := net.ParseIP(valStr)
+		if ip == nil {
+			return nil, fmt.Errorf("Invalid IP address: %s for attribute: %s", valStr, attr.Name)
+		}
+		return radius.NewIPAddr(ip)
+	case dictionary.AttributeDate:
+		t, err := utils.ParseTimeDetectLayout(valStr, timezone)
+		if err != nil {
+			return nil, err
+		}
+		return radius.NewDate(t)
+	case dictionary.AttributeOctets:
+		return radius.NewBytes([]byte(valStr))
+	}
+	return nil, fmt.Errorf("Unsupported type: %s for attribute: %s", attr.Type, attr.Name)
+}
+
+// Adds the value to attributes, packing it into Vendor-Specific if needed
+func addRadAttr(attrs radius.Attributes, attr *dictionary.Attribute, vendorId uint32, val radius.Attribute) error {
+	if vendorId == 0 {
+		attrs.Add(radius.Type(attr.OID[0]), val)
+		return nil
+	}
+	if len(val) > 253 {
+		return fmt.Errorf("Value too long for vendor attribute: %s", attr.Name)
+	}
+	subAttr := append(radius.Attribute{byte(attr.OID[0]), byte(len(val) + 2)}, val...)
+	vsa, err := radius.NewVendorSpecific(vendorId, subAttr)
+	if err != nil {
+		return err
+	}
+	attrs.Add(RAD_ATTR_VENDOR_SPECIFIC, vsa)
+	return nil
+}
+
+// Returns the integer value of an attribute, 0 if missing
+func radIntAttr(attrs radius.Attributes, typ radius.Type) uint32 {
+	if val, hasIt := attrs.Lookup(typ); hasIt {
+		if i, err := radius.Integer(val); err == nil {
+			return i
+		}
+	}
+	return 0
+}
+
+func newRadiusRequest(r *radius.Request, dict *dictionary.Dictionary, timezone string) *RadiusRequest {
+	return &RadiusRequest{Packet: r.Packet, RemoteAddr: r.RemoteAddr, Received: time.Now(), dict: dict, timezone: timezone}
+}
+
+// Access-Request or Accounting-Request received by the agent
+type RadiusRequest struct {
+	*radius.Packet
+	RemoteAddr net.Addr
+	Received   time.Time     // arrival time, used when client does not send Event-Timestamp
+	Usage      time.Duration // usage requested in Access-Request or reported since previous Accounting-Request
+	dict       *dictionary.Dictionary
+	timezone   string
+}
+
+func (self *RadiusRequest) eventName() string {
+	if self.Code == radius.CodeAccessRequest {
+		return RADIUS_AUTH
+	}
+	return RADIUS_ACCT
+}
+
+// Returns the value of attribute as string, false if not present in request
+func (self *RadiusRequest) attrValue(name string) (string, bool) {
+	attr, vendorId := radAttrByName(self.dict, name)
+	if attr == nil {
+		utils.Logger.Warning(fmt.Sprintf("<Radius> Attribute: %s not found in dictionaries", name))
+		return "", false
+	}
+	vals := radAttrValues(self.Attributes, attr, vendorId)
+	if len(vals) == 0 {
+		return "", false
+	}
+	valStr, err := radAttrValAsString(attr, vals[0])
+	if err != nil {
+		utils.Logger.Warning(fmt.Sprintf("<Radius> Cannot decode attribute: %s, error: %s", name, err.Error()))
+		return "", false
+	}
+	return valStr, true
+}
+
+// Start time of the session: event time minus delay in sending the request and the time session was active
+func (self *RadiusRequest) answerTime() time.Time {
+	evTime := self.Received
+	if val, hasIt := self.Attributes.Lookup(RAD_ATTR_EVENT_TIMESTAMP); hasIt {
+		if t, err := radius.Date(val); err == nil {
+			evTime = t
+		}
+	}
+	evTime = evTime.Add(-time.Duration(radIntAttr(self.Attributes, RAD_ATTR_ACCT_DELAY_TIME)) * time.Second)
+	return evTime.Add(-time.Duration(radIntAttr(self.Attributes, RAD_ATTR_ACCT_SESSION_TIME)) * time.Second)
+}
+
+func (self *RadiusRequest) passesFieldFilter(fieldFilter *utils.RSRField) bool {
+	if fieldFilter == nil {
+		return true
+	}
+	return fieldFilter.FilterPasses(self.eventFieldValue(utils.RSRFields{fieldFilter}))
+}
+
+// Handler for meta functions
+func (self *RadiusRequest) metaHandler(tag, arg string) (string, error) {
+	switch tag {
+	case META_RADIUS_USAGE:
+		return strconv.FormatFloat(self.Usage.Seconds(), 'f', -1, 64), nil
+	case META_RADIUS_ANSWER_TIME:
+		return self.answerTime().Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("Unsupported handler: %s", tag)
+}
+
+func (self *RadiusRequest) eventFieldValue(fldTpl utils.RSRFields) string {
+	var outVal string
+	for _, rsrTpl := range fldTpl {
+		if rsrTpl.IsStatic() {
+			outVal += rsrTpl.ParseValue("")
+		} else if val, hasIt := self.attrValue(rsrTpl.Id); hasIt {
+			outVal += rsrTpl.ParseValue(val)
+		}
+	}
+	return outVal
+}
+
+// Extracts data out of request into a SMGenericEvent based on the configured template
+func (self *RadiusRequest) AsSMGenericEvent(cfgFlds []*config.CfgCdrField) (sessionmanager.SMGenericEvent, error) {
+	outMap := make(map[string]string) // work with it so we can append values to keys
+	outMap[utils.EVENT_NAME] = self.eventName()
+	for _, cfgFld := range cfgFlds {
+		var outVal string
+		var err error
+		switch cfgFld.Type {
+		case utils.META_FILLER:
+			outVal = cfgFld.Value.Id()
+			cfgFld.Padding = "right"
+		case utils.META_CONSTANT:
+			outVal = cfgFld.Value.Id()
+		case utils.META_HANDLER:
+			outVal, err = self.metaHandler(cfgFld.HandlerId, cfgFld.Layout)
+			if err != nil {
+				utils.Logger.Warning(fmt.Sprintf("<Radius> Ignoring processing of metafunction: %s, error: %s", cfgFld.HandlerId, err.Error()))
+			}
+		case utils.META_COMPOSED:
+			outVal = self.eventFieldValue(cfgFld.Value)
+		}
+		fmtOut, err := utils.FmtFieldWidth(outVal, cfgFld.Width, cfgFld.Strip, cfgFld.Padding, cfgFld.Mandatory)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<Radius> Error when processing field template with tag: %s, error: %s", cfgFld.Tag, err.Error()))
+			return nil, err
+		}
+		outMap[cfgFld.FieldId] += fmtOut // If already there, postpend
+	}
+	return sessionmanager.SMGenericEvent(utils.ConvertMapValStrIf(outMap)), nil
+}
+
+// Populates reply attributes out of templates, values being extracted out of request or the usage granted (-1 for unlimited)
+func (self *RadiusRequest) SetReplyAttributes(reply *radius.Packet, cfgFlds []*config.CfgCdrField, maxUsage float64) error {
+	for _, cfgFld := range cfgFlds {
+		passesAllFilters := true
+		for _, fldFilter := range cfgFld.FieldFilter {
+			if !self.passesFieldFilter(fldFilter) {
+				passesAllFilters = false
+				break
+			}
+		}
+		if !passesAllFilters {
+			continue
+		}
+		var outVal string
+		var err error
+		switch cfgFld.Type {
+		case utils.META_CONSTANT:
+			outVal = cfgFld.Value.Id()
+		case utils.META_HANDLER:
+			if cfgFld.HandlerId == META_RADREPLY_USAGE {
+				if maxUsage >= 0 { // Unlimited usage results in no attribute
+					outVal = strconv.FormatInt(int64(maxUsage), 10)
+				}
+			} else if outVal, err = self.metaHandler(cfgFld.HandlerId, cfgFld.Layout); err != nil {
+				utils.Logger.Warning(fmt.Sprintf("<Radius> Ignoring processing of metafunction: %s, error: %s", cfgFld.HandlerId, err.Error()))
+			}
+		case utils.META_COMPOSED:
+			outVal = self.eventFieldValue(cfgFld.Value)
+		}
+		fmtOut, err := utils.FmtFieldWidth(outVal, cfgFld.Width, cfgFld.Strip, cfgFld.Padding, cfgFld.Mandatory)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<Radius> Error when processing field template with tag: %s, error: %s", cfgFld.Tag, err.Error()))
+			return err
+		}
+		if len(fmtOut) == 0 { // Not mandatory, no need of empty attribute
+			continue
+		}
+		attr, vendorId := radAttrByName(self.dict, cfgFld.FieldId)
+		if attr == nil {
+			return fmt.Errorf("Attribute: %s not found in dictionaries", cfgFld.FieldId)
+		}
+		val, err := radAttrFromString(self.dict, attr, vendorId, fmtOut, self.timezone)
+		if err != nil {
+			return err
+		}
+		if err := addRadAttr(reply.Attributes, attr, vendorId, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
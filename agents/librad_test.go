@@ -0,0 +1,126 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+	"layeh.com/radius"
+)
+
+func TestRadAttrVendorSpecific(t *testing.T) {
+	dict, err := loadRadiusDictionaries("../data/radius/dict", "RadiusAgent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attr, vendorId := radAttrByName(dict, "Mikrotik-Rate-Limit")
+	if attr == nil || vendorId != 14988 {
+		t.Fatal(attr, vendorId)
+	}
+	val, err := radAttrFromString(dict, attr, vendorId, "1M/2M", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := make(radius.Attributes)
+	if err := addRadAttr(attrs, attr, vendorId, val); err != nil {
+		t.Fatal(err)
+	}
+	if vals := radAttrValues(attrs, attr, vendorId); len(vals) != 1 || radius.String(vals[0]) != "1M/2M" {
+		t.Errorf("Received: %+v", vals)
+	}
+	acctStatus, _ := radAttrByName(dict, "Acct-Status-Type")
+	if val, err := radAttrFromString(dict, acctStatus, 0, "Interim-Update", ""); err != nil {
+		t.Error(err)
+	} else if valStr, _ := radAttrValAsString(acctStatus, val); valStr != "3" {
+		t.Error(valStr)
+	}
+}
+
+func TestRadiusRequestAsSMGenericEvent(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	dict, err := loadRadiusDictionaries("", "RadiusAgent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := radius.New(radius.CodeAccountingRequest, []byte("CGRateS.org"))
+	userName, _ := radius.NewString("1001")
+	pkt.Add(RAD_ATTR_USER_NAME, userName)
+	calledStation, _ := radius.NewString("1002")
+	pkt.Add(30, calledStation)
+	acctSessId, _ := radius.NewString("e4921177ab0e3586c37f6a185864b71a@0:0:0:0:0:0:0:0")
+	pkt.Add(RAD_ATTR_ACCT_SESSION_ID, acctSessId)
+	pkt.Add(RAD_ATTR_ACCT_STATUS_TYPE, radius.NewInteger(ACCT_STATUS_STOP))
+	pkt.Add(RAD_ATTR_ACCT_SESSION_TIME, radius.NewInteger(120))
+	evTime := time.Date(2016, 1, 5, 18, 32, 50, 0, time.UTC)
+	evTimestamp, _ := radius.NewDate(evTime)
+	pkt.Add(RAD_ATTR_EVENT_TIMESTAMP, evTimestamp)
+	req := newRadiusRequest(&radius.Request{Packet: pkt, RemoteAddr: &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 1813}}, dict, "")
+	rs := newRadiusSessions(0)
+	if req.Usage = rs.trackUsage(acctSessionKey(req), ACCT_STATUS_STOP, 120); req.Usage != time.Duration(120)*time.Second {
+		t.Error(req.Usage)
+	}
+	smgEv, err := req.AsSMGenericEvent(cgrCfg.RadiusAgentCfg().RequestProcessors[0].ContentFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smgEv[utils.EVENT_NAME] != RADIUS_ACCT || smgEv.GetUUID() != radius.String(acctSessId) ||
+		smgEv[utils.DESTINATION] != "1002" || smgEv["SubscriberId"] != "1001" || smgEv[utils.USAGE] != "120" {
+		t.Errorf("Received: %+v", smgEv)
+	}
+	if aTime, err := smgEv.GetAnswerTime(utils.META_DEFAULT, ""); err != nil {
+		t.Error(err)
+	} else if !aTime.Equal(evTime.Add(-time.Duration(120) * time.Second)) {
+		t.Error(aTime)
+	}
+	reply := pkt.Response(radius.CodeAccessAccept)
+	if err := req.SetReplyAttributes(reply, cgrCfg.RadiusAgentCfg().RequestProcessors[0].ReplyFields, 300); err != nil {
+		t.Error(err)
+	} else if sessTimeout := radIntAttr(reply.Attributes, RAD_ATTR_SESSION_TIMEOUT); sessTimeout != 300 {
+		t.Error(sessTimeout)
+	}
+	reply = pkt.Response(radius.CodeAccessAccept)
+	if err := req.SetReplyAttributes(reply, cgrCfg.RadiusAgentCfg().RequestProcessors[0].ReplyFields, -1); err != nil {
+		t.Error(err)
+	} else if _, hasIt := reply.Lookup(RAD_ATTR_SESSION_TIMEOUT); hasIt {
+		t.Error("Session-Timeout for unlimited usage")
+	}
+}
+
+func TestRadiusSessionsTrackUsage(t *testing.T) {
+	rs := newRadiusSessions(0)
+	if usage := rs.trackUsage("nas1:sess1", ACCT_STATUS_START, 0); usage != 0 {
+		t.Error(usage)
+	}
+	if usage := rs.trackUsage("nas1:sess1", ACCT_STATUS_INTERIM_UPDATE, 60); usage != time.Duration(60)*time.Second {
+		t.Error(usage)
+	}
+	if usage := rs.trackUsage("nas1:sess1", ACCT_STATUS_INTERIM_UPDATE, 90); usage != time.Duration(30)*time.Second {
+		t.Error(usage)
+	}
+	if usage := rs.trackUsage("nas1:sess1", ACCT_STATUS_STOP, 100); usage != time.Duration(100)*time.Second {
+		t.Error(usage)
+	}
+	if len(rs.sessionTimes) != 0 {
+		t.Error(rs.sessionTimes)
+	}
+}
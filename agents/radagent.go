@@ -0,0 +1,501 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rpc2"
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+	"layeh.com/radius"
+	"layeh.com/radius/dictionary"
+)
+
+const (
+	RADIUS_CLIENT_TIMEOUT = time.Duration(3) * time.Second // waiting for NAS to answer Disconnect-Request or CoA-Request
+	RADIUS_SHUTDOWN_WAIT  = time.Duration(2) * time.Second // waiting for requests in progress on shutdown
+)
+
+var ErrSMGNotConnected = errors.New("SMG_NOT_CONNECTED")
+
+func NewRadiusAgent(cgrCfg *config.CGRConfig, smgAddr string) (*RadiusAgent, error) {
+	ra := &RadiusAgent{cgrCfg: cgrCfg, smgAddr: smgAddr, sessions: newRadiusSessions(cgrCfg.RadiusAgentCfg().SessionTTL)}
+	var err error
+	if ra.dict, err = loadRadiusDictionaries(cgrCfg.RadiusAgentCfg().DictionariesDir, "RadiusAgent"); err != nil {
+		return nil, err
+	}
+	return ra, nil
+}
+
+type RadiusAgent struct {
+	cgrCfg   *config.CGRConfig
+	smgAddr  string // BiJSON address of SMG, so we can receive session disconnects over the same connection
+	smgMux   sync.RWMutex
+	smg      *rpc2.Client
+	stopped  bool // no reconnects to SMG after shutdown
+	dict     *dictionary.Dictionary
+	sessions *radiusSessions
+	servers  []*radius.PacketServer
+}
+
+// Data needed to reach the NAS for an active session
+type radiusSession struct {
+	nasAddr    string            // address where to send Disconnect-Request/CoA-Request
+	secret     string            // shared secret of the NAS
+	idAttrs    radius.Attributes // attributes identifying the session on NAS, RFC 5176
+	lastActive time.Time
+}
+
+// Last Acct-Session-Time reported for a session
+type radiusSessionTime struct {
+	sessionTime uint32
+	lastActive  time.Time
+}
+
+func newRadiusSessions(ttl time.Duration) *radiusSessions {
+	return &radiusSessions{sessionTimes: make(map[string]*radiusSessionTime), sessions: make(map[string]*radiusSession), ttl: ttl}
+}
+
+// Active accounting sessions, the ones not stopped being dropped after ttl of inactivity
+type radiusSessions struct {
+	sync.Mutex
+	sessionTimes map[string]*radiusSessionTime // indexed on NAS and Acct-Session-Id
+	sessions     map[string]*radiusSession     // sessions started in SMG, indexed on AccId
+	ttl          time.Duration
+	lastSweep    time.Time
+}
+
+// Usage since the previous report: 0 on Start, the increment on Interim-Update and the total on Stop
+func (self *radiusSessions) trackUsage(key string, statusType, sessionTime uint32) time.Duration {
+	self.Lock()
+	defer self.Unlock()
+	now := time.Now()
+	self.expire(now)
+	var usage uint32
+	switch statusType {
+	case ACCT_STATUS_START:
+		self.sessionTimes[key] = &radiusSessionTime{lastActive: now}
+	case ACCT_STATUS_INTERIM_UPDATE:
+		sessTime, hasIt := self.sessionTimes[key]
+		if !hasIt {
+			sessTime = new(radiusSessionTime)
+			self.sessionTimes[key] = sessTime
+		}
+		if sessionTime > sessTime.sessionTime {
+			usage = sessionTime - sessTime.sessionTime
+		}
+		sessTime.sessionTime = sessionTime
+		sessTime.lastActive = now
+	case ACCT_STATUS_STOP:
+		usage = sessionTime
+		delete(self.sessionTimes, key)
+	}
+	return time.Duration(usage) * time.Second
+}
+
+// Drops the sessions inactive for longer than ttl, checking at most once per ttl
+func (self *radiusSessions) expire(now time.Time) {
+	if self.ttl == 0 || now.Sub(self.lastSweep) < self.ttl {
+		return
+	}
+	self.lastSweep = now
+	for key, sessTime := range self.sessionTimes {
+		if now.Sub(sessTime.lastActive) > self.ttl {
+			delete(self.sessionTimes, key)
+		}
+	}
+	for accId, sess := range self.sessions {
+		if now.Sub(sess.lastActive) > self.ttl {
+			utils.Logger.Warning(fmt.Sprintf("<RadiusAgent> Dropping session: %s, no accounting received for %v", accId, self.ttl))
+			delete(self.sessions, accId)
+		}
+	}
+}
+
+func (self *radiusSessions) add(accId string, sess *radiusSession) {
+	self.Lock()
+	sess.lastActive = time.Now()
+	self.sessions[accId] = sess
+	self.Unlock()
+}
+
+// Marks the session as active on Interim-Update
+func (self *radiusSessions) touch(accId string) {
+	self.Lock()
+	if sess, hasIt := self.sessions[accId]; hasIt {
+		sess.lastActive = time.Now()
+	}
+	self.Unlock()
+}
+
+func (self *radiusSessions) get(accId string) *radiusSession {
+	self.Lock()
+	defer self.Unlock()
+	return self.sessions[accId]
+}
+
+func (self *radiusSessions) remove(accId string) {
+	self.Lock()
+	delete(self.sessions, accId)
+	self.Unlock()
+}
+
+// Returns the shared secret configured for the client IP, the *default one otherwise
+func (self *RadiusAgent) clientSecret(clntIP string) (string, bool) {
+	secrets := self.cgrCfg.RadiusAgentCfg().ClientSecrets
+	if secret, hasIt := secrets[clntIP]; hasIt {
+		return secret, true
+	}
+	secret, hasIt := secrets[utils.META_DEFAULT]
+	return secret, hasIt
+}
+
+// Implements radius.SecretSource
+func (self *RadiusAgent) RADIUSSecret(ctx context.Context, remoteAddr net.Addr) ([]byte, error) {
+	clntIP, _, err := net.SplitHostPort(remoteAddr.String())
+	if err != nil {
+		return nil, err
+	}
+	if secret, hasIt := self.clientSecret(clntIP); hasIt {
+		return []byte(secret), nil
+	}
+	utils.Logger.Warning(fmt.Sprintf("<RadiusAgent> No secret defined for client %s, ignoring request", clntIP))
+	return nil, nil // Request is dropped by the server
+}
+
+// Connects to SMG over BiJSON, registering the handlers SMG uses to manage the sessions
+func (self *RadiusAgent) connectSMG() error {
+	var conn net.Conn
+	var err error
+	for i := 0; i < self.cgrCfg.ConnectAttempts; i++ {
+		if conn, err = net.Dial("tcp", self.smgAddr); err == nil {
+			break
+		}
+		time.Sleep(time.Duration(i+1) * time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	clnt := rpc2.NewClient(conn)
+	clnt.Handle("SMGClientV1.DisconnectSession", self.handleDisconnectSession)
+	clnt.Handle("SMGClientV1.WarnSession", self.handleWarnSession)
+	go clnt.Run()
+	self.smgMux.Lock()
+	self.smg = clnt
+	self.smgMux.Unlock()
+	go func() {
+		<-clnt.DisconnectNotify()
+		self.smgMux.Lock()
+		self.smg = nil
+		stopped := self.stopped
+		self.smgMux.Unlock()
+		if stopped {
+			return
+		}
+		utils.Logger.Warning(fmt.Sprintf("<RadiusAgent> Lost connection to SMG at %s, reconnecting", self.smgAddr))
+		for i := 0; self.cgrCfg.Reconnects == -1 || i < self.cgrCfg.Reconnects; i++ {
+			if err := self.connectSMG(); err == nil {
+				return
+			}
+		}
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Could not reconnect to SMG at %s", self.smgAddr))
+	}()
+	return nil
+}
+
+func (self *RadiusAgent) callSMG(serviceMethod string, args interface{}, reply interface{}) error {
+	self.smgMux.RLock()
+	clnt := self.smg
+	self.smgMux.RUnlock()
+	if clnt == nil {
+		return ErrSMGNotConnected
+	}
+	return clnt.Call(serviceMethod, args, reply)
+}
+
+// Authorizes the request, returning the usage granted in seconds, -1 for unlimited
+func (self *RadiusAgent) processAuth(req *RadiusRequest, reqProcessor *config.RARequestProcessor) (float64, error) {
+	smgEv, err := req.AsSMGenericEvent(reqProcessor.ContentFields)
+	if err != nil {
+		return 0, err
+	}
+	if reqProcessor.DryRun {
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> DryRun, processor: %s, SMGenericEvent: %+v", reqProcessor.Id, smgEv))
+		return -1, nil
+	}
+	var maxUsage float64
+	if err := self.callSMG("SMGenericV1.GetMaxUsage", smgEv, &maxUsage); err != nil {
+		return 0, err
+	}
+	if maxUsage == 0 {
+		return 0, utils.ErrInsufficientCredit
+	}
+	return maxUsage, nil
+}
+
+// Passes the accounting event to SMG, remembering the sessions started so we can disconnect them later
+func (self *RadiusAgent) processAcct(req *RadiusRequest, reqProcessor *config.RARequestProcessor) error {
+	smgEv, err := req.AsSMGenericEvent(reqProcessor.ContentFields)
+	if err != nil {
+		return err
+	}
+	if reqProcessor.DryRun {
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> DryRun, processor: %s, SMGenericEvent: %+v", reqProcessor.Id, smgEv))
+		return nil
+	}
+	var maxUsage float64
+	var rpl string
+	switch radIntAttr(req.Attributes, RAD_ATTR_ACCT_STATUS_TYPE) {
+	case ACCT_STATUS_START:
+		if err := self.callSMG("SMGenericV1.SessionStart", smgEv, &maxUsage); err != nil {
+			return err
+		}
+		self.sessions.add(smgEv.GetUUID(), self.radiusSession(req))
+	case ACCT_STATUS_INTERIM_UPDATE:
+		self.sessions.touch(smgEv.GetUUID())
+		return self.callSMG("SMGenericV1.SessionUpdate", smgEv, &maxUsage)
+	case ACCT_STATUS_STOP:
+		self.sessions.remove(smgEv.GetUUID())
+		err = self.callSMG("SMGenericV1.SessionEnd", smgEv, &rpl)
+		if errCdr := self.callSMG("SMGenericV1.ProcessCdr", smgEv, &rpl); errCdr != nil {
+			err = errCdr
+		}
+		return err
+	}
+	return nil
+}
+
+// Identifies the accounting session on NAS-IP-Address or NAS-Identifier, falling back on the source IP, plus Acct-Session-Id.
+// Source port is left out since NAS can send from different sockets.
+func acctSessionKey(req *RadiusRequest) string {
+	nasId, _, _ := net.SplitHostPort(req.RemoteAddr.String())
+	if val, hasIt := req.Attributes.Lookup(RAD_ATTR_NAS_IP_ADDRESS); hasIt {
+		if ip, err := radius.IPAddr(val); err == nil {
+			nasId = ip.String()
+		}
+	} else if val, hasIt := req.Attributes.Lookup(RAD_ATTR_NAS_IDENTIFIER); hasIt {
+		nasId = radius.String(val)
+	}
+	return nasId + utils.CONCATENATED_KEY_SEP + radius.String(req.Get(RAD_ATTR_ACCT_SESSION_ID))
+}
+
+// Builds the data needed to disconnect the session out of Accounting-Start
+func (self *RadiusAgent) radiusSession(req *RadiusRequest) *radiusSession {
+	nasIP, _, _ := net.SplitHostPort(req.RemoteAddr.String())
+	if val, hasIt := req.Attributes.Lookup(RAD_ATTR_NAS_IP_ADDRESS); hasIt {
+		if ip, err := radius.IPAddr(val); err == nil {
+			nasIP = ip.String()
+		}
+	}
+	sess := &radiusSession{nasAddr: net.JoinHostPort(nasIP, strconv.Itoa(self.cgrCfg.RadiusAgentCfg().DisconnectPort)),
+		secret: string(req.Secret), idAttrs: make(radius.Attributes)}
+	for _, attrType := range []radius.Type{RAD_ATTR_USER_NAME, RAD_ATTR_NAS_IP_ADDRESS, RAD_ATTR_FRAMED_IP_ADDRESS,
+		RAD_ATTR_CALLING_STATION_ID, RAD_ATTR_NAS_IDENTIFIER, RAD_ATTR_ACCT_SESSION_ID} {
+		if val, hasIt := req.Attributes.Lookup(attrType); hasIt {
+			sess.idAttrs.Set(attrType, val)
+		}
+	}
+	return sess
+}
+
+// Runs the request through processors, returning false if none matched
+func (self *RadiusAgent) processRequest(req *RadiusRequest, reply *radius.Packet) (bool, error) {
+	var processed bool
+	for _, reqProcessor := range self.cgrCfg.RadiusAgentCfg().RequestProcessors {
+		passesAllFilters := true
+		for _, fldFilter := range reqProcessor.RequestFilter {
+			if !req.passesFieldFilter(fldFilter) {
+				passesAllFilters = false
+				break
+			}
+		}
+		if !passesAllFilters {
+			continue
+		}
+		processed = true
+		if req.Code == radius.CodeAccessRequest {
+			maxUsage, err := self.processAuth(req, reqProcessor)
+			if err != nil {
+				return processed, err
+			}
+			if err := req.SetReplyAttributes(reply, reqProcessor.ReplyFields, maxUsage); err != nil {
+				return processed, err
+			}
+		} else if err := self.processAcct(req, reqProcessor); err != nil {
+			return processed, err
+		}
+		if !reqProcessor.ContinueOnSuccess {
+			break
+		}
+	}
+	return processed, nil
+}
+
+// Answers with Access-Accept or Access-Reject carrying the error as Reply-Message
+func (self *RadiusAgent) handleAuth(w radius.ResponseWriter, r *radius.Request) {
+	req := newRadiusRequest(r, self.dict, self.cgrCfg.RadiusAgentCfg().Timezone)
+	req.Usage = self.cgrCfg.MaxCallDuration
+	if sessTimeout := radIntAttr(req.Attributes, RAD_ATTR_SESSION_TIMEOUT); sessTimeout != 0 { // Hint from NAS
+		req.Usage = time.Duration(sessTimeout) * time.Second
+	}
+	reply := r.Response(radius.CodeAccessAccept)
+	if processed, err := self.processRequest(req, reply); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Error processing Access-Request from %s, error: %s", r.RemoteAddr, err.Error()))
+		reply = r.Response(radius.CodeAccessReject)
+		if rplMsg, err := radius.NewString(err.Error()); err == nil {
+			reply.Set(RAD_ATTR_REPLY_MESSAGE, rplMsg)
+		}
+	} else if !processed {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> No request processor enabled for Access-Request from %s, ignoring request", r.RemoteAddr))
+		return
+	}
+	if err := w.Write(reply); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Failed to write reply to %s: %s", r.RemoteAddr, err.Error()))
+	}
+}
+
+// Answers with Accounting-Response only when the request was processed, as required by RFC 2866
+func (self *RadiusAgent) handleAcct(w radius.ResponseWriter, r *radius.Request) {
+	req := newRadiusRequest(r, self.dict, self.cgrCfg.RadiusAgentCfg().Timezone)
+	statusType := radIntAttr(req.Attributes, RAD_ATTR_ACCT_STATUS_TYPE)
+	if statusType == ACCT_STATUS_ACCOUNTING_ON || statusType == ACCT_STATUS_ACCOUNTING_OFF {
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> Accounting-On/Off received from %s", r.RemoteAddr))
+	} else {
+		req.Usage = self.sessions.trackUsage(acctSessionKey(req), statusType, radIntAttr(req.Attributes, RAD_ATTR_ACCT_SESSION_TIME))
+		if processed, err := self.processRequest(req, nil); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Error processing Accounting-Request from %s, error: %s", r.RemoteAddr, err.Error()))
+			return
+		} else if !processed {
+			utils.Logger.Err(fmt.Sprintf("<RadiusAgent> No request processor enabled for Accounting-Request from %s, ignoring request", r.RemoteAddr))
+			return
+		}
+	}
+	if err := w.Write(r.Response(radius.CodeAccountingResponse)); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Failed to write reply to %s: %s", r.RemoteAddr, err.Error()))
+	}
+}
+
+type attrRadDisconnectSession struct {
+	EventStart map[string]interface{}
+	Reason     string
+}
+
+// Sends Disconnect-Request to NAS, called by SMG over BiJSON
+func (self *RadiusAgent) handleDisconnectSession(clnt *rpc2.Client, args *attrRadDisconnectSession, reply *string) error {
+	accId, _ := utils.ConvertIfaceToString(args.EventStart[utils.ACCID])
+	sess := self.sessions.get(accId)
+	if sess == nil {
+		return utils.ErrNotFound
+	}
+	req := radius.New(radius.CodeDisconnectRequest, []byte(sess.secret))
+	if err := self.sendToNAS(sess, req, args.Reason, radius.CodeDisconnectACK); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Failed disconnecting session: %s, error: %s", accId, err.Error()))
+		return err
+	}
+	*reply = utils.OK
+	return nil
+}
+
+type attrRadWarnSession struct {
+	EventStart     map[string]interface{}
+	Reason         string
+	RemainingUsage float64 // Seconds
+	RemainingCost  float64
+}
+
+// Limits the session on NAS to the remaining usage via CoA-Request, called by SMG over BiJSON
+func (self *RadiusAgent) handleWarnSession(clnt *rpc2.Client, args *attrRadWarnSession, reply *string) error {
+	accId, _ := utils.ConvertIfaceToString(args.EventStart[utils.ACCID])
+	sess := self.sessions.get(accId)
+	if sess == nil {
+		return utils.ErrNotFound
+	}
+	req := radius.New(radius.CodeCoARequest, []byte(sess.secret))
+	req.Set(RAD_ATTR_SESSION_TIMEOUT, radius.NewInteger(uint32(args.RemainingUsage)))
+	if err := self.sendToNAS(sess, req, args.Reason, radius.CodeCoAACK); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> Failed sending CoA for session: %s, error: %s", accId, err.Error()))
+		return err
+	}
+	*reply = utils.OK
+	return nil
+}
+
+// Sends the request identifying the session to NAS, expecting the ACK code as answer
+func (self *RadiusAgent) sendToNAS(sess *radiusSession, req *radius.Packet, reason string, ackCode radius.Code) error {
+	for attrType, vals := range sess.idAttrs {
+		req.Attributes[attrType] = vals
+	}
+	if len(reason) != 0 {
+		if rplMsg, err := radius.NewString(reason); err == nil {
+			req.Set(RAD_ATTR_REPLY_MESSAGE, rplMsg)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), RADIUS_CLIENT_TIMEOUT)
+	defer cancel()
+	rply, err := radius.Exchange(ctx, req, sess.nasAddr)
+	if err != nil {
+		return err
+	}
+	if rply.Code != ackCode {
+		return fmt.Errorf("%s received from %s, Error-Cause: %d", rply.Code, sess.nasAddr, radIntAttr(rply.Attributes, RAD_ATTR_ERROR_CAUSE))
+	}
+	return nil
+}
+
+// Connects to SMG and serves authorization and accounting requests until one of the listeners fails
+func (self *RadiusAgent) ListenAndServe() error {
+	if err := self.connectSMG(); err != nil {
+		return err
+	}
+	self.servers = []*radius.PacketServer{
+		&radius.PacketServer{Addr: self.cgrCfg.RadiusAgentCfg().ListenAuth, SecretSource: self, Handler: radius.HandlerFunc(self.handleAuth)},
+		&radius.PacketServer{Addr: self.cgrCfg.RadiusAgentCfg().ListenAcct, SecretSource: self, Handler: radius.HandlerFunc(self.handleAcct)},
+	}
+	errChan := make(chan error, len(self.servers))
+	for _, srv := range self.servers {
+		utils.Logger.Info(fmt.Sprintf("<RadiusAgent> Listening for requests at %s", srv.Addr))
+		go func(srv *radius.PacketServer) { errChan <- srv.ListenAndServe() }(srv)
+	}
+	return <-errChan
+}
+
+// Stops the listeners, letting the requests in progress finish within RADIUS_SHUTDOWN_WAIT
+func (self *RadiusAgent) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), RADIUS_SHUTDOWN_WAIT)
+	defer cancel()
+	for _, srv := range self.servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	self.smgMux.Lock()
+	defer self.smgMux.Unlock()
+	self.stopped = true
+	if self.smg != nil {
+		return self.smg.Close()
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package agents
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+	"layeh.com/radius"
+)
+
+func newTestRadiusRequest(t *testing.T, srcPort int, attrs map[radius.Type]radius.Attribute) *RadiusRequest {
+	dict, err := loadRadiusDictionaries("", "RadiusAgent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := radius.New(radius.CodeAccountingRequest, []byte("CGRateS.org"))
+	for attrType, val := range attrs {
+		pkt.Add(attrType, val)
+	}
+	return newRadiusRequest(&radius.Request{Packet: pkt, RemoteAddr: &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: srcPort}}, dict, "")
+}
+
+func TestRadiusAgentAcctSessionKey(t *testing.T) {
+	acctSessId, _ := radius.NewString("sess1")
+	if key1, key2 := acctSessionKey(newTestRadiusRequest(t, 1813, map[radius.Type]radius.Attribute{RAD_ATTR_ACCT_SESSION_ID: acctSessId})),
+		acctSessionKey(newTestRadiusRequest(t, 32768, map[radius.Type]radius.Attribute{RAD_ATTR_ACCT_SESSION_ID: acctSessId})); key1 != key2 || key1 != "10.0.0.1:sess1" {
+		t.Errorf("Expecting same key for different source ports, received: %s, %s", key1, key2)
+	}
+	nasIP, _ := radius.NewIPAddr(net.ParseIP("192.168.1.1"))
+	if key := acctSessionKey(newTestRadiusRequest(t, 1813, map[radius.Type]radius.Attribute{RAD_ATTR_ACCT_SESSION_ID: acctSessId,
+		RAD_ATTR_NAS_IP_ADDRESS: nasIP})); key != "192.168.1.1:sess1" {
+		t.Error(key)
+	}
+	nasId, _ := radius.NewString("nas01")
+	if key := acctSessionKey(newTestRadiusRequest(t, 1813, map[radius.Type]radius.Attribute{RAD_ATTR_ACCT_SESSION_ID: acctSessId,
+		RAD_ATTR_NAS_IDENTIFIER: nasId})); key != "nas01:sess1" {
+		t.Error(key)
+	}
+}
+
+func TestRadiusSessionsExpire(t *testing.T) {
+	rs := newRadiusSessions(time.Hour)
+	rs.trackUsage("nas1:sess1", ACCT_STATUS_START, 0)
+	rs.add("sess1", &radiusSession{nasAddr: "10.0.0.1:3799"})
+	rs.add("sess2", &radiusSession{nasAddr: "10.0.0.1:3799"})
+	past := time.Now().Add(-2 * time.Hour) // Stop never received
+	rs.sessionTimes["nas1:sess1"].lastActive = past
+	rs.sessions["sess1"].lastActive = past
+	rs.lastSweep = past
+	rs.trackUsage("nas1:sess2", ACCT_STATUS_START, 0)
+	if _, hasIt := rs.sessionTimes["nas1:sess1"]; hasIt || len(rs.sessionTimes) != 1 {
+		t.Errorf("Expecting session time expired: %+v", rs.sessionTimes)
+	}
+	if rs.get("sess1") != nil || rs.get("sess2") == nil {
+		t.Errorf("Unexpected sessions: %+v", rs.sessions)
+	}
+}
+
+func TestRadiusAgentClientSecret(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	ra := &RadiusAgent{cgrCfg: cgrCfg}
+	if secret, err := ra.RADIUSSecret(nil, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1813}); err != nil || secret != nil {
+		t.Errorf("Expecting request dropped without default secret, received: %q, %v", secret, err)
+	}
+	cgrCfg.RadiusAgentCfg().ClientSecrets = map[string]string{"10.0.0.1": "nas1_secret", utils.META_DEFAULT: "default_secret"}
+	if secret, err := ra.RADIUSSecret(nil, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1813}); err != nil || string(secret) != "nas1_secret" {
+		t.Errorf("Received: %q, %v", secret, err)
+	}
+	if secret, _ := ra.clientSecret("10.0.0.2"); secret != "default_secret" {
+		t.Error(secret)
+	}
+}
+
+func TestRadiusAgentRadiusSession(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	ra := &RadiusAgent{cgrCfg: cgrCfg}
+	acctSessId, _ := radius.NewString("sess1")
+	userName, _ := radius.NewString("1001")
+	nasIP, _ := radius.NewIPAddr(net.ParseIP("192.168.1.1"))
+	sess := ra.radiusSession(newTestRadiusRequest(t, 1813, map[radius.Type]radius.Attribute{RAD_ATTR_ACCT_SESSION_ID: acctSessId,
+		RAD_ATTR_USER_NAME: userName, RAD_ATTR_NAS_IP_ADDRESS: nasIP}))
+	if sess.nasAddr != "192.168.1.1:3799" || sess.secret != "CGRateS.org" {
+		t.Errorf("Unexpected session: %+v", sess)
+	}
+	if len(sess.idAttrs) != 3 || radius.String(sess.idAttrs.Get(RAD_ATTR_USER_NAME)) != "1001" {
+		t.Errorf("Unexpected id attributes: %+v", sess.idAttrs)
+	}
+}
+
+func TestRadiusAgentDisconnectUnknownSession(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	ra := &RadiusAgent{cgrCfg: cgrCfg, sessions: newRadiusSessions(0)}
+	var reply string
+	if err := ra.handleDisconnectSession(nil, &attrRadDisconnectSession{EventStart: map[string]interface{}{utils.ACCID: "unknown"}}, &reply); err != utils.ErrNotFound {
+		t.Error(err)
+	}
+	if err := ra.handleWarnSession(nil, &attrRadWarnSession{EventStart: map[string]interface{}{utils.ACCID: "unknown"}}, &reply); err != utils.ErrNotFound {
+		t.Error(err)
+	}
+}
+
+func TestRadiusAgentProcessAcctNotConnected(t *testing.T) {
+	cgrCfg, _ := config.NewDefaultCGRConfig()
+	ra := &RadiusAgent{cgrCfg: cgrCfg, sessions: newRadiusSessions(0)}
+	acctSessId, _ := radius.NewString("sess1")
+	userName, _ := radius.NewString("1001")
+	calledStation, _ := radius.NewString("1002")
+	req := newTestRadiusRequest(t, 1813, map[radius.Type]radius.Attribute{RAD_ATTR_ACCT_SESSION_ID: acctSessId,
+		RAD_ATTR_USER_NAME: userName, 30: calledStation, RAD_ATTR_ACCT_STATUS_TYPE: radius.NewInteger(ACCT_STATUS_START)})
+	if processed, err := ra.processRequest(req, nil); !processed || err != ErrSMGNotConnected {
+		t.Errorf("Received: %v, %v", processed, err)
+	}
+	if ra.sessions.get("sess1") != nil {
+		t.Error("Session added without SMG start")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/cgrates/cgrates/cache2go"
+	"github.com/cgrates/cgrates/cdre"
 	"github.com/cgrates/cgrates/config"
 	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/scheduler"
@@ -50,6 +51,7 @@ type ApierV1 struct {
 	Responder   *engine.Responder
 	CdrStatsSrv engine.StatsInterface
 	Users       engine.UserService
+	CdreJobs    *cdre.CdreJobs
 }
 
 func (self *ApierV1) GetDestination(dstId string, reply *engine.Destination) error {
@@ -1087,10 +1089,35 @@ func (self *ApierV1) GetCacheStats(attrs utils.AttrCacheStats, reply *utils.Cach
 		cs.LastLoadId = loadHistInsts[0].LoadId
 		cs.LastLoadTime = loadHistInsts[0].LoadTime.Format(time.RFC3339)
 	}
+	if attrs.Counters {
+		cs.Counters = make(map[string]*utils.CacheCounters)
+		cntrs := cache2go.GetCounters()
+		for cacheName, prefix := range utils.CachePrefixes {
+			cacheCntrs := &utils.CacheCounters{}
+			if prfxCntrs, hasIt := cntrs[prefix]; hasIt {
+				cacheCntrs = &utils.CacheCounters{Hits: prfxCntrs.Hits, Misses: prfxCntrs.Misses, Evictions: prfxCntrs.Evictions, Expirations: prfxCntrs.Expirations}
+			}
+			cs.Counters[cacheName] = cacheCntrs
+		}
+	}
 	*reply = *cs
 	return nil
 }
 
+// Cache versions reached by the engines syncing their cache over the tariffplan_db, indexed on node id
+func (self *ApierV1) GetCacheVersions(ignored string, reply *map[string]*engine.CacheNodeVersion) error {
+	changesDb, canChanges := self.RatingDb.(engine.CacheChangesStorage)
+	if !canChanges {
+		return utils.ErrNotImplemented
+	}
+	nodeVersions, err := changesDb.GetCacheNodeVersions()
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	*reply = nodeVersions
+	return nil
+}
+
 func (self *ApierV1) LoadTariffPlanFromFolder(attrs utils.AttrLoadTpFromFolder, reply *string) error {
 	if len(attrs.FolderPath) == 0 {
 		return fmt.Errorf("%s:%s", utils.ErrMandatoryIeMissing.Error(), "FolderPath")
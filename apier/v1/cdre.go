@@ -35,6 +35,7 @@ import (
 
 	"github.com/cgrates/cgrates/cdre"
 	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/utils"
 )
 
@@ -42,11 +43,17 @@ func (self *ApierV1) ExportCdrsToZipString(attr utils.AttrExpFileCdrs, reply *st
 	tmpDir := "/tmp"
 	attr.ExportDir = &tmpDir // Enforce exporting to tmp always so we avoid cleanup issues
 	efc := utils.ExportedFileCdrs{}
-	if err := self.ExportCdrsToFile(attr, &efc); err != nil {
+	// Export uncompressed and without manifest since the content is zipped here
+	if err := self.exportCdrsToFile(attr, &efc, true); err != nil {
 		return err
 	} else if efc.TotalRecords == 0 || len(efc.ExportedFilePath) == 0 {
 		return errors.New("No CDR records to export")
 	}
+	defer func() {
+		if err := os.Remove(efc.ExportedFilePath); err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<ApierV1> Failed removing exported file at path: %s, error: %s", efc.ExportedFilePath, err.Error()))
+		}
+	}()
 	// Create a buffer to write our archive to.
 	buf := new(bytes.Buffer)
 	// Create a new zip archive.
@@ -68,9 +75,9 @@ func (self *ApierV1) ExportCdrsToZipString(attr utils.AttrExpFileCdrs, reply *st
 	// Write metadata into a separate file with extension .cgr
 	medaData, err := json.MarshalIndent(efc, "", "  ")
 	if err != nil {
-		errors.New("Failed creating metadata content")
+		return errors.New("Failed creating metadata content")
 	}
-	medatadaFileName := exportFileName[:len(path.Ext(exportFileName))] + ".cgr"
+	medatadaFileName := strings.TrimSuffix(exportFileName, path.Ext(exportFileName)) + ".cgr"
 	mf, err := w.Create(medatadaFileName)
 	if err != nil {
 		return err
@@ -83,15 +90,17 @@ func (self *ApierV1) ExportCdrsToZipString(attr utils.AttrExpFileCdrs, reply *st
 	if err := w.Close(); err != nil {
 		return err
 	}
-	if err := os.Remove(efc.ExportedFilePath); err != nil {
-		fmt.Errorf("Failed removing exported file at path: %s", efc.ExportedFilePath)
-	}
 	*reply = base64.StdEncoding.EncodeToString(buf.Bytes())
 	return nil
 }
 
 // Export Cdrs to file
 func (self *ApierV1) ExportCdrsToFile(attr utils.AttrExpFileCdrs, reply *utils.ExportedFileCdrs) error {
+	return self.exportCdrsToFile(attr, reply, false)
+}
+
+// Exports the CDRs to file, plainFile disables the compression and manifest of the export template
+func (self *ApierV1) exportCdrsToFile(attr utils.AttrExpFileCdrs, reply *utils.ExportedFileCdrs, plainFile bool) error {
 	var err error
 
 	cdreReloadStruct := <-self.Config.ConfigReloads[utils.CDRE]                  // Read the content of the channel, locking it
@@ -106,6 +115,11 @@ func (self *ApierV1) ExportCdrsToFile(attr utils.AttrExpFileCdrs, reply *utils.E
 	if exportTemplate == nil {
 		return fmt.Errorf("%s:ExportTemplate", utils.ErrMandatoryIeMissing.Error())
 	}
+	if plainFile && (len(exportTemplate.Compression) != 0 || exportTemplate.Manifest) {
+		exportTemplate = exportTemplate.Clone()
+		exportTemplate.Compression = ""
+		exportTemplate.Manifest = false
+	}
 	cdrFormat := exportTemplate.CdrFormat
 	if attr.CdrFormat != nil && len(*attr.CdrFormat) != 0 {
 		cdrFormat = strings.ToLower(*attr.CdrFormat)
@@ -172,26 +186,21 @@ func (self *ApierV1) ExportCdrsToFile(attr utils.AttrExpFileCdrs, reply *utils.E
 	if err != nil {
 		return utils.NewErrServerError(err)
 	}
-	cdrs, _, err := self.CdrDb.GetStoredCdrs(cdrsFltr)
-	if err != nil {
-		return err
-	} else if len(cdrs) == 0 {
-		*reply = utils.ExportedFileCdrs{ExportedFilePath: ""}
-		return nil
-	}
-	cdrexp, err := cdre.NewCdrExporter(cdrs, self.CdrDb, exportTemplate, cdrFormat, fieldSep, exportId, dataUsageMultiplyFactor, smsUsageMultiplyFactor, genericUsageMultiplyFactor,
+	cdrexp, err := cdre.NewCdrStreamExporter(self.CdrDb, cdrsFltr, exportTemplate.ExportPageSize, exportTemplate, cdrFormat, fieldSep, exportId, dataUsageMultiplyFactor, smsUsageMultiplyFactor, genericUsageMultiplyFactor,
 		costMultiplyFactor, costShiftDigits, roundingDecimals, self.Config.RoundingDecimals, maskDestId, maskLen, self.Config.HttpSkipTlsVerify, self.Config.DefaultTimezone)
 	if err != nil {
 		return utils.NewErrServerError(err)
 	}
+	// CgrIds are kept in memory and reported up to cdre.CDRE_MAX_KEPT_CGRIDS
+	cdrexp.SetKeepCgrIds(!attr.SuppressCgrIds)
+	if err := cdrexp.ExportToFile(filePath); err != nil { // CDRs are paged out of StorDB and written as they come
+		return err
+	}
 	if cdrexp.TotalExportedCdrs() == 0 {
 		*reply = utils.ExportedFileCdrs{ExportedFilePath: ""}
 		return nil
 	}
-	if err := cdrexp.WriteToFile(filePath); err != nil {
-		return utils.NewErrServerError(err)
-	}
-	*reply = utils.ExportedFileCdrs{ExportedFilePath: filePath, TotalRecords: len(cdrs), TotalCost: cdrexp.TotalCost(), FirstOrderId: cdrexp.FirstOrderId(), LastOrderId: cdrexp.LastOrderId()}
+	*reply = utils.ExportedFileCdrs{ExportedFilePath: cdrexp.ExportedFilePath(), TotalRecords: cdrexp.FetchedCdrs(), TotalCost: cdrexp.TotalCost(), FirstOrderId: cdrexp.FirstOrderId(), LastOrderId: cdrexp.LastOrderId()}
 	if !attr.SuppressCgrIds {
 		reply.ExportedCgrIds = cdrexp.PositiveExports()
 		reply.UnexportedCgrIds = cdrexp.NegativeExports()
@@ -199,6 +208,66 @@ func (self *ApierV1) ExportCdrsToFile(attr utils.AttrExpFileCdrs, reply *utils.E
 	return nil
 }
 
+type AttrGetCdreRuns struct {
+	JobId string // Runs of this job, all if empty
+	utils.Paginator
+}
+
+// Returns the bookkeeping of the scheduled exports
+func (self *ApierV1) GetCdreRuns(attr AttrGetCdreRuns, reply *[]*engine.CdreRun) error {
+	runs, err := self.CdrDb.GetCdreRuns(attr.JobId, &attr.Paginator)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	} else if len(runs) == 0 {
+		return utils.ErrNotFound
+	}
+	*reply = runs
+	return nil
+}
+
+type AttrCdreJobExport struct {
+	JobId    string
+	ExportId string // Export to be re-run, ignored by RunCdreJob
+}
+
+// Runs a scheduled export job out of schedule, exporting the CDRs not yet exported by it
+func (self *ApierV1) RunCdreJob(attr AttrCdreJobExport, reply *engine.CdreRun) error {
+	if missing := utils.MissingStructFields(&attr, []string{"JobId"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if self.CdreJobs == nil {
+		return utils.ErrNotImplemented
+	}
+	run, err := self.CdreJobs.RunJob(attr.JobId)
+	if err != nil {
+		if err == utils.ErrNotFound {
+			return err
+		}
+		return utils.NewErrServerError(err)
+	}
+	*reply = *run
+	return nil
+}
+
+// Re-exports the CDRs of a past run, overwriting its export file
+func (self *ApierV1) RerunCdreExport(attr AttrCdreJobExport, reply *engine.CdreRun) error {
+	if missing := utils.MissingStructFields(&attr, []string{"JobId", "ExportId"}); len(missing) != 0 {
+		return utils.NewErrMandatoryIeMissing(missing...)
+	}
+	if self.CdreJobs == nil {
+		return utils.ErrNotImplemented
+	}
+	run, err := self.CdreJobs.RerunExport(attr.JobId, attr.ExportId)
+	if err != nil {
+		if err == utils.ErrNotFound {
+			return err
+		}
+		return utils.NewErrServerError(err)
+	}
+	*reply = *run
+	return nil
+}
+
 // Remove Cdrs out of CDR storage
 func (self *ApierV1) RemCdrs(attrs utils.AttrRemCdrs, reply *string) error {
 	if len(attrs.CgrIds) == 0 {
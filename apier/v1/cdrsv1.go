@@ -39,6 +39,25 @@ func (self *CdrsV1) ProcessCdr(cdr *engine.StoredCdr, reply *string) error {
 	return nil
 }
 
+// Designed for CGR internal usage, replies with one result per CDR, utils.OK or the error processing it.
+// Storage errors fail the whole batch which can be retried, CDRs already stored being skipped.
+func (self *CdrsV1) ProcessCdrs(cdrs []*engine.StoredCdr, reply *[]string) error {
+	errs, err := self.CdrSrv.ProcessCdrs(cdrs)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	replies := make([]string, len(cdrs))
+	for idx, cdrErr := range errs {
+		if cdrErr != nil {
+			replies[idx] = utils.NewErrServerError(cdrErr).Error()
+		} else {
+			replies[idx] = utils.OK
+		}
+	}
+	*reply = replies
+	return nil
+}
+
 // Designed for external programs feeding CDRs to CGRateS
 func (self *CdrsV1) ProcessExternalCdr(cdr *engine.ExternalCdr, reply *string) error {
 	if err := self.CdrSrv.ProcessExternalCdr(cdr); err != nil {
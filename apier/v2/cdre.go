@@ -108,26 +108,21 @@ func (self *ApierV2) ExportCdrsToFile(attr utils.AttrExportCdrsToFile, reply *ut
 	if err != nil {
 		return utils.NewErrServerError(err)
 	}
-	cdrs, _, err := self.CdrDb.GetStoredCdrs(cdrsFltr)
-	if err != nil {
-		return err
-	} else if len(cdrs) == 0 {
-		*reply = utils.ExportedFileCdrs{ExportedFilePath: ""}
-		return nil
-	}
-	cdrexp, err := cdre.NewCdrExporter(cdrs, self.CdrDb, exportTemplate, cdrFormat, fieldSep, exportId, dataUsageMultiplyFactor, smsUsageMultiplyFactor, genericUsageMultiplyFactor,
+	cdrexp, err := cdre.NewCdrStreamExporter(self.CdrDb, cdrsFltr, exportTemplate.ExportPageSize, exportTemplate, cdrFormat, fieldSep, exportId, dataUsageMultiplyFactor, smsUsageMultiplyFactor, genericUsageMultiplyFactor,
 		costMultiplyFactor, costShiftDigits, roundingDecimals, self.Config.RoundingDecimals, maskDestId, maskLen, self.Config.HttpSkipTlsVerify, self.Config.DefaultTimezone)
 	if err != nil {
 		return utils.NewErrServerError(err)
 	}
+	// CgrIds are kept in memory and reported up to cdre.CDRE_MAX_KEPT_CGRIDS
+	cdrexp.SetKeepCgrIds(!attr.SuppressCgrIds)
+	if err := cdrexp.ExportToFile(filePath); err != nil { // CDRs are paged out of StorDB and written as they come
+		return err
+	}
 	if cdrexp.TotalExportedCdrs() == 0 {
 		*reply = utils.ExportedFileCdrs{ExportedFilePath: ""}
 		return nil
 	}
-	if err := cdrexp.WriteToFile(filePath); err != nil {
-		return utils.NewErrServerError(err)
-	}
-	*reply = utils.ExportedFileCdrs{ExportedFilePath: filePath, TotalRecords: len(cdrs), TotalCost: cdrexp.TotalCost(), FirstOrderId: cdrexp.FirstOrderId(), LastOrderId: cdrexp.LastOrderId()}
+	*reply = utils.ExportedFileCdrs{ExportedFilePath: cdrexp.ExportedFilePath(), TotalRecords: cdrexp.FetchedCdrs(), TotalCost: cdrexp.TotalCost(), FirstOrderId: cdrexp.FirstOrderId(), LastOrderId: cdrexp.LastOrderId()}
 	if !attr.SuppressCgrIds {
 		reply.ExportedCgrIds = cdrexp.PositiveExports()
 		reply.UnexportedCgrIds = cdrexp.NegativeExports()
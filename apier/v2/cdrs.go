@@ -19,6 +19,8 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package v2
 
 import (
+	"encoding/base64"
+
 	"github.com/cgrates/cgrates/apier/v1"
 
 	"github.com/cgrates/cgrates/engine"
@@ -43,6 +45,78 @@ func (apier *ApierV2) GetCdrs(attrs utils.RpcCdrsFilter, reply *[]*engine.Extern
 	return nil
 }
 
+const CDRS_PAGE_SIZE = 100 // Default number of CDRs returned by GetCdrsPage
+
+type AttrGetCdrsPage struct {
+	utils.RpcCdrsFilter
+	Cursor   string // Returned by the previous call, empty for the first page
+	PageSize int    // Maximum number of CDRs returned, derived CDRs of the last primary one might move to the next page
+}
+
+type CdrsPage struct {
+	Cdrs   []*engine.ExternalCdr
+	Cursor string // Pass it back to get the next page, empty when there are no more CDRs
+}
+
+// Streams the CDRs matching the filters page by page, the cursor keeps the position so CDRs added meanwhile do not shift the pages
+func (apier *ApierV2) GetCdrsPage(attrs AttrGetCdrsPage, reply *CdrsPage) error {
+	cdrsFltr, err := attrs.AsCdrsFilter(apier.Config.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	pageSize := attrs.PageSize
+	if pageSize <= 0 {
+		pageSize = CDRS_PAGE_SIZE
+	}
+	var cursor []byte
+	if attrs.Cursor != "" {
+		if cursor, err = base64.URLEncoding.DecodeString(attrs.Cursor); err != nil {
+			return engine.ErrInvalidCdrsCursor
+		}
+	}
+	cdrs, nextCursor, err := apier.CdrDb.GetStoredCdrsPage(cdrsFltr, string(cursor), pageSize)
+	if err == engine.ErrInvalidCdrsCursor {
+		return err
+	} else if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	reply.Cdrs = make([]*engine.ExternalCdr, len(cdrs))
+	for idx, cdr := range cdrs {
+		reply.Cdrs[idx] = cdr.AsExternalCdr()
+	}
+	if nextCursor != "" {
+		reply.Cursor = base64.URLEncoding.EncodeToString([]byte(nextCursor))
+	}
+	return nil
+}
+
+type AttrGetCdrsAggregate struct {
+	utils.RpcCdrsFilter
+	GroupBy    []string // Fields to group on, out of Tenant, Account, DestinationId, Supplier and MediationRunId
+	TimeBucket string   // Group also on the AnswerTime, truncated to <""|*hourly|*daily|*monthly>
+}
+
+// Counts and sums up usage and cost of the CDRs matching the filters, grouped on the fields requested. Computed by the StorDB.
+// Only the *default run is considered unless RunIds are filtered on or MediationRunId is grouped on.
+func (apier *ApierV2) GetCdrsAggregate(attrs AttrGetCdrsAggregate, reply *[]*engine.CdrsAggregate) error {
+	if err := engine.CheckCdrsAggregateGroupBy(attrs.GroupBy, attrs.TimeBucket); err != nil {
+		return err
+	}
+	cdrsFltr, err := attrs.AsCdrsFilter(apier.Config.DefaultTimezone)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	aggrs, err := apier.CdrDb.GetCdrsAggregates(cdrsFltr, attrs.GroupBy, attrs.TimeBucket)
+	if err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if len(aggrs) == 0 {
+		aggrs = make([]*engine.CdrsAggregate, 0)
+	}
+	*reply = aggrs
+	return nil
+}
+
 func (apier *ApierV2) CountCdrs(attrs utils.RpcCdrsFilter, reply *int64) error {
 	cdrsFltr, err := attrs.AsCdrsFilter(apier.Config.DefaultTimezone)
 	if err != nil {
@@ -200,6 +200,28 @@ func TestV2CdrsMysqlCountCdrs(t *testing.T) {
 	}
 }
 
+func TestV2CdrsMysqlGetCdrsPage(t *testing.T) {
+	if !*testLocal {
+		return
+	}
+	var cdrs []*engine.ExternalCdr
+	req := AttrGetCdrsPage{RpcCdrsFilter: utils.RpcCdrsFilter{}, PageSize: 3}
+	for pages := 1; pages <= 4; pages++ {
+		var reply CdrsPage
+		if err := cdrsRpc.Call("ApierV2.GetCdrsPage", req, &reply); err != nil {
+			t.Fatal("Unexpected error: ", err.Error())
+		}
+		cdrs = append(cdrs, reply.Cdrs...)
+		if reply.Cursor == "" {
+			break
+		}
+		req.Cursor = reply.Cursor
+	}
+	if len(cdrs) != 4 {
+		t.Error("Unexpected number of CDRs returned: ", len(cdrs))
+	}
+}
+
 // Test Prepaid CDRs without previous costs being calculated
 func TestV2CdrsMysqlProcessPrepaidCdr(t *testing.T) {
 	if !*testLocal {
@@ -1,7 +1,13 @@
 //Simple caching library with expiration capabilities
 package cache2go
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
 
 const (
 	PREFIX_LEN   = 4
@@ -11,12 +17,30 @@ const (
 	KIND_POP     = "POP"
 	KIND_PRF     = "PRF"
 	DOUBLE_CACHE = true
+	HIT          = "HIT"
+	MISS         = "MISS"
+	EVICTION     = "EVICTION"
+	EXPIRATION   = "EXPIRATION"
 )
 
-type transactionItem struct {
-	key   string
-	value interface{}
-	kind  string
+// Limits of the entries cached under one prefix
+type PrefixConfig struct {
+	Limit   int           // Maximum number of entries, the least recently used ones are evicted over it, 0 for unlimited
+	TTL     time.Duration // Entries expire this long after being cached, 0 to never expire
+	Partial bool          // Not all entries are cached upfront, the storages load them from the database on cache miss
+}
+
+// Get needs to reorder or expire the entries of the prefix
+func (self *PrefixConfig) bounded() bool {
+	return self != nil && (self.Limit > 0 || self.TTL != 0)
+}
+
+// Events happened on the entries of one prefix
+type Counters struct {
+	Hits        int64
+	Misses      int64
+	Evictions   int64 // Entries removed to keep the prefix within its limit
+	Expirations int64 // Entries found expired on Get
 }
 
 func init() {
@@ -30,118 +54,117 @@ func init() {
 var (
 	mux   sync.RWMutex
 	cache cacheStore
-	// transaction stuff
-	transactionBuffer []*transactionItem
-	transactionMux    sync.Mutex
-	transactionON     = false
-	transactionLock   = false
+	// limits and statistics
+	prefixConfigs = make(map[string]*PrefixConfig)
+	counters      = make(map[string]*Counters)
+	countersMux   sync.Mutex
 )
 
-func BeginTransaction() {
-	transactionMux.Lock()
-	transactionLock = true
-	transactionON = true
+// Applies limits to the entries of prefix, nil to remove them. Only the double store applies them.
+func SetPrefixConfig(prefix string, cfg *PrefixConfig) {
+	mux.Lock()
+	defer mux.Unlock()
+	if cfg == nil {
+		delete(prefixConfigs, prefix)
+	} else {
+		prefixConfigs[prefix] = cfg
+	}
+}
+
+// The entries of prefix are loaded on first use, a miss does not mean they are not in the database
+func IsPartial(prefix string) bool {
+	mux.RLock()
+	defer mux.RUnlock()
+	cfg, hasIt := prefixConfigs[prefix]
+	return hasIt && cfg.Partial
 }
 
-func RollbackTransaction() {
-	transactionBuffer = nil
-	transactionLock = false
-	transactionON = false
-	transactionMux.Unlock()
+func countEvent(prefix, event string) {
+	countersMux.Lock()
+	cntrs, hasIt := counters[prefix]
+	if !hasIt {
+		cntrs = new(Counters)
+		counters[prefix] = cntrs
+	}
+	countersMux.Unlock()
+	switch event {
+	case HIT:
+		atomic.AddInt64(&cntrs.Hits, 1)
+	case MISS:
+		atomic.AddInt64(&cntrs.Misses, 1)
+	case EVICTION:
+		atomic.AddInt64(&cntrs.Evictions, 1)
+	case EXPIRATION:
+		atomic.AddInt64(&cntrs.Expirations, 1)
+	}
 }
 
-func CommitTransaction() {
-	transactionON = false
-	// apply all transactioned items
-	mux.Lock()
-	for _, item := range transactionBuffer {
-		switch item.kind {
-		case KIND_REM:
-			RemKey(item.key)
-		case KIND_PRF:
-			RemPrefixKey(item.key)
-		case KIND_ADD:
-			Cache(item.key, item.value)
-		case KIND_ADP:
-			Push(item.key, item.value)
-		case KIND_POP:
-			Pop(item.key, item.value)
-		}
+// Drops the counters gathered so far
+func resetCounters() {
+	countersMux.Lock()
+	counters = make(map[string]*Counters)
+	countersMux.Unlock()
+}
+
+// Returns a copy of the counters, indexed on prefix
+func GetCounters() map[string]*Counters {
+	countersMux.Lock()
+	defer countersMux.Unlock()
+	cntrsCopy := make(map[string]*Counters, len(counters))
+	for prefix, cntrs := range counters {
+		cntrsCopy[prefix] = &Counters{Hits: atomic.LoadInt64(&cntrs.Hits), Misses: atomic.LoadInt64(&cntrs.Misses),
+			Evictions: atomic.LoadInt64(&cntrs.Evictions), Expirations: atomic.LoadInt64(&cntrs.Expirations)}
 	}
-	mux.Unlock()
-	transactionBuffer = nil
-	transactionLock = false
-	transactionMux.Unlock()
+	return cntrsCopy
 }
 
 // The function to be used to cache a key/value pair when expiration is not needed
 func Cache(key string, value interface{}) {
-	if !transactionLock {
-		mux.Lock()
-		defer mux.Unlock()
-	}
-	if !transactionON {
-		cache.Put(key, value)
-		//log.Println("ADD: ", key)
-	} else {
-		transactionBuffer = append(transactionBuffer, &transactionItem{key: key, value: value, kind: KIND_ADD})
-	}
+	mux.Lock()
+	defer mux.Unlock()
+	cache.Put(key, value)
 }
 
 // Appends to an existing slice in the cache key
 func Push(key string, value interface{}) {
-	if !transactionLock {
-		mux.Lock()
-		defer mux.Unlock()
-	}
-	if !transactionON {
-		cache.Append(key, value)
-	} else {
-		transactionBuffer = append(transactionBuffer, &transactionItem{key: key, value: value, kind: KIND_ADP})
-	}
+	mux.Lock()
+	defer mux.Unlock()
+	cache.Append(key, value)
 }
 
-// The function to extract a value for a key that never expire
+// The function to extract a value for a key, the prefixes with limits reorder their entries under a lock of their own
 func Get(key string) (v interface{}, err error) {
+	if len(key) < PREFIX_LEN {
+		return nil, utils.ErrNotFound
+	}
+	prefix := key[:PREFIX_LEN]
 	mux.RLock()
-	defer mux.RUnlock()
-	return cache.Get(key)
+	v, err = cache.Get(key)
+	mux.RUnlock()
+	if err != nil {
+		countEvent(prefix, MISS)
+	} else {
+		countEvent(prefix, HIT)
+	}
+	return
 }
 
 func Pop(key string, value interface{}) {
-	if !transactionLock {
-		mux.Lock()
-		defer mux.Unlock()
-	}
-	if !transactionON {
-		cache.Pop(key, value)
-	} else {
-		transactionBuffer = append(transactionBuffer, &transactionItem{key: key, value: value, kind: KIND_POP})
-	}
+	mux.Lock()
+	defer mux.Unlock()
+	cache.Pop(key, value)
 }
 
 func RemKey(key string) {
-	if !transactionLock {
-		mux.Lock()
-		defer mux.Unlock()
-	}
-	if !transactionON {
-		cache.Delete(key)
-	} else {
-		transactionBuffer = append(transactionBuffer, &transactionItem{key: key, kind: KIND_REM})
-	}
+	mux.Lock()
+	defer mux.Unlock()
+	cache.Delete(key)
 }
 
 func RemPrefixKey(prefix string) {
-	if !transactionLock {
-		mux.Lock()
-		defer mux.Unlock()
-	}
-	if !transactionON {
-		cache.DeletePrefix(prefix)
-	} else {
-		transactionBuffer = append(transactionBuffer, &transactionItem{key: prefix, kind: KIND_PRF})
-	}
+	mux.Lock()
+	defer mux.Unlock()
+	cache.DeletePrefix(prefix)
 }
 
 // Delete all keys from cache
@@ -1,6 +1,10 @@
 package cache2go
 
-import "testing"
+import (
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestRemKey(t *testing.T) {
 	Cache("t11_mm", "test")
@@ -14,14 +18,14 @@ func TestRemKey(t *testing.T) {
 }
 
 func TestTransaction(t *testing.T) {
-	BeginTransaction()
-	Cache("t11_mm", "test")
+	tx := Begin()
+	tx.Cache("t11_mm", "test")
 	if t1, err := Get("t11_mm"); err == nil || t1 == "test" {
 		t.Error("Error in transaction cache")
 	}
-	Cache("t12_mm", "test")
-	RemKey("t11_mm")
-	CommitTransaction()
+	tx.Cache("t12_mm", "test")
+	tx.RemKey("t11_mm")
+	tx.Commit()
 	if t1, err := Get("t12_mm"); err != nil || t1 != "test" {
 		t.Error("Error commiting transaction")
 	}
@@ -31,11 +35,11 @@ func TestTransaction(t *testing.T) {
 }
 
 func TestTransactionRem(t *testing.T) {
-	BeginTransaction()
-	Cache("t21_mm", "test")
-	Cache("t21_nn", "test")
-	RemPrefixKey("t21_")
-	CommitTransaction()
+	tx := Begin()
+	tx.Cache("t21_mm", "test")
+	tx.Cache("t21_nn", "test")
+	tx.RemPrefixKey("t21_")
+	tx.Commit()
 	if t1, err := Get("t21_mm"); err == nil || t1 == "test" {
 		t.Error("Error commiting transaction")
 	}
@@ -45,13 +49,13 @@ func TestTransactionRem(t *testing.T) {
 }
 
 func TestTransactionRollback(t *testing.T) {
-	BeginTransaction()
-	Cache("t31_mm", "test")
+	tx := Begin()
+	tx.Cache("t31_mm", "test")
 	if t1, err := Get("t31_mm"); err == nil || t1 == "test" {
 		t.Error("Error in transaction cache")
 	}
-	Cache("t32_mm", "test")
-	RollbackTransaction()
+	tx.Cache("t32_mm", "test")
+	tx.Rollback()
 	if t1, err := Get("t32_mm"); err == nil || t1 == "test" {
 		t.Error("Error commiting transaction")
 	}
@@ -61,11 +65,11 @@ func TestTransactionRollback(t *testing.T) {
 }
 
 func TestTransactionRemBefore(t *testing.T) {
-	BeginTransaction()
-	RemPrefixKey("t41_")
-	Cache("t41_mm", "test")
-	Cache("t41_nn", "test")
-	CommitTransaction()
+	tx := Begin()
+	tx.RemPrefixKey("t41_")
+	tx.Cache("t41_mm", "test")
+	tx.Cache("t41_nn", "test")
+	tx.Commit()
 	if t1, err := Get("t41_mm"); err != nil || t1 != "test" {
 		t.Error("Error commiting transaction")
 	}
@@ -74,6 +78,35 @@ func TestTransactionRemBefore(t *testing.T) {
 	}
 }
 
+func TestTransactionIsolation(t *testing.T) {
+	tx := Begin()
+	tx.RemPrefixKey("t51_")
+	tx.Cache("t51_mm", "test")
+	Cache("t51_nn", "test") // Written by someone else during the transaction
+	if t1, err := Get("t51_nn"); err != nil || t1 != "test" {
+		t.Error("Write outside transaction buffered")
+	}
+	tx.Commit()
+	if t1, err := Get("t51_mm"); err != nil || t1 != "test" {
+		t.Error("Error commiting transaction")
+	}
+	if _, err := Get("t51_nn"); err == nil {
+		t.Error("Transaction not applied over concurrent write")
+	}
+}
+
+func TestTransactionNil(t *testing.T) {
+	var tx *Transaction
+	tx.Cache("t61_mm", "test")
+	if t1, err := Get("t61_mm"); err != nil || t1 != "test" {
+		t.Error("Error caching without transaction")
+	}
+	tx.RemKey("t61_mm")
+	if _, err := Get("t61_mm"); err == nil {
+		t.Error("Error removing without transaction")
+	}
+}
+
 func TestRemPrefixKey(t *testing.T) {
 	Cache("xxx_t1", "test")
 	Cache("yyy_t1", "test")
@@ -118,3 +151,88 @@ func TestCount(t *testing.T) {
 		t.Error("Error countiong entries: ", CountEntries("dst_"))
 	}
 }
+
+func TestCacheLimitEviction(t *testing.T) {
+	resetCounters()
+	SetPrefixConfig("l41_", &PrefixConfig{Limit: 2, Partial: true})
+	defer SetPrefixConfig("l41_", nil)
+	RemPrefixKey("l41_")
+	Cache("l41_1", "one")
+	Cache("l41_2", "two")
+	if _, err := Get("l41_1"); err != nil { // l41_2 becomes least recently used
+		t.Error(err)
+	}
+	Cache("l41_3", "three")
+	if CountEntries("l41_") != 2 {
+		t.Error("Error limiting entries: ", CountEntries("l41_"))
+	}
+	if _, err := Get("l41_2"); err == nil {
+		t.Error("Least recently used not evicted")
+	}
+	if t1, err := Get("l41_1"); err != nil || t1 != "one" {
+		t.Error("Error getting cached key: ", err, t1)
+	}
+	if cntrs := GetCounters()["l41_"]; cntrs == nil || cntrs.Hits != 2 || cntrs.Misses != 1 || cntrs.Evictions != 1 {
+		t.Errorf("Unexpected counters: %+v", cntrs)
+	}
+}
+
+func TestCacheConcurrentGet(t *testing.T) {
+	resetCounters()
+	SetPrefixConfig("l43_", &PrefixConfig{Limit: 10, Partial: true})
+	defer SetPrefixConfig("l43_", nil)
+	RemPrefixKey("l43_")
+	Cache("l43_1", "one")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Get("l43_1")
+				CountEntries("l43_")
+			}
+		}()
+	}
+	wg.Wait()
+	if cntrs := GetCounters()["l43_"]; cntrs == nil || cntrs.Hits != 1000 {
+		t.Errorf("Unexpected counters: %+v", cntrs)
+	}
+}
+func TestCacheTTLExpiration(t *testing.T) {
+	resetCounters()
+	SetPrefixConfig("l42_", &PrefixConfig{TTL: time.Duration(10) * time.Millisecond, Partial: true})
+	defer SetPrefixConfig("l42_", nil)
+	RemPrefixKey("l42_")
+	Cache("l42_1", "one")
+	if t1, err := Get("l42_1"); err != nil || t1 != "one" {
+		t.Error("Error getting cached key: ", err, t1)
+	}
+	time.Sleep(time.Duration(20) * time.Millisecond)
+	if keys := GetEntriesKeys("l42_"); len(keys) != 0 {
+		t.Error("Expired keys returned: ", keys)
+	}
+	if _, err := Get("l42_1"); err == nil {
+		t.Error("Expired key returned")
+	}
+	if CountEntries("l42_") != 0 {
+		t.Error("Expired key not removed")
+	}
+	if cntrs := GetCounters()["l42_"]; cntrs == nil || cntrs.Expirations != 1 {
+		t.Errorf("Unexpected counters: %+v", cntrs)
+	}
+}
+
+func TestCacheIsPartial(t *testing.T) {
+	if IsPartial("l43_") {
+		t.Error("Partial without config")
+	}
+	SetPrefixConfig("l43_", &PrefixConfig{Partial: true})
+	if !IsPartial("l43_") {
+		t.Error("Not partial")
+	}
+	SetPrefixConfig("l43_", nil)
+	if IsPartial("l43_") {
+		t.Error("Partial after config removal")
+	}
+}
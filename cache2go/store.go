@@ -2,7 +2,10 @@
 package cache2go
 
 import (
+	"container/list"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cgrates/cgrates/utils"
 )
@@ -19,8 +22,35 @@ type cacheStore interface {
 	GetKeysForPrefix(string) []string
 }
 
-// easy to be counted exported by prefix
-type cacheDoubleStore map[string]map[string]interface{}
+// Entry together with the moment it expires at, zero time if never
+type cacheEntry struct {
+	key       string // Without prefix
+	value     interface{}
+	expiresAt time.Time
+}
+
+func (ce *cacheEntry) expired(now time.Time) bool {
+	return !ce.expiresAt.IsZero() && now.After(ce.expiresAt)
+}
+
+// Entries of one prefix, kept in the order of their last use so the least recently used can be evicted
+type prefixEntries struct {
+	sync.Mutex // Get reorders and expires entries holding only the read lock of the cache, readers of the prefix take this too
+	entries    map[string]*list.Element
+	lru        *list.List // Most recently used in front
+}
+
+func newPrefixEntries() *prefixEntries {
+	return &prefixEntries{entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func (pe *prefixEntries) remove(elm *list.Element) {
+	delete(pe.entries, elm.Value.(*cacheEntry).key)
+	pe.lru.Remove(elm)
+}
+
+// easy to be counted exported by prefix, limits configured with SetPrefixConfig are applied here
+type cacheDoubleStore map[string]*prefixEntries
 
 func newDoubleStore() cacheDoubleStore {
 	return make(cacheDoubleStore)
@@ -28,12 +58,29 @@ func newDoubleStore() cacheDoubleStore {
 
 func (cs cacheDoubleStore) Put(key string, value interface{}) {
 	prefix, key := key[:PREFIX_LEN], key[PREFIX_LEN:]
-	mp, ok := cs[prefix]
+	pe, ok := cs[prefix]
 	if !ok {
-		mp = make(map[string]interface{})
-		cs[prefix] = mp
+		pe = newPrefixEntries()
+		cs[prefix] = pe
+	}
+	cfg := prefixConfigs[prefix]
+	var expiresAt time.Time
+	if cfg != nil && cfg.TTL != 0 {
+		expiresAt = time.Now().Add(cfg.TTL)
+	}
+	if elm, exists := pe.entries[key]; exists {
+		ce := elm.Value.(*cacheEntry)
+		ce.value, ce.expiresAt = value, expiresAt
+		pe.lru.MoveToFront(elm)
+	} else {
+		pe.entries[key] = pe.lru.PushFront(&cacheEntry{key: key, value: value, expiresAt: expiresAt})
+	}
+	if cfg != nil && cfg.Limit > 0 {
+		for pe.lru.Len() > cfg.Limit {
+			pe.remove(pe.lru.Back())
+			countEvent(prefix, EVICTION)
+		}
 	}
-	mp[key] = value
 }
 
 func (cs cacheDoubleStore) Append(key string, value interface{}) {
@@ -47,14 +94,33 @@ func (cs cacheDoubleStore) Append(key string, value interface{}) {
 	cache.Put(key, elements)
 }
 
+// Expired entries are removed and the used ones moved in front only for prefixes with limits, under the lock of the prefix
 func (cs cacheDoubleStore) Get(key string) (interface{}, error) {
 	prefix, key := key[:PREFIX_LEN], key[PREFIX_LEN:]
-	if keyMap, ok := cs[prefix]; ok {
-		if ti, exists := keyMap[key]; exists {
-			return ti, nil
+	pe, ok := cs[prefix]
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	if !prefixConfigs[prefix].bounded() {
+		if elm, exists := pe.entries[key]; exists {
+			return elm.Value.(*cacheEntry).value, nil
 		}
+		return nil, utils.ErrNotFound
 	}
-	return nil, utils.ErrNotFound
+	pe.Lock()
+	defer pe.Unlock()
+	elm, exists := pe.entries[key]
+	if !exists {
+		return nil, utils.ErrNotFound
+	}
+	ce := elm.Value.(*cacheEntry)
+	if ce.expired(time.Now()) {
+		pe.remove(elm)
+		countEvent(prefix, EXPIRATION)
+		return nil, utils.ErrNotFound
+	}
+	pe.lru.MoveToFront(elm)
+	return ce.value, nil
 }
 
 func (cs cacheDoubleStore) Pop(key string, value interface{}) {
@@ -73,8 +139,10 @@ func (cs cacheDoubleStore) Pop(key string, value interface{}) {
 
 func (cs cacheDoubleStore) Delete(key string) {
 	prefix, key := key[:PREFIX_LEN], key[PREFIX_LEN:]
-	if keyMap, ok := cs[prefix]; ok {
-		delete(keyMap, key)
+	if pe, ok := cs[prefix]; ok {
+		if elm, exists := pe.entries[key]; exists {
+			pe.remove(elm)
+		}
 	}
 }
 
@@ -82,24 +150,43 @@ func (cs cacheDoubleStore) DeletePrefix(prefix string) {
 	delete(cs, prefix)
 }
 
+// Expired entries not yet removed by Get are counted in
 func (cs cacheDoubleStore) CountEntriesForPrefix(prefix string) int {
-	if m, ok := cs[prefix]; ok {
-		return len(m)
+	if pe, ok := cs[prefix]; ok {
+		pe.Lock()
+		defer pe.Unlock()
+		return len(pe.entries)
 	}
 	return 0
 }
 
 func (cs cacheDoubleStore) GetAllForPrefix(prefix string) (map[string]interface{}, error) {
-	if keyMap, ok := cs[prefix]; ok {
-		return keyMap, nil
+	pe, ok := cs[prefix]
+	if !ok {
+		return nil, utils.ErrNotFound
 	}
-	return nil, utils.ErrNotFound
+	pe.Lock()
+	defer pe.Unlock()
+	now := time.Now()
+	keyMap := make(map[string]interface{}, len(pe.entries))
+	for key, elm := range pe.entries {
+		if ce := elm.Value.(*cacheEntry); !ce.expired(now) {
+			keyMap[key] = ce.value
+		}
+	}
+	return keyMap, nil
 }
 
 func (cs cacheDoubleStore) GetKeysForPrefix(prefix string) (keys []string) {
 	prefix, key := prefix[:PREFIX_LEN], prefix[PREFIX_LEN:]
-	if keyMap, ok := cs[prefix]; ok {
-		for iterKey := range keyMap {
+	if pe, ok := cs[prefix]; ok {
+		pe.Lock()
+		defer pe.Unlock()
+		now := time.Now()
+		for iterKey, elm := range pe.entries {
+			if elm.Value.(*cacheEntry).expired(now) {
+				continue
+			}
 			if len(key) == 0 || strings.HasPrefix(iterKey, key) {
 				keys = append(keys, prefix+iterKey)
 			}
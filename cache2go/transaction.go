@@ -0,0 +1,89 @@
+package cache2go
+
+type transactionItem struct {
+	key   string
+	value interface{}
+	kind  string
+}
+
+// Cache writes collected on behalf of one caller, invisible to readers until committed.
+// A nil transaction applies the writes directly to cache.
+type Transaction struct {
+	items []*transactionItem
+}
+
+func Begin() *Transaction {
+	return new(Transaction)
+}
+
+func (tx *Transaction) Cache(key string, value interface{}) {
+	if tx == nil {
+		Cache(key, value)
+		return
+	}
+	tx.items = append(tx.items, &transactionItem{key: key, value: value, kind: KIND_ADD})
+}
+
+func (tx *Transaction) Push(key string, value interface{}) {
+	if tx == nil {
+		Push(key, value)
+		return
+	}
+	tx.items = append(tx.items, &transactionItem{key: key, value: value, kind: KIND_ADP})
+}
+
+func (tx *Transaction) Pop(key string, value interface{}) {
+	if tx == nil {
+		Pop(key, value)
+		return
+	}
+	tx.items = append(tx.items, &transactionItem{key: key, value: value, kind: KIND_POP})
+}
+
+func (tx *Transaction) RemKey(key string) {
+	if tx == nil {
+		RemKey(key)
+		return
+	}
+	tx.items = append(tx.items, &transactionItem{key: key, kind: KIND_REM})
+}
+
+func (tx *Transaction) RemPrefixKey(prefix string) {
+	if tx == nil {
+		RemPrefixKey(prefix)
+		return
+	}
+	tx.items = append(tx.items, &transactionItem{key: prefix, kind: KIND_PRF})
+}
+
+// Applies all the writes under one lock so readers see either the cache before or after them
+func (tx *Transaction) Commit() {
+	if tx == nil {
+		return
+	}
+	mux.Lock()
+	for _, item := range tx.items {
+		switch item.kind {
+		case KIND_REM:
+			cache.Delete(item.key)
+		case KIND_PRF:
+			cache.DeletePrefix(item.key)
+		case KIND_ADD:
+			cache.Put(item.key, item.value)
+		case KIND_ADP:
+			cache.Append(item.key, item.value)
+		case KIND_POP:
+			cache.Pop(item.key, item.value)
+		}
+	}
+	mux.Unlock()
+	tx.items = nil
+}
+
+// Drops the writes collected so far
+func (tx *Transaction) Rollback() {
+	if tx == nil {
+		return
+	}
+	tx.items = nil
+}
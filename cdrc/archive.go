@@ -0,0 +1,164 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Magic numbers identifying the compressed files
+var compressionMagics = []struct {
+	compression string
+	magic       []byte
+}{
+	{utils.GZIP, []byte{0x1f, 0x8b}},
+	{utils.BZIP2, []byte("BZh")},
+	{utils.ZIP, []byte("PK\x03\x04")},
+}
+
+// Returns the compression of the file out of its content, empty for plain files. The file is rewound after detection.
+func detectCompression(file *os.File) (string, error) {
+	magic := make([]byte, 4)
+	nRead, err := io.ReadFull(file, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return "", err
+	}
+	for _, cmprsMagic := range compressionMagics {
+		if !bytes.HasPrefix(magic[:nRead], cmprsMagic.magic) {
+			continue
+		}
+		if cmprsMagic.compression == utils.BZIP2 && (nRead < 4 || magic[3] < '1' || magic[3] > '9') { // Block size follows the bzip2 magic
+			continue
+		}
+		return cmprsMagic.compression, nil
+	}
+	return "", nil
+}
+
+var ErrArchiveTooLarge = errors.New("ARCHIVE_TOO_LARGE")
+
+// Reader failing with ErrArchiveTooLarge once more than the remaining bytes were read out of the archive, shared by all of its members
+type cappedReader struct {
+	rdr       io.Reader
+	remaining *int64 // nil for unlimited
+}
+
+func (self *cappedReader) Read(p []byte) (int, error) {
+	if self.remaining != nil && *self.remaining < 0 {
+		return 0, ErrArchiveTooLarge
+	}
+	n, err := self.rdr.Read(p)
+	if self.remaining != nil {
+		if *self.remaining -= int64(n); *self.remaining < 0 {
+			return n, ErrArchiveTooLarge
+		}
+	}
+	return n, err
+}
+
+// Decompresses the file as a stream, passing each member to mbrHandler in the order they are found in the archive.
+// Reading more than maxSize bytes out of the archive fails with ErrArchiveTooLarge, 0 for no limit.
+func walkArchive(file *os.File, fileName, compression string, maxSize int64, mbrHandler func(mbrName string, mbrIdx int, mbrRdr io.Reader) error) error {
+	var remaining *int64
+	if maxSize > 0 {
+		remaining = &maxSize
+	}
+	handleMember := func(mbrName string, mbrIdx int, mbrRdr io.Reader) error {
+		if err := mbrHandler(mbrName, mbrIdx, &cappedReader{rdr: mbrRdr, remaining: remaining}); err != nil {
+			return err
+		} else if remaining != nil && *remaining < 0 { // Records processors stopping on read errors without returning them
+			return ErrArchiveTooLarge
+		}
+		return nil
+	}
+	switch compression {
+	case utils.GZIP:
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzReader.Close()
+		mbrName := gzReader.Name
+		if len(mbrName) == 0 {
+			mbrName = strings.TrimSuffix(fileName, path.Ext(fileName))
+		}
+		return handleMember(path.Base(mbrName), 0, gzReader)
+	case utils.BZIP2:
+		return handleMember(strings.TrimSuffix(fileName, path.Ext(fileName)), 0, bzip2.NewReader(file))
+	case utils.ZIP:
+		fi, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		zipReader, err := zip.NewReader(file, fi.Size())
+		if err != nil {
+			return err
+		}
+		mbrIdx := 0
+		for _, zipFile := range zipReader.File {
+			if zipFile.FileInfo().IsDir() {
+				continue
+			}
+			if remaining != nil && zipFile.UncompressedSize64 > uint64(*remaining) { // Declared size, the actual one is enforced while reading
+				return ErrArchiveTooLarge
+			}
+			zipMbr, err := zipFile.Open()
+			if err != nil {
+				return err
+			}
+			err = handleMember(path.Base(zipFile.Name), mbrIdx, zipMbr)
+			zipMbr.Close()
+			if err != nil {
+				return err
+			}
+			mbrIdx += 1
+		}
+		return nil
+	}
+	return fmt.Errorf("Unsupported compression: %s", compression)
+}
+
+// Writes the content of one member into tmpDir, for the records processors needing to seek into it.
+// The name is prefixed by the index of the member so members with the same name in different archive folders do not overwrite each other.
+func extractMember(rdr io.Reader, name, tmpDir string, idx int) (string, error) {
+	mbrPath := path.Join(tmpDir, fmt.Sprintf("%d_%s", idx, name))
+	mbrFile, err := os.Create(mbrPath)
+	if err != nil {
+		return "", err
+	}
+	defer mbrFile.Close()
+	if _, err := io.Copy(mbrFile, rdr); err != nil {
+		return "", err
+	}
+	return mbrPath, nil
+}
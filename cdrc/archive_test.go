@@ -0,0 +1,232 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Collects the CDRs posted by cdrc
+type cdrsCollector struct {
+	engine.Connector
+	sync.Mutex
+	accIds       []string
+	batches      int             // Number of ProcessCdrs calls received
+	refuseCalls  int             // Number of calls to be refused, simulating an overloaded CDRS
+	failStoring  int             // Number of calls storing only the first CDR and failing the rest, simulating storage errors
+	rejectAccIds map[string]bool // CDRs to reply with error for
+}
+
+func (self *cdrsCollector) ProcessCdr(cdr *engine.StoredCdr, reply *string) error {
+	self.Lock()
+	self.accIds = append(self.accIds, cdr.AccId)
+	self.Unlock()
+	*reply = utils.OK
+	return nil
+}
+
+func (self *cdrsCollector) ProcessCdrs(cdrs []*engine.StoredCdr, reply *[]string) error {
+	self.Lock()
+	defer self.Unlock()
+	self.batches += 1
+	if self.refuseCalls > 0 {
+		self.refuseCalls -= 1
+		return utils.ErrTimedOut
+	}
+	replies := make([]string, len(cdrs))
+	for idx, cdr := range cdrs {
+		if idx != 0 && self.failStoring > 0 {
+			self.failStoring -= 1
+			*reply = replies
+			return utils.ErrServerError
+		}
+		if self.rejectAccIds[cdr.AccId] {
+			replies[idx] = "SERVER_ERROR"
+			continue
+		}
+		self.accIds = append(self.accIds, cdr.AccId)
+		replies[idx] = utils.OK
+	}
+	*reply = replies
+	return nil
+}
+
+func csvCdrRow(accId string) string {
+	return "ignored,ignored,*voice," + accId + ",*prepaid,*out,cgrates.org,call,1001,1001,1002,2013-02-03 19:50:00,2013-02-03 19:54:00,62\n"
+}
+
+// Content of csvCdrRow("bz2acc") compressed with bzip2, no bzip2 writer in the standard library
+var bz2CdrFile = []byte{0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x97, 0x36, 0x41, 0x52, 0x00, 0x00, 0x30, 0x59, 0x80, 0x00,
+	0x10, 0x40, 0x17, 0x7f, 0x30, 0x3e, 0xa5, 0xdf, 0x10, 0x20, 0x00, 0x74, 0x1a, 0xa7, 0xa6, 0x89, 0x88, 0x00, 0x64, 0x7a, 0x87, 0xa9, 0xea, 0x09,
+	0x48, 0x86, 0x4d, 0x19, 0x1a, 0x69, 0x90, 0x1a, 0x0b, 0xdc, 0x5c, 0x92, 0x92, 0xb6, 0x78, 0xc7, 0x93, 0xa5, 0x5b, 0x80, 0xc2, 0xad, 0x28, 0x39,
+	0x16, 0x5a, 0x2a, 0x21, 0xaa, 0xcc, 0x91, 0x44, 0x49, 0xad, 0x2c, 0x0c, 0xe1, 0x21, 0x6c, 0xc4, 0x0c, 0x3e, 0x95, 0x17, 0xfb, 0xc2, 0xc9, 0xc4,
+	0x9e, 0x38, 0xaa, 0x1f, 0x0b, 0x28, 0x4f, 0x06, 0xb1, 0x8a, 0x21, 0xa0, 0x6f, 0x82, 0xae, 0x45, 0x0b, 0x86, 0x64, 0xf6, 0x99, 0xda, 0xdf, 0x8b,
+	0xb9, 0x22, 0x9c, 0x28, 0x48, 0x4b, 0x9b, 0x20, 0xa9, 0x00}
+
+func TestCdrcCompressedFiles(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	var err error
+	if cdrcConfig.CdrInDir, err = ioutil.TempDir("", "cdrc_in"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdrcConfig.CdrInDir)
+	if cdrcConfig.CdrOutDir, err = ioutil.TempDir("", "cdrc_out"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdrcConfig.CdrOutDir)
+	cdrs := new(cdrsCollector)
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC", cdrs: cdrs,
+		maxOpenFiles: make(chan struct{})}
+	// gzip
+	gzFile, _ := os.Create(path.Join(cdrcConfig.CdrInDir, "cdrs1.csv.gz"))
+	gzWriter := gzip.NewWriter(gzFile)
+	gzWriter.Write([]byte(csvCdrRow("gzacc1") + csvCdrRow("gzacc2")))
+	gzWriter.Close()
+	gzFile.Close()
+	// zip with two members, one in a folder
+	zipFile, _ := os.Create(path.Join(cdrcConfig.CdrInDir, "cdrs2.zip"))
+	zipWriter := zip.NewWriter(zipFile)
+	for mbrName, accId := range map[string]string{"cdrs2_1.csv": "zipacc1", "folder/cdrs2_2.csv": "zipacc2"} {
+		mbr, _ := zipWriter.Create(mbrName)
+		mbr.Write([]byte(csvCdrRow(accId)))
+	}
+	zipWriter.Close()
+	zipFile.Close()
+	// bzip2 and plain
+	ioutil.WriteFile(path.Join(cdrcConfig.CdrInDir, "cdrs3.csv.bz2"), bz2CdrFile, 0644)
+	ioutil.WriteFile(path.Join(cdrcConfig.CdrInDir, "cdrs4.csv"), []byte(csvCdrRow("plainacc")), 0644)
+	for _, fn := range []string{"cdrs1.csv.gz", "cdrs2.zip", "cdrs3.csv.bz2", "cdrs4.csv"} {
+		if err := cdrc.processFile(path.Join(cdrcConfig.CdrInDir, fn)); err != nil {
+			t.Fatalf("File: %s, error: %s", fn, err.Error())
+		}
+		if _, err := os.Stat(path.Join(cdrcConfig.CdrOutDir, fn)); err != nil {
+			t.Errorf("File: %s, not moved to out dir: %s", fn, err.Error())
+		}
+	}
+	sort.Strings(cdrs.accIds)
+	if eAccIds := []string{"bz2acc", "gzacc1", "gzacc2", "plainacc", "zipacc1", "zipacc2"}; len(cdrs.accIds) != len(eAccIds) {
+		t.Errorf("Expecting: %+v, received: %+v", eAccIds, cdrs.accIds)
+	} else {
+		for idx := range eAccIds {
+			if eAccIds[idx] != cdrs.accIds[idx] {
+				t.Errorf("Expecting: %+v, received: %+v", eAccIds, cdrs.accIds)
+				break
+			}
+		}
+	}
+	if files, _ := ioutil.ReadDir(cdrcConfig.CdrInDir); len(files) != 0 {
+		t.Errorf("Files left in in dir: %+v", files)
+	}
+}
+
+func TestCdrcArchiveTooLarge(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	var err error
+	if cdrcConfig.CdrInDir, err = ioutil.TempDir("", "cdrc_in"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdrcConfig.CdrInDir)
+	if cdrcConfig.CdrOutDir, err = ioutil.TempDir("", "cdrc_out"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdrcConfig.CdrOutDir)
+	cdrcConfig.MaxExtractedSize = int64(len(csvCdrRow("gzacc1"))) // Room for the first record only
+	cdrs := new(cdrsCollector)
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC", cdrs: cdrs,
+		maxOpenFiles: make(chan struct{})}
+	for fn, writeArchive := range map[string]func(*os.File){
+		"cdrs1.csv.gz": func(file *os.File) {
+			gzWriter := gzip.NewWriter(file)
+			gzWriter.Write([]byte(csvCdrRow("gzacc1") + csvCdrRow("gzacc2")))
+			gzWriter.Close()
+		},
+		"cdrs2.zip": func(file *os.File) {
+			zipWriter := zip.NewWriter(file)
+			for _, mbrName := range []string{"cdrs2_1.csv", "cdrs2_2.csv"} {
+				mbr, _ := zipWriter.Create(mbrName)
+				mbr.Write([]byte(csvCdrRow(mbrName)))
+			}
+			zipWriter.Close()
+		},
+	} {
+		file, _ := os.Create(path.Join(cdrcConfig.CdrInDir, fn))
+		writeArchive(file)
+		file.Close()
+		if err := cdrc.processFile(path.Join(cdrcConfig.CdrInDir, fn)); err != ErrArchiveTooLarge {
+			t.Errorf("File: %s, expecting error: %v, received: %v", fn, ErrArchiveTooLarge, err)
+		}
+		if _, err := os.Stat(path.Join(cdrcConfig.CdrOutDir, fn)); err == nil {
+			t.Errorf("File: %s, moved to out dir", fn)
+		}
+	}
+}
+
+func TestCdrcTruncatedArchive(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	var err error
+	if cdrcConfig.CdrInDir, err = ioutil.TempDir("", "cdrc_in"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdrcConfig.CdrInDir)
+	if cdrcConfig.CdrOutDir, err = ioutil.TempDir("", "cdrc_out"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cdrcConfig.CdrOutDir)
+	cdrs := new(cdrsCollector)
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC", cdrs: cdrs,
+		maxOpenFiles: make(chan struct{})}
+	var gzContent bytes.Buffer
+	gzWriter := gzip.NewWriter(&gzContent)
+	for i := 1; i <= 100; i++ {
+		gzWriter.Write([]byte(csvCdrRow("gzacc" + strconv.Itoa(i))))
+	}
+	gzWriter.Close()
+	filePath := path.Join(cdrcConfig.CdrInDir, "cdrs1.csv.gz")
+	ioutil.WriteFile(filePath, gzContent.Bytes()[:gzContent.Len()/2], 0644) // Cut in the middle of the compressed records
+	errChan := make(chan error, 1)
+	go func() { errChan <- cdrc.processFile(filePath) }()
+	select {
+	case err := <-errChan:
+		if _, isCorrupt := err.(*CorruptFileError); !isCorrupt {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Processing of the truncated archive does not end")
+	}
+	if _, err := os.Stat(path.Join(cdrcConfig.CdrOutDir, "cdrs1.csv.gz")); err == nil {
+		t.Error("Truncated archive moved to out dir")
+	}
+}
@@ -0,0 +1,435 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	BER_CLASS_CONTEXT = 2
+	BER_MAX_LENGTH    = 1 << 24 // Protects against allocating memory out of corrupted length octets
+	BER_RECORD_TYPE   = "*record_type"
+	BER_DECODING_SEP  = "#"
+	// Decodings of the primitive values
+	BER_STRING      = "string"      // Content as it is, eg: IA5String
+	BER_HEX         = "hex"         // Hex representation of the content
+	BER_INTEGER     = "integer"     // Two's complement integer
+	BER_INTEGER_SUM = "integer_sum" // Sum of the integers found on all matching paths, eg: data volumes out of all containers
+	BER_TBCD        = "tbcd"        // Telephony BCD, eg: IMSI
+	BER_ADDRESS     = "address"     // TON/NPI octet followed by TBCD digits, eg: MSISDN, CallingNumber
+	BER_TIMESTAMP   = "timestamp"   // 3GPP TS 32.298 TimeStamp: BCD YYMMDDhhmmss followed by sign and BCD hhmm offset to UTC
+)
+
+// Field of the record out of one or more tag paths, tag numbers separated by utils.HIERARCHY_SEP
+type berSchemaField struct {
+	paths    []string
+	decoding string
+}
+
+// Names of the fields within one type of record, so they can be referenced out of ContentFields instead of the tag paths
+type berSchema struct {
+	name   string
+	fields map[string]*berSchemaField
+}
+
+// Records out of 3GPP TS 32.298 CallEventRecord, indexed on their CHOICE tag
+var berSchemas = map[int]*berSchema{
+	0: &berSchema{name: "moCallRecord", fields: map[string]*berSchemaField{
+		"recordType":       &berSchemaField{paths: []string{"0"}, decoding: BER_INTEGER},
+		"servedIMSI":       &berSchemaField{paths: []string{"1"}, decoding: BER_TBCD},
+		"servedIMEI":       &berSchemaField{paths: []string{"2"}, decoding: BER_TBCD},
+		"servedMSISDN":     &berSchemaField{paths: []string{"3"}, decoding: BER_ADDRESS},
+		"callingNumber":    &berSchemaField{paths: []string{"4"}, decoding: BER_ADDRESS},
+		"calledNumber":     &berSchemaField{paths: []string{"5"}, decoding: BER_ADDRESS},
+		"translatedNumber": &berSchemaField{paths: []string{"6"}, decoding: BER_ADDRESS},
+		"connectedNumber":  &berSchemaField{paths: []string{"7"}, decoding: BER_ADDRESS},
+		"roamingNumber":    &berSchemaField{paths: []string{"8"}, decoding: BER_ADDRESS},
+		"recordingEntity":  &berSchemaField{paths: []string{"9"}, decoding: BER_ADDRESS},
+		"seizureTime":      &berSchemaField{paths: []string{"22"}, decoding: BER_TIMESTAMP},
+		"answerTime":       &berSchemaField{paths: []string{"23"}, decoding: BER_TIMESTAMP},
+		"releaseTime":      &berSchemaField{paths: []string{"24"}, decoding: BER_TIMESTAMP},
+		"callDuration":     &berSchemaField{paths: []string{"25"}, decoding: BER_INTEGER},
+		"causeForTerm":     &berSchemaField{paths: []string{"30"}, decoding: BER_INTEGER},
+		"callReference":    &berSchemaField{paths: []string{"32"}, decoding: BER_HEX},
+		"sequenceNumber":   &berSchemaField{paths: []string{"33"}, decoding: BER_INTEGER},
+	}},
+	1: &berSchema{name: "mtCallRecord", fields: map[string]*berSchemaField{
+		"recordType":      &berSchemaField{paths: []string{"0"}, decoding: BER_INTEGER},
+		"servedIMSI":      &berSchemaField{paths: []string{"1"}, decoding: BER_TBCD},
+		"servedIMEI":      &berSchemaField{paths: []string{"2"}, decoding: BER_TBCD},
+		"servedMSISDN":    &berSchemaField{paths: []string{"3"}, decoding: BER_ADDRESS},
+		"callingNumber":   &berSchemaField{paths: []string{"4"}, decoding: BER_ADDRESS},
+		"connectedNumber": &berSchemaField{paths: []string{"5"}, decoding: BER_ADDRESS},
+		"recordingEntity": &berSchemaField{paths: []string{"6"}, decoding: BER_ADDRESS},
+		"seizureTime":     &berSchemaField{paths: []string{"19"}, decoding: BER_TIMESTAMP},
+		"answerTime":      &berSchemaField{paths: []string{"20"}, decoding: BER_TIMESTAMP},
+		"releaseTime":     &berSchemaField{paths: []string{"21"}, decoding: BER_TIMESTAMP},
+		"callDuration":    &berSchemaField{paths: []string{"22"}, decoding: BER_INTEGER},
+		"causeForTerm":    &berSchemaField{paths: []string{"27"}, decoding: BER_INTEGER},
+		"callReference":   &berSchemaField{paths: []string{"29"}, decoding: BER_HEX},
+		"sequenceNumber":  &berSchemaField{paths: []string{"30"}, decoding: BER_INTEGER},
+	}},
+	79: &berSchema{name: "pGWRecord", fields: map[string]*berSchemaField{
+		"recordType":              &berSchemaField{paths: []string{"0"}, decoding: BER_INTEGER},
+		"servedIMSI":              &berSchemaField{paths: []string{"3"}, decoding: BER_TBCD},
+		"chargingID":              &berSchemaField{paths: []string{"5"}, decoding: BER_INTEGER},
+		"accessPointNameNI":       &berSchemaField{paths: []string{"7"}, decoding: BER_STRING},
+		"recordOpeningTime":       &berSchemaField{paths: []string{"13"}, decoding: BER_TIMESTAMP},
+		"duration":                &berSchemaField{paths: []string{"14"}, decoding: BER_INTEGER},
+		"causeForRecClosing":      &berSchemaField{paths: []string{"15"}, decoding: BER_INTEGER},
+		"recordSequenceNumber":    &berSchemaField{paths: []string{"17"}, decoding: BER_INTEGER},
+		"nodeID":                  &berSchemaField{paths: []string{"18"}, decoding: BER_STRING},
+		"localSequenceNumber":     &berSchemaField{paths: []string{"20"}, decoding: BER_INTEGER},
+		"servedMSISDN":            &berSchemaField{paths: []string{"22"}, decoding: BER_ADDRESS},
+		"chargingCharacteristics": &berSchemaField{paths: []string{"23"}, decoding: BER_HEX},
+		"servedIMEISV":            &berSchemaField{paths: []string{"29"}, decoding: BER_TBCD},
+		"rATType":                 &berSchemaField{paths: []string{"30"}, decoding: BER_INTEGER},
+		// Volumes summed over all the ChangeOfCharCondition containers in listOfTrafficVolumes
+		"dataVolumeUplink":   &berSchemaField{paths: []string{"12>16>3"}, decoding: BER_INTEGER_SUM},
+		"dataVolumeDownlink": &berSchemaField{paths: []string{"12>16>4"}, decoding: BER_INTEGER_SUM},
+		"dataVolume":         &berSchemaField{paths: []string{"12>16>3", "12>16>4"}, decoding: BER_INTEGER_SUM},
+	}},
+}
+
+// One TLV out of the BER encoded file
+type berElement struct {
+	class       int
+	constructed bool
+	tag         int
+	content     []byte        // Populated for primitive elements
+	children    []*berElement // Populated for constructed elements
+}
+
+// Reads one element out of rdr, io.EOF is only returned if no byte of the element could be read
+func readBerElement(rdr *bufio.Reader) (*berElement, error) {
+	idOctet, err := rdr.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	elm := &berElement{class: int(idOctet >> 6), constructed: idOctet&0x20 != 0, tag: int(idOctet & 0x1f)}
+	if elm.tag == 0x1f { // High tag number form
+		elm.tag = 0
+		for i := 0; ; i++ {
+			if i == 4 {
+				return nil, errors.New("Tag number too big")
+			}
+			tagOctet, err := rdr.ReadByte()
+			if err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			elm.tag = elm.tag<<7 | int(tagOctet&0x7f)
+			if tagOctet&0x80 == 0 {
+				break
+			}
+		}
+	}
+	lenOctet, err := rdr.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if lenOctet == 0x80 { // Indefinite length, children follow till end-of-contents
+		if !elm.constructed {
+			return nil, errors.New("Indefinite length on primitive element")
+		}
+		for {
+			if eoc, err := rdr.Peek(2); err != nil {
+				return nil, io.ErrUnexpectedEOF
+			} else if eoc[0] == 0x00 && eoc[1] == 0x00 {
+				rdr.Discard(2)
+				return elm, nil
+			}
+			chld, err := readBerElement(rdr)
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			elm.children = append(elm.children, chld)
+		}
+	}
+	length := int(lenOctet)
+	if lenOctet&0x80 != 0 { // Long form
+		length = 0
+		lenOctets := int(lenOctet & 0x7f)
+		if lenOctets > 4 {
+			return nil, fmt.Errorf("Length on %d octets not supported", lenOctets)
+		}
+		for i := 0; i < lenOctets; i++ {
+			octet, err := rdr.ReadByte()
+			if err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			length = length<<8 | int(octet)
+		}
+	}
+	if length > BER_MAX_LENGTH {
+		return nil, fmt.Errorf("Element length %d over the maximum accepted", length)
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(rdr, content); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if !elm.constructed {
+		elm.content = content
+		return elm, nil
+	}
+	chldRdr := bufio.NewReader(bytes.NewReader(content))
+	for {
+		chld, err := readBerElement(chldRdr)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		elm.children = append(elm.children, chld)
+	}
+	return elm, nil
+}
+
+// Returns the elements found at the tag path, on each level all the children with matching tag number are followed
+func (self *berElement) elementsAt(tagPath string) ([]*berElement, error) {
+	elms := []*berElement{self}
+	for _, tagStr := range strings.Split(tagPath, utils.HIERARCHY_SEP) {
+		tag, err := strconv.Atoi(tagStr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tag: %s in path: %s", tagStr, tagPath)
+		}
+		var found []*berElement
+		for _, elm := range elms {
+			for _, chld := range elm.children {
+				if chld.tag == tag {
+					found = append(found, chld)
+				}
+			}
+		}
+		elms = found
+	}
+	return elms, nil
+}
+
+// Decodes the two's complement integer out of the content
+func berInteger(content []byte) int64 {
+	var val int64
+	for idx, octet := range content {
+		if idx == 0 && octet&0x80 != 0 {
+			val = -1
+		}
+		val = val<<8 | int64(octet)
+	}
+	return val
+}
+
+// Decodes telephony BCD, digits in the low nibble first, 0xf as filler
+func berTbcd(content []byte) string {
+	tbcdDigits := "0123456789*#abc"
+	var digits []byte
+	for _, octet := range content {
+		for _, nibble := range []byte{octet & 0x0f, octet >> 4} {
+			if nibble == 0x0f {
+				break
+			}
+			digits = append(digits, tbcdDigits[nibble])
+		}
+	}
+	return string(digits)
+}
+
+// Decodes the compact TimeStamp into RFC3339 format
+func berTimestamp(content []byte) (string, error) {
+	if len(content) != 9 || (content[6] != '+' && content[6] != '-') {
+		return "", fmt.Errorf("Invalid TimeStamp: %x", content)
+	}
+	return fmt.Sprintf("20%02x-%02x-%02xT%02x:%02x:%02x%c%02x:%02x",
+		content[0], content[1], content[2], content[3], content[4], content[5], content[6], content[7], content[8]), nil
+}
+
+// Decodes the value of the elements according to decoding
+func berDecode(elms []*berElement, decoding string) (string, error) {
+	if decoding == BER_INTEGER_SUM {
+		var sum int64
+		for _, elm := range elms {
+			sum += berInteger(elm.content)
+		}
+		return strconv.FormatInt(sum, 10), nil
+	}
+	content := elms[0].content
+	switch decoding {
+	case BER_STRING, "":
+		return string(content), nil
+	case BER_HEX:
+		return hex.EncodeToString(content), nil
+	case BER_INTEGER:
+		return strconv.FormatInt(berInteger(content), 10), nil
+	case BER_TBCD:
+		return berTbcd(content), nil
+	case BER_ADDRESS:
+		if len(content) == 0 {
+			return "", nil
+		}
+		return berTbcd(content[1:]), nil
+	case BER_TIMESTAMP:
+		return berTimestamp(content)
+	}
+	return "", fmt.Errorf("Unsupported decoding: %s", decoding)
+}
+
+func NewBerRecordsProcessor(rdr io.Reader, dfltCfg *config.CdrcConfig, cdrcCfgs map[string]*config.CdrcConfig, httpClient *http.Client, httpSkipTlsCheck bool, timezone string) *BerRecordsProcessor {
+	return &BerRecordsProcessor{reader: bufio.NewReader(rdr), dfltCfg: dfltCfg, cdrcCfgs: cdrcCfgs, httpClient: httpClient,
+		httpSkipTlsCheck: httpSkipTlsCheck, timezone: timezone}
+}
+
+// Processes files with concatenated BER encoded records, eg: 3GPP TS 32.298 CallEventRecords.
+// Field values are either names out of the schema of the record (eg: servedMSISDN) or tag paths with optional decoding (eg: 3>1#tbcd).
+type BerRecordsProcessor struct {
+	reader             *bufio.Reader
+	dfltCfg            *config.CdrcConfig
+	cdrcCfgs           map[string]*config.CdrcConfig
+	httpClient         *http.Client
+	httpSkipTlsCheck   bool
+	timezone           string
+	processedRecordsNr int64
+}
+
+func (self *BerRecordsProcessor) ProcessedRecordsNr() int64 {
+	return self.processedRecordsNr
+}
+
+// Wraps one decoded record together with its schema
+type berRecord struct {
+	elm    *berElement
+	schema *berSchema // nil if the record is not known, only tag paths can be used then
+}
+
+// Returns the value of the field, hasIt signals if it was found in the record
+func (self *berRecord) valueAt(fldId string) (val string, hasIt bool, err error) {
+	if fldId == BER_RECORD_TYPE {
+		if self.schema == nil {
+			return "", false, nil
+		}
+		return self.schema.name, true, nil
+	}
+	schemaFld, inSchema := (*berSchemaField)(nil), false
+	if self.schema != nil {
+		schemaFld, inSchema = self.schema.fields[fldId]
+	}
+	if !inSchema { // Tag path with optional decoding
+		schemaFld = &berSchemaField{paths: []string{fldId}}
+		if sepIdx := strings.Index(fldId, BER_DECODING_SEP); sepIdx != -1 {
+			schemaFld = &berSchemaField{paths: []string{fldId[:sepIdx]}, decoding: fldId[sepIdx+1:]}
+		}
+	}
+	var elms []*berElement
+	for _, tagPath := range schemaFld.paths {
+		pathElms, err := self.elm.elementsAt(tagPath)
+		if err != nil {
+			return "", false, err
+		}
+		elms = append(elms, pathElms...)
+	}
+	if len(elms) == 0 {
+		return "", false, nil
+	}
+	val, err = berDecode(elms, schemaFld.decoding)
+	return val, err == nil, err
+}
+
+func (self *BerRecordsProcessor) ProcessNextRecord() ([]*engine.StoredCdr, error) {
+	for { // Skip the filling between records
+		if octet, err := self.reader.Peek(1); err == io.EOF {
+			return nil, err
+		} else if err != nil {
+			return nil, NewCorruptFileError(self.processedRecordsNr+1, err)
+		} else if octet[0] != 0x00 && octet[0] != 0xff {
+			break
+		}
+		self.reader.Discard(1)
+	}
+	elm, err := readBerElement(self.reader)
+	if err != nil { // Corrupted or truncated, the start of the next record cannot be found
+		return nil, NewCorruptFileError(self.processedRecordsNr+1, err)
+	}
+	self.processedRecordsNr += 1
+	record := &berRecord{elm: elm}
+	if elm.class == BER_CLASS_CONTEXT && elm.constructed {
+		if schema, hasIt := berSchemas[elm.tag]; hasIt {
+			record.schema = schema
+		}
+	}
+	recordCdrs := make([]*engine.StoredCdr, 0) // More CDRs based on the number of filters and field templates
+	for cfgKey, cdrcCfg := range self.cdrcCfgs {
+		if passes, err := self.recordPassesCfgFilter(record, cfgKey); err != nil {
+			return nil, err
+		} else if !passes {
+			continue
+		}
+		if storedCdr, err := self.recordToStoredCdr(record, cfgKey); err != nil {
+			return nil, fmt.Errorf("Failed converting to StoredCdr, error: %s", err.Error())
+		} else {
+			recordCdrs = append(recordCdrs, storedCdr)
+		}
+		if !cdrcCfg.ContinueOnSuccess { // Successfully executed one config, do not continue for next one
+			break
+		}
+	}
+	return recordCdrs, nil
+}
+
+func (self *BerRecordsProcessor) recordPassesCfgFilter(record *berRecord, cfgKey string) (bool, error) {
+	for _, rsrFilter := range self.cdrcCfgs[cfgKey].CdrFilter {
+		if rsrFilter == nil { // Nil filter does not need to match anything
+			continue
+		}
+		if fldVal, hasIt, err := record.valueAt(rsrFilter.Id); err != nil {
+			return false, err
+		} else if !hasIt || !rsrFilter.FilterPasses(fldVal) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (self *BerRecordsProcessor) recordToStoredCdr(record *berRecord, cfgKey string) (*engine.StoredCdr, error) {
+	storedCdr := &engine.StoredCdr{CdrHost: "0.0.0.0", CdrSource: self.cdrcCfgs[cfgKey].CdrSourceId, ExtraFields: make(map[string]string), Cost: -1}
+	valueAt := func(cdrFldCfg *config.CfgCdrField, fldId string) (string, bool, error) {
+		berVal, hasIt, err := record.valueAt(fldId)
+		if err != nil {
+			return "", false, fmt.Errorf("Field %s, error: %s", cdrFldCfg.Tag, err.Error())
+		}
+		return berVal, hasIt, nil
+	}
+	if err := templateToStoredCdr(storedCdr, self.cdrcCfgs[cfgKey].ContentFields, valueAt, self.processedRecordsNr, true,
+		self.cdrcCfgs[cfgKey].DataUsageMultiplyFactor, self.timezone, self.httpSkipTlsCheck); err != nil {
+		return nil, err
+	}
+	return storedCdr, nil
+}
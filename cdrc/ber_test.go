@@ -0,0 +1,290 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Encodes one context specific TLV with definite length, constructed if built out of children
+func berCtx(tag int, content []byte, children ...[]byte) []byte {
+	idOctet := byte(BER_CLASS_CONTEXT << 6)
+	if children != nil {
+		idOctet |= 0x20
+		content = bytes.Join(children, nil)
+	}
+	var tlv []byte
+	if tag < 0x1f {
+		tlv = []byte{idOctet | byte(tag)}
+	} else {
+		tlv = []byte{idOctet | 0x1f}
+		if tag > 0x7f {
+			tlv = append(tlv, 0x80|byte(tag>>7))
+		}
+		tlv = append(tlv, byte(tag&0x7f))
+	}
+	if len(content) < 0x80 {
+		tlv = append(tlv, byte(len(content)))
+	} else {
+		tlv = append(tlv, 0x82, byte(len(content)>>8), byte(len(content)))
+	}
+	return append(tlv, content...)
+}
+
+// Sample MO call answered
+var berMoCall = berCtx(0, nil,
+	berCtx(0, []byte{0x01}),
+	berCtx(1, []byte{0x62, 0x02, 0x10, 0x32, 0x54, 0x76, 0x98, 0xf0}), // IMSI 262001234567890
+	berCtx(3, []byte{0x91, 0x94, 0x71, 0x03, 0x00, 0x10, 0xf1}),       // MSISDN 49173000011
+	berCtx(5, []byte{0x81, 0x00, 0x01, 0x32}),                         // 001023
+	berCtx(22, []byte{0x16, 0x01, 0x13, 0x09, 0x01, 0x51, '+', 0x01, 0x00}),
+	berCtx(23, []byte{0x16, 0x01, 0x13, 0x09, 0x01, 0x55, '+', 0x01, 0x00}),
+	berCtx(25, []byte{0x01, 0x2c}), // 300 seconds
+	berCtx(30, []byte{0x00}),
+	berCtx(32, []byte{0x0a, 0x0b, 0x0c, 0x0d}),
+)
+
+// Sample PGW record with two traffic containers, indefinite length encoding for the outer record
+var berPgwRecord = append(append([]byte{0xbf, 0x4f, 0x80}, bytes.Join([][]byte{
+	berCtx(0, []byte{0x55}),
+	berCtx(3, []byte{0x62, 0x02, 0x10, 0x32, 0x54, 0x76, 0x98, 0xf0}),
+	berCtx(5, []byte{0x00, 0x9f, 0x86}), // Charging id 40838
+	berCtx(7, []byte("internet")),
+	berCtx(12, nil,
+		append([]byte{0x30, 0x08}, append(berCtx(3, []byte{0x03, 0xe8}), berCtx(4, []byte{0x07, 0xd0})...)...),
+		append([]byte{0x30, 0x07}, append(berCtx(3, []byte{0x01, 0xf4}), berCtx(4, []byte{0x64})...)...)),
+	berCtx(13, []byte{0x16, 0x01, 0x13, 0x10, 0x00, 0x00, '-', 0x00, 0x00}),
+	berCtx(14, []byte{0x3c}),
+	berCtx(22, []byte{0x91, 0x94, 0x71, 0x03, 0x00, 0x10, 0xf1}),
+	berCtx(30, []byte{0x06}),
+}, nil)...), 0x00, 0x00)
+
+func TestBerReadElement(t *testing.T) {
+	elm, err := readBerElement(bufio.NewReader(bytes.NewReader(berPgwRecord)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elm.class != BER_CLASS_CONTEXT || !elm.constructed || elm.tag != 79 || len(elm.children) != 9 {
+		t.Errorf("Unexpected element: %+v", elm)
+	}
+	if vols, err := elm.elementsAt("12>16>4"); err != nil || len(vols) != 2 {
+		t.Errorf("Unexpected volumes: %+v, err: %v", vols, err)
+	}
+	if _, err := readBerElement(bufio.NewReader(bytes.NewReader(berMoCall[:10]))); err != io.ErrUnexpectedEOF {
+		t.Error("Unexpected error: ", err)
+	}
+	if val := berInteger([]byte{0xff, 0x38}); val != -200 {
+		t.Error("Unexpected integer: ", val)
+	}
+	if val := berTbcd([]byte{0x21, 0x43, 0xf5}); val != "12345" {
+		t.Error("Unexpected TBCD: ", val)
+	}
+}
+
+func TestBerRecordsProcessor(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	voiceCfg := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	voiceCfg.CdrFormat = utils.ASN1_BER
+	voiceCfg.CdrSourceId = "MSC"
+	voiceCfg.CdrFilter = utils.ParseRSRFieldsMustCompile("*record_type(moCallRecord)", utils.INFIELD_SEP)
+	voiceCfg.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR, Value: utils.ParseRSRFieldsMustCompile("^*voice", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("callReference", utils.INFIELD_SEP), Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT, Value: utils.ParseRSRFieldsMustCompile("servedMSISDN", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Subject", Type: utils.META_COMPOSED, FieldId: utils.SUBJECT, Value: utils.ParseRSRFieldsMustCompile("1#tbcd", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION, Value: utils.ParseRSRFieldsMustCompile("calledNumber", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME, Value: utils.ParseRSRFieldsMustCompile("seizureTime", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AnswerTime", Type: utils.META_COMPOSED, FieldId: utils.ANSWER_TIME, Value: utils.ParseRSRFieldsMustCompile("answerTime", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE, Value: utils.ParseRSRFieldsMustCompile("callDuration", utils.INFIELD_SEP)},
+	}
+	dataCfg := voiceCfg.Clone()
+	dataCfg.CdrSourceId = "PGW"
+	dataCfg.DataUsageMultiplyFactor = 1
+	dataCfg.CdrFilter = utils.ParseRSRFieldsMustCompile("*record_type(pGWRecord)", utils.INFIELD_SEP)
+	dataCfg.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR, Value: utils.ParseRSRFieldsMustCompile("^*data", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("chargingID", utils.INFIELD_SEP), Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT, Value: utils.ParseRSRFieldsMustCompile("servedMSISDN", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION, Value: utils.ParseRSRFieldsMustCompile("accessPointNameNI", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME, Value: utils.ParseRSRFieldsMustCompile("recordOpeningTime", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE, Value: utils.ParseRSRFieldsMustCompile("dataVolume", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Uplink", Type: utils.META_COMPOSED, FieldId: "Uplink", Value: utils.ParseRSRFieldsMustCompile("dataVolumeUplink", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "RatType", Type: utils.META_COMPOSED, FieldId: "RatType", Value: utils.ParseRSRFieldsMustCompile("30#integer", utils.INFIELD_SEP)},
+	}
+	// Records padded with filler octets as written by some of the network elements
+	berFile := bytes.Join([][]byte{berMoCall, {0xff, 0xff}, berPgwRecord, {0x00, 0x00, 0x00}}, nil)
+	berProcessor := NewBerRecordsProcessor(bytes.NewReader(berFile), voiceCfg, map[string]*config.CdrcConfig{"VOICE": voiceCfg, "DATA": dataCfg}, nil, false, "UTC")
+	var cdrs []*engine.StoredCdr
+	for {
+		recCdrs, err := berProcessor.ProcessNextRecord()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		cdrs = append(cdrs, recCdrs...)
+	}
+	if berProcessor.ProcessedRecordsNr() != 2 || len(cdrs) != 2 {
+		t.Fatalf("Processed records: %d, CDRs: %+v", berProcessor.ProcessedRecordsNr(), cdrs)
+	}
+	cet := time.FixedZone("", 3600)
+	if cdrs[0].AccId != "0a0b0c0d" || cdrs[0].CdrSource != "MSC" || cdrs[0].Account != "49173000011" || cdrs[0].Subject != "262001234567890" ||
+		cdrs[0].Destination != "001023" || !cdrs[0].SetupTime.Equal(time.Date(2016, 1, 13, 9, 1, 51, 0, cet)) ||
+		!cdrs[0].AnswerTime.Equal(time.Date(2016, 1, 13, 9, 1, 55, 0, cet)) || cdrs[0].Usage != time.Duration(300)*time.Second {
+		t.Errorf("Unexpected voice CDR: %+v", cdrs[0])
+	}
+	if cdrs[1].AccId != "40838" || cdrs[1].CdrSource != "PGW" || cdrs[1].TOR != utils.DATA || cdrs[1].Account != "49173000011" ||
+		cdrs[1].Destination != "internet" || !cdrs[1].SetupTime.Equal(time.Date(2016, 1, 13, 10, 0, 0, 0, time.UTC)) ||
+		cdrs[1].Usage != time.Duration(3600)*time.Second || cdrs[1].ExtraFields["Uplink"] != "1500" || cdrs[1].ExtraFields["RatType"] != "6" {
+		t.Errorf("Unexpected data CDR: %+v", cdrs[1])
+	}
+}
+
+// Reads all the CDRs out of the BER file content, stopping at the first error other than the record ones
+func berFileCdrs(content []byte, cdrcCfgs map[string]*config.CdrcConfig) (cdrs []*engine.StoredCdr, recordsNr int64, err error) {
+	berProcessor := NewBerRecordsProcessor(bytes.NewReader(content), cdrcCfgs["VOICE"], cdrcCfgs, nil, false, "UTC")
+	for {
+		recCdrs, err := berProcessor.ProcessNextRecord()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return cdrs, berProcessor.ProcessedRecordsNr(), err
+		}
+		cdrs = append(cdrs, recCdrs...)
+	}
+}
+
+// Files in testdata hold records encoded according to 3GPP TS 32.298, including the fields not present in the schemas
+func TestBerSampleFiles(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	voiceCfg := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	voiceCfg.CdrFormat = utils.ASN1_BER
+	voiceCfg.CdrFilter = utils.ParseRSRFieldsMustCompile("*record_type(moCallRecord)", utils.INFIELD_SEP)
+	voiceCfg.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR, Value: utils.ParseRSRFieldsMustCompile("^*voice", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("callReference", utils.INFIELD_SEP), Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT, Value: utils.ParseRSRFieldsMustCompile("servedMSISDN", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION, Value: utils.ParseRSRFieldsMustCompile("calledNumber", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME, Value: utils.ParseRSRFieldsMustCompile("seizureTime", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE, Value: utils.ParseRSRFieldsMustCompile("callDuration", utils.INFIELD_SEP)},
+	}
+	dataCfg := voiceCfg.Clone()
+	dataCfg.DataUsageMultiplyFactor = 1
+	dataCfg.CdrFilter = utils.ParseRSRFieldsMustCompile("*record_type(pGWRecord)", utils.INFIELD_SEP)
+	dataCfg.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR, Value: utils.ParseRSRFieldsMustCompile("^*data", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("chargingID", utils.INFIELD_SEP), Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT, Value: utils.ParseRSRFieldsMustCompile("servedMSISDN", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION, Value: utils.ParseRSRFieldsMustCompile("accessPointNameNI", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME, Value: utils.ParseRSRFieldsMustCompile("recordOpeningTime", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE, Value: utils.ParseRSRFieldsMustCompile("dataVolume", utils.INFIELD_SEP)},
+	}
+	cdrcCfgs := map[string]*config.CdrcConfig{"VOICE": voiceCfg, "DATA": dataCfg}
+	mscFile, err := ioutil.ReadFile(path.Join("testdata", "msc_32298.ber"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdrs, recordsNr, err := berFileCdrs(mscFile, cdrcCfgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recordsNr != 3 || len(cdrs) != 2 { // The MT call is not matched by any of the configs
+		t.Fatalf("Records: %d, CDRs: %s", recordsNr, utils.ToJSON(cdrs))
+	}
+	if cdrs[0].AccId != "0102030405060708" || cdrs[0].Account != "491710000001" || cdrs[0].Destination != "4930123456" ||
+		!cdrs[0].SetupTime.Equal(time.Date(2016, 1, 13, 8, 1, 51, 0, time.UTC)) || cdrs[0].Usage != time.Duration(780)*time.Second {
+		t.Errorf("Unexpected CDR: %+v", cdrs[0])
+	}
+	if cdrs[1].AccId != "010203040506070a" || cdrs[1].Destination != "4989123456" || cdrs[1].Usage != 0 {
+		t.Errorf("Unexpected CDR: %+v", cdrs[1])
+	}
+	pgwFile, err := ioutil.ReadFile(path.Join("testdata", "pgw_32298.ber"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cdrs, recordsNr, err = berFileCdrs(pgwFile, cdrcCfgs); err != nil {
+		t.Fatal(err)
+	}
+	if recordsNr != 2 || len(cdrs) != 2 {
+		t.Fatalf("Records: %d, CDRs: %s", recordsNr, utils.ToJSON(cdrs))
+	}
+	if cdrs[0].AccId != "40838" || cdrs[0].Destination != "internet" || cdrs[0].Usage != time.Duration(3600)*time.Second ||
+		cdrs[1].AccId != "40839" || cdrs[1].Account != "491710000002" || cdrs[1].Usage != time.Duration(170000)*time.Second {
+		t.Errorf("Unexpected CDRs: %s", utils.ToJSON(cdrs))
+	}
+	// Truncated in the middle of the second record
+	cdrs, recordsNr, err = berFileCdrs(pgwFile[:len(pgwFile)-20], cdrcCfgs)
+	if corruptErr, isCorrupt := err.(*CorruptFileError); !isCorrupt || corruptErr.RecordIdx != 2 || corruptErr.Err != io.ErrUnexpectedEOF {
+		t.Errorf("Unexpected error: %v", err)
+	} else if recordsNr != 1 || len(cdrs) != 1 {
+		t.Errorf("Records: %d, CDRs: %s", recordsNr, utils.ToJSON(cdrs))
+	}
+	// Length octets of the first record corrupted
+	corrupted := append([]byte{}, mscFile...)
+	corrupted[1], corrupted[2] = 0x84, 0xff
+	if _, _, err = berFileCdrs(corrupted, cdrcCfgs); err == nil {
+		t.Error("Corrupted file processed")
+	} else if _, isCorrupt := err.(*CorruptFileError); !isCorrupt {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestBerCorruptFileQuarantined(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.CdrFormat = utils.ASN1_BER
+	cdrcConfig.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("chargingID", utils.INFIELD_SEP), Mandatory: true}}
+	for _, dir := range []*string{&cdrcConfig.CdrInDir, &cdrcConfig.CdrOutDir, &cdrcConfig.QuarantineDir} {
+		var err error
+		if *dir, err = ioutil.TempDir("", "cdrc_ber"); err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(*dir)
+	}
+	pgwFile, err := ioutil.ReadFile(path.Join("testdata", "pgw_32298.ber"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := path.Join(cdrcConfig.CdrInDir, "pgw_truncated.ber")
+	ioutil.WriteFile(filePath, pgwFile[:len(pgwFile)-20], 0644)
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC", cdrs: new(cdrsCollector),
+		maxOpenFiles: make(chan struct{})}
+	if err := cdrc.processFile(filePath); err == nil {
+		t.Error("Truncated file processed")
+	}
+	if _, err := os.Stat(path.Join(cdrcConfig.QuarantineDir, "pgw_truncated.ber")); err != nil {
+		t.Error("File not quarantined: ", err)
+	}
+	if files, _ := ioutil.ReadDir(cdrcConfig.CdrOutDir); len(files) != 0 {
+		t.Errorf("Files in out dir: %+v", files)
+	}
+}
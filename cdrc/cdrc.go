@@ -21,6 +21,8 @@ package cdrc
 import (
 	"bufio"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -39,6 +41,38 @@ const (
 	CSV             = "csv"
 	FS_CSV          = "freeswitch_csv"
 	UNPAIRED_SUFFIX = ".unpaired"
+	MAX_RETRY_DELAY = time.Minute // Upper limit when doubling the pause between posting retries
+)
+
+// Returned when the cdrc is closed while posting CDRs, the file is left in place to be processed again
+var ErrCdrcClosed = errors.New("CDRC_CLOSED")
+
+// Returned by the records processors when the rest of the file cannot be decoded, failing the processing of the file
+type CorruptFileError struct {
+	RecordIdx int64 // Record which could not be decoded
+	Err       error
+}
+
+func NewCorruptFileError(recordIdx int64, err error) *CorruptFileError {
+	return &CorruptFileError{RecordIdx: recordIdx, Err: err}
+}
+
+func (self *CorruptFileError) Error() string {
+	return fmt.Sprintf("Corrupt file at record %d: %s", self.RecordIdx, self.Err.Error())
+}
+
+// Errors of the reader under a records processor, like the ones of a truncated archive, are not the fault of one record and fail the file
+func readerError(recordIdx int64, err error) error {
+	if err == io.EOF || err == ErrArchiveTooLarge {
+		return err
+	}
+	return NewCorruptFileError(recordIdx, err)
+}
+
+var (
+	cdrcFiles        = utils.Metrics.Counter("cgr_cdrc_files_total", "Files processed by CDRC, per input folder and status.", "cdr_in_dir", "status")
+	cdrcRows         = utils.Metrics.Counter("cgr_cdrc_rows_total", "Records read by CDRC, per input folder.", "cdr_in_dir")
+	cdrcRowsRejected = utils.Metrics.Counter("cgr_cdrc_rows_rejected_total", "Records or CDRs rejected by CDRC, per input folder.", "cdr_in_dir")
 )
 
 // Understands and processes a specific format of cdr (eg: .csv or .fwv)
@@ -60,22 +94,34 @@ func NewCdrc(cdrcCfgs map[string]*config.CdrcConfig, httpSkipTlsCheck bool, cdrs
 		break
 	}
 	cdrc := &Cdrc{httpSkipTlsCheck: httpSkipTlsCheck, cdrcCfgs: cdrcCfgs, dfltCdrcCfg: cdrcCfg, timezone: utils.FirstNonEmpty(cdrcCfg.Timezone, dfltTimezone), cdrs: cdrs,
-		closeChan: closeChan, maxOpenFiles: make(chan struct{}, cdrcCfg.MaxOpenFiles),
+		closeChan: closeChan, maxOpenFiles: make(chan struct{}, cdrcCfg.MaxOpenFiles), maxPostBatches: make(chan struct{}, cdrcCfg.BatchConcurrency),
 	}
 	var processFile struct{}
 	for i := 0; i < cdrcCfg.MaxOpenFiles; i++ {
 		cdrc.maxOpenFiles <- processFile // Empty initiate so we do not need to wait later when we pop
 	}
+	var postBatch struct{}
+	for i := 0; i < cdrcCfg.BatchConcurrency; i++ {
+		cdrc.maxPostBatches <- postBatch
+	}
 	var err error
 	if cdrc.partialRecordsCache, err = NewPartialRecordsCache(cdrcCfg.PartialRecordCache, cdrcCfg.CdrOutDir, cdrcCfg.FieldSeparator); err != nil {
 		return nil, err
 	}
 	// Before processing, make sure in and out folders exist
-	for _, dir := range []string{cdrcCfg.CdrInDir, cdrcCfg.CdrOutDir} {
+	for _, dir := range []string{cdrcCfg.CdrInDir, cdrcCfg.CdrOutDir, cdrcCfg.JournalDir, cdrcCfg.QuarantineDir} {
+		if len(dir) == 0 { // Optional folders
+			continue
+		}
 		if _, err := os.Stat(dir); err != nil && os.IsNotExist(err) {
 			return nil, fmt.Errorf("Nonexistent folder: %s", dir)
 		}
 	}
+	if len(cdrcCfg.JournalDir) != 0 {
+		if cdrc.journal, err = NewJournal(cdrcCfg.JournalDir, cdrcCfg.CdrInDir, cdrcCfg.JournalRetention); err != nil {
+			return nil, err
+		}
+	}
 	cdrc.httpClient = new(http.Client)
 	return cdrc, nil
 }
@@ -89,11 +135,16 @@ type Cdrc struct {
 	httpClient          *http.Client
 	closeChan           chan struct{}        // Used to signal config reloads when we need to span different CDRC-Client
 	maxOpenFiles        chan struct{}        // Maximum number of simultaneous files processed
+	maxPostBatches      chan struct{}        // Maximum number of batches posted simultaneously to CDRS, shared by all files
 	partialRecordsCache *PartialRecordsCache // Shared between all files in the folder we process
+	journal             *Journal             // Progress of the files processed, nil if disabled
 }
 
 // When called fires up folder monitoring, either automated via inotify or manual by sleeping between processing
 func (self *Cdrc) Run() error {
+	if self.journal != nil {
+		defer self.journal.Close()
+	}
 	if self.dfltCdrcCfg.RunDelay == time.Duration(0) { // Automated via inotify
 		return self.trackCDRFiles()
 	}
@@ -158,19 +209,166 @@ func (self *Cdrc) processCdrDir() error {
 }
 
 // Processe file at filePath and posts the valid cdr rows out of it
-func (self *Cdrc) processFile(filePath string) error {
+func (self *Cdrc) processFile(filePath string) (err error) {
 	if cap(self.maxOpenFiles) != 0 { // 0 goes for no limit
 		processFile := <-self.maxOpenFiles // Queue here for maxOpenFiles
 		defer func() { self.maxOpenFiles <- processFile }()
 	}
+	defer func() {
+		if err != nil {
+			cdrcFiles.Inc(self.dfltCdrcCfg.CdrInDir, "failed")
+		} else {
+			cdrcFiles.Inc(self.dfltCdrcCfg.CdrInDir, "ok")
+		}
+	}()
 	_, fn := path.Split(filePath)
 	utils.Logger.Info(fmt.Sprintf("<Cdrc> Parsing: %s", filePath))
 	file, err := os.Open(filePath)
-	defer file.Close()
 	if err != nil {
 		utils.Logger.Crit(err.Error())
 		return err
 	}
+	defer file.Close()
+	fp := &fileProcessing{fileName: fn}
+	defer fp.close()
+	if self.journal != nil {
+		hash, size, err := fileHash(file)
+		if err != nil {
+			return err
+		}
+		if fp.jrnlEntry = self.journal.Entry(hash); fp.jrnlEntry == nil {
+			fp.jrnlEntry = &JournalEntry{Hash: hash, FileName: fn, Size: size}
+		} else if fp.jrnlEntry.Completed {
+			return self.moveDuplicate(filePath, fp.jrnlEntry)
+		} else {
+			utils.Logger.Info(fmt.Sprintf("<Cdrc> Resuming processing of %s after record %d", fn, fp.jrnlEntry.LastRow))
+		}
+	}
+	compression, err := detectCompression(file)
+	if err != nil {
+		return err
+	}
+	timeStart := time.Now()
+	var recordsNr int64
+	var cdrsPosted int
+	if len(compression) == 0 {
+		if recordsNr, cdrsPosted, err = self.processRecords(file, fn, fp); err != nil {
+			self.quarantineFile(filePath, err)
+			return err
+		}
+	} else { // Process each member in the archive as one CDR file, streamed out of the archive unless the records processor needs to seek into it
+		var tmpDir string
+		defer func() {
+			if len(tmpDir) != 0 {
+				os.RemoveAll(tmpDir)
+			}
+		}()
+		if err := walkArchive(file, fn, compression, self.dfltCdrcCfg.MaxExtractedSize, func(mbrName string, mbrIdx int, mbrRdr io.Reader) error {
+			if self.dfltCdrcCfg.CdrFormat == utils.FWV {
+				if len(tmpDir) == 0 {
+					var err error
+					if tmpDir, err = ioutil.TempDir(self.dfltCdrcCfg.TmpDir, "cdrc_"); err != nil {
+						return err
+					}
+				}
+				mbrPath, err := extractMember(mbrRdr, mbrName, tmpDir, mbrIdx)
+				if err != nil {
+					return err
+				}
+				mbrFile, err := os.Open(mbrPath)
+				if err != nil {
+					return err
+				}
+				defer mbrFile.Close()
+				mbrRdr = mbrFile
+			}
+			mbrRecordsNr, mbrCdrsPosted, err := self.processRecords(mbrRdr, mbrName, fp)
+			if err != nil {
+				return err
+			}
+			utils.Logger.Info(fmt.Sprintf("<Cdrc> Processed %s out of %s. Total records processed: %d, CDRs posted: %d", mbrName, fn, mbrRecordsNr, mbrCdrsPosted))
+			recordsNr += mbrRecordsNr
+			cdrsPosted += mbrCdrsPosted
+			return nil
+		}); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<Cdrc> Cannot decompress %s, error: %s", filePath, err.Error()))
+			self.quarantineFile(filePath, err)
+			return err
+		}
+	}
+	// Finished with file, move it to processed folder
+	newPath := path.Join(self.dfltCdrcCfg.CdrOutDir, fn)
+	if err := os.Rename(filePath, newPath); err != nil {
+		utils.Logger.Err(err.Error())
+		return err
+	}
+	if fp.jrnlEntry != nil { // Completed only once moved, otherwise it would be seen as duplicate on restart
+		fp.jrnlEntry.Completed = true
+		if err := self.journal.Record(fp.jrnlEntry); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<Cdrc> Journal error: %s", err.Error()))
+		}
+	}
+	utils.Logger.Info(fmt.Sprintf("Finished processing %s, moved to %s. Total records processed: %d, CDRs posted: %d, run duration: %s",
+		fn, newPath, recordsNr, cdrsPosted, time.Now().Sub(timeStart)))
+	return nil
+}
+
+// Moves out of the way the files already processed according to the journal, into QuarantineDir if defined or rejected into CdrOutDir
+func (self *Cdrc) moveDuplicate(filePath string, jrnlEntry *JournalEntry) error {
+	_, fn := path.Split(filePath)
+	newPath := path.Join(self.dfltCdrcCfg.CdrOutDir, fn+DUPLICATE_SUFFIX)
+	if len(self.dfltCdrcCfg.QuarantineDir) != 0 {
+		newPath = path.Join(self.dfltCdrcCfg.QuarantineDir, fn)
+	}
+	if err := os.Rename(filePath, newPath); err != nil {
+		utils.Logger.Err(err.Error())
+		return err
+	}
+	utils.Logger.Warning(fmt.Sprintf("<Cdrc> File %s already processed as %s at %s, moved to %s",
+		fn, jrnlEntry.FileName, jrnlEntry.UpdatedAt.Format(time.RFC3339), newPath))
+	return nil
+}
+
+// Moves the file which cannot be processed because of its content into QuarantineDir if defined, otherwise it is left in place
+func (self *Cdrc) quarantineFile(filePath string, errRcv error) {
+	if _, isCorrupt := errRcv.(*CorruptFileError); !isCorrupt && errRcv != ErrArchiveTooLarge {
+		return
+	}
+	utils.Logger.Err(fmt.Sprintf("<Cdrc> Failed processing %s, error: %s", filePath, errRcv.Error()))
+	if len(self.dfltCdrcCfg.QuarantineDir) == 0 {
+		return
+	}
+	_, fn := path.Split(filePath)
+	if err := os.Rename(filePath, path.Join(self.dfltCdrcCfg.QuarantineDir, fn)); err != nil {
+		utils.Logger.Err(err.Error())
+	}
+}
+
+// Writes the failed record into the rejects file and logs it
+func (self *Cdrc) rejectRecord(fp *fileProcessing, recordIdx int64, errRcv error, storedCdr *engine.StoredCdr) {
+	cdrcRowsRejected.Inc(self.dfltCdrcCfg.CdrInDir)
+	if storedCdr == nil {
+		utils.Logger.Err(fmt.Sprintf("<Cdrc> File %s, record %d, error: %s", fp.fileName, recordIdx, errRcv.Error()))
+	} else {
+		utils.Logger.Err(fmt.Sprintf("<Cdrc> Failed sending CDR, %+v, error: %s", storedCdr, errRcv.Error()))
+	}
+	if !self.dfltCdrcCfg.WriteRejects {
+		return
+	}
+	if fp.rejects == nil {
+		var err error
+		if fp.rejects, err = os.OpenFile(path.Join(self.dfltCdrcCfg.CdrOutDir, fp.fileName+REJECTS_SUFFIX), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<Cdrc> Cannot open rejects file, error: %s", err.Error()))
+			return
+		}
+	}
+	if err := json.NewEncoder(fp.rejects).Encode(&rejectedRecord{Row: recordIdx, Error: errRcv.Error(), Cdr: storedCdr}); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<Cdrc> Cannot write rejects file, error: %s", err.Error()))
+	}
+}
+
+// Posts the valid CDRs out of one plain CDR file, returns the number of records processed and CDRs posted
+func (self *Cdrc) processRecords(file io.Reader, fn string, fp *fileProcessing) (int64, int, error) {
 	var recordsProcessor RecordsProcessor
 	switch self.dfltCdrcCfg.CdrFormat {
 	case CSV, FS_CSV, utils.KAM_FLATSTORE, utils.OSIPS_FLATSTORE:
@@ -179,43 +377,187 @@ func (self *Cdrc) processFile(filePath string) error {
 		recordsProcessor = NewCsvRecordsProcessor(csvReader, self.timezone, fn, self.dfltCdrcCfg, self.cdrcCfgs,
 			self.httpSkipTlsCheck, self.partialRecordsCache)
 	case utils.FWV:
-		recordsProcessor = NewFwvRecordsProcessor(file, self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone)
+		fwvFile, isFile := file.(*os.File)
+		if !isFile {
+			return 0, 0, errors.New("Fixed width records need a seekable file")
+		}
+		recordsProcessor = NewFwvRecordsProcessor(fwvFile, self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone)
+	case utils.XML:
+		recordsProcessor = NewXmlRecordsProcessor(bufio.NewReader(file), self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone)
+	case utils.JSON, utils.NDJSON:
+		recordsProcessor = NewJsonRecordsProcessor(file, self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone)
+	case utils.ASN1_BER:
+		recordsProcessor = NewBerRecordsProcessor(file, self.dfltCdrcCfg, self.cdrcCfgs, self.httpClient, self.httpSkipTlsCheck, self.timezone)
 	default:
-		return fmt.Errorf("Unsupported CDR format: %s", self.dfltCdrcCfg.CdrFormat)
+		return 0, 0, fmt.Errorf("Unsupported CDR format: %s", self.dfltCdrcCfg.CdrFormat)
 	}
-	rowNr := 0 // This counts the rows in the file, not really number of CDRs
 	cdrsPosted := 0
-	timeStart := time.Now()
+	var batch []*engine.StoredCdr // CDRs waiting to be posted
+	var batchRecords []int64      // Index of the record each CDR in the batch comes out of
 	for {
 		cdrs, err := recordsProcessor.ProcessNextRecord()
 		if err != nil && err == io.EOF {
 			break
+		} else if _, isCorrupt := err.(*CorruptFileError); isCorrupt || err == ErrArchiveTooLarge { // Not a record error, the rest of the file cannot be read
+			if len(batch) != 0 { // Records read before are valid
+				batchPosted, errPost := self.postCdrs(fp, batch, batchRecords)
+				cdrsPosted += batchPosted
+				if errPost == nil {
+					self.recordProgress(fp)
+				}
+			}
+			return recordsProcessor.ProcessedRecordsNr(), cdrsPosted, err
 		}
-		if err != nil {
-			utils.Logger.Err(fmt.Sprintf("<Cdrc> Row %d, error: %s", rowNr, err.Error()))
+		fp.recordIdx += 1
+		if fp.processedBefore() { // Posted before restart
 			continue
 		}
-		for _, storedCdr := range cdrs { // Send CDRs to CDRS
-			var reply string
-			if self.dfltCdrcCfg.DryRun {
+		cdrcRows.Inc(self.dfltCdrcCfg.CdrInDir)
+		if err != nil {
+			self.rejectRecord(fp, fp.recordIdx, err, nil)
+		} else if self.dfltCdrcCfg.DryRun {
+			for _, storedCdr := range cdrs {
 				utils.Logger.Info(fmt.Sprintf("<Cdrc> DryRun CDR: %+v", storedCdr))
-				continue
 			}
-			if err := self.cdrs.ProcessCdr(storedCdr, &reply); err != nil {
-				utils.Logger.Err(fmt.Sprintf("<Cdrc> Failed sending CDR, %+v, error: %s", storedCdr, err.Error()))
-			} else if reply != "OK" {
-				utils.Logger.Err(fmt.Sprintf("<Cdrc> Received unexpected reply for CDR, %+v, reply: %s", storedCdr, reply))
+		} else {
+			for _, storedCdr := range cdrs {
+				batch = append(batch, storedCdr)
+				batchRecords = append(batchRecords, fp.recordIdx)
+			}
+		}
+		if len(batch) >= self.dfltCdrcCfg.BatchSize {
+			batchPosted, err := self.postCdrs(fp, batch, batchRecords)
+			cdrsPosted += batchPosted
+			if err != nil { // Progress not journaled, processing resumes with the batch on restart
+				return recordsProcessor.ProcessedRecordsNr(), cdrsPosted, err
 			}
-			cdrsPosted += 1
+			batch, batchRecords = nil, nil
+		}
+		if len(batch) == 0 { // Records with CDRs still in the batch are journaled once posted
+			self.recordProgress(fp)
 		}
 	}
-	// Finished with file, move it to processed folder
-	newPath := path.Join(self.dfltCdrcCfg.CdrOutDir, fn)
-	if err := os.Rename(filePath, newPath); err != nil {
-		utils.Logger.Err(err.Error())
-		return err
+	if len(batch) != 0 {
+		batchPosted, err := self.postCdrs(fp, batch, batchRecords)
+		cdrsPosted += batchPosted
+		if err != nil {
+			return recordsProcessor.ProcessedRecordsNr(), cdrsPosted, err
+		}
+		self.recordProgress(fp)
+	}
+	return recordsProcessor.ProcessedRecordsNr(), cdrsPosted, nil
+}
+
+// Posts one batch of CDRs, pausing and retrying the CDRs not stored for as long as CDRS fails the batch. Returns the number of CDRs accepted.
+// Retrying is safe since CDRS skips the CDRs it already stored, ErrCdrcClosed is returned if the cdrc is closed while waiting to retry.
+func (self *Cdrc) postCdrs(fp *fileProcessing, cdrs []*engine.StoredCdr, cdrsRecords []int64) (int, error) {
+	if cap(self.maxPostBatches) != 0 { // 0 goes for no limit
+		postBatch := <-self.maxPostBatches
+		defer func() { self.maxPostBatches <- postBatch }()
+	}
+	cdrsPosted := 0
+	for retryDelay := self.dfltCdrcCfg.RetryInterval; ; {
+		var replies []string
+		err := self.cdrs.ProcessCdrs(cdrs, &replies)
+		var retryCdrs []*engine.StoredCdr
+		var retryRecords []int64
+		for idx, storedCdr := range cdrs {
+			if idx >= len(replies) || len(replies[idx]) == 0 { // Not processed by CDRS
+				if err == nil {
+					self.rejectRecord(fp, cdrsRecords[idx], errors.New("no reply"), storedCdr)
+				} else {
+					retryCdrs = append(retryCdrs, storedCdr)
+					retryRecords = append(retryRecords, cdrsRecords[idx])
+				}
+			} else if replies[idx] != utils.OK {
+				self.rejectRecord(fp, cdrsRecords[idx], fmt.Errorf("unexpected reply: %s", replies[idx]), storedCdr)
+			} else {
+				cdrsPosted += 1
+			}
+		}
+		if len(retryCdrs) == 0 {
+			return cdrsPosted, nil
+		}
+		cdrs, cdrsRecords = retryCdrs, retryRecords
+		utils.Logger.Warning(fmt.Sprintf("<Cdrc> Posting %d CDRs out of %s, error: %s, retrying in %s", len(cdrs), fp.fileName, err.Error(), retryDelay))
+		select {
+		case <-self.closeChan:
+			return cdrsPosted, ErrCdrcClosed
+		case <-time.After(retryDelay):
+		}
+		if retryDelay *= 2; retryDelay > MAX_RETRY_DELAY {
+			retryDelay = MAX_RETRY_DELAY
+		}
+	}
+}
+
+// Journals the records processed so far out of the file
+func (self *Cdrc) recordProgress(fp *fileProcessing) {
+	if fp.jrnlEntry == nil {
+		return
+	}
+	fp.jrnlEntry.LastRow = fp.recordIdx
+	if err := self.journal.Record(fp.jrnlEntry); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<Cdrc> Journal error: %s", err.Error()))
+	}
+}
+
+// Value of the field with fldId out of a record, hasIt false if the record does not contain it
+type recordValueFunc func(cdrFldCfg *config.CfgCdrField, fldId string) (val string, hasIt bool, err error)
+
+// Populates storedCdr out of one record according to the field templates, the CgrId is generated only for content records (not header or trailer).
+// Used by the records processors of the formats other than CSV, each of them providing the way of extracting the values out of its records.
+func templateToStoredCdr(storedCdr *engine.StoredCdr, cfgFields []*config.CfgCdrField, valueAt recordValueFunc, recordIdx int64, isContent bool,
+	duMultiplyFactor float64, timezone string, httpSkipTlsCheck bool) error {
+	var lazyHttpFields []*config.CfgCdrField
+	for _, cdrFldCfg := range cfgFields {
+		var fieldVal string
+		switch cdrFldCfg.Type {
+		case utils.META_COMPOSED:
+			for _, cfgFieldRSR := range cdrFldCfg.Value {
+				if cfgFieldRSR.IsStatic() {
+					fieldVal += cfgFieldRSR.ParseValue("")
+					continue
+				}
+				recordVal, hasIt, err := valueAt(cdrFldCfg, cfgFieldRSR.Id)
+				if err != nil {
+					return err
+				} else if !hasIt && cdrFldCfg.Mandatory {
+					return fmt.Errorf("Ignoring record: %d - cannot extract field %s", recordIdx, cdrFldCfg.Tag)
+				}
+				fieldVal += cfgFieldRSR.ParseValue(recordVal)
+			}
+		case utils.META_HTTP_POST:
+			lazyHttpFields = append(lazyHttpFields, cdrFldCfg) // Will process later so we can send an estimation of storedCdr to http server
+		default:
+			return fmt.Errorf("Unsupported field type: %s", cdrFldCfg.Type)
+		}
+		if err := storedCdr.ParseFieldValue(cdrFldCfg.FieldId, fieldVal, timezone); err != nil {
+			return err
+		}
+	}
+	if isContent && storedCdr.CgrId == "" && storedCdr.AccId != "" {
+		storedCdr.CgrId = utils.Sha1(storedCdr.AccId, storedCdr.SetupTime.UTC().String())
+	}
+	if storedCdr.TOR == utils.DATA && duMultiplyFactor != 0 {
+		storedCdr.Usage = time.Duration(float64(storedCdr.Usage.Nanoseconds()) * duMultiplyFactor)
+	}
+	for _, httpFieldCfg := range lazyHttpFields { // Lazy process the http fields
+		var httpAddr string
+		for _, rsrFld := range httpFieldCfg.Value {
+			httpAddr += rsrFld.ParseValue("")
+		}
+		outValByte, err := utils.HttpJsonPost(httpAddr, httpSkipTlsCheck, storedCdr)
+		if err != nil && httpFieldCfg.Mandatory {
+			return err
+		}
+		fieldVal := string(outValByte)
+		if len(fieldVal) == 0 && httpFieldCfg.Mandatory {
+			return fmt.Errorf("MandatoryIeMissing: Empty result for http_post field: %s", httpFieldCfg.Tag)
+		}
+		if err := storedCdr.ParseFieldValue(httpFieldCfg.FieldId, fieldVal, timezone); err != nil {
+			return err
+		}
 	}
-	utils.Logger.Info(fmt.Sprintf("Finished processing %s, moved to %s. Total records processed: %d, CDRs posted: %d, run duration: %s",
-		fn, newPath, recordsProcessor.ProcessedRecordsNr(), cdrsPosted, time.Now().Sub(timeStart)))
 	return nil
 }
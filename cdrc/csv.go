@@ -206,7 +206,10 @@ func (self *CsvRecordsProcessor) ProcessedRecordsNr() int64 {
 func (self *CsvRecordsProcessor) ProcessNextRecord() ([]*engine.StoredCdr, error) {
 	record, err := self.csvReader.Read()
 	if err != nil {
-		return nil, err
+		if _, isParseErr := err.(*csv.ParseError); isParseErr { // Reader goes on with the next record
+			return nil, err
+		}
+		return nil, readerError(self.processedRecordsNr+1, err)
 	}
 	self.processedRecordsNr += 1
 	if utils.IsSliceMember([]string{utils.KAM_FLATSTORE, utils.OSIPS_FLATSTORE}, self.dfltCdrcCfg.CdrFormat) {
@@ -29,7 +29,6 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"time"
 )
 
 func fwvValue(cdrLine string, indexStart, width int, padding string) string {
@@ -116,7 +115,7 @@ func (self *FwvRecordsProcessor) ProcessNextRecord() ([]*engine.StoredCdr, error
 	buf := make([]byte, self.lineLen)
 	nRead, err := self.file.Read(buf)
 	if err != nil {
-		return nil, err
+		return nil, readerError(self.processedRecordsNr+1, err)
 	} else if nRead != len(buf) {
 		utils.Logger.Err(fmt.Sprintf("<Cdrc> Could not read complete line, have instead: %s", string(buf)))
 		return nil, io.EOF
@@ -158,8 +157,6 @@ func (self *FwvRecordsProcessor) recordPassesCfgFilter(record, configKey string)
 
 // Converts a record (header or normal) to StoredCdr
 func (self *FwvRecordsProcessor) recordToStoredCdr(record string, cfgKey string) (*engine.StoredCdr, error) {
-	var err error
-	var lazyHttpFields []*config.CfgCdrField
 	var cfgFields []*config.CfgCdrField
 	var duMultiplyFactor float64
 	var storedCdr *engine.StoredCdr
@@ -177,55 +174,17 @@ func (self *FwvRecordsProcessor) recordToStoredCdr(record string, cfgKey string)
 		storedCdr.CdrSource = self.cdrcCfgs[cfgKey].CdrSourceId
 		duMultiplyFactor = self.cdrcCfgs[cfgKey].DataUsageMultiplyFactor
 	}
-	for _, cdrFldCfg := range cfgFields {
-		var fieldVal string
-		switch cdrFldCfg.Type {
-		case utils.META_COMPOSED:
-			for _, cfgFieldRSR := range cdrFldCfg.Value {
-				if cfgFieldRSR.IsStatic() {
-					fieldVal += cfgFieldRSR.ParseValue("")
-				} else { // Dynamic value extracted using index
-					if cfgFieldIdx, _ := strconv.Atoi(cfgFieldRSR.Id); len(record) <= cfgFieldIdx {
-						return nil, fmt.Errorf("Ignoring record: %v - cannot extract field %s", record, cdrFldCfg.Tag)
-					} else {
-						fieldVal += cfgFieldRSR.ParseValue(fwvValue(record, cfgFieldIdx, cdrFldCfg.Width, cdrFldCfg.Padding))
-					}
-				}
-			}
-		case utils.META_HTTP_POST:
-			lazyHttpFields = append(lazyHttpFields, cdrFldCfg) // Will process later so we can send an estimation of storedCdr to http server
-		default:
-			//return nil, fmt.Errorf("Unsupported field type: %s", cdrFldCfg.Type)
-			continue // Don't do anything for unsupported fields
-		}
-		if err := storedCdr.ParseFieldValue(cdrFldCfg.FieldId, fieldVal, self.timezone); err != nil {
-			return nil, err
-		}
-	}
-	if storedCdr.CgrId == "" && storedCdr.AccId != "" && cfgKey != "*header" {
-		storedCdr.CgrId = utils.Sha1(storedCdr.AccId, storedCdr.SetupTime.UTC().String())
-	}
-	if storedCdr.TOR == utils.DATA && duMultiplyFactor != 0 {
-		storedCdr.Usage = time.Duration(float64(storedCdr.Usage.Nanoseconds()) * duMultiplyFactor)
-	}
-	for _, httpFieldCfg := range lazyHttpFields { // Lazy process the http fields
-		var outValByte []byte
-		var fieldVal, httpAddr string
-		for _, rsrFld := range httpFieldCfg.Value {
-			httpAddr += rsrFld.ParseValue("")
-		}
-		if outValByte, err = utils.HttpJsonPost(httpAddr, self.httpSkipTlsCheck, storedCdr); err != nil && httpFieldCfg.Mandatory {
-			return nil, err
+	valueAt := func(cdrFldCfg *config.CfgCdrField, fldId string) (string, bool, error) { // Out of range index fails the record, mandatory or not
+		if cfgFieldIdx, _ := strconv.Atoi(fldId); len(record) <= cfgFieldIdx {
+			return "", false, fmt.Errorf("Ignoring record: %v - cannot extract field %s", record, cdrFldCfg.Tag)
 		} else {
-			fieldVal = string(outValByte)
-			if len(fieldVal) == 0 && httpFieldCfg.Mandatory {
-				return nil, fmt.Errorf("MandatoryIeMissing: Empty result for http_post field: %s", httpFieldCfg.Tag)
-			}
-			if err := storedCdr.ParseFieldValue(httpFieldCfg.FieldId, fieldVal, self.timezone); err != nil {
-				return nil, err
-			}
+			return fwvValue(record, cfgFieldIdx, cdrFldCfg.Width, cdrFldCfg.Padding), true, nil
 		}
 	}
+	if err := templateToStoredCdr(storedCdr, cfgFields, valueAt, self.processedRecordsNr, cfgKey != "*header",
+		duMultiplyFactor, self.timezone, self.httpSkipTlsCheck); err != nil {
+		return nil, err
+	}
 	return storedCdr, nil
 }
 
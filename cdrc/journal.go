@@ -0,0 +1,232 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	JOURNAL_SUFFIX   = ".journal"
+	DUPLICATE_SUFFIX = ".duplicate"
+	REJECTS_SUFFIX   = ".rejects"
+	JOURNAL_COMPACT  = 10000 // Lines appended to the journal before compacting it again
+)
+
+// Processing progress of one CDR file, identified by the hash of its content so renamed copies are recognized
+type JournalEntry struct {
+	Hash      string
+	FileName  string
+	Size      int64
+	LastRow   int64 // Number of records fully processed out of the file
+	Completed bool
+	UpdatedAt time.Time
+}
+
+// Computes the SHA-256 hash and the size of the file content, rewinding the file afterwards
+func fileHash(file *os.File) (string, int64, error) {
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// Append only log with the progress of the files processed by one cdrc, replayed and compacted on start
+type Journal struct {
+	sync.Mutex
+	filePath  string
+	retention time.Duration // Completed files older than this are dropped when compacting, 0 to keep them forever
+	file      *os.File
+	entries   map[string]*JournalEntry // Last state of the files, indexed on hash
+	appended  int                      // Lines appended since the last compaction
+}
+
+// Opens the journal of the cdrc processing cdrInDir, one journal file per in folder so the cdrcs can share journalDir
+func NewJournal(journalDir, cdrInDir string, retention time.Duration) (*Journal, error) {
+	jrnl := &Journal{filePath: path.Join(journalDir, "cdrc_"+utils.Sha1(cdrInDir)+JOURNAL_SUFFIX), retention: retention, entries: make(map[string]*JournalEntry)}
+	if err := jrnl.load(); err != nil {
+		return nil, err
+	}
+	if err := jrnl.compact(); err != nil {
+		return nil, err
+	}
+	if err := jrnl.open(); err != nil {
+		return nil, err
+	}
+	return jrnl, nil
+}
+
+func (self *Journal) open() (err error) {
+	self.file, err = os.OpenFile(self.filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	return
+}
+
+// Replays the journal file, the last state of each file wins
+func (self *Journal) load() error {
+	file, err := os.Open(self.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for {
+		var entry JournalEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil { // Most probably truncated by a crash while writing, keep what was read so far
+			utils.Logger.Warning(fmt.Sprintf("<Cdrc> Journal %s, ignoring entries after %d, error: %s", self.filePath, len(self.entries), err.Error()))
+			return nil
+		}
+		self.entries[entry.Hash] = &entry
+	}
+}
+
+// Rewrites the journal with one line per file so it does not grow with the progress records,
+// dropping the files completed longer ago than retention
+func (self *Journal) compact() error {
+	if self.retention > 0 {
+		expired := time.Now().Add(-self.retention)
+		for hash, entry := range self.entries {
+			if entry.Completed && entry.UpdatedAt.Before(expired) {
+				delete(self.entries, hash)
+			}
+		}
+	}
+	tmpFile, err := ioutil.TempFile(path.Dir(self.filePath), path.Base(self.filePath)+".")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(tmpFile)
+	for _, entry := range self.entries {
+		if err = encoder.Encode(entry); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), self.filePath)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	self.appended = 0
+	return nil
+}
+
+// Compacts the journal while it is in use, the appends continue into the new file
+func (self *Journal) recompact() error {
+	if err := self.file.Close(); err != nil {
+		return err
+	}
+	err := self.compact()
+	if errOpen := self.open(); err == nil {
+		err = errOpen
+	}
+	return err
+}
+
+// Returns a copy of the entry with hash, nil if the file was never processed
+func (self *Journal) Entry(hash string) *JournalEntry {
+	self.Lock()
+	defer self.Unlock()
+	entry, hasIt := self.entries[hash]
+	if !hasIt {
+		return nil
+	}
+	clnEntry := *entry
+	return &clnEntry
+}
+
+// Persists the new state of the entry, completed files are synced to disk
+func (self *Journal) Record(entry *JournalEntry) error {
+	self.Lock()
+	defer self.Unlock()
+	clnEntry := *entry
+	clnEntry.UpdatedAt = time.Now()
+	self.entries[entry.Hash] = &clnEntry
+	if err := json.NewEncoder(self.file).Encode(clnEntry); err != nil {
+		return err
+	}
+	self.appended += 1
+	if !entry.Completed {
+		return nil
+	}
+	if self.appended >= JOURNAL_COMPACT {
+		return self.recompact()
+	}
+	return self.file.Sync()
+}
+
+func (self *Journal) Close() error {
+	self.Lock()
+	defer self.Unlock()
+	return self.file.Close()
+}
+
+// One record failing processing, written as JSON line into the rejects file
+type rejectedRecord struct {
+	Row   int64
+	Error string
+	Cdr   *engine.StoredCdr `json:",omitempty"`
+}
+
+// State of one file being processed, shared by the members of an archive
+type fileProcessing struct {
+	fileName  string
+	jrnlEntry *JournalEntry // Nil if the journal is disabled
+	recordIdx int64         // Records read so far out of the file
+	rejects   *os.File      // Opened with the first rejected record
+}
+
+// Records were already processed before restart
+func (self *fileProcessing) processedBefore() bool {
+	return self.jrnlEntry != nil && self.recordIdx <= self.jrnlEntry.LastRow
+}
+
+func (self *fileProcessing) close() {
+	if self.rejects != nil {
+		self.rejects.Close()
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestCdrcJournal(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.WriteRejects = true
+	for _, dir := range []*string{&cdrcConfig.CdrInDir, &cdrcConfig.CdrOutDir, &cdrcConfig.JournalDir, &cdrcConfig.QuarantineDir} {
+		var err error
+		if *dir, err = ioutil.TempDir("", "cdrc_jrnl"); err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(*dir)
+	}
+	fileContent := []byte(csvCdrRow("acc1") + csvCdrRow("acc2") + "bad,row\n")
+	ioutil.WriteFile(path.Join(cdrcConfig.CdrInDir, "cdrs1.csv"), fileContent, 0644)
+	// Simulate a crash after the first record was posted
+	jrnl, err := NewJournal(cdrcConfig.JournalDir, cdrcConfig.CdrInDir, cdrcConfig.JournalRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, _ := os.Open(path.Join(cdrcConfig.CdrInDir, "cdrs1.csv"))
+	hash, size, err := fileHash(file)
+	file.Close()
+	if err != nil || size != int64(len(fileContent)) {
+		t.Fatalf("Hash: %s, size: %d, error: %v", hash, size, err)
+	}
+	if err := jrnl.Record(&JournalEntry{Hash: hash, FileName: "cdrs1.csv", Size: size, LastRow: 1}); err != nil {
+		t.Fatal(err)
+	}
+	jrnl.Close()
+	if jrnl, err = NewJournal(cdrcConfig.JournalDir, cdrcConfig.CdrInDir, cdrcConfig.JournalRetention); err != nil {
+		t.Fatal(err)
+	}
+	cdrs := new(cdrsCollector)
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC", cdrs: cdrs,
+		maxOpenFiles: make(chan struct{}), journal: jrnl}
+	if err := cdrc.processFile(path.Join(cdrcConfig.CdrInDir, "cdrs1.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"acc2"}, cdrs.accIds) { // acc1 posted before the crash
+		t.Errorf("Unexpected CDRs posted: %+v", cdrs.accIds)
+	}
+	if entry := jrnl.Entry(hash); entry == nil || !entry.Completed || entry.LastRow != 3 {
+		t.Errorf("Unexpected journal entry: %+v", entry)
+	}
+	if rejects, err := ioutil.ReadFile(path.Join(cdrcConfig.CdrOutDir, "cdrs1.csv"+REJECTS_SUFFIX)); err != nil {
+		t.Error(err)
+	} else {
+		var rejected rejectedRecord
+		if lines := strings.Split(strings.TrimSpace(string(rejects)), "\n"); len(lines) != 1 {
+			t.Errorf("Unexpected rejects: %s", rejects)
+		} else if err := json.Unmarshal([]byte(lines[0]), &rejected); err != nil || rejected.Row != 3 || rejected.Cdr != nil {
+			t.Errorf("Unexpected reject: %+v, error: %v", rejected, err)
+		}
+	}
+	// Same content dropped again under a different name
+	ioutil.WriteFile(path.Join(cdrcConfig.CdrInDir, "cdrs2.csv"), fileContent, 0644)
+	if err := cdrc.processFile(path.Join(cdrcConfig.CdrInDir, "cdrs2.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if len(cdrs.accIds) != 1 {
+		t.Errorf("Duplicate file posted CDRs: %+v", cdrs.accIds)
+	}
+	if _, err := os.Stat(path.Join(cdrcConfig.QuarantineDir, "cdrs2.csv")); err != nil {
+		t.Error("Duplicate not quarantined: ", err)
+	}
+	// Journal survives restarts
+	jrnl.Close()
+	if jrnl, err = NewJournal(cdrcConfig.JournalDir, cdrcConfig.CdrInDir, cdrcConfig.JournalRetention); err != nil {
+		t.Fatal(err)
+	}
+	defer jrnl.Close()
+	if entry := jrnl.Entry(hash); entry == nil || !entry.Completed || entry.FileName != "cdrs1.csv" {
+		t.Errorf("Unexpected journal entry: %+v", entry)
+	}
+}
+
+func TestCdrcPostBatches(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.WriteRejects = true
+	cdrcConfig.BatchSize = 2
+	cdrcConfig.RetryInterval = time.Millisecond
+	for _, dir := range []*string{&cdrcConfig.CdrInDir, &cdrcConfig.CdrOutDir} {
+		var err error
+		if *dir, err = ioutil.TempDir("", "cdrc_batch"); err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(*dir)
+	}
+	ioutil.WriteFile(path.Join(cdrcConfig.CdrInDir, "cdrs.csv"), []byte(csvCdrRow("acc1")+csvCdrRow("acc2")+csvCdrRow("rejacc")), 0644)
+	cdrs := &cdrsCollector{refuseCalls: 2, failStoring: 1, rejectAccIds: map[string]bool{"rejacc": true}}
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC", cdrs: cdrs,
+		maxOpenFiles: make(chan struct{}), maxPostBatches: make(chan struct{}, 1)}
+	cdrc.maxPostBatches <- struct{}{}
+	if err := cdrc.processFile(path.Join(cdrcConfig.CdrInDir, "cdrs.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"acc1", "acc2"}, cdrs.accIds) {
+		t.Errorf("Unexpected CDRs posted: %+v", cdrs.accIds)
+	}
+	if cdrs.batches != 5 { // First batch refused twice, then acc2 retried alone
+		t.Errorf("Unexpected batches: %d", cdrs.batches)
+	}
+	if rejects, err := ioutil.ReadFile(path.Join(cdrcConfig.CdrOutDir, "cdrs.csv"+REJECTS_SUFFIX)); err != nil {
+		t.Error(err)
+	} else {
+		var rejected rejectedRecord
+		if err := json.Unmarshal(rejects, &rejected); err != nil || rejected.Row != 3 || rejected.Cdr == nil || rejected.Cdr.AccId != "rejacc" {
+			t.Errorf("Unexpected reject: %+v, error: %v", rejected, err)
+		}
+	}
+}
+
+func TestCdrcPostClosed(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.RetryInterval = time.Hour
+	for _, dir := range []*string{&cdrcConfig.CdrInDir, &cdrcConfig.CdrOutDir} {
+		var err error
+		if *dir, err = ioutil.TempDir("", "cdrc_batch"); err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(*dir)
+	}
+	filePath := path.Join(cdrcConfig.CdrInDir, "cdrs.csv")
+	ioutil.WriteFile(filePath, []byte(csvCdrRow("acc1")), 0644)
+	closeChan := make(chan struct{})
+	cdrc := &Cdrc{cdrcCfgs: map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, dfltCdrcCfg: cdrcConfig, timezone: "UTC",
+		cdrs: &cdrsCollector{refuseCalls: 1}, maxOpenFiles: make(chan struct{}), closeChan: closeChan}
+	close(closeChan)
+	if err := cdrc.processFile(filePath); err != ErrCdrcClosed {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filePath); err != nil { // Processed again on next start
+		t.Error(err)
+	}
+}
+
+func TestCdrcJournalRetention(t *testing.T) {
+	jrnlDir, err := ioutil.TempDir("", "cdrc_jrnl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(jrnlDir)
+	jrnl, err := NewJournal(jrnlDir, "/var/log/cgrates/cdrc/in", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, entry := range []*JournalEntry{
+		&JournalEntry{Hash: "expired", FileName: "cdrs1.csv", LastRow: 10, Completed: true, UpdatedAt: old},
+		&JournalEntry{Hash: "unfinished", FileName: "cdrs2.csv", LastRow: 5, UpdatedAt: old}, // Kept so processing resumes
+		&JournalEntry{Hash: "recent", FileName: "cdrs3.csv", LastRow: 10, Completed: true, UpdatedAt: time.Now()},
+	} {
+		if err := json.NewEncoder(jrnl.file).Encode(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jrnl.Close()
+	if jrnl, err = NewJournal(jrnlDir, "/var/log/cgrates/cdrc/in", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if jrnl.Entry("expired") != nil || jrnl.Entry("unfinished") == nil || jrnl.Entry("recent") == nil {
+		t.Errorf("Unexpected entries: %s", utils.ToJSON(jrnl.entries))
+	}
+	// Compacting while in use
+	jrnl.entries["recent"].UpdatedAt = old
+	jrnl.Lock()
+	err = jrnl.recompact()
+	jrnl.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jrnl.Record(&JournalEntry{Hash: "new", FileName: "cdrs4.csv", LastRow: 1}); err != nil {
+		t.Fatal(err)
+	}
+	jrnl.Close()
+	if content, err := ioutil.ReadFile(jrnl.filePath); err != nil {
+		t.Error(err)
+	} else if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 2 ||
+		!strings.Contains(string(content), "cdrs2.csv") || !strings.Contains(string(content), "cdrs4.csv") {
+		t.Errorf("Unexpected journal: %s", content)
+	}
+}
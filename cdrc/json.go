@@ -0,0 +1,203 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const JSON_PATH_SEP = "."
+
+// Returns the value found at fldPath inside the JSON record, eg: caller.number or legs.0.duration for array elements
+func jsonValueAt(record map[string]interface{}, fldPath string) (string, bool) {
+	var val interface{} = record
+	for _, fldName := range strings.Split(fldPath, JSON_PATH_SEP) {
+		switch jsnVal := val.(type) {
+		case map[string]interface{}:
+			var hasIt bool
+			if val, hasIt = jsnVal[fldName]; !hasIt {
+				return "", false
+			}
+		case []interface{}:
+			idx, err := strconv.Atoi(fldName)
+			if err != nil || idx < 0 || idx >= len(jsnVal) {
+				return "", false
+			}
+			val = jsnVal[idx]
+		default:
+			return "", false
+		}
+	}
+	switch jsnVal := val.(type) {
+	case nil:
+		return "", true
+	case string:
+		return jsnVal, true
+	case json.Number:
+		return jsnVal.String(), true
+	case bool:
+		return strconv.FormatBool(jsnVal), true
+	default: // Objects and arrays are passed on as JSON
+		valJsn, err := json.Marshal(jsnVal)
+		if err != nil {
+			return "", false
+		}
+		return string(valJsn), true
+	}
+}
+
+func NewJsonRecordsProcessor(rdr io.Reader, dfltCfg *config.CdrcConfig, cdrcCfgs map[string]*config.CdrcConfig, httpClient *http.Client, httpSkipTlsCheck bool, timezone string) *JsonRecordsProcessor {
+	jsnProc := &JsonRecordsProcessor{dfltCfg: dfltCfg, cdrcCfgs: cdrcCfgs, httpClient: httpClient, httpSkipTlsCheck: httpSkipTlsCheck, timezone: timezone}
+	if dfltCfg.CdrFormat == utils.NDJSON {
+		jsnProc.lineReader = bufio.NewReader(rdr)
+	} else {
+		jsnProc.decoder = json.NewDecoder(rdr)
+		jsnProc.decoder.UseNumber()
+	}
+	return jsnProc
+}
+
+// Processes JSON files containing an array of CDR objects or NDJSON ones with one CDR object per line.
+// Field values are paths inside the CDR object, levels separated by JSON_PATH_SEP.
+type JsonRecordsProcessor struct {
+	decoder            *json.Decoder // Streams the elements out of a JSON array
+	lineReader         *bufio.Reader // Reads NDJSON files line by line so one bad line does not stop the processing
+	dfltCfg            *config.CdrcConfig
+	cdrcCfgs           map[string]*config.CdrcConfig
+	httpClient         *http.Client
+	httpSkipTlsCheck   bool
+	timezone           string
+	processedRecordsNr int64
+	arrayOpened        bool
+}
+
+func (self *JsonRecordsProcessor) ProcessedRecordsNr() int64 {
+	return self.processedRecordsNr
+}
+
+// Returns the next CDR object in the file
+func (self *JsonRecordsProcessor) nextRecord() (map[string]interface{}, error) {
+	var record map[string]interface{}
+	if self.lineReader != nil {
+		for {
+			line, err := self.lineReader.ReadBytes('\n')
+			if err != nil && err != io.EOF { // Line cut short, the reader cannot go on with the next ones
+				return nil, readerError(self.processedRecordsNr+1, err)
+			}
+			if len(bytes.TrimSpace(line)) == 0 {
+				if err != nil {
+					return nil, err
+				}
+				continue // Skip empty lines
+			}
+			self.processedRecordsNr += 1
+			decoder := json.NewDecoder(bytes.NewReader(line))
+			decoder.UseNumber()
+			if errDec := decoder.Decode(&record); errDec != nil {
+				return nil, fmt.Errorf("Line %d, error: %s", self.processedRecordsNr, errDec.Error())
+			}
+			return record, nil
+		}
+	}
+	if !self.arrayOpened {
+		if tkn, err := self.decoder.Token(); err != nil {
+			return nil, err
+		} else if delim, isDelim := tkn.(json.Delim); !isDelim || delim != '[' {
+			return nil, fmt.Errorf("Expecting array of CDRs, received: %v", tkn)
+		}
+		self.arrayOpened = true
+	}
+	if !self.decoder.More() {
+		if tkn, err := self.decoder.Token(); err != nil { // Array not closed, the file was truncated
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		} else if delim, isDelim := tkn.(json.Delim); !isDelim || delim != ']' {
+			return nil, fmt.Errorf("Expecting end of CDRs array, received: %v", tkn)
+		}
+		return nil, io.EOF
+	}
+	self.processedRecordsNr += 1
+	if err := self.decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (self *JsonRecordsProcessor) ProcessNextRecord() ([]*engine.StoredCdr, error) {
+	record, err := self.nextRecord()
+	if err != nil {
+		if err != io.EOF && self.decoder != nil { // Decoder cannot recover out of syntax errors, fail the file
+			return nil, NewCorruptFileError(self.processedRecordsNr, err)
+		}
+		return nil, err
+	}
+	recordCdrs := make([]*engine.StoredCdr, 0) // More CDRs based on the number of filters and field templates
+	for cfgKey, cdrcCfg := range self.cdrcCfgs {
+		if !self.recordPassesCfgFilter(record, cfgKey) {
+			continue
+		}
+		if storedCdr, err := self.recordToStoredCdr(record, cfgKey); err != nil {
+			return nil, fmt.Errorf("Failed converting to StoredCdr, error: %s", err.Error())
+		} else {
+			recordCdrs = append(recordCdrs, storedCdr)
+		}
+		if !cdrcCfg.ContinueOnSuccess { // Successfully executed one config, do not continue for next one
+			break
+		}
+	}
+	return recordCdrs, nil
+}
+
+func (self *JsonRecordsProcessor) recordPassesCfgFilter(record map[string]interface{}, cfgKey string) bool {
+	for _, rsrFilter := range self.cdrcCfgs[cfgKey].CdrFilter {
+		if rsrFilter == nil { // Nil filter does not need to match anything
+			continue
+		}
+		if fldVal, hasIt := jsonValueAt(record, rsrFilter.Id); !hasIt || !rsrFilter.FilterPasses(fldVal) {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *JsonRecordsProcessor) recordToStoredCdr(record map[string]interface{}, cfgKey string) (*engine.StoredCdr, error) {
+	storedCdr := &engine.StoredCdr{CdrHost: "0.0.0.0", CdrSource: self.cdrcCfgs[cfgKey].CdrSourceId, ExtraFields: make(map[string]string), Cost: -1}
+	valueAt := func(cdrFldCfg *config.CfgCdrField, fldId string) (string, bool, error) {
+		jsnVal, hasIt := jsonValueAt(record, fldId)
+		return jsnVal, hasIt, nil
+	}
+	if err := templateToStoredCdr(storedCdr, self.cdrcCfgs[cfgKey].ContentFields, valueAt, self.processedRecordsNr, true,
+		self.cdrcCfgs[cfgKey].DataUsageMultiplyFactor, self.timezone, self.httpSkipTlsCheck); err != nil {
+		return nil, err
+	}
+	return storedCdr, nil
+}
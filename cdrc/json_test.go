@@ -0,0 +1,143 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var jsonCdrs = []string{
+	`{"id": "call1", "caller": {"number": "+4986517174963"}, "callee": {"number": "1002"}, "start": "2016-01-13T09:01:51Z", "legs": [{"duration": 42}], "answered": true}`,
+	`{"id": "call2", "caller": {"number": "1002"}, "callee": {"number": "1003"}, "start": "2016-01-13T09:02:51Z", "legs": [{"duration": 0}], "answered": false}`,
+	`{"id": "call3", "caller": {"number": "1003"}, "callee": {"number": "1001"}, "start": "2016-01-13T09:03:51Z", "legs": [{"duration": 10}], "answered": true}`,
+}
+
+func TestJsonValueAt(t *testing.T) {
+	record := map[string]interface{}{"caller": map[string]interface{}{"number": "1001", "tags": []interface{}{"a", "b"}}, "answered": true}
+	if val, hasIt := jsonValueAt(record, "caller.number"); !hasIt || val != "1001" {
+		t.Errorf("Received: %q, %v", val, hasIt)
+	}
+	if val, hasIt := jsonValueAt(record, "caller.tags.1"); !hasIt || val != "b" {
+		t.Errorf("Received: %q, %v", val, hasIt)
+	}
+	if val, hasIt := jsonValueAt(record, "answered"); !hasIt || val != "true" {
+		t.Errorf("Received: %q, %v", val, hasIt)
+	}
+	if _, hasIt := jsonValueAt(record, "caller.number.prefix"); hasIt {
+		t.Error("Should not have value")
+	}
+}
+
+func TestJsonRecordsProcessor(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.CdrSourceId = "TEST_CDRC"
+	cdrcConfig.CdrFilter = utils.ParseRSRFieldsMustCompile("answered(true)", utils.INFIELD_SEP)
+	cdrcConfig.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR, Value: utils.ParseRSRFieldsMustCompile("^*voice", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("id", utils.INFIELD_SEP), Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT,
+			Value: utils.ParseRSRFieldsMustCompile("~caller.number:s/^\\+49(\\d+)$/0${1}/", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION, Value: utils.ParseRSRFieldsMustCompile("callee.number", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME, Value: utils.ParseRSRFieldsMustCompile("start", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE, Value: utils.ParseRSRFieldsMustCompile("legs.0.duration", utils.INFIELD_SEP)},
+	}
+	for cdrFormat, content := range map[string]string{
+		utils.JSON:   "[\n" + strings.Join(jsonCdrs, ",\n") + "\n]\n",
+		utils.NDJSON: strings.Join(jsonCdrs[:2], "\n") + "\n\nnot json\n" + jsonCdrs[2], // Bad lines are skipped
+	} {
+		cdrcConfig.CdrFormat = cdrFormat
+		jsnProcessor := NewJsonRecordsProcessor(strings.NewReader(content), cdrcConfig, map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, nil, false, "UTC")
+		var cdrs []*engine.StoredCdr
+		var errs int
+		for {
+			recCdrs, err := jsnProcessor.ProcessNextRecord()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				errs += 1
+				continue
+			}
+			cdrs = append(cdrs, recCdrs...)
+		}
+		if len(cdrs) != 2 { // Not answered call filtered out
+			t.Fatalf("Format: %s, unexpected CDRs: %+v", cdrFormat, cdrs)
+		}
+		if cdrFormat == utils.NDJSON && (errs != 1 || jsnProcessor.ProcessedRecordsNr() != 4) {
+			t.Errorf("Errors: %d, processed records: %d", errs, jsnProcessor.ProcessedRecordsNr())
+		}
+		if cdrs[0].AccId != "call1" || cdrs[0].Account != "086517174963" || cdrs[0].Destination != "1002" ||
+			!cdrs[0].SetupTime.Equal(time.Date(2016, 1, 13, 9, 1, 51, 0, time.UTC)) || cdrs[0].Usage != time.Duration(42)*time.Second || cdrs[0].CdrSource != "TEST_CDRC" {
+			t.Errorf("Format: %s, unexpected CDR: %+v", cdrFormat, cdrs[0])
+		}
+		if cdrs[1].AccId != "call3" || cdrs[1].Usage != time.Duration(10)*time.Second {
+			t.Errorf("Format: %s, unexpected CDR: %+v", cdrFormat, cdrs[1])
+		}
+	}
+}
+
+func TestJsonTruncatedFile(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.CdrFormat = utils.JSON
+	cdrcConfig.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("id", utils.INFIELD_SEP), Mandatory: true}}
+	for _, content := range []string{
+		"[\n" + jsonCdrs[0] + ",\n" + jsonCdrs[1][:len(jsonCdrs[1])/2], // Truncated inside the record
+		"[\n" + jsonCdrs[0] + ",\n",                                    // Truncated before the end of the array
+	} {
+		jsnProcessor := NewJsonRecordsProcessor(strings.NewReader(content), cdrcConfig, map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, nil, false, "UTC")
+		if _, err := jsnProcessor.ProcessNextRecord(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := jsnProcessor.ProcessNextRecord(); err == nil || err == io.EOF {
+			t.Errorf("Truncated file processed, error: %v", err)
+		} else if _, isCorrupt := err.(*CorruptFileError); !isCorrupt {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	cdrcConfig.CdrFormat = utils.NDJSON // Archive cut short, the lines after cannot be read
+	var gzContent bytes.Buffer
+	gzWriter := gzip.NewWriter(&gzContent)
+	gzWriter.Write([]byte(strings.Join(jsonCdrs, "\n") + "\n"))
+	gzWriter.Close()
+	gzReader, err := gzip.NewReader(bytes.NewReader(gzContent.Bytes()[:gzContent.Len()-4]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsnProcessor := NewJsonRecordsProcessor(gzReader, cdrcConfig, map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, nil, false, "UTC")
+	for i := 0; ; i++ {
+		_, err := jsnProcessor.ProcessNextRecord()
+		if err == nil {
+			continue
+		} else if _, isCorrupt := err.(*CorruptFileError); !isCorrupt || i > len(jsonCdrs) {
+			t.Errorf("Record: %d, unexpected error: %v", i, err)
+		}
+		break
+	}
+}
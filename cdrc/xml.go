@@ -0,0 +1,227 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const XML_ATTR_PREFIX = "@"
+
+// One element out of the XML file, only local names are considered
+type xmlElement struct {
+	name     string
+	attrs    []xml.Attr
+	text     string
+	children []*xmlElement
+}
+
+// Returns the value found at elmPath relative to the element, eg: basicModule>userNumber or basicModule>@id for an attribute
+func (self *xmlElement) valueAt(elmPath string) (string, bool) {
+	elm := self
+	for _, elmName := range strings.Split(elmPath, utils.HIERARCHY_SEP) {
+		if strings.HasPrefix(elmName, XML_ATTR_PREFIX) {
+			for _, attr := range elm.attrs {
+				if attr.Name.Local == elmName[len(XML_ATTR_PREFIX):] {
+					return attr.Value, true
+				}
+			}
+			return "", false
+		}
+		var found *xmlElement
+		for _, chld := range elm.children {
+			if chld.name == elmName {
+				found = chld
+				break
+			}
+		}
+		if found == nil {
+			return "", false
+		}
+		elm = found
+	}
+	return strings.TrimSpace(elm.text), true
+}
+
+func NewXmlRecordsProcessor(rdr io.Reader, dfltCfg *config.CdrcConfig, cdrcCfgs map[string]*config.CdrcConfig, httpClient *http.Client, httpSkipTlsCheck bool, timezone string) *XmlRecordsProcessor {
+	return &XmlRecordsProcessor{decoder: xml.NewDecoder(rdr), cdrPath: strings.Split(dfltCfg.CdrPath, utils.HIERARCHY_SEP), dfltCfg: dfltCfg, cdrcCfgs: cdrcCfgs,
+		httpClient: httpClient, httpSkipTlsCheck: httpSkipTlsCheck, timezone: timezone}
+}
+
+// Streams the CDR elements out of XML files, field values are paths relative to the CDR element for content and to the root element for header and trailer
+type XmlRecordsProcessor struct {
+	decoder            *xml.Decoder
+	cdrPath            []string // Names of the elements leading to one CDR, root included
+	dfltCfg            *config.CdrcConfig
+	cdrcCfgs           map[string]*config.CdrcConfig
+	httpClient         *http.Client
+	httpSkipTlsCheck   bool
+	timezone           string
+	processedRecordsNr int64
+	elmStack           []*xmlElement     // Elements opened but not yet closed, root first
+	docRoot            *xmlElement       // The document without the CDR elements, source of header and trailer values
+	headerProcessed    bool              // Header is processed once, before the first CDR
+	headerCdr          *engine.StoredCdr // Cache here the general purpose stored CDR
+}
+
+func (self *XmlRecordsProcessor) ProcessedRecordsNr() int64 {
+	return self.processedRecordsNr
+}
+
+// Checks if the element on top of the stack is a CDR one
+func (self *XmlRecordsProcessor) onCdrPath() bool {
+	if len(self.elmStack) != len(self.cdrPath) {
+		return false
+	}
+	for idx, elm := range self.elmStack {
+		if elm.name != self.cdrPath[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *XmlRecordsProcessor) ProcessNextRecord() ([]*engine.StoredCdr, error) {
+	for {
+		tkn, err := self.decoder.Token()
+		if err == io.EOF {
+			if len(self.dfltCfg.TrailerFields) != 0 && self.docRoot != nil {
+				if _, err := self.recordToStoredCdr(self.docRoot, "*trailer"); err != nil {
+					utils.Logger.Err(fmt.Sprintf("<Cdrc> Read trailer error: %s ", err.Error()))
+				}
+			}
+			return nil, io.EOF
+		} else if err != nil { // Decoder cannot recover out of syntax errors, fail the file
+			return nil, NewCorruptFileError(self.processedRecordsNr+1, err)
+		}
+		switch xmlTkn := tkn.(type) {
+		case xml.StartElement:
+			elm := &xmlElement{name: xmlTkn.Name.Local, attrs: xmlTkn.Attr}
+			if len(self.elmStack) == 0 {
+				self.docRoot = elm
+			} else {
+				parent := self.elmStack[len(self.elmStack)-1]
+				parent.children = append(parent.children, elm)
+			}
+			self.elmStack = append(self.elmStack, elm)
+		case xml.CharData:
+			if len(self.elmStack) != 0 {
+				self.elmStack[len(self.elmStack)-1].text += string(xmlTkn)
+			}
+		case xml.EndElement:
+			if len(self.elmStack) == 0 {
+				continue
+			}
+			isCdr := self.onCdrPath()
+			elm := self.elmStack[len(self.elmStack)-1]
+			self.elmStack = self.elmStack[:len(self.elmStack)-1]
+			if !isCdr {
+				continue
+			}
+			if len(self.elmStack) != 0 { // Detach the CDR so the document does not grow with the file
+				parent := self.elmStack[len(self.elmStack)-1]
+				parent.children = parent.children[:len(parent.children)-1]
+			}
+			if !self.headerProcessed {
+				self.headerProcessed = true
+				if len(self.dfltCfg.HeaderFields) != 0 {
+					if self.headerCdr, err = self.recordToStoredCdr(self.docRoot, "*header"); err != nil {
+						utils.Logger.Err(fmt.Sprintf("<Cdrc> Error reading header: %s", err.Error()))
+						return nil, io.EOF
+					}
+				}
+			}
+			self.processedRecordsNr += 1
+			return self.processRecord(elm)
+		}
+	}
+}
+
+// Turns the CDR element into StoredCdrs, one for each of the matching configurations
+func (self *XmlRecordsProcessor) processRecord(cdrElm *xmlElement) ([]*engine.StoredCdr, error) {
+	recordCdrs := make([]*engine.StoredCdr, 0) // More CDRs based on the number of filters and field templates
+	for cfgKey, cdrcCfg := range self.cdrcCfgs {
+		if !self.recordPassesCfgFilter(cdrElm, cfgKey) {
+			continue
+		}
+		if storedCdr, err := self.recordToStoredCdr(cdrElm, cfgKey); err != nil {
+			return nil, fmt.Errorf("Failed converting to StoredCdr, error: %s", err.Error())
+		} else {
+			recordCdrs = append(recordCdrs, storedCdr)
+		}
+		if !cdrcCfg.ContinueOnSuccess { // Successfully executed one config, do not continue for next one
+			break
+		}
+	}
+	return recordCdrs, nil
+}
+
+func (self *XmlRecordsProcessor) recordPassesCfgFilter(cdrElm *xmlElement, cfgKey string) bool {
+	for _, rsrFilter := range self.cdrcCfgs[cfgKey].CdrFilter {
+		if rsrFilter == nil { // Nil filter does not need to match anything
+			continue
+		}
+		if fldVal, hasIt := cdrElm.valueAt(rsrFilter.Id); !hasIt || !rsrFilter.FilterPasses(fldVal) {
+			return false
+		}
+	}
+	return true
+}
+
+// Converts a CDR element (or the document for header and trailer) to StoredCdr
+func (self *XmlRecordsProcessor) recordToStoredCdr(elm *xmlElement, cfgKey string) (*engine.StoredCdr, error) {
+	var cfgFields []*config.CfgCdrField
+	var duMultiplyFactor float64
+	var storedCdr *engine.StoredCdr
+	if self.headerCdr != nil { // Clone the header CDR so we can use it as base to future processing (inherit fields defined there)
+		storedCdr = self.headerCdr.Clone()
+	} else {
+		storedCdr = &engine.StoredCdr{CdrHost: "0.0.0.0", ExtraFields: make(map[string]string), Cost: -1}
+	}
+	switch cfgKey {
+	case "*header", "*trailer":
+		cfgFields = self.dfltCfg.HeaderFields
+		if cfgKey == "*trailer" {
+			cfgFields = self.dfltCfg.TrailerFields
+		}
+		storedCdr.CdrSource = self.dfltCfg.CdrSourceId
+		duMultiplyFactor = self.dfltCfg.DataUsageMultiplyFactor
+	default:
+		cfgFields = self.cdrcCfgs[cfgKey].ContentFields
+		storedCdr.CdrSource = self.cdrcCfgs[cfgKey].CdrSourceId
+		duMultiplyFactor = self.cdrcCfgs[cfgKey].DataUsageMultiplyFactor
+	}
+	valueAt := func(cdrFldCfg *config.CfgCdrField, fldId string) (string, bool, error) {
+		elmVal, hasIt := elm.valueAt(fldId)
+		return elmVal, hasIt, nil
+	}
+	if err := templateToStoredCdr(storedCdr, cfgFields, valueAt, self.processedRecordsNr, cfgKey != "*header" && cfgKey != "*trailer",
+		duMultiplyFactor, self.timezone, self.httpSkipTlsCheck); err != nil {
+		return nil, err
+	}
+	return storedCdr, nil
+}
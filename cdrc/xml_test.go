@@ -0,0 +1,162 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdrc
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+var xmlCdrs = `<?xml version="1.0" encoding="UTF-8"?>
+<File>
+	<FileHeader>
+		<Switch>sbc1</Switch>
+	</FileHeader>
+	<CDRs>
+		<CDR id="1">
+			<CallId>25160047719:0</CallId>
+			<Parties>
+				<Caller>1001</Caller>
+				<Called>+4986517174963</Called>
+			</Parties>
+			<StartTime>2016-01-13 09:01:51</StartTime>
+			<Duration>42</Duration>
+			<Status>answered</Status>
+		</CDR>
+		<CDR id="2">
+			<CallId>25160047719:1</CallId>
+			<Parties>
+				<Caller>1002</Caller>
+				<Called>1003</Called>
+			</Parties>
+			<StartTime>2016-01-13 09:02:51</StartTime>
+			<Duration>0</Duration>
+			<Status>failed</Status>
+		</CDR>
+		<CDR id="3">
+			<CallId>25160047719:2</CallId>
+			<Parties>
+				<Caller>1003</Caller>
+				<Called>1001</Called>
+			</Parties>
+			<StartTime>2016-01-13 09:03:51</StartTime>
+			<Duration>10</Duration>
+			<Status>answered</Status>
+		</CDR>
+	</CDRs>
+</File>
+`
+
+func TestXmlElementValueAt(t *testing.T) {
+	elm := &xmlElement{name: "CDR", children: []*xmlElement{
+		&xmlElement{name: "Parties", children: []*xmlElement{&xmlElement{name: "Caller", text: " 1001\n"}}}}}
+	if val, hasIt := elm.valueAt("Parties>Caller"); !hasIt || val != "1001" {
+		t.Errorf("Received: %q, %v", val, hasIt)
+	}
+	if _, hasIt := elm.valueAt("Parties>Called"); hasIt {
+		t.Error("Should not have value")
+	}
+}
+
+func TestXmlRecordsProcessor(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.CdrFormat = utils.XML
+	cdrcConfig.CdrSourceId = "TEST_CDRC"
+	cdrcConfig.CdrPath = "File>CDRs>CDR"
+	cdrcConfig.CdrFilter = utils.ParseRSRFieldsMustCompile("Status(answered)", utils.INFIELD_SEP)
+	cdrcConfig.HeaderFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "Switch", Type: utils.META_COMPOSED, FieldId: utils.TENANT, Value: utils.ParseRSRFieldsMustCompile("FileHeader>Switch", utils.INFIELD_SEP)}}
+	cdrcConfig.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "TOR", Type: utils.META_COMPOSED, FieldId: utils.TOR, Value: utils.ParseRSRFieldsMustCompile("^*voice", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("CallId;^_;@id", utils.INFIELD_SEP),
+			Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: utils.META_COMPOSED, FieldId: utils.ACCOUNT, Value: utils.ParseRSRFieldsMustCompile("Parties>Caller", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Destination", Type: utils.META_COMPOSED, FieldId: utils.DESTINATION,
+			Value: utils.ParseRSRFieldsMustCompile("~Parties>Called:s/^\\+49(\\d+)$/0${1}/", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "SetupTime", Type: utils.META_COMPOSED, FieldId: utils.SETUP_TIME, Value: utils.ParseRSRFieldsMustCompile("StartTime", utils.INFIELD_SEP)},
+		&config.CfgCdrField{Tag: "Usage", Type: utils.META_COMPOSED, FieldId: utils.USAGE, Value: utils.ParseRSRFieldsMustCompile("Duration", utils.INFIELD_SEP)},
+	}
+	xmlProcessor := NewXmlRecordsProcessor(strings.NewReader(xmlCdrs), cdrcConfig, map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, nil, false, "UTC")
+	var cdrs []*engine.StoredCdr
+	for {
+		recCdrs, err := xmlProcessor.ProcessNextRecord()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		cdrs = append(cdrs, recCdrs...)
+	}
+	if xmlProcessor.ProcessedRecordsNr() != 3 {
+		t.Errorf("Processed records: %d", xmlProcessor.ProcessedRecordsNr())
+	}
+	if len(cdrs) != 2 { // Failed call filtered out
+		t.Fatalf("Unexpected CDRs: %+v", cdrs)
+	}
+	eCdr := &engine.StoredCdr{CgrId: utils.Sha1("25160047719:0_1", time.Date(2016, 1, 13, 9, 1, 51, 0, time.UTC).String()), TOR: utils.VOICE, AccId: "25160047719:0_1", Tenant: "sbc1",
+		CdrHost: "0.0.0.0", CdrSource: "TEST_CDRC", Account: "1001", Destination: "086517174963", SetupTime: time.Date(2016, 1, 13, 9, 1, 51, 0, time.UTC),
+		Usage: time.Duration(42) * time.Second, ExtraFields: map[string]string{}, Cost: -1}
+	if cdrs[0].CgrId != eCdr.CgrId || cdrs[0].AccId != eCdr.AccId || cdrs[0].Tenant != eCdr.Tenant || cdrs[0].CdrSource != eCdr.CdrSource ||
+		cdrs[0].Account != eCdr.Account || cdrs[0].Destination != eCdr.Destination || !cdrs[0].SetupTime.Equal(eCdr.SetupTime) || cdrs[0].Usage != eCdr.Usage {
+		t.Errorf("Expecting: %+v, received: %+v", eCdr, cdrs[0])
+	}
+	if cdrs[1].AccId != "25160047719:2_3" || cdrs[1].Destination != "1001" {
+		t.Errorf("Unexpected CDR: %+v", cdrs[1])
+	}
+}
+
+func TestXmlTruncatedFile(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.CdrFormat = utils.XML
+	cdrcConfig.CdrPath = "File>CDRs>CDR"
+	cdrcConfig.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("CallId", utils.INFIELD_SEP), Mandatory: true}}
+	truncated := xmlCdrs[:strings.Index(xmlCdrs, "</CDR>")+len("</CDR>")+20]
+	xmlProcessor := NewXmlRecordsProcessor(strings.NewReader(truncated), cdrcConfig, map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, nil, false, "UTC")
+	if _, err := xmlProcessor.ProcessNextRecord(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := xmlProcessor.ProcessNextRecord(); err == nil {
+		t.Error("Truncated file processed")
+	} else if corruptErr, isCorrupt := err.(*CorruptFileError); !isCorrupt || corruptErr.RecordIdx != 2 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestXmlUnsupportedFieldType(t *testing.T) {
+	cgrConfig, _ := config.NewDefaultCGRConfig()
+	cdrcConfig := cgrConfig.CdrcProfiles["/var/log/cgrates/cdrc/in"][utils.META_DEFAULT].Clone()
+	cdrcConfig.CdrFormat = utils.XML
+	cdrcConfig.CdrPath = "File>CDRs>CDR"
+	cdrcConfig.ContentFields = []*config.CfgCdrField{
+		&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, FieldId: utils.ACCID, Value: utils.ParseRSRFieldsMustCompile("CallId", utils.INFIELD_SEP), Mandatory: true},
+		&config.CfgCdrField{Tag: "Account", Type: "*unknown", FieldId: utils.ACCOUNT, Value: utils.ParseRSRFieldsMustCompile("Parties>Caller", utils.INFIELD_SEP)}}
+	xmlProcessor := NewXmlRecordsProcessor(strings.NewReader(xmlCdrs), cdrcConfig, map[string]*config.CdrcConfig{utils.META_DEFAULT: cdrcConfig}, nil, false, "UTC")
+	if _, err := xmlProcessor.ProcessNextRecord(); err == nil || !strings.Contains(err.Error(), "Unsupported field type: *unknown") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
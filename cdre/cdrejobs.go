@@ -0,0 +1,193 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+	"github.com/gorhill/cronexpr"
+)
+
+func NewCdreJobs(cgrCfg *config.CGRConfig, cdrDb engine.CdrStorage) *CdreJobs {
+	return &CdreJobs{cgrCfg: cgrCfg, cdrDb: cdrDb, jobLocks: make(map[string]*sync.Mutex), stopChan: make(chan struct{})}
+}
+
+// Runs the configured CDR export jobs, keeping bookkeeping of each run in cdrDb
+type CdreJobs struct {
+	sync.Mutex // protects jobLocks
+	cgrCfg     *config.CGRConfig
+	cdrDb      engine.CdrStorage
+	jobLocks   map[string]*sync.Mutex // only one run of the same job at a time
+	stopChan   chan struct{}
+}
+
+// Schedules the configured jobs, blocking till Shutdown
+func (self *CdreJobs) ListenAndServe() error {
+	for _, job := range self.cgrCfg.CdreJobsCfg().Jobs {
+		schedule, err := cronexpr.Parse(job.Schedule)
+		if err != nil {
+			return fmt.Errorf("Invalid schedule for CDRE job %s: %s", job.Id, err.Error())
+		}
+		go self.scheduleJob(job.Id, schedule)
+	}
+	<-self.stopChan
+	return nil
+}
+
+func (self *CdreJobs) Shutdown() {
+	close(self.stopChan)
+}
+
+func (self *CdreJobs) scheduleJob(jobId string, schedule *cronexpr.Expression) {
+	for {
+		nextRun := schedule.Next(time.Now())
+		if nextRun.IsZero() {
+			utils.Logger.Warning(fmt.Sprintf("<CdreJobs> No more runs scheduled for job: %s", jobId))
+			return
+		}
+		select {
+		case <-self.stopChan:
+			return
+		case <-time.After(nextRun.Sub(time.Now())):
+		}
+		if run, err := self.RunJob(jobId); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<CdreJobs> Job: %s, error: %s", jobId, err.Error()))
+		} else {
+			utils.Logger.Info(fmt.Sprintf("<CdreJobs> Job: %s, export id: %s, exported CDRs: %d", jobId, run.ExportId, run.TotalRecords))
+		}
+	}
+}
+
+func (self *CdreJobs) jobLock(jobId string) *sync.Mutex {
+	self.Lock()
+	defer self.Unlock()
+	if _, hasIt := self.jobLocks[jobId]; !hasIt {
+		self.jobLocks[jobId] = new(sync.Mutex)
+	}
+	return self.jobLocks[jobId]
+}
+
+// Exports the CDRs not yet exported by previous runs of the job
+func (self *CdreJobs) RunJob(jobId string) (*engine.CdreRun, error) {
+	job := self.cgrCfg.CdreJobsCfg().Job(jobId)
+	if job == nil {
+		return nil, utils.ErrNotFound
+	}
+	jobLck := self.jobLock(jobId)
+	jobLck.Lock()
+	defer jobLck.Unlock()
+	runs, err := self.cdrDb.GetCdreRuns(jobId, nil)
+	if err != nil {
+		return nil, err
+	}
+	startTime := time.Now()
+	run := &engine.CdreRun{JobId: jobId, ExportId: fmt.Sprintf("%s_%d", jobId, startTime.UnixNano()), StartTime: startTime}
+	// Queried on OrderId so the cost stays the same no matter how many runs the job has, export marks are used only to rerun an export
+	return run, self.export(job, run, &utils.CdrsFilter{OrderIdStart: engine.CdreJobWatermark(runs) + 1})
+}
+
+// Exports again the CDRs covered by a past run, overwriting its export file
+func (self *CdreJobs) RerunExport(jobId, exportId string) (*engine.CdreRun, error) {
+	job := self.cgrCfg.CdreJobsCfg().Job(jobId)
+	if job == nil {
+		return nil, utils.ErrNotFound
+	}
+	jobLck := self.jobLock(jobId)
+	jobLck.Lock()
+	defer jobLck.Unlock()
+	runs, err := self.cdrDb.GetCdreRuns(jobId, nil)
+	if err != nil {
+		return nil, err
+	}
+	var run *engine.CdreRun
+	for _, pastRun := range runs {
+		if pastRun.ExportId == exportId {
+			run = pastRun
+			break
+		}
+	}
+	if run == nil {
+		return nil, utils.ErrNotFound
+	} else if len(run.Error) != 0 {
+		return nil, fmt.Errorf("Run failed with error: %s, its CDRs will be exported by the next run of the job", run.Error)
+	}
+	return run, self.export(job, run, &utils.CdrsFilter{ExportIds: []string{run.ExportId}})
+}
+
+// Exports the CDRs matching cdrsFltr, saving the run bookkeeping
+func (self *CdreJobs) export(job *config.CdreJobCfg, run *engine.CdreRun, cdrsFltr *utils.CdrsFilter) error {
+	cdreReloadStruct := <-self.cgrCfg.ConfigReloads[utils.CDRE] // Clone the template so exports do not block reloads
+	exportTpl, hasIt := self.cgrCfg.CdreProfiles[job.ExportTemplate]
+	if hasIt {
+		exportTpl = exportTpl.Clone()
+	}
+	self.cgrCfg.ConfigReloads[utils.CDRE] <- cdreReloadStruct
+	if !hasIt {
+		return fmt.Errorf("%s:ExportTemplate", utils.ErrNotFound.Error())
+	}
+	exportDir := exportTpl.ExportDir
+	if len(job.ExportDir) != 0 {
+		exportDir = job.ExportDir
+	}
+	filePath := path.Join(exportDir, fmt.Sprintf("cdre_%s.%s", run.ExportId, exportTpl.CdrFormat))
+	cdrexp, err := NewCdrStreamExporter(self.cdrDb, cdrsFltr, exportTpl.ExportPageSize,
+		exportTpl, exportTpl.CdrFormat, exportTpl.FieldSeparator, run.ExportId, exportTpl.DataUsageMultiplyFactor, exportTpl.SmsUsageMultiplyFactor,
+		exportTpl.GenericUsageMultiplyFactor, exportTpl.CostMultiplyFactor, exportTpl.CostShiftDigits, exportTpl.CostRoundingDecimals, self.cgrCfg.RoundingDecimals,
+		exportTpl.MaskDestId, exportTpl.MaskLength, self.cgrCfg.HttpSkipTlsVerify, self.cgrCfg.DefaultTimezone)
+	if err != nil {
+		return err
+	}
+	cdrexp.SetCdrFilter(job.CdrFilter)
+	cdrexp.SetMarkExports(true)
+	run.EndTime = time.Time{} // In progress
+	run.Error = ""
+	cdrexp.SetProgressHandler(func(processedCdrs, failedCdrs int) {
+		run.TotalRecords, run.FailedRecords = processedCdrs, failedCdrs
+		if err := self.cdrDb.SetCdreRun(run); err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<CdreJobs> Cannot save progress of job: %s, export id: %s, error: %s", run.JobId, run.ExportId, err.Error()))
+		}
+	})
+	err = cdrexp.ExportToFile(filePath)
+	run.EndTime = time.Now()
+	if err != nil {
+		run.Error = err.Error()
+	} else {
+		run.ExportedFilePath = ""
+		if cdrexp.TotalExportedCdrs() != 0 {
+			run.ExportedFilePath = cdrexp.ExportedFilePath()
+		}
+		run.OrderIdStart, run.LastOrderId = cdrexp.FirstOrderId(), cdrexp.LastOrderId()
+		run.TotalRecords = cdrexp.TotalExportedCdrs()
+		run.FailedRecords = cdrexp.FailedCdrs()
+		run.TotalCost = cdrexp.TotalCost()
+	}
+	if errSet := self.cdrDb.SetCdreRun(run); errSet != nil {
+		utils.Logger.Err(fmt.Sprintf("<CdreJobs> Cannot save run of job: %s, export id: %s, error: %s", run.JobId, run.ExportId, errSet.Error()))
+		if err == nil {
+			err = errSet
+		}
+	}
+	return err
+}
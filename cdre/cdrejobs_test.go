@@ -0,0 +1,128 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func (self *pagedCdrStorage) SetCdrsExportId(exportId string, cgrIds []string) error {
+	if self.exportIds == nil {
+		self.exportIds = make(map[string][]string)
+	}
+	for _, cgrId := range cgrIds {
+		if !utils.IsSliceMember(self.exportIds[exportId], cgrId) {
+			self.exportIds[exportId] = append(self.exportIds[exportId], cgrId)
+		}
+	}
+	return nil
+}
+
+func (self *pagedCdrStorage) SetCdreRun(run *engine.CdreRun) error {
+	for idx, pastRun := range self.runs {
+		if pastRun.ExportId == run.ExportId {
+			self.runs[idx] = run
+			return nil
+		}
+	}
+	self.runs = append(self.runs, run)
+	return nil
+}
+
+func (self *pagedCdrStorage) GetCdreRuns(jobId string, pag *utils.Paginator) (runs []*engine.CdreRun, err error) {
+	for _, run := range self.runs {
+		if len(jobId) == 0 || run.JobId == jobId {
+			clnRun := *run
+			runs = append(runs, &clnRun)
+		}
+	}
+	return
+}
+
+func TestCdreJobsRunJob(t *testing.T) {
+	cfg, _ := config.NewDefaultCGRConfig()
+	exportDir, err := ioutil.TempDir("", "cdre_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	cfg.CdreJobsCfg().Jobs = []*config.CdreJobCfg{&config.CdreJobCfg{Id: "daily", ExportTemplate: utils.META_DEFAULT,
+		CdrFilter: utils.ParseRSRFieldsMustCompile(`~MediationRunId:s/^\*default$//`, utils.INFIELD_SEP), Schedule: "0 1 * * *", ExportDir: exportDir}}
+	cdrDb := new(pagedCdrStorage)
+	addCdrs := func(fromOrderId, toOrderId int) {
+		for i := fromOrderId; i <= toOrderId; i++ {
+			for _, runId := range []string{utils.DEFAULT_RUNID, "run2"} {
+				cdrDb.cdrs = append(cdrDb.cdrs, &engine.StoredCdr{CgrId: utils.Sha1("dsafdsaf" + strconv.Itoa(i)), OrderId: int64(i), TOR: utils.VOICE,
+					AccId: "dsafdsaf" + strconv.Itoa(i), MediationRunId: runId, Account: "1001", Destination: "1002",
+					AnswerTime: time.Date(2013, 11, 7, 8, 42, 20+i, 0, time.UTC), Usage: time.Duration(10) * time.Second, Cost: 1.01})
+			}
+		}
+	}
+	addCdrs(1, 3)
+	cfg.CdreProfiles[utils.META_DEFAULT].ExportPageSize = 2
+	cdreJobs := NewCdreJobs(cfg, cdrDb)
+	if _, err := cdreJobs.RunJob("unknown"); err != utils.ErrNotFound {
+		t.Error(err)
+	}
+	run1, err := cdreJobs.RunJob("daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run1.OrderIdStart != 1 || run1.LastOrderId != 3 || run1.TotalRecords != 3 || len(run1.ExportedFilePath) == 0 || len(cdrDb.exportIds[run1.ExportId]) != 3 {
+		t.Errorf("Unexpected run: %+v", run1)
+	}
+	if runs, _ := cdrDb.GetCdreRuns("daily", nil); len(runs) != 1 || runs[0].EndTime.IsZero() || runs[0].FailedRecords != 0 {
+		t.Errorf("Unexpected runs: %s", utils.ToJSON(runs))
+	}
+	if run, err := cdreJobs.RunJob("daily"); err != nil { // Nothing new to export
+		t.Fatal(err)
+	} else if run.TotalRecords != 0 || len(run.ExportedFilePath) != 0 {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+	addCdrs(4, 5)
+	failedRun := &engine.CdreRun{JobId: "daily", ExportId: "daily_failed", OrderIdStart: 4, LastOrderId: 5, StartTime: time.Now(), EndTime: time.Now(),
+		Error: "SERVER_ERROR"}
+	if err := cdrDb.SetCdreRun(failedRun); err != nil {
+		t.Fatal(err)
+	}
+	if run, err := cdreJobs.RunJob("daily"); err != nil { // Failed run does not move the watermark
+		t.Fatal(err)
+	} else if run.OrderIdStart != 4 || run.LastOrderId != 5 || run.TotalRecords != 2 {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+	if runs, _ := cdrDb.GetCdreRuns("daily", nil); len(runs) != 4 || engine.CdreJobWatermark(runs) != 5 {
+		t.Errorf("Unexpected runs: %s", utils.ToJSON(runs))
+	}
+	if run, err := cdreJobs.RerunExport("daily", run1.ExportId); err != nil {
+		t.Fatal(err)
+	} else if run.OrderIdStart != 1 || run.LastOrderId != 3 || run.TotalRecords != 3 || run.ExportedFilePath != run1.ExportedFilePath {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+	if runs, _ := cdrDb.GetCdreRuns("daily", nil); len(runs) != 4 {
+		t.Errorf("Unexpected runs: %s", utils.ToJSON(runs))
+	}
+}
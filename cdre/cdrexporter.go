@@ -19,11 +19,11 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package cdre
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 	"time"
 
@@ -76,6 +76,7 @@ func NewCdrExporter(cdrs []*engine.StoredCdr, cdrDb engine.CdrStorage, exportTpl
 		timezone:                timezone,
 		maskLen:                 maskLen,
 		negativeExports:         make(map[string]string),
+		keepCgrIds:              true,
 	}
 	if err := cdre.processCdrs(); err != nil {
 		return nil, err
@@ -107,8 +108,21 @@ type CdrExporter struct {
 
 	totalCost                       float64
 	firstExpOrderId, lastExpOrderId int64
-	positiveExports                 []string          // CGRIds of successfully exported CDRs
-	negativeExports                 map[string]string // CgrIds of failed exports
+	positiveExports                 []string          // CGRIds of successfully exported CDRs, kept only if keepCgrIds
+	negativeExports                 map[string]string // CgrIds of failed exports, kept only if keepCgrIds
+	keepCgrIds                      bool              // Keep the CgrIds of the export in memory
+	maxKeptCgrIds                   int               // Limit of the CgrIds kept, 0 for unlimited
+	markExports                     bool              // Mark the exported CDRs in cdrDb with exportId, page by page on streaming exports
+	failedCdrs                      int               // Number of CDRs failing export
+	cdrsFltr                        *utils.CdrsFilter // Set on streaming exports, CDRs will be paged out of cdrDb
+	pageSize                        int               // Number of CDRs queried per page, 0 for all at once
+	cdrsLimit                       int               // Maximum number of CDRs to export, 0 for unlimited
+	cdrsFetched                     int               // Number of CDRs fetched so far from cdrDb
+	cdrsCursor                      string            // Cursor of the next page of CDRs, as returned by cdrDb
+	cdrFilter                       utils.RSRFields   // Only CDRs matching it are exported
+	progressHandler                 func(processedCdrs, failedCdrs int)
+	contentRecordsOut               int    // Content records written out so far, needed for separators of the structured formats
+	exportedFilePath                string // Final path of the written file, after compression suffix was added
 }
 
 // Return Json marshaled callCost attached to
@@ -414,11 +428,7 @@ func (cdre *CdrExporter) processCdr(cdr *engine.StoredCdr) error {
 // Builds header, content and trailers
 func (cdre *CdrExporter) processCdrs() error {
 	for _, cdr := range cdre.cdrs {
-		if err := cdre.processCdr(cdr); err != nil {
-			cdre.negativeExports[cdr.CgrId] = err.Error()
-		} else {
-			cdre.positiveExports = append(cdre.positiveExports, cdr.CgrId)
-		}
+		cdre.recordExport(cdr.CgrId, cdre.processCdr(cdr))
 	}
 	// Process header and trailer after processing cdrs since the metatag functions can access stats out of built cdrs
 	if cdre.exportTemplate.HeaderFields != nil {
@@ -484,23 +494,25 @@ func (cdre *CdrExporter) writeCsv(csvWriter *csv.Writer) error {
 
 // General method to write the content out to a file
 func (cdre *CdrExporter) WriteToFile(filePath string) error {
-	fileOut, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer fileOut.Close()
-	switch cdre.cdrFormat {
-	case utils.DRYRUN:
+	if cdre.cdrFormat == utils.DRYRUN {
 		return nil
-	case utils.CDRE_FIXED_WIDTH:
-		if err := cdre.writeOut(fileOut); err != nil {
-			return utils.NewErrServerError(err)
-		}
-	case utils.CSV:
-		csvWriter := csv.NewWriter(fileOut)
-		if err := cdre.writeCsv(csvWriter); err != nil {
-			return utils.NewErrServerError(err)
+	}
+	if err := cdre.writeExportFile(filePath, func(fileOut io.Writer) error {
+		switch cdre.cdrFormat {
+		case utils.CDRE_FIXED_WIDTH:
+			return cdre.writeOut(fileOut)
+		case utils.CSV:
+			return cdre.writeCsv(csv.NewWriter(fileOut))
+		case utils.JSON, utils.NDJSON, utils.XML:
+			content := new(bytes.Buffer)
+			if err := cdre.writeStructuredContent(content, cdre.content); err != nil {
+				return err
+			}
+			return cdre.writeStructuredOut(fileOut, content)
 		}
+		return nil
+	}); err != nil {
+		return utils.NewErrServerError(err)
 	}
 	return nil
 }
@@ -524,6 +536,24 @@ func (cdre *CdrExporter) TotalExportedCdrs() int {
 	return cdre.numberOfRecords
 }
 
+// Counts the outcome of exporting one CDR, keeping its CgrId only if requested
+func (cdre *CdrExporter) recordExport(cgrId string, err error) {
+	keep := cdre.keepCgrIds && (cdre.maxKeptCgrIds == 0 || len(cdre.positiveExports)+len(cdre.negativeExports) < cdre.maxKeptCgrIds)
+	if err != nil {
+		cdre.failedCdrs += 1
+		if keep {
+			cdre.negativeExports[cgrId] = err.Error()
+		}
+	} else if keep {
+		cdre.positiveExports = append(cdre.positiveExports, cgrId)
+	}
+}
+
+// Number of CDRs which failed export
+func (cdre *CdrExporter) FailedCdrs() int {
+	return cdre.failedCdrs
+}
+
 // Return successfully exported CgrIds
 func (cdre *CdrExporter) PositiveExports() []string {
 	return cdre.positiveExports
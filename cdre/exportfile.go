@@ -0,0 +1,157 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	MANIFEST_SUFFIX = ".manifest"
+	GZIP_SUFFIX     = ".gz"
+	ZIP_SUFFIX      = ".zip"
+)
+
+// Written next to the exported file so collectors can check it without parsing the export
+type ExportManifest struct {
+	ExportId          string
+	FileName          string // Name of the exported file, in the same directory with the manifest
+	CdrFormat         string
+	Compression       string
+	TotalRecords      int // Number of exported CDRs
+	FailedRecords     int // Number of CDRs failing export
+	TotalCost         float64
+	TotalDuration     time.Duration
+	TotalDataUsage    time.Duration
+	TotalSmsUsage     time.Duration
+	TotalGenericUsage time.Duration
+	FirstOrderId      int64
+	LastOrderId       int64
+	FirstCdrATime     time.Time
+	LastCdrATime      time.Time
+	Sha256            string // Checksum of the exported file as written on disk
+	CreatedAt         time.Time
+}
+
+// Writes data into a temporary file next to filePath and renames it when complete, so files picked up out of the export directory are never partial
+func writeFileAtomic(filePath string, writeOut func(io.Writer) error) error {
+	tmpFile, err := ioutil.TempFile(path.Dir(filePath), "."+path.Base(filePath))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name()) // No-op once renamed
+	fileWriter := bufio.NewWriter(tmpFile)
+	if err := writeOut(fileWriter); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := fileWriter.Flush(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil { // TempFile creates it readable by owner only
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+// Writes the export out to filePath, compressed as requested by the export template, followed by the manifest if configured
+func (cdre *CdrExporter) writeExportFile(filePath string, writeOut func(io.Writer) error) error {
+	var compression string
+	var withManifest bool
+	if cdre.exportTemplate != nil {
+		compression = cdre.exportTemplate.Compression
+		withManifest = cdre.exportTemplate.Manifest
+	}
+	exportedFilePath := filePath
+	switch compression {
+	case utils.GZIP:
+		exportedFilePath += GZIP_SUFFIX
+	case utils.ZIP:
+		exportedFilePath += ZIP_SUFFIX
+	}
+	hasher := sha256.New()
+	if err := writeFileAtomic(exportedFilePath, func(fileWriter io.Writer) error {
+		hashedWriter := io.MultiWriter(fileWriter, hasher)
+		switch compression {
+		case utils.GZIP:
+			gzWriter := gzip.NewWriter(hashedWriter)
+			gzWriter.Name = path.Base(filePath)
+			if err := writeOut(gzWriter); err != nil {
+				return err
+			}
+			return gzWriter.Close()
+		case utils.ZIP:
+			zipWriter := zip.NewWriter(hashedWriter)
+			zipMember, err := zipWriter.Create(path.Base(filePath))
+			if err != nil {
+				return err
+			}
+			if err := writeOut(zipMember); err != nil {
+				return err
+			}
+			return zipWriter.Close()
+		default:
+			return writeOut(hashedWriter)
+		}
+	}); err != nil {
+		return err
+	}
+	cdre.exportedFilePath = exportedFilePath
+	if !withManifest {
+		return nil
+	}
+	manifest := &ExportManifest{ExportId: cdre.exportId, FileName: path.Base(exportedFilePath), CdrFormat: cdre.cdrFormat, Compression: compression,
+		TotalRecords: cdre.numberOfRecords, FailedRecords: cdre.failedCdrs, TotalCost: cdre.totalCost,
+		TotalDuration: cdre.totalDuration, TotalDataUsage: cdre.totalDataUsage, TotalSmsUsage: cdre.totalSmsUsage, TotalGenericUsage: cdre.totalGenericUsage,
+		FirstOrderId: cdre.firstExpOrderId, LastOrderId: cdre.lastExpOrderId, FirstCdrATime: cdre.firstCdrATime, LastCdrATime: cdre.lastCdrATime,
+		Sha256: hex.EncodeToString(hasher.Sum(nil)), CreatedAt: time.Now()}
+	manifestJsn, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(exportedFilePath+MANIFEST_SUFFIX, func(fileWriter io.Writer) error {
+		_, err := fileWriter.Write(manifestJsn)
+		return err
+	})
+}
+
+// Path of the file written by the export, including the compression suffix, empty if nothing was written
+func (cdre *CdrExporter) ExportedFilePath() string {
+	return cdre.exportedFilePath
+}
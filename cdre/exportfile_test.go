@@ -0,0 +1,125 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestCdreCompressedExport(t *testing.T) {
+	cfg, _ := config.NewDefaultCGRConfig()
+	cdrDb := new(pagedCdrStorage)
+	for i := 1; i <= 2; i++ {
+		cdrDb.cdrs = append(cdrDb.cdrs, &engine.StoredCdr{CgrId: utils.Sha1("dsafdsaf" + strconv.Itoa(i)), OrderId: int64(i), TOR: utils.VOICE,
+			AccId: "dsafdsaf" + strconv.Itoa(i), MediationRunId: utils.DEFAULT_RUNID, Account: "1001", Destination: "1002",
+			AnswerTime: time.Date(2013, 11, 7, 8, 42, 20+i, 0, time.UTC), Usage: time.Duration(10) * time.Second, Cost: 1.01})
+	}
+	exportTpl := &config.CdreConfig{
+		Compression: utils.GZIP,
+		Manifest:    true,
+		ContentFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile(utils.ACCID, utils.INFIELD_SEP)}},
+	}
+	exportDir, err := ioutil.TempDir("", "cdre_compressed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	cdrexp, err := NewCdrStreamExporter(cdrDb, new(utils.CdrsFilter), 0, exportTpl, utils.CSV, ',', "compressed_1", 0.0, 0.0, 0.0, 0.0, 0, 2,
+		cfg.RoundingDecimals, "", 0, cfg.HttpSkipTlsVerify, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filePath := path.Join(exportDir, "cdre_compressed_1.csv")
+	if err := cdrexp.ExportToFile(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if cdrexp.ExportedFilePath() != filePath+GZIP_SUFFIX {
+		t.Errorf("Exported file path: %s", cdrexp.ExportedFilePath())
+	}
+	gzContent, err := ioutil.ReadFile(cdrexp.ExportedFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzFile, _ := os.Open(cdrexp.ExportedFilePath())
+	defer gzFile.Close()
+	gzReader, err := gzip.NewReader(gzFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadAll(gzReader); err != nil {
+		t.Error(err)
+	} else if string(content) != "dsafdsaf1\ndsafdsaf2\n" || gzReader.Name != "cdre_compressed_1.csv" {
+		t.Errorf("Unexpected content: %q, name: %s", string(content), gzReader.Name)
+	}
+	manifestJsn, err := ioutil.ReadFile(cdrexp.ExportedFilePath() + MANIFEST_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest ExportManifest
+	if err := json.Unmarshal(manifestJsn, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	checksum := sha256.Sum256(gzContent)
+	if manifest.FileName != "cdre_compressed_1.csv.gz" || manifest.TotalRecords != 2 || manifest.TotalCost != 2.02 || manifest.LastOrderId != 2 ||
+		manifest.Sha256 != hex.EncodeToString(checksum[:]) {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if files, _ := ioutil.ReadDir(exportDir); len(files) != 2 { // No temporary files left behind
+		t.Errorf("Files in export dir: %+v", files)
+	}
+	exportTpl.Compression = utils.ZIP
+	exportTpl.Manifest = false
+	cdrexp, _ = NewCdrStreamExporter(cdrDb, new(utils.CdrsFilter), 0, exportTpl, utils.CSV, ',', "compressed_2", 0.0, 0.0, 0.0, 0.0, 0, 2,
+		cfg.RoundingDecimals, "", 0, cfg.HttpSkipTlsVerify, "")
+	filePath = path.Join(exportDir, "cdre_compressed_2.csv")
+	if err := cdrexp.ExportToFile(filePath); err != nil {
+		t.Fatal(err)
+	}
+	zipReader, err := zip.OpenReader(filePath + ZIP_SUFFIX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zipReader.Close()
+	if len(zipReader.File) != 1 || zipReader.File[0].Name != "cdre_compressed_2.csv" {
+		t.Fatalf("Unexpected zip members: %+v", zipReader.File)
+	}
+	zipMember, _ := zipReader.File[0].Open()
+	defer zipMember.Close()
+	if content, _ := ioutil.ReadAll(zipMember); string(content) != "dsafdsaf1\ndsafdsaf2\n" {
+		t.Errorf("Unexpected content: %q", string(content))
+	}
+	if _, err := os.Stat(filePath + ZIP_SUFFIX + MANIFEST_SUFFIX); !os.IsNotExist(err) {
+		t.Error("Manifest written without being requested")
+	}
+}
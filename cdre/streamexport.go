@@ -0,0 +1,296 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	CDRE_MAX_KEPT_CGRIDS = 100000 // CgrIds kept in memory by a streaming export for reporting, the ones above are counted only
+	CDRE_MARKS_BATCH     = 1000   // CDRs marked at once when the export is not paged
+)
+
+// Streaming version of NewCdrExporter, the CDRs matching cdrsFltr are paged out of cdrDb by ExportToFile instead of being loaded upfront
+func NewCdrStreamExporter(cdrDb engine.CdrStorage, cdrsFltr *utils.CdrsFilter, pageSize int, exportTpl *config.CdreConfig, cdrFormat string, fieldSeparator rune, exportId string,
+	dataUsageMultiplyFactor, smsUsageMultiplyFactor, genericUsageMultiplyFactor, costMultiplyFactor float64,
+	costShiftDigits, roundDecimals, cgrPrecision int, maskDestId string, maskLen int, httpSkipTlsCheck bool, timezone string) (*CdrExporter, error) {
+	if cdrsFltr == nil {
+		return nil, errors.New("Undefined CDRs filter")
+	}
+	cdre := &CdrExporter{
+		cdrDb:                      cdrDb,
+		exportTemplate:             exportTpl,
+		cdrFormat:                  cdrFormat,
+		fieldSeparator:             fieldSeparator,
+		exportId:                   exportId,
+		dataUsageMultiplyFactor:    dataUsageMultiplyFactor,
+		smsUsageMultiplyFactor:     smsUsageMultiplyFactor,
+		genericUsageMultiplyFactor: genericUsageMultiplyFactor,
+		costMultiplyFactor:         costMultiplyFactor,
+		costShiftDigits:            costShiftDigits,
+		roundDecimals:              roundDecimals,
+		cgrPrecision:               cgrPrecision,
+		maskDestId:                 maskDestId,
+		httpSkipTlsCheck:           httpSkipTlsCheck,
+		timezone:                   timezone,
+		maskLen:                    maskLen,
+		cdrsFltr:                   cdrsFltr,
+		pageSize:                   pageSize,
+	}
+	if cdrsFltr.Paginator.Limit != nil { // Limit applies to the complete export, not to individual pages
+		cdre.cdrsLimit = *cdrsFltr.Paginator.Limit
+	}
+	return cdre, nil
+}
+
+// Called after each page of CDRs written out, useful to follow up long running exports
+func (cdre *CdrExporter) SetProgressHandler(hndlr func(processedCdrs, failedCdrs int)) {
+	cdre.progressHandler = hndlr
+}
+
+// Keeps the CgrIds of the export in memory so they can be reported back, up to CDRE_MAX_KEPT_CGRIDS
+func (cdre *CdrExporter) SetKeepCgrIds(keep bool) {
+	cdre.keepCgrIds = keep
+	cdre.maxKeptCgrIds = CDRE_MAX_KEPT_CGRIDS
+	if cdre.keepCgrIds && cdre.negativeExports == nil {
+		cdre.negativeExports = make(map[string]string)
+	}
+}
+
+// Marks the exported CDRs in cdrDb with the export id, once the export file is in place
+func (cdre *CdrExporter) SetMarkExports(mark bool) {
+	cdre.markExports = mark
+}
+
+// CDRs fetched but not passing the filter are skipped from export
+func (cdre *CdrExporter) SetCdrFilter(cdrFilter utils.RSRFields) {
+	cdre.cdrFilter = cdrFilter
+}
+
+// Checks the CDR against the cdrFilter
+func (cdre *CdrExporter) passesCdrFilter(cdr *engine.StoredCdr) bool {
+	for _, rsrFltr := range cdre.cdrFilter {
+		if pass, _ := cdr.PassesFieldFilter(rsrFltr); !pass {
+			return false
+		}
+	}
+	return true
+}
+
+// Queries the next page of CDRs out of cdrDb, lastPage signals there are no more CDRs to query after this one
+func (cdre *CdrExporter) nextCdrsPage() (cdrs []*engine.StoredCdr, lastPage bool, err error) {
+	qryFltr := *cdre.cdrsFltr
+	if cdre.pageSize <= 0 { // No paging, query everything at once
+		cdrs, _, err = cdre.cdrDb.GetStoredCdrs(&qryFltr)
+		cdre.cdrsFetched += len(cdrs)
+		return cdrs, true, err
+	}
+	limit := cdre.pageSize
+	if cdre.cdrsLimit != 0 {
+		if cdre.cdrsLimit-cdre.cdrsFetched <= 0 {
+			return nil, true, nil
+		} else if cdre.cdrsLimit-cdre.cdrsFetched < limit {
+			limit = cdre.cdrsLimit - cdre.cdrsFetched
+		}
+	}
+	qryFltr.Paginator.Limit = nil // Pages are sized by the exporter, offset only applies to the first one
+	if cdrs, cdre.cdrsCursor, err = cdre.cdrDb.GetStoredCdrsPage(&qryFltr, cdre.cdrsCursor, limit); err != nil {
+		return nil, true, err
+	}
+	cdre.cdrsFetched += len(cdrs)
+	return cdrs, cdre.cdrsCursor == "", nil
+}
+
+// Writes records in the format of the export, for the structured formats only content records are handled here
+func (cdre *CdrExporter) writeRecords(ioWriter io.Writer, records [][]string) error {
+	switch cdre.cdrFormat {
+	case utils.JSON, utils.NDJSON, utils.XML:
+		return cdre.writeStructuredContent(ioWriter, records)
+	case utils.CDRE_FIXED_WIDTH:
+		for _, record := range records {
+			if _, err := io.WriteString(ioWriter, strings.Join(record, "")+"\n"); err != nil {
+				return err
+			}
+		}
+	case utils.CSV:
+		csvWriter := csv.NewWriter(ioWriter)
+		csvWriter.Comma = cdre.fieldSeparator
+		return csvWriter.WriteAll(records)
+	}
+	return nil
+}
+
+// Pages through the CDRs in cdrDb and writes their content out as it goes.
+// Header and trailer depend on the stats of the complete export so they are added in a second pass, together with the content buffered on disk.
+func (cdre *CdrExporter) ExportToFile(filePath string) error {
+	if cdre.cdrsFltr == nil {
+		return errors.New("Not a streaming export")
+	}
+	var contentFile *os.File
+	var contentOut *bufio.Writer
+	if cdre.cdrFormat == utils.DRYRUN {
+		contentOut = bufio.NewWriter(ioutil.Discard)
+	} else {
+		var err error
+		if contentFile, err = ioutil.TempFile(path.Dir(filePath), "."+path.Base(filePath)); err != nil {
+			return utils.NewErrServerError(err)
+		}
+		defer func() {
+			contentFile.Close()
+			os.Remove(contentFile.Name())
+		}()
+		contentOut = bufio.NewWriter(contentFile)
+	}
+	var marksFile *os.File // CgrIds to mark, spooled so a failed export leaves its CDRs unmarked
+	var marksOut *bufio.Writer
+	if cdre.markExports {
+		var err error
+		if marksFile, err = ioutil.TempFile("", "cgr_cdre_marks"); err != nil {
+			return utils.NewErrServerError(err)
+		}
+		defer func() {
+			marksFile.Close()
+			os.Remove(marksFile.Name())
+		}()
+		marksOut = bufio.NewWriter(marksFile)
+	}
+	for lastPage := false; !lastPage; {
+		var err error
+		if cdre.cdrs, lastPage, err = cdre.nextCdrsPage(); err != nil {
+			return err
+		}
+		for _, cdr := range cdre.cdrs {
+			if !cdre.passesCdrFilter(cdr) {
+				continue
+			}
+			err := cdre.processCdr(cdr)
+			cdre.recordExport(cdr.CgrId, err)
+			if err == nil && cdre.markExports {
+				if _, err := marksOut.WriteString(cdr.CgrId + "\n"); err != nil {
+					return utils.NewErrServerError(err)
+				}
+			}
+		}
+		if err := cdre.writeRecords(contentOut, cdre.content); err != nil {
+			return utils.NewErrServerError(err)
+		}
+		cdre.content = nil
+		utils.Logger.Info(fmt.Sprintf("<Cdre> Export with id: %s, processed CDRs: %d, failed: %d", cdre.exportId, cdre.numberOfRecords, cdre.failedCdrs))
+		if cdre.progressHandler != nil {
+			cdre.progressHandler(cdre.numberOfRecords, cdre.failedCdrs)
+		}
+	}
+	cdre.cdrs = nil
+	// Stats are complete now, build header and trailer
+	if cdre.exportTemplate.HeaderFields != nil {
+		if err := cdre.composeHeader(); err != nil {
+			return err
+		}
+	}
+	if cdre.exportTemplate.TrailerFields != nil {
+		if err := cdre.composeTrailer(); err != nil {
+			return err
+		}
+	}
+	if cdre.numberOfRecords == 0 {
+		return nil
+	} else if cdre.cdrFormat == utils.DRYRUN {
+		cdre.exportedFilePath = filePath
+		return cdre.markSpooledExports(marksFile, marksOut)
+	}
+	if err := contentOut.Flush(); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if _, err := contentFile.Seek(0, 0); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if err := cdre.writeExportFile(filePath, func(fileWriter io.Writer) error {
+		if utils.IsSliceMember([]string{utils.JSON, utils.NDJSON, utils.XML}, cdre.cdrFormat) {
+			return cdre.writeStructuredOut(fileWriter, contentFile)
+		}
+		if len(cdre.header) != 0 {
+			if err := cdre.writeRecords(fileWriter, [][]string{cdre.header}); err != nil {
+				return err
+			}
+		}
+		if _, err := io.Copy(fileWriter, contentFile); err != nil {
+			return err
+		}
+		if len(cdre.trailer) != 0 {
+			return cdre.writeRecords(fileWriter, [][]string{cdre.trailer})
+		}
+		return nil
+	}); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	return cdre.markSpooledExports(marksFile, marksOut)
+}
+
+// Marks the CgrIds spooled by ExportToFile, in batches of one page
+func (cdre *CdrExporter) markSpooledExports(marksFile *os.File, marksOut *bufio.Writer) error {
+	if !cdre.markExports {
+		return nil
+	}
+	if err := marksOut.Flush(); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if _, err := marksFile.Seek(0, 0); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	batchSize := cdre.pageSize
+	if batchSize <= 0 {
+		batchSize = CDRE_MARKS_BATCH
+	}
+	cgrIds := make([]string, 0, batchSize)
+	scanner := bufio.NewScanner(marksFile)
+	for scanner.Scan() {
+		cgrIds = append(cgrIds, scanner.Text())
+		if len(cgrIds) == batchSize {
+			if err := cdre.cdrDb.SetCdrsExportId(cdre.exportId, cgrIds); err != nil {
+				return utils.NewErrServerError(err)
+			}
+			cgrIds = cgrIds[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	if err := cdre.cdrDb.SetCdrsExportId(cdre.exportId, cgrIds); err != nil {
+		return utils.NewErrServerError(err)
+	}
+	return nil
+}
+
+// Number of CDRs fetched out of cdrDb, including the ones failing export
+func (cdre *CdrExporter) FetchedCdrs() int {
+	return cdre.cdrsFetched
+}
@@ -0,0 +1,164 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// CdrStorage paging over a slice of CDRs ordered by OrderId
+type pagedCdrStorage struct {
+	engine.CdrStorage
+	cdrs      []*engine.StoredCdr
+	queries   int
+	runs      []*engine.CdreRun
+	exportIds map[string][]string
+}
+
+func (self *pagedCdrStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*engine.StoredCdr, int64, error) {
+	self.queries += 1
+	var cdrs []*engine.StoredCdr
+	for _, cdr := range self.cdrs {
+		if cdr.OrderId < qryFltr.OrderIdStart || (qryFltr.OrderIdEnd != 0 && cdr.OrderId >= qryFltr.OrderIdEnd) {
+			continue
+		}
+		if !self.passesExportIds(cdr.CgrId, qryFltr) {
+			continue
+		}
+		if qryFltr.Paginator.Limit != nil && len(cdrs) == *qryFltr.Paginator.Limit {
+			break
+		}
+		clnCdr := *cdr
+		cdrs = append(cdrs, &clnCdr)
+	}
+	return cdrs, 0, nil
+}
+
+// Checks the export marks of the CDR against ExportIds
+func (self *pagedCdrStorage) passesExportIds(cgrId string, qryFltr *utils.CdrsFilter) bool {
+	if len(qryFltr.ExportIds) == 0 {
+		return true
+	}
+	for _, exportId := range qryFltr.ExportIds {
+		if utils.IsSliceMember(self.exportIds[exportId], cgrId) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *pagedCdrStorage) GetStoredCdrsPage(qryFltr *utils.CdrsFilter, cursor string, pageSize int) ([]*engine.StoredCdr, string, error) {
+	return engine.PageStoredCdrsOnOrderId(self.GetStoredCdrs, qryFltr, cursor, pageSize)
+}
+
+func TestCdreStreamExport(t *testing.T) {
+	cfg, _ := config.NewDefaultCGRConfig()
+	cdrDb := new(pagedCdrStorage)
+	for i := 1; i <= 5; i++ {
+		for _, runId := range []string{utils.DEFAULT_RUNID, "run2"} { // Two CDRs derived out of the same primary one, sharing OrderId
+			cdrDb.cdrs = append(cdrDb.cdrs, &engine.StoredCdr{CgrId: utils.Sha1("dsafdsaf" + strconv.Itoa(i)), OrderId: int64(i), TOR: utils.VOICE,
+				AccId: "dsafdsaf" + strconv.Itoa(i), MediationRunId: runId, Account: "1001", Destination: "1002",
+				AnswerTime: time.Date(2013, 11, 7, 8, 42, 20+i, 0, time.UTC), Usage: time.Duration(10) * time.Second, Cost: 1.01})
+		}
+	}
+	exportTpl := &config.CdreConfig{
+		HeaderFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "NrCdrs", Type: utils.META_HANDLER, Value: utils.ParseRSRFieldsMustCompile(META_NRCDRS, utils.INFIELD_SEP)}},
+		ContentFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile(utils.ACCID, utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "RunId", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile(utils.MEDI_RUNID, utils.INFIELD_SEP)}},
+		TrailerFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "Cost", Type: utils.META_HANDLER, Value: utils.ParseRSRFieldsMustCompile(META_COSTCDRS, utils.INFIELD_SEP)}},
+	}
+	exportDir, err := ioutil.TempDir("", "cdre_stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	cdrexp, err := NewCdrStreamExporter(cdrDb, new(utils.CdrsFilter), 3, exportTpl, utils.CSV, ',', "stream_1", 0.0, 0.0, 0.0, 0.0, 0, 2,
+		cfg.RoundingDecimals, "", 0, cfg.HttpSkipTlsVerify, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdrexp.SetKeepCgrIds(true)
+	var progressCalls int
+	cdrexp.SetProgressHandler(func(processedCdrs, failedCdrs int) { progressCalls += 1 })
+	filePath := path.Join(exportDir, "cdre_stream_1.csv")
+	if err := cdrexp.ExportToFile(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if cdrDb.queries != 5 || progressCalls != 5 { // Pages of 3 are trimmed to 2 so derived CDRs do not get split
+		t.Errorf("Queries: %d, progress calls: %d", cdrDb.queries, progressCalls)
+	}
+	if cdrexp.TotalExportedCdrs() != 10 || cdrexp.FetchedCdrs() != 10 || cdrexp.FirstOrderId() != 1 || cdrexp.LastOrderId() != 5 {
+		t.Errorf("Exported: %d, fetched: %d, first: %d, last: %d", cdrexp.TotalExportedCdrs(), cdrexp.FetchedCdrs(), cdrexp.FirstOrderId(), cdrexp.LastOrderId())
+	}
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 12 || lines[0] != "10" || lines[1] != "dsafdsaf1,*default" || lines[10] != "dsafdsaf5,run2" || lines[11] != "10.1" {
+		t.Errorf("Unexpected content: %s", string(content))
+	}
+	if len(cdrexp.PositiveExports()) != 10 || len(cdrexp.NegativeExports()) != 0 || cdrexp.FailedCdrs() != 0 {
+		t.Errorf("Exported CgrIds: %+v, failed: %+v", cdrexp.PositiveExports(), cdrexp.NegativeExports())
+	}
+	if files, _ := ioutil.ReadDir(exportDir); len(files) != 1 { // Temporary content file should be gone
+		t.Errorf("Files in export dir: %+v", files)
+	}
+	limit := 4
+	cdrexp, _ = NewCdrStreamExporter(cdrDb, &utils.CdrsFilter{OrderIdStart: 2, Paginator: utils.Paginator{Limit: &limit}}, 3, exportTpl, utils.DRYRUN, ',', "stream_2",
+		0.0, 0.0, 0.0, 0.0, 0, 2, cfg.RoundingDecimals, "", 0, cfg.HttpSkipTlsVerify, "")
+	cdrexp.SetKeepCgrIds(true)
+	cdrexp.SetMarkExports(true)
+	if err := cdrexp.ExportToFile(utils.DRYRUN); err != nil {
+		t.Fatal(err)
+	}
+	if cdrexp.TotalExportedCdrs() != 4 || cdrexp.FirstOrderId() != 2 || cdrexp.LastOrderId() != 3 {
+		t.Errorf("Exported: %d, first: %d, last: %d", cdrexp.TotalExportedCdrs(), cdrexp.FirstOrderId(), cdrexp.LastOrderId())
+	}
+	if len(cdrexp.PositiveExports()) != 4 || len(cdrDb.exportIds["stream_2"]) != 2 { // Derived CDRs are marked once
+		t.Errorf("Exported CgrIds: %+v, marked: %+v", cdrexp.PositiveExports(), cdrDb.exportIds)
+	}
+	// Export file not published, its CDRs should stay unmarked
+	blockedPath := path.Join(exportDir, "cdre_stream_3.csv")
+	if err := os.MkdirAll(path.Join(blockedPath, "busy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cdrexp, _ = NewCdrStreamExporter(cdrDb, new(utils.CdrsFilter), 3, exportTpl, utils.CSV, ',', "stream_3",
+		0.0, 0.0, 0.0, 0.0, 0, 2, cfg.RoundingDecimals, "", 0, cfg.HttpSkipTlsVerify, "")
+	cdrexp.SetMarkExports(true)
+	if err := cdrexp.ExportToFile(blockedPath); err == nil {
+		t.Error("Export published over a directory")
+	}
+	if marked := cdrDb.exportIds["stream_3"]; len(marked) != 0 {
+		t.Errorf("Marked CgrIds of a failed export: %+v", marked)
+	}
+}
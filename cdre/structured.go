@@ -0,0 +1,233 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	XML_ROOT_ELEMENT    = "CDRs"
+	XML_HEADER_ELEMENT  = "Header"
+	XML_CDR_ELEMENT     = "CDR"
+	XML_TRAILER_ELEMENT = "Trailer"
+)
+
+// Node in the tree built out of one exported record, keeps the order of the template fields
+type recordNode struct {
+	name     string
+	value    string
+	children []*recordNode
+}
+
+// Returns the child with the given name, creating it if not there
+func (self *recordNode) child(name string) *recordNode {
+	for _, chld := range self.children {
+		if chld.name == name {
+			return chld
+		}
+	}
+	chld := &recordNode{name: name}
+	self.children = append(self.children, chld)
+	return chld
+}
+
+// Builds the tree of a record, the path of each value is given by the FieldId of its template field (Tag if missing), with levels separated by utils.HIERARCHY_SEP
+func newRecordTree(record []string, cfgFlds []*config.CfgCdrField) *recordNode {
+	root := new(recordNode)
+	for idx, cfgFld := range cfgFlds {
+		if idx >= len(record) {
+			break
+		}
+		fldPath := cfgFld.FieldId
+		if len(fldPath) == 0 {
+			fldPath = cfgFld.Tag
+		}
+		node := root
+		for _, elmName := range strings.Split(fldPath, utils.HIERARCHY_SEP) {
+			node = node.child(elmName)
+		}
+		node.value = record[idx]
+	}
+	return root
+}
+
+// Writes str as JSON string, without escaping the HTML characters json.Marshal does
+func writeJsonString(buf *bytes.Buffer, str string) error {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(str); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode terminates with new line
+	return nil
+}
+
+func (self *recordNode) writeJson(buf *bytes.Buffer) error {
+	if len(self.children) == 0 {
+		return writeJsonString(buf, self.value)
+	}
+	buf.WriteByte('{')
+	for idx, chld := range self.children {
+		if idx != 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJsonString(buf, chld.name); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := chld.writeJson(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func (self *recordNode) writeXml(buf *bytes.Buffer) error {
+	buf.WriteString("<" + self.name + ">")
+	if len(self.children) == 0 {
+		if err := xml.EscapeText(buf, []byte(self.value)); err != nil {
+			return err
+		}
+	}
+	for _, chld := range self.children {
+		if err := chld.writeXml(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteString("</" + self.name + ">")
+	return nil
+}
+
+// Formats one record as JSON object or XML element with the name elmName
+func (cdre *CdrExporter) formatStructuredRecord(record []string, cfgFlds []*config.CfgCdrField, elmName string) ([]byte, error) {
+	root := newRecordTree(record, cfgFlds)
+	buf := new(bytes.Buffer)
+	switch cdre.cdrFormat {
+	case utils.JSON, utils.NDJSON:
+		if len(root.children) == 0 {
+			buf.WriteString("{}")
+		} else if err := root.writeJson(buf); err != nil {
+			return nil, err
+		}
+	case utils.XML:
+		root.name = elmName
+		if err := root.writeXml(buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Writes content records in one of the structured formats, separators between records depending on the ones already written
+func (cdre *CdrExporter) writeStructuredContent(ioWriter io.Writer, records [][]string) error {
+	for _, record := range records {
+		recOut, err := cdre.formatStructuredRecord(record, cdre.exportTemplate.ContentFields, XML_CDR_ELEMENT)
+		if err != nil {
+			return err
+		}
+		if cdre.cdrFormat == utils.JSON && cdre.contentRecordsOut != 0 {
+			recOut = append([]byte(",\n"), recOut...)
+		} else if cdre.cdrFormat != utils.JSON {
+			recOut = append(recOut, '\n')
+		}
+		if _, err := ioWriter.Write(recOut); err != nil {
+			return err
+		}
+		cdre.contentRecordsOut += 1
+	}
+	return nil
+}
+
+// Writes out the complete export in one of the structured formats, content being already formatted by writeStructuredContent.
+// JSON is an array with header and trailer objects around the CDR ones, NDJSON one object per line and XML the CDR elements wrapped by the root one.
+func (cdre *CdrExporter) writeStructuredOut(ioWriter io.Writer, content io.Reader) error {
+	var hdrOut, trlOut []byte
+	var err error
+	if len(cdre.header) != 0 {
+		if hdrOut, err = cdre.formatStructuredRecord(cdre.header, cdre.exportTemplate.HeaderFields, XML_HEADER_ELEMENT); err != nil {
+			return err
+		}
+	}
+	if len(cdre.trailer) != 0 {
+		if trlOut, err = cdre.formatStructuredRecord(cdre.trailer, cdre.exportTemplate.TrailerFields, XML_TRAILER_ELEMENT); err != nil {
+			return err
+		}
+	}
+	out := new(bytes.Buffer) // Prologue, written before content
+	switch cdre.cdrFormat {
+	case utils.JSON:
+		out.WriteString("[\n")
+		if hdrOut != nil {
+			out.Write(hdrOut)
+			if cdre.contentRecordsOut != 0 || trlOut != nil {
+				out.WriteString(",\n")
+			}
+		}
+	case utils.NDJSON:
+		if hdrOut != nil {
+			out.Write(hdrOut)
+			out.WriteByte('\n')
+		}
+	case utils.XML:
+		out.WriteString(xml.Header)
+		out.WriteString("<" + XML_ROOT_ELEMENT + ">\n")
+		if hdrOut != nil {
+			out.Write(hdrOut)
+			out.WriteByte('\n')
+		}
+	}
+	if _, err := out.WriteTo(ioWriter); err != nil {
+		return err
+	}
+	if _, err := io.Copy(ioWriter, content); err != nil {
+		return err
+	}
+	switch cdre.cdrFormat { // Epilogue
+	case utils.JSON:
+		if trlOut != nil {
+			if cdre.contentRecordsOut != 0 {
+				out.WriteString(",\n")
+			}
+			out.Write(trlOut)
+		}
+		out.WriteString("\n]\n")
+	case utils.NDJSON:
+		if trlOut != nil {
+			out.Write(trlOut)
+			out.WriteByte('\n')
+		}
+	case utils.XML:
+		if trlOut != nil {
+			out.Write(trlOut)
+			out.WriteByte('\n')
+		}
+		out.WriteString("</" + XML_ROOT_ELEMENT + ">\n")
+	}
+	_, err = out.WriteTo(ioWriter)
+	return err
+}
@@ -0,0 +1,109 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package cdre
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestCdreStructuredFormats(t *testing.T) {
+	cfg, _ := config.NewDefaultCGRConfig()
+	cdrDb := new(pagedCdrStorage)
+	for i := 1; i <= 3; i++ {
+		cdrDb.cdrs = append(cdrDb.cdrs, &engine.StoredCdr{CgrId: utils.Sha1("dsafdsaf" + strconv.Itoa(i)), OrderId: int64(i), TOR: utils.VOICE,
+			AccId: "dsafdsaf" + strconv.Itoa(i), MediationRunId: utils.DEFAULT_RUNID, Account: "1001", Destination: "1002&3",
+			AnswerTime: time.Date(2013, 11, 7, 8, 42, 20+i, 0, time.UTC), Usage: time.Duration(10) * time.Second, Cost: 1.01})
+	}
+	exportTpl := &config.CdreConfig{
+		HeaderFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "NrCdrs", Type: utils.META_HANDLER, Value: utils.ParseRSRFieldsMustCompile(META_NRCDRS, utils.INFIELD_SEP)}},
+		ContentFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "AccId", Type: utils.META_COMPOSED, Value: utils.ParseRSRFieldsMustCompile(utils.ACCID, utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "Account", FieldId: "Parties>Caller", Type: utils.META_COMPOSED,
+				Value: utils.ParseRSRFieldsMustCompile(utils.ACCOUNT, utils.INFIELD_SEP)},
+			&config.CfgCdrField{Tag: "Destination", FieldId: "Parties>Called", Type: utils.META_COMPOSED,
+				Value: utils.ParseRSRFieldsMustCompile(utils.DESTINATION, utils.INFIELD_SEP)}},
+		TrailerFields: []*config.CfgCdrField{
+			&config.CfgCdrField{Tag: "Cost", Type: utils.META_HANDLER, Value: utils.ParseRSRFieldsMustCompile(META_COSTCDRS, utils.INFIELD_SEP)}},
+	}
+	exportDir, err := ioutil.TempDir("", "cdre_structured")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(exportDir)
+	exportFile := func(cdrFormat string) string {
+		cdrexp, err := NewCdrStreamExporter(cdrDb, new(utils.CdrsFilter), 2, exportTpl, cdrFormat, ',', "structured_"+cdrFormat, 0.0, 0.0, 0.0, 0.0, 0, 2,
+			cfg.RoundingDecimals, "", 0, cfg.HttpSkipTlsVerify, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		filePath := path.Join(exportDir, "cdre."+cdrFormat)
+		if err := cdrexp.ExportToFile(filePath); err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	var jsnOut []map[string]interface{}
+	if err := json.Unmarshal([]byte(exportFile(utils.JSON)), &jsnOut); err != nil {
+		t.Fatal(err)
+	}
+	if len(jsnOut) != 5 || jsnOut[0]["NrCdrs"] != "3" || jsnOut[4]["Cost"] != "3.03" || jsnOut[3]["AccId"] != "dsafdsaf3" ||
+		jsnOut[1]["Parties"].(map[string]interface{})["Called"] != "1002&3" {
+		t.Errorf("Unexpected JSON export: %+v", jsnOut)
+	}
+	lines := strings.Split(strings.TrimSpace(exportFile(utils.NDJSON)), "\n")
+	if len(lines) != 5 || lines[0] != `{"NrCdrs":"3"}` || lines[1] != `{"AccId":"dsafdsaf1","Parties":{"Caller":"1001","Called":"1002&3"}}` ||
+		lines[4] != `{"Cost":"3.03"}` {
+		t.Errorf("Unexpected NDJSON export: %+v", lines)
+	}
+	xmlOut := exportFile(utils.XML)
+	if !strings.Contains(xmlOut, "<CDR><AccId>dsafdsaf2</AccId><Parties><Caller>1001</Caller><Called>1002&amp;3</Called></Parties></CDR>") {
+		t.Errorf("Unexpected XML export: %s", xmlOut)
+	}
+	var xmlCdrs struct {
+		Header struct{ NrCdrs string }
+		CDR    []struct {
+			AccId   string
+			Parties struct{ Caller, Called string }
+		}
+		Trailer struct{ Cost string }
+	}
+	if err := xml.Unmarshal([]byte(xmlOut), &xmlCdrs); err != nil {
+		t.Fatal(err)
+	}
+	if xmlCdrs.Header.NrCdrs != "3" || len(xmlCdrs.CDR) != 3 || xmlCdrs.CDR[2].Parties.Called != "1002&3" || xmlCdrs.Trailer.Cost != "3.03" {
+		t.Errorf("Unexpected XML export: %+v", xmlCdrs)
+	}
+}
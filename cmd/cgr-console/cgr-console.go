@@ -19,6 +19,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package main
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -39,7 +40,14 @@ var (
 	verbose      = flag.Bool("verbose", false, "Show extra info about command execution.")
 	server       = flag.String("server", "127.0.0.1:2012", "server address host:port")
 	rpc_encoding = flag.String("rpc_encoding", "json", "RPC encoding used <gob|json>")
-	client       *rpcclient.RpcClient
+	useTLS       = flag.Bool("tls", false, "Connect over TLS")
+	tlsCA        = flag.String("tls_ca", "", "Path to the PEM CAs verifying the server certificate, system ones if empty")
+	tlsCert      = flag.String("tls_certificate", "", "Path to the PEM client certificate, for servers requiring one")
+	tlsKey       = flag.String("tls_key", "", "Path to the PEM private key of the client certificate")
+	apiKey       = flag.String("api_key", "", "API key to authenticate with")
+	username     = flag.String("username", "", "Username to authenticate with")
+	password     = flag.String("password", "", "Password of the username")
+	client       rpcclient.RpcClientConnection
 )
 
 func executeCommand(command string) {
@@ -109,7 +117,21 @@ func main() {
 		return
 	}
 	var err error
-	client, err = rpcclient.NewRpcClient("tcp", *server, 3, 3, *rpc_encoding, nil)
+	if *useTLS || len(*apiKey) != 0 || len(*username) != 0 {
+		var tlsCfg *tls.Config
+		if *useTLS {
+			if tlsCfg, err = utils.NewClientTLSConfig(*tlsCA, *tlsCert, *tlsKey); err != nil {
+				log.Fatal("Could not configure TLS: " + err.Error())
+			}
+		}
+		var creds *utils.AttrAuthenticate
+		if len(*apiKey) != 0 || len(*username) != 0 {
+			creds = &utils.AttrAuthenticate{ApiKey: *apiKey, Username: *username, Password: *password}
+		}
+		client, err = utils.NewAuthRpcClient(*server, 3, 3, *rpc_encoding, tlsCfg, creds)
+	} else {
+		client, err = rpcclient.NewRpcClient("tcp", *server, 3, 3, *rpc_encoding, nil)
+	}
 	if err != nil {
 		flag.PrintDefaults()
 		log.Fatal("Could not connect to server " + *server)
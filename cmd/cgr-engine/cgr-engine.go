@@ -19,6 +19,7 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
@@ -71,6 +72,25 @@ var (
 	err   error
 )
 
+// Connection towards another engine, over TLS and authenticated as configured in rpc_client
+func newRpcClient(addr string, reconnects int) (rpcclient.RpcClientConnection, error) {
+	var creds *utils.AttrAuthenticate
+	if len(cfg.RpcClientApiKey) != 0 || len(cfg.RpcClientUsername) != 0 {
+		creds = &utils.AttrAuthenticate{ApiKey: cfg.RpcClientApiKey, Username: cfg.RpcClientUsername, Password: cfg.RpcClientPassword}
+	}
+	if !cfg.RpcClientTLS && creds == nil {
+		return rpcclient.NewRpcClient("tcp", addr, cfg.ConnectAttempts, reconnects, utils.GOB, nil)
+	}
+	var tlsCfg *tls.Config
+	if cfg.RpcClientTLS {
+		var err error
+		if tlsCfg, err = utils.NewClientTLSConfig(cfg.RpcClientTLSCA, cfg.RpcClientTLSCertificate, cfg.RpcClientTLSKey); err != nil {
+			return nil, err
+		}
+	}
+	return utils.NewAuthRpcClient(addr, cfg.ConnectAttempts, reconnects, utils.GOB, tlsCfg, creds)
+}
+
 func startCdrcs(internalCdrSChan chan *engine.CdrServer, internalRaterChan chan *engine.Responder, exitChan chan bool) {
 	cdrcInitialized := false           // Control whether the cdrc was already initialized (so we don't reload in that case)
 	var cdrcChildrenChan chan struct{} // Will use it to communicate with the children of one fork
@@ -116,7 +136,7 @@ func startCdrc(internalCdrSChan chan *engine.CdrServer, internalRaterChan chan *
 		cdrsConn = resp
 		internalRaterChan <- resp
 	} else {
-		conn, err := rpcclient.NewRpcClient("tcp", cdrcCfg.Cdrs, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+		conn, err := newRpcClient(cdrcCfg.Cdrs, cfg.Reconnects)
 		if err != nil {
 			utils.Logger.Crit(fmt.Sprintf("<CDRC> Could not connect to CDRS via RPC: %v", err))
 			exitChan <- true
@@ -136,10 +156,11 @@ func startCdrc(internalCdrSChan chan *engine.CdrServer, internalRaterChan chan *
 	}
 }
 
-func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internalRaterChan chan *engine.Responder, server *utils.Server, exitChan chan bool) {
+func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internalRaterChan chan *engine.Responder, internalPubSubSChan chan engine.PublisherSubscriber,
+	server *utils.Server, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS SM-Generic service.")
 	var raterConn, cdrsConn engine.Connector
-	var client *rpcclient.RpcClient
+	var client rpcclient.RpcClientConnection
 	var err error
 	// Connect to rater
 	for _, raterCfg := range cfg.SmGenericConfig.HaRater {
@@ -148,7 +169,7 @@ func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internal
 			raterConn = resp // Will overwrite here for the sake of keeping internally the new configuration format for ha connections
 			internalRaterChan <- resp
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", raterCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(raterCfg.Server, cfg.Reconnects)
 			if err != nil { //Connected so no need to reiterate
 				utils.Logger.Crit(fmt.Sprintf("<SM-Generic> Could not connect to Rater via RPC: %v", err))
 				exitChan <- true
@@ -167,7 +188,7 @@ func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internal
 				cdrsConn = resp
 				internalRaterChan <- resp
 			} else {
-				client, err = rpcclient.NewRpcClient("tcp", cdrsCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+				client, err = newRpcClient(cdrsCfg.Server, cfg.Reconnects)
 				if err != nil {
 					utils.Logger.Crit(fmt.Sprintf("<SM-Generic> Could not connect to CDRS via RPC: %v", err))
 					exitChan <- true
@@ -177,8 +198,23 @@ func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internal
 			}
 		}
 	}
+	// Connect to PubSub, used for session warnings
+	var pubSubConn engine.PublisherSubscriber
+	if cfg.SmGenericConfig.PubSubs == utils.INTERNAL {
+		pubSubs := <-internalPubSubSChan
+		pubSubConn = pubSubs
+		internalPubSubSChan <- pubSubs
+	} else if len(cfg.SmGenericConfig.PubSubs) != 0 {
+		client, err = newRpcClient(cfg.SmGenericConfig.PubSubs, cfg.Reconnects)
+		if err != nil {
+			utils.Logger.Crit(fmt.Sprintf("<SM-Generic> Could not connect to pubsub server: %s", err.Error()))
+			exitChan <- true
+			return
+		}
+		pubSubConn = &engine.ProxyPubSub{Client: client}
+	}
 	smg_econns := sessionmanager.NewSMGExternalConnections()
-	sm := sessionmanager.NewSMGeneric(cfg, raterConn, cdrsConn, cfg.DefaultTimezone, smg_econns)
+	sm := sessionmanager.NewSMGeneric(cfg, raterConn, cdrsConn, pubSubConn, cfg.DefaultTimezone, smg_econns)
 	if err = sm.Connect(); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<SM-Generic> error: %s!", err))
 	}
@@ -194,40 +230,72 @@ func startSmGeneric(internalSMGChan chan rpcclient.RpcClientConnection, internal
 	// Register OnConnect handlers so we can intercept connections for session disconnects
 	server.BijsonRegisterOnConnect(smg_econns.OnClientConnect)
 	server.BijsonRegisterOnDisconnect(smg_econns.OnClientDisconnect)
+	if len(cfg.SmGenericConfig.ListenBijson) != 0 { // Needed by clients receiving session disconnects, eg: RadiusAgent
+		go server.ServeBiJSON(cfg.SmGenericConfig.ListenBijson)
+	}
 }
 
-func startDiameterAgent(internalSMGChan chan rpcclient.RpcClientConnection, exitChan chan bool) {
+func startDiameterAgent(internalSMGChan chan rpcclient.RpcClientConnection, server *utils.Server, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS DiameterAgent service.")
-	var smgConn *rpcclient.RpcClient
-	var err error
-	if cfg.DiameterAgentCfg().SMGeneric == utils.INTERNAL {
-		smgRpc := <-internalSMGChan
-		internalSMGChan <- smgRpc
-		smgConn, err = rpcclient.NewRpcClient("", "", 0, 0, rpcclient.INTERNAL_RPC, smgRpc)
-	} else {
-		smgConn, err = rpcclient.NewRpcClient("tcp", cfg.DiameterAgentCfg().SMGeneric, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+	var smgConns []rpcclient.RpcClientConnection
+	for _, smgCfg := range cfg.DiameterAgentCfg().HaSMGeneric {
+		var smgConn rpcclient.RpcClientConnection
+		var err error
+		if smgCfg.Server == utils.INTERNAL {
+			smgRpc := <-internalSMGChan
+			internalSMGChan <- smgRpc
+			smgConn, err = rpcclient.NewRpcClient("", "", 0, 0, rpcclient.INTERNAL_RPC, smgRpc)
+		} else {
+			smgConn, err = newRpcClient(smgCfg.Server, cfg.Reconnects)
+		}
+		if err != nil { // Failover connections are not mandatory at start
+			utils.Logger.Err(fmt.Sprintf("<DiameterAgent> Could not connect to SMG at %s: %s", smgCfg.Server, err.Error()))
+			continue
+		}
+		smgConns = append(smgConns, smgConn)
 	}
-	if err != nil {
-		utils.Logger.Crit(fmt.Sprintf("<DiameterAgent> Could not connect to SMG: %s", err.Error()))
+	if len(smgConns) == 0 {
+		utils.Logger.Crit("<DiameterAgent> Could not connect to any SMG")
 		exitChan <- true
 		return
 	}
-	da, err := agents.NewDiameterAgent(cfg, smgConn)
+	da, err := agents.NewDiameterAgent(cfg, agents.NewFailoverConnection(smgConns))
 	if err != nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> error: %s!", err))
 		exitChan <- true
 		return
 	}
+	server.RpcRegister(v1.NewDiameterAgentV1(da))
+	go shutdownDiameterAgentSignalHandler(da, exitChan)
 	if err = da.ListenAndServe(); err != nil {
 		utils.Logger.Err(fmt.Sprintf("<DiameterAgent> error: %s!", err))
 	}
 	exitChan <- true
 }
 
+func startRadiusAgent(exitChan chan bool) {
+	utils.Logger.Info("Starting CGRateS RadiusAgent service.")
+	smgAddr := cfg.RadiusAgentCfg().SMGeneric
+	if smgAddr == utils.INTERNAL { // Disconnects need the BiJSON connection, so we use the one of local SMG
+		smgAddr = cfg.SmGenericConfig.ListenBijson
+	}
+	ra, err := agents.NewRadiusAgent(cfg, smgAddr)
+	if err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> error: %s!", err))
+		exitChan <- true
+		return
+	}
+	go shutdownRadiusAgentSignalHandler(ra, exitChan)
+	if err = ra.ListenAndServe(); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<RadiusAgent> error: %s!", err))
+	}
+	exitChan <- true
+}
+
 func startSmFreeSWITCH(internalRaterChan chan *engine.Responder, cdrDb engine.CdrStorage, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS SM-FreeSWITCH service.")
 	var raterConn, cdrsConn engine.Connector
-	var client *rpcclient.RpcClient
+	var client rpcclient.RpcClientConnection
 	var err error
 	// Connect to rater
 	for _, raterCfg := range cfg.SmFsConfig.HaRater {
@@ -236,7 +304,7 @@ func startSmFreeSWITCH(internalRaterChan chan *engine.Responder, cdrDb engine.Cd
 			raterConn = resp // Will overwrite here for the sake of keeping internally the new configuration format for ha connections
 			internalRaterChan <- resp
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", raterCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(raterCfg.Server, cfg.Reconnects)
 			if err != nil { //Connected so no need to reiterate
 				utils.Logger.Crit(fmt.Sprintf("<SM-FreeSWITCH> Could not connect to rater via RPC: %v", err))
 				exitChan <- true
@@ -255,7 +323,7 @@ func startSmFreeSWITCH(internalRaterChan chan *engine.Responder, cdrDb engine.Cd
 				cdrsConn = resp
 				internalRaterChan <- resp
 			} else {
-				client, err = rpcclient.NewRpcClient("tcp", cdrsCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+				client, err = newRpcClient(cdrsCfg.Server, cfg.Reconnects)
 				if err != nil {
 					utils.Logger.Crit(fmt.Sprintf("<SM-FreeSWITCH> Could not connect to CDRS via RPC: %v", err))
 					exitChan <- true
@@ -276,7 +344,7 @@ func startSmFreeSWITCH(internalRaterChan chan *engine.Responder, cdrDb engine.Cd
 func startSmKamailio(internalRaterChan chan *engine.Responder, cdrDb engine.CdrStorage, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS SM-Kamailio service.")
 	var raterConn, cdrsConn engine.Connector
-	var client *rpcclient.RpcClient
+	var client rpcclient.RpcClientConnection
 	var err error
 	// Connect to rater
 	for _, raterCfg := range cfg.SmKamConfig.HaRater {
@@ -285,7 +353,7 @@ func startSmKamailio(internalRaterChan chan *engine.Responder, cdrDb engine.CdrS
 			raterConn = resp // Will overwrite here for the sake of keeping internally the new configuration format for ha connections
 			internalRaterChan <- resp
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", raterCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(raterCfg.Server, cfg.Reconnects)
 			if err != nil { //Connected so no need to reiterate
 				utils.Logger.Crit(fmt.Sprintf("<SM-Kamailio> Could not connect to rater via RPC: %v", err))
 				exitChan <- true
@@ -304,7 +372,7 @@ func startSmKamailio(internalRaterChan chan *engine.Responder, cdrDb engine.CdrS
 				cdrsConn = resp
 				internalRaterChan <- resp
 			} else {
-				client, err = rpcclient.NewRpcClient("tcp", cdrsCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+				client, err = newRpcClient(cdrsCfg.Server, cfg.Reconnects)
 				if err != nil {
 					utils.Logger.Crit(fmt.Sprintf("<SM-Kamailio> Could not connect to CDRS via RPC: %v", err))
 					exitChan <- true
@@ -325,7 +393,7 @@ func startSmKamailio(internalRaterChan chan *engine.Responder, cdrDb engine.CdrS
 func startSmOpenSIPS(internalRaterChan chan *engine.Responder, cdrDb engine.CdrStorage, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS SM-OpenSIPS service.")
 	var raterConn, cdrsConn engine.Connector
-	var client *rpcclient.RpcClient
+	var client rpcclient.RpcClientConnection
 	var err error
 	// Connect to rater
 	for _, raterCfg := range cfg.SmOsipsConfig.HaRater {
@@ -334,7 +402,7 @@ func startSmOpenSIPS(internalRaterChan chan *engine.Responder, cdrDb engine.CdrS
 			raterConn = resp // Will overwrite here for the sake of keeping internally the new configuration format for ha connections
 			internalRaterChan <- resp
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", raterCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(raterCfg.Server, cfg.Reconnects)
 			if err != nil { //Connected so no need to reiterate
 				utils.Logger.Crit(fmt.Sprintf("<SM-OpenSIPS> Could not connect to rater via RPC: %v", err))
 				exitChan <- true
@@ -353,7 +421,7 @@ func startSmOpenSIPS(internalRaterChan chan *engine.Responder, cdrDb engine.CdrS
 				cdrsConn = resp
 				internalRaterChan <- resp
 			} else {
-				client, err = rpcclient.NewRpcClient("tcp", cdrsCfg.Server, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+				client, err = newRpcClient(cdrsCfg.Server, cfg.Reconnects)
 				if err != nil {
 					utils.Logger.Crit(fmt.Sprintf("<SM-OpenSIPS> Could not connect to CDRS via RPC: %v", err))
 					exitChan <- true
@@ -377,7 +445,7 @@ func startCDRS(internalCdrSChan chan *engine.CdrServer, logDb engine.LogStorage,
 	internalCdrStatSChan chan engine.StatsInterface, server *utils.Server, exitChan chan bool) {
 	utils.Logger.Info("Starting CGRateS CDRS service.")
 	var err error
-	var client *rpcclient.RpcClient
+	var client rpcclient.RpcClientConnection
 	// Rater connection init
 	var raterConn engine.Connector
 	if cfg.CDRSRater == utils.INTERNAL {
@@ -385,7 +453,7 @@ func startCDRS(internalCdrSChan chan *engine.CdrServer, logDb engine.LogStorage,
 		raterConn = responder
 		internalRaterChan <- responder // Put back the connection since there might be other entities waiting for it
 	} else if len(cfg.CDRSRater) != 0 {
-		client, err = rpcclient.NewRpcClient("tcp", cfg.CDRSRater, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+		client, err = newRpcClient(cfg.CDRSRater, cfg.Reconnects)
 		if err != nil {
 			utils.Logger.Crit(fmt.Sprintf("<CDRS> Could not connect to rater: %s", err.Error()))
 			exitChan <- true
@@ -403,7 +471,7 @@ func startCDRS(internalCdrSChan chan *engine.CdrServer, logDb engine.LogStorage,
 		if cfg.CDRSRater == cfg.CDRSPubSub {
 			pubSubConn = &engine.ProxyPubSub{Client: client}
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", cfg.CDRSPubSub, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(cfg.CDRSPubSub, cfg.Reconnects)
 			if err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<CDRS> Could not connect to pubsub server: %s", err.Error()))
 				exitChan <- true
@@ -422,7 +490,7 @@ func startCDRS(internalCdrSChan chan *engine.CdrServer, logDb engine.LogStorage,
 		if cfg.CDRSRater == cfg.CDRSUsers {
 			usersConn = &engine.ProxyUserService{Client: client}
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", cfg.CDRSUsers, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(cfg.CDRSUsers, cfg.Reconnects)
 			if err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<CDRS> Could not connect to users server: %s", err.Error()))
 				exitChan <- true
@@ -441,7 +509,7 @@ func startCDRS(internalCdrSChan chan *engine.CdrServer, logDb engine.LogStorage,
 		if cfg.CDRSRater == cfg.CDRSAliases {
 			aliasesConn = &engine.ProxyAliasService{Client: client}
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", cfg.CDRSAliases, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(cfg.CDRSAliases, cfg.Reconnects)
 			if err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<CDRS> Could not connect to aliases server: %s", err.Error()))
 				exitChan <- true
@@ -460,7 +528,7 @@ func startCDRS(internalCdrSChan chan *engine.CdrServer, logDb engine.LogStorage,
 		if cfg.CDRSRater == cfg.CDRSStats {
 			statsConn = &engine.ProxyStats{Client: client}
 		} else {
-			client, err = rpcclient.NewRpcClient("tcp", cfg.CDRSStats, cfg.ConnectAttempts, cfg.Reconnects, utils.GOB, nil)
+			client, err = newRpcClient(cfg.CDRSStats, cfg.Reconnects)
 			if err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<CDRS> Could not connect to stats server: %s", err.Error()))
 				exitChan <- true
@@ -641,6 +709,19 @@ func main() {
 		}
 		defer accountDb.Close()
 		engine.SetAccountingStorage(accountDb)
+		if cfg.CacheSyncEnabled { // Cache reloads go through the syncer so they reach the other engines
+			cacheSyncer, err := engine.NewCacheSyncer(cfg.CacheSyncNodeId, ratingDb, accountDb, cfg.CacheSyncNotifications, cfg.CacheSyncPollInterval)
+			if err != nil {
+				utils.Logger.Crit(fmt.Sprintf("Could not start cache syncing: %s exiting!", err))
+				return
+			}
+			ratingDb = cacheSyncer.RatingStorage()
+			engine.SetRatingStorage(ratingDb)
+			accountDb = cacheSyncer.AccountingStorage()
+			engine.SetAccountingStorage(accountDb)
+			go cacheSyncer.ListenAndServe()
+			defer cacheSyncer.Shutdown()
+		}
 	}
 	if cfg.RaterEnabled || cfg.CDRSEnabled || cfg.SchedulerEnabled { // Only connect to storDb if necessary
 		logDb, err = engine.ConfigureLogStorage(cfg.StorDBType, cfg.StorDBHost, cfg.StorDBPort,
@@ -655,6 +736,15 @@ func main() {
 		loadDb = logDb.(engine.LoadStorage)
 		cdrDb = logDb.(engine.CdrStorage)
 		engine.SetCdrStorage(cdrDb)
+		if len(cfg.StorDBCdrsPartitioning) != 0 || cfg.StorDBCdrsRetention != 0 {
+			cdrsMaintenance := engine.NewCdrsMaintenance(cfg, cdrDb)
+			go func() {
+				if err := cdrsMaintenance.ListenAndServe(); err != nil {
+					utils.Logger.Err(fmt.Sprintf("<CdrsMaintenance> Error: %s", err.Error()))
+				}
+			}()
+			defer cdrsMaintenance.Shutdown()
+		}
 	}
 
 	engine.SetRoundingDecimals(cfg.RoundingDecimals)
@@ -662,6 +752,28 @@ func main() {
 
 	// Rpc/http server
 	server := new(utils.Server)
+	if len(cfg.TLSCertificate) != 0 {
+		tlsCfg, err := utils.NewServerTLSConfig(cfg.TLSCertificate, cfg.TLSKey, cfg.TLSClientCA)
+		if err != nil {
+			utils.Logger.Crit(fmt.Sprintf("Could not configure TLS: %s exiting!", err))
+			return
+		}
+		server.SetTLSConfig(tlsCfg)
+	}
+	if cfg.AuthEnabled { // Before any service registers
+		server.SetAuthCredentials(cfg.AuthCredentials)
+		if len(cfg.TLSCertificate) == 0 {
+			for _, cred := range cfg.AuthCredentials {
+				if len(cred.ApiKey) != 0 || len(cred.Username) != 0 {
+					utils.Logger.Warning("<Auth> API keys and passwords accepted over plaintext listeners, configure listen tls_certificate to protect them")
+					break
+				}
+			}
+		}
+	}
+	if !cfg.RpcClientTLS && (len(cfg.RpcClientApiKey) != 0 || len(cfg.RpcClientUsername) != 0) {
+		utils.Logger.Warning("<Auth> API key or password sent over plaintext rpc_client connections, enable rpc_client tls to protect them")
+	}
 
 	// Async starts here, will follow cgrates.json start order
 	exitChan := make(chan bool)
@@ -709,7 +821,7 @@ func main() {
 
 	// Start SM-Generic
 	if cfg.SmGenericConfig.Enabled {
-		go startSmGeneric(internalSMGChan, internalRaterChan, server, exitChan)
+		go startSmGeneric(internalSMGChan, internalRaterChan, internalPubSubSChan, server, exitChan)
 	}
 	// Start SM-FreeSWITCH
 	if cfg.SmFsConfig.Enabled {
@@ -735,7 +847,11 @@ func main() {
 	}
 
 	if cfg.DiameterAgentCfg().Enabled {
-		go startDiameterAgent(internalSMGChan, exitChan)
+		go startDiameterAgent(internalSMGChan, server, exitChan)
+	}
+
+	if cfg.RadiusAgentCfg().Enabled {
+		go startRadiusAgent(exitChan)
 	}
 
 	// Start HistoryS service
@@ -25,6 +25,8 @@ import (
 	"github.com/cgrates/cgrates/apier/v1"
 	"github.com/cgrates/cgrates/apier/v2"
 	"github.com/cgrates/cgrates/balancer2go"
+	"github.com/cgrates/cgrates/cache2go"
+	"github.com/cgrates/cgrates/cdre"
 	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/history"
 	"github.com/cgrates/cgrates/scheduler"
@@ -52,6 +54,9 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 	waitTasks = append(waitTasks, cacheTaskChan)
 	go func() {
 		defer close(cacheTaskChan)
+		for cacheName, cacheCfg := range cfg.CacheConfig { // Limits need to be known before caching
+			cache2go.SetPrefixConfig(utils.CachePrefixes[cacheName], &cache2go.PrefixConfig{Limit: cacheCfg.Limit, TTL: cacheCfg.TTL, Partial: cacheCfg.Partial})
+		}
 		if err := ratingDb.CacheRatingAll(); err != nil {
 			utils.Logger.Crit(fmt.Sprintf("Cache rating error: %s", err.Error()))
 			exitChan <- true
@@ -124,10 +129,12 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 					exitChan <- true
 					return
 				}
-			} else if cdrStats, err = engine.NewProxyStats(cfg.RaterCdrStats, cfg.ConnectAttempts, -1); err != nil {
+			} else if client, err := newRpcClient(cfg.RaterCdrStats, -1); err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<Rater> Could not connect to cdrstats, error: %s", err.Error()))
 				exitChan <- true
 				return
+			} else {
+				cdrStats = &engine.ProxyStats{Client: client}
 			}
 		}()
 	}
@@ -148,10 +155,12 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 					exitChan <- true
 					return
 				}
-			} else if scribeServer, err = history.NewProxyScribe(cfg.RaterHistoryServer, cfg.ConnectAttempts, -1); err != nil {
+			} else if client, err := newRpcClient(cfg.RaterHistoryServer, -1); err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<Rater> Could not connect historys, error: %s", err.Error()))
 				exitChan <- true
 				return
+			} else {
+				scribeServer = &history.ProxyScribe{Client: client}
 			}
 			engine.SetHistoryScribe(scribeServer) // ToDo: replace package sharing with connection based one
 		}()
@@ -173,10 +182,12 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 					exitChan <- true
 					return
 				}
-			} else if pubSubServer, err = engine.NewProxyPubSub(cfg.RaterPubSubServer, cfg.ConnectAttempts, -1); err != nil {
+			} else if client, err := newRpcClient(cfg.RaterPubSubServer, -1); err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<Rater> Could not connect to pubsubs: %s", err.Error()))
 				exitChan <- true
 				return
+			} else {
+				pubSubServer = &engine.ProxyPubSub{Client: client}
 			}
 			engine.SetPubSub(pubSubServer) // ToDo: replace package sharing with connection based one
 		}()
@@ -198,10 +209,12 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 					exitChan <- true
 					return
 				}
-			} else if aliasesServer, err = engine.NewProxyAliasService(cfg.RaterAliasesServer, cfg.ConnectAttempts, -1); err != nil {
+			} else if client, err := newRpcClient(cfg.RaterAliasesServer, -1); err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<Rater> Could not connect to aliases, error: %s", err.Error()))
 				exitChan <- true
 				return
+			} else {
+				aliasesServer = &engine.ProxyAliasService{Client: client}
 			}
 			engine.SetAliasService(aliasesServer) // ToDo: replace package sharing with connection based one
 		}()
@@ -223,10 +236,12 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 					exitChan <- true
 					return
 				}
-			} else if userServer, err = engine.NewProxyUserService(cfg.RaterUserServer, cfg.ConnectAttempts, -1); err != nil {
+			} else if client, err := newRpcClient(cfg.RaterUserServer, -1); err != nil {
 				utils.Logger.Crit(fmt.Sprintf("<Rater> Could not connect users, error: %s", err.Error()))
 				exitChan <- true
 				return
+			} else {
+				userServer = &engine.ProxyUserService{Client: client}
 			}
 			engine.SetUserService(userServer)
 		}()
@@ -238,8 +253,18 @@ func startRater(internalRaterChan chan *engine.Responder, cacheDoneChan chan str
 	}
 
 	responder := &engine.Responder{Bal: bal, ExitChan: exitChan, Stats: cdrStats}
+	cdreJobs := cdre.NewCdreJobs(cfg, cdrDb)
+	if cfg.CdreJobsCfg().Enabled {
+		utils.Logger.Info("Starting CGRateS CDRE jobs.")
+		go func() {
+			if err := cdreJobs.ListenAndServe(); err != nil {
+				utils.Logger.Crit(fmt.Sprintf("<CdreJobs> Error: %s", err.Error()))
+				exitChan <- true
+			}
+		}()
+	}
 	apierRpcV1 := &v1.ApierV1{StorDb: loadDb, RatingDb: ratingDb, AccountDb: accountDb, CdrDb: cdrDb, LogDb: logDb, Sched: sched,
-		Config: cfg, Responder: responder, CdrStatsSrv: cdrStats, Users: userServer}
+		Config: cfg, Responder: responder, CdrStatsSrv: cdrStats, Users: userServer, CdreJobs: cdreJobs}
 	apierRpcV2 := &v2.ApierV2{
 		ApierV1: *apierRpcV1}
 	// internalSchedulerChan shared here
@@ -25,6 +25,7 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/cgrates/cgrates/agents"
 	"github.com/cgrates/cgrates/balancer2go"
 	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/scheduler"
@@ -145,3 +146,24 @@ func shutdownSessionmanagerSingnalHandler(exitChan chan bool) {
 	}
 	exitChan <- true
 }
+
+// Sends Disconnect-Peer-Request to the diameter peers on shutdown
+func shutdownDiameterAgentSignalHandler(da *agents.DiameterAgent, exitChan chan bool) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	<-c
+	if err := da.Shutdown(); err != nil {
+		utils.Logger.Warning(fmt.Sprintf("<DiameterAgent> %s", err))
+	}
+	exitChan <- true
+}
+
+func shutdownRadiusAgentSignalHandler(ra *agents.RadiusAgent, exitChan chan bool) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	<-c
+	if err := ra.Shutdown(); err != nil {
+		utils.Logger.Warning(fmt.Sprintf("<RadiusAgent> %s", err))
+	}
+	exitChan <- true
+}
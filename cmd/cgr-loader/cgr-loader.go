@@ -19,10 +19,10 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
-	"net/rpc"
 	"path"
 	"strconv"
 	"strings"
@@ -77,8 +77,31 @@ var (
 	runId           = flag.String("runid", "", "Uniquely identify an import/load, postpended to some automatic fields")
 	loadHistorySize = flag.Int("load_history_size", cgrConfig.LoadHistorySize, "Limit the number of records in the load history")
 	timezone        = flag.String("timezone", cgrConfig.DefaultTimezone, `Timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>`)
+	rpcTLS          = flag.Bool("tls", cgrConfig.RpcClientTLS, "Connect to the history server and the services over TLS")
+	rpcTLSCA        = flag.String("tls_ca", cgrConfig.RpcClientTLSCA, "Path to the PEM CAs verifying the server certificates, system ones if empty")
+	rpcTLSCert      = flag.String("tls_certificate", cgrConfig.RpcClientTLSCertificate, "Path to the PEM client certificate, for servers requiring one")
+	rpcTLSKey       = flag.String("tls_key", cgrConfig.RpcClientTLSKey, "Path to the PEM private key of the client certificate")
+	rpcApiKey       = flag.String("api_key", cgrConfig.RpcClientApiKey, "API key to authenticate with on the history server and the services")
+	rpcUsername     = flag.String("username", cgrConfig.RpcClientUsername, "Username to authenticate with on the history server and the services")
+	rpcPassword     = flag.String("password", cgrConfig.RpcClientPassword, "Password of the username")
 )
 
+// Connection to the history server or the services, over TLS and authenticated as the flags ask
+func dialRpc(addr string, attempts, reconnects int) (*utils.AuthRpcClient, error) {
+	var tlsCfg *tls.Config
+	if *rpcTLS {
+		var err error
+		if tlsCfg, err = utils.NewClientTLSConfig(*rpcTLSCA, *rpcTLSCert, *rpcTLSKey); err != nil {
+			return nil, err
+		}
+	}
+	var creds *utils.AttrAuthenticate
+	if len(*rpcApiKey) != 0 || len(*rpcUsername) != 0 {
+		creds = &utils.AttrAuthenticate{ApiKey: *rpcApiKey, Username: *rpcUsername, Password: *rpcPassword}
+	}
+	return utils.NewAuthRpcClient(addr, attempts, reconnects, utils.GOB, tlsCfg, creds)
+}
+
 func main() {
 	flag.Parse()
 	if *version {
@@ -89,7 +112,7 @@ func main() {
 	var ratingDb engine.RatingStorage
 	var accountDb engine.AccountingStorage
 	var storDb engine.LoadStorage
-	var rater, cdrstats, users *rpc.Client
+	var rater, cdrstats, users *utils.AuthRpcClient
 	var loader engine.LoadReader
 	if *migrateRC8 != "" {
 		var db_nb int
@@ -241,18 +264,18 @@ func main() {
 		return
 	}
 	if *historyServer != "" { // Init scribeAgent so we can store the differences
-		if scribeAgent, err := history.NewProxyScribe(*historyServer, 3, 3); err != nil {
+		if client, err := dialRpc(*historyServer, 3, 3); err != nil {
 			log.Fatalf("Could not connect to history server, error: %s. Make sure you have properly configured it via -history_server flag.", err.Error())
 			return
 		} else {
-			engine.SetHistoryScribe(scribeAgent)
+			engine.SetHistoryScribe(&history.ProxyScribe{Client: client})
 			//defer scribeAgent.Client.Close()
 		}
 	} else {
 		log.Print("WARNING: Rates history archiving is disabled!")
 	}
 	if *raterAddress != "" { // Init connection to rater so we can reload it's data
-		rater, err = rpc.Dial("tcp", *raterAddress)
+		rater, err = dialRpc(*raterAddress, 1, 0)
 		if err != nil {
 			log.Fatalf("Could not connect to rater: %s", err.Error())
 			return
@@ -264,7 +287,7 @@ func main() {
 		if *cdrstatsAddress == *raterAddress {
 			cdrstats = rater
 		} else {
-			cdrstats, err = rpc.Dial("tcp", *cdrstatsAddress)
+			cdrstats, err = dialRpc(*cdrstatsAddress, 1, 0)
 			if err != nil {
 				log.Fatalf("Could not connect to CDRStats API: %s", err.Error())
 				return
@@ -277,7 +300,7 @@ func main() {
 		if *usersAddress == *raterAddress {
 			users = rater
 		} else {
-			users, err = rpc.Dial("tcp", *usersAddress)
+			users, err = dialRpc(*usersAddress, 1, 0)
 			if err != nil {
 				log.Fatalf("Could not connect to Users API: %s", err.Error())
 				return
@@ -0,0 +1,47 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package config
+
+import (
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Credential accepted by the listeners, out of the auth section
+func NewAuthCredentialFromJsonCfg(jsnCfg *AuthCredentialJsonCfg) *utils.AuthCredential {
+	cred := new(utils.AuthCredential)
+	if jsnCfg == nil {
+		return cred
+	}
+	if jsnCfg.Api_key != nil {
+		cred.ApiKey = *jsnCfg.Api_key
+	}
+	if jsnCfg.Username != nil {
+		cred.Username = *jsnCfg.Username
+	}
+	if jsnCfg.Password != nil {
+		cred.Password = *jsnCfg.Password
+	}
+	if jsnCfg.Certificate_cn != nil {
+		cred.CertificateCN = *jsnCfg.Certificate_cn
+	}
+	if jsnCfg.Permissions != nil {
+		cred.Permissions = *jsnCfg.Permissions
+	}
+	return cred
+}
@@ -0,0 +1,51 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package config
+
+import (
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Cache limits for one kind of tariffplan_db data
+type CacheParamConfig struct {
+	Limit   int           // maximum items cached, 0 for unlimited
+	TTL     time.Duration // items expire this long after being cached, 0 to never expire
+	Partial bool          // items are loaded on first use instead of at start
+}
+
+func (self *CacheParamConfig) loadFromJsonCfg(jsnCfg *CacheParamJsonCfg) error {
+	if jsnCfg == nil {
+		return nil
+	}
+	if jsnCfg.Limit != nil {
+		self.Limit = *jsnCfg.Limit
+	}
+	var err error
+	if jsnCfg.Ttl != nil {
+		if self.TTL, err = utils.ParseDurationWithSecs(*jsnCfg.Ttl); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Partial != nil {
+		self.Partial = *jsnCfg.Partial
+	}
+	return nil
+}
@@ -41,6 +41,16 @@ type CdrcConfig struct {
 	CdrFilter               utils.RSRFields // Filter CDR records to import
 	ContinueOnSuccess       bool            // Continue after execution
 	PartialRecordCache      time.Duration   // Duration to cache partial records when not pairing
+	CdrPath                 string          // Path towards one CDR element inside XML files, levels separated by utils.HIERARCHY_SEP
+	JournalDir              string          // Folder to keep the processing journal in, empty to disable it
+	JournalRetention        time.Duration   // Files completed longer ago than this are dropped out of the journal when compacting, 0 to keep them forever
+	QuarantineDir           string          // Folder to move the already processed and the corrupted files to, empty to reject the first into CdrOutDir and leave the second in place
+	WriteRejects            bool            // Write the failed records into a rejects file next to the processed one
+	BatchSize               int             // Number of CDRs posted to CDRS in one request
+	BatchConcurrency        int             // Maximum number of batches posted simultaneously, 0 for no limit
+	RetryInterval           time.Duration   // Pause before posting again a batch refused by CDRS, doubles on each retry
+	TmpDir                  string          // Folder to extract the archive members needing seeks into, empty for the default temporary folder
+	MaxExtractedSize        int64           // Maximum number of bytes decompressed out of one archive, 0 for unlimited
 	HeaderFields            []*CfgCdrField
 	ContentFields           []*CfgCdrField
 	TrailerFields           []*CfgCdrField
@@ -104,6 +114,40 @@ func (self *CdrcConfig) loadFromJsonCfg(jsnCfg *CdrcJsonCfg) error {
 			return err
 		}
 	}
+	if jsnCfg.Cdr_path != nil {
+		self.CdrPath = *jsnCfg.Cdr_path
+	}
+	if jsnCfg.Journal_dir != nil {
+		self.JournalDir = *jsnCfg.Journal_dir
+	}
+	if jsnCfg.Journal_retention != nil {
+		if self.JournalRetention, err = utils.ParseDurationWithSecs(*jsnCfg.Journal_retention); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Quarantine_dir != nil {
+		self.QuarantineDir = *jsnCfg.Quarantine_dir
+	}
+	if jsnCfg.Write_rejects != nil {
+		self.WriteRejects = *jsnCfg.Write_rejects
+	}
+	if jsnCfg.Batch_size != nil {
+		self.BatchSize = *jsnCfg.Batch_size
+	}
+	if jsnCfg.Batch_concurrency != nil {
+		self.BatchConcurrency = *jsnCfg.Batch_concurrency
+	}
+	if jsnCfg.Retry_interval != nil {
+		if self.RetryInterval, err = utils.ParseDurationWithSecs(*jsnCfg.Retry_interval); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Tmp_dir != nil {
+		self.TmpDir = *jsnCfg.Tmp_dir
+	}
+	if jsnCfg.Max_extracted_size != nil {
+		self.MaxExtractedSize = *jsnCfg.Max_extracted_size
+	}
 	if jsnCfg.Header_fields != nil {
 		if self.HeaderFields, err = CfgCdrFieldsFromCdrFieldsJsonCfg(*jsnCfg.Header_fields); err != nil {
 			return err
@@ -136,6 +180,16 @@ func (self *CdrcConfig) Clone() *CdrcConfig {
 	clnCdrc.CdrInDir = self.CdrInDir
 	clnCdrc.CdrOutDir = self.CdrOutDir
 	clnCdrc.CdrSourceId = self.CdrSourceId
+	clnCdrc.CdrPath = self.CdrPath
+	clnCdrc.JournalDir = self.JournalDir
+	clnCdrc.JournalRetention = self.JournalRetention
+	clnCdrc.QuarantineDir = self.QuarantineDir
+	clnCdrc.WriteRejects = self.WriteRejects
+	clnCdrc.BatchSize = self.BatchSize
+	clnCdrc.BatchConcurrency = self.BatchConcurrency
+	clnCdrc.RetryInterval = self.RetryInterval
+	clnCdrc.TmpDir = self.TmpDir
+	clnCdrc.MaxExtractedSize = self.MaxExtractedSize
 	clnCdrc.HeaderFields = make([]*CfgCdrField, len(self.HeaderFields))
 	clnCdrc.ContentFields = make([]*CfgCdrField, len(self.ContentFields))
 	clnCdrc.TrailerFields = make([]*CfgCdrField, len(self.TrailerFields))
@@ -31,6 +31,9 @@ type CdreConfig struct {
 	MaskDestId                 string
 	MaskLength                 int
 	ExportDir                  string
+	ExportPageSize             int    // number of CDRs fetched out of StorDB per export iteration, 0 to fetch them all at once
+	Compression                string // compress the exported file <""|gzip|zip>
+	Manifest                   bool   // write a manifest with record counts, totals and checksum next to the exported file
 	HeaderFields               []*CfgCdrField
 	ContentFields              []*CfgCdrField
 	TrailerFields              []*CfgCdrField
@@ -75,6 +78,15 @@ func (self *CdreConfig) loadFromJsonCfg(jsnCfg *CdreJsonCfg) error {
 	if jsnCfg.Export_dir != nil {
 		self.ExportDir = *jsnCfg.Export_dir
 	}
+	if jsnCfg.Export_page_size != nil {
+		self.ExportPageSize = *jsnCfg.Export_page_size
+	}
+	if jsnCfg.Compression != nil {
+		self.Compression = *jsnCfg.Compression
+	}
+	if jsnCfg.Manifest != nil {
+		self.Manifest = *jsnCfg.Manifest
+	}
 	if jsnCfg.Header_fields != nil {
 		if self.HeaderFields, err = CfgCdrFieldsFromCdrFieldsJsonCfg(*jsnCfg.Header_fields); err != nil {
 			return err
@@ -107,6 +119,9 @@ func (self *CdreConfig) Clone() *CdreConfig {
 	clnCdre.MaskDestId = self.MaskDestId
 	clnCdre.MaskLength = self.MaskLength
 	clnCdre.ExportDir = self.ExportDir
+	clnCdre.ExportPageSize = self.ExportPageSize
+	clnCdre.Compression = self.Compression
+	clnCdre.Manifest = self.Manifest
 	clnCdre.HeaderFields = make([]*CfgCdrField, len(self.HeaderFields))
 	for idx, fld := range self.HeaderFields {
 		clonedVal := *fld
@@ -47,6 +47,7 @@ func TestCdreCfgClone(t *testing.T) {
 		MaskDestId:              "MASKED_DESTINATIONS",
 		MaskLength:              0,
 		ExportDir:               "/var/log/cgrates/cdre",
+		ExportPageSize:          10000,
 		ContentFields:           initContentFlds,
 	}
 	eClnContentFlds := []*CfgCdrField{
@@ -69,6 +70,7 @@ func TestCdreCfgClone(t *testing.T) {
 		MaskDestId:              "MASKED_DESTINATIONS",
 		MaskLength:              0,
 		ExportDir:               "/var/log/cgrates/cdre",
+		ExportPageSize:          10000,
 		HeaderFields:            emptyFields,
 		ContentFields:           eClnContentFlds,
 		TrailerFields:           emptyFields,
@@ -0,0 +1,102 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package config
+
+import (
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Scheduled CDR exports
+type CdreJobsCfg struct {
+	Enabled bool // starts the export jobs: <true|false>
+	Jobs    []*CdreJobCfg
+}
+
+func (self *CdreJobsCfg) loadFromJsonCfg(jsnCfg *CdreJobsJsonCfg) error {
+	if jsnCfg == nil {
+		return nil
+	}
+	if jsnCfg.Enabled != nil {
+		self.Enabled = *jsnCfg.Enabled
+	}
+	if jsnCfg.Jobs != nil {
+		for _, jobJsn := range *jsnCfg.Jobs {
+			job := new(CdreJobCfg)
+			var haveJob bool
+			for _, jobSet := range self.Jobs {
+				if jobJsn.Id != nil && jobSet.Id == *jobJsn.Id {
+					job = jobSet // Will load data into the one set
+					haveJob = true
+					break
+				}
+			}
+			if err := job.loadFromJsonCfg(jobJsn); err != nil {
+				return err
+			}
+			if !haveJob {
+				self.Jobs = append(self.Jobs, job)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the job with the given id, nil if not configured
+func (self *CdreJobsCfg) Job(jobId string) *CdreJobCfg {
+	for _, job := range self.Jobs {
+		if job.Id == jobId {
+			return job
+		}
+	}
+	return nil
+}
+
+// One scheduled CDR export
+type CdreJobCfg struct {
+	Id             string
+	ExportTemplate string          // cdre template used for the export
+	CdrFilter      utils.RSRFields // only CDRs matching the filter are exported
+	Schedule       string          // cron expression scheduling the job runs
+	ExportDir      string          // overwrites export_dir of the template if not empty
+}
+
+func (self *CdreJobCfg) loadFromJsonCfg(jsnCfg *CdreJobJsonCfg) error {
+	if jsnCfg == nil {
+		return nil
+	}
+	if jsnCfg.Id != nil {
+		self.Id = *jsnCfg.Id
+	}
+	if jsnCfg.Export_template != nil {
+		self.ExportTemplate = *jsnCfg.Export_template
+	}
+	var err error
+	if jsnCfg.Cdr_filter != nil {
+		if self.CdrFilter, err = utils.ParseRSRFields(*jsnCfg.Cdr_filter, utils.INFIELD_SEP); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Schedule != nil {
+		self.Schedule = *jsnCfg.Schedule
+	}
+	if jsnCfg.Export_dir != nil {
+		self.ExportDir = *jsnCfg.Export_dir
+	}
+	return nil
+}
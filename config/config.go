@@ -66,6 +66,8 @@ func NewDefaultCGRConfig() (*CGRConfig, error) {
 	cfg.SmKamConfig = new(SmKamConfig)
 	cfg.SmOsipsConfig = new(SmOsipsConfig)
 	cfg.diameterAgentCfg = new(DiameterAgentCfg)
+	cfg.radiusAgentCfg = new(RadiusAgentCfg)
+	cfg.cdreJobsCfg = new(CdreJobsCfg)
 	cfg.ConfigReloads = make(map[string]chan struct{})
 	cfg.ConfigReloads[utils.CDRC] = make(chan struct{}, 1)
 	cfg.ConfigReloads[utils.CDRC] <- struct{}{} // Unlock the channel
@@ -75,6 +77,10 @@ func NewDefaultCGRConfig() (*CGRConfig, error) {
 	cfg.ConfigReloads[utils.SURETAX] <- struct{}{} // Unlock the channel
 	cfg.ConfigReloads[utils.DIAMETER_AGENT] = make(chan struct{}, 1)
 	cfg.ConfigReloads[utils.DIAMETER_AGENT] <- struct{}{} // Unlock the channel
+	cfg.ConfigReloads[utils.RADIUS_AGENT] = make(chan struct{}, 1)
+	cfg.ConfigReloads[utils.RADIUS_AGENT] <- struct{}{} // Unlock the channel
+	cfg.ConfigReloads[utils.CDRE_JOBS] = make(chan struct{}, 1)
+	cfg.ConfigReloads[utils.CDRE_JOBS] <- struct{}{} // Unlock the channel
 	cgrJsonCfg, err := NewCgrJsonCfgFromReader(strings.NewReader(CGRATES_CFG_JSON))
 	if err != nil {
 		return nil, err
@@ -167,87 +173,111 @@ func NewCGRConfigFromFolder(cfgDir string) (*CGRConfig, error) {
 
 // Holds system configuration, defaults are overwritten with values from config file if found
 type CGRConfig struct {
-	TpDbType             string
-	TpDbHost             string // The host to connect to. Values that start with / are for UNIX domain sockets.
-	TpDbPort             string // The port to bind to.
-	TpDbName             string // The name of the database to connect to.
-	TpDbUser             string // The user to sign in as.
-	TpDbPass             string // The user's password.
-	DataDbType           string
-	DataDbHost           string        // The host to connect to. Values that start with / are for UNIX domain sockets.
-	DataDbPort           string        // The port to bind to.
-	DataDbName           string        // The name of the database to connect to.
-	DataDbUser           string        // The user to sign in as.
-	DataDbPass           string        // The user's password.
-	LoadHistorySize      int           // Maximum number of records to archive in load history
-	StorDBType           string        // Should reflect the database type used to store logs
-	StorDBHost           string        // The host to connect to. Values that start with / are for UNIX domain sockets.
-	StorDBPort           string        // Th e port to bind to.
-	StorDBName           string        // The name of the database to connect to.
-	StorDBUser           string        // The user to sign in as.
-	StorDBPass           string        // The user's password.
-	StorDBMaxOpenConns   int           // Maximum database connections opened
-	StorDBMaxIdleConns   int           // Maximum idle connections to keep opened
-	DBDataEncoding       string        // The encoding used to store object data in strings: <msgpack|json>
-	RPCJSONListen        string        // RPC JSON listening address
-	RPCGOBListen         string        // RPC GOB listening address
-	HTTPListen           string        // HTTP listening address
-	DefaultReqType       string        // Use this request type if not defined on top
-	DefaultCategory      string        // set default type of record
-	DefaultTenant        string        // set default tenant
-	DefaultSubject       string        // set default rating subject, useful in case of fallback
-	DefaultTimezone      string        // default timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
-	Reconnects           int           // number of recconect attempts in case of connection lost <-1 for infinite | nb>
-	ConnectAttempts      int           // number of initial connection attempts before giving up
-	ResponseCacheTTL     time.Duration // the life span of a cached response
-	InternalTtl          time.Duration // maximum duration to wait for internal connections before giving up
-	RoundingDecimals     int           // Number of decimals to round end prices at
-	HttpSkipTlsVerify    bool          // If enabled Http Client will accept any TLS certificate
-	TpExportPath         string        // Path towards export folder for offline Tariff Plans
-	HttpFailedDir        string        // Directory path where we store failed http requests
-	MaxCallDuration      time.Duration // The maximum call duration (used by responder when querying DerivedCharging) // ToDo: export it in configuration file
-	RaterEnabled         bool          // start standalone server (no balancer)
-	RaterBalancer        string        // balancer address host:port
-	RaterCdrStats        string        // address where to reach the cdrstats service. Empty to disable stats gathering  <""|internal|x.y.z.y:1234>
-	RaterHistoryServer   string
-	RaterPubSubServer    string
-	RaterUserServer      string
-	RaterAliasesServer   string
-	BalancerEnabled      bool
-	SchedulerEnabled     bool
-	CDRSEnabled          bool                 // Enable CDR Server service
-	CDRSExtraFields      []*utils.RSRField    // Extra fields to store in CDRs
-	CDRSStoreCdrs        bool                 // store cdrs in storDb
-	CDRSRater            string               // address where to reach the Rater for cost calculation: <""|internal|x.y.z.y:1234>
-	CDRSPubSub           string               // address where to reach the pubsub service: <""|internal|x.y.z.y:1234>
-	CDRSUsers            string               // address where to reach the users service: <""|internal|x.y.z.y:1234>
-	CDRSAliases          string               // address where to reach the aliases service: <""|internal|x.y.z.y:1234>
-	CDRSStats            string               // address where to reach the cdrstats service. Empty to disable stats gathering  <""|internal|x.y.z.y:1234>
-	CDRSCdrReplication   []*CdrReplicationCfg // Replicate raw CDRs to a number of servers
-	CDRStatsEnabled      bool                 // Enable CDR Stats service
-	CDRStatsSaveInterval time.Duration        // Save interval duration
-	CdreProfiles         map[string]*CdreConfig
-	CdrcProfiles         map[string]map[string]*CdrcConfig // Number of CDRC instances running imports, format map[dirPath]map[instanceName]{Configs}
-	SmGenericConfig      *SmGenericConfig
-	SmFsConfig           *SmFsConfig              // SM-FreeSWITCH configuration
-	SmKamConfig          *SmKamConfig             // SM-Kamailio Configuration
-	SmOsipsConfig        *SmOsipsConfig           // SM-OpenSIPS Configuration
-	diameterAgentCfg     *DiameterAgentCfg        // DiameterAgent configuration
-	HistoryServer        string                   // Address where to reach the master history server: <internal|x.y.z.y:1234>
-	HistoryServerEnabled bool                     // Starts History as server: <true|false>.
-	HistoryDir           string                   // Location on disk where to store history files.
-	HistorySaveInterval  time.Duration            // The timout duration between pubsub writes
-	PubSubServerEnabled  bool                     // Starts PubSub as server: <true|false>.
-	AliasesServerEnabled bool                     // Starts PubSub as server: <true|false>.
-	UserServerEnabled    bool                     // Starts User as server: <true|false>
-	UserServerIndexes    []string                 // List of user profile field indexes
-	MailerServer         string                   // The server to use when sending emails out
-	MailerAuthUser       string                   // Authenticate to email server using this user
-	MailerAuthPass       string                   // Authenticate to email server with this password
-	MailerFromAddr       string                   // From address used when sending emails out
-	DataFolderPath       string                   // Path towards data folder, for tests internal usage, not loading out of .json options
-	sureTaxCfg           *SureTaxCfg              // Load here SureTax configuration, as pointer so we can have runtime reloads in the future
-	ConfigReloads        map[string]chan struct{} // Signals to specific entities that a config reload should occur
+	TpDbType                  string
+	TpDbHost                  string // The host to connect to. Values that start with / are for UNIX domain sockets.
+	TpDbPort                  string // The port to bind to.
+	TpDbName                  string // The name of the database to connect to.
+	TpDbUser                  string // The user to sign in as.
+	TpDbPass                  string // The user's password.
+	DataDbType                string
+	DataDbHost                string        // The host to connect to. Values that start with / are for UNIX domain sockets.
+	DataDbPort                string        // The port to bind to.
+	DataDbName                string        // The name of the database to connect to.
+	DataDbUser                string        // The user to sign in as.
+	DataDbPass                string        // The user's password.
+	LoadHistorySize           int           // Maximum number of records to archive in load history
+	StorDBType                string        // Should reflect the database type used to store logs
+	StorDBHost                string        // The host to connect to. Values that start with / are for UNIX domain sockets.
+	StorDBPort                string        // Th e port to bind to.
+	StorDBName                string        // The name of the database to connect to.
+	StorDBUser                string        // The user to sign in as.
+	StorDBPass                string        // The user's password.
+	StorDBMaxOpenConns        int           // Maximum database connections opened
+	StorDBMaxIdleConns        int           // Maximum idle connections to keep opened
+	StorDBCdrsPartitioning    string        // Partitioning of the CDR tables: <""|*monthly>
+	StorDBCdrsRetention       time.Duration // Remove CDRs stored longer ago than this, 0 to keep them forever
+	StorDBCdrsRetentionAction string        // What to do with the expired CDRs: <*delete|*archive>
+	StorDBCdrsArchiveDir      string        // Folder to write the archived CDRs into
+	DBDataEncoding            string        // The encoding used to store object data in strings: <msgpack|json>
+	RPCJSONListen             string        // RPC JSON listening address
+	RPCGOBListen              string        // RPC GOB listening address
+	HTTPListen                string        // HTTP listening address
+	TLSCertificate            string        // Path to the PEM certificate, enables TLS on all listeners
+	TLSKey                    string        // Path to the PEM private key of the certificate
+	TLSClientCA               string        // Path to the PEM CAs verifying client certificates, empty to not require them
+	DefaultReqType            string        // Use this request type if not defined on top
+	DefaultCategory           string        // set default type of record
+	DefaultTenant             string        // set default tenant
+	DefaultSubject            string        // set default rating subject, useful in case of fallback
+	DefaultTimezone           string        // default timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
+	Reconnects                int           // number of recconect attempts in case of connection lost <-1 for infinite | nb>
+	ConnectAttempts           int           // number of initial connection attempts before giving up
+	ResponseCacheTTL          time.Duration // the life span of a cached response
+	InternalTtl               time.Duration // maximum duration to wait for internal connections before giving up
+	RoundingDecimals          int           // Number of decimals to round end prices at
+	HttpSkipTlsVerify         bool          // If enabled Http Client will accept any TLS certificate
+	TpExportPath              string        // Path towards export folder for offline Tariff Plans
+	HttpFailedDir             string        // Directory path where we store failed http requests
+	MaxCallDuration           time.Duration // The maximum call duration (used by responder when querying DerivedCharging) // ToDo: export it in configuration file
+	RaterEnabled              bool          // start standalone server (no balancer)
+	RaterBalancer             string        // balancer address host:port
+	RaterCdrStats             string        // address where to reach the cdrstats service. Empty to disable stats gathering  <""|internal|x.y.z.y:1234>
+	RaterHistoryServer        string
+	RaterPubSubServer         string
+	RaterUserServer           string
+	RaterAliasesServer        string
+	BalancerEnabled           bool
+	SchedulerEnabled          bool
+	CDRSEnabled               bool                 // Enable CDR Server service
+	CDRSExtraFields           []*utils.RSRField    // Extra fields to store in CDRs
+	CDRSStoreCdrs             bool                 // store cdrs in storDb
+	CDRSDeriveConcurrency     int                  // Maximum number of CDRs derived in background, 0 for no limit
+	CDRSRater                 string               // address where to reach the Rater for cost calculation: <""|internal|x.y.z.y:1234>
+	CDRSPubSub                string               // address where to reach the pubsub service: <""|internal|x.y.z.y:1234>
+	CDRSUsers                 string               // address where to reach the users service: <""|internal|x.y.z.y:1234>
+	CDRSAliases               string               // address where to reach the aliases service: <""|internal|x.y.z.y:1234>
+	CDRSStats                 string               // address where to reach the cdrstats service. Empty to disable stats gathering  <""|internal|x.y.z.y:1234>
+	CDRSCdrReplication        []*CdrReplicationCfg // Replicate raw CDRs to a number of servers
+	CDRStatsEnabled           bool                 // Enable CDR Stats service
+	CDRStatsSaveInterval      time.Duration        // Save interval duration
+	CdreProfiles              map[string]*CdreConfig
+	CacheConfig               map[string]*CacheParamConfig      // Cache limits, indexed on cache name
+	CacheSyncEnabled          bool                              // Sync the cache with the other engines sharing the tariffplan_db
+	CacheSyncNodeId           string                            // Identifies this engine within the cache versions, hostname if empty
+	CacheSyncNotifications    bool                              // Sync on change notifications out of the tariffplan_db
+	CacheSyncPollInterval     time.Duration                     // Sync on this interval, 0 to disable polling
+	AuthEnabled               bool                              // Authenticate and authorize all requests on the listeners
+	AuthCredentials           []*utils.AuthCredential           // Credentials accepted when AuthEnabled
+	RpcClientTLS              bool                              // Connect to other engines over TLS
+	RpcClientTLSCA            string                            // Path to the PEM CAs verifying the server certificates, system ones if empty
+	RpcClientTLSCertificate   string                            // Path to the PEM client certificate
+	RpcClientTLSKey           string                            // Path to the PEM private key of the client certificate
+	RpcClientApiKey           string                            // Authenticates the connections to other engines
+	RpcClientUsername         string                            // Authenticates the connections to other engines together with RpcClientPassword
+	RpcClientPassword         string                            // Password of RpcClientUsername
+	cdreJobsCfg               *CdreJobsCfg                      // Scheduled CDR exports
+	CdrcProfiles              map[string]map[string]*CdrcConfig // Number of CDRC instances running imports, format map[dirPath]map[instanceName]{Configs}
+	SmGenericConfig           *SmGenericConfig
+	SmFsConfig                *SmFsConfig              // SM-FreeSWITCH configuration
+	SmKamConfig               *SmKamConfig             // SM-Kamailio Configuration
+	SmOsipsConfig             *SmOsipsConfig           // SM-OpenSIPS Configuration
+	diameterAgentCfg          *DiameterAgentCfg        // DiameterAgent configuration
+	radiusAgentCfg            *RadiusAgentCfg          // RadiusAgent configuration
+	HistoryServer             string                   // Address where to reach the master history server: <internal|x.y.z.y:1234>
+	HistoryServerEnabled      bool                     // Starts History as server: <true|false>.
+	HistoryDir                string                   // Location on disk where to store history files.
+	HistorySaveInterval       time.Duration            // The timout duration between pubsub writes
+	PubSubServerEnabled       bool                     // Starts PubSub as server: <true|false>.
+	AliasesServerEnabled      bool                     // Starts PubSub as server: <true|false>.
+	UserServerEnabled         bool                     // Starts User as server: <true|false>
+	UserServerIndexes         []string                 // List of user profile field indexes
+	MailerServer              string                   // The server to use when sending emails out
+	MailerAuthUser            string                   // Authenticate to email server using this user
+	MailerAuthPass            string                   // Authenticate to email server with this password
+	MailerFromAddr            string                   // From address used when sending emails out
+	DataFolderPath            string                   // Path towards data folder, for tests internal usage, not loading out of .json options
+	sureTaxCfg                *SureTaxCfg              // Load here SureTax configuration, as pointer so we can have runtime reloads in the future
+	ConfigReloads             map[string]chan struct{} // Signals to specific entities that a config reload should occur
 	// Cache defaults loaded from json and needing clones
 	dfltCdreProfile *CdreConfig // Default cdreConfig profile
 	dfltCdrcProfile *CdrcConfig // Default cdrcConfig profile
@@ -275,6 +305,12 @@ func (self *CGRConfig) checkConfigSanity() error {
 			return errors.New("Users service not enabled but requested by Rater component.")
 		}
 	}
+	// Internal storage checks, the default database names are not folders to persist into
+	for dbSection, dbCfg := range map[string][]string{TPDB_JSN: []string{self.TpDbType, self.TpDbName}, DATADB_JSN: []string{self.DataDbType, self.DataDbName}} {
+		if dbCfg[0] == utils.META_INTERNAL && len(dbCfg[1]) != 0 && !filepath.IsAbs(dbCfg[1]) {
+			return fmt.Errorf("%s db_name should be the absolute path of the folder to persist %s into, empty for memory only", dbSection, utils.META_INTERNAL)
+		}
+	}
 	// CDRServer checks
 	if self.CDRSEnabled {
 		if self.CDRSRater == utils.INTERNAL && !self.RaterEnabled {
@@ -292,6 +328,9 @@ func (self *CGRConfig) checkConfigSanity() error {
 		if self.CDRSStats == utils.INTERNAL && !self.CDRStatsEnabled {
 			return errors.New("CDRStats not enabled but requested by CDRS component.")
 		}
+		if self.CDRSDeriveConcurrency < 0 {
+			return errors.New("CDRS derive_concurrency should not be negative!")
+		}
 	}
 	// CDRC sanity checks
 	for _, cdrcCfgs := range self.CdrcProfiles {
@@ -316,6 +355,15 @@ func (self *CGRConfig) checkConfigSanity() error {
 					}
 				}
 			}
+			if cdrcInst.CdrFormat == utils.XML && len(cdrcInst.CdrPath) == 0 {
+				return errors.New("CdrC processing XML files but no cdr_path defined!")
+			}
+			if cdrcInst.BatchSize < 1 {
+				return errors.New("CdrC batch_size should be at least 1!")
+			}
+			if cdrcInst.RetryInterval <= 0 {
+				return errors.New("CdrC retry_interval should be positive!")
+			}
 		}
 	}
 	// SM-Generic checks
@@ -332,6 +380,9 @@ func (self *CGRConfig) checkConfigSanity() error {
 		if self.SmGenericConfig.HaCdrs[0].Server == utils.INTERNAL && !self.CDRSEnabled {
 			return errors.New("CDRS not enabled but referenced by SM-Generic component")
 		}
+		if self.SmGenericConfig.PubSubs == utils.INTERNAL && !self.PubSubServerEnabled {
+			return errors.New("PubSub service not enabled but requested by SM-Generic component.")
+		}
 	}
 	// SM-FreeSWITCH checks
 	if self.SmFsConfig.Enabled {
@@ -380,8 +431,116 @@ func (self *CGRConfig) checkConfigSanity() error {
 	}
 	// DAgent checks
 	if self.diameterAgentCfg.Enabled {
-		if self.diameterAgentCfg.SMGeneric == utils.INTERNAL && !self.SmGenericConfig.Enabled {
-			return errors.New("SMGeneric not enabled but referenced by DiameterAgent component")
+		if len(self.diameterAgentCfg.HaSMGeneric) == 0 {
+			return errors.New("SMGeneric definition is mandatory for DiameterAgent component")
+		}
+		for _, smgCfg := range self.diameterAgentCfg.HaSMGeneric {
+			if smgCfg.Server == utils.INTERNAL && !self.SmGenericConfig.Enabled {
+				return errors.New("SMGeneric not enabled but referenced by DiameterAgent component")
+			}
+		}
+	}
+	// Cache checks
+	for cacheName, cacheCfg := range self.CacheConfig {
+		if !utils.IsSliceMember(utils.CachePartials, cacheName) {
+			return fmt.Errorf("Unsupported cache: %s", cacheName)
+		}
+		if (cacheCfg.Limit != 0 || cacheCfg.TTL != 0) && !cacheCfg.Partial {
+			return fmt.Errorf("Cache %s with limit or ttl needs to be partial", cacheName)
+		}
+		if cacheCfg.Limit < 0 || cacheCfg.TTL < 0 {
+			return fmt.Errorf("Cache %s with negative limit or ttl", cacheName)
+		}
+	}
+	// Listeners checks
+	if (len(self.TLSCertificate) == 0) != (len(self.TLSKey) == 0) {
+		return errors.New("TLS needs both tls_certificate and tls_key")
+	}
+	if len(self.TLSClientCA) != 0 && len(self.TLSCertificate) == 0 {
+		return errors.New("TLS client certificates verification needs tls_certificate and tls_key")
+	}
+	if self.AuthEnabled && len(self.AuthCredentials) == 0 {
+		return errors.New("Auth enabled without credentials")
+	}
+	for idx, cred := range self.AuthCredentials {
+		if len(cred.ApiKey) == 0 && len(cred.Username) == 0 && len(cred.CertificateCN) == 0 {
+			return fmt.Errorf("Auth credential %d without api_key, username or certificate_cn", idx)
+		}
+		if len(cred.Username) != 0 && len(cred.Password) == 0 {
+			return fmt.Errorf("Auth credential %d with username but no password", idx)
+		}
+		if len(cred.Permissions) == 0 {
+			return fmt.Errorf("Auth credential %d without permissions", idx)
+		}
+		for _, perm := range cred.Permissions {
+			if len(perm) == 0 {
+				return fmt.Errorf("Auth credential %d with empty permission", idx)
+			}
+		}
+	}
+	if (len(self.RpcClientTLSCertificate) == 0) != (len(self.RpcClientTLSKey) == 0) {
+		return errors.New("Rpc client certificate needs both tls_certificate and tls_key")
+	}
+	if !self.RpcClientTLS && (len(self.RpcClientTLSCA) != 0 || len(self.RpcClientTLSCertificate) != 0) {
+		return errors.New("Rpc client tls_ca or tls_certificate without tls enabled")
+	}
+	if len(self.RpcClientUsername) != 0 && len(self.RpcClientPassword) == 0 {
+		return errors.New("Rpc client username without password")
+	}
+	// Cache sync checks
+	if self.CacheSyncEnabled {
+		if !self.CacheSyncNotifications && self.CacheSyncPollInterval == 0 {
+			return errors.New("Cache sync needs notifications or poll_interval")
+		}
+		if self.CacheSyncNotifications && self.TpDbType != utils.REDIS {
+			return errors.New("Cache sync notifications only available with redis tariffplan_db, disable them and rely on poll_interval")
+		}
+	}
+	// CDRE checks
+	for profileName, cdreProfile := range self.CdreProfiles {
+		if !utils.IsSliceMember(utils.CdreCompressions, cdreProfile.Compression) {
+			return fmt.Errorf("CDRE profile %s with unsupported compression: %s", profileName, cdreProfile.Compression)
+		}
+	}
+	// CDRE jobs checks
+	if self.cdreJobsCfg.Enabled {
+		if !self.RaterEnabled {
+			return errors.New("Rater not enabled but requested by CDRE jobs")
+		}
+		for _, job := range self.cdreJobsCfg.Jobs {
+			if len(job.Id) == 0 {
+				return errors.New("CDRE job without id")
+			}
+			if _, hasIt := self.CdreProfiles[job.ExportTemplate]; !hasIt {
+				return fmt.Errorf("CDRE job %s referencing unknown export template: %s", job.Id, job.ExportTemplate)
+			}
+			if len(job.Schedule) == 0 {
+				return fmt.Errorf("CDRE job %s without schedule", job.Id)
+			}
+		}
+	}
+	// RAgent checks
+	if self.radiusAgentCfg.Enabled {
+		if len(self.radiusAgentCfg.SMGeneric) == 0 {
+			return errors.New("SMGeneric definition is mandatory for RadiusAgent component")
+		}
+		if self.radiusAgentCfg.SMGeneric == utils.INTERNAL && !self.SmGenericConfig.Enabled {
+			return errors.New("SMGeneric not enabled but referenced by RadiusAgent component")
+		}
+		if len(self.radiusAgentCfg.ClientSecrets) == 0 {
+			return errors.New("No client secrets defined for RadiusAgent component")
+		}
+	}
+	// StorDB checks
+	if !utils.IsSliceMember([]string{"", utils.META_MONTHLY}, self.StorDBCdrsPartitioning) {
+		return fmt.Errorf("Unsupported CDRs partitioning: %s", self.StorDBCdrsPartitioning)
+	}
+	if self.StorDBCdrsRetention != 0 {
+		if !utils.IsSliceMember([]string{utils.META_DELETE, utils.META_ARCHIVE}, self.StorDBCdrsRetentionAction) {
+			return fmt.Errorf("Unsupported CDRs retention action: %s", self.StorDBCdrsRetentionAction)
+		}
+		if self.StorDBCdrsRetentionAction == utils.META_ARCHIVE && len(self.StorDBCdrsArchiveDir) == 0 {
+			return errors.New("CDRs archive dir is mandatory for *archive retention action")
 		}
 	}
 	return nil
@@ -401,6 +560,16 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnAuthCfg, err := jsnCfg.AuthJsonCfg()
+	if err != nil {
+		return err
+	}
+
+	jsnRpcClientCfg, err := jsnCfg.RpcClientJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnTpDbCfg, err := jsnCfg.DbJsonCfg(TPDB_JSN)
 	if err != nil {
 		return err
@@ -411,6 +580,16 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnCacheCfg, err := jsnCfg.CacheJsonCfg()
+	if err != nil {
+		return err
+	}
+
+	jsnCacheSyncCfg, err := jsnCfg.CacheSyncJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnStorDbCfg, err := jsnCfg.DbJsonCfg(STORDB_JSN)
 	if err != nil {
 		return err
@@ -446,6 +625,11 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnCdreJobsCfg, err := jsnCfg.CdreJobsJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnCdrcCfg, err := jsnCfg.CdrcJsonCfg()
 	if err != nil {
 		return err
@@ -476,6 +660,11 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		return err
 	}
 
+	jsnRACfg, err := jsnCfg.RadiusAgentJsonCfg()
+	if err != nil {
+		return err
+	}
+
 	jsnHistServCfg, err := jsnCfg.HistServJsonCfg()
 	if err != nil {
 		return err
@@ -552,6 +741,37 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		}
 	}
 
+	if jsnCacheCfg != nil {
+		if self.CacheConfig == nil {
+			self.CacheConfig = make(map[string]*CacheParamConfig)
+		}
+		for cacheName, jsnCacheParamCfg := range jsnCacheCfg {
+			if _, hasIt := self.CacheConfig[cacheName]; !hasIt {
+				self.CacheConfig[cacheName] = new(CacheParamConfig)
+			}
+			if err = self.CacheConfig[cacheName].loadFromJsonCfg(jsnCacheParamCfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	if jsnCacheSyncCfg != nil {
+		if jsnCacheSyncCfg.Enabled != nil {
+			self.CacheSyncEnabled = *jsnCacheSyncCfg.Enabled
+		}
+		if jsnCacheSyncCfg.Node_id != nil {
+			self.CacheSyncNodeId = *jsnCacheSyncCfg.Node_id
+		}
+		if jsnCacheSyncCfg.Notifications != nil {
+			self.CacheSyncNotifications = *jsnCacheSyncCfg.Notifications
+		}
+		if jsnCacheSyncCfg.Poll_interval != nil {
+			if self.CacheSyncPollInterval, err = utils.ParseDurationWithSecs(*jsnCacheSyncCfg.Poll_interval); err != nil {
+				return err
+			}
+		}
+	}
+
 	if jsnStorDbCfg != nil {
 		if jsnStorDbCfg.Db_type != nil {
 			self.StorDBType = *jsnStorDbCfg.Db_type
@@ -577,6 +797,20 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		if jsnStorDbCfg.Max_idle_conns != nil {
 			self.StorDBMaxIdleConns = *jsnStorDbCfg.Max_idle_conns
 		}
+		if jsnStorDbCfg.Cdrs_partitioning != nil {
+			self.StorDBCdrsPartitioning = *jsnStorDbCfg.Cdrs_partitioning
+		}
+		if jsnStorDbCfg.Cdrs_retention != nil {
+			if self.StorDBCdrsRetention, err = utils.ParseDurationWithSecs(*jsnStorDbCfg.Cdrs_retention); err != nil {
+				return err
+			}
+		}
+		if jsnStorDbCfg.Cdrs_retention_action != nil {
+			self.StorDBCdrsRetentionAction = *jsnStorDbCfg.Cdrs_retention_action
+		}
+		if jsnStorDbCfg.Cdrs_archive_dir != nil {
+			self.StorDBCdrsArchiveDir = *jsnStorDbCfg.Cdrs_archive_dir
+		}
 	}
 
 	if jsnGeneralCfg != nil {
@@ -638,6 +872,51 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		if jsnListenCfg.Http != nil {
 			self.HTTPListen = *jsnListenCfg.Http
 		}
+		if jsnListenCfg.Tls_certificate != nil {
+			self.TLSCertificate = *jsnListenCfg.Tls_certificate
+		}
+		if jsnListenCfg.Tls_key != nil {
+			self.TLSKey = *jsnListenCfg.Tls_key
+		}
+		if jsnListenCfg.Tls_client_ca != nil {
+			self.TLSClientCA = *jsnListenCfg.Tls_client_ca
+		}
+	}
+
+	if jsnAuthCfg != nil {
+		if jsnAuthCfg.Enabled != nil {
+			self.AuthEnabled = *jsnAuthCfg.Enabled
+		}
+		if jsnAuthCfg.Credentials != nil {
+			self.AuthCredentials = make([]*utils.AuthCredential, len(*jsnAuthCfg.Credentials))
+			for idx, jsnCred := range *jsnAuthCfg.Credentials {
+				self.AuthCredentials[idx] = NewAuthCredentialFromJsonCfg(jsnCred)
+			}
+		}
+	}
+
+	if jsnRpcClientCfg != nil {
+		if jsnRpcClientCfg.Tls != nil {
+			self.RpcClientTLS = *jsnRpcClientCfg.Tls
+		}
+		if jsnRpcClientCfg.Tls_ca != nil {
+			self.RpcClientTLSCA = *jsnRpcClientCfg.Tls_ca
+		}
+		if jsnRpcClientCfg.Tls_certificate != nil {
+			self.RpcClientTLSCertificate = *jsnRpcClientCfg.Tls_certificate
+		}
+		if jsnRpcClientCfg.Tls_key != nil {
+			self.RpcClientTLSKey = *jsnRpcClientCfg.Tls_key
+		}
+		if jsnRpcClientCfg.Api_key != nil {
+			self.RpcClientApiKey = *jsnRpcClientCfg.Api_key
+		}
+		if jsnRpcClientCfg.Username != nil {
+			self.RpcClientUsername = *jsnRpcClientCfg.Username
+		}
+		if jsnRpcClientCfg.Password != nil {
+			self.RpcClientPassword = *jsnRpcClientCfg.Password
+		}
 	}
 
 	if jsnRaterCfg != nil {
@@ -684,6 +963,9 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		if jsnCdrsCfg.Store_cdrs != nil {
 			self.CDRSStoreCdrs = *jsnCdrsCfg.Store_cdrs
 		}
+		if jsnCdrsCfg.Derive_concurrency != nil {
+			self.CDRSDeriveConcurrency = *jsnCdrsCfg.Derive_concurrency
+		}
 		if jsnCdrsCfg.Rater != nil {
 			self.CDRSRater = *jsnCdrsCfg.Rater
 		}
@@ -753,6 +1035,12 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		}
 	}
 
+	if jsnCdreJobsCfg != nil {
+		if err := self.cdreJobsCfg.loadFromJsonCfg(jsnCdreJobsCfg); err != nil {
+			return err
+		}
+	}
+
 	if jsnCdrcCfg != nil {
 		if self.CdrcProfiles == nil {
 			self.CdrcProfiles = make(map[string]map[string]*CdrcConfig)
@@ -803,6 +1091,12 @@ func (self *CGRConfig) loadFromJsonCfg(jsnCfg *CgrJsonCfg) error {
 		}
 	}
 
+	if jsnRACfg != nil {
+		if err := self.radiusAgentCfg.loadFromJsonCfg(jsnRACfg); err != nil {
+			return err
+		}
+	}
+
 	if jsnHistServCfg != nil {
 		if jsnHistServCfg.Enabled != nil {
 			self.HistoryServerEnabled = *jsnHistServCfg.Enabled
@@ -877,3 +1171,15 @@ func (self *CGRConfig) DiameterAgentCfg() *DiameterAgentCfg {
 	defer func() { self.ConfigReloads[utils.DIAMETER_AGENT] <- cfgChan }()
 	return self.diameterAgentCfg
 }
+
+func (self *CGRConfig) CdreJobsCfg() *CdreJobsCfg {
+	cfgChan := <-self.ConfigReloads[utils.CDRE_JOBS] // Lock config for read or reloads
+	defer func() { self.ConfigReloads[utils.CDRE_JOBS] <- cfgChan }()
+	return self.cdreJobsCfg
+}
+
+func (self *CGRConfig) RadiusAgentCfg() *RadiusAgentCfg {
+	cfgChan := <-self.ConfigReloads[utils.RADIUS_AGENT] // Lock config for read or reloads
+	defer func() { self.ConfigReloads[utils.RADIUS_AGENT] <- cfgChan }()
+	return self.radiusAgentCfg
+}
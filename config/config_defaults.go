@@ -49,39 +49,82 @@ const CGRATES_CFG_JSON = `
 	"rpc_json": "127.0.0.1:2012",			// RPC JSON listening address
 	"rpc_gob": "127.0.0.1:2013",			// RPC GOB listening address
 	"http": "127.0.0.1:2080",				// HTTP listening address
+	"tls_certificate": "",					// path to the PEM certificate, enables TLS on all listeners when set
+	"tls_key": "",							// path to the PEM private key of the certificate
+	"tls_client_ca": "",					// path to the PEM CAs verifying client certificates, empty to not require them
+},
+
+
+"auth": {
+	"enabled": false,						// authenticate and authorize all requests on the listeners: <true|false>
+	"credentials": [],						// identified by api_key, username with password or certificate_cn, ie: {"api_key": "ro_key", "permissions": ["ApierV1.Get*"]}
+},
+
+
+"rpc_client": {							// connections opened towards other engines, ie: SM-Generic, CDRS and CDRC to the rater
+	"tls": false,							// connect over TLS: <true|false>
+	"tls_ca": "",							// path to the PEM CAs verifying the server certificates, system ones if empty
+	"tls_certificate": "",					// path to the PEM client certificate, for listeners requiring one
+	"tls_key": "",							// path to the PEM private key of the client certificate
+	"api_key": "",							// sent with AuthV1.Authenticate after connecting, takes precedence over username
+	"username": "",							// sent with password on AuthV1.Authenticate after connecting
+	"password": "",							// password of the username
 },
 
 
 "tariffplan_db": {							// database used to store active tariff plan configuration
-	"db_type": "redis",						// tariffplan_db type: <redis>
+	"db_type": "redis",						// tariffplan_db type: <redis|*internal>
 	"db_host": "127.0.0.1",					// tariffplan_db host address
 	"db_port": 6379, 						// port to reach the tariffplan_db
-	"db_name": "10", 						// tariffplan_db name to connect to
+	"db_name": "10", 						// tariffplan_db name to connect to, absolute path of the folder to persist into for *internal, set explicitly (empty for memory only)
 	"db_user": "", 							// sername to use when connecting to tariffplan_db
 	"db_passwd": "", 						// password to use when connecting to tariffplan_db
 },
 
 
 "data_db": {								// database used to store runtime data (eg: accounts, cdr stats)
-	"db_type": "redis",						// data_db type: <redis>
+	"db_type": "redis",						// data_db type: <redis|*internal>
 	"db_host": "127.0.0.1",					// data_db host address
 	"db_port": 6379, 						// data_db port to reach the database
-	"db_name": "11", 						// data_db database name to connect to
+	"db_name": "11", 						// data_db database name to connect to, absolute path of the folder to persist into for *internal, set explicitly (empty for memory only)
 	"db_user": "", 							// username to use when connecting to data_db
 	"db_passwd": "", 						// password to use when connecting to data_db
 	"load_history_size": 10,				// Number of records in the load history
 },
 
 
+"cache": {									// in-memory cache of the tariffplan_db data, items loaded at start unless partial
+	"rating_plans": {"limit": 0, "ttl": "0s", "partial": false},		// limit: maximum items cached, least recently used evicted over it, 0 for unlimited
+	"actions": {"limit": 0, "ttl": "0s", "partial": false},			// ttl: items expire this long after being cached, 0 to never expire
+	"shared_groups": {"limit": 0, "ttl": "0s", "partial": false},		// partial: load items from tariffplan_db on first use, required by limit and ttl
+	"destinations": {"limit": 0, "ttl": "0s", "partial": false},		// destination ids cached on prefix, partial needs the reverse destinations written by the loader
+	"rating_profiles": {"limit": 0, "ttl": "0s", "partial": false},
+	"derived_chargers": {"limit": 0, "ttl": "0s", "partial": false},
+	"lcr": {"limit": 0, "ttl": "0s", "partial": false},
+},
+
+
+"cache_sync": {							// keeps the cache in sync with the other engines sharing the tariffplan_db
+	"enabled": false,						// starts cache syncing: <true|false>
+	"node_id": "",							// identifies this engine within the cache versions, hostname if empty
+	"notifications": true,					// sync on change notifications, redis only, disable for other tariffplan_db: <true|false>
+	"poll_interval": "10s",					// sync on this interval, the only sync without notifications, 0 to disable polling
+},
+
+
 "stor_db": {								// database used to store offline tariff plans and CDRs
-	"db_type": "mysql",						// stor database type to use: <mysql|postgres>
+	"db_type": "mysql",						// stor database type to use: <mysql|postgres|sqlite>
 	"db_host": "127.0.0.1",					// the host to connect to
 	"db_port": 3306,						// the port to reach the stordb
-	"db_name": "cgrates",					// stor database name
+	"db_name": "cgrates",					// stor database name, file path for sqlite
 	"db_user": "cgrates",					// username to use when connecting to stordb
 	"db_passwd": "CGRateS.org",				// password to use when connecting to stordb
 	"max_open_conns": 100,					// maximum database connections opened
 	"max_idle_conns": 10,					// maximum database connections idle
+	"cdrs_partitioning": "",				// partition the CDR tables on the month the CDRs were stored in: <""|*monthly>
+	"cdrs_retention": "0",					// remove the CDRs stored longer ago than this, 0 to keep them forever: <""|$dur>
+	"cdrs_retention_action": "*delete",		// what to do with the expired CDRs: <*delete|*archive>
+	"cdrs_archive_dir": "/var/spool/cgrates/cdrs_archive",	// where *archive writes the expired CDRs as JSON lines
 },
 
 
@@ -110,6 +153,7 @@ const CGRATES_CFG_JSON = `
 	"enabled": false,						// start the CDR Server service:  <true|false>
 	"extra_fields": [],						// extra fields to store in CDRs for non-generic CDRs
 	"store_cdrs": true,						// store cdrs in storDb
+	"derive_concurrency": 1000,				// maximum number of CDRs derived and rated in background, new CDRs waiting for a free slot, 0 for no limit
 	"rater": "internal",					// address where to reach the Rater for cost calculation, empty to disable functionality: <""|internal|x.y.z.y:1234>
 	"pubsubs": "",							// address where to reach the pubusb service, empty to disable pubsub functionality: <""|internal|x.y.z.y:1234>
 	"users": "",							// address where to reach the user service, empty to disable user profile functionality: <""|internal|x.y.z.y:1234>
@@ -127,7 +171,7 @@ const CGRATES_CFG_JSON = `
 
 "cdre": {
 	"*default": {
-		"cdr_format": "csv",							// exported CDRs format <csv>
+		"cdr_format": "csv",							// exported CDRs format <csv|fwv|json|ndjson|xml>
 		"field_separator": ",",
 		"data_usage_multiply_factor": 1,				// multiply data usage before export (eg: convert from KBytes to Bytes)
 		"sms_usage_multiply_factor": 1,					// multiply data usage before export (eg: convert from SMS unit to call duration in some billing systems)
@@ -138,6 +182,9 @@ const CGRATES_CFG_JSON = `
 		"mask_destination_id": "MASKED_DESTINATIONS",	// destination id containing called addresses to be masked on export
 		"mask_length": 0,								// length of the destination suffix to be masked
 		"export_dir": "/var/log/cgrates/cdre",			// path where the exported CDRs will be placed
+		"export_page_size": 10000,						// number of CDRs fetched out of StorDB per export iteration, 0 to fetch them all at once
+		"compression": "",								// compress the exported file <""|gzip|zip>
+		"manifest": false,								// write a manifest file with record counts, totals and SHA-256 checksum next to the exported one
 		"header_fields": [],							// template of the exported header fields
 		"content_fields": [								// template of the exported content fields
 			{"tag": "CgrId", "field_id": "CgrId", "type": "*composed", "value": "CgrId"},
@@ -161,12 +208,26 @@ const CGRATES_CFG_JSON = `
 },
 
 
+"cdre_jobs": {
+	"enabled": false,								// run scheduled CDR exports, needs rater: <true|false>
+	"jobs": [										// export jobs, each exporting only the CDRs not yet exported by its previous runs
+//		{
+//			"id": "daily_export",					// job identifier, unique, prefixes the export ids
+//			"export_template": "*default",			// cdre template used for the export
+//			"cdr_filter": "",						// export only the CDRs matching the filter
+//			"schedule": "0 1 * * *",				// cron expression scheduling the runs
+//			"export_dir": "",						// overwrites export_dir of the template, empty to use it as it is
+//		},
+	],
+},
+
+
 "cdrc": {
 	"*default": {
 		"enabled": false,							// enable CDR client functionality
 		"dry_run": false,							// do not send the CDRs to CDRS, just parse them
 		"cdrs": "internal",							// address where to reach CDR server. <internal|x.y.z.y:1234>
-		"cdr_format": "csv",						// CDR file format <csv|freeswitch_csv|fwv|opensips_flatstore>
+		"cdr_format": "csv",						// CDR file format <csv|freeswitch_csv|fwv|opensips_flatstore|xml|json|ndjson|asn1_ber>
 		"field_separator": ",",						// separator used in case of csv files
 		"timezone": "",								// timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
 		"run_delay": 0,								// sleep interval in seconds between consecutive runs, 0 to use automation via inotify
@@ -179,6 +240,16 @@ const CGRATES_CFG_JSON = `
 		"cdr_filter": "",							// filter CDR records to import
 		"continue_on_success": false,				// continue to the next template if executed
 		"partial_record_cache": "10s",				// duration to cache partial records when not pairing
+		"cdr_path": "",								// path towards one CDR element in case of XML CDR files, levels separated by > (eg: broadWorksCDR>cdrData)
+		"journal_dir": "",							// keep here the journal of processed files so processing resumes after restarts and duplicates are detected, empty to disable
+		"journal_retention": "720h",				// drop from the journal the files completed longer ago than this, 0 to keep them forever
+		"quarantine_dir": "",						// move here the files already processed according to the journal and the corrupted ones, empty to reject the first into cdr_out_dir and leave the second in place
+		"write_rejects": false,						// write the records failing processing into a .rejects file in cdr_out_dir
+		"batch_size": 100,							// number of CDRs posted to CDRS in one request
+		"batch_concurrency": 1,						// maximum number of batches posted simultaneously, 0 for no limit
+		"retry_interval": "1s",						// pause before posting again a batch refused by CDRS, doubled on each retry
+		"tmp_dir": "",								// extract here the archive members which cannot be streamed, empty for the system temporary folder
+		"max_extracted_size": 1073741824,			// maximum number of bytes decompressed out of one archive, 0 for unlimited
 		"header_fields": [],						// template of the import header fields
 		"content_fields":[							// import content_fields template, tag will match internally CDR field, in case of .csv value will be represented by index of the field value
 			{"tag": "tor", "field_id": "TOR", "type": "*composed", "value": "2", "mandatory": true},
@@ -203,9 +274,12 @@ const CGRATES_CFG_JSON = `
 	"listen_bijson": "127.0.0.1:2014",		// address where to listen for bidirectional JSON-RPC requests
 	"rater": "internal",					// address where to reach the Rater <""|internal|127.0.0.1:2013>
 	"cdrs": "internal",						// address where to reach CDR Server <""|internal|x.y.z.y:1234>
+	"pubsubs": "",							// address where to reach the pubusb service, empty to disable session warning events <""|internal|x.y.z.y:1234>
 	"debit_interval": "0s",					// interval to perform debits on.
 	"min_call_duration": "0s",				// only authorize calls with allowed duration higher than this
 	"max_call_duration": "3h",				// maximum call duration a prepaid call can last
+	"min_dur_low_balance": "0s",			// warn the session when the remaining usage goes under this threshold, 0 to disable
+	"min_cost_low_balance": 0,				// warn the session when the remaining credit goes under this threshold, 0 to disable
 },
 
 
@@ -264,13 +338,26 @@ const CGRATES_CFG_JSON = `
 	"listen": "127.0.0.1:3868",									// address where to listen for diameter requests <x.y.z.y:1234>
 	"dictionaries_dir": "/usr/share/cgrates/diameter/dict/",	// path towards directory holding additional dictionaries to load
 	"sm_generic": "internal",									// connection towards SMG component for session management
+	"sm_generic_failover": [],									// connections towards SMG components used in order when the previous ones are unreachable
 	"debit_interval": "5m",										// interval for CCR updates
+	"watchdog_interval": "30s",									// interval for Device-Watchdog-Requests towards idle peers, 0 to disable
 	"timezone": "",												// timezone for timestamps where not specified, empty for general defaults <""|UTC|Local|$IANA_TZ_DB>
 	"dialect": "huawei",										// the diameter dialect used in the communication, supported: <huawei>
 	"origin_host": "CGR-DA",									// diameter Origin-Host AVP used in replies
 	"origin_realm": "cgrates.org",								// diameter Origin-Realm AVP used in replies
 	"vendor_id": 0,												// diameter Vendor-Id AVP used in replies
 	"product_name": "CGRateS",									// diameter Product-Name AVP used in replies
+	"final_unit_action": "",									// Final-Unit-Action sent when granting the last units, empty to disable <""|*terminate|*redirect>
+	"redirect_address_type": 2,									// Redirect-Address-Type used with *redirect <0-IPv4|1-IPv6|2-URL|3-SIP_URI>
+	"redirect_address": "",										// Redirect-Server-Address used with *redirect
+	"validity_time": "0s",										// Validity-Time sent within each Multiple-Services-Credit-Control, 0 to disable
+	"session_ttl": "3h",										// MSCC usage of sessions without CCR-T is dropped after this inactivity
+	"result_codes": [											// Result-Code sent back on errors, first error contained in the message wins, unmapped errors answered with 5012
+		{"error": "INSUFFICIENT_CREDIT", "result_code": 4012},	// DIAMETER_CREDIT_LIMIT_REACHED
+		{"error": "ACCOUNT_DISABLED", "result_code": 4010},		// DIAMETER_END_USER_SERVICE_DENIED
+		{"error": "UNAUTHORIZED_DESTINATION", "result_code": 5031},	// DIAMETER_RATING_FAILED
+		{"error": "AccountNotFound", "result_code": 5030},		// DIAMETER_USER_UNKNOWN
+	],
 	"request_processors": [
 		{
 			"id": "*default",									// formal identifier of this processor
@@ -292,6 +379,46 @@ const CGRATES_CFG_JSON = `
 				{"tag": "usage", "field_id": "Usage", "type": "*handler", "handler_id": "*ccr_usage", "mandatory": true},
 				{"tag": "subscriber_id", "field_id": "SubscriberId", "type": "*composed", "value": "Subscription-Id>Subscription-Id-Data", "mandatory": true},
 			],
+			"cca_fields":[],							// additional AVPs in answer, field_id being the AVP path, eg: Service-Information>IN-Information>Time-Zone
+		},
+	],
+},
+
+
+"radius_agent": {
+	"enabled": false,											// enables the radius agent: <true|false>
+	"listen_auth": "127.0.0.1:1812",							// address where to listen for Access-Requests <x.y.z.y:1234>
+	"listen_acct": "127.0.0.1:1813",							// address where to listen for Accounting-Requests <x.y.z.y:1234>
+	"client_secrets": {},										// shared secrets indexed on client IP, *default used for the clients not listed
+	"dictionaries_dir": "/usr/share/cgrates/radius/dict/",		// path towards directory holding additional dictionaries to load
+	"sm_generic": "internal",									// BiJSON connection towards SMG component, needed for session disconnects <internal|x.y.z.y:1234>
+	"disconnect_port": 3799,									// port on NAS receiving Disconnect-Request and CoA-Request
+	"session_ttl": "3h",										// sessions without Accounting-Stop are dropped after this inactivity, keep it above the interim interval
+	"timezone": "",												// timezone for timestamps where not specified, empty for general defaults <""|UTC|Local|$IANA_TZ_DB>
+	"request_processors": [
+		{
+			"id": "*default",									// formal identifier of this processor
+			"dry_run": false,									// do not send the events to SMG, just parse them
+			"request_filter": "",								// filter requests processed by this processor
+			"continue_on_success": false,						// continue to the next template if executed
+			"content_fields":[									// import content_fields template, tag will match internally CDR field, value being the attribute name
+				{"tag": "tor", "field_id": "TOR", "type": "*composed", "value": "^*voice", "mandatory": true},
+				{"tag": "accid", "field_id": "AccId", "type": "*composed", "value": "Acct-Session-Id"},
+				{"tag": "reqtype", "field_id": "ReqType", "type": "*composed", "value": "^*users", "mandatory": true},
+				{"tag": "direction", "field_id": "Direction", "type": "*composed", "value": "^*out", "mandatory": true},
+				{"tag": "tenant", "field_id": "Tenant", "type": "*composed", "value": "^*users", "mandatory": true},
+				{"tag": "category", "field_id": "Category", "type": "*composed", "value": "^call", "mandatory": true},
+				{"tag": "account", "field_id": "Account", "type": "*composed", "value": "^*users", "mandatory": true},
+				{"tag": "subject", "field_id": "Subject", "type": "*composed", "value": "^*users", "mandatory": true},
+				{"tag": "destination", "field_id": "Destination", "type": "*composed", "value": "Called-Station-Id", "mandatory": true},
+				{"tag": "setup_time", "field_id": "SetupTime", "type": "*handler", "handler_id": "*radius_answer_time", "mandatory": true},
+				{"tag": "answer_time", "field_id": "AnswerTime", "type": "*handler", "handler_id": "*radius_answer_time", "mandatory": true},
+				{"tag": "usage", "field_id": "Usage", "type": "*handler", "handler_id": "*radius_usage", "mandatory": true},
+				{"tag": "subscriber_id", "field_id": "SubscriberId", "type": "*composed", "value": "User-Name", "mandatory": true},
+			],
+			"reply_fields":[									// attributes in Access-Accept, field_id being the attribute name
+				{"tag": "session_timeout", "field_id": "Session-Timeout", "type": "*handler", "handler_id": "*radreply_usage"},
+			],
 		},
 	],
 },
@@ -29,8 +29,12 @@ import (
 const (
 	GENERAL_JSN     = "general"
 	LISTEN_JSN      = "listen"
+	AUTH_JSN        = "auth"
+	RPC_CLIENT_JSN  = "rpc_client"
 	TPDB_JSN        = "tariffplan_db"
 	DATADB_JSN      = "data_db"
+	CACHE_JSN       = "cache"
+	CACHE_SYNC_JSN  = "cache_sync"
 	STORDB_JSN      = "stor_db"
 	BALANCER_JSN    = "balancer"
 	RATER_JSN       = "rater"
@@ -39,6 +43,7 @@ const (
 	MEDIATOR_JSN    = "mediator"
 	CDRSTATS_JSN    = "cdrstats"
 	CDRE_JSN        = "cdre"
+	CDRE_JOBS_JSN   = "cdre_jobs"
 	CDRC_JSN        = "cdrc"
 	SMGENERIC_JSON  = "sm_generic"
 	SMFS_JSN        = "sm_freeswitch"
@@ -49,6 +54,7 @@ const (
 	KAMAILIO_JSN    = "kamailio"
 	OSIPS_JSN       = "opensips"
 	DA_JSN          = "diameter_agent"
+	RA_JSN          = "radius_agent"
 	HISTSERV_JSN    = "historys"
 	PUBSUBSERV_JSN  = "pubsubs"
 	ALIASESSERV_JSN = "aliases"
@@ -104,6 +110,30 @@ func (self CgrJsonCfg) ListenJsonCfg() (*ListenJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) AuthJsonCfg() (*AuthJsonCfg, error) {
+	rawCfg, hasKey := self[AUTH_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(AuthJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (self CgrJsonCfg) RpcClientJsonCfg() (*RpcClientJsonCfg, error) {
+	rawCfg, hasKey := self[RPC_CLIENT_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(RpcClientJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) DbJsonCfg(section string) (*DbJsonCfg, error) {
 	rawCfg, hasKey := self[section]
 	if !hasKey {
@@ -116,6 +146,30 @@ func (self CgrJsonCfg) DbJsonCfg(section string) (*DbJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) CacheJsonCfg() (map[string]*CacheParamJsonCfg, error) {
+	rawCfg, hasKey := self[CACHE_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := make(map[string]*CacheParamJsonCfg)
+	if err := json.Unmarshal(*rawCfg, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (self CgrJsonCfg) CacheSyncJsonCfg() (*CacheSyncJsonCfg, error) {
+	rawCfg, hasKey := self[CACHE_SYNC_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(CacheSyncJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) BalancerJsonCfg() (*BalancerJsonCfg, error) {
 	rawCfg, hasKey := self[BALANCER_JSN]
 	if !hasKey {
@@ -188,6 +242,18 @@ func (self CgrJsonCfg) CdreJsonCfgs() (map[string]*CdreJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) CdreJobsJsonCfg() (*CdreJobsJsonCfg, error) {
+	rawCfg, hasKey := self[CDRE_JOBS_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(CdreJobsJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) CdrcJsonCfg() (map[string]*CdrcJsonCfg, error) {
 	rawCfg, hasKey := self[CDRC_JSN]
 	if !hasKey {
@@ -260,6 +326,18 @@ func (self CgrJsonCfg) DiameterAgentJsonCfg() (*DiameterAgentJsonCfg, error) {
 	return cfg, nil
 }
 
+func (self CgrJsonCfg) RadiusAgentJsonCfg() (*RadiusAgentJsonCfg, error) {
+	rawCfg, hasKey := self[RA_JSN]
+	if !hasKey {
+		return nil, nil
+	}
+	cfg := new(RadiusAgentJsonCfg)
+	if err := json.Unmarshal(*rawCfg, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 func (self CgrJsonCfg) HistServJsonCfg() (*HistServJsonCfg, error) {
 	rawCfg, hasKey := self[HISTSERV_JSN]
 	if !hasKey {
@@ -61,9 +61,12 @@ func TestDfGeneralJsonCfg(t *testing.T) {
 
 func TestDfListenJsonCfg(t *testing.T) {
 	eCfg := &ListenJsonCfg{
-		Rpc_json: utils.StringPointer("127.0.0.1:2012"),
-		Rpc_gob:  utils.StringPointer("127.0.0.1:2013"),
-		Http:     utils.StringPointer("127.0.0.1:2080")}
+		Rpc_json:        utils.StringPointer("127.0.0.1:2012"),
+		Rpc_gob:         utils.StringPointer("127.0.0.1:2013"),
+		Http:            utils.StringPointer("127.0.0.1:2080"),
+		Tls_certificate: utils.StringPointer(""),
+		Tls_key:         utils.StringPointer(""),
+		Tls_client_ca:   utils.StringPointer("")}
 	if cfg, err := dfCgrJsonCfg.ListenJsonCfg(); err != nil {
 		t.Error(err)
 	} else if !reflect.DeepEqual(eCfg, cfg) {
@@ -71,6 +74,25 @@ func TestDfListenJsonCfg(t *testing.T) {
 	}
 }
 
+func TestDfAuthJsonCfg(t *testing.T) {
+	eCfg := &AuthJsonCfg{Enabled: utils.BoolPointer(false), Credentials: &[]*AuthCredentialJsonCfg{}}
+	if cfg, err := dfCgrJsonCfg.AuthJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", utils.ToJSON(cfg))
+	}
+}
+
+func TestDfRpcClientJsonCfg(t *testing.T) {
+	eCfg := &RpcClientJsonCfg{Tls: utils.BoolPointer(false), Tls_ca: utils.StringPointer(""), Tls_certificate: utils.StringPointer(""),
+		Tls_key: utils.StringPointer(""), Api_key: utils.StringPointer(""), Username: utils.StringPointer(""), Password: utils.StringPointer("")}
+	if cfg, err := dfCgrJsonCfg.RpcClientJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", utils.ToJSON(cfg))
+	}
+}
+
 func TestDfDbJsonCfg(t *testing.T) {
 	eCfg := &DbJsonCfg{
 		Db_type:   utils.StringPointer("redis"),
@@ -100,14 +122,18 @@ func TestDfDbJsonCfg(t *testing.T) {
 		t.Error("Received: ", cfg)
 	}
 	eCfg = &DbJsonCfg{
-		Db_type:        utils.StringPointer("mysql"),
-		Db_host:        utils.StringPointer("127.0.0.1"),
-		Db_port:        utils.IntPointer(3306),
-		Db_name:        utils.StringPointer("cgrates"),
-		Db_user:        utils.StringPointer("cgrates"),
-		Db_passwd:      utils.StringPointer("CGRateS.org"),
-		Max_open_conns: utils.IntPointer(100),
-		Max_idle_conns: utils.IntPointer(10),
+		Db_type:               utils.StringPointer("mysql"),
+		Db_host:               utils.StringPointer("127.0.0.1"),
+		Db_port:               utils.IntPointer(3306),
+		Db_name:               utils.StringPointer("cgrates"),
+		Db_user:               utils.StringPointer("cgrates"),
+		Db_passwd:             utils.StringPointer("CGRateS.org"),
+		Max_open_conns:        utils.IntPointer(100),
+		Max_idle_conns:        utils.IntPointer(10),
+		Cdrs_partitioning:     utils.StringPointer(""),
+		Cdrs_retention:        utils.StringPointer("0"),
+		Cdrs_retention_action: utils.StringPointer(utils.META_DELETE),
+		Cdrs_archive_dir:      utils.StringPointer("/var/spool/cgrates/cdrs_archive"),
 	}
 	if cfg, err := dfCgrJsonCfg.DbJsonCfg(STORDB_JSN); err != nil {
 		t.Error(err)
@@ -116,6 +142,33 @@ func TestDfDbJsonCfg(t *testing.T) {
 	}
 }
 
+func TestDfCacheJsonCfg(t *testing.T) {
+	eCfg := map[string]*CacheParamJsonCfg{
+		utils.CACHE_DESTINATIONS:     &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+		utils.CACHE_RATING_PLANS:     &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+		utils.CACHE_RATING_PROFILES:  &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+		utils.CACHE_ACTIONS:          &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+		utils.CACHE_SHARED_GROUPS:    &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+		utils.CACHE_DERIVED_CHARGERS: &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+		utils.CACHE_LCR:              &CacheParamJsonCfg{Limit: utils.IntPointer(0), Ttl: utils.StringPointer("0s"), Partial: utils.BoolPointer(false)},
+	}
+	if cfg, err := dfCgrJsonCfg.CacheJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", utils.ToJSON(cfg))
+	}
+}
+
+func TestDfCacheSyncJsonCfg(t *testing.T) {
+	eCfg := &CacheSyncJsonCfg{Enabled: utils.BoolPointer(false), Node_id: utils.StringPointer(""),
+		Notifications: utils.BoolPointer(true), Poll_interval: utils.StringPointer("10s")}
+	if cfg, err := dfCgrJsonCfg.CacheSyncJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", cfg)
+	}
+}
+
 func TestDfBalancerJsonCfg(t *testing.T) {
 	eCfg := &BalancerJsonCfg{Enabled: utils.BoolPointer(false)}
 	if cfg, err := dfCgrJsonCfg.BalancerJsonCfg(); err != nil {
@@ -146,15 +199,16 @@ func TestDfSchedulerJsonCfg(t *testing.T) {
 
 func TestDfCdrsJsonCfg(t *testing.T) {
 	eCfg := &CdrsJsonCfg{
-		Enabled:         utils.BoolPointer(false),
-		Extra_fields:    utils.StringSlicePointer([]string{}),
-		Store_cdrs:      utils.BoolPointer(true),
-		Rater:           utils.StringPointer("internal"),
-		Pubsubs:         utils.StringPointer(""),
-		Users:           utils.StringPointer(""),
-		Aliases:         utils.StringPointer(""),
-		Cdrstats:        utils.StringPointer(""),
-		Cdr_replication: &[]*CdrReplicationJsonCfg{},
+		Enabled:            utils.BoolPointer(false),
+		Extra_fields:       utils.StringSlicePointer([]string{}),
+		Store_cdrs:         utils.BoolPointer(true),
+		Derive_concurrency: utils.IntPointer(1000),
+		Rater:              utils.StringPointer("internal"),
+		Pubsubs:            utils.StringPointer(""),
+		Users:              utils.StringPointer(""),
+		Aliases:            utils.StringPointer(""),
+		Cdrstats:           utils.StringPointer(""),
+		Cdr_replication:    &[]*CdrReplicationJsonCfg{},
 	}
 	if cfg, err := dfCgrJsonCfg.CdrsJsonCfg(); err != nil {
 		t.Error(err)
@@ -254,6 +308,9 @@ func TestDfCdreJsonCfgs(t *testing.T) {
 			Mask_destination_id:           utils.StringPointer("MASKED_DESTINATIONS"),
 			Mask_length:                   utils.IntPointer(0),
 			Export_dir:                    utils.StringPointer("/var/log/cgrates/cdre"),
+			Export_page_size:              utils.IntPointer(10000),
+			Compression:                   utils.StringPointer(""),
+			Manifest:                      utils.BoolPointer(false),
 			Header_fields:                 &eFields,
 			Content_fields:                &eContentFlds,
 			Trailer_fields:                &eFields,
@@ -266,6 +323,37 @@ func TestDfCdreJsonCfgs(t *testing.T) {
 	}
 }
 
+func TestDfCdreJobsJsonCfg(t *testing.T) {
+	eCfg := &CdreJobsJsonCfg{
+		Enabled: utils.BoolPointer(false),
+		Jobs:    &[]*CdreJobJsonCfg{},
+	}
+	if cfg, err := dfCgrJsonCfg.CdreJobsJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", utils.ToJSON(cfg))
+	}
+	jsnCfg := &CdreJobsJsonCfg{
+		Enabled: utils.BoolPointer(true),
+		Jobs: &[]*CdreJobJsonCfg{
+			&CdreJobJsonCfg{Id: utils.StringPointer("daily"), Export_template: utils.StringPointer(utils.META_DEFAULT),
+				Cdr_filter: utils.StringPointer(`~MediationRunId:s/^\*default$//`), Schedule: utils.StringPointer("0 1 * * *")},
+		},
+	}
+	cdreJobsCfg := new(CdreJobsCfg)
+	if err := cdreJobsCfg.loadFromJsonCfg(jsnCfg); err != nil {
+		t.Fatal(err)
+	}
+	jsnCfg.Jobs = &[]*CdreJobJsonCfg{&CdreJobJsonCfg{Id: utils.StringPointer("daily"), Export_dir: utils.StringPointer("/tmp/cdre")}} // Update the job already set
+	if err := cdreJobsCfg.loadFromJsonCfg(jsnCfg); err != nil {
+		t.Fatal(err)
+	}
+	if job := cdreJobsCfg.Job("daily"); len(cdreJobsCfg.Jobs) != 1 || job == nil || job.ExportTemplate != utils.META_DEFAULT ||
+		len(job.CdrFilter) != 1 || job.Schedule != "0 1 * * *" || job.ExportDir != "/tmp/cdre" {
+		t.Errorf("Received: %s", utils.ToJSON(cdreJobsCfg))
+	}
+}
+
 func TestDfCdrcJsonCfg(t *testing.T) {
 	eFields := []*CdrFieldJsonCfg{}
 	cdrFields := []*CdrFieldJsonCfg{
@@ -312,6 +400,16 @@ func TestDfCdrcJsonCfg(t *testing.T) {
 			Cdr_filter:                 utils.StringPointer(""),
 			Continue_on_success:        utils.BoolPointer(false),
 			Partial_record_cache:       utils.StringPointer("10s"),
+			Cdr_path:                   utils.StringPointer(""),
+			Journal_dir:                utils.StringPointer(""),
+			Journal_retention:          utils.StringPointer("720h"),
+			Quarantine_dir:             utils.StringPointer(""),
+			Write_rejects:              utils.BoolPointer(false),
+			Batch_size:                 utils.IntPointer(100),
+			Batch_concurrency:          utils.IntPointer(1),
+			Retry_interval:             utils.StringPointer("1s"),
+			Tmp_dir:                    utils.StringPointer(""),
+			Max_extracted_size:         utils.Int64Pointer(1073741824),
 			Header_fields:              &eFields,
 			Content_fields:             &cdrFields,
 			Trailer_fields:             &eFields,
@@ -326,13 +424,16 @@ func TestDfCdrcJsonCfg(t *testing.T) {
 
 func TestSmGenericJsonCfg(t *testing.T) {
 	eCfg := &SmGenericJsonCfg{
-		Enabled:           utils.BoolPointer(false),
-		Listen_bijson:     utils.StringPointer("127.0.0.1:2014"),
-		Rater:             utils.StringPointer("internal"),
-		Cdrs:              utils.StringPointer("internal"),
-		Debit_interval:    utils.StringPointer("0s"),
-		Min_call_duration: utils.StringPointer("0s"),
-		Max_call_duration: utils.StringPointer("3h"),
+		Enabled:              utils.BoolPointer(false),
+		Listen_bijson:        utils.StringPointer("127.0.0.1:2014"),
+		Rater:                utils.StringPointer("internal"),
+		Cdrs:                 utils.StringPointer("internal"),
+		Pubsubs:              utils.StringPointer(""),
+		Debit_interval:       utils.StringPointer("0s"),
+		Min_call_duration:    utils.StringPointer("0s"),
+		Max_call_duration:    utils.StringPointer("3h"),
+		Min_dur_low_balance:  utils.StringPointer("0s"),
+		Min_cost_low_balance: utils.Float64Pointer(0),
 	}
 	if cfg, err := dfCgrJsonCfg.SmGenericJsonCfg(); err != nil {
 		t.Error(err)
@@ -416,17 +517,29 @@ func TestSmOsipsJsonCfg(t *testing.T) {
 
 func TestDiameterAgentJsonCfg(t *testing.T) {
 	eCfg := &DiameterAgentJsonCfg{
-		Enabled:          utils.BoolPointer(false),
-		Listen:           utils.StringPointer("127.0.0.1:3868"),
-		Dictionaries_dir: utils.StringPointer("/usr/share/cgrates/diameter/dict/"),
-		Sm_generic:       utils.StringPointer("internal"),
-		Debit_interval:   utils.StringPointer("5m"),
-		Timezone:         utils.StringPointer(""),
-		Dialect:          utils.StringPointer("huawei"),
-		Origin_host:      utils.StringPointer("CGR-DA"),
-		Origin_realm:     utils.StringPointer("cgrates.org"),
-		Vendor_id:        utils.IntPointer(0),
-		Product_name:     utils.StringPointer("CGRateS"),
+		Enabled:               utils.BoolPointer(false),
+		Listen:                utils.StringPointer("127.0.0.1:3868"),
+		Dictionaries_dir:      utils.StringPointer("/usr/share/cgrates/diameter/dict/"),
+		Sm_generic:            utils.StringPointer("internal"),
+		Sm_generic_failover:   &[]string{},
+		Debit_interval:        utils.StringPointer("5m"),
+		Watchdog_interval:     utils.StringPointer("30s"),
+		Timezone:              utils.StringPointer(""),
+		Dialect:               utils.StringPointer("huawei"),
+		Origin_host:           utils.StringPointer("CGR-DA"),
+		Origin_realm:          utils.StringPointer("cgrates.org"),
+		Vendor_id:             utils.IntPointer(0),
+		Product_name:          utils.StringPointer("CGRateS"),
+		Final_unit_action:     utils.StringPointer(""),
+		Redirect_address_type: utils.IntPointer(2),
+		Redirect_address:      utils.StringPointer(""),
+		Validity_time:         utils.StringPointer("0s"),
+		Session_ttl:           utils.StringPointer("3h"),
+		Result_codes: &[]*DAResultCodeJsonCfg{
+			&DAResultCodeJsonCfg{Error: utils.StringPointer("INSUFFICIENT_CREDIT"), Result_code: utils.IntPointer(4012)},
+			&DAResultCodeJsonCfg{Error: utils.StringPointer("ACCOUNT_DISABLED"), Result_code: utils.IntPointer(4010)},
+			&DAResultCodeJsonCfg{Error: utils.StringPointer("UNAUTHORIZED_DESTINATION"), Result_code: utils.IntPointer(5031)},
+			&DAResultCodeJsonCfg{Error: utils.StringPointer("AccountNotFound"), Result_code: utils.IntPointer(5030)}},
 		Request_processors: &[]*DARequestProcessorJsnCfg{
 			&DARequestProcessorJsnCfg{
 				Id:                  utils.StringPointer("*default"),
@@ -461,6 +574,7 @@ func TestDiameterAgentJsonCfg(t *testing.T) {
 					&CdrFieldJsonCfg{Tag: utils.StringPointer("subscriber_id"), Field_id: utils.StringPointer("SubscriberId"), Type: utils.StringPointer(utils.META_COMPOSED),
 						Value: utils.StringPointer("Subscription-Id>Subscription-Id-Data"), Mandatory: utils.BoolPointer(true)},
 				},
+				Cca_fields: &[]*CdrFieldJsonCfg{},
 			},
 		},
 	}
@@ -471,6 +585,65 @@ func TestDiameterAgentJsonCfg(t *testing.T) {
 	}
 }
 
+func TestRadiusAgentJsonCfg(t *testing.T) {
+	eCfg := &RadiusAgentJsonCfg{
+		Enabled:          utils.BoolPointer(false),
+		Listen_auth:      utils.StringPointer("127.0.0.1:1812"),
+		Listen_acct:      utils.StringPointer("127.0.0.1:1813"),
+		Client_secrets:   &map[string]string{},
+		Dictionaries_dir: utils.StringPointer("/usr/share/cgrates/radius/dict/"),
+		Sm_generic:       utils.StringPointer("internal"),
+		Disconnect_port:  utils.IntPointer(3799),
+		Session_ttl:      utils.StringPointer("3h"),
+		Timezone:         utils.StringPointer(""),
+		Request_processors: &[]*RARequestProcessorJsnCfg{
+			&RARequestProcessorJsnCfg{
+				Id:                  utils.StringPointer("*default"),
+				Dry_run:             utils.BoolPointer(false),
+				Request_filter:      utils.StringPointer(""),
+				Continue_on_success: utils.BoolPointer(false),
+				Content_fields: &[]*CdrFieldJsonCfg{
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("tor"), Field_id: utils.StringPointer(utils.TOR), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^*voice"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("accid"), Field_id: utils.StringPointer(utils.ACCID), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("Acct-Session-Id")},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("reqtype"), Field_id: utils.StringPointer(utils.REQTYPE), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^*users"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("direction"), Field_id: utils.StringPointer(utils.DIRECTION), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^*out"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("tenant"), Field_id: utils.StringPointer(utils.TENANT), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^*users"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("category"), Field_id: utils.StringPointer(utils.CATEGORY), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^call"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("account"), Field_id: utils.StringPointer(utils.ACCOUNT), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^*users"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("subject"), Field_id: utils.StringPointer(utils.SUBJECT), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("^*users"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("destination"), Field_id: utils.StringPointer(utils.DESTINATION), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("Called-Station-Id"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("setup_time"), Field_id: utils.StringPointer(utils.SETUP_TIME), Type: utils.StringPointer(utils.META_HANDLER),
+						Handler_id: utils.StringPointer("*radius_answer_time"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("answer_time"), Field_id: utils.StringPointer(utils.ANSWER_TIME), Type: utils.StringPointer(utils.META_HANDLER),
+						Handler_id: utils.StringPointer("*radius_answer_time"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("usage"), Field_id: utils.StringPointer(utils.USAGE), Type: utils.StringPointer(utils.META_HANDLER),
+						Handler_id: utils.StringPointer("*radius_usage"), Mandatory: utils.BoolPointer(true)},
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("subscriber_id"), Field_id: utils.StringPointer("SubscriberId"), Type: utils.StringPointer(utils.META_COMPOSED),
+						Value: utils.StringPointer("User-Name"), Mandatory: utils.BoolPointer(true)},
+				},
+				Reply_fields: &[]*CdrFieldJsonCfg{
+					&CdrFieldJsonCfg{Tag: utils.StringPointer("session_timeout"), Field_id: utils.StringPointer("Session-Timeout"), Type: utils.StringPointer(utils.META_HANDLER),
+						Handler_id: utils.StringPointer("*radreply_usage")},
+				},
+			},
+		},
+	}
+	if cfg, err := dfCgrJsonCfg.RadiusAgentJsonCfg(); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(eCfg, cfg) {
+		t.Error("Received: ", cfg)
+	}
+}
+
 func TestDfHistServJsonCfg(t *testing.T) {
 	eCfg := &HistServJsonCfg{
 		Enabled:       utils.BoolPointer(false),
@@ -21,6 +21,9 @@ package config
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
 )
 
 var cfg *CGRConfig
@@ -58,3 +61,119 @@ func TestLoadCgrCfgWithDefaults(t *testing.T) {
 		t.Errorf("Expected: %+v, received: %+v", eCgrCfg.SmFsConfig, cgrCfg.SmFsConfig)
 	}
 }
+
+func TestCacheConfigSanity(t *testing.T) {
+	cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(`{"cache": {"rating_plans": {"limit": 1000, "ttl": "1h", "partial": true}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eCacheCfg := &CacheParamConfig{Limit: 1000, TTL: time.Duration(1) * time.Hour, Partial: true}
+	if !reflect.DeepEqual(eCacheCfg, cgrCfg.CacheConfig[utils.CACHE_RATING_PLANS]) {
+		t.Errorf("Expected: %+v, received: %+v", eCacheCfg, cgrCfg.CacheConfig[utils.CACHE_RATING_PLANS])
+	}
+	if err := cgrCfg.checkConfigSanity(); err != nil {
+		t.Error(err)
+	}
+	cgrCfg.CacheConfig[utils.CACHE_RATING_PLANS].Partial = false
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on limited cache not partial")
+	}
+	cgrCfg.CacheConfig[utils.CACHE_RATING_PLANS].Partial = true
+	cgrCfg.CacheConfig[utils.CACHE_DESTINATIONS] = &CacheParamConfig{Limit: 10000, Partial: true}
+	if err := cgrCfg.checkConfigSanity(); err != nil {
+		t.Error(err)
+	}
+	cgrCfg.CacheConfig[utils.CACHE_ALIASES] = &CacheParamConfig{Partial: true}
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on aliases partially cached")
+	}
+}
+
+func TestCacheSyncConfigSanity(t *testing.T) {
+	cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(`{"tariffplan_db": {"db_type": "mongo"}, "cache_sync": {"enabled": true}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on notifications with mongo tariffplan_db")
+	}
+	cgrCfg.CacheSyncNotifications = false
+	if err := cgrCfg.checkConfigSanity(); err != nil {
+		t.Error(err)
+	}
+	cgrCfg.CacheSyncPollInterval = 0
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on cache sync without notifications or poll_interval")
+	}
+}
+
+func TestInternalDbConfigSanity(t *testing.T) {
+	cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(`{"data_db": {"db_type": "*internal"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on default db_name")
+	}
+	for _, dbName := range []string{"", "/var/lib/cgrates/data_db"} {
+		cgrCfg.DataDbName = dbName
+		if err := cgrCfg.checkConfigSanity(); err != nil {
+			t.Errorf("db_name: %s, error: %s", dbName, err)
+		}
+	}
+}
+
+func TestAuthConfigSanity(t *testing.T) {
+	cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(`{"auth": {"enabled": true, "credentials": [
+		{"api_key": "ro_key", "permissions": ["ApierV1.Get*"]},
+		{"username": "admin", "password": "secret", "permissions": ["*"]}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eCreds := []*utils.AuthCredential{
+		&utils.AuthCredential{ApiKey: "ro_key", Permissions: []string{"ApierV1.Get*"}},
+		&utils.AuthCredential{Username: "admin", Password: "secret", Permissions: []string{"*"}},
+	}
+	if !reflect.DeepEqual(eCreds, cgrCfg.AuthCredentials) {
+		t.Errorf("Expected: %s, received: %s", utils.ToJSON(eCreds), utils.ToJSON(cgrCfg.AuthCredentials))
+	}
+	if err := cgrCfg.checkConfigSanity(); err != nil {
+		t.Error(err)
+	}
+	cgrCfg.AuthCredentials[1].Password = ""
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on username without password")
+	}
+	cgrCfg.AuthCredentials[1].Password = "secret"
+	cgrCfg.AuthCredentials[0].Permissions = []string{"ApierV1.Get*", ""}
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on empty permission")
+	}
+	cgrCfg.AuthCredentials[0].Permissions = []string{"ApierV1.Get*"}
+	cgrCfg.TLSClientCA = "/etc/cgrates/ca.pem"
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on client CA without certificate")
+	}
+}
+
+func TestRpcClientConfigSanity(t *testing.T) {
+	cgrCfg, err := NewCGRConfigFromJsonStringWithDefaults(`{"rpc_client": {"tls": true, "tls_ca": "/etc/cgrates/ca.pem", "api_key": "sm_key"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cgrCfg.RpcClientTLS || cgrCfg.RpcClientTLSCA != "/etc/cgrates/ca.pem" || cgrCfg.RpcClientApiKey != "sm_key" {
+		t.Errorf("Unexpected rpc client config, tls: %v, tls_ca: %s, api_key: %s", cgrCfg.RpcClientTLS, cgrCfg.RpcClientTLSCA, cgrCfg.RpcClientApiKey)
+	}
+	if err := cgrCfg.checkConfigSanity(); err != nil {
+		t.Error(err)
+	}
+	cgrCfg.RpcClientTLSCertificate = "/etc/cgrates/client.pem"
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on client certificate without key")
+	}
+	cgrCfg.RpcClientTLSKey = "/etc/cgrates/client.key"
+	cgrCfg.RpcClientTLS = false
+	if err := cgrCfg.checkConfigSanity(); err == nil {
+		t.Error("Expecting error on client certificate without tls")
+	}
+}
@@ -43,6 +43,11 @@ func TestLoadCdrcConfigMultipleFiles(t *testing.T) {
 			DataUsageMultiplyFactor: 1024,
 			RunDelay:                0,
 			MaxOpenFiles:            1024,
+			BatchSize:               100,
+			BatchConcurrency:        1,
+			RetryInterval:           time.Duration(1) * time.Second,
+			JournalRetention:        time.Duration(720) * time.Hour,
+			MaxExtractedSize:        1073741824,
 			CdrInDir:                "/var/log/cgrates/cdrc/in",
 			CdrOutDir:               "/var/log/cgrates/cdrc/out",
 			FailedCallsPrefix:       "missed_calls",
@@ -88,6 +93,11 @@ func TestLoadCdrcConfigMultipleFiles(t *testing.T) {
 			DataUsageMultiplyFactor: 1024,
 			RunDelay:                0,
 			MaxOpenFiles:            1024,
+			BatchSize:               100,
+			BatchConcurrency:        1,
+			RetryInterval:           time.Duration(1) * time.Second,
+			JournalRetention:        time.Duration(720) * time.Hour,
+			MaxExtractedSize:        1073741824,
 			CdrInDir:                "/tmp/cgrates/cdrc1/in",
 			CdrOutDir:               "/tmp/cgrates/cdrc1/out",
 			CdrSourceId:             "csv1",
@@ -131,6 +141,11 @@ func TestLoadCdrcConfigMultipleFiles(t *testing.T) {
 			DataUsageMultiplyFactor: 0.000976563,
 			RunDelay:                0,
 			MaxOpenFiles:            1024,
+			BatchSize:               100,
+			BatchConcurrency:        1,
+			RetryInterval:           time.Duration(1) * time.Second,
+			JournalRetention:        time.Duration(720) * time.Hour,
+			MaxExtractedSize:        1073741824,
 			CdrInDir:                "/tmp/cgrates/cdrc2/in",
 			CdrOutDir:               "/tmp/cgrates/cdrc2/out",
 			CdrSourceId:             "csv2",
@@ -154,6 +169,11 @@ func TestLoadCdrcConfigMultipleFiles(t *testing.T) {
 			DataUsageMultiplyFactor: 1024,
 			RunDelay:                0,
 			MaxOpenFiles:            1024,
+			BatchSize:               100,
+			BatchConcurrency:        1,
+			RetryInterval:           time.Duration(1) * time.Second,
+			JournalRetention:        time.Duration(720) * time.Hour,
+			MaxExtractedSize:        1073741824,
 			CdrInDir:                "/tmp/cgrates/cdrc3/in",
 			CdrOutDir:               "/tmp/cgrates/cdrc3/out",
 			CdrSourceId:             "csv3",
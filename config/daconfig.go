@@ -28,14 +28,21 @@ type DiameterAgentCfg struct {
 	Enabled           bool   // enables the diameter agent: <true|false>
 	Listen            string // address where to listen for diameter requests <x.y.z.y:1234>
 	DictionariesDir   string
-	SMGeneric         string // connection towards SMG component
+	HaSMGeneric       []*HaPoolConfig // connections towards SMG components, the ones after the first being used on failover
 	DebitInterval     time.Duration
-	Timezone          string // timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
-	Dialect           string // the diameter dialect used in the implementation <huawei>
+	WatchdogInterval  time.Duration // interval for Device-Watchdog-Requests towards idle peers, 0 to disable
+	Timezone          string        // timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
+	Dialect           string        // the diameter dialect used in the implementation <huawei>
 	OriginHost        string
 	OriginRealm       string
 	VendorId          int
 	ProductName       string
+	FinalUnitAction   string          // action requested via Final-Unit-Indication when granting the last units <""|*terminate|*redirect>
+	RedirectAddrType  int             // Redirect-Address-Type used with *redirect <0-IPv4|1-IPv6|2-URL|3-SIP_URI>
+	RedirectAddress   string          // Redirect-Server-Address used with *redirect
+	ValidityTime      time.Duration   // Validity-Time sent within each Multiple-Services-Credit-Control, 0 to disable
+	SessionTTL        time.Duration   // MSCC usage of sessions without CCR-T is dropped after this inactivity
+	ResultCodes       []*DAResultCode // Result-Code sent back for errors, first one contained in the error message being used
 	RequestProcessors []*DARequestProcessor
 }
 
@@ -53,7 +60,15 @@ func (self *DiameterAgentCfg) loadFromJsonCfg(jsnCfg *DiameterAgentJsonCfg) erro
 		self.DictionariesDir = *jsnCfg.Dictionaries_dir
 	}
 	if jsnCfg.Sm_generic != nil {
-		self.SMGeneric = *jsnCfg.Sm_generic
+		self.HaSMGeneric = []*HaPoolConfig{&HaPoolConfig{Server: *jsnCfg.Sm_generic, Timeout: time.Duration(1) * time.Second}}
+	}
+	if jsnCfg.Sm_generic_failover != nil {
+		if len(self.HaSMGeneric) != 0 { // Keep the primary connection
+			self.HaSMGeneric = self.HaSMGeneric[:1]
+		}
+		for _, smgAddr := range *jsnCfg.Sm_generic_failover {
+			self.HaSMGeneric = append(self.HaSMGeneric, &HaPoolConfig{Server: smgAddr, Timeout: time.Duration(1) * time.Second})
+		}
 	}
 	if jsnCfg.Debit_interval != nil {
 		var err error
@@ -61,6 +76,12 @@ func (self *DiameterAgentCfg) loadFromJsonCfg(jsnCfg *DiameterAgentJsonCfg) erro
 			return err
 		}
 	}
+	if jsnCfg.Watchdog_interval != nil {
+		var err error
+		if self.WatchdogInterval, err = utils.ParseDurationWithSecs(*jsnCfg.Watchdog_interval); err != nil {
+			return err
+		}
+	}
 	if jsnCfg.Timezone != nil {
 		self.Timezone = *jsnCfg.Timezone
 	}
@@ -76,6 +97,48 @@ func (self *DiameterAgentCfg) loadFromJsonCfg(jsnCfg *DiameterAgentJsonCfg) erro
 	if jsnCfg.Product_name != nil {
 		self.ProductName = *jsnCfg.Product_name
 	}
+	if jsnCfg.Final_unit_action != nil {
+		self.FinalUnitAction = *jsnCfg.Final_unit_action
+	}
+	if jsnCfg.Redirect_address_type != nil {
+		self.RedirectAddrType = *jsnCfg.Redirect_address_type
+	}
+	if jsnCfg.Redirect_address != nil {
+		self.RedirectAddress = *jsnCfg.Redirect_address
+	}
+	if jsnCfg.Validity_time != nil {
+		var err error
+		if self.ValidityTime, err = utils.ParseDurationWithSecs(*jsnCfg.Validity_time); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Session_ttl != nil {
+		var err error
+		if self.SessionTTL, err = utils.ParseDurationWithSecs(*jsnCfg.Session_ttl); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Result_codes != nil { // Configured ones are checked first, followed by the defaults they do not overwrite
+		resultCodes := make([]*DAResultCode, 0, len(*jsnCfg.Result_codes)+len(self.ResultCodes))
+		configured := make(map[string]bool)
+		for _, rcJsn := range *jsnCfg.Result_codes {
+			rc := new(DAResultCode)
+			if rcJsn.Error != nil {
+				rc.Error = *rcJsn.Error
+			}
+			if rcJsn.Result_code != nil {
+				rc.ResultCode = *rcJsn.Result_code
+			}
+			configured[rc.Error] = true
+			resultCodes = append(resultCodes, rc)
+		}
+		for _, rc := range self.ResultCodes {
+			if !configured[rc.Error] {
+				resultCodes = append(resultCodes, rc)
+			}
+		}
+		self.ResultCodes = resultCodes
+	}
 	if jsnCfg.Request_processors != nil {
 		for _, reqProcJsn := range *jsnCfg.Request_processors {
 			rp := new(DARequestProcessor)
@@ -94,6 +157,12 @@ func (self *DiameterAgentCfg) loadFromJsonCfg(jsnCfg *DiameterAgentJsonCfg) erro
 	return nil
 }
 
+// Result-Code sent back when the error message contains Error
+type DAResultCode struct {
+	Error      string
+	ResultCode int
+}
+
 // One Diameter request processor configuration
 type DARequestProcessor struct {
 	Id                string
@@ -101,6 +170,7 @@ type DARequestProcessor struct {
 	RequestFilter     utils.RSRFields
 	ContinueOnSuccess bool
 	ContentFields     []*CfgCdrField
+	CCAFields         []*CfgCdrField // Additional AVPs in the answer, field_id being the AVP path
 }
 
 func (self *DARequestProcessor) loadFromJsonCfg(jsnCfg *DARequestProcessorJsnCfg) error {
@@ -124,5 +194,10 @@ func (self *DARequestProcessor) loadFromJsonCfg(jsnCfg *DARequestProcessorJsnCfg
 			return err
 		}
 	}
+	if jsnCfg.Cca_fields != nil {
+		if self.CCAFields, err = CfgCdrFieldsFromCdrFieldsJsonCfg(*jsnCfg.Cca_fields); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -38,22 +38,70 @@ type GeneralJsonCfg struct {
 
 // Listen config section
 type ListenJsonCfg struct {
-	Rpc_json *string
-	Rpc_gob  *string
-	Http     *string
+	Rpc_json        *string
+	Rpc_gob         *string
+	Http            *string
+	Tls_certificate *string
+	Tls_key         *string
+	Tls_client_ca   *string
+}
+
+// Auth config section
+type AuthJsonCfg struct {
+	Enabled     *bool
+	Credentials *[]*AuthCredentialJsonCfg
+}
+
+// One credential accepted by the listeners
+type AuthCredentialJsonCfg struct {
+	Api_key        *string
+	Username       *string
+	Password       *string
+	Certificate_cn *string
+	Permissions    *[]string
+}
+
+// Rpc client config section, connections towards other engines
+type RpcClientJsonCfg struct {
+	Tls             *bool
+	Tls_ca          *string
+	Tls_certificate *string
+	Tls_key         *string
+	Api_key         *string
+	Username        *string
+	Password        *string
 }
 
 // Database config
 type DbJsonCfg struct {
-	Db_type           *string
-	Db_host           *string
-	Db_port           *int
-	Db_name           *string
-	Db_user           *string
-	Db_passwd         *string
-	Max_open_conns    *int // Used only in case of storDb
-	Max_idle_conns    *int
-	Load_history_size *int // Used in case of dataDb to limit the length of the loads history
+	Db_type               *string
+	Db_host               *string
+	Db_port               *int
+	Db_name               *string
+	Db_user               *string
+	Db_passwd             *string
+	Max_open_conns        *int // Used only in case of storDb
+	Max_idle_conns        *int
+	Load_history_size     *int    // Used in case of dataDb to limit the length of the loads history
+	Cdrs_partitioning     *string // Used only in case of storDb
+	Cdrs_retention        *string
+	Cdrs_retention_action *string
+	Cdrs_archive_dir      *string
+}
+
+// Cache limits for one kind of data
+type CacheParamJsonCfg struct {
+	Limit   *int
+	Ttl     *string
+	Partial *bool
+}
+
+// Cache sync config section
+type CacheSyncJsonCfg struct {
+	Enabled       *bool
+	Node_id       *string
+	Notifications *bool
+	Poll_interval *string
 }
 
 // Balancer config section
@@ -79,15 +127,16 @@ type SchedulerJsonCfg struct {
 
 // Cdrs config section
 type CdrsJsonCfg struct {
-	Enabled         *bool
-	Extra_fields    *[]string
-	Store_cdrs      *bool
-	Rater           *string
-	Pubsubs         *string
-	Users           *string
-	Aliases         *string
-	Cdrstats        *string
-	Cdr_replication *[]*CdrReplicationJsonCfg
+	Enabled            *bool
+	Extra_fields       *[]string
+	Store_cdrs         *bool
+	Derive_concurrency *int
+	Rater              *string
+	Pubsubs            *string
+	Users              *string
+	Aliases            *string
+	Cdrstats           *string
+	Cdr_replication    *[]*CdrReplicationJsonCfg
 }
 
 type CdrReplicationJsonCfg struct {
@@ -132,6 +181,9 @@ type CdreJsonCfg struct {
 	Mask_destination_id           *string
 	Mask_length                   *int
 	Export_dir                    *string
+	Export_page_size              *int
+	Compression                   *string
+	Manifest                      *bool
 	Header_fields                 *[]*CdrFieldJsonCfg
 	Content_fields                *[]*CdrFieldJsonCfg
 	Trailer_fields                *[]*CdrFieldJsonCfg
@@ -155,6 +207,16 @@ type CdrcJsonCfg struct {
 	Continue_on_success        *bool
 	Max_open_files             *int
 	Partial_record_cache       *string
+	Cdr_path                   *string
+	Journal_dir                *string
+	Journal_retention          *string
+	Quarantine_dir             *string
+	Write_rejects              *bool
+	Batch_size                 *int
+	Batch_concurrency          *int
+	Retry_interval             *string
+	Tmp_dir                    *string
+	Max_extracted_size         *int64
 	Header_fields              *[]*CdrFieldJsonCfg
 	Content_fields             *[]*CdrFieldJsonCfg
 	Trailer_fields             *[]*CdrFieldJsonCfg
@@ -162,13 +224,16 @@ type CdrcJsonCfg struct {
 
 // SM-Generic config section
 type SmGenericJsonCfg struct {
-	Enabled           *bool
-	Listen_bijson     *string
-	Rater             *string
-	Cdrs              *string
-	Debit_interval    *string
-	Min_call_duration *string
-	Max_call_duration *string
+	Enabled              *bool
+	Listen_bijson        *string
+	Rater                *string
+	Cdrs                 *string
+	Pubsubs              *string
+	Debit_interval       *string
+	Min_call_duration    *string
+	Max_call_duration    *string
+	Min_dur_low_balance  *string
+	Min_cost_low_balance *float64
 }
 
 // SM-FreeSWITCH config section
@@ -237,18 +302,32 @@ type OsipsConnJsonCfg struct {
 
 // DiameterAgent configuration
 type DiameterAgentJsonCfg struct {
-	Enabled            *bool   // enables the diameter agent: <true|false>
-	Listen             *string // address where to listen for diameter requests <x.y.z.y:1234>
-	Dictionaries_dir   *string // path towards additional dictionaries
-	Sm_generic         *string // Connection towards generic SM
-	Debit_interval     *string
-	Timezone           *string // timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
-	Dialect            *string
-	Origin_host        *string
-	Origin_realm       *string
-	Vendor_id          *int
-	Product_name       *string
-	Request_processors *[]*DARequestProcessorJsnCfg
+	Enabled               *bool   // enables the diameter agent: <true|false>
+	Listen                *string // address where to listen for diameter requests <x.y.z.y:1234>
+	Dictionaries_dir      *string // path towards additional dictionaries
+	Sm_generic            *string // Connection towards generic SM
+	Sm_generic_failover   *[]string
+	Debit_interval        *string
+	Watchdog_interval     *string
+	Timezone              *string // timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
+	Dialect               *string
+	Origin_host           *string
+	Origin_realm          *string
+	Vendor_id             *int
+	Product_name          *string
+	Final_unit_action     *string
+	Redirect_address_type *int
+	Redirect_address      *string
+	Validity_time         *string
+	Session_ttl           *string
+	Result_codes          *[]*DAResultCodeJsonCfg
+	Request_processors    *[]*DARequestProcessorJsnCfg
+}
+
+// Diameter Result-Code mapped to error
+type DAResultCodeJsonCfg struct {
+	Error       *string
+	Result_code *int
 }
 
 // One Diameter request processor configuration
@@ -258,6 +337,46 @@ type DARequestProcessorJsnCfg struct {
 	Request_filter      *string
 	Continue_on_success *bool
 	Content_fields      *[]*CdrFieldJsonCfg
+	Cca_fields          *[]*CdrFieldJsonCfg
+}
+
+// Scheduled CDR exports config section
+type CdreJobsJsonCfg struct {
+	Enabled *bool
+	Jobs    *[]*CdreJobJsonCfg
+}
+
+// One scheduled CDR export
+type CdreJobJsonCfg struct {
+	Id              *string
+	Export_template *string
+	Cdr_filter      *string
+	Schedule        *string
+	Export_dir      *string
+}
+
+// RadiusAgent configuration
+type RadiusAgentJsonCfg struct {
+	Enabled            *bool              // enables the radius agent: <true|false>
+	Listen_auth        *string            // address where to listen for Access-Requests <x.y.z.y:1812>
+	Listen_acct        *string            // address where to listen for Accounting-Requests <x.y.z.y:1813>
+	Client_secrets     *map[string]string // shared secrets, indexed on client IP
+	Dictionaries_dir   *string            // path towards additional dictionaries
+	Sm_generic         *string            // BiJSON connection towards generic SM
+	Disconnect_port    *int
+	Session_ttl        *string
+	Timezone           *string // timezone for timestamps where not specified <""|UTC|Local|$IANA_TZ_DB>
+	Request_processors *[]*RARequestProcessorJsnCfg
+}
+
+// One Radius request processor configuration
+type RARequestProcessorJsnCfg struct {
+	Id                  *string
+	Dry_run             *bool
+	Request_filter      *string
+	Continue_on_success *bool
+	Content_fields      *[]*CdrFieldJsonCfg
+	Reply_fields        *[]*CdrFieldJsonCfg
 }
 
 // History server config section
@@ -72,13 +72,16 @@ func (self *FsConnConfig) loadFromJsonCfg(jsnCfg *FsConnJsonCfg) error {
 }
 
 type SmGenericConfig struct {
-	Enabled         bool
-	ListenBijson    string
-	HaRater         []*HaPoolConfig
-	HaCdrs          []*HaPoolConfig
-	DebitInterval   time.Duration
-	MinCallDuration time.Duration
-	MaxCallDuration time.Duration
+	Enabled           bool
+	ListenBijson      string
+	HaRater           []*HaPoolConfig
+	HaCdrs            []*HaPoolConfig
+	PubSubs           string // address where to reach the pubsub service: <""|internal|x.y.z.y:1234>
+	DebitInterval     time.Duration
+	MinCallDuration   time.Duration
+	MaxCallDuration   time.Duration
+	MinDurLowBalance  time.Duration // warn the session when remaining usage goes under this value, 0 to disable
+	MinCostLowBalance float64       // warn the session when remaining credit goes under this value, 0 to disable
 }
 
 func (self *SmGenericConfig) loadFromJsonCfg(jsnCfg *SmGenericJsonCfg) error {
@@ -98,6 +101,9 @@ func (self *SmGenericConfig) loadFromJsonCfg(jsnCfg *SmGenericJsonCfg) error {
 	if jsnCfg.Cdrs != nil {
 		self.HaCdrs = []*HaPoolConfig{&HaPoolConfig{Server: *jsnCfg.Cdrs, Timeout: time.Duration(1) * time.Second}}
 	}
+	if jsnCfg.Pubsubs != nil {
+		self.PubSubs = *jsnCfg.Pubsubs
+	}
 	if jsnCfg.Debit_interval != nil {
 		if self.DebitInterval, err = utils.ParseDurationWithSecs(*jsnCfg.Debit_interval); err != nil {
 			return err
@@ -113,6 +119,14 @@ func (self *SmGenericConfig) loadFromJsonCfg(jsnCfg *SmGenericJsonCfg) error {
 			return err
 		}
 	}
+	if jsnCfg.Min_dur_low_balance != nil {
+		if self.MinDurLowBalance, err = utils.ParseDurationWithSecs(*jsnCfg.Min_dur_low_balance); err != nil {
+			return err
+		}
+	}
+	if jsnCfg.Min_cost_low_balance != nil {
+		self.MinCostLowBalance = *jsnCfg.Min_cost_low_balance
+	}
 	return nil
 }
 
@@ -0,0 +1,68 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package console
+
+import (
+	"github.com/cgrates/cgrates/agents"
+)
+
+func init() {
+	c := &CmdDiameterPeers{
+		name:      "diameter_peers",
+		rpcMethod: "DiameterAgentV1.Peers",
+	}
+	commands[c.Name()] = c
+	c.CommandExecuter = &CommandExecuter{c}
+}
+
+// Commander implementation
+type CmdDiameterPeers struct {
+	name      string
+	rpcMethod string
+	rpcParams *EmptyWrapper
+	*CommandExecuter
+}
+
+func (self *CmdDiameterPeers) Name() string {
+	return self.name
+}
+
+func (self *CmdDiameterPeers) RpcMethod() string {
+	return self.rpcMethod
+}
+
+func (self *CmdDiameterPeers) RpcParams(reset bool) interface{} {
+	if reset || self.rpcParams == nil {
+		self.rpcParams = &EmptyWrapper{}
+	}
+	return self.rpcParams
+}
+
+func (self *CmdDiameterPeers) PostprocessRpcParams() error {
+	return nil
+}
+
+func (self *CmdDiameterPeers) RpcResult() interface{} {
+	var peers []*agents.DiameterPeer
+	return &peers
+}
+
+func (self *CmdDiameterPeers) ClientArgs() (args []string) {
+	return
+}
@@ -0,0 +1,6 @@
+USE `cgrates`;
+
+-- Destination id matched when rating, needed to aggregate CDRs on it. Apply it also on the monthly partitions, cost_details_YYYYMM
+
+ALTER TABLE `cost_details`
+	ADD COLUMN `destination_id` varchar(64) NOT NULL DEFAULT '' after `destination` ;
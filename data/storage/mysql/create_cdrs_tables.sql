@@ -65,6 +65,7 @@ CREATE TABLE cost_details (
   account varchar(128) NOT NULL,
   subject varchar(128) NOT NULL,
   destination varchar(128) NOT NULL,
+  destination_id varchar(64) NOT NULL DEFAULT '',
   cost DECIMAL(20,4) NOT NULL,
   timespans text,
   cost_source varchar(64) NOT NULL,
@@ -105,4 +106,41 @@ CREATE TABLE `rated_cdrs` (
   PRIMARY KEY (`id`),
   UNIQUE KEY `costid` (`cgrid`,`runid`),
   KEY deleted_at_idx (deleted_at)
-);
\ No newline at end of file
+);
+--
+-- Table structure for table `cdrs_exports`
+--
+
+DROP TABLE IF EXISTS cdrs_exports;
+CREATE TABLE cdrs_exports (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  cgrid char(40) NOT NULL,
+  export_id varchar(64) NOT NULL,
+  created_at TIMESTAMP,
+  PRIMARY KEY (id),
+  UNIQUE KEY cgrid_export (cgrid, export_id),
+  KEY export_id_idx (export_id)
+);
+
+--
+-- Table structure for table `cdre_runs`
+--
+
+DROP TABLE IF EXISTS cdre_runs;
+CREATE TABLE cdre_runs (
+  id int(11) NOT NULL AUTO_INCREMENT,
+  job_id varchar(64) NOT NULL,
+  export_id varchar(64) NOT NULL,
+  order_id_start bigint NOT NULL,
+  last_order_id bigint NOT NULL,
+  exported_file_path varchar(256) NOT NULL,
+  total_records int(11) NOT NULL,
+  failed_records int(11) NOT NULL,
+  total_cost DECIMAL(20,4) NOT NULL,
+  start_time datetime NOT NULL,
+  end_time datetime NOT NULL,
+  error text NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY export_id (export_id),
+  KEY job_id_idx (job_id)
+);
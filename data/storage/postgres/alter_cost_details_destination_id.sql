@@ -0,0 +1,3 @@
+-- Destination id matched when rating, needed to aggregate CDRs on it. Apply it also on the monthly partitions, cost_details_YYYYMM
+
+ALTER TABLE cost_details ADD COLUMN destination_id VARCHAR(64) NOT NULL DEFAULT '';
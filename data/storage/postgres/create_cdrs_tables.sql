@@ -62,6 +62,7 @@ CREATE TABLE cost_details (
   account VARCHAR(128) NOT NULL,
   subject VARCHAR(128) NOT NULL,
   destination VARCHAR(128) NOT NULL,
+  destination_id VARCHAR(64) NOT NULL DEFAULT '',
   cost NUMERIC(20,4) NOT NULL,
   timespans jsonb,
   cost_source VARCHAR(64) NOT NULL,
@@ -101,3 +102,37 @@ CREATE TABLE rated_cdrs (
   UNIQUE (cgrid, runid)
 );
 CREATE INDEX deleted_at_rc_idx ON rated_cdrs (deleted_at);
+
+--
+-- Table structure for table `cdrs_exports`
+--
+DROP TABLE IF EXISTS cdrs_exports;
+CREATE TABLE cdrs_exports (
+  id SERIAL PRIMARY KEY,
+  cgrid CHAR(40) NOT NULL,
+  export_id VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP,
+  UNIQUE (cgrid, export_id)
+);
+CREATE INDEX export_id_ce_idx ON cdrs_exports (export_id);
+
+--
+-- Table structure for table `cdre_runs`
+--
+DROP TABLE IF EXISTS cdre_runs;
+CREATE TABLE cdre_runs (
+  id SERIAL PRIMARY KEY,
+  job_id VARCHAR(64) NOT NULL,
+  export_id VARCHAR(64) NOT NULL,
+  order_id_start BIGINT NOT NULL,
+  last_order_id BIGINT NOT NULL,
+  exported_file_path VARCHAR(256) NOT NULL,
+  total_records INTEGER NOT NULL,
+  failed_records INTEGER NOT NULL,
+  total_cost NUMERIC(20,4) NOT NULL,
+  start_time TIMESTAMP NOT NULL,
+  end_time TIMESTAMP NOT NULL,
+  error TEXT NOT NULL,
+  UNIQUE (export_id)
+);
+CREATE INDEX job_id_cr_idx ON cdre_runs (job_id);
@@ -0,0 +1,138 @@
+
+--
+-- Table structure for table `cdrs_primary`
+--
+
+DROP TABLE IF EXISTS cdrs_primary;
+CREATE TABLE cdrs_primary (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  cgrid CHAR(40) NOT NULL,
+  tor  VARCHAR(16) NOT NULL,
+  accid VARCHAR(64) NOT NULL,
+  cdrhost VARCHAR(64) NOT NULL,
+  cdrsource VARCHAR(64) NOT NULL,
+  reqtype VARCHAR(24) NOT NULL,
+  direction VARCHAR(8) NOT NULL,
+  tenant VARCHAR(64) NOT NULL,
+  category VARCHAR(32) NOT NULL,
+  account VARCHAR(128) NOT NULL,
+  subject VARCHAR(128) NOT NULL,
+  destination VARCHAR(128) NOT NULL,
+  setup_time TIMESTAMP NOT NULL,
+  pdd NUMERIC(12,9) NOT NULL,
+  answer_time TIMESTAMP NOT NULL,
+  usage NUMERIC(30,9) NOT NULL,
+  supplier VARCHAR(128) NOT NULL,
+  disconnect_cause VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP,
+  deleted_at TIMESTAMP,
+  UNIQUE (cgrid)
+);
+CREATE INDEX answer_time_idx ON cdrs_primary (answer_time);
+CREATE INDEX deleted_at_cp_idx ON cdrs_primary (deleted_at);
+
+--
+-- Table structure for table `cdrs_extra`
+--
+
+DROP TABLE IF EXISTS cdrs_extra;
+CREATE TABLE cdrs_extra (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  cgrid CHAR(40) NOT NULL,
+  extra_fields TEXT NOT NULL,
+  created_at TIMESTAMP,
+  deleted_at TIMESTAMP,
+  UNIQUE (cgrid)
+);
+CREATE INDEX deleted_at_ce_idx ON cdrs_extra (deleted_at);
+
+--
+-- Table structure for table `cost_details`
+--
+
+DROP TABLE IF EXISTS cost_details;
+CREATE TABLE cost_details (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  cgrid CHAR(40) NOT NULL,
+  runid  VARCHAR(64) NOT NULL,
+  tor  VARCHAR(16) NOT NULL,
+  direction VARCHAR(8) NOT NULL,
+  tenant VARCHAR(128) NOT NULL,
+  category VARCHAR(32) NOT NULL,
+  account VARCHAR(128) NOT NULL,
+  subject VARCHAR(128) NOT NULL,
+  destination VARCHAR(128) NOT NULL,
+  destination_id VARCHAR(64) NOT NULL DEFAULT '',
+  cost NUMERIC(20,4) NOT NULL,
+  timespans TEXT,
+  cost_source VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP,
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP,
+  UNIQUE (cgrid, runid)
+);
+CREATE INDEX deleted_at_cd_idx ON cost_details (deleted_at);
+
+--
+-- Table structure for table `rated_cdrs`
+--
+DROP TABLE IF EXISTS rated_cdrs;
+CREATE TABLE rated_cdrs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  cgrid CHAR(40) NOT NULL,
+  runid  VARCHAR(64) NOT NULL,
+  reqtype VARCHAR(24) NOT NULL,
+  direction VARCHAR(8) NOT NULL,
+  tenant VARCHAR(64) NOT NULL,
+  category VARCHAR(32) NOT NULL,
+  account VARCHAR(128) NOT NULL,
+  subject VARCHAR(128) NOT NULL,
+  destination VARCHAR(128) NOT NULL,
+  setup_time TIMESTAMP NOT NULL,
+  pdd NUMERIC(12,9) NOT NULL,
+  answer_time TIMESTAMP NOT NULL,
+  usage NUMERIC(30,9) NOT NULL,
+  supplier VARCHAR(128) NOT NULL,
+  disconnect_cause VARCHAR(64) NOT NULL,
+  cost NUMERIC(20,4) DEFAULT NULL,
+  extra_info text,
+  created_at TIMESTAMP,
+  updated_at TIMESTAMP,
+  deleted_at TIMESTAMP,
+  UNIQUE (cgrid, runid)
+);
+CREATE INDEX deleted_at_rc_idx ON rated_cdrs (deleted_at);
+
+--
+-- Table structure for table `cdrs_exports`
+--
+DROP TABLE IF EXISTS cdrs_exports;
+CREATE TABLE cdrs_exports (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  cgrid CHAR(40) NOT NULL,
+  export_id VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP,
+  UNIQUE (cgrid, export_id)
+);
+CREATE INDEX export_id_ce_idx ON cdrs_exports (export_id);
+
+--
+-- Table structure for table `cdre_runs`
+--
+DROP TABLE IF EXISTS cdre_runs;
+CREATE TABLE cdre_runs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  job_id VARCHAR(64) NOT NULL,
+  export_id VARCHAR(64) NOT NULL,
+  order_id_start BIGINT NOT NULL,
+  last_order_id BIGINT NOT NULL,
+  exported_file_path VARCHAR(256) NOT NULL,
+  total_records INTEGER NOT NULL,
+  failed_records INTEGER NOT NULL,
+  total_cost NUMERIC(20,4) NOT NULL,
+  start_time TIMESTAMP NOT NULL,
+  end_time TIMESTAMP NOT NULL,
+  error TEXT NOT NULL,
+  UNIQUE (export_id)
+);
+CREATE INDEX job_id_cr_idx ON cdre_runs (job_id);
@@ -0,0 +1,18 @@
+#! /usr/bin/env sh
+
+db=$1
+if [ -z "$1" ]; then
+	db="/var/lib/cgrates/stordb.sqlite"
+fi
+
+sqlite3 $db < create_cdrs_tables.sql
+cdrt=$?
+sqlite3 $db < create_tariffplan_tables.sql
+tpt=$?
+
+if [ $cdrt = 0 ] && [ $tpt = 0 ]; then
+	echo ""
+	echo "\t+++ CGR-DB successfully set-up! +++"
+	echo ""
+	exit 0
+fi
@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/cgrates/cgrates/cache2go"
 	"github.com/cgrates/cgrates/utils"
 
 	"strings"
@@ -212,8 +211,7 @@ func (ub *Account) getBalancesForPrefix(prefix, category, direction, tor string,
 		b.account = ub
 		if len(b.DestinationIds) > 0 && b.DestinationIds[utils.ANY] == false {
 			for _, p := range utils.SplitPrefix(prefix, MIN_PREFIX_MATCH) {
-				if x, err := cache2go.Get(utils.DESTINATION_PREFIX + p); err == nil {
-					destIds := x.(map[interface{}]struct{})
+				if destIds, err := getCachedDestIds(p); err == nil {
 					for dId, _ := range destIds {
 						if b.DestinationIds[dId.(string)] == true {
 							b.precision = len(p)
@@ -519,7 +519,7 @@ func setddestinations(ub *Account, sq *StatsQueueTriggered, a *Action, acs Actio
 		// update destid in storage
 		ratingStorage.SetDestination(&Destination{Id: ddcDestId, Prefixes: prefixes})
 		// remove existing from cache
-		CleanStalePrefixes([]string{ddcDestId})
+		CleanStalePrefixes([]string{ddcDestId}, nil)
 		// update new values from redis
 		ratingStorage.CacheRatingPrefixValues(map[string][]string{utils.DESTINATION_PREFIX: []string{utils.DESTINATION_PREFIX + ddcDestId}})
 	} else {
@@ -115,24 +115,24 @@ func (al *Alias) SetId(id string) error {
 	return nil
 }
 
-func (al *Alias) SetReverseCache() {
+func (al *Alias) SetReverseCache(tx *cache2go.Transaction) {
 	for _, value := range al.Values {
 		for target, pairs := range value.Pairs {
 			for _, alias := range pairs {
 				rKey := strings.Join([]string{utils.REVERSE_ALIASES_PREFIX, alias, target, al.Context}, "")
-				cache2go.Push(rKey, utils.ConcatenatedKey(al.GetId(), value.DestinationId))
+				tx.Push(rKey, utils.ConcatenatedKey(al.GetId(), value.DestinationId))
 			}
 		}
 	}
 }
 
-func (al *Alias) RemoveReverseCache() {
+func (al *Alias) RemoveReverseCache(tx *cache2go.Transaction) {
 	for _, value := range al.Values {
 		tmpKey := utils.ConcatenatedKey(al.GetId(), value.DestinationId)
 		for target, pairs := range value.Pairs {
 			for _, alias := range pairs {
 				rKey := utils.REVERSE_ALIASES_PREFIX + alias + target + al.Context
-				cache2go.Pop(rKey, tmpKey)
+				tx.Pop(rKey, tmpKey)
 			}
 		}
 	}
@@ -344,8 +344,7 @@ func (am *AliasHandler) GetMatchingAlias(attr AttrMatchingAlias, result *string)
 	}
 	// check destination ids
 	for _, p := range utils.SplitPrefix(attr.Destination, MIN_PREFIX_MATCH) {
-		if x, err := cache2go.Get(utils.DESTINATION_PREFIX + p); err == nil {
-			destIds := x.(map[interface{}]struct{})
+		if destIds, err := getCachedDestIds(p); err == nil {
 			for _, value := range values {
 				for idId := range destIds {
 					dId := idId.(string)
@@ -369,7 +368,7 @@ func (am *AliasHandler) GetMatchingAlias(attr AttrMatchingAlias, result *string)
 }
 
 type ProxyAliasService struct {
-	Client *rpcclient.RpcClient
+	Client rpcclient.RpcClientConnection
 }
 
 func NewProxyAliasService(addr string, attempts, reconnects int) (*ProxyAliasService, error) {
@@ -441,8 +440,7 @@ func LoadAlias(attr *AttrMatchingAlias, in interface{}, extraFields string) erro
 	if rightPairs == nil {
 		// check destination ids
 		for _, p := range utils.SplitPrefix(attr.Destination, MIN_PREFIX_MATCH) {
-			if x, err := cache2go.Get(utils.DESTINATION_PREFIX + p); err == nil {
-				destIds := x.(map[interface{}]struct{})
+			if destIds, err := getCachedDestIds(p); err == nil {
 				for _, value := range values {
 					for idId := range destIds {
 						dId := idId.(string)
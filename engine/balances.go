@@ -26,7 +26,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/cgrates/cgrates/cache2go"
 	"github.com/cgrates/cgrates/utils"
 )
 
@@ -105,8 +104,7 @@ func (b *Balance) MatchCCFilter(cc *CallCost) bool {
 	foundMatchingDestId := false
 	if len(b.DestinationIds) > 0 && cc.Destination != "" {
 		for _, p := range utils.SplitPrefix(cc.Destination, MIN_PREFIX_MATCH) {
-			if x, err := cache2go.Get(utils.DESTINATION_PREFIX + p); err == nil {
-				destIds := x.(map[interface{}]struct{})
+			if destIds, err := getCachedDestIds(p); err == nil {
 				for filterDestId := range b.DestinationIds {
 					if _, ok := destIds[filterDestId]; ok {
 						foundMatchingDestId = true
@@ -246,8 +244,7 @@ func (b *Balance) Clone() *Balance {
 func (b *Balance) getMatchingPrefixAndDestId(dest string) (prefix, destId string) {
 	if len(b.DestinationIds) != 0 && b.DestinationIds[utils.ANY] == false {
 		for _, p := range utils.SplitPrefix(dest, MIN_PREFIX_MATCH) {
-			if x, err := cache2go.Get(utils.DESTINATION_PREFIX + p); err == nil {
-				destIds := x.(map[interface{}]struct{})
+			if destIds, err := getCachedDestIds(p); err == nil {
 				for dId, _ := range destIds {
 					if b.DestinationIds[dId.(string)] == true {
 						return p, dId.(string)
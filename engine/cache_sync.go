@@ -0,0 +1,296 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	CACHE_CHANGES_SIZE        = 1000            // Changes kept in the log, engines further behind reload all of their cache
+	CACHE_CHANGES_GAP_TIMEOUT = time.Minute     // Wait this long for a missing change before reloading all of the cache
+	CACHE_SUBSCRIBE_RETRY     = 5 * time.Second // Wait between subscribe attempts to the change notifications
+	CACHE_NODE_TTL            = time.Hour       // Versions of the engines not syncing anymore expire after this, running ones refresh theirs every half of it
+)
+
+var ErrCacheChangesTrimmed = errors.New("CACHE_CHANGES_TRIMMED")
+
+// Cache reload done by one engine, applied by the others sharing the same tariffplan_db
+type CacheChange struct {
+	Version    int64  // Position in the changes log, set by the storage
+	Origin     string // Node id of the engine which published it
+	Time       time.Time
+	Rating     map[string][]string // Keys reloaded out of the ratingDb indexed on prefix, nil for all the keys of the prefix
+	Accounting map[string][]string // Keys reloaded out of the accountingDb indexed on prefix
+}
+
+// Cache version reached by one engine
+type CacheNodeVersion struct {
+	Version  int64
+	LastSync time.Time
+}
+
+// Keeps the cache of this engine in sync with the changes published by the other engines
+type CacheSyncer struct {
+	nodeId        string
+	changesDb     CacheChangesStorage
+	ratingDb      RatingStorage // Applies the changes without publishing them again
+	accountingDb  AccountingStorage
+	notifications bool
+	pollInterval  time.Duration
+	mux           sync.Mutex // One sync at a time
+	version       int64
+	gapSince      time.Time // Missing change detected at, zero if none
+	stopChan      chan struct{}
+}
+
+// The changes log is kept in ratingDb, versions published before the syncer is created are considered part of the initial cache
+func NewCacheSyncer(nodeId string, ratingDb RatingStorage, accountingDb AccountingStorage, notifications bool, pollInterval time.Duration) (*CacheSyncer, error) {
+	changesDb, canChanges := ratingDb.(CacheChangesStorage)
+	if !canChanges {
+		return nil, errors.New("tariffplan_db without support for cache changes")
+	}
+	if len(nodeId) == 0 {
+		var err error
+		if nodeId, err = os.Hostname(); err != nil {
+			return nil, err
+		}
+	}
+	version, err := changesDb.GetCacheVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &CacheSyncer{nodeId: nodeId, changesDb: changesDb, ratingDb: ratingDb, accountingDb: accountingDb,
+		notifications: notifications, pollInterval: pollInterval, version: version, stopChan: make(chan struct{})}, nil
+}
+
+// RatingStorage publishing the cache reloads done through it
+func (self *CacheSyncer) RatingStorage() RatingStorage {
+	return &cacheSyncRatingStorage{RatingStorage: self.ratingDb, CacheChangesStorage: self.changesDb, syncer: self}
+}
+
+// AccountingStorage publishing the cache reloads done through it
+func (self *CacheSyncer) AccountingStorage() AccountingStorage {
+	return &cacheSyncAccountingStorage{AccountingStorage: self.accountingDb, syncer: self}
+}
+
+func (self *CacheSyncer) Version() int64 {
+	self.mux.Lock()
+	defer self.mux.Unlock()
+	return self.version
+}
+
+// Adds the reload to the changes log, failures are only logged since the local cache is already reloaded
+func (self *CacheSyncer) publish(rating, accounting map[string][]string) {
+	cc := &CacheChange{Origin: self.nodeId, Time: time.Now(), Rating: publishedKeys(rating), Accounting: publishedKeys(accounting)}
+	if len(cc.Rating) == 0 && len(cc.Accounting) == 0 {
+		return
+	}
+	if _, err := self.changesDb.AddCacheChange(cc); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<CacheSyncer> Cannot publish cache change, error: %s", err.Error()))
+	}
+}
+
+// Drops the prefixes with nothing reloaded, nil keys stay since they stand for all of the prefix
+func publishedKeys(prefixKeys map[string][]string) map[string][]string {
+	published := make(map[string][]string)
+	for prefix, keys := range prefixKeys {
+		if keys == nil || len(keys) != 0 {
+			published[prefix] = keys
+		}
+	}
+	return published
+}
+
+// Applies the changes published by other engines since the last sync
+func (self *CacheSyncer) Sync() error {
+	self.mux.Lock()
+	defer self.mux.Unlock()
+	lastVersion, err := self.changesDb.GetCacheVersion()
+	if err != nil {
+		return err
+	}
+	if lastVersion > self.version {
+		changes, err := self.changesDb.GetCacheChanges(self.version)
+		if err == ErrCacheChangesTrimmed {
+			return self.reloadAll(lastVersion)
+		} else if err != nil {
+			return err
+		}
+		syncedVersion := self.version
+		for _, cc := range changes {
+			if cc.Version != self.version+1 { // Missing change, let it be written before going further
+				break
+			}
+			if cc.Origin != self.nodeId {
+				if err := self.apply(cc); err != nil {
+					return err
+				}
+			}
+			self.version = cc.Version
+		}
+		if self.version == lastVersion || self.version != syncedVersion {
+			self.gapSince = time.Time{}
+		}
+		if self.version < lastVersion {
+			if self.gapSince.IsZero() {
+				self.gapSince = time.Now()
+			} else if time.Since(self.gapSince) > CACHE_CHANGES_GAP_TIMEOUT {
+				return self.reloadAll(lastVersion)
+			}
+		}
+	}
+	return self.changesDb.SetCacheNodeVersion(self.nodeId, &CacheNodeVersion{Version: self.version, LastSync: time.Now()})
+}
+
+func (self *CacheSyncer) apply(cc *CacheChange) error {
+	if len(cc.Rating) != 0 {
+		if err := self.ratingDb.CacheRatingPrefixValues(cc.Rating); err != nil {
+			return err
+		}
+	}
+	if len(cc.Accounting) != 0 {
+		if err := self.accountingDb.CacheAccountingPrefixValues(cc.Accounting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Used when the changes since our version are not available anymore
+func (self *CacheSyncer) reloadAll(lastVersion int64) error {
+	utils.Logger.Warning(fmt.Sprintf("<CacheSyncer> Cannot sync from version %d to %d, reloading all cache", self.version, lastVersion))
+	if err := self.ratingDb.CacheRatingAll(); err != nil {
+		return err
+	}
+	if err := self.accountingDb.CacheAccountingAll(); err != nil {
+		return err
+	}
+	self.version = lastVersion
+	self.gapSince = time.Time{}
+	return self.changesDb.SetCacheNodeVersion(self.nodeId, &CacheNodeVersion{Version: self.version, LastSync: time.Now()})
+}
+
+// Syncs on change notifications and on every poll interval, blocks until Shutdown
+func (self *CacheSyncer) ListenAndServe() {
+	versionChan := make(chan int64)
+	if self.notifications {
+		go self.subscribe(versionChan)
+	}
+	var pollChan <-chan time.Time
+	if self.pollInterval != 0 {
+		ticker := time.NewTicker(self.pollInterval)
+		defer ticker.Stop()
+		pollChan = ticker.C
+	}
+	nodeTicker := time.NewTicker(CACHE_NODE_TTL / 2) // Keeps our version from expiring while no changes are published
+	defer nodeTicker.Stop()
+	for {
+		select {
+		case <-self.stopChan:
+			return
+		case <-versionChan:
+		case <-pollChan:
+		case <-nodeTicker.C:
+		}
+		if err := self.Sync(); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<CacheSyncer> Sync error: %s", err.Error()))
+		}
+	}
+}
+
+func (self *CacheSyncer) subscribe(versionChan chan<- int64) {
+	for {
+		err := self.changesDb.SubscribeCacheChanges(versionChan, self.stopChan)
+		if err == nil { // Stopped
+			return
+		} else if err == utils.ErrNotImplemented {
+			utils.Logger.Info("<CacheSyncer> No change notifications out of tariffplan_db, relying on polling")
+			return
+		}
+		utils.Logger.Err(fmt.Sprintf("<CacheSyncer> Change notifications error: %s", err.Error()))
+		select {
+		case <-self.stopChan:
+			return
+		case <-time.After(CACHE_SUBSCRIBE_RETRY):
+		}
+	}
+}
+
+func (self *CacheSyncer) Shutdown() {
+	close(self.stopChan)
+}
+
+// Full caching is not published, done by each engine on start
+type cacheSyncRatingStorage struct {
+	RatingStorage
+	CacheChangesStorage
+	syncer *CacheSyncer
+}
+
+func (self *cacheSyncRatingStorage) CacheRatingPrefixes(prefixes ...string) error {
+	if err := self.RatingStorage.CacheRatingPrefixes(prefixes...); err != nil {
+		return err
+	}
+	prefixKeys := make(map[string][]string, len(prefixes))
+	for _, prefix := range prefixes {
+		prefixKeys[prefix] = nil
+	}
+	self.syncer.publish(prefixKeys, nil)
+	return nil
+}
+
+func (self *cacheSyncRatingStorage) CacheRatingPrefixValues(prefixes map[string][]string) error {
+	if err := self.RatingStorage.CacheRatingPrefixValues(prefixes); err != nil {
+		return err
+	}
+	self.syncer.publish(prefixes, nil)
+	return nil
+}
+
+type cacheSyncAccountingStorage struct {
+	AccountingStorage
+	syncer *CacheSyncer
+}
+
+func (self *cacheSyncAccountingStorage) CacheAccountingPrefixes(prefixes ...string) error {
+	if err := self.AccountingStorage.CacheAccountingPrefixes(prefixes...); err != nil {
+		return err
+	}
+	prefixKeys := make(map[string][]string, len(prefixes))
+	for _, prefix := range prefixes {
+		prefixKeys[prefix] = nil
+	}
+	self.syncer.publish(nil, prefixKeys)
+	return nil
+}
+
+func (self *cacheSyncAccountingStorage) CacheAccountingPrefixValues(prefixes map[string][]string) error {
+	if err := self.AccountingStorage.CacheAccountingPrefixValues(prefixes); err != nil {
+		return err
+	}
+	self.syncer.publish(nil, prefixes)
+	return nil
+}
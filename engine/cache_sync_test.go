@@ -0,0 +1,136 @@
+/*
+Rating system designed to be used in VoIP Carriers World
+Copyright (C) 2012-2015 ITsysCOM
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Records the cache reloads instead of doing them, changes log kept in the shared MapStorage
+type cacheReloadsRecorder struct {
+	*MapStorage
+	rating     []map[string][]string
+	accounting []map[string][]string
+}
+
+func (self *cacheReloadsRecorder) CacheRatingPrefixValues(prefixes map[string][]string) error {
+	self.rating = append(self.rating, prefixes)
+	return nil
+}
+
+func (self *cacheReloadsRecorder) CacheAccountingPrefixes(prefixes ...string) error {
+	prefixKeys := make(map[string][]string)
+	for _, prefix := range prefixes {
+		prefixKeys[prefix] = nil
+	}
+	self.accounting = append(self.accounting, prefixKeys)
+	return nil
+}
+
+func (self *cacheReloadsRecorder) CacheAccountingPrefixValues(prefixes map[string][]string) error {
+	self.accounting = append(self.accounting, prefixes)
+	return nil
+}
+
+func TestCacheSyncApplyChanges(t *testing.T) {
+	changesDb, _ := NewMapStorage()
+	recA := &cacheReloadsRecorder{MapStorage: changesDb}
+	recB := &cacheReloadsRecorder{MapStorage: changesDb}
+	syncA, err := NewCacheSyncer("nodeA", recA, recA, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncB, err := NewCacheSyncer("nodeB", recB, recB, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncA.RatingStorage().CacheRatingPrefixValues(map[string][]string{
+		utils.DESTINATION_PREFIX: []string{"GERMANY"},
+		utils.RATING_PLAN_PREFIX: []string{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncA.AccountingStorage().CacheAccountingPrefixes(utils.ALIASES_PREFIX); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := changesDb.GetCacheVersion(); err != nil {
+		t.Error(err)
+	} else if version != 2 {
+		t.Error("Unexpected version: ", version)
+	}
+	if err := syncB.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	eRating := []map[string][]string{map[string][]string{utils.DESTINATION_PREFIX: []string{"GERMANY"}}}
+	if !reflect.DeepEqual(eRating, recB.rating) {
+		t.Errorf("Expecting: %+v, received: %+v", eRating, recB.rating)
+	}
+	if len(recB.accounting) != 1 || len(recB.accounting[0]) != 1 || recB.accounting[0][utils.ALIASES_PREFIX] != nil {
+		t.Errorf("Unexpected accounting reloads: %+v", recB.accounting)
+	}
+	if err := syncA.Sync(); err != nil { // Own changes, nothing to reload again
+		t.Fatal(err)
+	}
+	if len(recA.rating) != 1 || len(recA.accounting) != 1 {
+		t.Errorf("Unexpected reloads on origin, rating: %+v, accounting: %+v", recA.rating, recA.accounting)
+	}
+	if nodeVersions, err := changesDb.GetCacheNodeVersions(); err != nil {
+		t.Error(err)
+	} else if len(nodeVersions) != 2 || nodeVersions["nodeA"].Version != 2 || nodeVersions["nodeB"].Version != 2 {
+		t.Errorf("Unexpected node versions: %+v", nodeVersions)
+	}
+}
+
+func TestCacheSyncNodeVersionExpired(t *testing.T) {
+	changesDb, _ := NewMapStorage()
+	if err := changesDb.SetCacheNodeVersion("nodeA", &CacheNodeVersion{Version: 1, LastSync: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := changesDb.SetCacheNodeVersion("nodeB", &CacheNodeVersion{Version: 1, LastSync: time.Now().Add(-CACHE_NODE_TTL - time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if nodeVersions, err := changesDb.GetCacheNodeVersions(); err != nil {
+		t.Error(err)
+	} else if _, hasIt := nodeVersions["nodeB"]; len(nodeVersions) != 1 || hasIt {
+		t.Errorf("Unexpected node versions: %+v", nodeVersions)
+	}
+	if _, hasIt := changesDb.get(utils.CACHE_NODE_PREFIX + "nodeB"); hasIt {
+		t.Error("Expired node version not removed")
+	}
+}
+
+func TestCacheSyncChangesTrimmed(t *testing.T) {
+	changesDb, _ := NewMapStorage()
+	for i := 0; i < CACHE_CHANGES_SIZE+1; i++ {
+		if _, err := changesDb.AddCacheChange(&CacheChange{Origin: "nodeA", Rating: map[string][]string{utils.DESTINATION_PREFIX: nil}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := changesDb.GetCacheChanges(0); err != ErrCacheChangesTrimmed {
+		t.Error("Expecting trimmed error, received: ", err)
+	}
+	if changes, err := changesDb.GetCacheChanges(1); err != nil {
+		t.Error(err)
+	} else if len(changes) != CACHE_CHANGES_SIZE || changes[0].Version != 2 {
+		t.Errorf("Unexpected changes: %d", len(changes))
+	}
+}
@@ -33,6 +33,8 @@ type CallCost struct {
 	deductConnectFee                                                bool
 	negativeConnectFee                                              bool // the connect fee went negative on default balance
 	maxCostDisconect                                                bool
+	RemainingUsage                                                  time.Duration `json:",omitempty"` // Usage left on the account unit balances after MaxDebit
+	RemainingCredit                                                 float64       `json:",omitempty"` // Monetary credit left on the account after MaxDebit
 }
 
 // Merges the received timespan if they are similar (same activation period, same interval, same minute info.
@@ -62,6 +64,14 @@ func (cc *CallCost) GetDuration() (td time.Duration) {
 	return
 }
 
+// Destination id matched when rating, taken out of the first timespan
+func (cc *CallCost) GetMatchedDestId() string {
+	if len(cc.Timespans) == 0 {
+		return ""
+	}
+	return cc.Timespans[0].MatchedDestId
+}
+
 func (cc *CallCost) GetConnectFee() float64 {
 	if len(cc.Timespans) == 0 ||
 		cc.Timespans[0].RateInterval == nil ||
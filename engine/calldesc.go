@@ -178,7 +178,7 @@ func (cd *CallDescriptor) getAccount() (ub *Account, err error) {
 		cd.account, err = accountingStorage.GetAccount(cd.GetAccountKey())
 	}
 	if cd.account != nil && cd.account.Disabled {
-		return nil, fmt.Errorf("User %s is disabled", cd.account.Id)
+		return nil, utils.ErrAccountDisabled
 	}
 	return cd.account, err
 }
@@ -617,6 +617,9 @@ func (origCD *CallDescriptor) getMaxSessionDuration(origAcc *Account) (time.Dura
 func (cd *CallDescriptor) GetMaxSessionDuration() (duration time.Duration, err error) {
 	cd.account = nil // make sure it's not cached
 	if account, err := cd.getAccount(); err != nil || account == nil {
+		if err == utils.ErrAccountDisabled {
+			return 0, err
+		}
 		utils.Logger.Err(fmt.Sprintf("Account: %s, not found", cd.GetAccountKey()))
 		return 0, utils.ErrAccountNotFound
 	} else {
@@ -674,12 +677,18 @@ func (cd *CallDescriptor) Debit() (cc *CallCost, err error) {
 	cd.account = nil // make sure it's not cached
 	// lock all group members
 	if account, err := cd.getAccount(); err != nil || account == nil {
+		if err == utils.ErrAccountDisabled {
+			return nil, err
+		}
 		utils.Logger.Err(fmt.Sprintf("Account: %s, not found", cd.GetAccountKey()))
 		return nil, utils.ErrAccountNotFound
 	} else {
 		if memberIds, sgerr := account.GetUniqueSharedGroupMembers(cd); sgerr == nil {
 			_, err = Guardian.Guard(func() (interface{}, error) {
 				cc, err = cd.debit(account, false, true)
+				if err == nil && cc != nil {
+					cc.RemainingUsage, cc.RemainingCredit, _ = account.getCreditForPrefix(cd)
+				}
 				return 0, err
 			}, 0, memberIds...)
 		} else {
@@ -696,6 +705,9 @@ func (cd *CallDescriptor) Debit() (cc *CallCost, err error) {
 func (cd *CallDescriptor) MaxDebit() (cc *CallCost, err error) {
 	cd.account = nil // make sure it's not cached
 	if account, err := cd.getAccount(); err != nil || account == nil {
+		if err == utils.ErrAccountDisabled {
+			return nil, err
+		}
 		utils.Logger.Err(fmt.Sprintf("Account: %s, not found", cd.GetAccountKey()))
 		return nil, utils.ErrAccountNotFound
 	} else {
@@ -729,6 +741,9 @@ func (cd *CallDescriptor) MaxDebit() (cc *CallCost, err error) {
 				}
 				cc, err = cd.debit(account, false, true)
 				//log.Print(balanceMap[0].Value, balanceMap[1].Value)
+				if err == nil && cc != nil { // Report what is left so sessions can warn on low balance without querying again
+					cc.RemainingUsage, cc.RemainingCredit, _ = account.getCreditForPrefix(cd)
+				}
 				return 0, err
 			}, 0, memberIds...)
 			if err != nil {
@@ -893,7 +908,15 @@ func (cd *CallDescriptor) GetLCR(stats StatsInterface, p *utils.Paginator) (*LCR
 		}
 		ratingProfileSearchKey := utils.ConcatenatedKey(lcr.Direction, lcr.Tenant, lcrCost.Entry.RPCategory)
 		//log.Print("KEY: ", ratingProfileSearchKey)
-		suppliers := cache2go.GetEntriesKeys(utils.RATING_PROFILE_PREFIX + ratingProfileSearchKey)
+		var suppliers []string
+		if cache2go.IsPartial(utils.RATING_PROFILE_PREFIX) { // Not all rating profiles are in cache
+			var err error
+			if suppliers, err = ratingStorage.GetKeysForPrefix(utils.RATING_PROFILE_PREFIX + ratingProfileSearchKey); err != nil {
+				return nil, err
+			}
+		} else {
+			suppliers = cache2go.GetEntriesKeys(utils.RATING_PROFILE_PREFIX + ratingProfileSearchKey)
+		}
 		for _, supplier := range suppliers {
 			//log.Print("Supplier: ", supplier)
 			split := strings.Split(supplier, ":")
@@ -991,6 +991,10 @@ func TestDebitAndMaxDebit(t *testing.T) {
 	if err1 != nil || err2 != nil {
 		t.Error("Error debiting and/or maxdebiting: ", err1, err2)
 	}
+	if cc1.RemainingUsage-cc2.RemainingUsage != time.Duration(10)*time.Second || cc1.RemainingCredit != cc2.RemainingCredit {
+		t.Errorf("Unexpected remaining usage: %v, %v", cc1.RemainingUsage, cc2.RemainingUsage)
+	}
+	cc2.RemainingUsage = cc1.RemainingUsage // Both debited out of the same account
 	if !reflect.DeepEqual(cc1, cc2) {
 		t.Logf("CC1: %+v", cc1)
 		for _, ts := range cc1.Timespans {
@@ -0,0 +1,49 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"time"
+)
+
+// Bookkeeping of one CDR export performed by a scheduled export job.
+// The CDRs exported are the ones marked with ExportId, the next run of the job exports the CDRs after the LastOrderId of its completed runs.
+type CdreRun struct {
+	JobId            string
+	ExportId         string
+	OrderIdStart     int64 // Lowest OrderId exported by the run
+	LastOrderId      int64 // Highest OrderId exported by the run
+	ExportedFilePath string
+	TotalRecords     int // Updated after each exported page while the run is in progress
+	FailedRecords    int
+	TotalCost        float64
+	StartTime        time.Time
+	EndTime          time.Time // Zero while the run is in progress
+	Error            string    // Populated if the run failed, its CDRs will be exported by the next run of the job
+}
+
+// Highest OrderId exported by the runs completed without error, the next run of the job starts after it
+func CdreJobWatermark(runs []*CdreRun) (watermark int64) {
+	for _, run := range runs {
+		if len(run.Error) == 0 && !run.EndTime.IsZero() && run.LastOrderId > watermark {
+			watermark = run.LastOrderId
+		}
+	}
+	return
+}
@@ -31,8 +31,19 @@ import (
 	mgov2 "gopkg.in/mgo.v2"
 )
 
+const (
+	CDRS_RATE_PAGE_SIZE = 1000          // CDRs queried at once when re-rating
+	CDRS_LOCK_PREFIX    = "cdrs_cgrid_" // Guardian keys of the CDRs processed in batches
+)
+
 var cdrServer *CdrServer // Share the server so we can use it in http handlers
 
+var (
+	cdrsProcessed    = utils.Metrics.Counter("cgr_cdrs_processed_total", "CDRs accepted by the CDR Server.")
+	cdrsFailed       = utils.Metrics.Counter("cgr_cdrs_failed_total", "CDRs the CDR Server could not accept.")
+	cdrsRatingFailed = utils.Metrics.Counter("cgr_cdrs_rating_failed_total", "CDR runs the CDR Server could not rate.")
+)
+
 type CallCostLog struct {
 	CgrId          string
 	Source         string
@@ -67,18 +78,25 @@ func fsCdrHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func NewCdrServer(cgrCfg *config.CGRConfig, cdrDb CdrStorage, rater Connector, pubsub PublisherSubscriber, users UserService, aliases AliasService, stats StatsInterface) (*CdrServer, error) {
-	return &CdrServer{cgrCfg: cgrCfg, cdrDb: cdrDb, rater: rater, pubsub: pubsub, users: users, aliases: aliases, stats: stats, guard: &GuardianLock{queue: make(map[string]chan bool)}}, nil
+	return &CdrServer{cgrCfg: cgrCfg, cdrDb: cdrDb, rater: rater, pubsub: pubsub, users: users, aliases: aliases, stats: stats, guard: &GuardianLock{queue: make(map[string]chan bool)},
+		deriveRoutines: make(chan struct{}, cgrCfg.CDRSDeriveConcurrency)}, nil
+}
+
+// Storing the raw CDR failed, returned by processCdr so the batches are interrupted
+type cdrStoreError struct {
+	error
 }
 
 type CdrServer struct {
-	cgrCfg  *config.CGRConfig
-	cdrDb   CdrStorage
-	rater   Connector
-	pubsub  PublisherSubscriber
-	users   UserService
-	aliases AliasService
-	stats   StatsInterface
-	guard   *GuardianLock
+	cgrCfg         *config.CGRConfig
+	cdrDb          CdrStorage
+	rater          Connector
+	pubsub         PublisherSubscriber
+	users          UserService
+	aliases        AliasService
+	stats          StatsInterface
+	guard          *GuardianLock
+	deriveRoutines chan struct{} // Limits the CDRs derived in background, capacity 0 for no limit
 }
 
 func (self *CdrServer) Timezone() string {
@@ -96,6 +114,52 @@ func (self *CdrServer) ProcessCdr(cdr *StoredCdr) error {
 	return self.processCdr(cdr)
 }
 
+// RPC method, used to internally process CDRs in bulk, errors are returned in the order of the CDRs, nil for success.
+// Failing to store one CDR interrupts the batch, the error is returned separately and the CDRs starting with it are left without result.
+// CDRs already stored with the same CgrId are not processed again so the batches can be retried.
+func (self *CdrServer) ProcessCdrs(cdrs []*StoredCdr) ([]error, error) {
+	errs := make([]error, len(cdrs))
+	lockKeys := make([]string, 0, len(cdrs))
+	cgrIds := make(map[string]bool, len(cdrs)) // true if stored
+	for _, cdr := range cdrs {
+		if _, hasIt := cgrIds[cdr.CgrId]; !hasIt {
+			cgrIds[cdr.CgrId] = false
+			lockKeys = append(lockKeys, CDRS_LOCK_PREFIX+cdr.CgrId)
+		}
+	}
+	processed := 0
+	_, err := self.guard.Guard(func() (interface{}, error) { // Batches retried while the first attempt is still running wait for it
+		if self.cgrCfg.CDRSStoreCdrs {
+			qryCgrIds := make([]string, 0, len(cgrIds))
+			for cgrId := range cgrIds {
+				qryCgrIds = append(qryCgrIds, cgrId)
+			}
+			storedCdrs, _, err := self.cdrDb.GetStoredCdrs(&utils.CdrsFilter{CgrIds: qryCgrIds})
+			if err != nil {
+				return nil, err
+			}
+			for _, cdr := range storedCdrs {
+				cgrIds[cdr.CgrId] = true
+			}
+		}
+		for idx, cdr := range cdrs {
+			if !cgrIds[cdr.CgrId] {
+				if err := self.processCdr(cdr); err != nil {
+					if _, notStored := err.(*cdrStoreError); notStored {
+						return nil, err
+					}
+					errs[idx] = err
+				} else if self.cgrCfg.CDRSStoreCdrs {
+					cgrIds[cdr.CgrId] = true
+				}
+			}
+			processed += 1
+		}
+		return nil, nil
+	}, 0, lockKeys...)
+	return errs[:processed], err
+}
+
 // RPC method, used to process external CDRs
 func (self *CdrServer) ProcessExternalCdr(cdr *ExternalCdr) error {
 	storedCdr, err := NewStoredCdrFromExternalCdr(cdr, self.cgrCfg.DefaultTimezone)
@@ -135,14 +199,30 @@ func (self *CdrServer) RateCdrs(cgrIds, runIds, tors, cdrHosts, cdrSources, reqT
 	} else if rerateRated {
 		costStart = utils.Float64Pointer(0.0)
 	}
-	cdrs, _, err := self.cdrDb.GetStoredCdrs(&utils.CdrsFilter{CgrIds: cgrIds, RunIds: runIds, Tors: tors, CdrHosts: cdrHosts, CdrSources: cdrSources,
+	cdrsFltr := &utils.CdrsFilter{CgrIds: cgrIds, RunIds: runIds, Tors: tors, CdrHosts: cdrHosts, CdrSources: cdrSources,
 		ReqTypes: reqTypes, Directions: directions, Tenants: tenants, Categories: categories, Accounts: accounts,
 		Subjects: subjects, DestPrefixes: destPrefixes, RatedAccounts: ratedAccounts, RatedSubjects: ratedSubjects,
 		OrderIdStart: orderIdStart, OrderIdEnd: orderIdEnd, AnswerTimeStart: &timeStart, AnswerTimeEnd: &timeEnd,
-		MinCost: costStart, MaxCost: costEnd})
-	if err != nil {
-		return err
+		MinCost: costStart, MaxCost: costEnd}
+	// Page on the cursor so the CDRs re-rated, hence not matching the cost filter anymore, do not shift the pages
+	var cdrs []*StoredCdr
+	var cursor string
+	var err error
+	for {
+		if cdrs, cursor, err = self.cdrDb.GetStoredCdrsPage(cdrsFltr, cursor, CDRS_RATE_PAGE_SIZE); err != nil {
+			return err
+		}
+		if err := self.rateCdrs(cdrs); err != nil {
+			return err
+		}
+		if cursor == "" {
+			return nil
+		}
 	}
+}
+
+// Re-rates one page of CDRs
+func (self *CdrServer) rateCdrs(cdrs []*StoredCdr) error {
 	for _, cdr := range cdrs {
 		if cdr.MediationRunId == "" { // raw CDRs which were not calculated before
 			cdr.MediationRunId = utils.META_DEFAULT
@@ -172,6 +252,13 @@ func (self *CdrServer) RateCdrs(cgrIds, runIds, tors, cdrHosts, cdrSources, reqT
 
 // Returns error if not able to properly store the CDR, mediation is async since we can always recover offline
 func (self *CdrServer) processCdr(storedCdr *StoredCdr) (err error) {
+	defer func() {
+		if err != nil {
+			cdrsFailed.Inc()
+		} else {
+			cdrsProcessed.Inc()
+		}
+	}()
 	if storedCdr.Direction == "" {
 		storedCdr.Direction = utils.OUT
 	}
@@ -206,11 +293,19 @@ func (self *CdrServer) processCdr(storedCdr *StoredCdr) (err error) {
 	if self.cgrCfg.CDRSStoreCdrs { // Store RawCDRs, this we do sync so we can reply with the status
 		if err := self.cdrDb.SetCdr(storedCdr); err != nil { // Only original CDR stored in primary table, no derived
 			utils.Logger.Err(fmt.Sprintf("<CDRS> Storing primary CDR %+v, got error: %s", storedCdr, err.Error()))
-			return err // Error is propagated back and we don't continue processing the CDR if we cannot store it
+			return &cdrStoreError{err} // Error is propagated back and we don't continue processing the CDR if we cannot store it
 		}
 
 	}
-	go self.deriveRateStoreStatsReplicate(storedCdr)
+	if cap(self.deriveRoutines) != 0 { // Wait here for a free routine, pushing back to the CDR sources
+		self.deriveRoutines <- struct{}{}
+	}
+	go func() {
+		self.deriveRateStoreStatsReplicate(storedCdr)
+		if cap(self.deriveRoutines) != 0 {
+			<-self.deriveRoutines
+		}
+	}()
 	return nil
 }
 
@@ -250,6 +345,7 @@ func (self *CdrServer) rateStoreStatsReplicate(cdr *StoredCdr) error {
 		if err := self.rateCDR(cdr); err != nil {
 			cdr.Cost = -1.0 // If there was an error, mark the CDR
 			cdr.ExtraInfo = err.Error()
+			cdrsRatingFailed.Inc()
 		}
 	}
 	if cdr.MediationRunId == utils.META_SURETAX { // Request should be processed by SureTax
@@ -0,0 +1,94 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Totals of the CDRs sharing the same values on the fields grouped on
+type CdrsAggregate struct {
+	GroupValues map[string]string // Values of the fields grouped on, indexed on field name
+	TimeBucket  time.Time         // Start of the AnswerTime interval grouped on, zero if not grouping on time
+	Count       int64
+	Usage       time.Duration
+	Cost        float64 // CDRs which failed rating are not considered
+}
+
+// Checks that the CDRs can be aggregated on the fields and time bucket received
+func CheckCdrsAggregateGroupBy(groupBy []string, timeBucket string) error {
+	for _, fld := range groupBy {
+		if !utils.IsSliceMember(utils.CdrsAggregateFields, fld) {
+			return fmt.Errorf("Unsupported group-by field: %s", fld)
+		}
+	}
+	if !utils.IsSliceMember(utils.CdrsAggregateTimeBuckets, timeBucket) {
+		return fmt.Errorf("Unsupported time bucket: %s", timeBucket)
+	}
+	return nil
+}
+
+// Filter the CDRs are aggregated with, restricted to the *default run unless runs are filtered or grouped on,
+// so the CDRs derived out of one primary CDR are not counted more than once
+func cdrsAggregateFilter(qryFltr *utils.CdrsFilter, groupBy []string) *utils.CdrsFilter {
+	if len(qryFltr.RunIds) != 0 || utils.IsSliceMember(groupBy, utils.MEDI_RUNID) {
+		return qryFltr
+	}
+	aggrFltr := *qryFltr
+	aggrFltr.RunIds = []string{utils.META_DEFAULT}
+	return &aggrFltr
+}
+
+// Key identifying the group of the aggregate
+func (self *CdrsAggregate) groupKey(groupBy []string) string {
+	keyVals := make([]string, len(groupBy)+1)
+	for idx, fld := range groupBy {
+		keyVals[idx] = self.GroupValues[fld]
+	}
+	keyVals[len(groupBy)] = self.TimeBucket.Format(time.RFC3339)
+	return strings.Join(keyVals, utils.CONCATENATED_KEY_SEP)
+}
+
+// Merges the aggregates of the same group, computed over different partitions, and orders them on the group values
+func mergeCdrsAggregates(aggrs []*CdrsAggregate, groupBy []string) []*CdrsAggregate {
+	merged := make(map[string]*CdrsAggregate)
+	var keys []string
+	for _, aggr := range aggrs {
+		key := aggr.groupKey(groupBy)
+		if mrgd, hasIt := merged[key]; hasIt {
+			mrgd.Count += aggr.Count
+			mrgd.Usage += aggr.Usage
+			mrgd.Cost += aggr.Cost
+			continue
+		}
+		merged[key] = aggr
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	mrgdAggrs := make([]*CdrsAggregate, len(keys))
+	for idx, key := range keys {
+		mrgdAggrs[idx] = merged[key]
+	}
+	return mrgdAggrs
+}
@@ -0,0 +1,70 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestCheckCdrsAggregateGroupBy(t *testing.T) {
+	if err := CheckCdrsAggregateGroupBy([]string{utils.ACCOUNT, utils.DESTINATION_ID}, utils.META_DAILY); err != nil {
+		t.Error(err)
+	}
+	if err := CheckCdrsAggregateGroupBy([]string{utils.SUBJECT}, ""); err == nil {
+		t.Error("Expecting error for unsupported field")
+	}
+	if err := CheckCdrsAggregateGroupBy(nil, "*weekly"); err == nil {
+		t.Error("Expecting error for unsupported time bucket")
+	}
+}
+
+func TestMergeCdrsAggregates(t *testing.T) {
+	day1 := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	aggrs := []*CdrsAggregate{ // As received out of two partitions
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1002"}, TimeBucket: day1, Count: 1, Usage: time.Minute, Cost: 0.5},
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1001"}, TimeBucket: day2, Count: 2, Usage: 2 * time.Minute, Cost: 1},
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1001"}, TimeBucket: day1, Count: 3, Usage: 3 * time.Minute, Cost: 1.5},
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1001"}, TimeBucket: day2, Count: 1, Usage: time.Minute, Cost: 0.5},
+	}
+	eAggrs := []*CdrsAggregate{
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1001"}, TimeBucket: day1, Count: 3, Usage: 3 * time.Minute, Cost: 1.5},
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1001"}, TimeBucket: day2, Count: 3, Usage: 3 * time.Minute, Cost: 1.5},
+		&CdrsAggregate{GroupValues: map[string]string{utils.ACCOUNT: "1002"}, TimeBucket: day1, Count: 1, Usage: time.Minute, Cost: 0.5},
+	}
+	if rcvAggrs := mergeCdrsAggregates(aggrs, []string{utils.ACCOUNT}); !reflect.DeepEqual(eAggrs, rcvAggrs) {
+		t.Errorf("Expecting: %s, received: %s", utils.ToJSON(eAggrs), utils.ToJSON(rcvAggrs))
+	}
+}
+
+func TestCdrsAggregateFilter(t *testing.T) {
+	if fltr := cdrsAggregateFilter(&utils.CdrsFilter{Accounts: []string{"1001"}}, []string{utils.ACCOUNT}); !reflect.DeepEqual(fltr.RunIds, []string{utils.META_DEFAULT}) {
+		t.Errorf("Received runs: %+v", fltr.RunIds)
+	}
+	if fltr := cdrsAggregateFilter(&utils.CdrsFilter{RunIds: []string{"run2"}}, nil); !reflect.DeepEqual(fltr.RunIds, []string{"run2"}) {
+		t.Errorf("Received runs: %+v", fltr.RunIds)
+	}
+	if fltr := cdrsAggregateFilter(&utils.CdrsFilter{}, []string{utils.MEDI_RUNID}); len(fltr.RunIds) != 0 {
+		t.Errorf("Received runs: %+v", fltr.RunIds)
+	}
+}
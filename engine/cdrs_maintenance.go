@@ -0,0 +1,137 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	CDRS_MAINTENANCE_INTERVAL = time.Hour // How often the partitions are rolled and the retention applied
+	CDRS_ARCHIVE_BATCH        = 1000      // CDRs read out of the storage at once when archiving
+	CDRS_ARCHIVE_LAYOUT       = "20060102150405"
+)
+
+func NewCdrsMaintenance(cgrCfg *config.CGRConfig, cdrDb CdrStorage) *CdrsMaintenance {
+	return &CdrsMaintenance{cgrCfg: cgrCfg, cdrDb: cdrDb, stopChan: make(chan struct{})}
+}
+
+// Keeps the stored CDRs partitioned and within their retention period
+type CdrsMaintenance struct {
+	cgrCfg   *config.CGRConfig
+	cdrDb    CdrStorage
+	stopChan chan struct{}
+}
+
+// Runs the maintenance periodically, blocking till Shutdown
+func (self *CdrsMaintenance) ListenAndServe() error {
+	for {
+		if err := self.Run(time.Now()); err != nil {
+			utils.Logger.Err(fmt.Sprintf("<CdrsMaintenance> Error: %s", err.Error()))
+		}
+		select {
+		case <-self.stopChan:
+			return nil
+		case <-time.After(CDRS_MAINTENANCE_INTERVAL):
+		}
+	}
+}
+
+func (self *CdrsMaintenance) Shutdown() {
+	close(self.stopChan)
+}
+
+// Rolls the CDRs of the past months into their partitions and removes the ones expired at now
+func (self *CdrsMaintenance) Run(now time.Time) error {
+	if self.cgrCfg.StorDBCdrsPartitioning == utils.META_MONTHLY {
+		if err := self.cdrDb.RollCdrPartitions(now); err != nil {
+			return err
+		}
+	}
+	if self.cgrCfg.StorDBCdrsRetention == 0 {
+		return nil
+	}
+	storedBefore := now.Add(-self.cgrCfg.StorDBCdrsRetention)
+	if self.cgrCfg.StorDBCdrsRetentionAction == utils.META_ARCHIVE {
+		if err := self.archive(storedBefore); err != nil {
+			return err
+		}
+	}
+	return self.cdrDb.PurgeCdrs(storedBefore)
+}
+
+// Writes the CDRs stored before storedBefore into a new file of the archive dir, one JSON object per line.
+// The file gets its final name only once complete.
+func (self *CdrsMaintenance) archive(storedBefore time.Time) error {
+	fileName := fmt.Sprintf("cdrs_%s.json", storedBefore.Format(CDRS_ARCHIVE_LAYOUT))
+	tmpPath := path.Join(self.cgrCfg.StorDBCdrsArchiveDir, "."+fileName)
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath) // No effect once renamed
+	wrtr := bufio.NewWriter(file)
+	encoder := json.NewEncoder(wrtr)
+	archived := 0
+	var cursor string
+	for {
+		var cdrs []*StoredCdr
+		if cdrs, cursor, err = self.cdrDb.GetStoredCdrsPage(&utils.CdrsFilter{CreatedAtEnd: &storedBefore, Unscoped: true}, cursor, CDRS_ARCHIVE_BATCH); err != nil {
+			file.Close()
+			return err
+		}
+		for _, cdr := range cdrs {
+			if err := encoder.Encode(cdr); err != nil {
+				file.Close()
+				return err
+			}
+		}
+		archived += len(cdrs)
+		if cursor == "" {
+			break
+		}
+	}
+	if err := wrtr.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if archived == 0 {
+		return nil
+	}
+	if err := os.Rename(tmpPath, path.Join(self.cgrCfg.StorDBCdrsArchiveDir, fileName)); err != nil {
+		return err
+	}
+	utils.Logger.Info(fmt.Sprintf("<CdrsMaintenance> Archived %d CDRs stored before %s into %s", archived, storedBefore, fileName))
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Records the maintenance done on it
+type maintainedCdrStorage struct {
+	CdrStorage
+	cdrs         []*StoredCdr
+	rolledBefore time.Time
+	purgedBefore time.Time
+}
+
+// Cursor is the index of the CDR the page starts with
+func (self *maintainedCdrStorage) GetStoredCdrsPage(qryFltr *utils.CdrsFilter, cursor string, pageSize int) ([]*StoredCdr, string, error) {
+	start, _ := strconv.Atoi(cursor)
+	if start+pageSize >= len(self.cdrs) {
+		return self.cdrs[start:], "", nil
+	}
+	return self.cdrs[start : start+pageSize], strconv.Itoa(start + pageSize), nil
+}
+
+func (self *maintainedCdrStorage) RollCdrPartitions(storedBefore time.Time) error {
+	self.rolledBefore = storedBefore
+	return nil
+}
+
+func (self *maintainedCdrStorage) PurgeCdrs(storedBefore time.Time) error {
+	self.purgedBefore = storedBefore
+	return nil
+}
+
+func TestCdrsMaintenanceRun(t *testing.T) {
+	cfg, _ := config.NewDefaultCGRConfig()
+	var err error
+	if cfg.StorDBCdrsArchiveDir, err = ioutil.TempDir("", "cdrs_archive"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cfg.StorDBCdrsArchiveDir)
+	cfg.StorDBCdrsPartitioning = utils.META_MONTHLY
+	cfg.StorDBCdrsRetention = time.Duration(24) * time.Hour
+	cfg.StorDBCdrsRetentionAction = utils.META_ARCHIVE
+	cdrDb := new(maintainedCdrStorage)
+	for i := 0; i < CDRS_ARCHIVE_BATCH+1; i++ { // Over one batch
+		cdrDb.cdrs = append(cdrDb.cdrs, &StoredCdr{CgrId: utils.Sha1("archived", strconv.Itoa(i)), MediationRunId: utils.DEFAULT_RUNID, Usage: time.Duration(i) * time.Second})
+	}
+	now := time.Date(2016, 2, 3, 10, 0, 0, 0, time.UTC)
+	if err := NewCdrsMaintenance(cfg, cdrDb).Run(now); err != nil {
+		t.Fatal(err)
+	}
+	if !cdrDb.rolledBefore.Equal(now) {
+		t.Errorf("Rolled before: %v", cdrDb.rolledBefore)
+	}
+	if eBefore := time.Date(2016, 2, 2, 10, 0, 0, 0, time.UTC); !cdrDb.purgedBefore.Equal(eBefore) {
+		t.Errorf("Expecting purged before: %v, received: %v", eBefore, cdrDb.purgedBefore)
+	}
+	files, _ := ioutil.ReadDir(cfg.StorDBCdrsArchiveDir)
+	if len(files) != 1 || files[0].Name() != "cdrs_20160202100000.json" {
+		t.Fatalf("Archive files: %+v", files)
+	}
+	file, _ := os.Open(path.Join(cfg.StorDBCdrsArchiveDir, files[0].Name()))
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	archived := 0
+	for ; scanner.Scan(); archived++ {
+		var cdr StoredCdr
+		if err := json.Unmarshal(scanner.Bytes(), &cdr); err != nil {
+			t.Fatal(err)
+		}
+		if cdr.CgrId != cdrDb.cdrs[archived].CgrId {
+			t.Errorf("Line %d, expecting: %+v, received: %+v", archived, cdrDb.cdrs[archived], cdr)
+		}
+	}
+	if archived != len(cdrDb.cdrs) {
+		t.Errorf("Archived %d CDRs out of %d", archived, len(cdrDb.cdrs))
+	}
+	// Nothing left to archive
+	cdrDb.cdrs = nil
+	if err := NewCdrsMaintenance(cfg, cdrDb).Run(now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := ioutil.ReadDir(cfg.StorDBCdrsArchiveDir); len(files) != 1 {
+		t.Errorf("Archive files: %+v", files)
+	}
+}
+
+func TestFilterCdrPartitions(t *testing.T) {
+	partitions := []string{"201511", "201512", "201601", "201602"}
+	createdStart := time.Date(2015, 12, 15, 0, 0, 0, 0, time.Local)
+	createdEnd := time.Date(2016, 2, 1, 0, 0, 0, 0, time.Local)
+	if fltrd := filterCdrPartitions(partitions, &utils.CdrsFilter{CreatedAtStart: &createdStart, CreatedAtEnd: &createdEnd}); len(fltrd) != 2 ||
+		fltrd[0] != "201512" || fltrd[1] != "201601" {
+		t.Errorf("Filtered partitions: %+v", fltrd)
+	}
+	if fltrd := filterCdrPartitions(partitions, &utils.CdrsFilter{CreatedAtStart: &createdStart, CreatedAtEnd: &createdEnd, FilterOnRated: true}); len(fltrd) != 4 {
+		t.Errorf("Filtered partitions: %+v", fltrd)
+	}
+	if src := cdrTableSource(utils.TBL_CDRS_PRIMARY, ""); src != utils.TBL_CDRS_PRIMARY {
+		t.Errorf("Table source: %s", src)
+	}
+	if src, eSrc := cdrTableSource(utils.TBL_CDRS_PRIMARY, "201512"), "cdrs_primary_201512 AS cdrs_primary"; src != eSrc {
+		t.Errorf("Expecting: %s, received: %s", eSrc, src)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+var ErrInvalidCdrsCursor = errors.New("INVALID_CURSOR")
+
+// Queries one page of CDRs out of getter using keyset pagination on OrderId, the cursor being the OrderId where the page starts.
+// CDRs derived out of the same primary one share its OrderId and are kept on the same page, the trailing ones are left for the next page.
+// A primary CDR with more derived ones than the page size gets them all on one page.
+// Returns the cursor of the next page, empty when there are no more CDRs to query. The offset in qryFltr applies only to the first page.
+func PageStoredCdrsOnOrderId(getter func(*utils.CdrsFilter) ([]*StoredCdr, int64, error), qryFltr *utils.CdrsFilter,
+	cursor string, pageSize int) ([]*StoredCdr, string, error) {
+	if pageSize <= 0 {
+		return nil, "", utils.NewErrMandatoryIeMissing("PageSize")
+	}
+	pageFltr := *qryFltr
+	pageFltr.Count = false
+	pageFltr.Paginator = utils.Paginator{Limit: &pageSize, SearchTerm: qryFltr.Paginator.SearchTerm}
+	if cursor == "" {
+		pageFltr.Paginator.Offset = qryFltr.Paginator.Offset
+	} else {
+		orderIdStart, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || orderIdStart < qryFltr.OrderIdStart {
+			return nil, "", ErrInvalidCdrsCursor
+		}
+		pageFltr.OrderIdStart = orderIdStart
+	}
+	cdrs, _, err := getter(&pageFltr)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(cdrs) < pageSize { // Nothing more to query
+		return cdrs, "", nil
+	}
+	lastOrderId := cdrs[len(cdrs)-1].OrderId
+	pageEnd := len(cdrs)
+	for pageEnd > 0 && cdrs[pageEnd-1].OrderId == lastOrderId {
+		pageEnd--
+	}
+	if pageEnd == 0 { // Whole page occupied by one primary CDR, return all of its CDRs even if over the page size
+		pageFltr.OrderIdStart, pageFltr.OrderIdEnd = lastOrderId, lastOrderId+1
+		pageFltr.Paginator = utils.Paginator{SearchTerm: qryFltr.Paginator.SearchTerm}
+		if cdrs, _, err = getter(&pageFltr); err != nil {
+			return nil, "", err
+		}
+		return cdrs, strconv.FormatInt(lastOrderId+1, 10), nil
+	}
+	return cdrs[:pageEnd], strconv.FormatInt(lastOrderId, 10), nil
+}
@@ -0,0 +1,83 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestPageStoredCdrsOnOrderId(t *testing.T) {
+	var storCdrs []*StoredCdr
+	for _, orderId := range []int64{1, 2, 2, 3, 4, 4, 4, 5} { // Derived CDRs share the OrderId of the primary one
+		storCdrs = append(storCdrs, &StoredCdr{OrderId: orderId})
+	}
+	var queries int
+	getter := func(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
+		queries += 1
+		var cdrs []*StoredCdr
+		var skipped int
+		for _, cdr := range storCdrs {
+			if cdr.OrderId < qryFltr.OrderIdStart || (qryFltr.OrderIdEnd != 0 && cdr.OrderId >= qryFltr.OrderIdEnd) {
+				continue
+			}
+			if qryFltr.Paginator.Offset != nil && skipped < *qryFltr.Paginator.Offset {
+				skipped += 1
+				continue
+			}
+			if qryFltr.Paginator.Limit != nil && len(cdrs) == *qryFltr.Paginator.Limit {
+				break
+			}
+			cdrs = append(cdrs, cdr)
+		}
+		return cdrs, 0, nil
+	}
+	var orderIds []int64
+	var cursor string
+	for {
+		cdrs, nextCursor, err := PageStoredCdrsOnOrderId(getter, &utils.CdrsFilter{OrderIdStart: 2}, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cdr := range cdrs {
+			orderIds = append(orderIds, cdr.OrderId)
+		}
+		if cursor = nextCursor; cursor == "" {
+			break
+		}
+	}
+	// Pages: [2 2] and [4 4 4] over one OrderId each so queried again without limit, [3] with 4 moved to the next page, [5]
+	if eIds := []int64{2, 2, 3, 4, 4, 4, 5}; !reflect.DeepEqual(eIds, orderIds) {
+		t.Errorf("Expecting: %v, received: %v", eIds, orderIds)
+	} else if queries != 6 {
+		t.Errorf("Queries: %d", queries)
+	}
+	// Offset on the first page only
+	cdrs, cursor, err := PageStoredCdrsOnOrderId(getter, &utils.CdrsFilter{Paginator: utils.Paginator{Offset: utils.IntPointer(3)}}, "", 3)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(cdrs) != 1 || cdrs[0].OrderId != 3 || cursor != "4" {
+		t.Errorf("Cdrs: %+v, cursor: %s", cdrs, cursor)
+	}
+	if _, _, err := PageStoredCdrsOnOrderId(getter, &utils.CdrsFilter{OrderIdStart: 2}, "1", 3); err != ErrInvalidCdrsCursor {
+		t.Error("Expecting invalid cursor, received: ", err)
+	}
+}
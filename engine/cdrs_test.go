@@ -0,0 +1,89 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Stores the raw CDRs in memory, failing once to store the CDR with failCgrId
+type batchCdrStorage struct {
+	CdrStorage
+	sync.Mutex
+	cgrIds    []string
+	failCgrId string
+}
+
+func (self *batchCdrStorage) SetCdr(cdr *StoredCdr) error {
+	self.Lock()
+	defer self.Unlock()
+	if cdr.CgrId == self.failCgrId {
+		self.failCgrId = ""
+		return errors.New("storage unavailable")
+	}
+	self.cgrIds = append(self.cgrIds, cdr.CgrId)
+	return nil
+}
+
+func (self *batchCdrStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
+	self.Lock()
+	defer self.Unlock()
+	var cdrs []*StoredCdr
+	for _, cgrId := range self.cgrIds {
+		if utils.IsSliceMember(qryFltr.CgrIds, cgrId) {
+			cdrs = append(cdrs, &StoredCdr{CgrId: cgrId})
+		}
+	}
+	return cdrs, 0, nil
+}
+
+func (self *batchCdrStorage) SetRatedCdr(cdr *StoredCdr) error {
+	return nil
+}
+
+func (self *batchCdrStorage) LogCallCost(cgrid, source, runid string, cc *CallCost) error {
+	return nil
+}
+
+func TestCdrsProcessCdrsRetried(t *testing.T) {
+	cfg, _ := config.NewDefaultCGRConfig()
+	cfg.CDRSDeriveConcurrency = 1
+	cdrDb := &batchCdrStorage{failCgrId: "cdr2"}
+	cdrSrv, _ := NewCdrServer(cfg, cdrDb, nil, nil, nil, nil, nil)
+	var cdrs []*StoredCdr
+	for _, cgrId := range []string{"cdr1", "cdr2", "cdr3"} {
+		cdrs = append(cdrs, &StoredCdr{CgrId: cgrId, ReqType: utils.META_RATED, Rated: true}) // Rated CDRs are not derived
+	}
+	errs, err := cdrSrv.ProcessCdrs(cdrs)
+	if err == nil || len(errs) != 1 || errs[0] != nil { // Interrupted by the second CDR
+		t.Fatalf("Errors: %+v, error: %v", errs, err)
+	}
+	if errs, err = cdrSrv.ProcessCdrs(cdrs); err != nil || len(errs) != 3 {
+		t.Fatalf("Errors: %+v, error: %v", errs, err)
+	}
+	if !reflect.DeepEqual([]string{"cdr1", "cdr2", "cdr3"}, cdrDb.cgrIds) { // First CDR not stored twice
+		t.Errorf("Stored CDRs: %+v", cdrDb.cgrIds)
+	}
+}
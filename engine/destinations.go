@@ -72,16 +72,37 @@ func (d *Destination) GetHistoryRecord() history.Record {
 	}
 }
 
+// Ids of the destinations containing prefix, out of cache.
+// With destinations partially cached a miss is loaded from the rating storage, prefixes without destinations are cached empty.
+func getCachedDestIds(prefix string) (map[interface{}]struct{}, error) {
+	if x, err := cache2go.Get(utils.DESTINATION_PREFIX + prefix); err == nil {
+		return x.(map[interface{}]struct{}), nil
+	} else if !cache2go.IsPartial(utils.DESTINATION_PREFIX) || ratingStorage == nil {
+		return nil, err
+	}
+	dIDs, err := ratingStorage.GetReverseDestination(prefix)
+	if err != nil && err != utils.ErrNotFound {
+		return nil, err
+	}
+	destIds := make(map[interface{}]struct{}, len(dIDs))
+	for _, dID := range dIDs {
+		destIds[dID] = struct{}{}
+	}
+	cache2go.Cache(utils.DESTINATION_PREFIX+prefix, destIds)
+	return destIds, nil
+}
+
 // Reverse search in cache to see if prefix belongs to destination id
 func CachedDestHasPrefix(destId, prefix string) bool {
-	if cached, err := cache2go.Get(utils.DESTINATION_PREFIX + prefix); err == nil {
-		_, found := cached.(map[interface{}]struct{})[destId]
+	if destIds, err := getCachedDestIds(prefix); err == nil {
+		_, found := destIds[destId]
 		return found
 	}
 	return false
 }
 
-func CleanStalePrefixes(destIds []string) {
+// Removes destIds from the cached prefixes, tx collects the cache writes, nil to write directly
+func CleanStalePrefixes(destIds []string, tx *cache2go.Transaction) {
 	prefixMap, err := cache2go.GetAllEntries(utils.DESTINATION_PREFIX)
 	if err != nil {
 		return
@@ -93,7 +114,7 @@ func CleanStalePrefixes(destIds []string) {
 			if _, found := dIDs[searchedDID]; found {
 				if len(dIDs) == 1 {
 					// remove de prefix from cache
-					cache2go.RemKey(utils.DESTINATION_PREFIX + prefix)
+					tx.RemKey(utils.DESTINATION_PREFIX + prefix)
 				} else {
 					// delete the destination from list and put the new list in chache
 					delete(dIDs, searchedDID)
@@ -102,7 +123,7 @@ func CleanStalePrefixes(destIds []string) {
 			}
 		}
 		if changed {
-			cache2go.Cache(utils.DESTINATION_PREFIX+prefix, dIDs)
+			tx.Cache(utils.DESTINATION_PREFIX+prefix, dIDs)
 		}
 	}
 }
@@ -131,7 +131,7 @@ func TestCleanStalePrefixes(t *testing.T) {
 	cache2go.Cache(utils.DESTINATION_PREFIX+"1", map[interface{}]struct{}{"D1": x, "D2": x})
 	cache2go.Cache(utils.DESTINATION_PREFIX+"2", map[interface{}]struct{}{"D1": x})
 	cache2go.Cache(utils.DESTINATION_PREFIX+"3", map[interface{}]struct{}{"D2": x})
-	CleanStalePrefixes([]string{"D1"})
+	CleanStalePrefixes([]string{"D1"}, nil)
 	if r, err := cache2go.Get(utils.DESTINATION_PREFIX + "1"); err != nil || len(r.(map[interface{}]struct{})) != 1 {
 		t.Error("Error cleaning stale destination ids", r)
 	}
@@ -152,3 +152,21 @@ func BenchmarkDestinationStorageStoreRestore(b *testing.B) {
 		ratingStorage.GetDestination(nationale.Id)
 	}
 }
+
+func TestDestinationIndexReverse(t *testing.T) {
+	ms, _ := NewMapStorage()
+	if err := ms.SetDestination(&Destination{Id: "GERMANY", Prefixes: []string{"49", "4915"}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"49", "4915"} { // Stored before the reverse destinations were kept
+		ms.del(utils.REVERSE_DESTINATION_PREFIX + p)
+	}
+	if err := ms.indexReverseDestinations(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"49", "4915"} {
+		if dIDs, err := ms.GetReverseDestination(p); err != nil || len(dIDs) != 1 || dIDs[0] != "GERMANY" {
+			t.Errorf("Prefix: %s, destinations: %+v, error: %v", p, dIDs, err)
+		}
+	}
+}
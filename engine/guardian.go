@@ -21,8 +21,12 @@ package engine
 import (
 	"sync"
 	"time"
+
+	"github.com/cgrates/cgrates/utils"
 )
 
+var guardianLockWait = utils.Metrics.Histogram("cgr_guardian_lock_wait_seconds", "Time waited by Guardian for its locks.", utils.MetricsLatencyBuckets)
+
 // global package variable
 var Guardian = &GuardianLock{queue: make(map[string]chan bool)}
 
@@ -36,6 +40,7 @@ type GuardianLock struct {
 }
 
 func (cm *GuardianLock) Guard(handler func() (interface{}, error), timeout time.Duration, names ...string) (reply interface{}, err error) {
+	waitStart := time.Now()
 	cm.mu.Lock()
 	for _, name := range names {
 		lock, exists := Guardian.queue[name]
@@ -46,6 +51,7 @@ func (cm *GuardianLock) Guard(handler func() (interface{}, error), timeout time.
 		lock <- true
 	}
 	cm.mu.Unlock()
+	guardianLockWait.Observe(utils.MetricsSince(waitStart))
 	funcWaiter := make(chan bool)
 	go func() {
 		// execute
@@ -433,22 +433,23 @@ func (t TblCdrsExtra) TableName() string {
 }
 
 type TblCostDetail struct {
-	Id          int64
-	Cgrid       string
-	Runid       string
-	Tor         string
-	Direction   string
-	Tenant      string
-	Category    string
-	Account     string
-	Subject     string
-	Destination string
-	Cost        float64
-	Timespans   string
-	CostSource  string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	DeletedAt   time.Time
+	Id            int64
+	Cgrid         string
+	Runid         string
+	Tor           string
+	Direction     string
+	Tenant        string
+	Category      string
+	Account       string
+	Subject       string
+	Destination   string
+	DestinationId string
+	Cost          float64
+	Timespans     string
+	CostSource    string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     time.Time
 }
 
 func (t TblCostDetail) TableName() string {
@@ -482,3 +483,33 @@ type TblRatedCdr struct {
 func (t TblRatedCdr) TableName() string {
 	return utils.TBL_RATED_CDRS
 }
+
+type TblCdrsExport struct {
+	Id        int64
+	Cgrid     string
+	ExportId  string
+	CreatedAt time.Time
+}
+
+func (t TblCdrsExport) TableName() string {
+	return utils.TBL_CDRS_EXPORTS
+}
+
+type TblCdreRun struct {
+	Id               int64
+	JobId            string
+	ExportId         string
+	OrderIdStart     int64
+	LastOrderId      int64
+	ExportedFilePath string
+	TotalRecords     int
+	FailedRecords    int
+	TotalCost        float64
+	StartTime        time.Time
+	EndTime          time.Time
+	Error            string
+}
+
+func (t TblCdreRun) TableName() string {
+	return utils.TBL_CDRE_RUNS
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/cgrates/rpcclient"
 )
 
+var pubsubDeliveries = utils.Metrics.Counter("cgr_pubsub_deliveries_total", "Events delivered to subscribers, per transport and status.", "transport", "status")
+
 type SubscribeInfo struct {
 	EventFilter string
 	Transport   string
@@ -146,9 +148,11 @@ func (ps *PubSub) Publish(evt CgrEvent, reply *string) error {
 				delay := utils.Fib()
 				for i := 0; i < 5; i++ { // Loop so we can increase the success rate on best effort
 					if _, err := ps.pubFunc(address, ps.ttlVerify, evt); err == nil {
+						pubsubDeliveries.Inc(utils.META_HTTP_POST, "ok")
 						break // Success, no need to reinterate
 					} else if i == 4 { // Last iteration, syslog the warning
 						utils.Logger.Warning(fmt.Sprintf("<PubSub> Failed calling url: [%s], error: [%s], event type: %s", address, err.Error(), evt["EventName"]))
+						pubsubDeliveries.Inc(utils.META_HTTP_POST, "failed")
 						break
 					}
 					time.Sleep(delay())
@@ -166,7 +170,7 @@ func (ps *PubSub) ShowSubscribers(in string, out *map[string]*SubscriberData) er
 }
 
 type ProxyPubSub struct {
-	Client *rpcclient.RpcClient
+	Client rpcclient.RpcClientConnection
 }
 
 func NewProxyPubSub(addr string, attempts, reconnects int) (*ProxyPubSub, error) {
@@ -34,6 +34,19 @@ import (
 	"github.com/cgrates/rpcclient"
 )
 
+var (
+	responderDuration = utils.Metrics.Histogram("cgr_responder_duration_seconds", "Duration of the Responder operations.", utils.MetricsLatencyBuckets, "operation")
+	responderErrors   = utils.Metrics.Counter("cgr_responder_errors_total", "Responder operations ending in error.", "operation")
+)
+
+// Deferred by the measured operations, err points to their named result
+func observeResponder(operation string, start time.Time, err *error) {
+	responderDuration.Observe(utils.MetricsSince(start), operation)
+	if *err != nil {
+		responderErrors.Inc(operation)
+	}
+}
+
 // Individual session run
 type SessionRun struct {
 	DerivedCharger *utils.DerivedCharger // Needed in reply
@@ -77,6 +90,7 @@ func (rs *Responder) getCache() *cache2go.ResponseCache {
 RPC method thet provides the external RPC interface for getting the rating information.
 */
 func (rs *Responder) GetCost(arg *CallDescriptor, reply *CallCost) (err error) {
+	defer observeResponder("GetCost", time.Now(), &err)
 	rs.cnt += 1
 	if arg.Subject == "" {
 		arg.Subject = arg.Account
@@ -151,6 +165,7 @@ func (rs *Responder) Debit(arg *CallDescriptor, reply *CallCost) (err error) {
 }
 
 func (rs *Responder) MaxDebit(arg *CallDescriptor, reply *CallCost) (err error) {
+	defer observeResponder("MaxDebit", time.Now(), &err)
 	if item, err := rs.getCache().Get(utils.MAX_DEBIT_CACHE_PREFIX + arg.CgrId); err == nil && item != nil {
 		*reply = *(item.Value.(*CallCost))
 		return item.Err
@@ -443,6 +458,25 @@ func (rs *Responder) ProcessCdr(cdr *StoredCdr, reply *string) error {
 	return nil
 }
 
+// Replies with one result per CDR, utils.OK or the error processing it. Storage errors fail the batch,
+// the CDRs not stored being replied with empty result so they can be retried.
+func (rs *Responder) ProcessCdrs(cdrs []*StoredCdr, reply *[]string) error {
+	if rs.CdrSrv == nil {
+		return errors.New("CDR_SERVER_NOT_RUNNING")
+	}
+	replies := make([]string, len(cdrs))
+	errs, err := rs.CdrSrv.ProcessCdrs(cdrs)
+	for idx, cdrErr := range errs {
+		if cdrErr != nil {
+			replies[idx] = cdrErr.Error()
+		} else {
+			replies[idx] = utils.OK
+		}
+	}
+	*reply = replies
+	return err
+}
+
 func (rs *Responder) LogCallCost(ccl *CallCostLog, reply *string) error {
 	if item, err := rs.getCache().Get(utils.LOG_CALL_COST_CACHE_PREFIX + ccl.CgrId); err == nil && item != nil {
 		*reply = item.Value.(string)
@@ -468,7 +502,8 @@ func (rs *Responder) LogCallCost(ccl *CallCostLog, reply *string) error {
 	return nil
 }
 
-func (rs *Responder) GetLCR(attrs *AttrGetLcr, reply *LCRCost) error {
+func (rs *Responder) GetLCR(attrs *AttrGetLcr, reply *LCRCost) (err error) {
+	defer observeResponder("GetLCR", time.Now(), &err)
 	if attrs.CallDescriptor.Subject == "" {
 		attrs.CallDescriptor.Subject = attrs.CallDescriptor.Account
 	}
@@ -669,13 +704,14 @@ type Connector interface {
 	GetDerivedMaxSessionTime(*StoredCdr, *float64) error
 	GetSessionRuns(*StoredCdr, *[]*SessionRun) error
 	ProcessCdr(*StoredCdr, *string) error
+	ProcessCdrs([]*StoredCdr, *[]string) error
 	LogCallCost(*CallCostLog, *string) error
 	GetLCR(*AttrGetLcr, *LCRCost) error
 	GetTimeout(int, *time.Duration) error
 }
 
 type RPCClientConnector struct {
-	Client  *rpcclient.RpcClient
+	Client  rpcclient.RpcClientConnection
 	Timeout time.Duration
 }
 
@@ -715,6 +751,10 @@ func (rcc *RPCClientConnector) ProcessCdr(cdr *StoredCdr, reply *string) error {
 	return rcc.Client.Call("CdrsV1.ProcessCdr", cdr, reply)
 }
 
+func (rcc *RPCClientConnector) ProcessCdrs(cdrs []*StoredCdr, reply *[]string) error {
+	return rcc.Client.Call("CdrsV1.ProcessCdrs", cdrs, reply)
+}
+
 func (rcc *RPCClientConnector) LogCallCost(ccl *CallCostLog, reply *string) error {
 	return rcc.Client.Call("CdrsV1.LogCallCost", ccl, reply)
 }
@@ -910,6 +950,21 @@ func (cp ConnectorPool) ProcessCdr(cdr *StoredCdr, reply *string) error {
 	return utils.ErrTimedOut
 }
 
+// Moves to the next connection only once the previous one answered, so the batch is never processed twice in parallel
+func (cp ConnectorPool) ProcessCdrs(cdrs []*StoredCdr, reply *[]string) (err error) {
+	for _, con := range cp {
+		var r []string
+		if err = con.ProcessCdrs(cdrs, &r); err == nil || len(r) != 0 { // Partial results are answered back for retrying
+			*reply = r
+			return
+		}
+	}
+	if err == nil {
+		err = utils.ErrTimedOut
+	}
+	return
+}
+
 func (cp ConnectorPool) LogCallCost(ccl *CallCostLog, reply *string) error {
 	for _, con := range cp {
 		c := make(chan error, 1)
@@ -27,6 +27,8 @@ import (
 	"github.com/cgrates/rpcclient"
 )
 
+var cdrStatsValues = utils.Metrics.Gauge("cgr_cdrstats_value", "CDR stats metrics, per queue.", "queue", "metric")
+
 type StatsInterface interface {
 	GetValues(string, *map[string]float64) error
 	GetQueueIds(int, *[]string) error
@@ -89,9 +91,23 @@ func NewStats(ratingDb RatingStorage, accountingDb AccountingStorage, saveInterv
 	} else {
 		utils.Logger.Err(fmt.Sprintf("Cannot load cdr stats: %v", err))
 	}
+	cdrStatsValues.AddCollector(cdrStats.metricSamples)
 	return cdrStats
 }
 
+// Current values of all queues, collected on /metrics scrapes
+func (s *Stats) metricSamples() []*utils.MetricSample {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	var samples []*utils.MetricSample
+	for sqID, sq := range s.queues {
+		for metric, value := range sq.GetStats() {
+			samples = append(samples, &utils.MetricSample{LabelValues: []string{sqID, metric}, Value: value})
+		}
+	}
+	return samples
+}
+
 func (s *Stats) GetQueueIds(in int, ids *[]string) error {
 	s.mux.Lock()
 	defer s.mux.Unlock()
@@ -287,7 +303,7 @@ func (s *Stats) Stop(int, *int) error {
 }
 
 type ProxyStats struct {
-	Client *rpcclient.RpcClient
+	Client rpcclient.RpcClientConnection
 }
 
 func NewProxyStats(addr string, attempts, reconnects int) (*ProxyStats, error) {
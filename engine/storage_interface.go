@@ -50,6 +50,7 @@ type RatingStorage interface {
 	RemoveRatingProfile(string) error
 	GetDestination(string) (*Destination, error)
 	SetDestination(*Destination) error
+	GetReverseDestination(string) ([]string, error)
 	GetLCR(string, bool) (*LCR, error)
 	SetLCR(*LCR) error
 	SetCdrStats(*CdrStats) error
@@ -99,7 +100,24 @@ type CdrStorage interface {
 	LogCallCost(cgrid, source, runid string, cc *CallCost) error
 	GetCallCostLog(cgrid, source, runid string) (*CallCost, error)
 	GetStoredCdrs(*utils.CdrsFilter) ([]*StoredCdr, int64, error)
+	GetStoredCdrsPage(qryFltr *utils.CdrsFilter, cursor string, pageSize int) ([]*StoredCdr, string, error)
+	GetCdrsAggregates(qryFltr *utils.CdrsFilter, groupBy []string, timeBucket string) ([]*CdrsAggregate, error)
 	RemStoredCdrs([]string) error
+	SetCdrsExportId(exportId string, cgrIds []string) error
+	SetCdreRun(*CdreRun) error
+	GetCdreRuns(jobId string, pag *utils.Paginator) ([]*CdreRun, error)
+	RollCdrPartitions(storedBefore time.Time) error
+	PurgeCdrs(storedBefore time.Time) error
+}
+
+// Cache changes log shared by the engines using the same tariffplan_db
+type CacheChangesStorage interface {
+	AddCacheChange(*CacheChange) (int64, error)
+	GetCacheChanges(sinceVersion int64) ([]*CacheChange, error)
+	GetCacheVersion() (int64, error)
+	SetCacheNodeVersion(string, *CacheNodeVersion) error
+	GetCacheNodeVersions() (map[string]*CacheNodeVersion, error)
+	SubscribeCacheChanges(versionChan chan<- int64, stopChan <-chan struct{}) error
 }
 
 type LogStorage interface {
@@ -24,8 +24,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cgrates/cgrates/cache2go"
@@ -33,8 +34,12 @@ import (
 )
 
 type MapStorage struct {
-	dict map[string][]byte
-	ms   Marshaler
+	sync.RWMutex
+	dict          map[string][]byte
+	ms            Marshaler
+	wal           *mapStorageWal // Persists the changes to disk, nil for memory only storage
+	snapshotMux   sync.Mutex     // One snapshot at a time
+	stopSnapshots chan struct{}  // Closed to stop the background snapshots
 }
 
 func NewMapStorage() (*MapStorage, error) {
@@ -45,21 +50,136 @@ func NewMapStorageJson() (*MapStorage, error) {
 	return &MapStorage{dict: make(map[string][]byte), ms: new(JSONBufMarshaler)}, nil
 }
 
-func (ms *MapStorage) Close() {}
+func (ms *MapStorage) Close() {
+	if ms.wal == nil {
+		return
+	}
+	close(ms.stopSnapshots)
+	if err := ms.snapshot(); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<MapStorage> Cannot snapshot %s, error: %s", ms.wal.name, err.Error()))
+	}
+	ms.Lock()
+	defer ms.Unlock()
+	ms.wal.close()
+}
 
 func (ms *MapStorage) Flush(ignore string) error {
+	ms.snapshotMux.Lock()
+	defer ms.snapshotMux.Unlock()
+	ms.Lock()
+	defer ms.Unlock()
 	ms.dict = make(map[string][]byte)
+	if ms.wal == nil {
+		return nil
+	}
+	if err := ms.wal.rotate(); err != nil {
+		return err
+	}
+	return ms.wal.snapshot(ms.dict, ms.wal.rotated)
+}
+
+// Snapshots the changes done since the last snapshot, the storage is locked only while copying its content
+func (ms *MapStorage) snapshot() error {
+	ms.snapshotMux.Lock()
+	defer ms.snapshotMux.Unlock()
+	ms.Lock()
+	if ms.wal.entries == 0 {
+		ms.Unlock()
+		return nil
+	}
+	if err := ms.wal.rotate(); err != nil {
+		ms.Unlock()
+		return err
+	}
+	rotated := ms.wal.rotated
+	dict := make(map[string][]byte, len(ms.dict))
+	for key, value := range ms.dict { // Values are replaced on change, never modified
+		dict[key] = value
+	}
+	ms.Unlock()
+	return ms.wal.snapshot(dict, rotated)
+}
+
+func (ms *MapStorage) snapshotLoop() {
+	ticker := time.NewTicker(MAP_SNAPSHOT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ms.stopSnapshots:
+			return
+		case <-ticker.C:
+			if err := ms.snapshot(); err != nil {
+				utils.Logger.Err(fmt.Sprintf("<MapStorage> Cannot snapshot %s, error: %s", ms.wal.name, err.Error()))
+			}
+		}
+	}
+}
+
+func (ms *MapStorage) get(key string) ([]byte, bool) {
+	ms.RLock()
+	defer ms.RUnlock()
+	value, hasIt := ms.dict[key]
+	return value, hasIt
+}
+
+func (ms *MapStorage) set(key string, value []byte) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if err := ms.logChange(MAP_WAL_SET, key, value); err != nil {
+		return err
+	}
+	ms.dict[key] = value
 	return nil
 }
 
-func (ms *MapStorage) GetKeysForPrefix(prefix string) ([]string, error) {
+func (ms *MapStorage) del(key string) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if err := ms.logChange(MAP_WAL_DEL, key, nil); err != nil {
+		return err
+	}
+	delete(ms.dict, key)
+	return nil
+}
+
+// Appends the change to the write-ahead log before it is applied, so changes are not done unless persisted. Called with the lock held.
+func (ms *MapStorage) logChange(op byte, key string, value []byte) error {
+	if ms.wal == nil {
+		return nil
+	}
+	if err := ms.wal.append(op, key, value); err != nil {
+		utils.Logger.Err(fmt.Sprintf("<MapStorage> Cannot write log for %s, key: %s, error: %s", ms.wal.name, key, err.Error()))
+		return err
+	}
+	return nil
+}
+
+// Keys starting with prefix, copied so the storage can be changed while iterating over them
+func (ms *MapStorage) keysForPrefix(prefix string) []string {
+	ms.RLock()
+	defer ms.RUnlock()
 	keysForPrefix := make([]string, 0)
 	for key := range ms.dict {
 		if strings.HasPrefix(key, prefix) {
 			keysForPrefix = append(keysForPrefix, key)
 		}
 	}
-	return keysForPrefix, nil
+	return keysForPrefix
+}
+
+// Copy of the content, safe to iterate over
+func (ms *MapStorage) items() map[string][]byte {
+	ms.RLock()
+	defer ms.RUnlock()
+	items := make(map[string][]byte, len(ms.dict))
+	for key, value := range ms.dict {
+		items[key] = value
+	}
+	return items
+}
+
+func (ms *MapStorage) GetKeysForPrefix(prefix string) ([]string, error) {
+	return ms.keysForPrefix(prefix), nil
 }
 
 func (ms *MapStorage) CacheRatingAll() error {
@@ -107,91 +227,104 @@ func (ms *MapStorage) CacheRatingPrefixValues(prefixes map[string][]string) erro
 }
 
 func (ms *MapStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys []string) error {
-	cache2go.BeginTransaction()
+	tx := cache2go.Begin()
+	if dKeys == nil && cache2go.IsPartial(utils.DESTINATION_PREFIX) {
+		if err := ms.indexReverseDestinations(); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	dKeys = invalidatePartialDestinations(dKeys, tx)
 	if dKeys == nil || (float64(cache2go.CountEntries(utils.DESTINATION_PREFIX))*utils.DESTINATIONS_LOAD_THRESHOLD < float64(len(dKeys))) {
-		cache2go.RemPrefixKey(utils.DESTINATION_PREFIX)
+		tx.RemPrefixKey(utils.DESTINATION_PREFIX)
 	} else {
-		CleanStalePrefixes(dKeys)
+		CleanStalePrefixes(dKeys, tx)
 	}
+	rpKeys = invalidatePartialCache(utils.RATING_PLAN_PREFIX, rpKeys, tx)
 	if rpKeys == nil {
-		cache2go.RemPrefixKey(utils.RATING_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.RATING_PLAN_PREFIX)
 	}
+	rpfKeys = invalidatePartialCache(utils.RATING_PROFILE_PREFIX, rpfKeys, tx)
 	if rpfKeys == nil {
-		cache2go.RemPrefixKey(utils.RATING_PROFILE_PREFIX)
+		tx.RemPrefixKey(utils.RATING_PROFILE_PREFIX)
 	}
+	lcrKeys = invalidatePartialCache(utils.LCR_PREFIX, lcrKeys, tx)
 	if lcrKeys == nil {
-		cache2go.RemPrefixKey(utils.LCR_PREFIX)
+		tx.RemPrefixKey(utils.LCR_PREFIX)
 	}
+	dcsKeys = invalidatePartialCache(utils.DERIVEDCHARGERS_PREFIX, dcsKeys, tx)
 	if dcsKeys == nil {
-		cache2go.RemPrefixKey(utils.DERIVEDCHARGERS_PREFIX)
+		tx.RemPrefixKey(utils.DERIVEDCHARGERS_PREFIX)
 	}
+	actKeys = invalidatePartialCache(utils.ACTION_PREFIX, actKeys, tx)
 	if actKeys == nil {
-		cache2go.RemPrefixKey(utils.ACTION_PREFIX) // Forced until we can fine tune it
+		tx.RemPrefixKey(utils.ACTION_PREFIX) // Forced until we can fine tune it
 	}
 	if aplKeys == nil {
-		cache2go.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
 	}
+	shgKeys = invalidatePartialCache(utils.SHARED_GROUP_PREFIX, shgKeys, tx)
 	if shgKeys == nil {
-		cache2go.RemPrefixKey(utils.SHARED_GROUP_PREFIX) // Forced until we can fine tune it
+		tx.RemPrefixKey(utils.SHARED_GROUP_PREFIX) // Forced until we can fine tune it
 	}
-	for k, _ := range ms.dict {
-		if strings.HasPrefix(k, utils.DESTINATION_PREFIX) {
-			if _, err := ms.GetDestination(k[len(utils.DESTINATION_PREFIX):]); err != nil {
-				cache2go.RollbackTransaction()
+	for _, k := range ms.keysForPrefix("") {
+		if strings.HasPrefix(k, utils.DESTINATION_PREFIX) && !cache2go.IsPartial(utils.DESTINATION_PREFIX) {
+			if _, err := ms.getDestination(k[len(utils.DESTINATION_PREFIX):], tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
-		if strings.HasPrefix(k, utils.RATING_PLAN_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetRatingPlan(k[len(utils.RATING_PLAN_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+		if strings.HasPrefix(k, utils.RATING_PLAN_PREFIX) && !cache2go.IsPartial(utils.RATING_PLAN_PREFIX) {
+			tx.RemKey(k)
+			if _, err := ms.getRatingPlan(k[len(utils.RATING_PLAN_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
-		if strings.HasPrefix(k, utils.RATING_PROFILE_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetRatingProfile(k[len(utils.RATING_PROFILE_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+		if strings.HasPrefix(k, utils.RATING_PROFILE_PREFIX) && !cache2go.IsPartial(utils.RATING_PROFILE_PREFIX) {
+			tx.RemKey(k)
+			if _, err := ms.getRatingProfile(k[len(utils.RATING_PROFILE_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
-		if strings.HasPrefix(k, utils.LCR_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetLCR(k[len(utils.LCR_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+		if strings.HasPrefix(k, utils.LCR_PREFIX) && !cache2go.IsPartial(utils.LCR_PREFIX) {
+			tx.RemKey(k)
+			if _, err := ms.getLCR(k[len(utils.LCR_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
-		if strings.HasPrefix(k, utils.DERIVEDCHARGERS_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetDerivedChargers(k[len(utils.DERIVEDCHARGERS_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+		if strings.HasPrefix(k, utils.DERIVEDCHARGERS_PREFIX) && !cache2go.IsPartial(utils.DERIVEDCHARGERS_PREFIX) {
+			tx.RemKey(k)
+			if _, err := ms.getDerivedChargers(k[len(utils.DERIVEDCHARGERS_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
-		if strings.HasPrefix(k, utils.ACTION_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetActions(k[len(utils.ACTION_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+		if strings.HasPrefix(k, utils.ACTION_PREFIX) && !cache2go.IsPartial(utils.ACTION_PREFIX) {
+			tx.RemKey(k)
+			if _, err := ms.getActions(k[len(utils.ACTION_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
 		if strings.HasPrefix(k, utils.ACTION_PLAN_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetActionPlans(k[len(utils.ACTION_PLAN_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+			tx.RemKey(k)
+			if _, err := ms.getActionPlans(k[len(utils.ACTION_PLAN_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
-		if strings.HasPrefix(k, utils.SHARED_GROUP_PREFIX) {
-			cache2go.RemKey(k)
-			if _, err := ms.GetSharedGroup(k[len(utils.SHARED_GROUP_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+		if strings.HasPrefix(k, utils.SHARED_GROUP_PREFIX) && !cache2go.IsPartial(utils.SHARED_GROUP_PREFIX) {
+			tx.RemKey(k)
+			if _, err := ms.getSharedGroup(k[len(utils.SHARED_GROUP_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
 	}
-	cache2go.CommitTransaction()
+	tx.Commit()
 	return nil
 }
 
@@ -226,27 +359,27 @@ func (ms *MapStorage) CacheAccountingPrefixValues(prefixes map[string][]string)
 }
 
 func (ms *MapStorage) cacheAccounting(alsKeys []string) error {
-	cache2go.BeginTransaction()
+	tx := cache2go.Begin()
 	if alsKeys == nil {
-		cache2go.RemPrefixKey(utils.ALIASES_PREFIX) // Forced until we can fine tune it
+		tx.RemPrefixKey(utils.ALIASES_PREFIX) // Forced until we can fine tune it
 	}
-	for k, _ := range ms.dict {
+	for _, k := range ms.keysForPrefix("") {
 		if strings.HasPrefix(k, utils.ALIASES_PREFIX) {
 			// check if it already exists
 			// to remove reverse cache keys
 			if avs, err := cache2go.Get(k); err == nil && avs != nil {
 				al := &Alias{Values: avs.(AliasValues)}
 				al.SetId(k[len(utils.ALIASES_PREFIX):])
-				al.RemoveReverseCache()
+				al.RemoveReverseCache(tx)
 			}
-			cache2go.RemKey(k)
-			if _, err := ms.GetAlias(k[len(utils.ALIASES_PREFIX):], true); err != nil {
-				cache2go.RollbackTransaction()
+			tx.RemKey(k)
+			if _, err := ms.getAlias(k[len(utils.ALIASES_PREFIX):], true, tx); err != nil {
+				tx.Rollback()
 				return err
 			}
 		}
 	}
-	cache2go.CommitTransaction()
+	tx.Commit()
 	return nil
 }
 
@@ -254,22 +387,26 @@ func (ms *MapStorage) cacheAccounting(alsKeys []string) error {
 func (ms *MapStorage) HasData(categ, subject string) (bool, error) {
 	switch categ {
 	case utils.DESTINATION_PREFIX, utils.RATING_PLAN_PREFIX, utils.RATING_PROFILE_PREFIX, utils.ACTION_PREFIX, utils.ACTION_PLAN_PREFIX, utils.ACCOUNT_PREFIX, utils.DERIVEDCHARGERS_PREFIX:
-		_, exists := ms.dict[categ+subject]
+		_, exists := ms.get(categ + subject)
 		return exists, nil
 	}
 	return false, errors.New("Unsupported HasData category")
 }
 
 func (ms *MapStorage) GetRatingPlan(key string, skipCache bool) (rp *RatingPlan, err error) {
+	return ms.getRatingPlan(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getRatingPlan(key string, skipCache bool, tx *cache2go.Transaction) (rp *RatingPlan, err error) {
 	key = utils.RATING_PLAN_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*RatingPlan), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.RATING_PLAN_PREFIX) {
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		b := bytes.NewBuffer(values)
 		r, err := zlib.NewReader(b)
 		if err != nil {
@@ -282,7 +419,7 @@ func (ms *MapStorage) GetRatingPlan(key string, skipCache bool) (rp *RatingPlan,
 		r.Close()
 		rp = new(RatingPlan)
 		err = ms.ms.Unmarshal(out, rp)
-		cache2go.Cache(key, rp)
+		tx.Cache(key, rp)
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -295,7 +432,9 @@ func (ms *MapStorage) SetRatingPlan(rp *RatingPlan) (err error) {
 	w := zlib.NewWriter(&b)
 	w.Write(result)
 	w.Close()
-	ms.dict[utils.RATING_PLAN_PREFIX+rp.Id] = b.Bytes()
+	if err := ms.set(utils.RATING_PLAN_PREFIX+rp.Id, b.Bytes()); err != nil {
+		return err
+	}
 	response := 0
 	if historyScribe != nil {
 		go historyScribe.Record(rp.GetHistoryRecord(), &response)
@@ -304,19 +443,23 @@ func (ms *MapStorage) SetRatingPlan(rp *RatingPlan) (err error) {
 }
 
 func (ms *MapStorage) GetRatingProfile(key string, skipCache bool) (rpf *RatingProfile, err error) {
+	return ms.getRatingProfile(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getRatingProfile(key string, skipCache bool, tx *cache2go.Transaction) (rpf *RatingProfile, err error) {
 	key = utils.RATING_PROFILE_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*RatingProfile), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.RATING_PROFILE_PREFIX) {
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		rpf = new(RatingProfile)
 
 		err = ms.ms.Unmarshal(values, rpf)
-		cache2go.Cache(key, rpf)
+		tx.Cache(key, rpf)
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -325,7 +468,9 @@ func (ms *MapStorage) GetRatingProfile(key string, skipCache bool) (rpf *RatingP
 
 func (ms *MapStorage) SetRatingProfile(rpf *RatingProfile) (err error) {
 	result, err := ms.ms.Marshal(rpf)
-	ms.dict[utils.RATING_PROFILE_PREFIX+rpf.Id] = result
+	if err := ms.set(utils.RATING_PROFILE_PREFIX+rpf.Id, result); err != nil {
+		return err
+	}
 	response := 0
 	if historyScribe != nil {
 		go historyScribe.Record(rpf.GetHistoryRecord(false), &response)
@@ -334,9 +479,11 @@ func (ms *MapStorage) SetRatingProfile(rpf *RatingProfile) (err error) {
 }
 
 func (ms *MapStorage) RemoveRatingProfile(key string) (err error) {
-	for k := range ms.dict {
+	for _, k := range ms.keysForPrefix("") {
 		if strings.HasPrefix(k, key) {
-			delete(ms.dict, key)
+			if err := ms.del(key); err != nil {
+				return err
+			}
 			cache2go.RemKey(k)
 			response := 0
 			rpf := &RatingProfile{Id: key}
@@ -349,17 +496,21 @@ func (ms *MapStorage) RemoveRatingProfile(key string) (err error) {
 }
 
 func (ms *MapStorage) GetLCR(key string, skipCache bool) (lcr *LCR, err error) {
+	return ms.getLCR(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getLCR(key string, skipCache bool, tx *cache2go.Transaction) (lcr *LCR, err error) {
 	key = utils.LCR_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*LCR), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.LCR_PREFIX) {
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		err = ms.ms.Unmarshal(values, &lcr)
-		cache2go.Cache(key, lcr)
+		tx.Cache(key, lcr)
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -368,13 +519,27 @@ func (ms *MapStorage) GetLCR(key string, skipCache bool) (lcr *LCR, err error) {
 
 func (ms *MapStorage) SetLCR(lcr *LCR) (err error) {
 	result, err := ms.ms.Marshal(lcr)
-	ms.dict[utils.LCR_PREFIX+lcr.GetId()] = result
-	return
+	return ms.set(utils.LCR_PREFIX+lcr.GetId(), result)
 }
 
 func (ms *MapStorage) GetDestination(key string) (dest *Destination, err error) {
+	return ms.getDestination(key, nil)
+}
+
+func (ms *MapStorage) getDestination(key string, tx *cache2go.Transaction) (dest *Destination, err error) {
+	if dest, err = ms.readDestination(key); dest != nil {
+		// create optimized structure
+		for _, p := range dest.Prefixes {
+			tx.Push(utils.DESTINATION_PREFIX+p, dest.Id)
+		}
+	}
+	return
+}
+
+// Destination as stored, its prefixes not cached
+func (ms *MapStorage) readDestination(key string) (dest *Destination, err error) {
 	key = utils.DESTINATION_PREFIX + key
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		b := bytes.NewBuffer(values)
 		r, err := zlib.NewReader(b)
 		if err != nil {
@@ -387,10 +552,6 @@ func (ms *MapStorage) GetDestination(key string) (dest *Destination, err error)
 		r.Close()
 		dest = new(Destination)
 		err = ms.ms.Unmarshal(out, dest)
-		// create optimized structure
-		for _, p := range dest.Prefixes {
-			cache2go.Push(utils.DESTINATION_PREFIX+p, dest.Id)
-		}
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -399,11 +560,17 @@ func (ms *MapStorage) GetDestination(key string) (dest *Destination, err error)
 
 func (ms *MapStorage) SetDestination(dest *Destination) (err error) {
 	result, err := ms.ms.Marshal(dest)
+	oldDest, _ := ms.readDestination(dest.Id)
 	var b bytes.Buffer
 	w := zlib.NewWriter(&b)
 	w.Write(result)
 	w.Close()
-	ms.dict[utils.DESTINATION_PREFIX+dest.Id] = b.Bytes()
+	if err := ms.set(utils.DESTINATION_PREFIX+dest.Id, b.Bytes()); err != nil {
+		return err
+	}
+	if err := ms.setReverseDestination(oldDest, dest); err != nil {
+		return err
+	}
 	response := 0
 	if historyScribe != nil {
 		go historyScribe.Record(dest.GetHistoryRecord(), &response)
@@ -411,18 +578,91 @@ func (ms *MapStorage) SetDestination(dest *Destination) (err error) {
 	return
 }
 
+// Keeps the ids of the destinations containing each prefix, partially cached destinations are loaded out of them
+func (ms *MapStorage) setReverseDestination(oldDest, dest *Destination) error {
+	changed := make(map[string]bool) // prefix, true if dest.Id is added to it
+	if oldDest != nil {
+		for _, p := range oldDest.Prefixes {
+			changed[p] = false
+		}
+	}
+	for _, p := range dest.Prefixes {
+		changed[p] = true
+	}
+	for p, added := range changed {
+		dIDs, err := ms.GetReverseDestination(p)
+		if err != nil && err != utils.ErrNotFound {
+			return err
+		}
+		var newIDs []string
+		for _, dID := range dIDs {
+			if dID != dest.Id {
+				newIDs = append(newIDs, dID)
+			}
+		}
+		if added {
+			newIDs = append(newIDs, dest.Id)
+		}
+		key := utils.REVERSE_DESTINATION_PREFIX + p
+		if len(newIDs) == 0 {
+			if err := ms.del(key); err != nil {
+				return err
+			}
+			continue
+		}
+		result, err := ms.ms.Marshal(newIDs)
+		if err != nil {
+			return err
+		}
+		if err := ms.set(key, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Indexes the destinations stored before the reverse destinations were kept, partially cached destinations are missed otherwise
+func (ms *MapStorage) indexReverseDestinations() error {
+	if len(ms.keysForPrefix(utils.REVERSE_DESTINATION_PREFIX)) != 0 {
+		return nil
+	}
+	for _, key := range ms.keysForPrefix(utils.DESTINATION_PREFIX) {
+		dest, err := ms.readDestination(key[len(utils.DESTINATION_PREFIX):])
+		if err != nil {
+			return err
+		}
+		if err := ms.setReverseDestination(nil, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *MapStorage) GetReverseDestination(prefix string) (dIDs []string, err error) {
+	values, ok := ms.get(utils.REVERSE_DESTINATION_PREFIX + prefix)
+	if !ok {
+		return nil, utils.ErrNotFound
+	}
+	err = ms.ms.Unmarshal(values, &dIDs)
+	return
+}
+
 func (ms *MapStorage) GetActions(key string, skipCache bool) (as Actions, err error) {
+	return ms.getActions(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getActions(key string, skipCache bool, tx *cache2go.Transaction) (as Actions, err error) {
 	key = utils.ACTION_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(Actions), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.ACTION_PREFIX) {
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		err = ms.ms.Unmarshal(values, &as)
-		cache2go.Cache(key, as)
+		tx.Cache(key, as)
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -431,23 +671,26 @@ func (ms *MapStorage) GetActions(key string, skipCache bool) (as Actions, err er
 
 func (ms *MapStorage) SetActions(key string, as Actions) (err error) {
 	result, err := ms.ms.Marshal(&as)
-	ms.dict[utils.ACTION_PREFIX+key] = result
-	return
+	return ms.set(utils.ACTION_PREFIX+key, result)
 }
 
 func (ms *MapStorage) GetSharedGroup(key string, skipCache bool) (sg *SharedGroup, err error) {
+	return ms.getSharedGroup(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getSharedGroup(key string, skipCache bool, tx *cache2go.Transaction) (sg *SharedGroup, err error) {
 	key = utils.SHARED_GROUP_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*SharedGroup), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.SHARED_GROUP_PREFIX) {
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		err = ms.ms.Unmarshal(values, &sg)
 		if err == nil {
-			cache2go.Cache(key, sg)
+			tx.Cache(key, sg)
 		}
 	} else {
 		return nil, utils.ErrNotFound
@@ -457,12 +700,11 @@ func (ms *MapStorage) GetSharedGroup(key string, skipCache bool) (sg *SharedGrou
 
 func (ms *MapStorage) SetSharedGroup(sg *SharedGroup) (err error) {
 	result, err := ms.ms.Marshal(sg)
-	ms.dict[utils.SHARED_GROUP_PREFIX+sg.Id] = result
-	return
+	return ms.set(utils.SHARED_GROUP_PREFIX+sg.Id, result)
 }
 
 func (ms *MapStorage) GetAccount(key string) (ub *Account, err error) {
-	if values, ok := ms.dict[utils.ACCOUNT_PREFIX+key]; ok {
+	if values, ok := ms.get(utils.ACCOUNT_PREFIX + key); ok {
 		ub = &Account{Id: key}
 		err = ms.ms.Unmarshal(values, ub)
 	} else {
@@ -485,17 +727,15 @@ func (ms *MapStorage) SetAccount(ub *Account) (err error) {
 		}
 	}
 	result, err := ms.ms.Marshal(ub)
-	ms.dict[utils.ACCOUNT_PREFIX+ub.Id] = result
-	return
+	return ms.set(utils.ACCOUNT_PREFIX+ub.Id, result)
 }
 
 func (ms *MapStorage) RemoveAccount(key string) (err error) {
-	delete(ms.dict, utils.ACCOUNT_PREFIX+key)
-	return
+	return ms.del(utils.ACCOUNT_PREFIX + key)
 }
 
 func (ms *MapStorage) GetCdrStatsQueue(key string) (sq *StatsQueue, err error) {
-	if values, ok := ms.dict[utils.CDR_STATS_QUEUE_PREFIX+key]; ok {
+	if values, ok := ms.get(utils.CDR_STATS_QUEUE_PREFIX + key); ok {
 		sq = &StatsQueue{}
 		err = ms.ms.Unmarshal(values, sq)
 	} else {
@@ -506,13 +746,12 @@ func (ms *MapStorage) GetCdrStatsQueue(key string) (sq *StatsQueue, err error) {
 
 func (ms *MapStorage) SetCdrStatsQueue(sq *StatsQueue) (err error) {
 	result, err := ms.ms.Marshal(sq)
-	ms.dict[utils.CDR_STATS_QUEUE_PREFIX+sq.GetId()] = result
-	return
+	return ms.set(utils.CDR_STATS_QUEUE_PREFIX+sq.GetId(), result)
 }
 
 func (ms *MapStorage) GetSubscribers() (result map[string]*SubscriberData, err error) {
 	result = make(map[string]*SubscriberData)
-	for key, value := range ms.dict {
+	for key, value := range ms.items() {
 		if strings.HasPrefix(key, utils.PUBSUB_SUBSCRIBERS_PREFIX) {
 			sub := &SubscriberData{}
 			if err = ms.ms.Unmarshal(value, sub); err == nil {
@@ -524,13 +763,11 @@ func (ms *MapStorage) GetSubscribers() (result map[string]*SubscriberData, err e
 }
 func (ms *MapStorage) SetSubscriber(key string, sub *SubscriberData) (err error) {
 	result, err := ms.ms.Marshal(sub)
-	ms.dict[utils.PUBSUB_SUBSCRIBERS_PREFIX+key] = result
-	return
+	return ms.set(utils.PUBSUB_SUBSCRIBERS_PREFIX+key, result)
 }
 
 func (ms *MapStorage) RemoveSubscriber(key string) (err error) {
-	delete(ms.dict, utils.PUBSUB_SUBSCRIBERS_PREFIX+key)
-	return
+	return ms.del(utils.PUBSUB_SUBSCRIBERS_PREFIX + key)
 }
 
 func (ms *MapStorage) SetUser(up *UserProfile) error {
@@ -538,12 +775,11 @@ func (ms *MapStorage) SetUser(up *UserProfile) error {
 	if err != nil {
 		return err
 	}
-	ms.dict[utils.USERS_PREFIX+up.GetId()] = result
-	return nil
+	return ms.set(utils.USERS_PREFIX+up.GetId(), result)
 }
 func (ms *MapStorage) GetUser(key string) (up *UserProfile, err error) {
 	up = &UserProfile{}
-	if values, ok := ms.dict[utils.USERS_PREFIX+key]; ok {
+	if values, ok := ms.get(utils.USERS_PREFIX + key); ok {
 		err = ms.ms.Unmarshal(values, &up)
 	} else {
 		return nil, utils.ErrNotFound
@@ -552,7 +788,7 @@ func (ms *MapStorage) GetUser(key string) (up *UserProfile, err error) {
 }
 
 func (ms *MapStorage) GetUsers() (result []*UserProfile, err error) {
-	for key, value := range ms.dict {
+	for key, value := range ms.items() {
 		if strings.HasPrefix(key, utils.USERS_PREFIX) {
 			up := &UserProfile{}
 			if err = ms.ms.Unmarshal(value, up); err == nil {
@@ -564,8 +800,7 @@ func (ms *MapStorage) GetUsers() (result []*UserProfile, err error) {
 }
 
 func (ms *MapStorage) RemoveUser(key string) error {
-	delete(ms.dict, utils.USERS_PREFIX+key)
-	return nil
+	return ms.del(utils.USERS_PREFIX + key)
 }
 
 func (ms *MapStorage) SetAlias(al *Alias) error {
@@ -573,11 +808,14 @@ func (ms *MapStorage) SetAlias(al *Alias) error {
 	if err != nil {
 		return err
 	}
-	ms.dict[utils.ALIASES_PREFIX+al.GetId()] = result
-	return nil
+	return ms.set(utils.ALIASES_PREFIX+al.GetId(), result)
 }
 
 func (ms *MapStorage) GetAlias(key string, skipCache bool) (al *Alias, err error) {
+	return ms.getAlias(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getAlias(key string, skipCache bool, tx *cache2go.Transaction) (al *Alias, err error) {
 	key = utils.ALIASES_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
@@ -588,13 +826,13 @@ func (ms *MapStorage) GetAlias(key string, skipCache bool) (al *Alias, err error
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		al = &Alias{Values: make(AliasValues, 0)}
 		al.SetId(key[len(utils.ALIASES_PREFIX):])
 		err = ms.ms.Unmarshal(values, &al.Values)
 		if err == nil {
-			cache2go.Cache(key, al.Values)
-			al.SetReverseCache()
+			tx.Cache(key, al.Values)
+			al.SetReverseCache(tx)
 		}
 	} else {
 		return nil, utils.ErrNotFound
@@ -607,17 +845,23 @@ func (ms *MapStorage) RemoveAlias(key string) error {
 	al.SetId(key)
 	key = utils.ALIASES_PREFIX + key
 	aliasValues := make(AliasValues, 0)
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		ms.ms.Unmarshal(values, &aliasValues)
 	}
 	al.Values = aliasValues
-	delete(ms.dict, key)
-	al.RemoveReverseCache()
+	if err := ms.del(key); err != nil {
+		return err
+	}
+	al.RemoveReverseCache(nil)
 	cache2go.RemKey(key)
 	return nil
 }
 
 func (ms *MapStorage) GetLoadHistory(limitItems int, skipCache bool) ([]*LoadInstance, error) {
+	return ms.getLoadHistory(limitItems, skipCache, nil)
+}
+
+func (ms *MapStorage) getLoadHistory(limitItems int, skipCache bool, tx *cache2go.Transaction) ([]*LoadInstance, error) {
 	return nil, nil
 }
 
@@ -626,7 +870,7 @@ func (ms *MapStorage) AddLoadHistory(*LoadInstance, int) error {
 }
 
 func (ms *MapStorage) GetActionTriggers(key string) (atrs ActionTriggers, err error) {
-	if values, ok := ms.dict[utils.ACTION_TRIGGER_PREFIX+key]; ok {
+	if values, ok := ms.get(utils.ACTION_TRIGGER_PREFIX + key); ok {
 		err = ms.ms.Unmarshal(values, &atrs)
 	} else {
 		return nil, utils.ErrNotFound
@@ -637,15 +881,17 @@ func (ms *MapStorage) GetActionTriggers(key string) (atrs ActionTriggers, err er
 func (ms *MapStorage) SetActionTriggers(key string, atrs ActionTriggers) (err error) {
 	if len(atrs) == 0 {
 		// delete the key
-		delete(ms.dict, utils.ACTION_TRIGGER_PREFIX+key)
-		return
+		return ms.del(utils.ACTION_TRIGGER_PREFIX + key)
 	}
 	result, err := ms.ms.Marshal(&atrs)
-	ms.dict[utils.ACTION_TRIGGER_PREFIX+key] = result
-	return
+	return ms.set(utils.ACTION_TRIGGER_PREFIX+key, result)
 }
 
 func (ms *MapStorage) GetActionPlans(key string, skipCache bool) (ats ActionPlans, err error) {
+	return ms.getActionPlans(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getActionPlans(key string, skipCache bool, tx *cache2go.Transaction) (ats ActionPlans, err error) {
 	key = utils.ACTION_PLAN_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
@@ -654,9 +900,9 @@ func (ms *MapStorage) GetActionPlans(key string, skipCache bool) (ats ActionPlan
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		err = ms.ms.Unmarshal(values, &ats)
-		cache2go.Cache(key, ats)
+		tx.Cache(key, ats)
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -666,13 +912,14 @@ func (ms *MapStorage) GetActionPlans(key string, skipCache bool) (ats ActionPlan
 func (ms *MapStorage) SetActionPlans(key string, ats ActionPlans) (err error) {
 	if len(ats) == 0 {
 		// delete the key
-		delete(ms.dict, utils.ACTION_PLAN_PREFIX+key)
+		if err := ms.del(utils.ACTION_PLAN_PREFIX + key); err != nil {
+			return err
+		}
 		cache2go.RemKey(utils.ACTION_PLAN_PREFIX + key)
 		return
 	}
 	result, err := ms.ms.Marshal(&ats)
-	ms.dict[utils.ACTION_PLAN_PREFIX+key] = result
-	return
+	return ms.set(utils.ACTION_PLAN_PREFIX+key, result)
 }
 
 func (ms *MapStorage) GetAllActionPlans() (ats map[string]ActionPlans, err error) {
@@ -691,17 +938,21 @@ func (ms *MapStorage) GetAllActionPlans() (ats map[string]ActionPlans, err error
 }
 
 func (ms *MapStorage) GetDerivedChargers(key string, skipCache bool) (dcs *utils.DerivedChargers, err error) {
+	return ms.getDerivedChargers(key, skipCache, nil)
+}
+
+func (ms *MapStorage) getDerivedChargers(key string, skipCache bool, tx *cache2go.Transaction) (dcs *utils.DerivedChargers, err error) {
 	key = utils.DERIVEDCHARGERS_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*utils.DerivedChargers), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.DERIVEDCHARGERS_PREFIX) {
 			return nil, err
 		}
 	}
-	if values, ok := ms.dict[key]; ok {
+	if values, ok := ms.get(key); ok {
 		err = ms.ms.Unmarshal(values, &dcs)
-		cache2go.Cache(key, dcs)
+		tx.Cache(key, dcs)
 	} else {
 		return nil, utils.ErrNotFound
 	}
@@ -710,23 +961,29 @@ func (ms *MapStorage) GetDerivedChargers(key string, skipCache bool) (dcs *utils
 
 func (ms *MapStorage) SetDerivedChargers(key string, dcs *utils.DerivedChargers) error {
 	if dcs == nil || len(dcs.Chargers) == 0 {
-		delete(ms.dict, utils.DERIVEDCHARGERS_PREFIX+key)
+		if err := ms.del(utils.DERIVEDCHARGERS_PREFIX + key); err != nil {
+			return err
+		}
 		cache2go.RemKey(utils.DERIVEDCHARGERS_PREFIX + key)
 		return nil
 	}
 	result, err := ms.ms.Marshal(dcs)
-	ms.dict[utils.DERIVEDCHARGERS_PREFIX+key] = result
+	if err := ms.set(utils.DERIVEDCHARGERS_PREFIX+key, result); err != nil {
+		return err
+	}
 	return err
 }
 
 func (ms *MapStorage) SetCdrStats(cs *CdrStats) error {
 	result, err := ms.ms.Marshal(cs)
-	ms.dict[utils.CDR_STATS_PREFIX+cs.Id] = result
+	if err := ms.set(utils.CDR_STATS_PREFIX+cs.Id, result); err != nil {
+		return err
+	}
 	return err
 }
 
 func (ms *MapStorage) GetCdrStats(key string) (cs *CdrStats, err error) {
-	if values, ok := ms.dict[utils.CDR_STATS_PREFIX+key]; ok {
+	if values, ok := ms.get(utils.CDR_STATS_PREFIX + key); ok {
 		err = ms.ms.Unmarshal(values, &cs)
 	} else {
 		return nil, utils.ErrNotFound
@@ -735,7 +992,7 @@ func (ms *MapStorage) GetCdrStats(key string) (cs *CdrStats, err error) {
 }
 
 func (ms *MapStorage) GetAllCdrStats() (css []*CdrStats, err error) {
-	for key, value := range ms.dict {
+	for key, value := range ms.items() {
 		if !strings.HasPrefix(key, utils.CDR_STATS_PREFIX) {
 			continue
 		}
@@ -748,12 +1005,14 @@ func (ms *MapStorage) GetAllCdrStats() (css []*CdrStats, err error) {
 
 func (ms *MapStorage) LogCallCost(cgrid, source, runid string, cc *CallCost) error {
 	result, err := ms.ms.Marshal(cc)
-	ms.dict[utils.LOG_CALL_COST_PREFIX+source+runid+"_"+cgrid] = result
+	if err := ms.set(utils.LOG_CALL_COST_PREFIX+source+runid+"_"+cgrid, result); err != nil {
+		return err
+	}
 	return err
 }
 
 func (ms *MapStorage) GetCallCostLog(cgrid, source, runid string) (cc *CallCost, err error) {
-	if values, ok := ms.dict[utils.LOG_CALL_COST_PREFIX+source+runid+"_"+cgrid]; ok {
+	if values, ok := ms.get(utils.LOG_CALL_COST_PREFIX + source + runid + "_" + cgrid); ok {
 		err = ms.ms.Unmarshal(values, &cc)
 	} else {
 		return nil, utils.ErrNotFound
@@ -770,8 +1029,7 @@ func (ms *MapStorage) LogActionTrigger(ubId, source string, at *ActionTrigger, a
 	if err != nil {
 		return
 	}
-	ms.dict[utils.LOG_ACTION_TRIGGER_PREFIX+source+"_"+time.Now().Format(time.RFC3339Nano)] = []byte(fmt.Sprintf("%s*%s*%s", ubId, string(mat), string(mas)))
-	return
+	return ms.set(utils.LOG_ACTION_TRIGGER_PREFIX+source+"_"+time.Now().Format(time.RFC3339Nano), []byte(fmt.Sprintf("%s*%s*%s", ubId, string(mat), string(mas))))
 }
 
 func (ms *MapStorage) LogActionPlan(source string, at *ActionPlan, as Actions) (err error) {
@@ -783,6 +1041,114 @@ func (ms *MapStorage) LogActionPlan(source string, at *ActionPlan, as Actions) (
 	if err != nil {
 		return
 	}
-	ms.dict[utils.LOG_ACTION_TIMMING_PREFIX+source+"_"+time.Now().Format(time.RFC3339Nano)] = []byte(fmt.Sprintf("%s*%s", string(mat), string(mas)))
-	return
+	return ms.set(utils.LOG_ACTION_TIMMING_PREFIX+source+"_"+time.Now().Format(time.RFC3339Nano), []byte(fmt.Sprintf("%s*%s", string(mat), string(mas))))
+}
+
+func (ms *MapStorage) AddCacheChange(cc *CacheChange) (int64, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	version, err := ms.cacheVersion()
+	if err != nil {
+		return 0, err
+	}
+	version++
+	cc.Version = version
+	result, err := ms.ms.Marshal(cc)
+	if err != nil {
+		return 0, err
+	}
+	versionKey := utils.CACHE_CHANGE_PREFIX + strconv.FormatInt(version, 10)
+	if err := ms.logChange(MAP_WAL_SET, versionKey, result); err != nil {
+		return 0, err
+	}
+	ms.dict[versionKey] = result
+	versionValue := []byte(strconv.FormatInt(version, 10))
+	if err := ms.logChange(MAP_WAL_SET, utils.CACHE_VERSION_KEY, versionValue); err != nil { // Entry left behind is overwritten by the next change
+		return 0, err
+	}
+	ms.dict[utils.CACHE_VERSION_KEY] = versionValue
+	trimmedKey := utils.CACHE_CHANGE_PREFIX + strconv.FormatInt(version-CACHE_CHANGES_SIZE, 10)
+	if _, hasIt := ms.dict[trimmedKey]; hasIt {
+		if err := ms.logChange(MAP_WAL_DEL, trimmedKey, nil); err != nil {
+			return 0, err
+		}
+		delete(ms.dict, trimmedKey)
+	}
+	return version, nil
+}
+
+// Changes after sinceVersion, ordered by version
+func (ms *MapStorage) GetCacheChanges(sinceVersion int64) ([]*CacheChange, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+	lastVersion, err := ms.cacheVersion()
+	if err != nil {
+		return nil, err
+	}
+	if lastVersion-sinceVersion > CACHE_CHANGES_SIZE {
+		return nil, ErrCacheChangesTrimmed
+	}
+	var changes []*CacheChange
+	for version := sinceVersion + 1; version <= lastVersion; version++ {
+		values, hasIt := ms.dict[utils.CACHE_CHANGE_PREFIX+strconv.FormatInt(version, 10)]
+		if !hasIt {
+			continue
+		}
+		cc := new(CacheChange)
+		if err := ms.ms.Unmarshal(values, cc); err != nil {
+			return nil, err
+		}
+		changes = append(changes, cc)
+	}
+	return changes, nil
+}
+
+func (ms *MapStorage) GetCacheVersion() (int64, error) {
+	ms.RLock()
+	defer ms.RUnlock()
+	return ms.cacheVersion()
+}
+
+// Called with the lock held
+func (ms *MapStorage) cacheVersion() (int64, error) {
+	values, hasIt := ms.dict[utils.CACHE_VERSION_KEY]
+	if !hasIt {
+		return 0, nil
+	}
+	return strconv.ParseInt(string(values), 10, 64)
+}
+
+func (ms *MapStorage) SetCacheNodeVersion(nodeId string, nv *CacheNodeVersion) error {
+	result, err := ms.ms.Marshal(nv)
+	if err != nil {
+		return err
+	}
+	return ms.set(utils.CACHE_NODE_PREFIX+nodeId, result)
+}
+
+func (ms *MapStorage) GetCacheNodeVersions() (map[string]*CacheNodeVersion, error) {
+	nodeVersions := make(map[string]*CacheNodeVersion)
+	for _, key := range ms.keysForPrefix(utils.CACHE_NODE_PREFIX) {
+		values, hasIt := ms.get(key)
+		if !hasIt {
+			continue
+		}
+		nv := new(CacheNodeVersion)
+		if err := ms.ms.Unmarshal(values, nv); err != nil {
+			return nil, err
+		}
+		if time.Since(nv.LastSync) > CACHE_NODE_TTL { // No expiring keys here, dropped once read
+			if err := ms.del(key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		nodeVersions[key[len(utils.CACHE_NODE_PREFIX):]] = nv
+	}
+	return nodeVersions, nil
+}
+
+// Storage local to the process, nothing to subscribe to
+func (ms *MapStorage) SubscribeCacheChanges(versionChan chan<- int64, stopChan <-chan struct{}) error {
+	return utils.ErrNotImplemented
 }
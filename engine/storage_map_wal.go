@@ -0,0 +1,302 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+const (
+	MAP_WAL_SUFFIX        = ".wal"
+	MAP_SNAPSHOT_SUFFIX   = ".snapshot"
+	MAP_SNAPSHOT_INTERVAL = time.Minute // Snapshot the storage in background this often if it was changed
+	MAP_WAL_SET           = byte(1)
+	MAP_WAL_DEL           = byte(2)
+)
+
+var errMapWalCorrupted = errors.New("corrupted entry")
+
+// Opens a MapStorage persisted in dirPath, its content is restored out of the last snapshot and the write-ahead logs following it.
+// dirPath needs to be absolute so the database name of the other storages is not taken for a folder, empty for memory only.
+func NewMapStoragePersistent(dirPath, name, marshaler string) (*MapStorage, error) {
+	var mrshler Marshaler
+	if marshaler == utils.MSGPACK {
+		mrshler = NewCodecMsgpackMarshaler()
+	} else if marshaler == utils.JSON {
+		mrshler = new(JSONBufMarshaler)
+	} else {
+		return nil, fmt.Errorf("Unsupported marshaler: %v", marshaler)
+	}
+	ms := &MapStorage{dict: make(map[string][]byte), ms: mrshler}
+	if len(dirPath) == 0 { // Memory only
+		return ms, nil
+	}
+	if !path.IsAbs(dirPath) {
+		return nil, fmt.Errorf("Persistence folder should be an absolute path, received: %s", dirPath)
+	}
+	ms.wal = &mapStorageWal{dirPath: dirPath, name: name}
+	if err := ms.wal.restore(ms.dict); err != nil {
+		return nil, err
+	}
+	if err := ms.wal.rotate(); err != nil {
+		return nil, err
+	}
+	if err := ms.wal.snapshot(ms.dict, ms.wal.rotated); err != nil { // Start with a clean log
+		return nil, err
+	}
+	ms.stopSnapshots = make(chan struct{})
+	go ms.snapshotLoop()
+	return ms, nil
+}
+
+// Write-ahead log with the changes done on a MapStorage since its last snapshot.
+// Entries are written without syncing so they survive process crashes, snapshots are synced to disk.
+// Before snapshotting, the log is rotated so the content can be written without holding the storage lock.
+type mapStorageWal struct {
+	dirPath string
+	name    string
+	file    *os.File
+	entries int // Entries in the log since it was rotated
+	rotated int // Index of the last log rotated
+}
+
+func (self *mapStorageWal) walPath() string {
+	return path.Join(self.dirPath, self.name+MAP_WAL_SUFFIX)
+}
+
+// Path of the log rotated with index, kept until a snapshot covering it is written
+func (self *mapStorageWal) rotatedPath(idx int) string {
+	return self.walPath() + "." + strconv.Itoa(idx)
+}
+
+// Indexes of the rotated logs found on disk, ascending
+func (self *mapStorageWal) rotatedIdxs() ([]int, error) {
+	rotatedPaths, err := filepath.Glob(self.walPath() + ".*")
+	if err != nil {
+		return nil, err
+	}
+	idxs := make([]int, 0, len(rotatedPaths))
+	for _, rotatedPath := range rotatedPaths {
+		if idx, err := strconv.Atoi(strings.TrimPrefix(rotatedPath, self.walPath()+".")); err == nil {
+			idxs = append(idxs, idx)
+		}
+	}
+	sort.Ints(idxs)
+	return idxs, nil
+}
+
+func (self *mapStorageWal) snapshotPath() string {
+	return path.Join(self.dirPath, self.name+MAP_SNAPSHOT_SUFFIX)
+}
+
+// Encodes one entry: payload length, payload checksum and the payload out of operation, key length, key and value
+func encodeMapWalEntry(op byte, key string, value []byte) []byte {
+	payload := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(key)+len(value))
+	payload[0] = op
+	payload = payload[:1+binary.PutUvarint(payload[1:], uint64(len(key)))]
+	payload = append(append(payload, key...), value...)
+	entry := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(entry[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(entry[4:], crc32.ChecksumIEEE(payload))
+	return append(entry, payload...)
+}
+
+// Reads the next entry out of the remaining bytes of the file, io.EOF at the end of the entries, errMapWalCorrupted for torn writes.
+// The payload length is checked against the remaining bytes before allocating, a torn header could announce up to 4GB.
+func decodeMapWalEntry(rdr *bufio.Reader, remaining int64) (op byte, key string, value []byte, entryLen int64, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(rdr, header[:]); err == io.EOF {
+		return
+	} else if err != nil {
+		return 0, "", nil, 0, errMapWalCorrupted
+	}
+	payloadLen := binary.BigEndian.Uint32(header[:4])
+	if entryLen = int64(len(header)) + int64(payloadLen); entryLen > remaining {
+		return 0, "", nil, 0, errMapWalCorrupted
+	}
+	payload := make([]byte, payloadLen)
+	if _, err = io.ReadFull(rdr, payload); err != nil || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) || len(payload) < 2 {
+		return 0, "", nil, 0, errMapWalCorrupted
+	}
+	keyLen, n := binary.Uvarint(payload[1:])
+	if n <= 0 || uint64(len(payload)-1-n) < keyLen {
+		return 0, "", nil, 0, errMapWalCorrupted
+	}
+	keyEnd := 1 + n + int(keyLen)
+	return payload[0], string(payload[1+n : keyEnd]), payload[keyEnd:], entryLen, nil
+}
+
+// Applies the entries in file to dict, returns the number of entries applied
+func applyMapWalFile(filePath string, dict map[string][]byte) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	remaining := fileInfo.Size()
+	rdr := bufio.NewReader(file)
+	for applied := 0; ; applied++ {
+		op, key, value, entryLen, err := decodeMapWalEntry(rdr, remaining)
+		if err == io.EOF {
+			return applied, nil
+		} else if err != nil {
+			return applied, err
+		}
+		remaining -= entryLen
+		switch op {
+		case MAP_WAL_SET:
+			dict[key] = value
+		case MAP_WAL_DEL:
+			delete(dict, key)
+		default:
+			return applied, errMapWalCorrupted
+		}
+	}
+}
+
+// Loads the snapshot and replays the logs on top of it, oldest first. Rotated logs already covered by the snapshot
+// are left behind only if their removal failed, replaying them again before the newer logs gives the same content.
+func (self *mapStorageWal) restore(dict map[string][]byte) error {
+	if _, err := applyMapWalFile(self.snapshotPath(), dict); err != nil {
+		return fmt.Errorf("Snapshot %s, error: %s", self.snapshotPath(), err.Error())
+	}
+	idxs, err := self.rotatedIdxs()
+	if err != nil {
+		return err
+	}
+	logPaths := make([]string, 0, len(idxs)+1)
+	for _, idx := range idxs {
+		logPaths = append(logPaths, self.rotatedPath(idx))
+		self.rotated = idx
+	}
+	for _, logPath := range append(logPaths, self.walPath()) {
+		applied, err := applyMapWalFile(logPath, dict)
+		if err == errMapWalCorrupted { // Last write torn by a crash, the entries before it are still good
+			utils.Logger.Warning(fmt.Sprintf("<MapStorage> Log %s corrupted after %d entries, ignoring the rest", logPath, applied))
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Moves the log aside and starts a new one, the content at this point can then be snapshotted while changes go on. Called with the storage lock held.
+func (self *mapStorageWal) rotate() error {
+	self.close()
+	err := os.Rename(self.walPath(), self.rotatedPath(self.rotated+1))
+	if err == nil {
+		self.rotated += 1
+		self.entries = 0
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if err == nil {
+		flags |= os.O_TRUNC
+	}
+	var errOpen error
+	if self.file, errOpen = os.OpenFile(self.walPath(), flags, 0644); err == nil {
+		err = errOpen
+	}
+	return err
+}
+
+// Writes the content into a new snapshot and removes the logs rotated up to rotated, which the content includes
+func (self *mapStorageWal) snapshot(dict map[string][]byte, rotated int) error {
+	tmpFile, err := ioutil.TempFile(self.dirPath, self.name+MAP_SNAPSHOT_SUFFIX+".")
+	if err != nil {
+		return err
+	}
+	wrtr := bufio.NewWriter(tmpFile)
+	for key, value := range dict {
+		if _, err = wrtr.Write(encodeMapWalEntry(MAP_WAL_SET, key, value)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = wrtr.Flush()
+	}
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), self.snapshotPath())
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	// Snapshot in place, the logs before it are not needed anymore
+	idxs, err := self.rotatedIdxs()
+	if err != nil {
+		return err
+	}
+	for _, idx := range idxs {
+		if idx > rotated {
+			break
+		}
+		if err := os.Remove(self.rotatedPath(idx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (self *mapStorageWal) append(op byte, key string, value []byte) error {
+	if self.file == nil {
+		return errors.New("log closed")
+	}
+	if _, err := self.file.Write(encodeMapWalEntry(op, key, value)); err != nil {
+		return err
+	}
+	self.entries += 1
+	return nil
+}
+
+func (self *mapStorageWal) close() {
+	if self.file != nil {
+		self.file.Close()
+		self.file = nil
+	}
+}
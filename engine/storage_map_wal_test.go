@@ -0,0 +1,178 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+func TestMapStoragePersistent(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "map_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	ms, err := NewMapStoragePersistent(dirPath, "data_db", utils.MSGPACK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, acntId := range []string{"cgrates.org:1001", "cgrates.org:1002"} {
+		if err := ms.SetAccount(&Account{Id: acntId, AllowNegative: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ms.RemoveAccount("cgrates.org:1002"); err != nil {
+		t.Fatal(err)
+	}
+	// Crash without snapshot, last write torn
+	walFile, _ := os.OpenFile(path.Join(dirPath, "data_db"+MAP_WAL_SUFFIX), os.O_WRONLY|os.O_APPEND, 0644)
+	walFile.Write(encodeMapWalEntry(MAP_WAL_SET, utils.ACCOUNT_PREFIX+"cgrates.org:1003", []byte("torn"))[:10])
+	walFile.Close()
+	if ms, err = NewMapStoragePersistent(dirPath, "data_db", utils.MSGPACK); err != nil {
+		t.Fatal(err)
+	}
+	if acnt, err := ms.GetAccount("cgrates.org:1001"); err != nil || !acnt.AllowNegative {
+		t.Errorf("Unexpected account: %+v, error: %v", acnt, err)
+	}
+	for _, acntId := range []string{"cgrates.org:1002", "cgrates.org:1003"} {
+		if _, err := ms.GetAccount(acntId); err != utils.ErrNotFound {
+			t.Errorf("Account: %s, unexpected error: %v", acntId, err)
+		}
+	}
+	// Restored out of the snapshot taken on close
+	if err := ms.SetDestination(&Destination{Id: "GERMANY", Prefixes: []string{"49"}}); err != nil {
+		t.Fatal(err)
+	}
+	ms.Close()
+	if walInfo, err := os.Stat(path.Join(dirPath, "data_db"+MAP_WAL_SUFFIX)); err != nil || walInfo.Size() != 0 {
+		t.Errorf("Log not truncated: %+v, error: %v", walInfo, err)
+	}
+	if ms, err = NewMapStoragePersistent(dirPath, "data_db", utils.MSGPACK); err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Close()
+	if dest, err := ms.GetDestination("GERMANY"); err != nil || len(dest.Prefixes) != 1 || dest.Prefixes[0] != "49" {
+		t.Errorf("Unexpected destination: %+v, error: %v", dest, err)
+	}
+	if keys, _ := ms.GetKeysForPrefix(utils.ACCOUNT_PREFIX); len(keys) != 1 {
+		t.Errorf("Unexpected account keys: %+v", keys)
+	}
+}
+
+func TestMapStorageBackgroundSnapshot(t *testing.T) {
+	if _, err := NewMapStoragePersistent("11", "data_db", utils.MSGPACK); err == nil {
+		t.Error("Relative folder accepted")
+	}
+	dirPath, err := ioutil.TempDir("", "map_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	ms, err := NewMapStoragePersistent(dirPath, "data_db", utils.MSGPACK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.SetAccount(&Account{Id: "cgrates.org:1001"}); err != nil {
+		t.Fatal(err)
+	}
+	// Snapshot failing after the log was rotated, simulated by leaving the rotated log behind
+	if err := ms.snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	ms.Lock()
+	if err := ms.wal.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	ms.Unlock()
+	if err := ms.RemoveAccount("cgrates.org:1001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.SetAccount(&Account{Id: "cgrates.org:1002"}); err != nil {
+		t.Fatal(err)
+	}
+	// Crash, restored out of the snapshot, the rotated log and the current one
+	if ms, err = NewMapStoragePersistent(dirPath, "data_db", utils.MSGPACK); err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Close()
+	if keys, _ := ms.GetKeysForPrefix(utils.ACCOUNT_PREFIX); len(keys) != 1 || keys[0] != utils.ACCOUNT_PREFIX+"cgrates.org:1002" {
+		t.Errorf("Unexpected account keys: %+v", keys)
+	}
+	if rotatedLogs, _ := filepath.Glob(path.Join(dirPath, "data_db"+MAP_WAL_SUFFIX+".*")); len(rotatedLogs) != 0 {
+		t.Errorf("Rotated logs not removed: %+v", rotatedLogs)
+	}
+	// Changes not applied unless logged
+	ms.Lock()
+	ms.wal.close()
+	ms.Unlock()
+	if err := ms.SetAccount(&Account{Id: "cgrates.org:1003"}); err == nil {
+		t.Error("Change not logged accepted")
+	} else if _, err := ms.GetAccount("cgrates.org:1003"); err != utils.ErrNotFound {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestMapWalTornEntryLength(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "map_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	walContent := append(encodeMapWalEntry(MAP_WAL_SET, "key1", []byte("value1")), encodeMapWalEntry(MAP_WAL_DEL, "key2", nil)...)
+	walContent = append(walContent, 0xff, 0xff, 0xff, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x01) // Header torn into a length of almost 4GB
+	walPath := path.Join(dirPath, "data_db"+MAP_WAL_SUFFIX)
+	if err := ioutil.WriteFile(walPath, walContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dict := make(map[string][]byte)
+	if applied, err := applyMapWalFile(walPath, dict); err != errMapWalCorrupted || applied != 2 {
+		t.Errorf("Applied: %d, error: %v", applied, err)
+	}
+	if string(dict["key1"]) != "value1" || len(dict) != 1 {
+		t.Errorf("Unexpected content: %+v", dict)
+	}
+}
+
+func TestMapStorageCacheChangeNotLogged(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "map_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirPath)
+	ms, err := NewMapStoragePersistent(dirPath, "data_db", utils.MSGPACK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version, err := ms.AddCacheChange(&CacheChange{Origin: "nodeA"}); err != nil || version != 1 {
+		t.Fatalf("Version: %d, error: %v", version, err)
+	}
+	ms.wal.close() // Log cannot be written anymore
+	if _, err := ms.AddCacheChange(&CacheChange{Origin: "nodeA"}); err == nil {
+		t.Error("Change added without being logged")
+	}
+	if changes, err := ms.GetCacheChanges(0); err != nil || len(changes) != 1 {
+		t.Errorf("Changes: %+v, error: %v", changes, err)
+	}
+}
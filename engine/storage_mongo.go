@@ -21,6 +21,8 @@ package engine
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/cgrates/cgrates/cache2go"
 	"github.com/cgrates/cgrates/utils"
@@ -31,26 +33,30 @@ import (
 )
 
 const (
-	colDst    = "destinations"
-	colAct    = "actions"
-	colApl    = "actionplans"
-	colAtr    = "actiontriggers"
-	colRpl    = "ratingplans"
-	colRpf    = "ratingprofiles"
-	colAcc    = "accounts"
-	colShg    = "sharedgroups"
-	colLcr    = "lcrrules"
-	colDcs    = "derivedchargers"
-	colAls    = "aliases"
-	colStq    = "statsqeues"
-	colPbs    = "pubsub"
-	colUsr    = "users"
-	colCrs    = "cdrstats"
-	colLht    = "loadhistory"
-	colLogAtr = "actiontriggerslogs"
-	colLogApl = "actionplanlogs"
-	colLogErr = "errorlogs"
-	colCdrs   = "cdrs"
+	colDst      = "destinations"
+	colAct      = "actions"
+	colApl      = "actionplans"
+	colAtr      = "actiontriggers"
+	colRpl      = "ratingplans"
+	colRpf      = "ratingprofiles"
+	colAcc      = "accounts"
+	colShg      = "sharedgroups"
+	colLcr      = "lcrrules"
+	colDcs      = "derivedchargers"
+	colAls      = "aliases"
+	colStq      = "statsqeues"
+	colPbs      = "pubsub"
+	colUsr      = "users"
+	colCrs      = "cdrstats"
+	colLht      = "loadhistory"
+	colLogAtr   = "actiontriggerslogs"
+	colLogApl   = "actionplanlogs"
+	colLogErr   = "errorlogs"
+	colCdrs     = "cdrs"
+	colCdreRuns = "cdreruns"
+	colCch      = "cachechanges"
+	colCsn      = "cachesync"
+	colSeq      = "sequences"
 )
 
 type MongoStorage struct {
@@ -76,7 +82,7 @@ func NewMongoStorage(host, port, db, user, pass string) (*MongoStorage, error) {
 		Background: false, // Build index in background and return immediately
 		Sparse:     false, // Only index documents containing the Key fields
 	}
-	collections := []string{colAct, colApl, colAtr, colDcs, colAls, colUsr, colLcr, colLht}
+	collections := []string{colAct, colApl, colAtr, colDcs, colAls, colUsr, colLcr, colLht, colCsn}
 	for _, col := range collections {
 		if err = ndb.C(col).EnsureIndex(index); err != nil {
 			return nil, err
@@ -95,6 +101,12 @@ func NewMongoStorage(host, port, db, user, pass string) (*MongoStorage, error) {
 			return nil, err
 		}
 	}
+	if err = ndb.C(colDst).EnsureIndex(mgo.Index{Key: []string{"prefixes"}}); err != nil { // Partially cached destinations are loaded on prefix
+		return nil, err
+	}
+	if err = ndb.C(colCsn).EnsureIndex(mgo.Index{Key: []string{"value.lastsync"}, ExpireAfter: CACHE_NODE_TTL}); err != nil { // Only the node versions have a date there
+		return nil, err
+	}
 	index = mgo.Index{
 		Key:        []string{"tpid", "tag"},
 		Unique:     true,
@@ -199,6 +211,22 @@ func NewMongoStorage(host, port, db, user, pass string) (*MongoStorage, error) {
 			return nil, err
 		}
 	}
+	if err = ndb.C(colCdrs).EnsureIndex(cdrsOrderIdIndex); err != nil { // Pages are read in OrderId order
+		return nil, err
+	}
+	index = mgo.Index{
+		Key:        []string{"version"},
+		Unique:     true,
+		DropDups:   false,
+		Background: false,
+		Sparse:     false,
+	}
+	collections = []string{colCch}
+	for _, col := range collections {
+		if err = ndb.C(col).EnsureIndex(index); err != nil {
+			return nil, err
+		}
+	}
 	return &MongoStorage{db: ndb, session: session}, err
 }
 
@@ -206,8 +234,18 @@ func (ms *MongoStorage) Close() {
 	ms.session.Close()
 }
 
+// Only rating profiles are listed, needed by LCR when they are partially cached
 func (ms *MongoStorage) GetKeysForPrefix(prefix string) ([]string, error) {
-	return nil, nil
+	if !strings.HasPrefix(prefix, utils.RATING_PROFILE_PREFIX) {
+		return nil, nil
+	}
+	var keys []string
+	idResult := struct{ Id string }{}
+	iter := ms.db.C(colRpf).Find(bson.M{"id": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix[len(utils.RATING_PROFILE_PREFIX):])}}).Select(bson.M{"id": 1}).Iter()
+	for iter.Next(&idResult) {
+		keys = append(keys, utils.RATING_PROFILE_PREFIX+idResult.Id)
+	}
+	return keys, iter.Close()
 }
 
 func (ms *MongoStorage) Flush(ignore string) (err error) {
@@ -268,9 +306,10 @@ func (ms *MongoStorage) CacheRatingPrefixValues(prefixes map[string][]string) er
 }
 
 func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys []string) (err error) {
-	cache2go.BeginTransaction()
+	tx := cache2go.Begin()
 	keyResult := struct{ Key string }{}
 	idResult := struct{ Id string }{}
+	dKeys = invalidatePartialDestinations(dKeys, tx)
 	if dKeys == nil || (float64(cache2go.CountEntries(utils.DESTINATION_PREFIX))*utils.DESTINATIONS_LOAD_THRESHOLD < float64(len(dKeys))) {
 		// if need to load more than a half of exiting keys load them all
 		utils.Logger.Info("Caching all destinations")
@@ -280,27 +319,28 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			dKeys = append(dKeys, utils.DESTINATION_PREFIX+idResult.Id)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.DESTINATION_PREFIX)
+		tx.RemPrefixKey(utils.DESTINATION_PREFIX)
 	} else if len(dKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching destinations: %v", dKeys))
-		CleanStalePrefixes(dKeys)
+		CleanStalePrefixes(dKeys, tx)
 	}
 	for _, key := range dKeys {
 		if len(key) <= len(utils.DESTINATION_PREFIX) {
 			utils.Logger.Warning(fmt.Sprintf("Got malformed destination id: %s", key))
 			continue
 		}
-		if _, err = ms.GetDestination(key[len(utils.DESTINATION_PREFIX):]); err != nil {
-			cache2go.RollbackTransaction()
+		if _, err = ms.getDestination(key[len(utils.DESTINATION_PREFIX):], tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(dKeys) != 0 {
 		utils.Logger.Info("Finished destinations caching.")
 	}
+	rpKeys = invalidatePartialCache(utils.RATING_PLAN_PREFIX, rpKeys, tx)
 	if rpKeys == nil {
 		utils.Logger.Info("Caching all rating plans")
 		iter := ms.db.C(colRpl).Find(nil).Select(bson.M{"id": 1}).Iter()
@@ -309,23 +349,24 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			rpKeys = append(rpKeys, utils.RATING_PLAN_PREFIX+idResult.Id)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.RATING_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.RATING_PLAN_PREFIX)
 	} else if len(rpKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching rating plans: %v", rpKeys))
 	}
 	for _, key := range rpKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetRatingPlan(key[len(utils.RATING_PLAN_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getRatingPlan(key[len(utils.RATING_PLAN_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(rpKeys) != 0 {
 		utils.Logger.Info("Finished rating plans caching.")
 	}
+	rpfKeys = invalidatePartialCache(utils.RATING_PROFILE_PREFIX, rpfKeys, tx)
 	if rpfKeys == nil {
 		utils.Logger.Info("Caching all rating profiles")
 		iter := ms.db.C(colRpf).Find(nil).Select(bson.M{"id": 1}).Iter()
@@ -334,23 +375,24 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			rpfKeys = append(rpfKeys, utils.RATING_PROFILE_PREFIX+idResult.Id)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.RATING_PROFILE_PREFIX)
+		tx.RemPrefixKey(utils.RATING_PROFILE_PREFIX)
 	} else if len(rpfKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching rating profile: %v", rpfKeys))
 	}
 	for _, key := range rpfKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetRatingProfile(key[len(utils.RATING_PROFILE_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getRatingProfile(key[len(utils.RATING_PROFILE_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(rpfKeys) != 0 {
 		utils.Logger.Info("Finished rating profile caching.")
 	}
+	lcrKeys = invalidatePartialCache(utils.LCR_PREFIX, lcrKeys, tx)
 	if lcrKeys == nil {
 		utils.Logger.Info("Caching LCR rules.")
 		iter := ms.db.C(colLcr).Find(nil).Select(bson.M{"key": 1}).Iter()
@@ -359,17 +401,17 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			lcrKeys = append(lcrKeys, utils.LCR_PREFIX+keyResult.Key)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.LCR_PREFIX)
+		tx.RemPrefixKey(utils.LCR_PREFIX)
 	} else if len(lcrKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching LCR rules: %v", lcrKeys))
 	}
 	for _, key := range lcrKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetLCR(key[len(utils.LCR_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getLCR(key[len(utils.LCR_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -377,6 +419,7 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 		utils.Logger.Info("Finished LCR rules caching.")
 	}
 	// DerivedChargers caching
+	dcsKeys = invalidatePartialCache(utils.DERIVEDCHARGERS_PREFIX, dcsKeys, tx)
 	if dcsKeys == nil {
 		utils.Logger.Info("Caching all derived chargers")
 		iter := ms.db.C(colDcs).Find(nil).Select(bson.M{"key": 1}).Iter()
@@ -385,25 +428,26 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			dcsKeys = append(dcsKeys, utils.DERIVEDCHARGERS_PREFIX+keyResult.Key)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.DERIVEDCHARGERS_PREFIX)
+		tx.RemPrefixKey(utils.DERIVEDCHARGERS_PREFIX)
 	} else if len(dcsKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching derived chargers: %v", dcsKeys))
 	}
 	for _, key := range dcsKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetDerivedChargers(key[len(utils.DERIVEDCHARGERS_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getDerivedChargers(key[len(utils.DERIVEDCHARGERS_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(dcsKeys) != 0 {
 		utils.Logger.Info("Finished derived chargers caching.")
 	}
+	actKeys = invalidatePartialCache(utils.ACTION_PREFIX, actKeys, tx)
 	if actKeys == nil {
-		cache2go.RemPrefixKey(utils.ACTION_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PREFIX)
 	}
 	if actKeys == nil {
 		utils.Logger.Info("Caching all actions")
@@ -413,17 +457,17 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			actKeys = append(actKeys, utils.ACTION_PREFIX+keyResult.Key)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.ACTION_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PREFIX)
 	} else if len(actKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching actions: %v", actKeys))
 	}
 	for _, key := range actKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetActions(key[len(utils.ACTION_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getActions(key[len(utils.ACTION_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -432,7 +476,7 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 	}
 
 	if aplKeys == nil {
-		cache2go.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
 	}
 	if aplKeys == nil {
 		utils.Logger.Info("Caching all action plans")
@@ -442,17 +486,17 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			aplKeys = append(aplKeys, utils.ACTION_PLAN_PREFIX+keyResult.Key)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
 	} else if len(aplKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching action plans: %v", aplKeys))
 	}
 	for _, key := range aplKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetActionPlans(key[len(utils.ACTION_PLAN_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getActionPlans(key[len(utils.ACTION_PLAN_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -460,8 +504,9 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 		utils.Logger.Info("Finished action plans caching.")
 	}
 
+	shgKeys = invalidatePartialCache(utils.SHARED_GROUP_PREFIX, shgKeys, tx)
 	if shgKeys == nil {
-		cache2go.RemPrefixKey(utils.SHARED_GROUP_PREFIX)
+		tx.RemPrefixKey(utils.SHARED_GROUP_PREFIX)
 	}
 	if shgKeys == nil {
 		utils.Logger.Info("Caching all shared groups")
@@ -471,23 +516,23 @@ func (ms *MongoStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 			shgKeys = append(shgKeys, utils.SHARED_GROUP_PREFIX+idResult.Id)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
 	} else if len(shgKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching shared groups: %v", shgKeys))
 	}
 	for _, key := range shgKeys {
-		cache2go.RemKey(key)
-		if _, err = ms.GetSharedGroup(key[len(utils.SHARED_GROUP_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getSharedGroup(key[len(utils.SHARED_GROUP_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(shgKeys) != 0 {
 		utils.Logger.Info("Finished shared groups caching.")
 	}
-	cache2go.CommitTransaction()
+	tx.Commit()
 	return nil
 }
 
@@ -522,10 +567,10 @@ func (ms *MongoStorage) CacheAccountingPrefixValues(prefixes map[string][]string
 }
 
 func (ms *MongoStorage) cacheAccounting(alsKeys []string) (err error) {
-	cache2go.BeginTransaction()
+	tx := cache2go.Begin()
 	var keyResult struct{ Key string }
 	if alsKeys == nil {
-		cache2go.RemPrefixKey(utils.ALIASES_PREFIX)
+		tx.RemPrefixKey(utils.ALIASES_PREFIX)
 	}
 	if alsKeys == nil {
 		utils.Logger.Info("Caching all aliases")
@@ -535,7 +580,7 @@ func (ms *MongoStorage) cacheAccounting(alsKeys []string) (err error) {
 			alsKeys = append(alsKeys, utils.ALIASES_PREFIX+keyResult.Key)
 		}
 		if err := iter.Close(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
 	} else if len(alsKeys) != 0 {
@@ -547,11 +592,11 @@ func (ms *MongoStorage) cacheAccounting(alsKeys []string) (err error) {
 		if avs, err := cache2go.Get(key); err == nil && avs != nil {
 			al := &Alias{Values: avs.(AliasValues)}
 			al.SetId(key[len(utils.ALIASES_PREFIX):])
-			al.RemoveReverseCache()
+			al.RemoveReverseCache(tx)
 		}
-		cache2go.RemKey(key)
-		if _, err = ms.GetAlias(key[len(utils.ALIASES_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = ms.getAlias(key[len(utils.ALIASES_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -559,12 +604,12 @@ func (ms *MongoStorage) cacheAccounting(alsKeys []string) (err error) {
 		utils.Logger.Info("Finished aliases caching.")
 	}
 	utils.Logger.Info("Caching load history")
-	if _, err = ms.GetLoadHistory(1, true); err != nil {
-		cache2go.RollbackTransaction()
+	if _, err = ms.getLoadHistory(1, true, tx); err != nil {
+		tx.Rollback()
 		return err
 	}
 	utils.Logger.Info("Finished load history caching.")
-	cache2go.CommitTransaction()
+	tx.Commit()
 	return nil
 }
 
@@ -593,17 +638,23 @@ func (ms *MongoStorage) HasData(category, subject string) (bool, error) {
 }
 
 func (ms *MongoStorage) GetRatingPlan(key string, skipCache bool) (rp *RatingPlan, err error) {
+	return ms.getRatingPlan(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getRatingPlan(key string, skipCache bool, tx *cache2go.Transaction) (rp *RatingPlan, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.RATING_PLAN_PREFIX + key); err == nil {
 			return x.(*RatingPlan), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.RATING_PLAN_PREFIX) {
 			return nil, err
 		}
 	}
 	rp = new(RatingPlan)
 	err = ms.db.C(colRpl).Find(bson.M{"id": key}).One(rp)
 	if err == nil {
-		cache2go.Cache(utils.RATING_PLAN_PREFIX+key, rp)
+		tx.Cache(utils.RATING_PLAN_PREFIX+key, rp)
+	} else if err == mgo.ErrNotFound {
+		return nil, utils.ErrNotFound
 	}
 	return
 }
@@ -618,17 +669,21 @@ func (ms *MongoStorage) SetRatingPlan(rp *RatingPlan) error {
 }
 
 func (ms *MongoStorage) GetRatingProfile(key string, skipCache bool) (rp *RatingProfile, err error) {
+	return ms.getRatingProfile(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getRatingProfile(key string, skipCache bool, tx *cache2go.Transaction) (rp *RatingProfile, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.RATING_PROFILE_PREFIX + key); err == nil {
 			return x.(*RatingProfile), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.RATING_PROFILE_PREFIX) {
 			return nil, err
 		}
 	}
 	rp = new(RatingProfile)
 	err = ms.db.C(colRpf).Find(bson.M{"id": key}).One(rp)
 	if err == nil {
-		cache2go.Cache(utils.RATING_PROFILE_PREFIX+key, rp)
+		tx.Cache(utils.RATING_PROFILE_PREFIX+key, rp)
 	}
 	return
 }
@@ -660,10 +715,14 @@ func (ms *MongoStorage) RemoveRatingProfile(key string) error {
 }
 
 func (ms *MongoStorage) GetLCR(key string, skipCache bool) (lcr *LCR, err error) {
+	return ms.getLCR(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getLCR(key string, skipCache bool, tx *cache2go.Transaction) (lcr *LCR, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.LCR_PREFIX + key); err == nil {
 			return x.(*LCR), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.LCR_PREFIX) {
 			return nil, err
 		}
 	}
@@ -674,7 +733,9 @@ func (ms *MongoStorage) GetLCR(key string, skipCache bool) (lcr *LCR, err error)
 	err = ms.db.C(colLcr).Find(bson.M{"key": key}).One(&result)
 	if err == nil {
 		lcr = result.Value
-		cache2go.Cache(utils.LCR_PREFIX+key, lcr)
+		tx.Cache(utils.LCR_PREFIX+key, lcr)
+	} else if err == mgo.ErrNotFound {
+		return nil, utils.ErrNotFound
 	}
 	return
 }
@@ -688,6 +749,10 @@ func (ms *MongoStorage) SetLCR(lcr *LCR) error {
 }
 
 func (ms *MongoStorage) GetDestination(key string) (result *Destination, err error) {
+	return ms.getDestination(key, nil)
+}
+
+func (ms *MongoStorage) getDestination(key string, tx *cache2go.Transaction) (result *Destination, err error) {
 	result = new(Destination)
 	err = ms.db.C(colDst).Find(bson.M{"id": key}).One(result)
 	if err != nil {
@@ -696,7 +761,7 @@ func (ms *MongoStorage) GetDestination(key string) (result *Destination, err err
 	}
 	// create optimized structure
 	for _, p := range result.Prefixes {
-		cache2go.Push(utils.DESTINATION_PREFIX+p, result.Id)
+		tx.Push(utils.DESTINATION_PREFIX+p, result.Id)
 	}
 	return
 }
@@ -710,11 +775,30 @@ func (ms *MongoStorage) SetDestination(dest *Destination) (err error) {
 	return
 }
 
+func (ms *MongoStorage) GetReverseDestination(prefix string) ([]string, error) {
+	var results []struct{ Id string }
+	if err := ms.db.C(colDst).Find(bson.M{"prefixes": prefix}).Select(bson.M{"id": 1}).All(&results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	dIDs := make([]string, len(results))
+	for idx, result := range results {
+		dIDs[idx] = result.Id
+	}
+	return dIDs, nil
+}
+
 func (ms *MongoStorage) GetActions(key string, skipCache bool) (as Actions, err error) {
+	return ms.getActions(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getActions(key string, skipCache bool, tx *cache2go.Transaction) (as Actions, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.ACTION_PREFIX + key); err == nil {
 			return x.(Actions), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.ACTION_PREFIX) {
 			return nil, err
 		}
 	}
@@ -725,7 +809,9 @@ func (ms *MongoStorage) GetActions(key string, skipCache bool) (as Actions, err
 	err = ms.db.C(colAct).Find(bson.M{"key": key}).One(&result)
 	if err == nil {
 		as = result.Value
-		cache2go.Cache(utils.ACTION_PREFIX+key, as)
+		tx.Cache(utils.ACTION_PREFIX+key, as)
+	} else if err == mgo.ErrNotFound {
+		return nil, utils.ErrNotFound
 	}
 	return
 }
@@ -739,17 +825,23 @@ func (ms *MongoStorage) SetActions(key string, as Actions) error {
 }
 
 func (ms *MongoStorage) GetSharedGroup(key string, skipCache bool) (sg *SharedGroup, err error) {
+	return ms.getSharedGroup(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getSharedGroup(key string, skipCache bool, tx *cache2go.Transaction) (sg *SharedGroup, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.SHARED_GROUP_PREFIX + key); err == nil {
 			return x.(*SharedGroup), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.SHARED_GROUP_PREFIX) {
 			return nil, err
 		}
 	}
 	sg = &SharedGroup{}
 	err = ms.db.C(colShg).Find(bson.M{"id": key}).One(sg)
 	if err == nil {
-		cache2go.Cache(utils.SHARED_GROUP_PREFIX+key, sg)
+		tx.Cache(utils.SHARED_GROUP_PREFIX+key, sg)
+	} else if err == mgo.ErrNotFound {
+		return nil, utils.ErrNotFound
 	}
 	return
 }
@@ -882,6 +974,10 @@ func (ms *MongoStorage) SetAlias(al *Alias) (err error) {
 }
 
 func (ms *MongoStorage) GetAlias(key string, skipCache bool) (al *Alias, err error) {
+	return ms.getAlias(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getAlias(key string, skipCache bool, tx *cache2go.Transaction) (al *Alias, err error) {
 	origKey := key
 	key = utils.ALIASES_PREFIX + key
 	if !skipCache {
@@ -901,9 +997,9 @@ func (ms *MongoStorage) GetAlias(key string, skipCache bool) (al *Alias, err err
 		al = &Alias{Values: kv.Value}
 		al.SetId(origKey)
 		if err == nil {
-			cache2go.Cache(key, al.Values)
+			tx.Cache(key, al.Values)
 			// cache reverse alias
-			al.SetReverseCache()
+			al.SetReverseCache(tx)
 		}
 	}
 	return
@@ -923,7 +1019,7 @@ func (ms *MongoStorage) RemoveAlias(key string) (err error) {
 	}
 	err = ms.db.C(colAls).Remove(bson.M{"key": origKey})
 	if err == nil {
-		al.RemoveReverseCache()
+		al.RemoveReverseCache(nil)
 		cache2go.RemKey(key)
 	}
 	return
@@ -931,6 +1027,10 @@ func (ms *MongoStorage) RemoveAlias(key string) (err error) {
 
 // Limit will only retrieve the last n items out of history, newest first
 func (ms *MongoStorage) GetLoadHistory(limit int, skipCache bool) (loadInsts []*LoadInstance, err error) {
+	return ms.getLoadHistory(limit, skipCache, nil)
+}
+
+func (ms *MongoStorage) getLoadHistory(limit int, skipCache bool, tx *cache2go.Transaction) (loadInsts []*LoadInstance, err error) {
 	if limit == 0 {
 		return nil, nil
 	}
@@ -952,8 +1052,8 @@ func (ms *MongoStorage) GetLoadHistory(limit int, skipCache bool) (loadInsts []*
 	err = ms.db.C(colLht).Find(bson.M{"key": utils.LOADINST_KEY}).One(&kv)
 	if err == nil {
 		loadInsts = kv.Value
-		cache2go.RemKey(utils.LOADINST_KEY)
-		cache2go.Cache(utils.LOADINST_KEY, loadInsts)
+		tx.RemKey(utils.LOADINST_KEY)
+		tx.Cache(utils.LOADINST_KEY, loadInsts)
 	}
 	return loadInsts, nil
 }
@@ -1027,6 +1127,10 @@ func (ms *MongoStorage) SetActionTriggers(key string, atrs ActionTriggers) (err
 }
 
 func (ms *MongoStorage) GetActionPlans(key string, skipCache bool) (ats ActionPlans, err error) {
+	return ms.getActionPlans(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getActionPlans(key string, skipCache bool, tx *cache2go.Transaction) (ats ActionPlans, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.ACTION_PLAN_PREFIX + key); err == nil {
 			return x.(ActionPlans), nil
@@ -1041,7 +1145,7 @@ func (ms *MongoStorage) GetActionPlans(key string, skipCache bool) (ats ActionPl
 	err = ms.db.C(colApl).Find(bson.M{"key": key}).One(&kv)
 	if err == nil {
 		ats = kv.Value
-		cache2go.Cache(utils.ACTION_PLAN_PREFIX+key, ats)
+		tx.Cache(utils.ACTION_PLAN_PREFIX+key, ats)
 	}
 	return
 }
@@ -1078,10 +1182,14 @@ func (ms *MongoStorage) GetAllActionPlans() (ats map[string]ActionPlans, err err
 }
 
 func (ms *MongoStorage) GetDerivedChargers(key string, skipCache bool) (dcs *utils.DerivedChargers, err error) {
+	return ms.getDerivedChargers(key, skipCache, nil)
+}
+
+func (ms *MongoStorage) getDerivedChargers(key string, skipCache bool, tx *cache2go.Transaction) (dcs *utils.DerivedChargers, err error) {
 	if !skipCache {
 		if x, err := cache2go.Get(utils.DERIVEDCHARGERS_PREFIX + key); err == nil {
 			return x.(*utils.DerivedChargers), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.DERIVEDCHARGERS_PREFIX) {
 			return nil, err
 		}
 	}
@@ -1092,7 +1200,9 @@ func (ms *MongoStorage) GetDerivedChargers(key string, skipCache bool) (dcs *uti
 	err = ms.db.C(colDcs).Find(bson.M{"key": key}).One(&kv)
 	if err == nil {
 		dcs = kv.Value
-		cache2go.Cache(utils.DERIVEDCHARGERS_PREFIX+key, dcs)
+		tx.Cache(utils.DERIVEDCHARGERS_PREFIX+key, dcs)
+	} else if err == mgo.ErrNotFound {
+		return nil, utils.ErrNotFound
 	}
 	return
 }
@@ -1134,3 +1244,83 @@ func (ms *MongoStorage) GetAllCdrStats() (css []*CdrStats, err error) {
 	err = iter.Close()
 	return
 }
+
+// The version is incremented before the change is inserted, readers in between see it as a gap
+func (ms *MongoStorage) AddCacheChange(cc *CacheChange) (int64, error) {
+	var kv struct {
+		Key   string
+		Value int64
+	}
+	if _, err := ms.db.C(colCsn).Find(bson.M{"key": utils.CACHE_VERSION_KEY}).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"value": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &kv); err != nil {
+		return 0, err
+	}
+	cc.Version = kv.Value
+	if err := ms.db.C(colCch).Insert(cc); err != nil {
+		return 0, err
+	}
+	if _, err := ms.db.C(colCch).RemoveAll(bson.M{"version": bson.M{"$lte": cc.Version - CACHE_CHANGES_SIZE}}); err != nil {
+		return 0, err
+	}
+	return cc.Version, nil
+}
+
+// Changes after sinceVersion, ordered by version
+func (ms *MongoStorage) GetCacheChanges(sinceVersion int64) ([]*CacheChange, error) {
+	lastVersion, err := ms.GetCacheVersion()
+	if err != nil {
+		return nil, err
+	}
+	if lastVersion-sinceVersion > CACHE_CHANGES_SIZE {
+		return nil, ErrCacheChangesTrimmed
+	}
+	var changes []*CacheChange
+	if err := ms.db.C(colCch).Find(bson.M{"version": bson.M{"$gt": sinceVersion}}).Sort("version").All(&changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (ms *MongoStorage) GetCacheVersion() (int64, error) {
+	var kv struct {
+		Key   string
+		Value int64
+	}
+	if err := ms.db.C(colCsn).Find(bson.M{"key": utils.CACHE_VERSION_KEY}).One(&kv); err == mgo.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return kv.Value, nil
+}
+
+func (ms *MongoStorage) SetCacheNodeVersion(nodeId string, nv *CacheNodeVersion) error {
+	_, err := ms.db.C(colCsn).Upsert(bson.M{"key": utils.CACHE_NODE_PREFIX + nodeId}, &struct {
+		Key   string
+		Value *CacheNodeVersion
+	}{Key: utils.CACHE_NODE_PREFIX + nodeId, Value: nv})
+	return err
+}
+
+func (ms *MongoStorage) GetCacheNodeVersions() (map[string]*CacheNodeVersion, error) {
+	var kvs []struct {
+		Key   string
+		Value *CacheNodeVersion
+	}
+	if err := ms.db.C(colCsn).Find(bson.M{"key": bson.M{"$regex": "^" + utils.CACHE_NODE_PREFIX}}).All(&kvs); err != nil {
+		return nil, err
+	}
+	nodeVersions := make(map[string]*CacheNodeVersion, len(kvs))
+	for _, kv := range kvs {
+		nodeVersions[kv.Key[len(utils.CACHE_NODE_PREFIX):]] = kv.Value
+	}
+	return nodeVersions, nil
+}
+
+// Change streams are not available with the mgo driver, cache syncing with mongo relies on poll_interval and the config refuses notifications for it
+func (ms *MongoStorage) SubscribeCacheChanges(versionChan chan<- int64, stopChan <-chan struct{}) error {
+	return utils.ErrNotImplemented
+}
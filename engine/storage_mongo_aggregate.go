@@ -0,0 +1,116 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Numbers summed up by the aggregation come back as int, int64 or float64 depending on the values
+func mongoNumber(val interface{}) float64 {
+	switch num := val.(type) {
+	case int:
+		return float64(num)
+	case int64:
+		return float64(num)
+	case float64:
+		return num
+	}
+	return 0
+}
+
+// Counts and sums up usage and cost of the CDRs matching qryFltr with an aggregation pipeline on each CDR collection, merging the results
+func (ms *MongoStorage) GetCdrsAggregates(qryFltr *utils.CdrsFilter, groupBy []string, timeBucket string) ([]*CdrsAggregate, error) {
+	if err := CheckCdrsAggregateGroupBy(groupBy, timeBucket); err != nil {
+		return nil, err
+	}
+	qryFltr = cdrsAggregateFilter(qryFltr, groupBy)
+	groupId := bson.M{}
+	for _, fld := range groupBy {
+		switch fld {
+		case utils.TENANT:
+			groupId[fld] = "$tenant"
+		case utils.ACCOUNT:
+			groupId[fld] = "$account"
+		case utils.DESTINATION_ID: // Matched on the first timespan
+			groupId[fld] = bson.M{"$arrayElemAt": []interface{}{"$costdetails.timespans.matcheddestid", 0}}
+		case utils.SUPPLIER:
+			groupId[fld] = "$supplier"
+		case utils.MEDI_RUNID:
+			groupId[fld] = "$mediationrunid"
+		}
+	}
+	if timeBucket != "" {
+		groupId["year"] = bson.M{"$year": "$answertime"}
+		groupId["month"] = bson.M{"$month": "$answertime"}
+		if timeBucket != utils.META_MONTHLY {
+			groupId["day"] = bson.M{"$dayOfMonth": "$answertime"}
+		}
+		if timeBucket == utils.META_HOURLY {
+			groupId["hour"] = bson.M{"$hour": "$answertime"}
+		}
+	}
+	pipeline := []bson.M{
+		bson.M{"$match": ms.cdrsQueryFilters(qryFltr)},
+		bson.M{"$group": bson.M{
+			"_id":   groupId,
+			"count": bson.M{"$sum": 1},
+			"usage": bson.M{"$sum": "$usage"},
+			"cost":  bson.M{"$sum": bson.M{"$cond": []interface{}{bson.M{"$gte": []interface{}{"$cost", 0}}, "$cost", 0}}}, // Errors are stored with negative cost
+		}},
+	}
+	colNames, err := ms.cdrCollections(qryFltr)
+	if err != nil {
+		return nil, err
+	}
+	var aggrs []*CdrsAggregate
+	for _, colName := range colNames {
+		var results []struct {
+			Id    bson.M `bson:"_id"`
+			Count interface{}
+			Usage interface{}
+			Cost  interface{}
+		}
+		if err := ms.db.C(colName).Pipe(pipeline).All(&results); err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			aggr := &CdrsAggregate{GroupValues: make(map[string]string), Count: int64(mongoNumber(result.Count)),
+				Usage: time.Duration(mongoNumber(result.Usage)), Cost: mongoNumber(result.Cost)}
+			for _, fld := range groupBy {
+				aggr.GroupValues[fld], _ = result.Id[fld].(string)
+			}
+			if timeBucket != "" {
+				day, hour := 1, 0
+				if result.Id["day"] != nil {
+					day = int(mongoNumber(result.Id["day"]))
+				}
+				if result.Id["hour"] != nil {
+					hour = int(mongoNumber(result.Id["hour"]))
+				}
+				aggr.TimeBucket = time.Date(int(mongoNumber(result.Id["year"])), time.Month(mongoNumber(result.Id["month"])), day, hour, 0, 0, 0, time.UTC)
+			}
+			aggrs = append(aggrs, aggr)
+		}
+	}
+	return mergeCdrsAggregates(aggrs, groupBy), nil
+}
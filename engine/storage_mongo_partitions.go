@@ -0,0 +1,181 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const MONGO_PURGE_BATCH = 1000 // CDRs removed with one query when purging
+
+// Index of the CDR collections the pages of CDRs are read with
+var cdrsOrderIdIndex = mgo.Index{Key: []string{"orderid", "_id"}}
+
+// Suffixes of the existing CDR partitions, oldest first
+func (ms *MongoStorage) cdrPartitions() ([]string, error) {
+	colNames, err := ms.db.CollectionNames()
+	if err != nil {
+		return nil, err
+	}
+	var partitions []string
+	for _, colName := range colNames {
+		if !strings.HasPrefix(colName, colCdrs+"_") {
+			continue
+		}
+		suffix := strings.TrimPrefix(colName, colCdrs+"_")
+		if _, err := time.Parse(CDRS_PARTITION_LAYOUT, suffix); err == nil {
+			partitions = append(partitions, suffix)
+		}
+	}
+	sort.Strings(partitions)
+	return partitions, nil
+}
+
+// Collections to query for CDRs matching the filter, the partitions first since they hold the older CDRs
+func (ms *MongoStorage) cdrCollections(qryFltr *utils.CdrsFilter) ([]string, error) {
+	partitions, err := ms.cdrPartitions()
+	if err != nil {
+		return nil, err
+	}
+	var colNames []string
+	for _, suffix := range filterCdrPartitions(partitions, qryFltr) {
+		colNames = append(colNames, colCdrs+"_"+suffix)
+	}
+	return append(colNames, colCdrs), nil
+}
+
+// Moves the CDRs stored before the month of storedBefore into their monthly partitions, the time they were stored at is the one of their _id.
+// Rating data stored for them later follows into the partition of its CDR.
+func (ms *MongoStorage) RollCdrPartitions(storedBefore time.Time) error {
+	before := cdrsPartitionMonth(storedBefore)
+	partitions, err := ms.cdrPartitions()
+	if err != nil {
+		return err
+	}
+	iter := ms.db.C(colCdrs).Find(bson.M{"_id": bson.M{"$lt": bson.NewObjectIdWithTime(before)}}).Sort("_id").Iter()
+	var doc bson.M
+	for iter.Next(&doc) {
+		id, hasId := doc["_id"].(bson.ObjectId)
+		if !hasId {
+			continue
+		}
+		suffix, err := ms.cdrPartition(partitions, doc["cgrid"])
+		if err != nil {
+			iter.Close()
+			return err
+		}
+		if len(suffix) == 0 { // First data of the CDR, its partition is given by it
+			suffix = cdrsPartitionMonth(id.Time().In(before.Location())).Format(CDRS_PARTITION_LAYOUT)
+			if !utils.IsSliceMember(partitions, suffix) {
+				if err := ms.db.C(colCdrs + "_" + suffix).EnsureIndex(mgo.Index{Key: []string{"cgrid", "cdrsource", "mediationrunid"}, Unique: true}); err != nil {
+					iter.Close()
+					return err
+				}
+				if err := ms.db.C(colCdrs + "_" + suffix).EnsureIndex(cdrsOrderIdIndex); err != nil {
+					iter.Close()
+					return err
+				}
+				partitions = append(partitions, suffix)
+			}
+		}
+		delete(doc, "_id")
+		if _, err := ms.db.C(colCdrs+"_"+suffix).Upsert(bson.M{"cgrid": doc["cgrid"], "cdrsource": doc["cdrsource"], "mediationrunid": doc["mediationrunid"]},
+			bson.M{"$set": doc, "$setOnInsert": bson.M{"_id": id}}); err != nil { // Newer data replaces the one already in the partition
+			iter.Close()
+			return err
+		}
+		if err := ms.db.C(colCdrs).RemoveId(id); err != nil {
+			iter.Close()
+			return err
+		}
+		doc = nil
+	}
+	return iter.Close()
+}
+
+// Suffix of the partition already holding data of the CDR, empty if none
+func (ms *MongoStorage) cdrPartition(partitions []string, cgrid interface{}) (string, error) {
+	for idx := len(partitions) - 1; idx >= 0; idx-- { // Newest first, most probably there
+		if cnt, err := ms.db.C(colCdrs + "_" + partitions[idx]).Find(bson.M{"cgrid": cgrid}).Count(); err != nil {
+			return "", err
+		} else if cnt != 0 {
+			return partitions[idx], nil
+		}
+	}
+	return "", nil
+}
+
+// Removes the CDRs stored before storedBefore together with their data, partitions expired as a whole are dropped
+func (ms *MongoStorage) PurgeCdrs(storedBefore time.Time) error {
+	partitions, err := ms.cdrPartitions()
+	if err != nil {
+		return err
+	}
+	for _, suffix := range partitions {
+		month, err := time.ParseInLocation(CDRS_PARTITION_LAYOUT, suffix, storedBefore.Location())
+		if err != nil {
+			return err
+		}
+		if !month.Before(storedBefore) {
+			continue
+		}
+		if !month.AddDate(0, 1, 0).After(storedBefore) {
+			if err := ms.db.C(colCdrs + "_" + suffix).DropCollection(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := ms.purgeCdrCollection(colCdrs+"_"+suffix, storedBefore); err != nil {
+			return err
+		}
+	}
+	return ms.purgeCdrCollection(colCdrs, storedBefore)
+}
+
+// Removes all data of the CDRs having data stored before storedBefore
+func (ms *MongoStorage) purgeCdrCollection(colName string, storedBefore time.Time) error {
+	col := ms.db.C(colName)
+	for {
+		var docs []struct {
+			Id    bson.ObjectId `bson:"_id"`
+			CgrId string
+		}
+		if err := col.Find(bson.M{"_id": bson.M{"$lt": bson.NewObjectIdWithTime(storedBefore)}}).Select(bson.M{"cgrid": 1}).Limit(MONGO_PURGE_BATCH).All(&docs); err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+		ids := make([]bson.ObjectId, len(docs))
+		cgrIds := make([]string, len(docs))
+		for idx, doc := range docs {
+			ids[idx] = doc.Id
+			cgrIds[idx] = doc.CgrId
+		}
+		if _, err := col.RemoveAll(bson.M{"$or": []bson.M{bson.M{"_id": bson.M{"$in": ids}}, bson.M{"cgrid": bson.M{"$in": cgrIds}}}}); err != nil {
+			return err
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/cgrates/cgrates/utils"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -716,12 +717,45 @@ func (ms *MongoStorage) GetCallCostLog(cgrid, source, runid string) (cc *CallCos
 	return
 }
 
+// Populates the OrderId of a CDR stored for the first time, CDRs derived out of the same primary one share it as they do on SQL
+func (ms *MongoStorage) setCdrOrderId(cdr *StoredCdr) error {
+	if cdr.OrderId != 0 {
+		return nil
+	}
+	var stored struct{ OrderId int64 }
+	if err := ms.db.C(colCdrs).Find(bson.M{"cgrid": cdr.CgrId, "orderid": bson.M{"$gt": 0}}).Select(bson.M{"orderid": 1}).One(&stored); err == nil {
+		cdr.OrderId = stored.OrderId
+		return nil
+	} else if err != mgo.ErrNotFound {
+		return err
+	}
+	var seq struct {
+		Key   string
+		Value int64
+	}
+	if _, err := ms.db.C(colSeq).Find(bson.M{"key": colCdrs}).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"value": 1}},
+		Upsert:    true,
+		ReturnNew: true,
+	}, &seq); err != nil {
+		return err
+	}
+	cdr.OrderId = seq.Value
+	return nil
+}
+
 func (ms *MongoStorage) SetCdr(cdr *StoredCdr) error {
+	if err := ms.setCdrOrderId(cdr); err != nil {
+		return err
+	}
 	_, err := ms.db.C(colCdrs).Upsert(bson.M{"cgrid": cdr.CgrId, "mediationrunid": cdr.MediationRunId}, cdr)
 	return err
 }
 
 func (ms *MongoStorage) SetRatedCdr(storedCdr *StoredCdr) error {
+	if err := ms.setCdrOrderId(storedCdr); err != nil {
+		return err
+	}
 	_, err := ms.db.C(colCdrs).Upsert(bson.M{"cgrid": storedCdr.CgrId, "mediationrunid": storedCdr.MediationRunId}, storedCdr)
 	return err
 }
@@ -735,6 +769,46 @@ func (ms *MongoStorage) RemStoredCdrs(cgrIds []string) error {
 	return err
 }
 
+// Marks the CDRs with the export id, kept as a set inside the CDR so re-runs do not duplicate it
+func (ms *MongoStorage) SetCdrsExportId(exportId string, cgrIds []string) error {
+	if len(cgrIds) == 0 {
+		return nil
+	}
+	colNames, err := ms.cdrCollections(new(utils.CdrsFilter)) // Exported CDRs can be already rolled into partitions
+	if err != nil {
+		return err
+	}
+	for _, colName := range colNames {
+		if _, err := ms.db.C(colName).UpdateAll(bson.M{"cgrid": bson.M{"$in": cgrIds}}, bson.M{"$addToSet": bson.M{"exportids": exportId}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *MongoStorage) SetCdreRun(run *CdreRun) error {
+	_, err := ms.db.C(colCdreRuns).Upsert(bson.M{"exportid": run.ExportId}, run)
+	return err
+}
+
+func (ms *MongoStorage) GetCdreRuns(jobId string, pag *utils.Paginator) (runs []*CdreRun, err error) {
+	fltr := bson.M{}
+	if len(jobId) != 0 {
+		fltr["jobid"] = jobId
+	}
+	q := ms.db.C(colCdreRuns).Find(fltr).Sort("starttime")
+	if pag != nil {
+		if pag.Limit != nil {
+			q = q.Limit(*pag.Limit)
+		}
+		if pag.Offset != nil {
+			q = q.Skip(*pag.Offset)
+		}
+	}
+	err = q.All(&runs)
+	return
+}
+
 func (ms *MongoStorage) cleanEmptyFilters(filters bson.M) {
 	for k, v := range filters {
 		switch value := v.(type) {
@@ -759,7 +833,8 @@ func (ms *MongoStorage) cleanEmptyFilters(filters bson.M) {
 	}
 }
 
-func (ms *MongoStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
+// Builds the query matching qryFltr, paginator and count excluded
+func (ms *MongoStorage) cdrsQueryFilters(qryFltr *utils.CdrsFilter) bson.M {
 	filters := bson.M{
 		"cgrid":            bson.M{"$in": qryFltr.CgrIds, "$nin": qryFltr.NotCgrIds},
 		"mediationrunid":   bson.M{"$in": qryFltr.RunIds, "$nin": qryFltr.NotRunIds},
@@ -776,28 +851,39 @@ func (ms *MongoStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr,
 		"disconnect_cause": bson.M{"$in": qryFltr.DisconnectCauses, "$nin": qryFltr.NotDisconnectCauses},
 		"setuptime":        bson.M{"$gte": qryFltr.SetupTimeStart, "$lt": qryFltr.SetupTimeEnd},
 		"answertime":       bson.M{"$gte": qryFltr.AnswerTimeStart, "$lt": qryFltr.AnswerTimeEnd},
-		"created_at":       bson.M{"$gte": qryFltr.CreatedAtStart, "$lt": qryFltr.CreatedAtEnd},
 		"updated_at":       bson.M{"$gte": qryFltr.UpdatedAtStart, "$lt": qryFltr.UpdatedAtEnd},
 		"usage":            bson.M{"$gte": qryFltr.MinUsage, "$lt": qryFltr.MaxUsage},
 		"pdd":              bson.M{"$gte": qryFltr.MinPdd, "$lt": qryFltr.MaxPdd},
 		"costdetails.account": bson.M{"$in": qryFltr.RatedAccounts, "$nin": qryFltr.NotRatedAccounts},
 		"costdetails.subject": bson.M{"$in": qryFltr.RatedSubjects, "$nin": qryFltr.NotRatedSubjects},
+		"exportids":           bson.M{"$in": qryFltr.ExportIds},
 	}
 	//file, _ := ioutil.TempFile(os.TempDir(), "debug")
 	//file.WriteString(fmt.Sprintf("FILTER: %v\n", utils.ToIJSON(qryFltr)))
 	//file.WriteString(fmt.Sprintf("BEFORE: %v\n", utils.ToIJSON(filters)))
 	ms.cleanEmptyFilters(filters)
+	// The time CDRs were stored at is the one of their _id
+	if qryFltr.CreatedAtStart != nil && !qryFltr.CreatedAtStart.IsZero() {
+		filters["_id"] = bson.M{"$gte": bson.NewObjectIdWithTime(*qryFltr.CreatedAtStart)}
+	}
+	if qryFltr.CreatedAtEnd != nil && !qryFltr.CreatedAtEnd.IsZero() {
+		if m, ok := filters["_id"]; ok {
+			m.(bson.M)["$lt"] = bson.NewObjectIdWithTime(*qryFltr.CreatedAtEnd)
+		} else {
+			filters["_id"] = bson.M{"$lt": bson.NewObjectIdWithTime(*qryFltr.CreatedAtEnd)}
+		}
+	}
 
-	/*if qryFltr.OrderIdStart != 0 {
-		filters["id"] = bson.M{"$gte": qryFltr.OrderIdStart}
+	if qryFltr.OrderIdStart != 0 {
+		filters["orderid"] = bson.M{"$gte": qryFltr.OrderIdStart}
 	}
 	if qryFltr.OrderIdEnd != 0 {
-		if m, ok := filters["id"]; ok {
-			m.(bson.M)["$gte"] = qryFltr.OrderIdStart
+		if m, ok := filters["orderid"]; ok {
+			m.(bson.M)["$lt"] = qryFltr.OrderIdEnd
 		} else {
-			filters["id"] = bson.M{"$gte": qryFltr.OrderIdStart}
+			filters["orderid"] = bson.M{"$lt": qryFltr.OrderIdEnd}
 		}
-	}*/
+	}
 
 	if len(qryFltr.DestPrefixes) != 0 {
 		var regexes []bson.RegEx
@@ -853,28 +939,76 @@ func (ms *MongoStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr,
 	}
 	//file.WriteString(fmt.Sprintf("AFTER: %v\n", utils.ToIJSON(filters)))
 	//file.Close()
-	q := ms.db.C(colCdrs).Find(filters)
-	if qryFltr.Paginator.Limit != nil {
-		q = q.Limit(*qryFltr.Paginator.Limit)
+	return filters
+}
+
+func (ms *MongoStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
+	filters := ms.cdrsQueryFilters(qryFltr)
+	colNames, err := ms.cdrCollections(qryFltr)
+	if err != nil {
+		return nil, 0, err
 	}
+	offset, limit := 0, -1
 	if qryFltr.Paginator.Offset != nil {
-		q = q.Skip(*qryFltr.Paginator.Offset)
+		offset = *qryFltr.Paginator.Offset
+	}
+	if qryFltr.Paginator.Limit != nil {
+		limit = *qryFltr.Paginator.Limit
 	}
 	if qryFltr.Count {
-		cnt, err := q.Count()
-		if err != nil {
-			return nil, 0, err
+		var cnt int
+		for _, colName := range colNames {
+			colCnt, err := ms.db.C(colName).Find(filters).Count()
+			if err != nil {
+				return nil, 0, err
+			}
+			cnt += colCnt
+		}
+		if cnt -= offset; cnt < 0 {
+			cnt = 0
+		}
+		if limit != -1 && cnt > limit {
+			cnt = limit
 		}
 		return nil, int64(cnt), nil
 	}
-
-	// Execute query
-	iter := q.Iter()
 	var cdrs []*StoredCdr
-	cdr := StoredCdr{}
-	for iter.Next(&cdr) {
-		clone := cdr
-		cdrs = append(cdrs, &clone)
+	for _, colName := range colNames { // Paginate over the partitions as if they were one collection
+		if limit != -1 && len(cdrs) == limit {
+			break
+		}
+		q := ms.db.C(colName).Find(filters)
+		if offset != 0 {
+			colCnt, err := ms.db.C(colName).Find(filters).Count()
+			if err != nil {
+				return nil, 0, err
+			}
+			if colCnt <= offset {
+				offset -= colCnt
+				continue
+			}
+			q = q.Skip(offset)
+			offset = 0
+		}
+		if limit != -1 {
+			q = q.Sort("orderid", "_id") // Stable order so we can page through results
+			q = q.Limit(limit - len(cdrs))
+		}
+		// Execute query
+		iter := q.Iter()
+		cdr := StoredCdr{}
+		for iter.Next(&cdr) {
+			clone := cdr
+			cdrs = append(cdrs, &clone)
+		}
+		if err := iter.Close(); err != nil {
+			return nil, 0, err
+		}
 	}
 	return cdrs, 0, nil
 }
+
+// Keyset pagination on the OrderId of the CDRs, as on SQL, the cursor being the OrderId the next page starts with
+func (ms *MongoStorage) GetStoredCdrsPage(qryFltr *utils.CdrsFilter, cursor string, pageSize int) ([]*StoredCdr, string, error) {
+	return PageStoredCdrsOnOrderId(ms.GetStoredCdrs, qryFltr, cursor, pageSize)
+}
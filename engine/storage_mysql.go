@@ -49,6 +49,9 @@ type MySQLStorage struct {
 }
 
 func (self *MySQLStorage) Flush(scriptsPath string) (err error) {
+	if err := self.dropCdrPartitions(); err != nil {
+		return err
+	}
 	for _, scriptName := range []string{utils.CREATE_CDRS_TABLES_SQL, utils.CREATE_TARIFFPLAN_TABLES_SQL} {
 		if err := self.CreateTablesFromScript(path.Join(scriptsPath, scriptName)); err != nil {
 			return err
@@ -71,8 +74,12 @@ func (self *MySQLStorage) LogCallCost(cgrid, source, runid string, cc *CallCost)
 		utils.Logger.Err(fmt.Sprintf("Error marshalling timespans to json: %v", err))
 		return err
 	}
-	_, err = self.Db.Exec(fmt.Sprintf("INSERT INTO %s (cgrid,runid,tor,direction,tenant,category,account,subject,destination,cost,timespans,cost_source,created_at) VALUES ('%s','%s','%s','%s','%s','%s','%s','%s','%s',%f,'%s','%s','%s') ON DUPLICATE KEY UPDATE tor=values(tor),direction=values(direction),tenant=values(tenant),category=values(category),account=values(account),subject=values(subject),destination=values(destination),cost=values(cost),timespans=values(timespans),cost_source=values(cost_source),updated_at='%s'",
-		utils.TBL_COST_DETAILS,
+	tblName, err := self.cdrTableFor(utils.TBL_COST_DETAILS, cgrid)
+	if err != nil {
+		return err
+	}
+	_, err = self.Db.Exec(fmt.Sprintf("INSERT INTO %s (cgrid,runid,tor,direction,tenant,category,account,subject,destination,destination_id,cost,timespans,cost_source,created_at) VALUES ('%s','%s','%s','%s','%s','%s','%s','%s','%s','%s',%f,'%s','%s','%s') ON DUPLICATE KEY UPDATE tor=values(tor),direction=values(direction),tenant=values(tenant),category=values(category),account=values(account),subject=values(subject),destination=values(destination),destination_id=values(destination_id),cost=values(cost),timespans=values(timespans),cost_source=values(cost_source),updated_at='%s'",
+		tblName,
 		cgrid,
 		runid,
 		cc.TOR,
@@ -82,6 +89,7 @@ func (self *MySQLStorage) LogCallCost(cgrid, source, runid string, cc *CallCost)
 		cc.Account,
 		cc.Subject,
 		cc.Destination,
+		cc.GetMatchedDestId(),
 		cc.Cost,
 		tss,
 		source,
@@ -95,8 +103,12 @@ func (self *MySQLStorage) LogCallCost(cgrid, source, runid string, cc *CallCost)
 }
 
 func (self *MySQLStorage) SetRatedCdr(storedCdr *StoredCdr) (err error) {
+	tblName, err := self.cdrTableFor(utils.TBL_RATED_CDRS, storedCdr.CgrId)
+	if err != nil {
+		return err
+	}
 	_, err = self.Db.Exec(fmt.Sprintf("INSERT INTO %s (cgrid,runid,reqtype,direction,tenant,category,account,subject,destination,setup_time,answer_time,`usage`,pdd,supplier,disconnect_cause,cost,extra_info,created_at) VALUES ('%s','%s','%s','%s','%s','%s','%s','%s','%s','%s','%s',%v,%v,'%s','%s',%f,'%s','%s') ON DUPLICATE KEY UPDATE reqtype=values(reqtype),direction=values(direction),tenant=values(tenant),category=values(category),account=values(account),subject=values(subject),destination=values(destination),setup_time=values(setup_time),answer_time=values(answer_time),`usage`=values(`usage`),pdd=values(pdd),cost=values(cost),supplier=values(supplier),disconnect_cause=values(disconnect_cause),extra_info=values(extra_info), updated_at='%s'",
-		utils.TBL_RATED_CDRS,
+		tblName,
 		storedCdr.CgrId,
 		storedCdr.MediationRunId,
 		storedCdr.ReqType,
@@ -52,6 +52,9 @@ func NewPostgresStorage(host, port, name, user, password string, maxConn, maxIdl
 }
 
 func (self *PostgresStorage) Flush(scriptsPath string) (err error) {
+	if err := self.dropCdrPartitions(); err != nil {
+		return err
+	}
 	for _, scriptName := range []string{utils.CREATE_CDRS_TABLES_SQL, utils.CREATE_TARIFFPLAN_TABLES_SQL} {
 		if err := self.CreateTablesFromScript(path.Join(scriptsPath, scriptName)); err != nil {
 			return err
@@ -74,28 +77,33 @@ func (self *PostgresStorage) LogCallCost(cgrid, source, runid string, cc *CallCo
 		utils.Logger.Err(fmt.Sprintf("Error marshalling timespans to json: %v", err))
 		return err
 	}
+	tblName, err := self.cdrTableFor(utils.TBL_COST_DETAILS, cgrid)
+	if err != nil {
+		return err
+	}
 	tx := self.db.Begin()
 	cd := &TblCostDetail{
-		Cgrid:       cgrid,
-		Runid:       runid,
-		Tor:         cc.TOR,
-		Direction:   cc.Direction,
-		Tenant:      cc.Tenant,
-		Category:    cc.Category,
-		Account:     cc.Account,
-		Subject:     cc.Subject,
-		Destination: cc.Destination,
-		Cost:        cc.Cost,
-		Timespans:   string(tss),
-		CostSource:  source,
-		CreatedAt:   time.Now(),
+		Cgrid:         cgrid,
+		Runid:         runid,
+		Tor:           cc.TOR,
+		Direction:     cc.Direction,
+		Tenant:        cc.Tenant,
+		Category:      cc.Category,
+		Account:       cc.Account,
+		Subject:       cc.Subject,
+		Destination:   cc.Destination,
+		DestinationId: cc.GetMatchedDestId(),
+		Cost:          cc.Cost,
+		Timespans:     string(tss),
+		CostSource:    source,
+		CreatedAt:     time.Now(),
 	}
 
-	if tx.Save(cd).Error != nil { // Check further since error does not properly reflect duplicates here (sql: no rows in result set)
+	if tx.Table(tblName).Save(cd).Error != nil { // Check further since error does not properly reflect duplicates here (sql: no rows in result set)
 		tx.Rollback()
 		tx = self.db.Begin()
-		updated := tx.Model(TblCostDetail{}).Where(&TblCostDetail{Cgrid: cgrid, Runid: runid}).Updates(&TblCostDetail{Tor: cc.TOR, Direction: cc.Direction, Tenant: cc.Tenant, Category: cc.Category,
-			Account: cc.Account, Subject: cc.Subject, Destination: cc.Destination, Cost: cc.Cost, Timespans: string(tss), CostSource: source, UpdatedAt: time.Now()})
+		updated := tx.Table(tblName).Model(TblCostDetail{}).Where(&TblCostDetail{Cgrid: cgrid, Runid: runid}).Updates(&TblCostDetail{Tor: cc.TOR, Direction: cc.Direction, Tenant: cc.Tenant, Category: cc.Category,
+			Account: cc.Account, Subject: cc.Subject, Destination: cc.Destination, DestinationId: cc.GetMatchedDestId(), Cost: cc.Cost, Timespans: string(tss), CostSource: source, UpdatedAt: time.Now()})
 		if updated.Error != nil {
 			tx.Rollback()
 			return updated.Error
@@ -106,8 +114,12 @@ func (self *PostgresStorage) LogCallCost(cgrid, source, runid string, cc *CallCo
 }
 
 func (self *PostgresStorage) SetRatedCdr(cdr *StoredCdr) (err error) {
+	tblName, err := self.cdrTableFor(utils.TBL_RATED_CDRS, cdr.CgrId)
+	if err != nil {
+		return err
+	}
 	tx := self.db.Begin()
-	saved := tx.Save(&TblRatedCdr{
+	saved := tx.Table(tblName).Save(&TblRatedCdr{
 		Cgrid:           cdr.CgrId,
 		Runid:           cdr.MediationRunId,
 		Reqtype:         cdr.ReqType,
@@ -130,7 +142,7 @@ func (self *PostgresStorage) SetRatedCdr(cdr *StoredCdr) (err error) {
 	if saved.Error != nil {
 		tx.Rollback()
 		tx = self.db.Begin()
-		updated := tx.Model(TblRatedCdr{}).Where(&TblRatedCdr{Cgrid: cdr.CgrId, Runid: cdr.MediationRunId}).Updates(&TblRatedCdr{Reqtype: cdr.ReqType,
+		updated := tx.Table(tblName).Model(TblRatedCdr{}).Where(&TblRatedCdr{Cgrid: cdr.CgrId, Runid: cdr.MediationRunId}).Updates(&TblRatedCdr{Reqtype: cdr.ReqType,
 			Direction: cdr.Direction, Tenant: cdr.Tenant, Category: cdr.Category, Account: cdr.Account, Subject: cdr.Subject, Destination: cdr.Destination,
 			SetupTime: cdr.SetupTime, AnswerTime: cdr.AnswerTime, Usage: cdr.Usage.Seconds(), Pdd: cdr.Pdd.Seconds(), Supplier: cdr.Supplier, DisconnectCause: cdr.DisconnectCause,
 			Cost: cdr.Cost, ExtraInfo: cdr.ExtraInfo,
@@ -24,11 +24,13 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strconv"
 	"time"
 
 	"github.com/cgrates/cgrates/cache2go"
 	"github.com/cgrates/cgrates/utils"
 	"github.com/mediocregopher/radix.v2/pool"
+	"github.com/mediocregopher/radix.v2/pubsub"
 	"github.com/mediocregopher/radix.v2/redis"
 )
 
@@ -37,8 +39,9 @@ var (
 )
 
 type RedisStorage struct {
-	db *pool.Pool
-	ms Marshaler
+	db   *pool.Pool
+	ms   Marshaler
+	dial func() (*redis.Client, error) // Dedicated connections, ie: for subscriptions
 }
 
 func NewRedisStorage(address string, db int, pass, mrshlerStr string, maxConns int) (*RedisStorage, error) {
@@ -73,7 +76,8 @@ func NewRedisStorage(address string, db int, pass, mrshlerStr string, maxConns i
 	} else {
 		return nil, fmt.Errorf("Unsupported marshaler: %v", mrshlerStr)
 	}
-	return &RedisStorage{db: p, ms: mrshler}, nil
+	dial := func() (*redis.Client, error) { return df("tcp", address) }
+	return &RedisStorage{db: p, ms: mrshler, dial: dial}, nil
 }
 
 func (rs *RedisStorage) Close() {
@@ -137,91 +141,101 @@ func (rs *RedisStorage) CacheRatingPrefixValues(prefixes map[string][]string) er
 }
 
 func (rs *RedisStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, actKeys, aplKeys, shgKeys []string) (err error) {
-	cache2go.BeginTransaction()
+	tx := cache2go.Begin()
 	conn, err := rs.db.Get()
 	if err != nil {
 		return err
 	}
 	defer rs.db.Put(conn)
+	if dKeys == nil && cache2go.IsPartial(utils.DESTINATION_PREFIX) {
+		if err = rs.indexReverseDestinations(); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	dKeys = invalidatePartialDestinations(dKeys, tx)
 	if dKeys == nil || (float64(cache2go.CountEntries(utils.DESTINATION_PREFIX))*utils.DESTINATIONS_LOAD_THRESHOLD < float64(len(dKeys))) {
 		// if need to load more than a half of exiting keys load them all
 		utils.Logger.Info("Caching all destinations")
 		if dKeys, err = conn.Cmd("KEYS", utils.DESTINATION_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.DESTINATION_PREFIX)
+		tx.RemPrefixKey(utils.DESTINATION_PREFIX)
 	} else if len(dKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching destinations: %v", dKeys))
-		CleanStalePrefixes(dKeys)
+		CleanStalePrefixes(dKeys, tx)
 	}
 	for _, key := range dKeys {
 		if len(key) <= len(utils.DESTINATION_PREFIX) {
 			utils.Logger.Warning(fmt.Sprintf("Got malformed destination id: %s", key))
 			continue
 		}
-		if _, err = rs.GetDestination(key[len(utils.DESTINATION_PREFIX):]); err != nil {
-			cache2go.RollbackTransaction()
+		if _, err = rs.getDestination(key[len(utils.DESTINATION_PREFIX):], tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(dKeys) != 0 {
 		utils.Logger.Info("Finished destinations caching.")
 	}
+	rpKeys = invalidatePartialCache(utils.RATING_PLAN_PREFIX, rpKeys, tx)
 	if rpKeys == nil {
 		utils.Logger.Info("Caching all rating plans")
 		if rpKeys, err = conn.Cmd("KEYS", utils.RATING_PLAN_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.RATING_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.RATING_PLAN_PREFIX)
 	} else if len(rpKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching rating plans: %v", rpKeys))
 	}
 	for _, key := range rpKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetRatingPlan(key[len(utils.RATING_PLAN_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getRatingPlan(key[len(utils.RATING_PLAN_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(rpKeys) != 0 {
 		utils.Logger.Info("Finished rating plans caching.")
 	}
+	rpfKeys = invalidatePartialCache(utils.RATING_PROFILE_PREFIX, rpfKeys, tx)
 	if rpfKeys == nil {
 		utils.Logger.Info("Caching all rating profiles")
 		if rpfKeys, err = conn.Cmd("KEYS", utils.RATING_PROFILE_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.RATING_PROFILE_PREFIX)
+		tx.RemPrefixKey(utils.RATING_PROFILE_PREFIX)
 	} else if len(rpfKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching rating profile: %v", rpfKeys))
 	}
 	for _, key := range rpfKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetRatingProfile(key[len(utils.RATING_PROFILE_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getRatingProfile(key[len(utils.RATING_PROFILE_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(rpfKeys) != 0 {
 		utils.Logger.Info("Finished rating profile caching.")
 	}
+	lcrKeys = invalidatePartialCache(utils.LCR_PREFIX, lcrKeys, tx)
 	if lcrKeys == nil {
 		utils.Logger.Info("Caching LCR rules.")
 		if lcrKeys, err = conn.Cmd("KEYS", utils.LCR_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.LCR_PREFIX)
+		tx.RemPrefixKey(utils.LCR_PREFIX)
 	} else if len(lcrKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching LCR rules: %v", lcrKeys))
 	}
 	for _, key := range lcrKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetLCR(key[len(utils.LCR_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getLCR(key[len(utils.LCR_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -229,40 +243,42 @@ func (rs *RedisStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 		utils.Logger.Info("Finished LCR rules caching.")
 	}
 	// DerivedChargers caching
+	dcsKeys = invalidatePartialCache(utils.DERIVEDCHARGERS_PREFIX, dcsKeys, tx)
 	if dcsKeys == nil {
 		utils.Logger.Info("Caching all derived chargers")
 		if dcsKeys, err = conn.Cmd("KEYS", utils.DERIVEDCHARGERS_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.DERIVEDCHARGERS_PREFIX)
+		tx.RemPrefixKey(utils.DERIVEDCHARGERS_PREFIX)
 	} else if len(dcsKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching derived chargers: %v", dcsKeys))
 	}
 	for _, key := range dcsKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetDerivedChargers(key[len(utils.DERIVEDCHARGERS_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getDerivedChargers(key[len(utils.DERIVEDCHARGERS_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
 	if len(dcsKeys) != 0 {
 		utils.Logger.Info("Finished derived chargers caching.")
 	}
+	actKeys = invalidatePartialCache(utils.ACTION_PREFIX, actKeys, tx)
 	if actKeys == nil {
 		utils.Logger.Info("Caching all actions")
 		if actKeys, err = conn.Cmd("KEYS", utils.ACTION_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.ACTION_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PREFIX)
 	} else if len(actKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching actions: %v", actKeys))
 	}
 	for _, key := range actKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetActions(key[len(utils.ACTION_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getActions(key[len(utils.ACTION_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -273,17 +289,17 @@ func (rs *RedisStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 	if aplKeys == nil {
 		utils.Logger.Info("Caching all action plans")
 		if aplKeys, err = rs.db.Cmd("KEYS", utils.ACTION_PLAN_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
+		tx.RemPrefixKey(utils.ACTION_PLAN_PREFIX)
 	} else if len(aplKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching action plan: %v", aplKeys))
 	}
 	for _, key := range aplKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetActionPlans(key[len(utils.ACTION_PLAN_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getActionPlans(key[len(utils.ACTION_PLAN_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -291,20 +307,21 @@ func (rs *RedisStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 		utils.Logger.Info("Finished action plans caching.")
 	}
 
+	shgKeys = invalidatePartialCache(utils.SHARED_GROUP_PREFIX, shgKeys, tx)
 	if shgKeys == nil {
 		utils.Logger.Info("Caching all shared groups")
 		if shgKeys, err = conn.Cmd("KEYS", utils.SHARED_GROUP_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.SHARED_GROUP_PREFIX)
+		tx.RemPrefixKey(utils.SHARED_GROUP_PREFIX)
 	} else if len(shgKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching shared groups: %v", shgKeys))
 	}
 	for _, key := range shgKeys {
-		cache2go.RemKey(key)
-		if _, err = rs.GetSharedGroup(key[len(utils.SHARED_GROUP_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getSharedGroup(key[len(utils.SHARED_GROUP_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -312,7 +329,7 @@ func (rs *RedisStorage) cacheRating(dKeys, rpKeys, rpfKeys, lcrKeys, dcsKeys, ac
 		utils.Logger.Info("Finished shared groups caching.")
 	}
 
-	cache2go.CommitTransaction()
+	tx.Commit()
 	return nil
 }
 
@@ -347,7 +364,7 @@ func (rs *RedisStorage) CacheAccountingPrefixValues(prefixes map[string][]string
 }
 
 func (rs *RedisStorage) cacheAccounting(alsKeys []string) (err error) {
-	cache2go.BeginTransaction()
+	tx := cache2go.Begin()
 	conn, err := rs.db.Get()
 	if err != nil {
 		return err
@@ -356,11 +373,11 @@ func (rs *RedisStorage) cacheAccounting(alsKeys []string) (err error) {
 	if alsKeys == nil {
 		utils.Logger.Info("Caching all aliases")
 		if alsKeys, err = conn.Cmd("KEYS", utils.ALIASES_PREFIX+"*").List(); err != nil {
-			cache2go.RollbackTransaction()
+			tx.Rollback()
 			return err
 		}
-		cache2go.RemPrefixKey(utils.ALIASES_PREFIX)
-		cache2go.RemPrefixKey(utils.REVERSE_ALIASES_PREFIX)
+		tx.RemPrefixKey(utils.ALIASES_PREFIX)
+		tx.RemPrefixKey(utils.REVERSE_ALIASES_PREFIX)
 	} else if len(alsKeys) != 0 {
 		utils.Logger.Info(fmt.Sprintf("Caching aliases: %v", alsKeys))
 	}
@@ -371,11 +388,11 @@ func (rs *RedisStorage) cacheAccounting(alsKeys []string) (err error) {
 		if avs, err := cache2go.Get(key); err == nil && avs != nil {
 			al.Values = avs.(AliasValues)
 			al.SetId(key[len(utils.ALIASES_PREFIX):])
-			al.RemoveReverseCache()
+			al.RemoveReverseCache(tx)
 		}
-		cache2go.RemKey(key)
-		if _, err = rs.GetAlias(key[len(utils.ALIASES_PREFIX):], true); err != nil {
-			cache2go.RollbackTransaction()
+		tx.RemKey(key)
+		if _, err = rs.getAlias(key[len(utils.ALIASES_PREFIX):], true, tx); err != nil {
+			tx.Rollback()
 			return err
 		}
 	}
@@ -383,12 +400,12 @@ func (rs *RedisStorage) cacheAccounting(alsKeys []string) (err error) {
 		utils.Logger.Info("Finished aliases caching.")
 	}
 	utils.Logger.Info("Caching load history")
-	if _, err = rs.GetLoadHistory(1, true); err != nil {
-		cache2go.RollbackTransaction()
+	if _, err = rs.getLoadHistory(1, true, tx); err != nil {
+		tx.Rollback()
 		return err
 	}
 	utils.Logger.Info("Finished load history caching.")
-	cache2go.CommitTransaction()
+	tx.Commit()
 	return nil
 }
 
@@ -403,16 +420,20 @@ func (rs *RedisStorage) HasData(category, subject string) (bool, error) {
 }
 
 func (rs *RedisStorage) GetRatingPlan(key string, skipCache bool) (rp *RatingPlan, err error) {
+	return rs.getRatingPlan(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getRatingPlan(key string, skipCache bool, tx *cache2go.Transaction) (rp *RatingPlan, err error) {
 	key = utils.RATING_PLAN_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*RatingPlan), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.RATING_PLAN_PREFIX) {
 			return nil, err
 		}
 	}
 	var values []byte
-	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
+	if values, err = rs.getBytes(key); err == nil {
 		b := bytes.NewBuffer(values)
 		r, err := zlib.NewReader(b)
 		if err != nil {
@@ -425,7 +446,7 @@ func (rs *RedisStorage) GetRatingPlan(key string, skipCache bool) (rp *RatingPla
 		r.Close()
 		rp = new(RatingPlan)
 		err = rs.ms.Unmarshal(out, rp)
-		cache2go.Cache(key, rp)
+		tx.Cache(key, rp)
 	}
 	return
 }
@@ -445,20 +466,24 @@ func (rs *RedisStorage) SetRatingPlan(rp *RatingPlan) (err error) {
 }
 
 func (rs *RedisStorage) GetRatingProfile(key string, skipCache bool) (rpf *RatingProfile, err error) {
+	return rs.getRatingProfile(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getRatingProfile(key string, skipCache bool, tx *cache2go.Transaction) (rpf *RatingProfile, err error) {
 
 	key = utils.RATING_PROFILE_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*RatingProfile), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.RATING_PROFILE_PREFIX) {
 			return nil, err
 		}
 	}
 	var values []byte
-	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
+	if values, err = rs.getBytes(key); err == nil {
 		rpf = new(RatingProfile)
 		err = rs.ms.Unmarshal(values, rpf)
-		cache2go.Cache(key, rpf)
+		tx.Cache(key, rpf)
 	}
 	return
 }
@@ -498,18 +523,22 @@ func (rs *RedisStorage) RemoveRatingProfile(key string) error {
 }
 
 func (rs *RedisStorage) GetLCR(key string, skipCache bool) (lcr *LCR, err error) {
+	return rs.getLCR(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getLCR(key string, skipCache bool, tx *cache2go.Transaction) (lcr *LCR, err error) {
 	key = utils.LCR_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*LCR), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.LCR_PREFIX) {
 			return nil, err
 		}
 	}
 	var values []byte
-	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
+	if values, err = rs.getBytes(key); err == nil {
 		err = rs.ms.Unmarshal(values, &lcr)
-		cache2go.Cache(key, lcr)
+		tx.Cache(key, lcr)
 	}
 	return
 }
@@ -522,6 +551,21 @@ func (rs *RedisStorage) SetLCR(lcr *LCR) (err error) {
 }
 
 func (rs *RedisStorage) GetDestination(key string) (dest *Destination, err error) {
+	return rs.getDestination(key, nil)
+}
+
+func (rs *RedisStorage) getDestination(key string, tx *cache2go.Transaction) (dest *Destination, err error) {
+	if dest, err = rs.readDestination(key); dest != nil {
+		// create optimized structure
+		for _, p := range dest.Prefixes {
+			tx.Push(utils.DESTINATION_PREFIX+p, dest.Id)
+		}
+	}
+	return
+}
+
+// Destination as stored, its prefixes not cached
+func (rs *RedisStorage) readDestination(key string) (dest *Destination, err error) {
 	key = utils.DESTINATION_PREFIX + key
 	var values []byte
 	if values, err = rs.db.Cmd("GET", key).Bytes(); len(values) > 0 && err == nil {
@@ -537,10 +581,6 @@ func (rs *RedisStorage) GetDestination(key string) (dest *Destination, err error
 		r.Close()
 		dest = new(Destination)
 		err = rs.ms.Unmarshal(out, dest)
-		// create optimized structure
-		for _, p := range dest.Prefixes {
-			cache2go.Push(utils.DESTINATION_PREFIX+p, dest.Id)
-		}
 	} else {
 		return nil, errors.New("not found")
 	}
@@ -552,31 +592,90 @@ func (rs *RedisStorage) SetDestination(dest *Destination) (err error) {
 	if err != nil {
 		return err
 	}
+	oldDest, _ := rs.readDestination(dest.Id)
 	var b bytes.Buffer
 	w := zlib.NewWriter(&b)
 	w.Write(result)
 	w.Close()
-	err = rs.db.Cmd("SET", utils.DESTINATION_PREFIX+dest.Id, b.Bytes()).Err
-	if err == nil && historyScribe != nil {
+	if err = rs.db.Cmd("SET", utils.DESTINATION_PREFIX+dest.Id, b.Bytes()).Err; err != nil {
+		return
+	}
+	if err = rs.setReverseDestination(oldDest, dest); err == nil && historyScribe != nil {
 		response := 0
 		go historyScribe.Record(dest.GetHistoryRecord(), &response)
 	}
 	return
 }
 
+// Keeps the ids of the destinations containing each prefix, partially cached destinations are loaded out of them
+func (rs *RedisStorage) setReverseDestination(oldDest, dest *Destination) error {
+	if oldDest != nil {
+		for _, p := range oldDest.Prefixes {
+			if utils.IsSliceMember(dest.Prefixes, p) {
+				continue
+			}
+			if err := rs.db.Cmd("SREM", utils.REVERSE_DESTINATION_PREFIX+p, dest.Id).Err; err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range dest.Prefixes {
+		if err := rs.db.Cmd("SADD", utils.REVERSE_DESTINATION_PREFIX+p, dest.Id).Err; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Indexes the destinations stored before the reverse destinations were kept, partially cached destinations are missed otherwise
+func (rs *RedisStorage) indexReverseDestinations() error {
+	if indexed, err := rs.hasKeys(utils.REVERSE_DESTINATION_PREFIX + "*"); err != nil || indexed {
+		return err
+	}
+	dKeys, err := rs.scanKeys(utils.DESTINATION_PREFIX + "*")
+	if err != nil || len(dKeys) == 0 {
+		return err
+	}
+	utils.Logger.Info(fmt.Sprintf("Indexing %d destinations on prefixes", len(dKeys)))
+	for _, key := range dKeys {
+		dest, err := rs.readDestination(key[len(utils.DESTINATION_PREFIX):])
+		if err != nil {
+			return err
+		}
+		if err := rs.setReverseDestination(nil, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rs *RedisStorage) GetReverseDestination(prefix string) ([]string, error) {
+	dIDs, err := rs.db.Cmd("SMEMBERS", utils.REVERSE_DESTINATION_PREFIX+prefix).List()
+	if err != nil {
+		return nil, err
+	} else if len(dIDs) == 0 {
+		return nil, utils.ErrNotFound
+	}
+	return dIDs, nil
+}
+
 func (rs *RedisStorage) GetActions(key string, skipCache bool) (as Actions, err error) {
+	return rs.getActions(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getActions(key string, skipCache bool, tx *cache2go.Transaction) (as Actions, err error) {
 	key = utils.ACTION_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(Actions), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.ACTION_PREFIX) {
 			return nil, err
 		}
 	}
 	var values []byte
-	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
+	if values, err = rs.getBytes(key); err == nil {
 		err = rs.ms.Unmarshal(values, &as)
-		cache2go.Cache(key, as)
+		tx.Cache(key, as)
 	}
 	return
 }
@@ -588,18 +687,22 @@ func (rs *RedisStorage) SetActions(key string, as Actions) (err error) {
 }
 
 func (rs *RedisStorage) GetSharedGroup(key string, skipCache bool) (sg *SharedGroup, err error) {
+	return rs.getSharedGroup(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getSharedGroup(key string, skipCache bool, tx *cache2go.Transaction) (sg *SharedGroup, err error) {
 	key = utils.SHARED_GROUP_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*SharedGroup), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.SHARED_GROUP_PREFIX) {
 			return nil, err
 		}
 	}
 	var values []byte
-	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
+	if values, err = rs.getBytes(key); err == nil {
 		err = rs.ms.Unmarshal(values, &sg)
-		cache2go.Cache(key, sg)
+		tx.Cache(key, sg)
 	}
 	return
 }
@@ -610,6 +713,17 @@ func (rs *RedisStorage) SetSharedGroup(sg *SharedGroup) (err error) {
 	return
 }
 
+// Value of key, utils.ErrNotFound if missing
+func (rs *RedisStorage) getBytes(key string) ([]byte, error) {
+	rpl := rs.db.Cmd("GET", key)
+	if rpl.Err != nil {
+		return nil, rpl.Err
+	} else if rpl.IsType(redis.Nil) {
+		return nil, utils.ErrNotFound
+	}
+	return rpl.Bytes()
+}
+
 func (rs *RedisStorage) GetAccount(key string) (*Account, error) {
 	rpl := rs.db.Cmd("GET", utils.ACCOUNT_PREFIX+key)
 	if rpl.Err != nil {
@@ -754,6 +868,10 @@ func (rs *RedisStorage) SetAlias(al *Alias) (err error) {
 }
 
 func (rs *RedisStorage) GetAlias(key string, skipCache bool) (al *Alias, err error) {
+	return rs.getAlias(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getAlias(key string, skipCache bool, tx *cache2go.Transaction) (al *Alias, err error) {
 	origKey := key
 	key = utils.ALIASES_PREFIX + key
 	if !skipCache {
@@ -771,9 +889,9 @@ func (rs *RedisStorage) GetAlias(key string, skipCache bool) (al *Alias, err err
 		al.SetId(origKey)
 		err = rs.ms.Unmarshal(values, &al.Values)
 		if err == nil {
-			cache2go.Cache(key, al.Values)
+			tx.Cache(key, al.Values)
 			// cache reverse alias
-			al.SetReverseCache()
+			al.SetReverseCache(tx)
 		}
 	}
 	return
@@ -795,7 +913,7 @@ func (rs *RedisStorage) RemoveAlias(key string) (err error) {
 	al.Values = aliasValues
 	err = conn.Cmd("DEL", key).Err
 	if err == nil {
-		al.RemoveReverseCache()
+		al.RemoveReverseCache(nil)
 		cache2go.RemKey(key)
 	}
 	return
@@ -803,6 +921,10 @@ func (rs *RedisStorage) RemoveAlias(key string) (err error) {
 
 // Limit will only retrieve the last n items out of history, newest first
 func (rs *RedisStorage) GetLoadHistory(limit int, skipCache bool) ([]*LoadInstance, error) {
+	return rs.getLoadHistory(limit, skipCache, nil)
+}
+
+func (rs *RedisStorage) getLoadHistory(limit int, skipCache bool, tx *cache2go.Transaction) ([]*LoadInstance, error) {
 	if limit == 0 {
 		return nil, nil
 	}
@@ -833,8 +955,8 @@ func (rs *RedisStorage) GetLoadHistory(limit int, skipCache bool) ([]*LoadInstan
 		}
 		loadInsts[idx] = &lInst
 	}
-	cache2go.RemKey(utils.LOADINST_KEY)
-	cache2go.Cache(utils.LOADINST_KEY, loadInsts)
+	tx.RemKey(utils.LOADINST_KEY)
+	tx.Cache(utils.LOADINST_KEY, loadInsts)
 	return loadInsts, nil
 }
 
@@ -894,6 +1016,10 @@ func (rs *RedisStorage) SetActionTriggers(key string, atrs ActionTriggers) (err
 }
 
 func (rs *RedisStorage) GetActionPlans(key string, skipCache bool) (ats ActionPlans, err error) {
+	return rs.getActionPlans(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getActionPlans(key string, skipCache bool, tx *cache2go.Transaction) (ats ActionPlans, err error) {
 	key = utils.ACTION_PLAN_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
@@ -906,7 +1032,7 @@ func (rs *RedisStorage) GetActionPlans(key string, skipCache bool) (ats ActionPl
 
 	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
 		err = rs.ms.Unmarshal(values, &ats)
-		cache2go.Cache(key, ats)
+		tx.Cache(key, ats)
 	}
 	return
 }
@@ -941,18 +1067,22 @@ func (rs *RedisStorage) GetAllActionPlans() (ats map[string]ActionPlans, err err
 }
 
 func (rs *RedisStorage) GetDerivedChargers(key string, skipCache bool) (dcs *utils.DerivedChargers, err error) {
+	return rs.getDerivedChargers(key, skipCache, nil)
+}
+
+func (rs *RedisStorage) getDerivedChargers(key string, skipCache bool, tx *cache2go.Transaction) (dcs *utils.DerivedChargers, err error) {
 	key = utils.DERIVEDCHARGERS_PREFIX + key
 	if !skipCache {
 		if x, err := cache2go.Get(key); err == nil {
 			return x.(*utils.DerivedChargers), nil
-		} else {
+		} else if !cache2go.IsPartial(utils.DERIVEDCHARGERS_PREFIX) {
 			return nil, err
 		}
 	}
 	var values []byte
-	if values, err = rs.db.Cmd("GET", key).Bytes(); err == nil {
+	if values, err = rs.getBytes(key); err == nil {
 		err = rs.ms.Unmarshal(values, &dcs)
-		cache2go.Cache(key, dcs)
+		tx.Cache(key, dcs)
 	}
 	return dcs, err
 }
@@ -1048,3 +1178,213 @@ func (rs *RedisStorage) LogActionPlan(source string, at *ActionPlan, as Actions)
 	}
 	return rs.db.Cmd("SET", utils.LOG_ACTION_TIMMING_PREFIX+source+"_"+time.Now().Format(time.RFC3339Nano), []byte(fmt.Sprintf("%v*%v", string(mat), string(mas)))).Err
 }
+
+// Increments the version, stores the change under it, drops the one falling out of the log and notifies subscribers, all in one go
+var redisAddCacheChange = `local version = redis.call("INCR", KEYS[1])
+redis.call("SET", ARGV[1] .. version, ARGV[2])
+redis.call("DEL", ARGV[1] .. (version - tonumber(ARGV[3])))
+redis.call("PUBLISH", ARGV[4], version)
+return version`
+
+func (rs *RedisStorage) AddCacheChange(cc *CacheChange) (int64, error) {
+	marshaled, err := rs.ms.Marshal(cc)
+	if err != nil {
+		return 0, err
+	}
+	version, err := rs.db.Cmd("EVAL", redisAddCacheChange, 1, utils.CACHE_VERSION_KEY,
+		utils.CACHE_CHANGE_PREFIX, marshaled, CACHE_CHANGES_SIZE, utils.CACHE_CHANGES_CHANNEL).Int()
+	if err != nil {
+		return 0, err
+	}
+	cc.Version = int64(version)
+	return cc.Version, nil
+}
+
+// Changes after sinceVersion, ordered by version
+func (rs *RedisStorage) GetCacheChanges(sinceVersion int64) ([]*CacheChange, error) {
+	lastVersion, err := rs.GetCacheVersion()
+	if err != nil {
+		return nil, err
+	}
+	if lastVersion-sinceVersion > CACHE_CHANGES_SIZE {
+		return nil, ErrCacheChangesTrimmed
+	}
+	conn, err := rs.db.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer rs.db.Put(conn)
+	for version := sinceVersion + 1; version <= lastVersion; version++ {
+		conn.PipeAppend("GET", utils.CACHE_CHANGE_PREFIX+strconv.FormatInt(version, 10))
+	}
+	var changes []*CacheChange
+	for version := sinceVersion + 1; version <= lastVersion; version++ {
+		r := conn.PipeResp()
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		if r.IsType(redis.Nil) { // Not yet written or already trimmed
+			continue
+		}
+		marshaled, err := r.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		cc := new(CacheChange)
+		if err := rs.ms.Unmarshal(marshaled, cc); err != nil {
+			return nil, err
+		}
+		cc.Version = version
+		changes = append(changes, cc)
+	}
+	return changes, nil
+}
+
+func (rs *RedisStorage) GetCacheVersion() (int64, error) {
+	r := rs.db.Cmd("GET", utils.CACHE_VERSION_KEY)
+	if r.Err != nil {
+		return 0, r.Err
+	}
+	if r.IsType(redis.Nil) {
+		return 0, nil
+	}
+	version, err := r.Int()
+	return int64(version), err
+}
+
+func (rs *RedisStorage) SetCacheNodeVersion(nodeId string, nv *CacheNodeVersion) error {
+	marshaled, err := rs.ms.Marshal(nv)
+	if err != nil {
+		return err
+	}
+	return rs.db.Cmd("SET", utils.CACHE_NODE_PREFIX+nodeId, marshaled, "EX", int64(CACHE_NODE_TTL.Seconds())).Err
+}
+
+func (rs *RedisStorage) GetCacheNodeVersions() (map[string]*CacheNodeVersion, error) {
+	keys, err := rs.scanKeys(utils.CACHE_NODE_PREFIX + "*")
+	if err != nil {
+		return nil, err
+	}
+	nodeVersions := make(map[string]*CacheNodeVersion, len(keys))
+	for _, key := range keys {
+		marshaled, err := rs.getBytes(key)
+		if err == utils.ErrNotFound { // Removed meanwhile
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		nv := new(CacheNodeVersion)
+		if err := rs.ms.Unmarshal(marshaled, nv); err != nil {
+			return nil, err
+		}
+		nodeVersions[key[len(utils.CACHE_NODE_PREFIX):]] = nv
+	}
+	return nodeVersions, nil
+}
+
+// Iterates with SCAN so the server is not blocked as with KEYS, keys may be returned more than once
+// Checks if any key matches pattern, the scan stops at the first one found
+func (rs *RedisStorage) hasKeys(pattern string) (bool, error) {
+	cursor := "0"
+	for {
+		reply, err := rs.db.Cmd("SCAN", cursor, "MATCH", pattern, "COUNT", 100).Array()
+		if err != nil {
+			return false, err
+		}
+		if len(reply) != 2 {
+			return false, fmt.Errorf("unexpected SCAN reply length: %d", len(reply))
+		}
+		if cursor, err = reply[0].Str(); err != nil {
+			return false, err
+		}
+		if batch, err := reply[1].List(); err != nil {
+			return false, err
+		} else if len(batch) != 0 {
+			return true, nil
+		}
+		if cursor == "0" {
+			return false, nil
+		}
+	}
+}
+
+func (rs *RedisStorage) scanKeys(pattern string) ([]string, error) {
+	keys := make(map[string]struct{})
+	cursor := "0"
+	for {
+		reply, err := rs.db.Cmd("SCAN", cursor, "MATCH", pattern, "COUNT", 100).Array()
+		if err != nil {
+			return nil, err
+		}
+		if len(reply) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply length: %d", len(reply))
+		}
+		if cursor, err = reply[0].Str(); err != nil {
+			return nil, err
+		}
+		batch, err := reply[1].List()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range batch {
+			keys[key] = struct{}{}
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	result := make([]string, 0, len(keys))
+	for key := range keys {
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// Sends the published versions on versionChan until stopChan is closed, returns nil when stopped
+func (rs *RedisStorage) SubscribeCacheChanges(versionChan chan<- int64, stopChan <-chan struct{}) error {
+	client, err := rs.dial()
+	if err != nil {
+		return err
+	}
+	subClient := pubsub.NewSubClient(client)
+	if sr := subClient.Subscribe(utils.CACHE_CHANGES_CHANNEL); sr.Err != nil {
+		client.Close()
+		return sr.Err
+	}
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() { // Unblocks Receive on stop
+		select {
+		case <-stopChan:
+		case <-stopped:
+		}
+		client.Close()
+	}()
+	for {
+		sr := subClient.Receive()
+		select {
+		case <-stopChan:
+			return nil
+		default:
+		}
+		if sr.Err != nil {
+			if sr.Timeout() {
+				continue
+			}
+			return sr.Err
+		}
+		if sr.Type != pubsub.Message {
+			continue
+		}
+		version, err := strconv.ParseInt(sr.Message, 10, 64)
+		if err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<RedisStorage> Invalid cache version notification: %s", sr.Message))
+			continue
+		}
+		select {
+		case versionChan <- version:
+		case <-stopChan:
+			return nil
+		}
+	}
+}
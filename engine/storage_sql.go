@@ -567,8 +567,12 @@ func (self *SQLStorage) LogCallCost(cgrid, source, runid string, cc *CallCost) e
 }
 
 func (self *SQLStorage) GetCallCostLog(cgrid, source, runid string) (*CallCost, error) {
+	tblName, err := self.cdrTableFor(utils.TBL_COST_DETAILS, cgrid)
+	if err != nil {
+		return nil, err
+	}
 	var tpCostDetail TblCostDetail
-	if err := self.db.Where(&TblCostDetail{Cgrid: cgrid, Runid: runid, CostSource: source}).First(&tpCostDetail).Error; err != nil {
+	if err := self.db.Table(tblName).Where(&TblCostDetail{Cgrid: cgrid, Runid: runid, CostSource: source}).First(&tpCostDetail).Error; err != nil {
 		return nil, err
 	}
 	if len(tpCostDetail.Timespans) == 0 {
@@ -638,28 +642,14 @@ func (self *SQLStorage) SetCdr(cdr *StoredCdr) error {
 func (self *SQLStorage) SetRatedCdr(storedCdr *StoredCdr) error {
 	return utils.ErrNotImplemented
 }
-func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
-	var cdrs []*StoredCdr
-	// Select string
-	var selectStr string
-	if qryFltr.FilterOnRated { // We use different tables to query account data in case of derived
-		selectStr = fmt.Sprintf("%s.cgrid,%s.id,%s.tor,%s.accid,%s.cdrhost,%s.cdrsource,%s.reqtype,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.setup_time,%s.answer_time,%s.usage,%s.pdd,%s.supplier,%s.disconnect_cause,%s.extra_fields,%s.runid,%s.cost,%s.tor,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.cost,%s.timespans",
-			utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS,
-			utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS,
-			utils.TBL_CDRS_EXTRA, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS,
-			utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS)
-	} else {
-		selectStr = fmt.Sprintf("%s.cgrid,%s.id,%s.tor,%s.accid,%s.cdrhost,%s.cdrsource,%s.reqtype,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.setup_time,%s.answer_time,%s.usage,%s.pdd,%s.supplier,%s.disconnect_cause,%s.extra_fields,%s.runid,%s.cost,%s.tor,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.cost,%s.timespans",
-			utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY,
-			utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY,
-			utils.TBL_CDRS_EXTRA, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS,
-			utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS)
 
-	}
+// Builds the query over the joined CDR tables with suffix matching qryFltr, without select, paginator and count.
+// The data of a CDR is kept in the partition of its primary CDR so each partition is queried on its own, using its indexes.
+func (self *SQLStorage) cdrsQuery(qryFltr *utils.CdrsFilter, suffix string) *gorm.DB {
 	// Join string
-	joinStr := fmt.Sprintf("LEFT JOIN %s ON %s.cgrid=%s.cgrid LEFT JOIN %s ON %s.cgrid=%s.cgrid LEFT JOIN %s ON %s.cgrid=%s.cgrid AND %s.runid=%s.runid", utils.TBL_CDRS_EXTRA, utils.TBL_CDRS_PRIMARY,
-		utils.TBL_CDRS_EXTRA, utils.TBL_RATED_CDRS, utils.TBL_CDRS_PRIMARY, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS)
-	q := self.db.Table(utils.TBL_CDRS_PRIMARY).Select(selectStr).Joins(joinStr)
+	joinStr := fmt.Sprintf("LEFT JOIN %s ON %s.cgrid=%s.cgrid LEFT JOIN %s ON %s.cgrid=%s.cgrid LEFT JOIN %s ON %s.cgrid=%s.cgrid AND %s.runid=%s.runid", cdrTableSource(utils.TBL_CDRS_EXTRA, suffix), utils.TBL_CDRS_PRIMARY,
+		utils.TBL_CDRS_EXTRA, cdrTableSource(utils.TBL_RATED_CDRS, suffix), utils.TBL_CDRS_PRIMARY, utils.TBL_RATED_CDRS, cdrTableSource(utils.TBL_COST_DETAILS, suffix), utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS)
+	q := self.db.Table(cdrTableSource(utils.TBL_CDRS_PRIMARY, suffix)).Joins(joinStr)
 	if qryFltr.Unscoped {
 		q = q.Unscoped()
 	} else {
@@ -891,6 +881,9 @@ func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr,
 	if qryFltr.OrderIdEnd != 0 {
 		q = q.Where(utils.TBL_CDRS_PRIMARY+".id < ?", qryFltr.OrderIdEnd)
 	}
+	if len(qryFltr.ExportIds) != 0 {
+		q = q.Where(utils.TBL_CDRS_PRIMARY+".cgrid IN (SELECT cgrid FROM "+utils.TBL_CDRS_EXPORTS+" WHERE export_id IN (?))", qryFltr.ExportIds)
+	}
 	if qryFltr.SetupTimeStart != nil {
 		tblName := utils.TBL_CDRS_PRIMARY
 		if qryFltr.FilterOnRated {
@@ -992,26 +985,83 @@ func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr,
 			q = q.Where(fmt.Sprintf("( %s.cost IS NULL OR %s.cost < %f )", utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, *qryFltr.MaxCost))
 		}
 	}
-	if qryFltr.Paginator.Limit != nil {
-		q = q.Limit(*qryFltr.Paginator.Limit)
+	return q
+}
+
+func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
+	var cdrs []*StoredCdr
+	// Select string
+	var selectStr string
+	if qryFltr.FilterOnRated { // We use different tables to query account data in case of derived
+		selectStr = fmt.Sprintf("%s.cgrid,%s.id,%s.tor,%s.accid,%s.cdrhost,%s.cdrsource,%s.reqtype,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.setup_time,%s.answer_time,%s.usage,%s.pdd,%s.supplier,%s.disconnect_cause,%s.extra_fields,%s.runid,%s.cost,%s.tor,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.cost,%s.timespans",
+			utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS,
+			utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS,
+			utils.TBL_CDRS_EXTRA, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS,
+			utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS)
+	} else {
+		selectStr = fmt.Sprintf("%s.cgrid,%s.id,%s.tor,%s.accid,%s.cdrhost,%s.cdrsource,%s.reqtype,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.setup_time,%s.answer_time,%s.usage,%s.pdd,%s.supplier,%s.disconnect_cause,%s.extra_fields,%s.runid,%s.cost,%s.tor,%s.direction,%s.tenant,%s.category,%s.account,%s.subject,%s.destination,%s.cost,%s.timespans",
+			utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY,
+			utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_PRIMARY,
+			utils.TBL_CDRS_EXTRA, utils.TBL_RATED_CDRS, utils.TBL_RATED_CDRS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS,
+			utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS, utils.TBL_COST_DETAILS)
+
 	}
-	if qryFltr.Paginator.Offset != nil {
-		q = q.Offset(*qryFltr.Paginator.Offset)
+	suffixes, err := self.cdrsQuerySuffixes(qryFltr)
+	if err != nil {
+		return nil, 0, err
 	}
 	if qryFltr.Count {
 		var cnt int64
-		if err := q.Count(&cnt).Error; err != nil {
-			//if err := q.Debug().Count(&cnt).Error; err != nil {
-			return nil, 0, err
+		for _, suffix := range suffixes {
+			var partCnt int64
+			if err := self.cdrsQuery(qryFltr, suffix).Count(&partCnt).Error; err != nil {
+				return nil, 0, err
+			}
+			cnt += partCnt
 		}
 		return nil, cnt, nil
 	}
+	var offset int
+	if qryFltr.Paginator.Offset != nil {
+		offset = *qryFltr.Paginator.Offset
+	}
+	for _, suffix := range suffixes { // Partitions hold older ids than the CDR tables, queried in this order the ids stay sorted
+		if qryFltr.Paginator.Limit != nil && len(cdrs) >= *qryFltr.Paginator.Limit {
+			break
+		}
+		q := self.cdrsQuery(qryFltr, suffix).Select(selectStr)
+		if offset != 0 { // Skip the partitions falling within the offset
+			var partCnt int64
+			if err := self.cdrsQuery(qryFltr, suffix).Count(&partCnt).Error; err != nil {
+				return nil, 0, err
+			}
+			if partCnt <= int64(offset) {
+				offset -= int(partCnt)
+				continue
+			}
+			q = q.Offset(offset)
+			offset = 0
+		}
+		if qryFltr.Paginator.Limit != nil { // Stable order so we can page through results
+			q = q.Limit(*qryFltr.Paginator.Limit - len(cdrs)).Order(utils.TBL_CDRS_PRIMARY + ".id")
+		}
+		partCdrs, err := scanStoredCdrs(q)
+		if err != nil {
+			return nil, 0, err
+		}
+		cdrs = append(cdrs, partCdrs...)
+	}
+	return cdrs, 0, nil
+}
 
-	// Execute query
+// Executes the query selecting the CDRs the way GetStoredCdrs does
+func scanStoredCdrs(q *gorm.DB) ([]*StoredCdr, error) {
 	rows, err := q.Rows()
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
+	defer rows.Close()
+	var cdrs []*StoredCdr
 	for rows.Next() {
 		var cgrid, tor, accid, cdrhost, cdrsrc, reqtype, direction, tenant, category, account, subject, destination, runid, ccTor,
 			ccDirection, ccTenant, ccCategory, ccAccount, ccSubject, ccDestination, ccSupplier, ccDisconnectCause sql.NullString
@@ -1024,16 +1074,16 @@ func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr,
 		if err := rows.Scan(&cgrid, &orderid, &tor, &accid, &cdrhost, &cdrsrc, &reqtype, &direction, &tenant, &category, &account, &subject, &destination,
 			&setupTime, &answerTime, &usage, &pdd, &ccSupplier, &ccDisconnectCause,
 			&extraFields, &runid, &cost, &ccTor, &ccDirection, &ccTenant, &ccCategory, &ccAccount, &ccSubject, &ccDestination, &ccCost, &ccTimespansBytes); err != nil {
-			return nil, 0, err
+			return nil, err
 		}
 		if len(extraFields) != 0 {
 			if err := json.Unmarshal(extraFields, &extraFieldsMp); err != nil {
-				return nil, 0, fmt.Errorf("JSON unmarshal error for cgrid: %s, runid: %v, error: %s", cgrid.String, runid.String, err.Error())
+				return nil, fmt.Errorf("JSON unmarshal error for cgrid: %s, runid: %v, error: %s", cgrid.String, runid.String, err.Error())
 			}
 		}
 		if len(ccTimespansBytes) != 0 {
 			if err := json.Unmarshal(ccTimespansBytes, &ccTimespans); err != nil {
-				return nil, 0, fmt.Errorf("JSON unmarshal callcost error for cgrid: %s, runid: %v, error: %s", cgrid.String, runid.String, err.Error())
+				return nil, fmt.Errorf("JSON unmarshal callcost error for cgrid: %s, runid: %v, error: %s", cgrid.String, runid.String, err.Error())
 			}
 		}
 		usageDur, _ := time.ParseDuration(strconv.FormatFloat(usage.Float64, 'f', -1, 64) + "s")
@@ -1054,7 +1104,12 @@ func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr,
 		}
 		cdrs = append(cdrs, storCdr)
 	}
-	return cdrs, 0, nil
+	return cdrs, rows.Err()
+}
+
+// Keyset pagination on the id of the primary CDRs, the cursor being the id the next page starts with
+func (self *SQLStorage) GetStoredCdrsPage(qryFltr *utils.CdrsFilter, cursor string, pageSize int) ([]*StoredCdr, string, error) {
+	return PageStoredCdrsOnOrderId(self.GetStoredCdrs, qryFltr, cursor, pageSize)
 }
 
 /*func (self *SQLStorage) GetStoredCdrs(qryFltr *utils.CdrsFilter) ([]*StoredCdr, int64, error) {
@@ -1389,8 +1444,19 @@ func (self *SQLStorage) RemStoredCdrs(cgrIds []string) error {
 	if len(cgrIds) == 0 {
 		return nil
 	}
+	partitions, err := self.cdrPartitions()
+	if err != nil {
+		return err
+	}
+	var tblNames []string
+	for _, tblName := range cdrTables {
+		tblNames = append(tblNames, tblName)
+		for _, suffix := range partitions {
+			tblNames = append(tblNames, tblName+"_"+suffix)
+		}
+	}
 	tx := self.db.Begin()
-	for _, tblName := range []string{utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_EXTRA, utils.TBL_COST_DETAILS, utils.TBL_RATED_CDRS} {
+	for _, tblName := range tblNames {
 		txI := tx.Table(tblName)
 		for idx, cgrId := range cgrIds {
 			if idx == 0 {
@@ -1408,6 +1474,98 @@ func (self *SQLStorage) RemStoredCdrs(cgrIds []string) error {
 	return nil
 }
 
+const SQL_EXPORT_MARKS_BATCH = 1000 // Export marks inserted with one query
+
+// Marks the CDRs with the export id, called once per exported page; CDRs already marked by the same export are skipped so re-runs do not duplicate them
+func (self *SQLStorage) SetCdrsExportId(exportId string, cgrIds []string) error {
+	if len(cgrIds) == 0 {
+		return nil
+	}
+	tx := self.db.Begin()
+	isMarked := make(map[string]bool)
+	now := time.Now()
+	for batchStart := 0; batchStart < len(cgrIds); batchStart += SQL_EXPORT_MARKS_BATCH {
+		batchEnd := batchStart + SQL_EXPORT_MARKS_BATCH
+		if batchEnd > len(cgrIds) {
+			batchEnd = len(cgrIds)
+		}
+		batch := cgrIds[batchStart:batchEnd]
+		var marked []TblCdrsExport
+		if err := tx.Where("export_id = ? AND cgrid IN (?)", exportId, batch).Find(&marked).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, mark := range marked {
+			isMarked[mark.Cgrid] = true
+		}
+		var values []string
+		var args []interface{}
+		for _, cgrId := range batch {
+			if isMarked[cgrId] {
+				continue
+			}
+			isMarked[cgrId] = true // Derived CDRs share the CGRID
+			values = append(values, "(?,?,?)")
+			args = append(args, cgrId, exportId, now)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf("INSERT INTO %s (cgrid,export_id,created_at) VALUES %s", utils.TBL_CDRS_EXPORTS, strings.Join(values, ",")), args...).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (self *SQLStorage) SetCdreRun(run *CdreRun) error {
+	tblRun := &TblCdreRun{
+		JobId:            run.JobId,
+		ExportId:         run.ExportId,
+		OrderIdStart:     run.OrderIdStart,
+		LastOrderId:      run.LastOrderId,
+		ExportedFilePath: run.ExportedFilePath,
+		TotalRecords:     run.TotalRecords,
+		FailedRecords:    run.FailedRecords,
+		TotalCost:        run.TotalCost,
+		StartTime:        run.StartTime,
+		EndTime:          run.EndTime,
+		Error:            run.Error,
+	}
+	var existing TblCdreRun
+	if err := self.db.Where(&TblCdreRun{ExportId: run.ExportId}).First(&existing).Error; err == nil { // Re-run, update it
+		tblRun.Id = existing.Id
+	}
+	return self.db.Save(tblRun).Error
+}
+
+func (self *SQLStorage) GetCdreRuns(jobId string, pag *utils.Paginator) ([]*CdreRun, error) {
+	var tblRuns []TblCdreRun
+	q := self.db.Order("start_time")
+	if len(jobId) != 0 {
+		q = q.Where("job_id = ?", jobId)
+	}
+	if pag != nil {
+		if pag.Limit != nil {
+			q = q.Limit(*pag.Limit)
+		}
+		if pag.Offset != nil {
+			q = q.Offset(*pag.Offset)
+		}
+	}
+	if err := q.Find(&tblRuns).Error; err != nil {
+		return nil, err
+	}
+	runs := make([]*CdreRun, len(tblRuns))
+	for i, tblRun := range tblRuns {
+		runs[i] = &CdreRun{JobId: tblRun.JobId, ExportId: tblRun.ExportId, OrderIdStart: tblRun.OrderIdStart, LastOrderId: tblRun.LastOrderId,
+			ExportedFilePath: tblRun.ExportedFilePath, TotalRecords: tblRun.TotalRecords, FailedRecords: tblRun.FailedRecords, TotalCost: tblRun.TotalCost,
+			StartTime: tblRun.StartTime, EndTime: tblRun.EndTime, Error: tblRun.Error}
+	}
+	return runs, nil
+}
+
 func (self *SQLStorage) GetTpDestinations(tpid, tag string) ([]TpDestination, error) {
 	var tpDests []TpDestination
 	q := self.db.Where("tpid = ?", tpid)
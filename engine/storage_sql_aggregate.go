@@ -0,0 +1,144 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+	"github.com/jinzhu/gorm"
+)
+
+const CDRS_BUCKET_LAYOUT = "2006-01-02 15:04:05"
+
+// Expression truncating the answer time to the start of its bucket, formatted as CDRS_BUCKET_LAYOUT
+func (self *SQLStorage) cdrsTimeBucketExpr(answerTimeCol, timeBucket string) (string, error) {
+	var mysqlFmt, psqlFmt string
+	switch timeBucket {
+	case utils.META_HOURLY:
+		mysqlFmt, psqlFmt = "%Y-%m-%d %H:00:00", "YYYY-MM-DD HH24:00:00"
+	case utils.META_DAILY:
+		mysqlFmt, psqlFmt = "%Y-%m-%d 00:00:00", "YYYY-MM-DD 00:00:00"
+	case utils.META_MONTHLY:
+		mysqlFmt, psqlFmt = "%Y-%m-01 00:00:00", "YYYY-MM-01 00:00:00"
+	}
+	switch self.db.Dialect().GetName() {
+	case "mysql":
+		return "DATE_FORMAT(" + answerTimeCol + ",'" + mysqlFmt + "')", nil
+	case "postgres":
+		return "to_char(" + answerTimeCol + ",'" + psqlFmt + "')", nil
+	case "sqlite3":
+		return "strftime('" + mysqlFmt + "'," + answerTimeCol + ")", nil
+	}
+	return "", errors.New("Unsupported dialect: " + self.db.Dialect().GetName())
+}
+
+// Counts and sums up usage and cost of the CDRs matching qryFltr with GROUP BY on the fields and the time bucket
+func (self *SQLStorage) GetCdrsAggregates(qryFltr *utils.CdrsFilter, groupBy []string, timeBucket string) ([]*CdrsAggregate, error) {
+	if err := CheckCdrsAggregateGroupBy(groupBy, timeBucket); err != nil {
+		return nil, err
+	}
+	qryFltr = cdrsAggregateFilter(qryFltr, groupBy)
+	cdrsTbl := utils.TBL_CDRS_PRIMARY
+	if qryFltr.FilterOnRated { // Same data as returned by GetStoredCdrs
+		cdrsTbl = utils.TBL_RATED_CDRS
+	}
+	var groupCols []string
+	for _, fld := range groupBy {
+		switch fld {
+		case utils.TENANT:
+			groupCols = append(groupCols, cdrsTbl+".tenant")
+		case utils.ACCOUNT:
+			groupCols = append(groupCols, cdrsTbl+".account")
+		case utils.DESTINATION_ID:
+			groupCols = append(groupCols, utils.TBL_COST_DETAILS+".destination_id")
+		case utils.SUPPLIER:
+			groupCols = append(groupCols, cdrsTbl+".supplier")
+		case utils.MEDI_RUNID:
+			groupCols = append(groupCols, utils.TBL_RATED_CDRS+".runid")
+		}
+	}
+	if timeBucket != "" {
+		bucketExpr, err := self.cdrsTimeBucketExpr(cdrsTbl+".answer_time", timeBucket)
+		if err != nil {
+			return nil, err
+		}
+		groupCols = append(groupCols, bucketExpr)
+	}
+	suffixes, err := self.cdrsQuerySuffixes(qryFltr)
+	if err != nil {
+		return nil, err
+	}
+	selectCols := append(append([]string{}, groupCols...), "COUNT(DISTINCT "+utils.TBL_CDRS_PRIMARY+".cgrid)", "SUM("+cdrsTbl+".usage)",
+		"SUM(CASE WHEN "+utils.TBL_RATED_CDRS+".cost >= 0 THEN "+utils.TBL_RATED_CDRS+".cost ELSE 0 END)") // Errors are stored with negative cost
+	var aggrs []*CdrsAggregate
+	for _, suffix := range suffixes {
+		q := self.cdrsQuery(qryFltr, suffix).Select(strings.Join(selectCols, ","))
+		if len(groupCols) != 0 {
+			q = q.Group(strings.Join(groupCols, ","))
+		}
+		partAggrs, err := scanCdrsAggregates(q, groupBy, timeBucket)
+		if err != nil {
+			return nil, err
+		}
+		aggrs = append(aggrs, partAggrs...)
+	}
+	return mergeCdrsAggregates(aggrs, groupBy), nil
+}
+
+// Executes the aggregation query, with the group columns followed by count, usage and cost
+func scanCdrsAggregates(q *gorm.DB, groupBy []string, timeBucket string) ([]*CdrsAggregate, error) {
+	groupLen := len(groupBy)
+	if timeBucket != "" {
+		groupLen++
+	}
+	rows, err := q.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var aggrs []*CdrsAggregate
+	for rows.Next() {
+		groupVals := make([]sql.NullString, groupLen)
+		var cnt int64
+		var usage, cost sql.NullFloat64
+		scanDest := make([]interface{}, groupLen, groupLen+3)
+		for idx := range groupVals {
+			scanDest[idx] = &groupVals[idx]
+		}
+		if err := rows.Scan(append(scanDest, &cnt, &usage, &cost)...); err != nil {
+			return nil, err
+		}
+		aggr := &CdrsAggregate{GroupValues: make(map[string]string), Count: cnt, Cost: cost.Float64,
+			Usage: time.Duration(usage.Float64 * float64(time.Second))}
+		for idx, fld := range groupBy {
+			aggr.GroupValues[fld] = groupVals[idx].String
+		}
+		if timeBucket != "" && groupVals[len(groupBy)].Valid {
+			if aggr.TimeBucket, err = time.ParseInLocation(CDRS_BUCKET_LAYOUT, groupVals[len(groupBy)].String, time.UTC); err != nil {
+				return nil, err
+			}
+		}
+		aggrs = append(aggrs, aggr)
+	}
+	return aggrs, rows.Err()
+}
@@ -0,0 +1,305 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+	"github.com/jinzhu/gorm"
+)
+
+const CDRS_PARTITION_LAYOUT = "200601" // Suffix of the CDR partitions, out of the month the CDRs were stored in
+
+// Tables with the data of one CDR, partitioned together on the month the primary CDR was stored in
+var cdrTables = []string{utils.TBL_CDRS_PRIMARY, utils.TBL_CDRS_EXTRA, utils.TBL_COST_DETAILS, utils.TBL_RATED_CDRS}
+
+// First moment of the month t is in
+func cdrsPartitionMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Partitions possibly holding CDRs matching the filter, the rest are left out of the queries
+func filterCdrPartitions(partitions []string, qryFltr *utils.CdrsFilter) (fltrd []string) {
+	if qryFltr.FilterOnRated { // CreatedAt refers to the rating time then, not to the time the CDR was stored
+		return partitions
+	}
+	for _, suffix := range partitions {
+		month, err := time.ParseInLocation(CDRS_PARTITION_LAYOUT, suffix, time.Local)
+		if err != nil {
+			continue
+		}
+		if qryFltr.CreatedAtStart != nil && !qryFltr.CreatedAtStart.IsZero() && !month.AddDate(0, 1, 0).After(*qryFltr.CreatedAtStart) {
+			continue
+		}
+		if qryFltr.CreatedAtEnd != nil && !qryFltr.CreatedAtEnd.IsZero() && !month.Before(*qryFltr.CreatedAtEnd) {
+			continue
+		}
+		fltrd = append(fltrd, suffix)
+	}
+	return
+}
+
+// Source of a CDR table inside the queries on one partition, aliased to the table name so the columns can be referenced as usual
+func cdrTableSource(tblName, suffix string) string {
+	if len(suffix) == 0 {
+		return tblName
+	}
+	return fmt.Sprintf("%s_%s AS %s", tblName, suffix, tblName)
+}
+
+// Suffixes of the tables to query for the filter, the partitions oldest first followed by the CDR tables themselves (empty suffix)
+func (self *SQLStorage) cdrsQuerySuffixes(qryFltr *utils.CdrsFilter) ([]string, error) {
+	partitions, err := self.cdrPartitions()
+	if err != nil {
+		return nil, err
+	}
+	return append(filterCdrPartitions(partitions, qryFltr), ""), nil
+}
+
+// Table the data of the CDR with cgrid goes into, the partition its primary CDR was rolled into if any
+func (self *SQLStorage) cdrTableFor(tblName, cgrid string) (string, error) {
+	partitions, err := self.cdrPartitions()
+	if err != nil {
+		return "", err
+	}
+	if len(partitions) == 0 {
+		return tblName, nil
+	}
+	var cnt int64
+	if err := self.db.Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE cgrid=?", utils.TBL_CDRS_PRIMARY), cgrid).Row().Scan(&cnt); err != nil {
+		return "", err
+	}
+	if cnt != 0 {
+		return tblName, nil
+	}
+	for idx := len(partitions) - 1; idx >= 0; idx-- { // Most recent partitions are the likeliest to be re-rated
+		if err := self.db.Raw(fmt.Sprintf("SELECT COUNT(*) FROM %s_%s WHERE cgrid=?", utils.TBL_CDRS_PRIMARY, partitions[idx]), cgrid).Row().Scan(&cnt); err != nil {
+			return "", err
+		}
+		if cnt != 0 {
+			return tblName + "_" + partitions[idx], nil
+		}
+	}
+	return tblName, nil
+}
+
+// Suffixes of the existing CDR partitions, oldest first
+func (self *SQLStorage) cdrPartitions() ([]string, error) {
+	var qry string
+	switch self.db.Dialect().GetName() {
+	case "mysql":
+		qry = "SELECT table_name FROM information_schema.tables WHERE table_schema=DATABASE() AND table_name LIKE ?"
+	case "postgres":
+		qry = "SELECT table_name FROM information_schema.tables WHERE table_schema=current_schema() AND table_name LIKE ?"
+	case "sqlite3":
+		qry = "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE ?"
+	default:
+		return nil, utils.ErrNotImplemented
+	}
+	rows, err := self.db.Raw(qry, utils.TBL_CDRS_PRIMARY+"_%").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var partitions []string
+	for rows.Next() {
+		var tblName string
+		if err := rows.Scan(&tblName); err != nil {
+			return nil, err
+		}
+		suffix := strings.TrimPrefix(tblName, utils.TBL_CDRS_PRIMARY+"_")
+		if _, err := time.Parse(CDRS_PARTITION_LAYOUT, suffix); err == nil {
+			partitions = append(partitions, suffix)
+		}
+	}
+	sort.Strings(partitions)
+	return partitions, rows.Err()
+}
+
+// Creates the partition tables with the structure of the CDR tables, only the indexes are taken over
+func (self *SQLStorage) createCdrPartition(suffix string) error {
+	for _, tblName := range cdrTables {
+		partName := tblName + "_" + suffix
+		var qry string
+		switch self.db.Dialect().GetName() {
+		case "mysql":
+			qry = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", partName, tblName)
+		case "postgres": // Without defaults so the partition does not depend on the id sequence of the table
+			qry = fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING INDEXES)", partName, tblName)
+		case "sqlite3":
+			var tblSql string
+			if err := self.Db.QueryRow("SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tblName).Scan(&tblSql); err != nil {
+				return err
+			}
+			qry = strings.Replace(tblSql, "CREATE TABLE "+tblName, "CREATE TABLE IF NOT EXISTS "+partName, 1)
+		default:
+			return utils.ErrNotImplemented
+		}
+		if err := self.db.Exec(qry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Drops all CDR partitions, used when flushing the database
+func (self *SQLStorage) dropCdrPartitions() error {
+	partitions, err := self.cdrPartitions()
+	if err != nil {
+		return err
+	}
+	for _, suffix := range partitions {
+		for _, tblName := range cdrTables {
+			if err := self.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s_%s", tblName, suffix)).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Moves the CDRs stored before the month of storedBefore out of the CDR tables into their monthly partitions, together with their data.
+// Rating data written later for them goes straight into the partition of its CDR.
+func (self *SQLStorage) RollCdrPartitions(storedBefore time.Time) error {
+	before := cdrsPartitionMonth(storedBefore)
+	for {
+		var createdAt *time.Time // Stays nil once no CDRs are left to roll
+		if err := self.db.Raw(fmt.Sprintf("SELECT created_at FROM %s WHERE created_at < ? ORDER BY created_at LIMIT 1", utils.TBL_CDRS_PRIMARY),
+			before).Row().Scan(&createdAt); err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if createdAt == nil {
+			break
+		}
+		month := cdrsPartitionMonth(createdAt.In(before.Location()))
+		suffix := month.Format(CDRS_PARTITION_LAYOUT)
+		if err := self.createCdrPartition(suffix); err != nil {
+			return err
+		}
+		tx := self.db.Begin()
+		if err := tx.Exec(fmt.Sprintf("INSERT INTO %s_%s SELECT * FROM %s WHERE created_at >= ? AND created_at < ?", utils.TBL_CDRS_PRIMARY, suffix, utils.TBL_CDRS_PRIMARY),
+			month, month.AddDate(0, 1, 0)).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := rollCdrDependents(tx, suffix); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE created_at >= ? AND created_at < ?", utils.TBL_CDRS_PRIMARY), month, month.AddDate(0, 1, 0)).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	partitions, err := self.cdrPartitions()
+	if err != nil {
+		return err
+	}
+	for _, suffix := range partitions { // Data written into the CDR tables while its CDR was being rolled
+		tx := self.db.Begin()
+		if err := rollCdrDependents(tx, suffix); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Moves the data left in the CDR tables into the partition holding its primary CDR, replacing older versions of it
+func rollCdrDependents(tx *gorm.DB, suffix string) error {
+	for _, tblName := range cdrTables[1:] {
+		partName := tblName + "_" + suffix
+		inPartition := fmt.Sprintf("%s.cgrid IN (SELECT cgrid FROM %s_%s)", tblName, utils.TBL_CDRS_PRIMARY, suffix)
+		sameData := fmt.Sprintf("%s.cgrid=%s.cgrid", tblName, partName)
+		if tblName != utils.TBL_CDRS_EXTRA {
+			sameData += fmt.Sprintf(" AND %s.runid=%s.runid", tblName, partName)
+		}
+		for _, qry := range []string{
+			fmt.Sprintf("DELETE FROM %s WHERE EXISTS (SELECT 1 FROM %s WHERE %s AND %s)", partName, tblName, inPartition, sameData),
+			fmt.Sprintf("INSERT INTO %s SELECT * FROM %s WHERE %s", partName, tblName, inPartition),
+			fmt.Sprintf("DELETE FROM %s WHERE %s", tblName, inPartition),
+		} {
+			if err := tx.Exec(qry).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Removes the CDRs stored before storedBefore together with their data, partitions expired as a whole are dropped
+func (self *SQLStorage) PurgeCdrs(storedBefore time.Time) error {
+	partitions, err := self.cdrPartitions()
+	if err != nil {
+		return err
+	}
+	for _, suffix := range partitions {
+		month, err := time.ParseInLocation(CDRS_PARTITION_LAYOUT, suffix, storedBefore.Location())
+		if err != nil {
+			return err
+		}
+		if !month.Before(storedBefore) {
+			continue
+		}
+		if !month.AddDate(0, 1, 0).After(storedBefore) {
+			for _, tblName := range cdrTables {
+				if err := self.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s_%s", tblName, suffix)).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := self.purgeCdrTables("_"+suffix, storedBefore); err != nil {
+			return err
+		}
+	}
+	return self.purgeCdrTables("", storedBefore)
+}
+
+func (self *SQLStorage) purgeCdrTables(suffix string, storedBefore time.Time) error {
+	tx := self.db.Begin()
+	for _, tblName := range cdrTables[1:] {
+		qry := fmt.Sprintf("DELETE FROM %s%s WHERE cgrid IN (SELECT cgrid FROM %s%s WHERE created_at < ?)", tblName, suffix, utils.TBL_CDRS_PRIMARY, suffix)
+		qryArgs := []interface{}{storedBefore}
+		if len(suffix) == 0 { // Data left behind by CDRs in dropped partitions
+			qry += fmt.Sprintf(" OR (created_at < ? AND cgrid NOT IN (SELECT cgrid FROM %s))", utils.TBL_CDRS_PRIMARY)
+			qryArgs = append(qryArgs, storedBefore)
+		}
+		if err := tx.Exec(qry, qryArgs...).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Exec(fmt.Sprintf("DELETE FROM %s%s WHERE created_at < ?", utils.TBL_CDRS_PRIMARY, suffix), storedBefore).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
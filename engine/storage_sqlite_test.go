@@ -0,0 +1,97 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package engine
+
+import (
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/utils"
+)
+
+// Runs against an in-memory database created out of the scripts shipped in data/storage/sqlite
+func TestSQLiteStorage(t *testing.T) {
+	sqliteDb, err := NewSQLiteStorage(SQLITE_MEMORY, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqliteDb.Close()
+	if err := sqliteDb.Flush(path.Join("..", "data", "storage", utils.SQLITE)); err != nil {
+		t.Fatal(err)
+	}
+	// Offline tariff plans
+	mtms := []TpTiming{*APItoModelTiming(&utils.ApierTPTiming{TPid: utils.TEST_SQL, TimingId: "ALWAYS", Time: "00:00:00"})}
+	if err := sqliteDb.SetTpTimings(mtms); err != nil {
+		t.Fatal(err)
+	}
+	if tmgs, err := sqliteDb.GetTpTimings(utils.TEST_SQL, "ALWAYS"); err != nil {
+		t.Error(err)
+	} else if len(tmgs) != 1 || !modelEqual(mtms[0], tmgs[0]) {
+		t.Errorf("Expecting: %+v, received: %+v", mtms, tmgs)
+	}
+	// CDRs
+	strCdr := &StoredCdr{TOR: utils.VOICE, AccId: "ccc1", CdrHost: "192.168.1.1", CdrSource: "TEST_CDR", ReqType: utils.META_RATED,
+		Direction: "*out", Tenant: "cgrates.org", Category: "call", Account: "1001", Subject: "1001", Destination: "1002",
+		SetupTime: time.Date(2013, 12, 7, 8, 42, 24, 0, time.UTC), AnswerTime: time.Date(2013, 12, 7, 8, 42, 26, 0, time.UTC),
+		Usage: time.Duration(10) * time.Second, Pdd: time.Duration(3) * time.Second, Supplier: "SUPPL1",
+		ExtraFields:    map[string]string{"field_extr1": "val_extr1", "fieldextr2": "valextr2"},
+		MediationRunId: utils.DEFAULT_RUNID, Cost: 1.201}
+	strCdr.CgrId = utils.Sha1(strCdr.AccId, strCdr.SetupTime.String())
+	if err := sqliteDb.SetCdr(strCdr); err != nil {
+		t.Fatal(err)
+	}
+	for _, cost := range []float64{0.5, strCdr.Cost} { // Second time updated
+		rtCdr := *strCdr
+		rtCdr.Cost = cost
+		if err := sqliteDb.SetRatedCdr(&rtCdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rcvCdrs, _, err := sqliteDb.GetStoredCdrs(&utils.CdrsFilter{CgrIds: []string{strCdr.CgrId}, FilterOnRated: true}); err != nil {
+		t.Error(err)
+	} else if len(rcvCdrs) != 1 {
+		t.Errorf("Unexpected cdrs returned: %+v", rcvCdrs)
+	} else if rcvCdr := rcvCdrs[0]; strCdr.AccId != rcvCdr.AccId || strCdr.Account != rcvCdr.Account || !strCdr.AnswerTime.Equal(rcvCdr.AnswerTime) ||
+		strCdr.Usage != rcvCdr.Usage || strCdr.Pdd != rcvCdr.Pdd || strCdr.Cost != rcvCdr.Cost || !reflect.DeepEqual(strCdr.ExtraFields, rcvCdr.ExtraFields) {
+		t.Errorf("Expecting: %+v, received: %+v", strCdr, rcvCdr)
+	}
+	cc := &CallCost{Direction: "*out", Category: "call", Tenant: "cgrates.org", Subject: "1001", Account: "1001", Destination: "1002", TOR: utils.VOICE,
+		Timespans: []*TimeSpan{&TimeSpan{TimeStart: time.Date(2013, 12, 7, 8, 42, 26, 0, time.UTC), TimeEnd: time.Date(2013, 12, 7, 8, 42, 36, 0, time.UTC)}}}
+	for _, category := range []string{"call", "premium_call"} { // Second time updated
+		cc.Category = category
+		if err := sqliteDb.LogCallCost(strCdr.CgrId, utils.TEST_SQL, utils.DEFAULT_RUNID, cc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ccRcv, err := sqliteDb.GetCallCostLog(strCdr.CgrId, utils.TEST_SQL, utils.DEFAULT_RUNID); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(cc, ccRcv) {
+		t.Errorf("Expecting call cost: %+v, received: %+v", cc, ccRcv)
+	}
+	if err := sqliteDb.RemStoredCdrs([]string{strCdr.CgrId}); err != nil {
+		t.Error(err)
+	}
+	if _, cnt, err := sqliteDb.GetStoredCdrs(&utils.CdrsFilter{Count: true}); err != nil {
+		t.Error(err)
+	} else if cnt != 0 {
+		t.Errorf("CDRs left: %d", cnt)
+	}
+}
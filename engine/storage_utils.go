@@ -22,6 +22,8 @@ import (
 	"errors"
 	"strconv"
 
+	"github.com/cgrates/cgrates/cache2go"
+	"github.com/cgrates/cgrates/config"
 	"github.com/cgrates/cgrates/utils"
 )
 
@@ -44,6 +46,8 @@ func ConfigureRatingStorage(db_type, host, port, name, user, pass, marshaler str
 	case utils.MONGO:
 		d, err = NewMongoStorage(host, port, name, user, pass)
 		db = d.(RatingStorage)
+	case utils.META_INTERNAL: // name is the folder to persist into, empty for memory only
+		d, err = NewMapStoragePersistent(name, config.TPDB_JSN, marshaler)
 	default:
 		err = errors.New("unknown db")
 	}
@@ -70,6 +74,8 @@ func ConfigureAccountingStorage(db_type, host, port, name, user, pass, marshaler
 	case utils.MONGO:
 		d, err = NewMongoStorage(host, port, name, user, pass)
 		db = d.(AccountingStorage)
+	case utils.META_INTERNAL: // name is the folder to persist into, empty for memory only
+		d, err = NewMapStoragePersistent(name, config.DATADB_JSN, marshaler)
 	default:
 		err = errors.New("unknown db")
 	}
@@ -101,6 +107,8 @@ func ConfigureLogStorage(db_type, host, port, name, user, pass, marshaler string
 		d, err = NewPostgresStorage(host, port, name, user, pass, maxConn, maxIdleConn)
 	case utils.MYSQL:
 		d, err = NewMySQLStorage(host, port, name, user, pass, maxConn, maxIdleConn)
+	case utils.SQLITE:
+		d, err = NewSQLiteStorage(name, maxConn, maxIdleConn)
 	default:
 		err = errors.New("unknown db")
 	}
@@ -117,6 +125,8 @@ func ConfigureLoadStorage(db_type, host, port, name, user, pass, marshaler strin
 		d, err = NewPostgresStorage(host, port, name, user, pass, maxConn, maxIdleConn)
 	case utils.MYSQL:
 		d, err = NewMySQLStorage(host, port, name, user, pass, maxConn, maxIdleConn)
+	case utils.SQLITE:
+		d, err = NewSQLiteStorage(name, maxConn, maxIdleConn)
 	case utils.MONGO:
 		d, err = NewMongoStorage(host, port, name, user, pass)
 	default:
@@ -135,6 +145,8 @@ func ConfigureCdrStorage(db_type, host, port, name, user, pass string, maxConn,
 		d, err = NewPostgresStorage(host, port, name, user, pass, maxConn, maxIdleConn)
 	case utils.MYSQL:
 		d, err = NewMySQLStorage(host, port, name, user, pass, maxConn, maxIdleConn)
+	case utils.SQLITE:
+		d, err = NewSQLiteStorage(name, maxConn, maxIdleConn)
 	case utils.MONGO:
 		d, err = NewMongoStorage(host, port, name, user, pass)
 	default:
@@ -145,3 +157,31 @@ func ConfigureCdrStorage(db_type, host, port, name, user, pass string, maxConn,
 	}
 	return d, nil
 }
+
+// Partially cached prefixes are only dropped from cache on reload, the storages load them back on first use.
+// Returns the keys still to be cached.
+func invalidatePartialCache(prefix string, keys []string, tx *cache2go.Transaction) []string {
+	if !cache2go.IsPartial(prefix) {
+		return keys
+	}
+	if keys == nil {
+		tx.RemPrefixKey(prefix)
+	} else {
+		for _, key := range keys {
+			tx.RemKey(key)
+		}
+	}
+	return []string{}
+}
+
+// Destinations are cached on their prefixes so with partial cache all of them are dropped on reload, to be loaded back on first use.
+// Returns the destination keys still to be cached.
+func invalidatePartialDestinations(dKeys []string, tx *cache2go.Transaction) []string {
+	if !cache2go.IsPartial(utils.DESTINATION_PREFIX) {
+		return dKeys
+	}
+	if dKeys == nil || len(dKeys) != 0 {
+		tx.RemPrefixKey(utils.DESTINATION_PREFIX)
+	}
+	return []string{}
+}
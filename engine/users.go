@@ -403,7 +403,7 @@ func (um *UserMap) GetIndexes(in string, reply *map[string][]string) error {
 }
 
 type ProxyUserService struct {
-	Client *rpcclient.RpcClient
+	Client rpcclient.RpcClientConnection
 }
 
 func NewProxyUserService(addr string, attempts, reconnects int) (*ProxyUserService, error) {
@@ -24,7 +24,7 @@ import (
 )
 
 type ProxyScribe struct {
-	Client *rpcclient.RpcClient
+	Client rpcclient.RpcClientConnection
 }
 
 func NewProxyScribe(addr string, attempts, reconnects int) (*ProxyScribe, error) {
@@ -64,6 +64,8 @@ const (
 	UNAUTHORIZED_DESTINATION = "-UNAUTHORIZED_DESTINATION"
 	MISSING_PARAMETER        = "-MISSING_PARAMETER"
 	SYSTEM_ERROR             = "-SYSTEM_ERROR"
+	LOW_BALANCE              = "-LOW_BALANCE"
+	FINAL_UNITS              = "-FINAL_UNITS"
 	MANAGER_REQUEST          = "+MANAGER_REQUEST"
 	USERNAME                 = "Caller-Username"
 	FS_IPv4                  = "FreeSWITCH-IPv4"
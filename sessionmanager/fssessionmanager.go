@@ -33,7 +33,7 @@ import (
 )
 
 func NewFSSessionManager(smFsConfig *config.SmFsConfig, rater, cdrs engine.Connector, timezone string) *FSSessionManager {
-	return &FSSessionManager{
+	sm := &FSSessionManager{
 		cfg:         smFsConfig,
 		conns:       make(map[string]*fsock.FSock),
 		senderPools: make(map[string]*fsock.FSockPool),
@@ -42,6 +42,8 @@ func NewFSSessionManager(smFsConfig *config.SmFsConfig, rater, cdrs engine.Conne
 		sessions:    NewSessions(),
 		timezone:    timezone,
 	}
+	registerSessionsMetrics("SM-FreeSWITCH", func() int { return len(sm.Sessions()) })
+	return sm
 }
 
 // The freeswitch session manager type holding a buffer for the network connection
@@ -33,6 +33,7 @@ import (
 
 func NewKamailioSessionManager(smKamCfg *config.SmKamConfig, rater, cdrsrv engine.Connector, timezone string) (*KamailioSessionManager, error) {
 	ksm := &KamailioSessionManager{cfg: smKamCfg, rater: rater, cdrsrv: cdrsrv, timezone: timezone, conns: make(map[string]*kamevapi.KamEvapi), sessions: NewSessions()}
+	registerSessionsMetrics("SM-Kamailio", func() int { return len(ksm.Sessions()) })
 	return ksm, nil
 }
 
@@ -98,6 +98,7 @@ func (mc *MockConnector) GetDerivedChargers(*utils.AttrDerivedChargers, *utils.D
 func (mc *MockConnector) GetDerivedMaxSessionTime(*engine.StoredCdr, *float64) error    { return nil }
 func (mc *MockConnector) GetSessionRuns(*engine.StoredCdr, *[]*engine.SessionRun) error { return nil }
 func (mc *MockConnector) ProcessCdr(*engine.StoredCdr, *string) error                   { return nil }
+func (mc *MockConnector) ProcessCdrs([]*engine.StoredCdr, *[]string) error              { return nil }
 func (mc *MockConnector) LogCallCost(*engine.CallCostLog, *string) error                { return nil }
 func (mc *MockConnector) GetLCR(*engine.AttrGetLcr, *engine.LCRCost) error              { return nil }
 func (mc *MockConnector) GetTimeout(int, *time.Duration) error                          { return nil }
@@ -22,8 +22,18 @@ import (
 	"time"
 
 	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
 )
 
+var activeSessions = utils.Metrics.Gauge("cgr_active_sessions", "Sessions active, per session manager.", "session_manager")
+
+// Exposes the number of sessions returned by countSessions on /metrics
+func registerSessionsMetrics(smName string, countSessions func() int) {
+	activeSessions.AddCollector(func() []*utils.MetricSample {
+		return []*utils.MetricSample{&utils.MetricSample{LabelValues: []string{smName}, Value: float64(countSessions())}}
+	})
+}
+
 type SessionManager interface {
 	Rater() engine.Connector
 	CdrSrv() engine.Connector
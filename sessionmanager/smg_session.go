@@ -21,8 +21,10 @@ package sessionmanager
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/cgrates/cgrates/config"
 	"github.com/cgrates/cgrates/engine"
 	"github.com/cgrates/cgrates/utils"
 )
@@ -34,13 +36,16 @@ type SMGSession struct {
 	connId        string         // Reference towards connection id on the session manager side.
 	runId         string         // Keep a reference for the derived run
 	timezone      string
-	rater         engine.Connector // Connector to Rater service
-	cdrsrv        engine.Connector // Connector to CDRS service
+	cgrCfg        *config.CGRConfig
+	rater         engine.Connector           // Connector to Rater service
+	cdrsrv        engine.Connector           // Connector to CDRS service
+	pubsub        engine.PublisherSubscriber // Connector to PubSub service, nil if not configured
 	extconns      *SMGExternalConnections
 	cd            *engine.CallDescriptor
 	sessionCds    []*engine.CallDescriptor
 	callCosts     []*engine.CallCost
 	extraDuration time.Duration // keeps the current duration debited on top of what heas been asked
+	lowBalWarned  bool          // low balance warning was already sent for this session
 }
 
 // Called in case of automatic debits
@@ -63,12 +68,14 @@ func (self *SMGSession) debitLoop(debitInterval time.Duration) {
 			}
 			return
 		} else if maxDebit < debitInterval {
+			self.warnSession(FINAL_UNITS, maxDebit, 0)
 			time.Sleep(maxDebit)
 			if err := self.disconnectSession(INSUFFICIENT_FUNDS); err != nil {
 				utils.Logger.Err(fmt.Sprintf("<SMGeneric> Could not disconnect session: %s, error: %s", self.eventStart.GetUUID(), err.Error()))
 			}
 			return
 		}
+		self.checkLowBalance(self.callCosts[len(self.callCosts)-1])
 		time.Sleep(debitInterval)
 		loopIndex++
 	}
@@ -175,6 +182,68 @@ func (self *SMGSession) close(endTime time.Time) error {
 	return nil
 }
 
+// Warns the session once when the credit left after the last debit goes under configured thresholds
+func (self *SMGSession) checkLowBalance(cc *engine.CallCost) {
+	if self.lowBalWarned || self.cgrCfg == nil || cc == nil {
+		return
+	}
+	minDur, minCost := self.cgrCfg.SmGenericConfig.MinDurLowBalance, self.cgrCfg.SmGenericConfig.MinCostLowBalance
+	if minDur == 0 && minCost == 0 {
+		return
+	}
+	remainingUsage := cc.RemainingUsage
+	usageKnown := true
+	if ccDur := cc.GetDuration(); cc.Cost > 0 && ccDur > 0 { // Translate the monetary credit into usage at the rate of the last debit
+		remainingUsage += time.Duration(cc.RemainingCredit / cc.Cost * float64(ccDur))
+	} else if cc.RemainingCredit > 0 { // Debited out of free units, credit left cannot be translated into usage
+		usageKnown = false
+	}
+	if (minDur == 0 || !usageKnown || remainingUsage >= minDur) && (minCost == 0 || cc.RemainingCredit >= minCost) {
+		return
+	}
+	self.lowBalWarned = true
+	self.warnSession(LOW_BALANCE, remainingUsage, cc.RemainingCredit)
+}
+
+// Notifies the remote connection and the PubSub subscribers about the credit state of the session
+func (self *SMGSession) warnSession(reason string, remainingUsage time.Duration, remainingCost float64) {
+	type AttrWarnSession struct {
+		EventStart     map[string]interface{}
+		Reason         string
+		RemainingUsage float64 // Seconds
+		RemainingCost  float64
+	}
+	if conn := self.extconns.GetConnection(self.connId); conn != nil {
+		var reply string
+		if err := conn.Call("SMGClientV1.WarnSession", AttrWarnSession{EventStart: self.eventStart, Reason: reason,
+			RemainingUsage: remainingUsage.Seconds(), RemainingCost: remainingCost}, &reply); err != nil {
+			utils.Logger.Warning(fmt.Sprintf("<SMGeneric> Could not warn session: %s, error: %s", self.eventStart.GetUUID(), err.Error()))
+		}
+	}
+	if self.pubsub == nil {
+		return
+	}
+	evName := utils.EVT_SESSION_LOW_BALANCE
+	if reason == FINAL_UNITS {
+		evName = utils.EVT_SESSION_FINAL_UNITS
+	}
+	var reply string
+	if err := self.pubsub.Publish(engine.CgrEvent{
+		"EventName":      evName,
+		"CgrId":          self.eventStart.GetCgrId(self.timezone),
+		"AccId":          self.eventStart.GetUUID(),
+		"RunId":          self.runId,
+		"Tenant":         self.cd.Tenant,
+		"Account":        self.cd.Account,
+		"Subject":        self.cd.Subject,
+		"Destination":    self.cd.Destination,
+		"RemainingUsage": strconv.FormatFloat(remainingUsage.Seconds(), 'f', -1, 64),
+		"RemainingCost":  strconv.FormatFloat(remainingCost, 'f', -1, 64),
+	}, &reply); err != nil {
+		utils.Logger.Warning(fmt.Sprintf("<SMGeneric> Could not publish warning for session: %s, error: %s", self.eventStart.GetUUID(), err.Error()))
+	}
+}
+
 // Send disconnect order to remote connection
 func (self *SMGSession) disconnectSession(reason string) error {
 	type AttrDisconnectSession struct {
@@ -0,0 +1,123 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package sessionmanager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cgrates/cgrates/config"
+	"github.com/cgrates/cgrates/engine"
+	"github.com/cgrates/cgrates/utils"
+)
+
+type MockPublisher struct {
+	events []engine.CgrEvent
+}
+
+func (mp *MockPublisher) Subscribe(engine.SubscribeInfo, *string) error   { return nil }
+func (mp *MockPublisher) Unsubscribe(engine.SubscribeInfo, *string) error { return nil }
+func (mp *MockPublisher) Publish(ev engine.CgrEvent, reply *string) error {
+	mp.events = append(mp.events, ev)
+	return nil
+}
+func (mp *MockPublisher) ShowSubscribers(string, *map[string]*engine.SubscriberData) error {
+	return nil
+}
+
+func newTestSMGSession(minDur time.Duration, minCost float64) (*SMGSession, *MockPublisher) {
+	smgCfg, _ := config.NewDefaultCGRConfig()
+	smgCfg.SmGenericConfig.MinDurLowBalance = minDur
+	smgCfg.SmGenericConfig.MinCostLowBalance = minCost
+	pubsub := new(MockPublisher)
+	return &SMGSession{eventStart: SMGenericEvent{utils.ACCID: "12345"}, runId: utils.META_DEFAULT, cgrCfg: smgCfg,
+		pubsub: pubsub, extconns: NewSMGExternalConnections(), cd: &engine.CallDescriptor{Tenant: "cgrates.org", Account: "1001"}}, pubsub
+}
+
+// CallCost debiting 10s at 0.1 per second
+func newTestDebitCallCost(remainingUsage time.Duration, remainingCredit float64) *engine.CallCost {
+	tStart := time.Date(2015, 6, 10, 14, 7, 0, 0, time.UTC)
+	return &engine.CallCost{Cost: 1, RemainingUsage: remainingUsage, RemainingCredit: remainingCredit,
+		Timespans: engine.TimeSpans{&engine.TimeSpan{TimeStart: tStart, TimeEnd: tStart.Add(10 * time.Second)}}}
+}
+
+func TestSMGSessionCheckLowBalanceDuration(t *testing.T) {
+	s, pubsub := newTestSMGSession(time.Duration(60)*time.Second, 0)
+	s.checkLowBalance(newTestDebitCallCost(0, 10)) // 100s left
+	if len(pubsub.events) != 0 {
+		t.Errorf("Unexpected warnings: %+v", pubsub.events)
+	}
+	s.checkLowBalance(newTestDebitCallCost(time.Duration(20)*time.Second, 3)) // 20s out of units plus 30s out of credit
+	if len(pubsub.events) != 1 {
+		t.Fatalf("Expecting one warning, received: %+v", pubsub.events)
+	}
+	if pubsub.events[0]["EventName"] != utils.EVT_SESSION_LOW_BALANCE ||
+		pubsub.events[0]["RemainingUsage"] != "50" || pubsub.events[0]["RemainingCost"] != "3" {
+		t.Errorf("Unexpected warning: %+v", pubsub.events[0])
+	}
+	s.checkLowBalance(newTestDebitCallCost(0, 1))
+	if len(pubsub.events) != 1 {
+		t.Errorf("Expecting warning only once, received: %+v", pubsub.events)
+	}
+}
+
+func TestSMGSessionCheckLowBalanceCost(t *testing.T) {
+	s, pubsub := newTestSMGSession(0, 5)
+	s.checkLowBalance(newTestDebitCallCost(0, 5))
+	if len(pubsub.events) != 0 {
+		t.Errorf("Unexpected warnings: %+v", pubsub.events)
+	}
+	s.checkLowBalance(newTestDebitCallCost(0, 4.5))
+	if len(pubsub.events) != 1 || pubsub.events[0]["RemainingCost"] != "4.5" {
+		t.Errorf("Unexpected warnings: %+v", pubsub.events)
+	}
+}
+
+func TestSMGSessionCheckLowBalanceFreeUnits(t *testing.T) {
+	s, pubsub := newTestSMGSession(time.Duration(60)*time.Second, 0)
+	cc := newTestDebitCallCost(time.Duration(10)*time.Second, 10)
+	cc.Cost = 0 // Debited out of units, credit cannot be translated into usage
+	s.checkLowBalance(cc)
+	if len(pubsub.events) != 0 {
+		t.Errorf("Unexpected warnings: %+v", pubsub.events)
+	}
+	cc.RemainingCredit = 0
+	s.checkLowBalance(cc)
+	if len(pubsub.events) != 1 || pubsub.events[0]["RemainingUsage"] != "10" {
+		t.Errorf("Unexpected warnings: %+v", pubsub.events)
+	}
+}
+
+func TestSMGSessionWarnSessionFinalUnits(t *testing.T) {
+	s, pubsub := newTestSMGSession(0, 0)
+	s.warnSession(FINAL_UNITS, time.Duration(5)*time.Second, 0)
+	if len(pubsub.events) != 1 {
+		t.Fatalf("Expecting one warning, received: %+v", pubsub.events)
+	}
+	ev := pubsub.events[0]
+	if ev["EventName"] != utils.EVT_SESSION_FINAL_UNITS || ev["AccId"] != "12345" || ev["Account"] != "1001" ||
+		ev["RunId"] != utils.META_DEFAULT || ev["RemainingUsage"] != "5" {
+		t.Errorf("Unexpected warning: %+v", ev)
+	}
+	s.pubsub = nil // Without PubSub only the connection is warned
+	s.warnSession(FINAL_UNITS, 0, 0)
+	if len(pubsub.events) != 1 {
+		t.Errorf("Unexpected warnings: %+v", pubsub.events)
+	}
+}
@@ -30,9 +30,15 @@ import (
 	"github.com/cgrates/cgrates/utils"
 )
 
-func NewSMGeneric(cgrCfg *config.CGRConfig, rater engine.Connector, cdrsrv engine.Connector, timezone string, extconns *SMGExternalConnections) *SMGeneric {
-	gsm := &SMGeneric{cgrCfg: cgrCfg, rater: rater, cdrsrv: cdrsrv, extconns: extconns, timezone: timezone,
+func NewSMGeneric(cgrCfg *config.CGRConfig, rater engine.Connector, cdrsrv engine.Connector, pubsub engine.PublisherSubscriber,
+	timezone string, extconns *SMGExternalConnections) *SMGeneric {
+	gsm := &SMGeneric{cgrCfg: cgrCfg, rater: rater, cdrsrv: cdrsrv, pubsub: pubsub, extconns: extconns, timezone: timezone,
 		sessions: make(map[string][]*SMGSession), sessionsMux: new(sync.Mutex), guard: engine.NewGuardianLock()}
+	registerSessionsMetrics("SMGeneric", func() int {
+		gsm.sessionsMux.Lock()
+		defer gsm.sessionsMux.Unlock()
+		return len(gsm.sessions)
+	})
 	return gsm
 }
 
@@ -40,6 +46,7 @@ type SMGeneric struct {
 	cgrCfg      *config.CGRConfig // Separate from smCfg since there can be multiple
 	rater       engine.Connector
 	cdrsrv      engine.Connector
+	pubsub      engine.PublisherSubscriber // Optional, used to publish session warnings
 	timezone    string
 	sessions    map[string][]*SMGSession //Group sessions per sessionId, multiple runs based on derived charging
 	extconns    *SMGExternalConnections  // Reference towards external connections manager
@@ -91,7 +98,7 @@ func (self *SMGeneric) sessionStart(evStart SMGenericEvent, connId string) error
 		stopDebitChan := make(chan struct{})
 		for _, sessionRun := range sessionRuns {
 			s := &SMGSession{eventStart: evStart, connId: connId, runId: sessionRun.DerivedCharger.RunId, timezone: self.timezone,
-				rater: self.rater, cdrsrv: self.cdrsrv, cd: sessionRun.CallDescriptor}
+				cgrCfg: self.cgrCfg, rater: self.rater, cdrsrv: self.cdrsrv, pubsub: self.pubsub, extconns: self.extconns, cd: sessionRun.CallDescriptor}
 			self.indexSession(sessionId, s)
 			if self.cgrCfg.SmGenericConfig.DebitInterval != 0 {
 				s.stopDebit = stopDebitChan
@@ -168,10 +175,11 @@ func (self *SMGeneric) SessionUpdate(gev SMGenericEvent, clnt *rpc2.Client) (tim
 	for _, s := range self.getSession(evUuid) {
 		if maxDur, err := s.debit(evMaxUsage); err != nil {
 			return nilDuration, err
+		} else if maxDur < evMaxUsage {
+			s.warnSession(FINAL_UNITS, maxDur, 0)
+			evMaxUsage = maxDur
 		} else {
-			if maxDur < evMaxUsage {
-				evMaxUsage = maxDur
-			}
+			s.checkLowBalance(s.callCosts[len(s.callCosts)-1])
 		}
 	}
 	return evMaxUsage, nil
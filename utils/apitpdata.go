@@ -566,6 +566,7 @@ type ApiReloadCache struct {
 }
 
 type AttrCacheStats struct { // Add in the future filters here maybe so we avoid counting complete cache
+	Counters bool // Return also the hit, miss and eviction counters
 }
 
 type CacheStats struct {
@@ -582,6 +583,14 @@ type CacheStats struct {
 	Aliases         int
 	LastLoadId      string
 	LastLoadTime    string
+	Counters        map[string]*CacheCounters // Indexed on cache name, only when requested
+}
+
+type CacheCounters struct {
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
 }
 
 type AttrExpFileCdrs struct {
@@ -619,7 +628,7 @@ type AttrExpFileCdrs struct {
 	TimeEnd                    string   // If provided, it will represent the end of the CDRs interval (<)
 	SkipErrors                 bool     // Do not export errored CDRs
 	SkipRated                  bool     // Do not export rated CDRs
-	SuppressCgrIds             bool     // Disable CgrIds reporting in reply/ExportedCgrIds and reply/UnexportedCgrIds
+	SuppressCgrIds             bool     // Disable CgrIds reporting in reply/ExportedCgrIds and reply/UnexportedCgrIds, these are reported only for exports with a Limit
 	Paginator
 }
 
@@ -874,6 +883,7 @@ type CdrsFilter struct {
 	NotExtraFields      map[string]string // Filter out based on extra fields content
 	OrderIdStart        int64             // Export from this order identifier
 	OrderIdEnd          int64             // Export smaller than this order identifier
+	ExportIds           []string          // If provided, only CDRs marked by one of these exports
 	SetupTimeStart      *time.Time        // Start of interval, bigger or equal than configured
 	SetupTimeEnd        *time.Time        // End interval, smaller than setupTime
 	AnswerTimeStart     *time.Time        // Start of interval, bigger or equal than configured
@@ -1075,7 +1085,7 @@ type AttrExportCdrsToFile struct {
 	RoundDecimals              *int     // Overwrite configured roundDecimals with this dynamically, -1 to use general config ones
 	MaskDestinationId          *string  // Overwrite configured MaskDestId
 	MaskLength                 *int     // Overwrite configured MaskLength, -1 to use general config ones
-	SuppressCgrIds             bool     // Disable CgrIds reporting in reply/ExportedCgrIds and reply/UnexportedCgrIds
+	SuppressCgrIds             bool     // Disable CgrIds reporting in reply/ExportedCgrIds and reply/UnexportedCgrIds, these are reported only for exports with a Limit
 	RpcCdrsFilter                       // Inherit the CDR filter attributes
 }
 
@@ -1114,6 +1124,13 @@ type AttrGetCallCost struct {
 	RunId string // Run Id
 }
 
+// Credentials sent in-band over RPC connections, either ApiKey or Username and Password
+type AttrAuthenticate struct {
+	ApiKey   string
+	Username string
+	Password string
+}
+
 type TpAlias struct {
 	Direction string
 	Tenant    string
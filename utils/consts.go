@@ -27,6 +27,9 @@ var (
 	ErrInvalidKey              = errors.New("INVALID_KEY")
 	ErrUnauthorizedDestination = errors.New("UNAUTHORIZED_DESTINATION")
 	ErrAccountNotFound         = errors.New("AccountNotFound")
+	ErrAccountDisabled         = errors.New("ACCOUNT_DISABLED")
+	ErrInsufficientCredit      = errors.New("INSUFFICIENT_CREDIT")
+	ErrUnauthorized            = errors.New("UNAUTHORIZED")
 )
 
 const (
@@ -35,8 +38,10 @@ const (
 	REDIS_MAX_CONNS              = 10
 	POSTGRES                     = "postgres"
 	MYSQL                        = "mysql"
+	SQLITE                       = "sqlite"
 	MONGO                        = "mongo"
 	REDIS                        = "redis"
+	META_INTERNAL                = "*internal"
 	LOCALHOST                    = "127.0.0.1"
 	FSCDR_FILE_CSV               = "freeswitch_file_csv"
 	FSCDR_HTTP_JSON              = "freeswitch_http_json"
@@ -70,6 +75,8 @@ const (
 	TBL_CDRS_EXTRA               = "cdrs_extra"
 	TBL_COST_DETAILS             = "cost_details"
 	TBL_RATED_CDRS               = "rated_cdrs"
+	TBL_CDRS_EXPORTS             = "cdrs_exports"
+	TBL_CDRE_RUNS                = "cdre_runs"
 	TIMINGS_CSV                  = "Timings.csv"
 	DESTINATIONS_CSV             = "Destinations.csv"
 	RATES_CSV                    = "Rates.csv"
@@ -124,6 +131,7 @@ const (
 	PDD                          = "Pdd"
 	SUPPLIER                     = "Supplier"
 	MEDI_RUNID                   = "MediationRunId"
+	DESTINATION_ID               = "DestinationId"
 	RATED_ACCOUNT                = "RatedAccount"
 	RATED_SUBJECT                = "RatedSubject"
 	COST                         = "Cost"
@@ -135,6 +143,11 @@ const (
 	STATIC_VALUE_PREFIX          = "^"
 	CSV                          = "csv"
 	FWV                          = "fwv"
+	NDJSON                       = "ndjson"
+	XML                          = "xml"
+	GZIP                         = "gzip"
+	ZIP                          = "zip"
+	BZIP2                        = "bzip2"
 	DRYRUN                       = "dry_run"
 	META_COMBIMED                = "*combimed"
 	INTERNAL                     = "internal"
@@ -177,6 +190,7 @@ const (
 	SHARED_GROUP_PREFIX          = "shg_"
 	ACCOUNT_PREFIX               = "acc_"
 	DESTINATION_PREFIX           = "dst_"
+	REVERSE_DESTINATION_PREFIX   = "rds_"
 	LCR_PREFIX                   = "lcr_"
 	DERIVEDCHARGERS_PREFIX       = "dcs_"
 	CDR_STATS_QUEUE_PREFIX       = "csq_"
@@ -193,6 +207,12 @@ const (
 	LOG_CDR                      = "cdr_"
 	LOG_MEDIATED_CDR             = "mcd_"
 	LOADINST_KEY                 = "load_history"
+	CACHE_VERSION_KEY            = "cache_version"
+	CACHE_CHANGE_PREFIX          = "cch_"
+	CACHE_NODE_PREFIX            = "cnd_"
+	CACHE_CHANGES_CHANNEL        = "cgr_cache_changes"
+	AUTH_AUTHENTICATE            = "AuthV1.Authenticate"
+	AUTH_API_KEY_HEADER          = "X-API-Key"
 	SESSION_MANAGER_SOURCE       = "SMR"
 	MEDIATOR_SOURCE              = "MED"
 	CDRS_SOURCE                  = "CDRS"
@@ -228,6 +248,7 @@ const (
 	CGR_SUPPLIERS                = "cgr_suppliers"
 	KAM_FLATSTORE                = "kamailio_flatstore"
 	OSIPS_FLATSTORE              = "opensips_flatstore"
+	ASN1_BER                     = "asn1_ber"
 	MAX_DEBIT_CACHE_PREFIX       = "MAX_DEBIT_"
 	REFUND_INCR_CACHE_PREFIX     = "REFUND_INCR_"
 	GET_SESS_RUNS_CACHE_PREFIX   = "GET_SESS_RUNS_"
@@ -239,6 +260,8 @@ const (
 	META_SURETAX                 = "*sure_tax"
 	SURETAX                      = "suretax"
 	DIAMETER_AGENT               = "diameter_agent"
+	RADIUS_AGENT                 = "radius_agent"
+	CDRE_JOBS                    = "cdre_jobs"
 	COUNTER_EVENT                = "*event"
 	COUNTER_BALANCE              = "*balance"
 	EVENT_NAME                   = "EventName"
@@ -262,10 +285,33 @@ const (
 	MatchStartPrefix            = "^"
 	MatchEndPrefix              = "$"
 	SessionManagerGeneric       = "SMG"
+	META_TERMINATE              = "*terminate"
+	META_REDIRECT               = "*redirect"
+	META_HOURLY                 = "*hourly"
+	META_DAILY                  = "*daily"
+	META_MONTHLY                = "*monthly"
+	META_DELETE                 = "*delete"
+	META_ARCHIVE                = "*archive"
+	CACHE_DESTINATIONS          = "destinations"
+	CACHE_RATING_PLANS          = "rating_plans"
+	CACHE_RATING_PROFILES       = "rating_profiles"
+	CACHE_ACTIONS               = "actions"
+	CACHE_ACTION_PLANS          = "action_plans"
+	CACHE_SHARED_GROUPS         = "shared_groups"
+	CACHE_DERIVED_CHARGERS      = "derived_chargers"
+	CACHE_LCR                   = "lcr"
+	CACHE_ALIASES               = "aliases"
 )
 
 var (
-	CdreCdrFormats   = []string{CSV, DRYRUN, CDRE_FIXED_WIDTH}
+	CdreCdrFormats   = []string{CSV, DRYRUN, CDRE_FIXED_WIDTH, JSON, NDJSON, XML}
+	CdreCompressions = []string{"", GZIP, ZIP}
 	PrimaryCdrFields = []string{CGRID, TOR, ACCID, CDRHOST, CDRSOURCE, REQTYPE, DIRECTION, TENANT, CATEGORY, ACCOUNT, SUBJECT, DESTINATION, SETUP_TIME, PDD, ANSWER_TIME, USAGE,
 		SUPPLIER, DISCONNECT_CAUSE, COST, RATED}
+	CdrsAggregateFields      = []string{TENANT, ACCOUNT, DESTINATION_ID, SUPPLIER, MEDI_RUNID}
+	CdrsAggregateTimeBuckets = []string{"", META_HOURLY, META_DAILY, META_MONTHLY}
+	CachePrefixes            = map[string]string{CACHE_DESTINATIONS: DESTINATION_PREFIX, CACHE_RATING_PLANS: RATING_PLAN_PREFIX, CACHE_RATING_PROFILES: RATING_PROFILE_PREFIX,
+		CACHE_ACTIONS: ACTION_PREFIX, CACHE_ACTION_PLANS: ACTION_PLAN_PREFIX, CACHE_SHARED_GROUPS: SHARED_GROUP_PREFIX, CACHE_DERIVED_CHARGERS: DERIVEDCHARGERS_PREFIX,
+		CACHE_LCR: LCR_PREFIX, CACHE_ALIASES: ALIASES_PREFIX}
+	CachePartials = []string{CACHE_DESTINATIONS, CACHE_RATING_PLANS, CACHE_RATING_PROFILES, CACHE_ACTIONS, CACHE_SHARED_GROUPS, CACHE_DERIVED_CHARGERS, CACHE_LCR} // Caches which can be limited and loaded on demand
 )
@@ -2,4 +2,6 @@ package utils
 
 const (
 	EVT_ACCOUNT_BALANCE_MODIFIED = "ACCOUNT_BALANCE_MODIFIED"
+	EVT_SESSION_LOW_BALANCE      = "SESSION_LOW_BALANCE"
+	EVT_SESSION_FINAL_UNITS      = "SESSION_FINAL_UNITS"
 )
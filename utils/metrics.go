@@ -0,0 +1,338 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	METRIC_COUNTER   = "counter"
+	METRIC_GAUGE     = "gauge"
+	METRIC_HISTOGRAM = "histogram"
+)
+
+// Registry exposed by the Server on /metrics
+var Metrics = NewMetricsRegistry()
+
+// Histogram buckets for durations, in seconds
+var MetricsLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+var (
+	rpcCallDuration = Metrics.Histogram("cgr_rpc_call_duration_seconds", "Duration of the RPC calls served, per method.", MetricsLatencyBuckets, "method")
+	rpcCallErrors   = Metrics.Counter("cgr_rpc_call_errors_total", "RPC calls answered with an error, per method.", "method")
+)
+
+// One value out of a gauge collected on scrape
+type MetricSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type metricValue struct {
+	labelValues []string
+	value       float64  // Counter or gauge value, histogram sum
+	counts      []uint64 // Histogram observations per bucket, last one for +Inf
+	count       uint64
+}
+
+// Metric family with its values indexed on the label values
+type MetricVec struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+	mux        sync.Mutex
+	values     map[string]*metricValue
+	collectors []func() []*MetricSample // Gauges computed on scrape
+}
+
+func (mv *MetricVec) value(labelValues []string) *metricValue {
+	key := strings.Join(labelValues, "\xff")
+	val, hasIt := mv.values[key]
+	if !hasIt {
+		val = &metricValue{labelValues: append([]string(nil), labelValues...)}
+		if mv.kind == METRIC_HISTOGRAM {
+			val.counts = make([]uint64, len(mv.buckets)+1)
+		}
+		mv.values[key] = val
+	}
+	return val
+}
+
+// Adds to a counter or gauge
+func (mv *MetricVec) Add(v float64, labelValues ...string) {
+	mv.mux.Lock()
+	mv.value(labelValues).value += v
+	mv.mux.Unlock()
+}
+
+func (mv *MetricVec) Inc(labelValues ...string) {
+	mv.Add(1, labelValues...)
+}
+
+// Sets a gauge
+func (mv *MetricVec) Set(v float64, labelValues ...string) {
+	mv.mux.Lock()
+	mv.value(labelValues).value = v
+	mv.mux.Unlock()
+}
+
+// Records one observation into a histogram
+func (mv *MetricVec) Observe(v float64, labelValues ...string) {
+	idx := sort.SearchFloat64s(mv.buckets, v) // First bucket with upper bound >= v, len(buckets) for +Inf
+	mv.mux.Lock()
+	val := mv.value(labelValues)
+	val.counts[idx]++
+	val.count++
+	val.value += v
+	mv.mux.Unlock()
+}
+
+// Adds a function computing gauge samples on each scrape
+func (mv *MetricVec) AddCollector(collect func() []*MetricSample) {
+	mv.mux.Lock()
+	mv.collectors = append(mv.collectors, collect)
+	mv.mux.Unlock()
+}
+
+func (mv *MetricVec) writeTo(w io.Writer) {
+	mv.mux.Lock()
+	collectors := mv.collectors
+	values := make([]*metricValue, 0, len(mv.values))
+	for _, val := range mv.values {
+		cpVal := *val
+		cpVal.counts = append([]uint64(nil), val.counts...)
+		values = append(values, &cpVal)
+	}
+	mv.mux.Unlock()
+	for _, collect := range collectors { // Outside the lock since they can take their own
+		for _, smpl := range collect() {
+			values = append(values, &metricValue{labelValues: smpl.LabelValues, value: smpl.Value})
+		}
+	}
+	sort.Sort(metricValuesSorter(values))
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", mv.name, mv.help, mv.name, mv.kind)
+	for _, val := range values {
+		if mv.kind != METRIC_HISTOGRAM {
+			fmt.Fprintf(w, "%s%s %s\n", mv.name, formatMetricLabels(mv.labelNames, val.labelValues, "", ""), formatMetricValue(val.value))
+			continue
+		}
+		var cumulative uint64
+		for idx, count := range val.counts {
+			upperBound := math.Inf(1)
+			if idx < len(mv.buckets) {
+				upperBound = mv.buckets[idx]
+			}
+			cumulative += count
+			fmt.Fprintf(w, "%s_bucket%s %d\n", mv.name, formatMetricLabels(mv.labelNames, val.labelValues, "le", formatMetricValue(upperBound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", mv.name, formatMetricLabels(mv.labelNames, val.labelValues, "", ""), formatMetricValue(val.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", mv.name, formatMetricLabels(mv.labelNames, val.labelValues, "", ""), val.count)
+	}
+}
+
+type metricValuesSorter []*metricValue
+
+func (mvs metricValuesSorter) Len() int      { return len(mvs) }
+func (mvs metricValuesSorter) Swap(i, j int) { mvs[i], mvs[j] = mvs[j], mvs[i] }
+func (mvs metricValuesSorter) Less(i, j int) bool {
+	return strings.Join(mvs[i].labelValues, "\xff") < strings.Join(mvs[j].labelValues, "\xff")
+}
+
+func formatMetricLabels(labelNames, labelValues []string, extraName, extraValue string) string {
+	pairs := make([]string, 0, len(labelNames)+1)
+	for idx, labelName := range labelNames {
+		var labelValue string
+		if idx < len(labelValues) {
+			labelValue = labelValues[idx]
+		}
+		pairs = append(pairs, labelName+"="+strconv.Quote(labelValue))
+	}
+	if len(extraName) != 0 {
+		pairs = append(pairs, extraName+"="+strconv.Quote(extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Metric families in registration order, rendered in the Prometheus text format
+type MetricsRegistry struct {
+	mux     sync.RWMutex
+	metrics []*MetricVec
+	byName  map[string]*MetricVec
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{byName: make(map[string]*MetricVec)}
+}
+
+// Returns the already registered family with the same name so packages can register it independently
+func (mr *MetricsRegistry) register(name, help, kind string, buckets []float64, labelNames []string) *MetricVec {
+	mr.mux.Lock()
+	defer mr.mux.Unlock()
+	if mv, hasIt := mr.byName[name]; hasIt {
+		return mv
+	}
+	mv := &MetricVec{name: name, help: help, kind: kind, labelNames: labelNames, buckets: buckets, values: make(map[string]*metricValue)}
+	mr.metrics = append(mr.metrics, mv)
+	mr.byName[name] = mv
+	return mv
+}
+
+func (mr *MetricsRegistry) Counter(name, help string, labelNames ...string) *MetricVec {
+	return mr.register(name, help, METRIC_COUNTER, nil, labelNames)
+}
+
+func (mr *MetricsRegistry) Gauge(name, help string, labelNames ...string) *MetricVec {
+	return mr.register(name, help, METRIC_GAUGE, nil, labelNames)
+}
+
+// Buckets are upper bounds in increasing order
+func (mr *MetricsRegistry) Histogram(name, help string, buckets []float64, labelNames ...string) *MetricVec {
+	return mr.register(name, help, METRIC_HISTOGRAM, buckets, labelNames)
+}
+
+func (mr *MetricsRegistry) WriteMetrics(w io.Writer) {
+	mr.mux.RLock()
+	metrics := append([]*MetricVec(nil), mr.metrics...)
+	mr.mux.RUnlock()
+	for _, mv := range metrics {
+		mv.writeTo(w)
+	}
+}
+
+func (mr *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	mr.WriteMetrics(w)
+}
+
+// Seconds passed since start, as observed by the duration histograms
+func MetricsSince(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// Label of the calls to methods not registered, the method names sent by clients would otherwise grow the labels without limit
+const METRICS_UNKNOWN_METHOD = "unknown"
+
+// Methods registered on the Server, the only ones labeled on their own
+var metricsMethods = struct {
+	sync.RWMutex
+	names map[string]struct{}
+}{names: map[string]struct{}{AUTH_AUTHENTICATE: struct{}{}}}
+
+var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+
+// Adds the methods net/rpc serves out of rcvr under name
+func registerMetricsMethods(name string, rcvr interface{}) {
+	rcvrType := reflect.TypeOf(rcvr)
+	metricsMethods.Lock()
+	defer metricsMethods.Unlock()
+	for i := 0; i < rcvrType.NumMethod(); i++ {
+		method := rcvrType.Method(i)
+		if method.PkgPath != "" || method.Type.NumIn() != 3 || method.Type.NumOut() != 1 ||
+			method.Type.In(2).Kind() != reflect.Ptr || method.Type.Out(0) != typeOfError {
+			continue
+		}
+		metricsMethods.names[name+"."+method.Name] = struct{}{}
+	}
+}
+
+func metricsMethodLabel(method string) string {
+	metricsMethods.RLock()
+	defer metricsMethods.RUnlock()
+	if _, hasIt := metricsMethods.names[method]; !hasIt {
+		return METRICS_UNKNOWN_METHOD
+	}
+	return method
+}
+
+type rpcCallStart struct {
+	method string
+	start  time.Time
+}
+
+// Measures the calls between reading their request and writing their response
+type metricsServerCodec struct {
+	rpc.ServerCodec
+	mux     sync.Mutex
+	started map[uint64]*rpcCallStart // Indexed on request sequence
+}
+
+func newMetricsServerCodec(codec rpc.ServerCodec) *metricsServerCodec {
+	return &metricsServerCodec{ServerCodec: codec, started: make(map[uint64]*rpcCallStart)}
+}
+
+func (c *metricsServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	c.mux.Lock()
+	c.started[r.Seq] = &rpcCallStart{method: metricsMethodLabel(r.ServiceMethod), start: time.Now()}
+	c.mux.Unlock()
+	return nil
+}
+
+func (c *metricsServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.mux.Lock()
+	callStart, hasIt := c.started[r.Seq]
+	delete(c.started, r.Seq)
+	c.mux.Unlock()
+	if hasIt {
+		rpcCallDuration.Observe(MetricsSince(callStart.start), callStart.method)
+		if len(r.Error) != 0 {
+			rpcCallErrors.Inc(callStart.method)
+		}
+	}
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+// Wraps a BiJSON handler so its calls are measured like the RPC ones
+func metricsBijsonHandler(method string, handlerFunc interface{}) interface{} {
+	fn := reflect.ValueOf(handlerFunc)
+	return reflect.MakeFunc(fn.Type(), func(in []reflect.Value) []reflect.Value {
+		start := time.Now()
+		out := fn.Call(in)
+		rpcCallDuration.Observe(MetricsSince(start), method)
+		if !out[0].IsNil() {
+			rpcCallErrors.Inc(method)
+		}
+		return out
+	}).Interface()
+}
@@ -0,0 +1,93 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package utils
+
+import (
+	"bytes"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"testing"
+)
+
+func TestMetricsRegistryWrite(t *testing.T) {
+	mr := NewMetricsRegistry()
+	cntr := mr.Counter("test_calls_total", "Test calls.", "method")
+	cntr.Inc("B")
+	cntr.Add(2, "A")
+	if mr.Counter("test_calls_total", "Registered twice.", "method") != cntr {
+		t.Error("Expecting the already registered counter")
+	}
+	hist := mr.Histogram("test_duration_seconds", "Test durations.", []float64{0.1, 1}, "method")
+	hist.Observe(0.05, "A")
+	hist.Observe(0.5, "A")
+	hist.Observe(2, "A")
+	gauge := mr.Gauge("test_sessions", "Test sessions.", "sm")
+	gauge.AddCollector(func() []*MetricSample {
+		return []*MetricSample{&MetricSample{LabelValues: []string{"SMGeneric"}, Value: 3}}
+	})
+	buf := new(bytes.Buffer)
+	mr.WriteMetrics(buf)
+	eOut := `# HELP test_calls_total Test calls.
+# TYPE test_calls_total counter
+test_calls_total{method="A"} 2
+test_calls_total{method="B"} 1
+# HELP test_duration_seconds Test durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{method="A",le="0.1"} 1
+test_duration_seconds_bucket{method="A",le="1"} 2
+test_duration_seconds_bucket{method="A",le="+Inf"} 3
+test_duration_seconds_sum{method="A"} 2.55
+test_duration_seconds_count{method="A"} 3
+# HELP test_sessions Test sessions.
+# TYPE test_sessions gauge
+test_sessions{sm="SMGeneric"} 3
+`
+	if buf.String() != eOut {
+		t.Errorf("Expecting:\n%s\nreceived:\n%s", eOut, buf.String())
+	}
+}
+
+func TestMetricsServerCodec(t *testing.T) {
+	srvConn, clntConn := net.Pipe()
+	go rpc.ServeCodec(newMetricsServerCodec(jsonrpc.NewServerCodec(srvConn)))
+	clnt := jsonrpc.NewClient(clntConn)
+	defer clnt.Close()
+	var reply string
+	if err := clnt.Call("AuthTestV1.GetEcho", "test", &reply); err != nil {
+		t.Error(err)
+	}
+	if err := clnt.Call("AuthTestV1.Missing", "test", &reply); err == nil {
+		t.Error("Expecting error on missing method")
+	}
+	buf := new(bytes.Buffer)
+	Metrics.WriteMetrics(buf)
+	for _, eLine := range []string{ // Metrics are global, values depend on the calls of the runs before
+		`cgr_rpc_call_duration_seconds_count{method="AuthTestV1.GetEcho"} `,
+		`cgr_rpc_call_errors_total{method="unknown"} `,
+	} {
+		if !strings.Contains(buf.String(), eLine) {
+			t.Errorf("Expecting %s in:\n%s", eLine, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "AuthTestV1.Missing") {
+		t.Errorf("Not registered method labeled in:\n%s", buf.String())
+	}
+}
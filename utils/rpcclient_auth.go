@@ -0,0 +1,164 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+)
+
+// TLS configuration for the connections towards the listeners, server certificates verified against caFile or the system CAs, certFile presented to listeners requiring client certificates
+func NewClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	tlsCfg := new(tls.Config)
+	if len(caFile) != 0 {
+		caPEM, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("No certificates found in " + caFile)
+		}
+	}
+	if len(certFile) != 0 {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// Rpc connection over TLS and/or authenticated with AuthV1.Authenticate on every connect, reconnects the same way rpcclient does
+type AuthRpcClient struct {
+	addr       string
+	codec      string
+	tlsCfg     *tls.Config       // Plain connection if nil
+	creds      *AttrAuthenticate // Not authenticating if nil
+	reconnects int               // -1 for unlimited
+	mux        sync.RWMutex
+	client     *rpc.Client
+}
+
+func NewAuthRpcClient(addr string, connectAttempts, reconnects int, codec string, tlsCfg *tls.Config, creds *AttrAuthenticate) (*AuthRpcClient, error) {
+	if codec != GOB && codec != JSON {
+		return nil, fmt.Errorf("Unsupported codec: %s", codec)
+	}
+	c := &AuthRpcClient{addr: addr, codec: codec, tlsCfg: tlsCfg, creds: creds, reconnects: reconnects}
+	var err error
+	for i := 0; i < connectAttempts || i == 0; i++ {
+		if i != 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		if c.client, err = c.dial(); err == nil {
+			return c, nil
+		} else if err == ErrUnauthorized { // Retrying with the same credentials does not help
+			break
+		}
+	}
+	return nil, err
+}
+
+func (c *AuthRpcClient) dial() (*rpc.Client, error) {
+	var conn net.Conn
+	var err error
+	if c.tlsCfg != nil {
+		conn, err = tls.Dial("tcp", c.addr, c.tlsCfg)
+	} else {
+		conn, err = net.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var client *rpc.Client
+	if c.codec == JSON {
+		client = jsonrpc.NewClient(conn)
+	} else {
+		client = rpc.NewClient(conn)
+	}
+	if c.creds != nil {
+		var reply string
+		if err := client.Call(AUTH_AUTHENTICATE, c.creds, &reply); err != nil {
+			client.Close()
+			if err.Error() == ErrUnauthorized.Error() {
+				return nil, ErrUnauthorized
+			}
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// Replaces the broken client, unless another call did it meanwhile
+func (c *AuthRpcClient) reconnect(broken *rpc.Client) (*rpc.Client, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.client != broken {
+		return c.client, nil
+	}
+	client, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	broken.Close()
+	c.client = client
+	return client, nil
+}
+
+// Errors of the connection, the ones returned by the server are not considered
+func isConnectionError(err error) bool {
+	return err == rpc.ErrShutdown || err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+func (c *AuthRpcClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	c.mux.RLock()
+	client := c.client
+	c.mux.RUnlock()
+	err := client.Call(serviceMethod, args, reply)
+	for i := 0; isConnectionError(err) && (c.reconnects == -1 || i < c.reconnects); i++ {
+		if i != 0 {
+			time.Sleep(time.Duration(i) * time.Second)
+		}
+		newClient, connErr := c.reconnect(client)
+		if connErr == ErrUnauthorized {
+			return connErr
+		} else if connErr != nil {
+			continue // Keeps the connection error until a reconnect succeeds
+		}
+		client = newClient
+		err = client.Call(serviceMethod, args, reply)
+	}
+	return err
+}
+
+func (c *AuthRpcClient) Close() error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.client.Close()
+}
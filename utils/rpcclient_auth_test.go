@@ -0,0 +1,92 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package utils
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"testing"
+)
+
+// Serves AuthTestV1 with authentication, keeping the accepted connections so the test can break them
+type authTestListener struct {
+	net.Listener
+	mux   sync.Mutex
+	conns []net.Conn
+}
+
+func newAuthTestListener(t *testing.T) *authTestListener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	atl := &authTestListener{Listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atl.mux.Lock()
+			atl.conns = append(atl.conns, conn)
+			atl.mux.Unlock()
+			go rpc.ServeCodec(newAuthServerCodec(jsonrpc.NewServerCodec(conn), newServerAuth(testAuthCreds), nil))
+		}
+	}()
+	return atl
+}
+
+func (atl *authTestListener) closeConns() {
+	atl.mux.Lock()
+	defer atl.mux.Unlock()
+	for _, conn := range atl.conns {
+		conn.Close()
+	}
+	atl.conns = nil
+}
+
+func TestAuthRpcClient(t *testing.T) {
+	atl := newAuthTestListener(t)
+	defer atl.Close()
+	if _, err := NewAuthRpcClient(atl.Addr().String(), 3, 0, JSON, nil, &AttrAuthenticate{ApiKey: "wrong_key"}); err != ErrUnauthorized {
+		t.Error("Expecting unauthorized, received: ", err)
+	}
+	clnt, err := NewAuthRpcClient(atl.Addr().String(), 1, 1, JSON, nil, &AttrAuthenticate{ApiKey: "ro_key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clnt.Close()
+	var reply string
+	if err := clnt.Call("AuthTestV1.GetEcho", "test", &reply); err != nil {
+		t.Error(err)
+	} else if reply != "test" {
+		t.Error("Unexpected reply: ", reply)
+	}
+	if err := clnt.Call("AuthTestV1.SetEcho", "test", &reply); err == nil || err.Error() != ErrUnauthorized.Error() {
+		t.Error("Expecting unauthorized, received: ", err)
+	}
+	atl.closeConns()
+	if err := clnt.Call("AuthTestV1.GetEcho", "again", &reply); err != nil { // Authenticated again on reconnect
+		t.Error(err)
+	} else if reply != "again" {
+		t.Error("Unexpected reply: ", reply)
+	}
+}
@@ -19,6 +19,7 @@ package utils
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -26,6 +27,7 @@ import (
 	"net/http"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+	"reflect"
 
 	"github.com/cenkalti/rpc2"
 	"golang.org/x/net/websocket"
@@ -33,24 +35,61 @@ import (
 import _ "net/http/pprof"
 
 type Server struct {
-	rpcEnabled  bool
-	httpEnabled bool
-	bijsonSrv   *rpc2.Server
+	rpcEnabled bool
+	bijsonSrv  *rpc2.Server
+	tlsCfg     *tls.Config // Serve all listeners over TLS if not nil
+	auth       *serverAuth // Authenticate and authorize all requests if not nil
+}
+
+// Enables TLS on all listeners, call before serving
+func (s *Server) SetTLSConfig(tlsCfg *tls.Config) {
+	s.tlsCfg = tlsCfg
+}
+
+// Enables authentication with the credentials, call before registering services
+func (s *Server) SetAuthCredentials(credentials []*AuthCredential) {
+	s.auth = newServerAuth(credentials)
+}
+
+// Serves the RPC requests out of codec, measured and checked against perms when auth is enabled
+func (s *Server) serveCodec(codec rpc.ServerCodec, perms []string) {
+	codec = newMetricsServerCodec(codec)
+	if s.auth != nil {
+		codec = newAuthServerCodec(codec, s.auth, perms)
+	}
+	rpc.ServeCodec(codec)
+}
+
+// Permissions known upfront for a new connection, nil without auth
+func (s *Server) connPermissions(conn net.Conn) []string {
+	if s.auth == nil {
+		return nil
+	}
+	return s.auth.connPermissions(conn)
+}
+
+func (s *Server) listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil || s.tlsCfg == nil {
+		return l, err
+	}
+	return tls.NewListener(l, s.tlsCfg), nil
 }
 
 func (s *Server) RpcRegister(rcvr interface{}) {
 	rpc.Register(rcvr)
+	registerMetricsMethods(reflect.Indirect(reflect.ValueOf(rcvr)).Type().Name(), rcvr)
 	s.rpcEnabled = true
 }
 
 func (s *Server) RpcRegisterName(name string, rcvr interface{}) {
 	rpc.RegisterName(name, rcvr)
+	registerMetricsMethods(name, rcvr)
 	s.rpcEnabled = true
 }
 
 func (s *Server) RegisterHttpFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
 	http.HandleFunc(pattern, handler)
-	s.httpEnabled = true
 }
 
 // Registers a new BiJsonRpc name
@@ -58,6 +97,10 @@ func (s *Server) BijsonRegisterName(method string, handlerFunc interface{}) {
 	if s.bijsonSrv == nil {
 		s.bijsonSrv = rpc2.NewServer()
 	}
+	if s.auth != nil {
+		handlerFunc = s.auth.bijsonHandler(method, handlerFunc)
+	}
+	handlerFunc = metricsBijsonHandler(method, handlerFunc)
 	s.bijsonSrv.Handle(method, handlerFunc)
 }
 
@@ -75,7 +118,7 @@ func (s *Server) ServeJSON(addr string) {
 	if !s.rpcEnabled {
 		return
 	}
-	lJSON, e := net.Listen("tcp", addr)
+	lJSON, e := s.listen(addr)
 	if e != nil {
 		log.Fatal("ServeJSON listen error:", e)
 	}
@@ -87,7 +130,9 @@ func (s *Server) ServeJSON(addr string) {
 			continue
 		}
 		//utils.Logger.Info(fmt.Sprintf("<CGRServer> New incoming connection: %v", conn.RemoteAddr()))
-		go jsonrpc.ServeConn(conn)
+		go func(conn net.Conn) {
+			s.serveCodec(jsonrpc.NewServerCodec(conn), s.connPermissions(conn))
+		}(conn)
 	}
 
 }
@@ -96,7 +141,7 @@ func (s *Server) ServeGOB(addr string) {
 	if !s.rpcEnabled {
 		return
 	}
-	lGOB, e := net.Listen("tcp", addr)
+	lGOB, e := s.listen(addr)
 	if e != nil {
 		log.Fatal("ServeGOB listen error:", e)
 	}
@@ -109,7 +154,9 @@ func (s *Server) ServeGOB(addr string) {
 		}
 
 		//utils.Logger.Info(fmt.Sprintf("<CGRServer> New incoming connection: %v", conn.RemoteAddr()))
-		go rpc.ServeConn(conn)
+		go func(conn net.Conn) {
+			s.serveCodec(newGobServerCodec(conn), s.connPermissions(conn))
+		}(conn)
 	}
 }
 
@@ -118,46 +165,79 @@ func (s *Server) ServeHTTP(addr string) {
 		http.HandleFunc("/jsonrpc", func(w http.ResponseWriter, req *http.Request) {
 			defer req.Body.Close()
 			w.Header().Set("Content-Type", "application/json")
-			res := NewRPCRequest(req.Body).Call()
+			rpcReq := NewRPCRequest(req.Body)
+			rpcReq.srv = s
+			if s.auth != nil {
+				rpcReq.perms = s.auth.httpPermissions(req)
+			}
+			res := rpcReq.Call()
 			io.Copy(w, res)
 		})
 		http.Handle("/ws", websocket.Handler(func(ws *websocket.Conn) {
-			jsonrpc.ServeConn(ws)
+			var perms []string
+			if s.auth != nil {
+				perms = s.auth.httpPermissions(ws.Request())
+			}
+			s.serveCodec(jsonrpc.NewServerCodec(ws), perms)
 		}))
-		s.httpEnabled = true
 	}
-	if !s.httpEnabled {
-		return
+	http.Handle("/metrics", Metrics) // Always served so the engine can be monitored
+	var handler http.Handler         // DefaultServeMux if nil
+	if s.auth != nil {
+		handler = s.auth.httpHandler([]string{"/jsonrpc", "/ws"}, http.DefaultServeMux)
 	}
 	Logger.Info(fmt.Sprintf("Starting CGRateS HTTP server at %s.", addr))
-	http.ListenAndServe(addr, nil)
+	if s.tlsCfg == nil {
+		http.ListenAndServe(addr, handler)
+		return
+	}
+	httpSrv := &http.Server{Addr: addr, Handler: handler, TLSConfig: s.tlsCfg}
+	httpSrv.ListenAndServeTLS("", "") // Certificates out of TLSConfig
 }
 
 func (s *Server) ServeBiJSON(addr string) {
 	if s.bijsonSrv == nil {
 		return
 	}
-	lBiJSON, e := net.Listen("tcp", addr)
+	lBiJSON, e := s.listen(addr)
 	if e != nil {
 		log.Fatal("ServeBiJSON listen error:", e)
 	}
 	Logger.Info(fmt.Sprintf("Starting CGRateS BiJSON server at %s.", addr))
-	s.bijsonSrv.Accept(lBiJSON)
+	if s.auth == nil {
+		s.bijsonSrv.Accept(lBiJSON)
+		return
+	}
+	s.bijsonSrv.Handle(AUTH_AUTHENTICATE, s.auth.bijsonAuthenticate)
+	for {
+		conn, err := lBiJSON.Accept()
+		if err != nil {
+			Logger.Err(fmt.Sprintf("<CGRServer> BiJSON accept error: %v", err))
+			return
+		}
+		go func(conn net.Conn) {
+			state := rpc2.NewState()
+			state.Set(authPermsStateKey, s.auth.connPermissions(conn))
+			s.bijsonSrv.ServeCodecWithState(rpc2.NewGobCodec(conn), state)
+		}(conn)
+	}
 }
 
 // rpcRequest represents a RPC request.
 // rpcRequest implements the io.ReadWriteCloser interface.
 type rpcRequest struct {
-	r    io.Reader     // holds the JSON formated RPC request
-	rw   io.ReadWriter // holds the JSON formated RPC response
-	done chan bool     // signals then end of the RPC request
+	r     io.Reader     // holds the JSON formated RPC request
+	rw    io.ReadWriter // holds the JSON formated RPC response
+	done  chan bool     // signals then end of the RPC request
+	srv   *Server       // serves the request measured and checked if not nil
+	perms []string      // permissions of the HTTP request
 }
 
 // NewRPCRequest returns a new rpcRequest.
 func NewRPCRequest(r io.Reader) *rpcRequest {
 	var buf bytes.Buffer
 	done := make(chan bool)
-	return &rpcRequest{r, &buf, done}
+	return &rpcRequest{r: r, rw: &buf, done: done}
 }
 
 func (r *rpcRequest) Read(p []byte) (n int, err error) {
@@ -177,7 +257,11 @@ func (r *rpcRequest) Close() error {
 
 // Call invokes the RPC request, waits for it to complete, and returns the results.
 func (r *rpcRequest) Call() io.Reader {
-	go jsonrpc.ServeConn(r)
+	if r.srv == nil {
+		go jsonrpc.ServeConn(r)
+	} else {
+		go r.srv.serveCodec(jsonrpc.NewServerCodec(r), r.perms)
+	}
 	<-r.done
 	return r.rw
 }
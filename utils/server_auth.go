@@ -0,0 +1,297 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package utils
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/cenkalti/rpc2"
+)
+
+const authPermsStateKey = "auth_permissions" // rpc2 client State key holding the permissions of the connection
+
+// Credential accepted by the server, identified by any of ApiKey, Username and Password or client CertificateCN
+type AuthCredential struct {
+	ApiKey        string
+	Username      string
+	Password      string
+	CertificateCN string   // Common name of the TLS client certificate
+	Permissions   []string // Methods allowed, prefixes when ending in *, ie: ApierV1.Get*, HTTP paths for the non RPC handlers, * for all
+}
+
+// TLS configuration for the listeners, client certificates are required and verified if clientCAFile is provided
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if len(clientCAFile) != 0 {
+		caPEM, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = x509.NewCertPool()
+		if !tlsCfg.ClientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("No certificates found in " + clientCAFile)
+		}
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// Permissions of the credentials, indexed on the way they identify
+type serverAuth struct {
+	apiKeys   map[string][]string
+	users     map[string]*AuthCredential
+	certNames map[string][]string
+}
+
+func newServerAuth(credentials []*AuthCredential) *serverAuth {
+	sa := &serverAuth{apiKeys: make(map[string][]string), users: make(map[string]*AuthCredential), certNames: make(map[string][]string)}
+	for _, cred := range credentials {
+		if len(cred.ApiKey) != 0 {
+			sa.apiKeys[cred.ApiKey] = cred.Permissions
+		}
+		if len(cred.Username) != 0 {
+			sa.users[cred.Username] = cred
+		}
+		if len(cred.CertificateCN) != 0 {
+			sa.certNames[cred.CertificateCN] = cred.Permissions
+		}
+	}
+	return sa
+}
+
+func (sa *serverAuth) authenticate(attr *AttrAuthenticate) ([]string, error) {
+	if len(attr.ApiKey) != 0 {
+		if perms, hasIt := sa.apiKeys[attr.ApiKey]; hasIt {
+			return perms, nil
+		}
+	} else if cred, hasIt := sa.users[attr.Username]; hasIt && len(attr.Username) != 0 {
+		if subtle.ConstantTimeCompare([]byte(cred.Password), []byte(attr.Password)) == 1 {
+			return cred.Permissions, nil
+		}
+	}
+	return nil, ErrUnauthorized
+}
+
+// Permissions out of the client certificate, nil if none or unknown
+func (sa *serverAuth) tlsPermissions(state *tls.ConnectionState) []string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return sa.certNames[state.PeerCertificates[0].Subject.CommonName]
+}
+
+// Permissions of a new connection, known upfront only for TLS ones
+func (sa *serverAuth) connPermissions(conn net.Conn) []string {
+	tlsConn, isTLS := conn.(*tls.Conn)
+	if !isTLS {
+		return nil
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return nil // Connection unusable, reads will fail
+	}
+	state := tlsConn.ConnectionState()
+	return sa.tlsPermissions(&state)
+}
+
+// Permissions out of the api key header, basic authentication or client certificate, in this order
+func (sa *serverAuth) httpPermissions(req *http.Request) []string {
+	if apiKey := req.Header.Get(AUTH_API_KEY_HEADER); len(apiKey) != 0 {
+		perms, _ := sa.authenticate(&AttrAuthenticate{ApiKey: apiKey})
+		return perms
+	}
+	if username, password, hasBasic := req.BasicAuth(); hasBasic {
+		perms, _ := sa.authenticate(&AttrAuthenticate{Username: username, Password: password})
+		return perms
+	}
+	return sa.tlsPermissions(req.TLS)
+}
+
+// Checks the method, or HTTP path, against the permissions, matched as prefixes only when ending in *
+func authorized(perms []string, method string) bool {
+	for _, perm := range perms {
+		if strings.HasSuffix(perm, "*") {
+			if strings.HasPrefix(method, perm[:len(perm)-1]) {
+				return true
+			}
+		} else if method == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Rejects the non RPC HTTP requests not permitted on their path, RPC ones are checked per method by their codec
+func (sa *serverAuth) httpHandler(rpcPaths []string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, rpcPath := range rpcPaths {
+			if req.URL.Path == rpcPath {
+				handler.ServeHTTP(w, req)
+				return
+			}
+		}
+		if !authorized(sa.httpPermissions(req), req.URL.Path) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="CGRateS"`)
+			http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// Wraps a BiJSON handler so it only runs for connections permitted on method
+func (sa *serverAuth) bijsonHandler(method string, handlerFunc interface{}) interface{} {
+	fn := reflect.ValueOf(handlerFunc)
+	return reflect.MakeFunc(fn.Type(), func(in []reflect.Value) []reflect.Value {
+		var perms []string
+		if clnt, canCast := in[0].Interface().(*rpc2.Client); canCast && clnt.State != nil {
+			if statePerms, hasIt := clnt.State.Get(authPermsStateKey); hasIt {
+				perms = statePerms.([]string)
+			}
+		}
+		if !authorized(perms, method) {
+			err := ErrUnauthorized
+			return []reflect.Value{reflect.ValueOf(&err).Elem()}
+		}
+		return fn.Call(in)
+	}).Interface()
+}
+
+// In-band authentication for BiJSON connections
+func (sa *serverAuth) bijsonAuthenticate(clnt *rpc2.Client, attr AttrAuthenticate, reply *string) error {
+	perms, err := sa.authenticate(&attr)
+	if err != nil {
+		return err
+	}
+	clnt.State.Set(authPermsStateKey, perms)
+	*reply = OK
+	return nil
+}
+
+// Checks every request against the permissions of the connection, answering itself the unauthorized ones and the in-band authentication
+type authServerCodec struct {
+	rpc.ServerCodec
+	auth    *serverAuth
+	perms   []string   // Only accessed by the reading goroutine
+	sendMux sync.Mutex // Our responses interleave with the ones of the rpc server
+}
+
+func newAuthServerCodec(codec rpc.ServerCodec, auth *serverAuth, perms []string) *authServerCodec {
+	return &authServerCodec{ServerCodec: codec, auth: auth, perms: perms}
+}
+
+func (c *authServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	for {
+		if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+			return err
+		}
+		var reply string
+		var errStr string
+		if r.ServiceMethod == AUTH_AUTHENTICATE {
+			var attr AttrAuthenticate
+			if err := c.ServerCodec.ReadRequestBody(&attr); err != nil {
+				return err
+			}
+			if perms, err := c.auth.authenticate(&attr); err != nil {
+				errStr = err.Error()
+			} else {
+				c.perms = perms
+				reply = OK
+			}
+		} else if authorized(c.perms, r.ServiceMethod) {
+			return nil
+		} else {
+			if err := c.ServerCodec.ReadRequestBody(nil); err != nil { // Discard the params
+				return err
+			}
+			errStr = ErrUnauthorized.Error()
+		}
+		if err := c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: errStr}, reply); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *authServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	c.sendMux.Lock()
+	defer c.sendMux.Unlock()
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+// Same as the gob codec used by rpc.ServeConn, not exported by net/rpc
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) *gobServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{rwc: conn, dec: gob.NewDecoder(conn), enc: gob.NewEncoder(buf), encBuf: buf}
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil { // Gob couldn't encode the header, shut down
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil { // Was a gob problem encoding the body but the header has been written
+			c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() error {
+	if c.closed { // Only call c.rwc.Close once; otherwise the semantics are undefined
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
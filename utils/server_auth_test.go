@@ -0,0 +1,125 @@
+/*
+Real-time Charging System for Telecom & ISP environments
+Copyright (C) 2012-2015 ITsysCOM GmbH
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <http://www.gnu.org/licenses/>
+*/
+
+package utils
+
+import (
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"testing"
+)
+
+type AuthTestV1 struct{}
+
+func (self *AuthTestV1) GetEcho(arg string, reply *string) error {
+	*reply = arg
+	return nil
+}
+
+func (self *AuthTestV1) SetEcho(arg string, reply *string) error {
+	*reply = arg
+	return nil
+}
+
+func init() {
+	new(Server).RpcRegister(new(AuthTestV1))
+}
+
+var testAuthCreds = []*AuthCredential{
+	&AuthCredential{ApiKey: "ro_key", Permissions: []string{"AuthTestV1.Get*"}},
+	&AuthCredential{Username: "admin", Password: "secret", Permissions: []string{"*"}},
+}
+
+func TestAuthAuthorized(t *testing.T) {
+	if !authorized([]string{"ApierV1.Get*"}, "ApierV1.GetCacheStats") {
+		t.Error("Expecting authorized on prefix")
+	}
+	if authorized([]string{"ApierV1.Get*"}, "ApierV1.SetAccount") {
+		t.Error("Not expecting authorized outside prefix")
+	}
+	if !authorized([]string{"*"}, "/cdr_http") {
+		t.Error("Expecting authorized on all")
+	}
+	if !authorized([]string{"ApierV1.GetAccount"}, "ApierV1.GetAccount") {
+		t.Error("Expecting authorized on exact method")
+	}
+	if authorized([]string{"ApierV1.GetAccount"}, "ApierV1.GetAccounts") {
+		t.Error("Not expecting authorized on method prefixed by a permission without *")
+	}
+	if authorized(nil, "ApierV1.GetCacheStats") {
+		t.Error("Not expecting authorized without permissions")
+	}
+}
+
+func TestAuthServerCodec(t *testing.T) {
+	srvConn, clntConn := net.Pipe()
+	go rpc.ServeCodec(newAuthServerCodec(jsonrpc.NewServerCodec(srvConn), newServerAuth(testAuthCreds), nil))
+	clnt := jsonrpc.NewClient(clntConn)
+	defer clnt.Close()
+	var reply string
+	if err := clnt.Call("AuthTestV1.GetEcho", "test", &reply); err == nil || err.Error() != ErrUnauthorized.Error() {
+		t.Error("Expecting unauthorized, received: ", err)
+	}
+	if err := clnt.Call(AUTH_AUTHENTICATE, AttrAuthenticate{ApiKey: "wrong_key"}, &reply); err == nil || err.Error() != ErrUnauthorized.Error() {
+		t.Error("Expecting unauthorized, received: ", err)
+	}
+	if err := clnt.Call(AUTH_AUTHENTICATE, AttrAuthenticate{ApiKey: "ro_key"}, &reply); err != nil {
+		t.Error(err)
+	} else if reply != OK {
+		t.Error("Unexpected reply: ", reply)
+	}
+	if err := clnt.Call("AuthTestV1.GetEcho", "test", &reply); err != nil {
+		t.Error(err)
+	} else if reply != "test" {
+		t.Error("Unexpected reply: ", reply)
+	}
+	if err := clnt.Call("AuthTestV1.SetEcho", "test", &reply); err == nil || err.Error() != ErrUnauthorized.Error() {
+		t.Error("Expecting unauthorized, received: ", err)
+	}
+	if err := clnt.Call(AUTH_AUTHENTICATE, AttrAuthenticate{Username: "admin", Password: "secret"}, &reply); err != nil {
+		t.Error(err)
+	}
+	if err := clnt.Call("AuthTestV1.SetEcho", "test2", &reply); err != nil {
+		t.Error(err)
+	} else if reply != "test2" {
+		t.Error("Unexpected reply: ", reply)
+	}
+}
+
+func TestAuthHttpPermissions(t *testing.T) {
+	sa := newServerAuth(testAuthCreds)
+	req, _ := http.NewRequest("POST", "http://127.0.0.1:2080/jsonrpc", nil)
+	if perms := sa.httpPermissions(req); perms != nil {
+		t.Error("Unexpected permissions: ", perms)
+	}
+	req.Header.Set(AUTH_API_KEY_HEADER, "ro_key")
+	if perms := sa.httpPermissions(req); len(perms) != 1 || perms[0] != "AuthTestV1.Get*" {
+		t.Error("Unexpected permissions: ", perms)
+	}
+	req.Header.Del(AUTH_API_KEY_HEADER)
+	req.SetBasicAuth("admin", "wrong")
+	if perms := sa.httpPermissions(req); perms != nil {
+		t.Error("Unexpected permissions: ", perms)
+	}
+	req.SetBasicAuth("admin", "secret")
+	if perms := sa.httpPermissions(req); len(perms) != 1 || perms[0] != "*" {
+		t.Error("Unexpected permissions: ", perms)
+	}
+}